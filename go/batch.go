@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// isBatch reports whether a message is a JSON-RPC batch, an array of
+// messages.
+func isBatch(line []byte) bool {
+	trimmed := bytes.TrimLeft(line, " \t\r\n")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// handleBatch processes a JSON-RPC batch, which protocol versions before
+// 2025-06-18 allow. Its messages are handled as if sent one by one, and
+// once every request in it has been answered the responses are sent in one
+// array. A batch of notifications and responses only gets no answer.
+// Requests to the client made while handling it are sent right away.
+func (s *Server) handleBatch(sess *session, line []byte) {
+	w := sess.w
+	if !sess.lifecycle.allowsBatches() {
+		sendError(w, nil, -32600, "Invalid Request: batches are not supported in this protocol version")
+		return
+	}
+	var messages []json.RawMessage
+	if err := json.Unmarshal(line, &messages); err != nil {
+		sendError(w, nil, -32700, "Parse error")
+		return
+	}
+	if len(messages) == 0 {
+		sendError(w, nil, -32600, "Invalid Request: empty batch")
+		return
+	}
+
+	var out bytes.Buffer
+	batch := &session{
+		state:     sess.state,
+		ctx:       sess.ctx,
+		shutdown:  sess.shutdown,
+		w:         s.sessionWriter(&out),
+		limiter:   sess.limiter,
+		access:    sess.access,
+		requests:  sess.requests,
+		lifecycle: sess.lifecycle,
+		toClient:  sess.clientWriter(),
+	}
+	for _, msg := range messages {
+		if isBatch(msg) {
+			sendError(batch.w, nil, -32600, "Invalid Request")
+			continue
+		}
+		s.handleLine(batch, msg)
+	}
+
+	sess.inflight.Add(1)
+	go func() {
+		defer sess.inflight.Done()
+		batch.inflight.Wait()
+		responses := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+		if len(responses[0]) == 0 {
+			return
+		}
+		var answer bytes.Buffer
+		answer.WriteByte('[')
+		answer.Write(bytes.Join(responses, []byte(",")))
+		answer.WriteString("]\n")
+		w.Write(answer.Bytes())
+	}()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// maxBatchCalls is the largest number of calls one batch_call may make.
+const maxBatchCalls = 32
+
+// WithBatchCallTool adds the built-in batch_call tool, which makes several
+// tool calls at once.
+func WithBatchCallTool() Option {
+	return func(s *Server) {
+		s.batchCallTool = true
+	}
+}
+
+// callSessionKey is the context key of the session a tool call belongs
+// to, which batch_call makes its calls in.
+type callSessionKey struct{}
+
+// batchCallTool runs a list of tool calls concurrently, each as if the
+// client had sent it: with the same rate limits, validation, budget,
+// concurrency limits, confirmation, and hooks. The calls share the
+// deadline of the batch.
+type batchCallTool struct {
+	server *Server
+}
+
+// batchCallArgs are the arguments of the batch_call tool.
+type batchCallArgs struct {
+	Calls []batchCallItem `json:"calls" description:"The tool calls to make, at once"`
+}
+
+// batchCallItem is one call of a batch.
+type batchCallItem struct {
+	Name      string                 `json:"name" description:"Name of the tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty" description:"Arguments of the call"`
+}
+
+// batchCallResult is the outcome of one call of a batch: the result of a
+// tools/call request, which may be flagged as an error, or the error it
+// was answered with.
+type batchCallResult struct {
+	Name   string          `json:"name"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *JSONRPCError   `json:"error,omitempty"`
+}
+
+// Name returns the name of the batch_call tool.
+func (t *batchCallTool) Name() string {
+	return "batch_call"
+}
+
+// Description returns a brief description of the batch_call tool.
+func (t *batchCallTool) Description() string {
+	return fmt.Sprintf("Calls up to %d tools at once and returns the result or error of each, in order, saving a round trip per call", maxBatchCalls)
+}
+
+// InputSchema returns the JSON schema for the batch_call tool's input parameters.
+func (t *batchCallTool) InputSchema() map[string]interface{} {
+	schema := mcp.SchemaFor(batchCallArgs{})
+	calls := schema["properties"].(map[string]interface{})["calls"].(map[string]interface{})
+	calls["minItems"], calls["maxItems"] = 1, maxBatchCalls
+	return schema
+}
+
+// Annotations leaves it to the calls of a batch to be confirmed when they
+// are destructive.
+func (t *batchCallTool) Annotations() ToolAnnotations {
+	destructive := false
+	return ToolAnnotations{Title: "Batch call", DestructiveHint: &destructive}
+}
+
+// Execute cannot make calls outside a session.
+func (t *batchCallTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext makes the calls and returns their results as text.
+func (t *batchCallTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult makes the calls, at most as many at once as the server has
+// workers, and returns their results as structured content.
+func (t *batchCallTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	sess, _ := ctx.Value(callSessionKey{}).(*session)
+	if sess == nil {
+		return nil, errors.New("batch_call can only be called by a client")
+	}
+	var a batchCallArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if len(a.Calls) == 0 || len(a.Calls) > maxBatchCalls {
+		return nil, fmt.Errorf("invalid value for 'calls': expected 1 to %d calls, got %d", maxBatchCalls, len(a.Calls))
+	}
+
+	results := make([]batchCallResult, len(a.Calls))
+	slots := make(chan struct{}, cap(t.server.workers))
+	var wg sync.WaitGroup
+	for i, item := range a.Calls {
+		results[i].Name = item.Name
+		if item.Name == t.Name() {
+			results[i].Error = &JSONRPCError{Code: -32602, Message: "batch_call cannot call itself"}
+			continue
+		}
+		wg.Add(1)
+		go func(r *batchCallResult, item batchCallItem) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				r.Error = &JSONRPCError{Code: codeRequestTimeout, Message: "The batch ended before the call started"}
+				return
+			}
+			t.call(ctx, sess, item, r)
+		}(&results[i], item)
+	}
+	wg.Wait()
+	return mcp.NewResult().WithStructured(map[string]interface{}{"results": results}), nil
+}
+
+// call makes one call of a batch in sess, as a tools/call request whose
+// response is captured into r.
+func (t *batchCallTool) call(ctx context.Context, sess *session, item batchCallItem, r *batchCallResult) {
+	if item.Arguments == nil {
+		item.Arguments = map[string]interface{}{}
+	}
+	var out bytes.Buffer
+	itemSess := &session{
+		state:     sess.state,
+		ctx:       ctx,
+		shutdown:  sess.shutdown,
+		w:         t.server.sessionWriter(&out),
+		limiter:   sess.limiter,
+		access:    sess.access,
+		requests:  sess.requests,
+		lifecycle: sess.lifecycle,
+		toClient:  sess.clientWriter(),
+	}
+	call, callErr := t.server.prepareToolCall(itemSess, item.Name, item.Arguments)
+	if callErr != nil {
+		r.Error = callErr
+		return
+	}
+	deadline, _ := ctx.Deadline()
+	t.server.runToolCall(itemSess, itemSess.w, 0, call.tool, call.args, call.dryRun, nil, deadline)
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *JSONRPCError   `json:"error"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		r.Error = &JSONRPCError{Code: -32603, Message: "Internal error during tool execution"}
+		return
+	}
+	r.Result, r.Error = resp.Result, resp.Error
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// barrierTool returns once as many calls as it expects are running.
+type barrierTool struct {
+	wg sync.WaitGroup
+}
+
+func (b *barrierTool) Name() string        { return "barrier" }
+func (b *barrierTool) Description() string { return "Waits for the other calls" }
+func (b *barrierTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (b *barrierTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	b.wg.Done()
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return []ToolContent{{Type: "text", Text: "together"}}, nil
+	case <-time.After(2 * time.Second):
+		return nil, toolFailure("the other calls did not run at the same time")
+	}
+}
+
+// Test that the calls of a batch run at once and each get their own
+// result or error
+func TestBatchCall(t *testing.T) {
+	barrier := &barrierTool{}
+	barrier.wg.Add(2)
+	s := NewServer(WithTools(&echoTool{}, barrier), WithBatchCallTool())
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"batch_call","arguments":{"calls":[
+		{"name":"barrier"},
+		{"name":"barrier"},
+		{"name":"echo","arguments":{"message":"hi"}},
+		{"name":"echo"},
+		{"name":"missing"},
+		{"name":"batch_call","arguments":{"calls":[]}}
+	]}},"id":1}`+"\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one response, got %q", lines)
+	}
+	var resp struct {
+		Result struct {
+			Structured struct {
+				Results []batchCallResult `json:"results"`
+			} `json:"structuredContent"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatal(err)
+	}
+	results := resp.Result.Structured.Results
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results, got %s", lines[0])
+	}
+	for i, want := range []string{`"text":"together"`, `"text":"together"`, `"text":"Echo: hi"`} {
+		if !strings.Contains(string(results[i].Result), want) || results[i].Error != nil {
+			t.Errorf("call %d: expected %s, got %+v", i+1, want, results[i])
+		}
+	}
+	for i, code := range map[int]int{3: -32602, 4: -32601, 5: -32602} {
+		if results[i].Error == nil || results[i].Error.Code != code {
+			t.Errorf("call %d: expected error %d, got %+v", i+1, code, results[i])
+		}
+	}
+}
+
+// Test that batch_call refuses to run outside a session
+func TestBatchCallWithoutSession(t *testing.T) {
+	tool := &batchCallTool{server: NewServer()}
+	if _, err := tool.ExecuteContext(context.Background(), map[string]interface{}{"calls": []interface{}{}}); err == nil {
+		t.Error("expected an error")
+	}
+}
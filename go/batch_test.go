@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Test that a batch is answered with one array of its responses
+func TestBatch(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{}},"id":0}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`[{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"a"}},"id":1},` +
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":9}},` +
+		`{"jsonrpc":"2.0","method":"ping","id":2},[]]` + "\n" +
+		`[{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":9}}]` + "\n" +
+		`[]` + "\n"
+	lines := runServerInput(t, NewServer(WithTools(&echoTool{})), input)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines output, got %v", lines)
+	}
+	// Lines may come in any order, so tell the batch from the other
+	// responses by their shape.
+	type response struct {
+		ID     json.RawMessage `json:"id"`
+		Error  *JSONRPCError   `json:"error"`
+		Result json.RawMessage `json:"result"`
+	}
+	var batch []response
+	single := map[string]response{}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "[") {
+			if batch != nil {
+				t.Fatalf("expected one array of responses, got %v", lines)
+			}
+			if err := json.Unmarshal([]byte(line), &batch); err != nil {
+				t.Fatalf("expected an array of responses, got %s", line)
+			}
+			if !strings.Contains(line, "Echo: a") {
+				t.Errorf("expected the echo result in the batch, got %s", line)
+			}
+			continue
+		}
+		var r response
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("expected a response, got %s", line)
+		}
+		single[string(r.ID)] = r
+	}
+	ids := map[string]bool{}
+	for _, r := range batch {
+		ids[string(r.ID)] = true
+	}
+	if len(batch) != 3 || !ids["1"] || !ids["2"] || !ids["null"] {
+		t.Errorf("unexpected batch responses %v", lines)
+	}
+	if r, ok := single["0"]; !ok || r.Result == nil {
+		t.Errorf("expected the initialize response, got %v", lines)
+	}
+	if r, ok := single["null"]; !ok || r.Error == nil || !strings.Contains(r.Error.Message, "empty batch") {
+		t.Errorf("expected an error for an empty batch, got %v", lines)
+	}
+}
+
+// Test that batches are rejected in protocol versions without them
+func TestBatchUnsupported(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-06-18","capabilities":{}},"id":0}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`[{"jsonrpc":"2.0","method":"ping","id":1}]` + "\n"
+	lines := runServerInput(t, NewServer(), input)
+	if len(lines) != 2 || !strings.Contains(lines[1], `"id":null,"error":{"code":-32600,"message":"Invalid Request: batches are not supported`) {
+		t.Errorf("expected the batch to be rejected, got %v", lines)
+	}
+}
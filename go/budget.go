@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// codeBudgetExceeded is the implementation-defined JSON-RPC error code
+// sent when a session has used up its budget.
+const codeBudgetExceeded = -32004
+
+// Budget bounds what the tool calls of one session may consume in total.
+// Zero fields are unlimited. The call that reaches a limit still
+// completes; the calls after it are rejected.
+type Budget struct {
+	ToolCalls     int           // tool calls started
+	ExecutionTime time.Duration // time spent executing tool calls
+	ResultBytes   int64         // encoded size of the tool results sent
+}
+
+// budgetExceededData is the "data" member of a budget exceeded error,
+// telling the client which limit was hit.
+type budgetExceededData struct {
+	Limit string  `json:"limit"` // "toolCalls", "executionTime" or "resultBytes"
+	Max   float64 `json:"max"`   // seconds for executionTime
+	Used  float64 `json:"used"`
+}
+
+// WithSessionBudget limits what the tool calls of each session may
+// consume in total. Calls once a limit is reached are rejected with a
+// budget exceeded error.
+func WithSessionBudget(b Budget) Option {
+	return func(s *Server) {
+		s.sessionBudget = b
+	}
+}
+
+// budgetUsage is what the tool calls of a session consumed.
+type budgetUsage struct {
+	mu            sync.Mutex
+	toolCalls     int
+	executionTime time.Duration
+	resultBytes   int64
+}
+
+// start counts a tool call, unless the session has reached a limit of b,
+// for which it returns the error data instead.
+func (u *budgetUsage) start(b Budget) *budgetExceededData {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	switch {
+	case b.ToolCalls > 0 && u.toolCalls >= b.ToolCalls:
+		return &budgetExceededData{Limit: "toolCalls", Max: float64(b.ToolCalls), Used: float64(u.toolCalls)}
+	case b.ExecutionTime > 0 && u.executionTime >= b.ExecutionTime:
+		return &budgetExceededData{Limit: "executionTime", Max: b.ExecutionTime.Seconds(), Used: u.executionTime.Seconds()}
+	case b.ResultBytes > 0 && u.resultBytes >= b.ResultBytes:
+		return &budgetExceededData{Limit: "resultBytes", Max: float64(b.ResultBytes), Used: float64(u.resultBytes)}
+	}
+	u.toolCalls++
+	return nil
+}
+
+// add records the execution time and result size of a finished call.
+func (u *budgetUsage) add(elapsed time.Duration, size int) {
+	u.mu.Lock()
+	u.executionTime += elapsed
+	u.resultBytes += int64(size)
+	u.mu.Unlock()
+}
+
+// budgetExceededMessage returns the error message for data.
+func budgetExceededMessage(data *budgetExceededData) string {
+	switch data.Limit {
+	case "executionTime":
+		return fmt.Sprintf("Session budget exceeded: tool calls ran for %gs of %gs", data.Used, data.Max)
+	case "resultBytes":
+		return fmt.Sprintf("Session budget exceeded: tool results took %g of %g bytes", data.Used, data.Max)
+	}
+	return fmt.Sprintf("Session budget exceeded: %g of %g tool calls made", data.Used, data.Max)
+}
+
+// parseBudget parses a comma-separated list of "calls=N", "time=DURATION"
+// and "bytes=N" limits.
+func parseBudget(s string) (Budget, error) {
+	var b Budget
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(entry, "=")
+		var err error
+		switch name {
+		case "calls":
+			b.ToolCalls, err = strconv.Atoi(value)
+		case "time":
+			b.ExecutionTime, err = time.ParseDuration(value)
+		case "bytes":
+			b.ResultBytes, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return Budget{}, fmt.Errorf("invalid budget %q: expected calls=N, time=DURATION, or bytes=N", entry)
+		}
+		if err == nil && (b.ToolCalls < 0 || b.ExecutionTime < 0 || b.ResultBytes < 0) {
+			err = errors.New("must not be negative")
+		}
+		if err != nil {
+			return Budget{}, fmt.Errorf("invalid budget %q: %w", entry, err)
+		}
+	}
+	return b, nil
+}
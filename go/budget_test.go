@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test parsing budgets
+func TestParseBudget(t *testing.T) {
+	b, err := parseBudget("calls=10, time=1m,bytes=1048576")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b != (Budget{ToolCalls: 10, ExecutionTime: time.Minute, ResultBytes: 1 << 20}) {
+		t.Errorf("unexpected budget %+v", b)
+	}
+	for _, s := range []string{"calls", "calls=-1", "time=soon", "tokens=5"} {
+		if _, err := parseBudget(s); err == nil {
+			t.Errorf("expected an error for %q", s)
+		}
+	}
+}
+
+// Test that calls beyond the number of calls in the budget are rejected
+func TestSessionBudgetCalls(t *testing.T) {
+	call := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":%d}` + "\n"
+	s := NewServer(WithSessionBudget(Budget{ToolCalls: 2}))
+	out := strings.Join(runServerInput(t, s, fmt.Sprintf(call, 1)+fmt.Sprintf(call, 2)+fmt.Sprintf(call, 3)), "\n")
+	for _, want := range []string{
+		`{"id":1,"jsonrpc":"2.0","result":`,
+		`{"id":2,"jsonrpc":"2.0","result":`,
+		`"id":3,"error":{"code":-32004,"message":"Session budget exceeded: 2 of 2 tool calls made","data":{"limit":"toolCalls","max":2,"used":2}}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in output:\n%s", want, out)
+		}
+	}
+}
+
+// Test that calls are rejected once the results sent reach the budget
+func TestSessionBudgetResultBytes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pw, out, _ := serveInBackground(ctx, NewServer(WithSessionBudget(Budget{ResultBytes: 10})))
+	defer pw.Close()
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":1}`)
+	waitForOutput(t, out, regexp.MustCompile(`Echo: hi`), 1)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":2}`)
+	waitForOutput(t, out, regexp.MustCompile(`"code":-32004,.*"data":\{"limit":"resultBytes","max":10,"used":\d+\}`), 1)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to bufferPool, so that one
+// huge message does not pin its memory for the rest of the process.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool holds the buffers that messages are read into and encoded in.
+// Hosts that issue bursts of small requests would otherwise allocate and
+// grow a fresh buffer for every message in each direction.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns b to the pool. b must not be used afterwards.
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	bufferPool.Put(b)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Test that pooled buffers come back empty and huge ones are not kept
+func TestBufferPool(t *testing.T) {
+	b := getBuffer()
+	b.WriteString("leftover")
+	putBuffer(b)
+	if b.Len() != 0 {
+		t.Errorf("expected a returned buffer to be reset")
+	}
+	if got := getBuffer(); got.Len() != 0 {
+		t.Errorf("expected an empty buffer, got %q", got.String())
+	}
+
+	big := bytes.NewBuffer(make([]byte, 0, 2*maxPooledBuffer))
+	big.WriteString("kept")
+	putBuffer(big)
+	if big.String() != "kept" {
+		t.Errorf("expected an oversized buffer to be left alone")
+	}
+}
+
+// Test that reading and answering requests reuses the pooled buffers
+// instead of allocating new ones for every message
+func TestMessageBuffersReused(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items at random under the race detector")
+	}
+	input := strings.NewReader(strings.Repeat(`{"jsonrpc":"2.0","method":"tools/list","id":1}`+"\n", 1000))
+	mr := newMessageReader(input, 0)
+	allocs := testing.AllocsPerRun(100, func() {
+		line, err := mr.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		putBuffer(line)
+	})
+	if allocs != 0 {
+		t.Errorf("expected reading a message not to allocate, got %.0f allocations", allocs)
+	}
+
+	mw := newMessageWriter(io.Discard)
+	resp := json.RawMessage(`{"jsonrpc":"2.0","id":1,"result":{"tools":[]}}`)
+	allocs = testing.AllocsPerRun(100, func() { mw.send(resp) })
+	if allocs > 2 {
+		t.Errorf("expected sending a message to reuse its buffer, got %.0f allocations", allocs)
+	}
+}
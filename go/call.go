@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// callUsage describes the call subcommand.
+const callUsage = `Usage: mcp-minimal-server call TOOL [--arg KEY=VALUE ...] [--json] [flags]
+
+Calls TOOL once in-process and prints its result. Values are converted to
+the types in the tool's schema. The exit status is 1 if the call fails or
+the tool reports an error. Every server flag is accepted, e.g. --config.
+`
+
+// callArgs holds the parsed command line of the call subcommand.
+type callArgs struct {
+	tool   string
+	pairs  []string // KEY=VALUE arguments of the tool
+	json   bool     // print the raw result instead of its content
+	server []string // remaining arguments, parsed as server flags
+}
+
+// serverFlags tells parseCallArgs which server flags take a value.
+var serverFlags = defaultConfig().flagSet(io.Discard)
+
+// isBoolFlag reports whether f is a flag that takes no value. Unknown flags,
+// for which f is nil, are left for loadConfig to report.
+func isBoolFlag(f *flag.Flag) bool {
+	if f == nil {
+		return true
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// parseCallArgs separates the tool name and the call's own flags from the
+// server flags in args. The tool name must come first.
+func parseCallArgs(args []string) (*callArgs, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return nil, errors.New("missing tool name")
+	}
+	c := &callArgs{tool: args[0]}
+	for i := 1; i < len(args); i++ {
+		a := args[i]
+		switch name, value, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "="); {
+		case !strings.HasPrefix(a, "-"):
+			return nil, fmt.Errorf("unexpected argument %q; tool arguments are given as --arg KEY=VALUE", a)
+		case name == "arg" && hasValue:
+			c.pairs = append(c.pairs, value)
+		case name == "arg":
+			if i+1 == len(args) {
+				return nil, errors.New("flag needs an argument: --arg")
+			}
+			i++
+			c.pairs = append(c.pairs, args[i])
+		case name == "json" && !hasValue:
+			c.json = true
+		default:
+			c.server = append(c.server, a)
+			if !hasValue && !isBoolFlag(serverFlags.Lookup(name)) && i+1 < len(args) {
+				i++
+				c.server = append(c.server, args[i])
+			}
+		}
+	}
+	return c, nil
+}
+
+// runCall implements the call subcommand and returns the process exit code.
+func runCall(args []string, out io.Writer) int {
+	c, err := parseCallArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, callUsage)
+		return 2
+	}
+	cfg, err := loadConfig(c.server, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, "\n"+callUsage)
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		return 2
+	}
+	server, _, done, code := newServerFromConfig(cfg, os.Stderr)
+	if code != 0 {
+		return code
+	}
+	defer done()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return callTool(ctx, server, c, out)
+}
+
+// callTool calls the tool described by c on s, writes the result to out,
+// and returns the process exit code.
+func callTool(ctx context.Context, s *Server, c *callArgs, out io.Writer) int {
+	cl, err := connectInProcess(ctx, s)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to initialize: %v\n", err)
+		return 1
+	}
+	defer cl.Close()
+	tools, err := cl.ListTools(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list tools: %v\n", err)
+		return 1
+	}
+	t, ok := findTool(tools, c.tool)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown tool %q\n", c.tool)
+		return 1
+	}
+	args, err := parseToolArgs(c.pairs, t.InputSchema)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid arguments: %v\n", err)
+		return 2
+	}
+	result, err := cl.CallTool(ctx, t.Name, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Call failed: %v\n", err)
+		return 1
+	}
+	if c.json {
+		encoded, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Fprintf(out, "%s\n", encoded)
+	} else {
+		printResult(out, result)
+	}
+	if result.IsError {
+		return 1
+	}
+	return 0
+}
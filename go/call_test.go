@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test separating the call subcommand's flags from the server flags
+func TestParseCallArgs(t *testing.T) {
+	c, err := parseCallArgs([]string{"echo", "--arg", "message=hi", "--read-only", "-arg=x=1",
+		"--tools", "echo", "--json", "--log-level=debug"})
+	if err != nil {
+		t.Fatalf("parseCallArgs error: %v", err)
+	}
+	want := &callArgs{
+		tool:   "echo",
+		pairs:  []string{"message=hi", "x=1"},
+		json:   true,
+		server: []string{"--read-only", "--tools", "echo", "--log-level=debug"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+	for _, bad := range [][]string{{}, {"--arg", "x=1"}, {"echo", "stray"}, {"echo", "--arg"}} {
+		if _, err := parseCallArgs(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+// Test calling a tool once and the resulting exit codes
+func TestCallTool(t *testing.T) {
+	s := NewServer(WithTools(&echoTool{}, &countTextTool{}))
+	var out bytes.Buffer
+	code := callTool(context.Background(), s, &callArgs{tool: "echo", pairs: []string{"message=hi there"}}, &out)
+	if code != 0 || out.String() != "Echo: hi there\n" {
+		t.Errorf("echo: exit code %d, output %q", code, out.String())
+	}
+
+	out.Reset()
+	code = callTool(context.Background(), s, &callArgs{tool: "count_text", pairs: []string{"text=a b"}, json: true}, &out)
+	if code != 0 || !strings.Contains(out.String(), `"text": "characters: 3\nwords: 2`) {
+		t.Errorf("count_text --json: exit code %d, output %q", code, out.String())
+	}
+
+	for _, c := range []*callArgs{
+		{tool: "missing"},
+		{tool: "echo"},
+		{tool: "echo", pairs: []string{"message"}},
+	} {
+		out.Reset()
+		if code := callTool(context.Background(), s, c, &out); code == 0 {
+			t.Errorf("%+v: expected a non-zero exit code, output %q", c, out.String())
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of chunk_text.
+const (
+	maxChunkTextSize   = 4 << 20
+	defaultChunkTokens = 500
+	maxChunkTokens     = 8000
+)
+
+// chunkTextTool splits a text into overlapping chunks of about a number of
+// tokens, as retrieval pipelines index documents. The text is passed as is
+// or read from a path in ws.
+type chunkTextTool struct {
+	ws *Workspace
+}
+
+// withTextWorkspace returns a copy of list in which chunk_text reads files
+// in ws.
+func withTextWorkspace(list []MCPTool, ws *Workspace) []MCPTool {
+	out := make([]MCPTool, len(list))
+	for i, t := range list {
+		if _, ok := t.(*chunkTextTool); ok {
+			t = &chunkTextTool{ws: ws}
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// chunkTextArgs are the arguments of the chunk_text tool.
+type chunkTextArgs struct {
+	Text    string `json:"text,omitempty" description:"The text to split"`
+	Path    string `json:"path,omitempty" description:"The path of a text file to split, relative to the server's text directory"`
+	By      string `json:"by,omitempty" enum:"tokens,sentences,headings" description:"Where chunks may end: between words, between sentences, or between sentences with a new chunk at every Markdown heading (default sentences)"`
+	Size    int    `json:"size,omitempty" minimum:"1" maximum:"8000" description:"Most approximate tokens in a chunk (default 500)"`
+	Overlap *int   `json:"overlap,omitempty" minimum:"0" description:"Approximate tokens a chunk repeats from the end of the one before it, less than size (default a tenth of size)"`
+}
+
+// Name returns the name of the chunk_text tool.
+func (t *chunkTextTool) Name() string {
+	return "chunk_text"
+}
+
+// Description returns a brief description of the chunk_text tool.
+func (t *chunkTextTool) Description() string {
+	return "Splits a text or text file into overlapping chunks of up to a number of approximate tokens, ending them between words, sentences, or Markdown sections, and returns the chunks with their byte offsets"
+}
+
+// InputSchema returns the JSON schema for the chunk_text tool's input
+// parameters.
+func (t *chunkTextTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(chunkTextArgs{})
+}
+
+// Annotations marks the chunk_text tool as read-only.
+func (t *chunkTextTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Chunk text")
+}
+
+// textChunk is a chunk as chunk_text returns it. Start and End are byte
+// offsets in the text; Heading is the trail of Markdown headings the chunk
+// is under, as in "Setup > Linux".
+type textChunk struct {
+	Index   int    `json:"index"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Tokens  int    `json:"tokens"`
+	Heading string `json:"heading,omitempty"`
+	Text    string `json:"text"`
+}
+
+// Execute splits the text without a deadline of its own.
+func (t *chunkTextTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext splits the text and returns the chunks as JSON text.
+func (t *chunkTextTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult splits the text and returns the chunks as structured
+// content.
+func (t *chunkTextTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	a := chunkTextArgs{By: "sentences", Size: defaultChunkTokens}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.By != "tokens" && a.By != "sentences" && a.By != "headings" {
+		return nil, errors.New("invalid value for 'by': expected tokens, sentences, or headings")
+	}
+	if a.Size < 1 || a.Size > maxChunkTokens {
+		return nil, fmt.Errorf("invalid value for 'size': expected 1 to %d", maxChunkTokens)
+	}
+	overlap := a.Size / 10
+	if a.Overlap != nil {
+		overlap = *a.Overlap
+	}
+	if overlap < 0 || overlap >= a.Size {
+		return nil, errors.New("invalid value for 'overlap': expected 0 to less than 'size'")
+	}
+	text, err := t.read(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []textChunk{}
+	for _, sec := range textSections(text, a.By == "headings") {
+		units := textUnits(text, sec.textSpan, a.By == "tokens", a.Size)
+		for _, s := range packSpans(units, a.Size, overlap) {
+			chunks = append(chunks, textChunk{
+				Index:   len(chunks),
+				Start:   s.Start,
+				End:     s.End,
+				Tokens:  approximateTokens(text[s.Start:s.End]),
+				Heading: sec.heading,
+				Text:    text[s.Start:s.End],
+			})
+		}
+	}
+	return mcp.NewResult().WithStructured(map[string]interface{}{"chunks": chunks}), nil
+}
+
+// read returns the text given by exactly one of the text and path
+// arguments.
+func (t *chunkTextTool) read(ctx context.Context, a chunkTextArgs) (string, error) {
+	if (a.Text == "") == (a.Path == "") {
+		return "", errors.New("exactly one of 'text' and 'path' is required")
+	}
+	if a.Text != "" {
+		if len(a.Text) > maxChunkTextSize {
+			return "", fmt.Errorf("invalid value for 'text': larger than %d bytes", maxChunkTextSize)
+		}
+		return a.Text, nil
+	}
+	if t.ws == nil {
+		return "", errors.New("reading text from paths is disabled; start the server with --text-dir")
+	}
+	ws, err := t.ws.For(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no text directory: %w", err)
+	}
+	path, err := ws.Resolve(a.Path, "text")
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", toolFailure("%s: %v", a.Path, errors.Unwrap(err))
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxChunkTextSize+1))
+	if err != nil {
+		return "", toolFailure("%s: %v", a.Path, err)
+	}
+	if len(data) > maxChunkTextSize {
+		return "", toolFailure("%s is larger than %d bytes", a.Path, maxChunkTextSize)
+	}
+	if !utf8.Valid(data) {
+		return "", toolFailure("%s is not UTF-8 text", a.Path)
+	}
+	return string(data), nil
+}
+
+// textSection is a part of a text chunked on its own, with the trail of
+// headings it is under.
+type textSection struct {
+	textSpan
+	heading string
+}
+
+// textSections splits text at its Markdown headings outside of code
+// fences, or returns it whole if byHeadings is false.
+func textSections(text string, byHeadings bool) []textSection {
+	if !byHeadings {
+		return []textSection{{textSpan: textSpan{0, len(text)}}}
+	}
+	var sections []textSection
+	var trail []string // by level
+	current := textSection{}
+	fenced := false
+	for start := 0; start < len(text); {
+		end := strings.IndexByte(text[start:], '\n') + 1
+		if end == 0 {
+			end = len(text) - start
+		}
+		line := text[start : start+end]
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+		} else if level, title := markdownHeading(line); level > 0 && !fenced {
+			if current.End = start; current.End > current.Start {
+				sections = append(sections, current)
+			}
+			if len(trail) >= level {
+				trail = trail[:level-1]
+			}
+			for len(trail) < level-1 {
+				trail = append(trail, "")
+			}
+			trail = append(trail, title)
+			var parts []string
+			for _, h := range trail {
+				if h != "" {
+					parts = append(parts, h)
+				}
+			}
+			current = textSection{textSpan: textSpan{Start: start}, heading: strings.Join(parts, " > ")}
+		}
+		start += end
+	}
+	if current.End = len(text); current.End > current.Start {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+// markdownHeading returns the level and title of line if it is an ATX
+// heading such as "## Setup", or level 0.
+func markdownHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t' && line[level] != '\n' && line[level] != '\r') {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+}
+
+// tokenSpan is a span of text with its approximate tokens.
+type tokenSpan struct {
+	textSpan
+	tokens int
+}
+
+// textUnits splits the span of text into the units chunks are made of:
+// words if byWords is set, and otherwise sentences, which are split into
+// words if they have more than size tokens. Headings and paragraphs end
+// sentences. Whitespace around units is left out of them.
+func textUnits(text string, span textSpan, byWords bool, size int) []tokenSpan {
+	var units []tokenSpan
+	add := func(s textSpan) {
+		for s.Start < s.End {
+			r, n := utf8.DecodeRuneInString(text[s.Start:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			s.Start += n
+		}
+		for s.End > s.Start {
+			r, n := utf8.DecodeLastRuneInString(text[:s.End])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			s.End -= n
+		}
+		if s.Start == s.End {
+			return
+		}
+		tokens := approximateTokens(text[s.Start:s.End])
+		if !byWords && tokens > size {
+			units = append(units, textUnits(text, s, true, size)...)
+			return
+		}
+		units = append(units, tokenSpan{s, tokens})
+	}
+	start, lineStart := span.Start, span.Start
+	for i := span.Start; i < span.End; {
+		r, n := utf8.DecodeRuneInString(text[i:])
+		i += n
+		switch {
+		case byWords:
+			if unicode.IsSpace(r) {
+				add(textSpan{start, i})
+				start = i
+			}
+		case r == '\n':
+			next := text[i:span.End]
+			if end := strings.IndexByte(next, '\n'); end >= 0 {
+				next = next[:end]
+			}
+			if level, _ := markdownHeading(text[lineStart:i]); level > 0 || strings.TrimSpace(next) == "" || strings.HasPrefix(next, "#") {
+				add(textSpan{start, i})
+				start = i
+			}
+			lineStart = i
+		case strings.ContainsRune(".!?。！？", r):
+			for i < span.End {
+				c, n := utf8.DecodeRuneInString(text[i:])
+				if !strings.ContainsRune(`"')]”’`, c) {
+					break
+				}
+				i += n
+			}
+			if next, _ := utf8.DecodeRuneInString(text[i:span.End]); i == span.End || unicode.IsSpace(next) || r >= utf8.RuneSelf {
+				add(textSpan{start, i})
+				start = i
+			}
+		}
+	}
+	add(textSpan{start, span.End})
+	return units
+}
+
+// packSpans joins consecutive units into spans of at most size tokens,
+// starting each span with the units ending the one before it that have at
+// most overlap tokens. A unit of more than size tokens is a span of its
+// own.
+func packSpans(units []tokenSpan, size, overlap int) []textSpan {
+	var spans []textSpan
+	for i := 0; i < len(units); {
+		j, n := i, 0
+		for j < len(units) && (j == i || n+units[j].tokens <= size) {
+			n += units[j].tokens
+			j++
+		}
+		spans = append(spans, textSpan{units[i].Start, units[j-1].End})
+		if j == len(units) {
+			break
+		}
+		k, m := j, 0
+		for k > i+1 && m+units[k-1].tokens <= overlap {
+			m += units[k-1].tokens
+			k--
+		}
+		i = k
+	}
+	return spans
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// callChunkText calls chunk_text and returns its chunks.
+func callChunkText(t *testing.T, tool *chunkTextTool, args map[string]interface{}) []textChunk {
+	t.Helper()
+	result, err := tool.ExecuteResult(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Structured.(map[string]interface{})["chunks"].([]textChunk)
+}
+
+// Test that chunks end where the mode allows, stay within the size, and
+// overlap the chunks before them
+func TestChunkTextTool(t *testing.T) {
+	text := "The server starts quickly. It reads its config first! Then it listens?\n\nA new paragraph begins here. It ends here."
+	var texts []string
+	for _, c := range callChunkText(t, &chunkTextTool{}, map[string]interface{}{"text": text, "size": 16, "overlap": 8}) {
+		texts = append(texts, c.Text)
+		if text[c.Start:c.End] != c.Text || c.Tokens > 16 {
+			t.Errorf("unexpected chunk %+v", c)
+		}
+	}
+	if want := []string{
+		"The server starts quickly. It reads its config first!",
+		"It reads its config first! Then it listens?",
+		"Then it listens?\n\nA new paragraph begins here.",
+		"A new paragraph begins here. It ends here.",
+	}; strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("expected sentence chunks\n%q\ngot\n%q", want, texts)
+	}
+
+	texts = nil
+	for _, c := range callChunkText(t, &chunkTextTool{}, map[string]interface{}{"text": "one two three four five six seven", "by": "tokens", "size": 3, "overlap": 1}) {
+		texts = append(texts, c.Text)
+	}
+	if got := strings.Join(texts, "|"); got != "one two three|three four five|five six seven" {
+		t.Errorf("unexpected word chunks %q", got)
+	}
+
+	// A sentence larger than the size is split between words.
+	chunks := callChunkText(t, &chunkTextTool{}, map[string]interface{}{"text": strings.Repeat("word ", 30) + "end.", "size": 10, "overlap": 0})
+	if len(chunks) < 2 || !strings.HasSuffix(chunks[len(chunks)-1].Text, "end.") {
+		t.Errorf("unexpected chunks of a long sentence %+v", chunks)
+	}
+	for _, c := range chunks {
+		if c.Tokens > 10 {
+			t.Errorf("chunk %d has %d tokens", c.Index, c.Tokens)
+		}
+	}
+}
+
+// Test that Markdown sections are chunked on their own under the trail of
+// their headings, ignoring headings in code
+func TestChunkTextHeadings(t *testing.T) {
+	text := "Intro text.\n\n# Setup\n\nInstall it.\n\n## Linux\n\nUse apt.\n\n```sh\n# not a heading\napt install x\n```\n\n## macOS\nUse brew.\n\n# Usage\nRun it.\n"
+	var got []string
+	for _, c := range callChunkText(t, &chunkTextTool{}, map[string]interface{}{"text": text, "by": "headings"}) {
+		got = append(got, c.Heading+": "+c.Text)
+	}
+	want := []string{
+		": Intro text.",
+		"Setup: # Setup\n\nInstall it.",
+		"Setup > Linux: ## Linux\n\nUse apt.\n\n```sh\n# not a heading\napt install x\n```",
+		"Setup > macOS: ## macOS\nUse brew.",
+		"Usage: # Usage\nRun it.",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected\n%q\ngot\n%q", want, got)
+	}
+}
+
+// Test that files are read only from the text directory and that invalid
+// arguments are rejected
+func TestChunkTextFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("Hello there. General Kenobi."), 0o644)
+	os.WriteFile(filepath.Join(dir, "image.bin"), []byte{0xff, 0xfe, 0x00}, 0o644)
+	tool := &chunkTextTool{ws: &Workspace{Root: dir}}
+	if chunks := callChunkText(t, tool, map[string]interface{}{"path": "notes.md"}); len(chunks) != 1 || chunks[0].Text != "Hello there. General Kenobi." {
+		t.Errorf("unexpected chunks %+v", chunks)
+	}
+
+	for _, tc := range []struct {
+		tool *chunkTextTool
+		args map[string]interface{}
+		want string
+	}{
+		{tool, map[string]interface{}{"path": "../secret.txt"}, "not a path within"},
+		{tool, map[string]interface{}{"path": "image.bin"}, "not UTF-8"},
+		{tool, map[string]interface{}{"path": "missing.md"}, "missing.md"},
+		{&chunkTextTool{}, map[string]interface{}{"path": "notes.md"}, "--text-dir"},
+		{tool, map[string]interface{}{"text": "x", "path": "notes.md"}, "exactly one of"},
+		{tool, map[string]interface{}{}, "exactly one of"},
+		{tool, map[string]interface{}{"text": "x", "by": "pages"}, "invalid value for 'by'"},
+		{tool, map[string]interface{}{"text": "x", "size": 0}, "invalid value for 'size'"},
+		{tool, map[string]interface{}{"text": "x", "size": 10, "overlap": 10}, "invalid value for 'overlap'"},
+	} {
+		if _, err := tc.tool.Execute(tc.args); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: expected an error containing %q, got %v", tc.args, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package client implements an MCP client that talks to servers over
+// standard input/output of a subprocess, any pair of streams, or the
+// Streamable HTTP transport.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// ProtocolVersion is the protocol version sent in initialize requests.
+const ProtocolVersion = "2025-03-26"
+
+// ErrClosed is returned for calls on a closed client or after the
+// connection to the server was lost.
+var ErrClosed = errors.New("client: connection closed")
+
+// RPCError is a JSON-RPC error returned by the server.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Error implements error.
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// Tool describes a tool offered by the server.
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations *mcp.ToolAnnotations   `json:"annotations,omitempty"`
+}
+
+// CallToolResult is the result of a tools/call request.
+type CallToolResult struct {
+	Content           []mcp.Content   `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// Resource describes a resource offered by the server.
+type Resource struct {
+	URI         string           `json:"uri"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	MimeType    string           `json:"mimeType,omitempty"`
+	Annotations *mcp.Annotations `json:"annotations,omitempty"`
+}
+
+// ResourceContents is one item of a resources/read result.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"` // base64-encoded
+}
+
+// Prompt describes a prompt template offered by the server.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument describes one argument of a prompt template.
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// GetPromptResult is the result of a prompts/get request.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    json.RawMessage `json:"messages"`
+}
+
+// Completion is the result of a completion/complete request: suggested
+// values for an argument.
+type Completion struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`   // values in all, if more were found than returned
+	HasMore bool     `json:"hasMore,omitempty"` // whether there are values beyond Values
+}
+
+// InitializeResult is the result of the initialize request.
+type InitializeResult struct {
+	ProtocolVersion string                     `json:"protocolVersion"`
+	ServerInfo      Implementation             `json:"serverInfo"`
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+}
+
+// Implementation names a client or server implementation.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// transport carries JSON-RPC messages to a server.
+type transport interface {
+	// call sends a request and returns the raw response message.
+	call(ctx context.Context, id int64, msg []byte) ([]byte, error)
+	// notify sends a notification.
+	notify(ctx context.Context, msg []byte) error
+	close() error
+}
+
+// Client is a connection to an MCP server. It is safe for concurrent use.
+type Client struct {
+	t            transport
+	info         Implementation
+	reconnect    *Backoff // nil unless WithReconnect
+	timeout      time.Duration
+	retry        *Backoff // nil unless WithRetry
+	retryMethods []string
+
+	mu     sync.Mutex
+	nextID int64
+	closed bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithClientInfo sets the implementation name and version sent to the
+// server in the initialize request.
+func WithClientInfo(name, version string) Option {
+	return func(c *Client) {
+		c.info = Implementation{Name: name, Version: version}
+	}
+}
+
+// newClient returns a client using t.
+func newClient(t transport, opts []Option) *Client {
+	c := &Client{t: t, info: Implementation{Name: "mcp-minimal-client", Version: "0.1.0"}}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Close closes the connection to the server.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	return c.t.close()
+}
+
+// Call sends a request for method with params and decodes its result into
+// result, which may be nil. Errors returned by the server are *RPCError;
+// failures to reach it are *TransportError.
+func (c *Client) Call(ctx context.Context, method string, params, result interface{}) error {
+	var data []byte
+	for attempt := 0; ; attempt++ {
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			return ErrClosed
+		}
+		// Each attempt has an ID of its own, so that a late response to an
+		// earlier one is not taken for it.
+		c.nextID++
+		id := c.nextID
+		c.mu.Unlock()
+
+		msg, err := json.Marshal(request{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+		if err != nil {
+			return err
+		}
+		data, err = c.send(ctx, method, id, msg)
+		if err == nil {
+			break
+		}
+		d, ok := c.retryDelay(ctx, method, attempt, err)
+		if !ok || !sleep(ctx, d) {
+			return err
+		}
+	}
+	var resp response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("client: invalid response: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("client: invalid %s result: %w", method, err)
+	}
+	return nil
+}
+
+// Notify sends a notification for method with params. Failures to reach
+// the server are *TransportError.
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := json.Marshal(request{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	_, err = c.send(ctx, method, 0, msg)
+	return err
+}
+
+// Initialize performs the initialization handshake. It must be called
+// before any other request.
+func (c *Client) Initialize(ctx context.Context) (*InitializeResult, error) {
+	var result InitializeResult
+	params := map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"clientInfo":      c.info,
+		"capabilities":    map[string]interface{}{},
+	}
+	if err := c.Call(ctx, "initialize", params, &result); err != nil {
+		return nil, err
+	}
+	if err := c.Notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListTools returns the tools offered by the server.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var result struct {
+		Tools []Tool `json:"tools"`
+	}
+	err := c.Call(ctx, "tools/list", nil, &result)
+	return result.Tools, err
+}
+
+// CallTool calls the named tool with args.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	var result CallToolResult
+	params := map[string]interface{}{"name": name, "arguments": args}
+	if err := c.Call(ctx, "tools/call", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListResources returns the resources offered by the server.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	var result struct {
+		Resources []Resource `json:"resources"`
+	}
+	err := c.Call(ctx, "resources/list", nil, &result)
+	return result.Resources, err
+}
+
+// ReadResource returns the contents of the resource at uri.
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var result struct {
+		Contents []ResourceContents `json:"contents"`
+	}
+	err := c.Call(ctx, "resources/read", map[string]string{"uri": uri}, &result)
+	return result.Contents, err
+}
+
+// ListPrompts returns the prompt templates offered by the server.
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var result struct {
+		Prompts []Prompt `json:"prompts"`
+	}
+	err := c.Call(ctx, "prompts/list", nil, &result)
+	return result.Prompts, err
+}
+
+// GetPrompt renders the named prompt template with args.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	var result GetPromptResult
+	params := map[string]interface{}{"name": name, "arguments": args}
+	if err := c.Call(ctx, "prompts/get", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CompletePrompt returns suggested values of the named prompt's argument
+// that continue value. args holds the arguments already filled in.
+func (c *Client) CompletePrompt(ctx context.Context, name, argument, value string, args map[string]string) (*Completion, error) {
+	var result struct {
+		Completion Completion `json:"completion"`
+	}
+	params := map[string]interface{}{
+		"ref":      map[string]string{"type": "ref/prompt", "name": name},
+		"argument": map[string]string{"name": argument, "value": value},
+	}
+	if len(args) > 0 {
+		params["context"] = map[string]interface{}{"arguments": args}
+	}
+	if err := c.Call(ctx, "completion/complete", params, &result); err != nil {
+		return nil, err
+	}
+	return &result.Completion, nil
+}
+
+// request is an outgoing JSON-RPC request or, without an ID, notification.
+type request struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int64       `json:"id,omitempty"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// response is an incoming JSON-RPC message.
+type response struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer answers requests with canned results keyed by method. An
+// unknown method gets a "method not found" error.
+func fakeServer(results map[string]interface{}) func(msg []byte) []byte {
+	return func(msg []byte) []byte {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.Unmarshal(msg, &req); err != nil || len(req.ID) == 0 {
+			return nil
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if result, ok := results[req.Method]; ok {
+			resp["result"] = result
+		} else {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "Method not found"}
+		}
+		data, _ := json.Marshal(resp)
+		return data
+	}
+}
+
+var fakeResults = map[string]interface{}{
+	"initialize": map[string]interface{}{
+		"protocolVersion": ProtocolVersion,
+		"serverInfo":      map[string]string{"name": "fake", "version": "1"},
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+	},
+	"tools/list": map[string]interface{}{
+		"tools": []map[string]interface{}{{"name": "echo", "inputSchema": map[string]interface{}{"type": "object"}}},
+	},
+	"tools/call": map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": "hi"}},
+	},
+}
+
+// exercise runs a short session against c.
+func exercise(t *testing.T, c *Client) {
+	t.Helper()
+	ctx := context.Background()
+	init, err := c.Initialize(ctx)
+	if err != nil {
+		t.Fatalf("Initialize error: %v", err)
+	}
+	if init.ServerInfo.Name != "fake" {
+		t.Errorf("unexpected server info %+v", init.ServerInfo)
+	}
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "echo" {
+		t.Errorf("unexpected tools %v, %v", tools, err)
+	}
+	result, err := c.CallTool(ctx, "echo", nil)
+	if err != nil || len(result.Content) != 1 || result.Content[0].Text != "hi" {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	var rpcErr *RPCError
+	if _, err := c.ListPrompts(ctx); !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("expected a method not found error, got %v", err)
+	}
+}
+
+// Test a session over a pair of streams
+func TestStreamClient(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	handle := fakeServer(fakeResults)
+	go func() {
+		sc := bufio.NewScanner(serverIn)
+		for sc.Scan() {
+			if resp := handle(sc.Bytes()); resp != nil {
+				serverOut.Write(append(resp, '\n'))
+			}
+		}
+		serverOut.Close()
+	}()
+
+	c := New(clientIn, clientOut, clientOut.Close)
+	exercise(t, c)
+	c.Close()
+	if err := c.Call(context.Background(), "ping", nil, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+// Test that a pending call fails when the server goes away
+func TestStreamClientDisconnect(t *testing.T) {
+	clientIn, serverOut := io.Pipe()
+	c := New(clientIn, io.Discard, nil)
+	time.AfterFunc(20*time.Millisecond, func() { serverOut.Close() })
+	if err := c.Call(context.Background(), "ping", nil, nil); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+}
+
+// Test a session over the Streamable HTTP transport
+func TestHTTPClient(t *testing.T) {
+	handle := fakeServer(fakeResults)
+	var sawSession bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.Header.Get(sessionIDHeader) == "s1" {
+			sawSession = true
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set(sessionIDHeader, "s1")
+		resp := handle(body)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Write(resp)
+	}))
+	defer ts.Close()
+
+	c := NewHTTP(ts.URL, nil)
+	exercise(t, c)
+	if !sawSession {
+		t.Error("expected the session ID to be sent back")
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+}
+
+// Test that an event stream broken before the response is resumed after
+// the last event seen
+func TestHTTPClientResume(t *testing.T) {
+	var lastEventID string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if r.Method == http.MethodGet {
+			lastEventID = r.Header.Get("Last-Event-ID")
+			io.WriteString(w, "id: e2\ndata: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}}\n\n")
+			return
+		}
+		// A notification, then the stream breaks before the response.
+		io.WriteString(w, "retry: 10\nid: e1\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\"}\n\n")
+	}))
+	defer ts.Close()
+
+	if err := NewHTTP(ts.URL, nil).Call(context.Background(), "ping", nil, nil); err == nil {
+		t.Error("expected the broken stream to fail without reconnection")
+	}
+	c := NewHTTP(ts.URL, nil, WithReconnect(Backoff{Attempts: 3}))
+	if err := c.Call(context.Background(), "ping", nil, nil); err != nil {
+		t.Fatalf("Call error: %v", err)
+	}
+	if lastEventID != "e1" {
+		t.Errorf("expected the stream to be resumed after e1, got %q", lastEventID)
+	}
+}
+
+// Test that a request is sent again once a server that could not be
+// reached comes up
+func TestHTTPClientReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	if err := NewHTTP("http://"+addr, nil).Call(context.Background(), "ping", nil, nil); err == nil {
+		t.Fatal("expected a call to a server that is down to fail")
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	}))
+	defer ts.Close()
+	time.AfterFunc(50*time.Millisecond, func() {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("listen: %v", err)
+			return
+		}
+		ts.Listener = l
+		ts.Start()
+	})
+
+	c := NewHTTP("http://"+addr, nil, WithReconnect(Backoff{Initial: 10 * time.Millisecond, Max: 40 * time.Millisecond}))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Call(ctx, "ping", nil, nil); err != nil {
+		t.Errorf("Call error: %v", err)
+	}
+}
+
+// Test the delays between reconnection attempts
+func TestBackoff(t *testing.T) {
+	b := &Backoff{Initial: time.Second, Max: 5 * time.Second, Attempts: 4}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second} {
+		if d, ok := b.delay(attempt, 0); !ok || d != want {
+			t.Errorf("attempt %d: expected %v, got %v, %v", attempt, want, d, ok)
+		}
+	}
+	if _, ok := b.delay(4, 0); ok {
+		t.Error("expected no attempts past the limit")
+	}
+	if d, _ := b.delay(1, 3*time.Second); d != 5*time.Second {
+		t.Errorf("expected the server's delay to be doubled up to the limit, got %v", d)
+	}
+	if _, ok := (*Backoff)(nil).delay(0, 0); ok {
+		t.Error("expected no attempts without a backoff")
+	}
+}
+
+// Test that idempotent requests that time out are retried with a new ID,
+// and that other requests and JSON-RPC errors are not
+func TestClientRetry(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	handle := fakeServer(fakeResults)
+	var mu sync.Mutex
+	seen := map[string]int{}
+	go func() {
+		sc := bufio.NewScanner(serverIn)
+		for sc.Scan() {
+			var req struct{ Method string }
+			json.Unmarshal(sc.Bytes(), &req)
+			mu.Lock()
+			seen[req.Method]++
+			n := seen[req.Method]
+			mu.Unlock()
+			// The first request for each method goes unanswered.
+			if n == 1 {
+				continue
+			}
+			if resp := handle(sc.Bytes()); resp != nil {
+				serverOut.Write(append(resp, '\n'))
+			}
+		}
+		serverOut.Close()
+	}()
+
+	c := New(clientIn, clientOut, clientOut.Close, WithTimeout(50*time.Millisecond), WithRetry(Backoff{Initial: time.Millisecond, Attempts: 2}))
+	defer c.Close()
+	ctx := context.Background()
+	if tools, err := c.ListTools(ctx); err != nil || len(tools) != 1 {
+		t.Errorf("expected the retry to list the tools, got %v, %v", tools, err)
+	}
+	_, err := c.CallTool(ctx, "echo", nil)
+	var te *TransportError
+	if !errors.As(err, &te) || te.Method != "tools/call" || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected tools/call to time out without a retry, got %v", err)
+	}
+	var rpcErr *RPCError
+	if _, err := c.ListPrompts(ctx); !errors.As(err, &rpcErr) || errors.As(err, &te) {
+		t.Errorf("expected the JSON-RPC error, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if seen["tools/list"] != 2 || seen["tools/call"] != 1 || seen["prompts/list"] != 2 {
+		t.Errorf("unexpected requests %v", seen)
+	}
+}
+
+// Test that a caller's cancelled context is returned as is rather than as
+// a transport failure
+func TestClientCancel(t *testing.T) {
+	clientIn, _ := io.Pipe()
+	c := New(clientIn, io.Discard, nil, WithRetry(Backoff{}))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := c.Call(ctx, "ping", nil, nil)
+	var te *TransportError
+	if err != context.DeadlineExceeded || errors.As(err, &te) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+}
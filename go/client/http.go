@@ -0,0 +1,258 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionIDHeader carries the session ID of the Streamable HTTP transport.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// httpTransport posts each message to a Streamable HTTP endpoint and reads
+// the response from the body, either as JSON or from a stream of
+// server-sent events. Other messages the server sends on a stream are
+// ignored.
+type httpTransport struct {
+	url     string
+	client  *http.Client
+	backoff *Backoff // nil to fail at once when the server cannot be reached
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+// NewHTTP returns a client for the Streamable HTTP endpoint at url, e.g.
+// "http://127.0.0.1:8080/mcp". A nil httpClient uses http.DefaultClient.
+func NewHTTP(url string, httpClient *http.Client, opts ...Option) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	t := &httpTransport{url: url, client: httpClient}
+	c := newClient(t, opts)
+	t.backoff = c.reconnect
+	return c
+}
+
+// call implements transport.
+func (t *httpTransport) call(ctx context.Context, id int64, msg []byte) ([]byte, error) {
+	return t.post(ctx, msg, id)
+}
+
+// notify implements transport.
+func (t *httpTransport) notify(ctx context.Context, msg []byte) error {
+	_, err := t.post(ctx, msg, 0)
+	return err
+}
+
+// post sends msg and returns the response to the request with the given
+// ID, zero for a notification. The session ID assigned by the server is
+// remembered and sent with later messages.
+func (t *httpTransport) post(ctx context.Context, msg []byte, id int64) ([]byte, error) {
+	resp, err := t.send(ctx, http.MethodPost, msg, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK && isEventStream(resp) {
+		return t.readStream(ctx, resp, id)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return nil, nil
+	}
+	body = bytes.TrimSpace(body)
+	// Error statuses may still carry a JSON-RPC error worth decoding.
+	if resp.StatusCode >= 300 && !bytes.HasPrefix(body, []byte("{")) {
+		return nil, fmt.Errorf("client: %s: %s", resp.Status, body)
+	}
+	return body, nil
+}
+
+// send makes a request with body, resuming the stream after lastEventID
+// if it is set. While the server cannot be reached it retries as the
+// backoff allows: a POST only if it never got through, so that it does
+// not run twice, and a GET, which only resumes a stream, after any error.
+func (t *httpTransport) send(ctx context.Context, method string, body []byte, lastEventID string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, t.url, r)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json, text/event-stream")
+		} else {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		t.mu.Lock()
+		if t.sessionID != "" {
+			req.Header.Set(sessionIDHeader, t.sessionID)
+		}
+		t.mu.Unlock()
+
+		resp, err := t.client.Do(req)
+		if err == nil {
+			if id := resp.Header.Get(sessionIDHeader); id != "" {
+				t.mu.Lock()
+				t.sessionID = id
+				t.mu.Unlock()
+			}
+			return resp, nil
+		}
+		if ctx.Err() != nil || method == http.MethodPost && !unsent(err) {
+			return nil, err
+		}
+		d, ok := t.backoff.delay(attempt, 0)
+		if !ok || !sleep(ctx, d) {
+			return nil, err
+		}
+	}
+}
+
+// readStream reads the events of resp until the response to the request
+// with the given ID comes, and returns it. If the stream breaks first, it
+// is resumed after the last event seen, as the backoff allows. A stream
+// answering a notification is closed at once.
+func (t *httpTransport) readStream(ctx context.Context, resp *http.Response, id int64) ([]byte, error) {
+	if id == 0 {
+		resp.Body.Close()
+		return nil, nil
+	}
+	var es eventStream
+	for attempt := 0; ; {
+		seen := es.lastID
+		msg, err := es.read(resp.Body, id)
+		resp.Body.Close()
+		if msg != nil {
+			return msg, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		// Only a stream whose events have IDs can be resumed.
+		if es.lastID == "" {
+			return nil, fmt.Errorf("client: event stream ended before the response: %w", err)
+		}
+		if es.lastID != seen {
+			attempt = 0
+		}
+		d, ok := t.backoff.delay(attempt, es.retry)
+		if !ok || !sleep(ctx, d) {
+			return nil, fmt.Errorf("client: event stream ended before the response: %w", err)
+		}
+		attempt++
+		if resp, err = t.send(ctx, http.MethodGet, nil, es.lastID); err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK || !isEventStream(resp) {
+			resp.Body.Close()
+			return nil, fmt.Errorf("client: resuming the event stream: %s", resp.Status)
+		}
+	}
+}
+
+// isEventStream reports whether resp holds server-sent events.
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// eventStream is the state of a stream of server-sent events kept across
+// reconnections.
+type eventStream struct {
+	lastID string        // ID of the last event, sent back to resume after it
+	retry  time.Duration // reconnection delay the server asked for
+}
+
+// read reads events from r until one holds the response to the request
+// with the given ID, and returns it, or until r ends or fails.
+func (es *eventStream) read(r io.Reader, id int64) ([]byte, error) {
+	br := bufio.NewReader(r)
+	var data []string
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			// An event cut short is dropped.
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if msg := responseTo(strings.Join(data, "\n"), id); msg != nil {
+				return msg, nil
+			}
+			data = data[:0]
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				es.lastID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				es.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// responseTo returns data if it is the response to the request with the
+// given ID.
+func responseTo(data string, id int64) []byte {
+	var msg response
+	if err := json.Unmarshal([]byte(data), &msg); err != nil || msg.Method != "" {
+		return nil
+	}
+	if got, err := strconv.ParseInt(string(msg.ID), 10, 64); err != nil || got != id {
+		return nil
+	}
+	return []byte(data)
+}
+
+// close implements transport by ending the session.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	id := t.sessionID
+	t.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(sessionIDHeader, id)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Backoff says how a client retries reaching a server after losing it:
+// the delays between attempts double from Initial up to Max.
+type Backoff struct {
+	Initial  time.Duration // first delay; 100ms if zero
+	Max      time.Duration // longest delay; 10s if zero
+	Attempts int           // retries before giving up; unlimited if zero, though the call's context still ends them
+}
+
+// WithReconnect makes clients of network transports ride out blips: a
+// message that could not reach the server is sent again, and an event
+// stream that broke before the response came is resumed with the
+// Last-Event-ID header, so the server replays what the client missed. Both
+// wait between attempts as b says. Without it the call fails at once.
+func WithReconnect(b Backoff) Option {
+	return func(c *Client) {
+		c.reconnect = &b
+	}
+}
+
+// delay returns how long to wait before retry number attempt, counted from
+// zero, and false once b allows no more. A nil b allows none. hint, if
+// positive, is the delay the server asked for, which replaces Initial.
+func (b *Backoff) delay(attempt int, hint time.Duration) (time.Duration, bool) {
+	if b == nil || b.Attempts > 0 && attempt >= b.Attempts {
+		return 0, false
+	}
+	d, limit := b.Initial, b.Max
+	if hint > 0 {
+		d = hint
+	}
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	if limit <= 0 {
+		limit = 10 * time.Second
+	}
+	for i := 0; i < attempt && d < limit; i++ {
+		d *= 2
+	}
+	return min(d, limit), true
+}
+
+// sleep waits for d or until ctx is done, reporting whether d passed.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// unsent reports whether err shows a request never reached the server, so
+// that sending it again cannot run it twice.
+func unsent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// idempotentMethods are the methods WithRetry retries unless told others:
+// those that only list what the server offers, and ping.
+var idempotentMethods = []string{"ping", "tools/list", "resources/list", "resources/templates/list", "prompts/list"}
+
+// TransportError is a failure to exchange a message with the server, as
+// opposed to an *RPCError, which the server answered with. Err is the
+// cause: errors.Is(err, ErrClosed) tells whether the connection is gone
+// for good, and errors.Is(err, context.DeadlineExceeded) whether the
+// request timed out.
+type TransportError struct {
+	Method string
+	Err    error
+}
+
+// Error implements error.
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("client: %s: %v", e.Method, e.Err)
+}
+
+// Unwrap returns the cause.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// WithTimeout limits each request and notification to d, on top of the
+// deadline of its context, so that a hung server does not hold a call
+// forever. Each retry gets d afresh. Zero means no limit.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithRetry sends requests for methods again after a transport failure,
+// waiting between attempts as b says; JSON-RPC errors are never retried.
+// Without methods it retries ping and the list requests, which are safe to
+// repeat; only name others that are too.
+func WithRetry(b Backoff, methods ...string) Option {
+	if len(methods) == 0 {
+		methods = idempotentMethods
+	}
+	return func(c *Client) {
+		c.retry = &b
+		c.retryMethods = methods
+	}
+}
+
+// send sends one request or, with a zero id, notification for method,
+// within the per-request timeout. Failures other than the caller's context
+// ending are *TransportError.
+func (c *Client) send(ctx context.Context, method string, id int64, msg []byte) ([]byte, error) {
+	callCtx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var data []byte
+	var err error
+	if id == 0 {
+		err = c.t.notify(callCtx, msg)
+	} else {
+		data, err = c.t.call(callCtx, id, msg)
+	}
+	if err == nil {
+		return data, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, &TransportError{Method: method, Err: err}
+}
+
+// retryDelay returns how long to wait before retrying a request for method
+// that failed with err for the attempt-th time, and false if it must not
+// be retried.
+func (c *Client) retryDelay(ctx context.Context, method string, attempt int, err error) (time.Duration, bool) {
+	var te *TransportError
+	if c.retry == nil || ctx.Err() != nil || !errors.As(err, &te) || errors.Is(err, ErrClosed) ||
+		!slices.Contains(c.retryMethods, method) {
+		return 0, false
+	}
+	return c.retry.delay(attempt, 0)
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// streamTransport exchanges newline-delimited messages over a pair of
+// streams and matches responses to requests by ID.
+type streamTransport struct {
+	w      io.Writer
+	closer func() error
+
+	wmu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan []byte
+	err     error // set once the read loop stops
+}
+
+// New returns a client exchanging newline-delimited JSON-RPC messages with
+// a server over r and w. Closing the client calls closer, if not nil.
+func New(r io.Reader, w io.Writer, closer func() error, opts ...Option) *Client {
+	t := &streamTransport{w: w, closer: closer, pending: map[int64]chan []byte{}}
+	go t.readLoop(r)
+	return newClient(t, opts)
+}
+
+// NewStdio starts command with args and returns a client talking to it over
+// its standard input and output. The subprocess's standard error is passed
+// through to this process's. Closing the client closes the subprocess's
+// standard input and waits for it to exit, killing it after a grace period.
+func NewStdio(command string, args []string, env []string, opts ...Option) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = env
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	closer := func() error {
+		stdin.Close()
+		done := make(chan error, 1)
+		go func() { done <- cmd.Wait() }()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			cmd.Process.Kill()
+			return <-done
+		}
+	}
+	return New(stdout, stdin, closer, opts...), nil
+}
+
+// readLoop delivers responses to the waiting calls until r fails.
+func (t *streamTransport) readLoop(r io.Reader) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			t.deliver(line)
+		}
+		if err != nil {
+			t.mu.Lock()
+			t.err = ErrClosed
+			for id, ch := range t.pending {
+				close(ch)
+				delete(t.pending, id)
+			}
+			t.mu.Unlock()
+			return
+		}
+	}
+}
+
+// deliver routes one inbound message to the call waiting for it. Requests
+// and notifications from the server are ignored.
+func (t *streamTransport) deliver(line []byte) {
+	var msg response
+	if err := json.Unmarshal(line, &msg); err != nil || msg.Method != "" {
+		return
+	}
+	id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	ch, ok := t.pending[id]
+	delete(t.pending, id)
+	t.mu.Unlock()
+	if ok {
+		ch <- line
+	}
+}
+
+// call implements transport.
+func (t *streamTransport) call(ctx context.Context, id int64, msg []byte) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[id] = ch
+	t.mu.Unlock()
+
+	if err := t.write(msg); err != nil {
+		t.forget(id)
+		return nil, err
+	}
+	select {
+	case data, ok := <-ch:
+		if !ok {
+			return nil, ErrClosed
+		}
+		return data, nil
+	case <-ctx.Done():
+		t.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+// notify implements transport.
+func (t *streamTransport) notify(ctx context.Context, msg []byte) error {
+	return t.write(msg)
+}
+
+// write sends one message followed by a newline.
+func (t *streamTransport) write(msg []byte) error {
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	_, err := t.w.Write(append(msg, '\n'))
+	return err
+}
+
+// forget stops waiting for the response to id.
+func (t *streamTransport) forget(id int64) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+// close implements transport.
+func (t *streamTransport) close() error {
+	if t.closer != nil {
+		return t.closer()
+	}
+	return nil
+}
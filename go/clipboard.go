@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// clipboardTimeout bounds a run of a clipboard command, which may hang
+// when, for example, no display is reachable.
+const clipboardTimeout = 5 * time.Second
+
+// clipboardBackend reads and writes the system clipboard by running the
+// platform's clipboard commands: get prints the clipboard's text and set
+// replaces it with its standard input.
+type clipboardBackend struct {
+	get []string
+	set []string
+}
+
+// findClipboardBackend returns the clipboard commands of the current
+// platform. On Linux it prefers wl-clipboard under Wayland and otherwise
+// uses xclip or xsel, whichever is installed.
+func findClipboardBackend() (*clipboardBackend, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return &clipboardBackend{get: []string{"pbpaste"}, set: []string{"pbcopy"}}, nil
+	case "windows":
+		return &clipboardBackend{
+			get: []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Get-Clipboard -Raw"},
+			set: []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "$input | Set-Clipboard"},
+		}, nil
+	}
+	candidates := []clipboardBackend{
+		{get: []string{"xclip", "-selection", "clipboard", "-out"}, set: []string{"xclip", "-selection", "clipboard", "-in"}},
+		{get: []string{"xsel", "--clipboard", "--output"}, set: []string{"xsel", "--clipboard", "--input"}},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		wayland := clipboardBackend{get: []string{"wl-paste", "--no-newline"}, set: []string{"wl-copy"}}
+		candidates = append([]clipboardBackend{wayland}, candidates...)
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c.get[0]); err == nil {
+			return &c, nil
+		}
+	}
+	return nil, errors.New("no clipboard command found: install wl-clipboard, xclip, or xsel")
+}
+
+// run runs argv with stdin as its standard input and returns its standard
+// output.
+func (b *clipboardBackend) run(ctx context.Context, argv []string, stdin string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, clipboardTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	// xclip and wl-copy stay in the background to serve the selection;
+	// do not wait for them to close the output pipes.
+	cmd.WaitDelay = 100 * time.Millisecond
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		err = nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", toolFailure("%s: %s", argv[0], msg)
+		}
+		return "", toolFailure("%s: %v", argv[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// clipboardTools returns the clipboard_get and clipboard_set tools, or an
+// error if the platform has no clipboard commands.
+func clipboardTools() ([]MCPTool, error) {
+	b, err := findClipboardBackend()
+	if err != nil {
+		return nil, err
+	}
+	return []MCPTool{&clipboardGetTool{backend: b}, &clipboardSetTool{backend: b}}, nil
+}
+
+// clipboardGetTool returns the text on the user's clipboard.
+type clipboardGetTool struct {
+	backend *clipboardBackend
+}
+
+// Name returns the name of the clipboard_get tool.
+func (c *clipboardGetTool) Name() string {
+	return "clipboard_get"
+}
+
+// Description returns a brief description of the clipboard_get tool.
+func (c *clipboardGetTool) Description() string {
+	return "Returns the text on the user's clipboard"
+}
+
+// InputSchema returns the JSON schema for the clipboard_get tool, which
+// takes no arguments.
+func (c *clipboardGetTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+}
+
+// Annotations marks the clipboard_get tool as read-only. It is not
+// idempotent, since the user may copy something else between calls.
+func (c *clipboardGetTool) Annotations() ToolAnnotations {
+	closed := false
+	return ToolAnnotations{Title: "Read clipboard", ReadOnlyHint: true, OpenWorldHint: &closed}
+}
+
+// Execute reads the clipboard without a deadline of its own.
+func (c *clipboardGetTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return c.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext reads the clipboard and returns its text.
+func (c *clipboardGetTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	text, err := c.backend.run(ctx, c.backend.get, "")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: text}}, nil
+}
+
+// clipboardSetTool replaces the text on the user's clipboard.
+type clipboardSetTool struct {
+	backend *clipboardBackend
+}
+
+// clipboardSetArgs are the arguments of the clipboard_set tool.
+type clipboardSetArgs struct {
+	Text string `json:"text" description:"The text to put on the clipboard"`
+}
+
+// Name returns the name of the clipboard_set tool.
+func (c *clipboardSetTool) Name() string {
+	return "clipboard_set"
+}
+
+// Description returns a brief description of the clipboard_set tool.
+func (c *clipboardSetTool) Description() string {
+	return "Replaces the text on the user's clipboard with the specified text"
+}
+
+// InputSchema returns the JSON schema for the clipboard_set tool's input
+// parameters.
+func (c *clipboardSetTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(clipboardSetArgs{})
+}
+
+// Annotations marks the clipboard_set tool as idempotent. It overwrites
+// whatever the user copied last, so it counts as destructive.
+func (c *clipboardSetTool) Annotations() ToolAnnotations {
+	closed := false
+	return ToolAnnotations{Title: "Write clipboard", IdempotentHint: true, OpenWorldHint: &closed}
+}
+
+// Execute writes the clipboard without a deadline of its own.
+func (c *clipboardSetTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return c.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext puts the text on the clipboard.
+func (c *clipboardSetTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a clipboardSetArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if _, err := c.backend.run(ctx, c.backend.set, a.Text); err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: fmt.Sprintf("Copied %d characters to the clipboard", len([]rune(a.Text)))}}, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testClipboard returns a backend keeping the clipboard in a file.
+func testClipboard(t *testing.T) *clipboardBackend {
+	file := filepath.Join(t.TempDir(), "clipboard")
+	return &clipboardBackend{
+		get: []string{"sh", "-c", `cat "$0" 2>/dev/null || true`, file},
+		set: []string{"sh", "-c", `cat > "$0"`, file},
+	}
+}
+
+// Test copying text to the clipboard and reading it back
+func TestClipboardTools(t *testing.T) {
+	b := testClipboard(t)
+	get, set := &clipboardGetTool{backend: b}, &clipboardSetTool{backend: b}
+
+	content, err := get.Execute(nil)
+	if err != nil || len(content) != 1 || content[0].Text != "" {
+		t.Fatalf("expected an empty clipboard, got %v, %v", content, err)
+	}
+	content, err = set.Execute(map[string]interface{}{"text": "héllo\nworld"})
+	if err != nil || !strings.Contains(content[0].Text, "11 characters") {
+		t.Fatalf("unexpected clipboard_set result %v, %v", content, err)
+	}
+	content, err = get.Execute(nil)
+	if err != nil || content[0].Text != "héllo\nworld" {
+		t.Errorf("expected the copied text, got %v, %v", content, err)
+	}
+}
+
+// Test that failing clipboard commands fail the call with their message,
+// and that a platform without clipboard commands is reported
+func TestClipboardErrors(t *testing.T) {
+	b := &clipboardBackend{get: []string{"sh", "-c", "echo 'Error: cannot open display' >&2; exit 1"}}
+	var failure *toolResultError
+	if _, err := (&clipboardGetTool{backend: b}).Execute(nil); !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "open display") {
+		t.Errorf("expected the command's error as an error result, got %v", err)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	t.Setenv("PATH", t.TempDir())
+	t.Setenv("WAYLAND_DISPLAY", "")
+	if _, err := clipboardTools(); err == nil || !strings.Contains(err.Error(), "xclip") {
+		t.Errorf("expected the missing commands to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+// Command mcpgen generates Go stubs implementing mcp.Tool from tool
+// definitions, to speed up porting tools from other servers.
+//
+// The input is either the output of tools/list from another server, as a
+// full JSON-RPC response or just its result, or a bare JSON Schema of a
+// tool's arguments together with -name:
+//
+//	go run ./cmd/mcpgen -out ./tools tools.json
+//	go run ./cmd/mcpgen -name resize -out ./tools schema.json
+//
+// One file is written per tool, holding an arguments struct whose tags
+// reproduce the schema through mcp.SchemaFor and an Execute method to fill
+// in.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"mcp-minimal-server-go/client"
+)
+
+func main() {
+	fs := flag.NewFlagSet("mcpgen", flag.ContinueOnError)
+	out := fs.String("out", ".", "directory to write the generated files to")
+	pkg := fs.String("package", "main", "package name of the generated files")
+	name := fs.String("name", "", "tool name, if the input is a bare JSON Schema")
+	description := fs.String("description", "", "tool description, if the input is a bare JSON Schema")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: mcpgen [flags] <tools.json | schema.json | ->\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		os.Exit(2)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	source := fs.Arg(0)
+	var data []byte
+	var err error
+	if source == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mcpgen: %v\n", err)
+		os.Exit(1)
+	}
+	tools, err := parseInput(data, *name, *description)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mcpgen: %s: %v\n", source, err)
+		os.Exit(1)
+	}
+	for _, t := range tools {
+		src, err := generate(t, *pkg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "mcpgen: tool %q: %v\n", t.Name, err)
+			os.Exit(1)
+		}
+		path := filepath.Join(*out, fileName(t.Name))
+		if err := os.WriteFile(path, src, 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "mcpgen: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+	}
+}
+
+// parseInput returns the tools described by data: a tools/list response or
+// result, or, if name is set, a JSON Schema for a single tool.
+func parseInput(data []byte, name, description string) ([]client.Tool, error) {
+	if name != "" {
+		var schema map[string]interface{}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, err
+		}
+		return []client.Tool{{Name: name, Description: description, InputSchema: schema}}, nil
+	}
+	var input struct {
+		Tools  []client.Tool `json:"tools"`
+		Result *struct {
+			Tools []client.Tool `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &input); err != nil {
+		return nil, err
+	}
+	tools := input.Tools
+	if input.Result != nil {
+		tools = input.Result.Tools
+	}
+	if len(tools) == 0 {
+		return nil, errors.New("no tools found; use -name for a bare JSON Schema")
+	}
+	seen := map[string]bool{}
+	for _, t := range tools {
+		if t.Name == "" {
+			return nil, errors.New("tool without a name")
+		}
+		if seen[fileName(t.Name)] {
+			return nil, fmt.Errorf("tools named like %q would share a file", t.Name)
+		}
+		seen[fileName(t.Name)] = true
+	}
+	return tools, nil
+}
+
+// generator accumulates the struct types of one tool's arguments.
+type generator struct {
+	types []string // struct declarations, outermost first
+}
+
+// generate returns the formatted source of a stub for t.
+func generate(t client.Tool, pkg string) ([]byte, error) {
+	base := identifier(t.Name, false)
+	argsType := base + "Args"
+	toolType := base + "Tool"
+
+	g := &generator{}
+	schema := t.InputSchema
+	if schema == nil {
+		schema = map[string]interface{}{"type": "object"}
+	}
+	g.structType(argsType, fmt.Sprintf("%s are the arguments of the %s tool.", argsType, t.Name), schema)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Generated by mcpgen; implement Execute to complete the tool.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "import (\n\t\"errors\"\n\n\t\"mcp-minimal-server-go/mcp\"\n)\n\n")
+	for _, decl := range g.types {
+		b.WriteString(decl)
+	}
+	fmt.Fprintf(&b, "// %s implements the %s tool.\n", toolType, t.Name)
+	fmt.Fprintf(&b, "type %s struct{}\n\n", toolType)
+	fmt.Fprintf(&b, "// Name returns the name of the %s tool.\n", t.Name)
+	fmt.Fprintf(&b, "func (t *%s) Name() string {\n\treturn %s\n}\n\n", toolType, strconv.Quote(t.Name))
+	fmt.Fprintf(&b, "// Description returns a brief description of the %s tool.\n", t.Name)
+	fmt.Fprintf(&b, "func (t *%s) Description() string {\n\treturn %s\n}\n\n", toolType, strconv.Quote(t.Description))
+	fmt.Fprintf(&b, "// InputSchema returns the JSON schema for the %s tool's input parameters.\n", t.Name)
+	fmt.Fprintf(&b, "func (t *%s) InputSchema() map[string]interface{} {\n\treturn mcp.SchemaFor(%s{})\n}\n\n", toolType, argsType)
+	if a := t.Annotations; a != nil {
+		fmt.Fprintf(&b, "// Annotations describes the behavior of the %s tool.\n", t.Name)
+		fmt.Fprintf(&b, "func (t *%s) Annotations() mcp.ToolAnnotations {\n", toolType)
+		if a.DestructiveHint != nil {
+			fmt.Fprintf(&b, "\tdestructive := %t\n", *a.DestructiveHint)
+		}
+		if a.OpenWorldHint != nil {
+			fmt.Fprintf(&b, "\topenWorld := %t\n", *a.OpenWorldHint)
+		}
+		fmt.Fprintf(&b, "\treturn mcp.ToolAnnotations{\n")
+		if a.Title != "" {
+			fmt.Fprintf(&b, "\t\tTitle: %s,\n", strconv.Quote(a.Title))
+		}
+		if a.ReadOnlyHint {
+			fmt.Fprintf(&b, "\t\tReadOnlyHint: true,\n")
+		}
+		if a.DestructiveHint != nil {
+			fmt.Fprintf(&b, "\t\tDestructiveHint: &destructive,\n")
+		}
+		if a.IdempotentHint {
+			fmt.Fprintf(&b, "\t\tIdempotentHint: true,\n")
+		}
+		if a.OpenWorldHint != nil {
+			fmt.Fprintf(&b, "\t\tOpenWorldHint: &openWorld,\n")
+		}
+		fmt.Fprintf(&b, "\t}\n}\n\n")
+	}
+	fmt.Fprintf(&b, "// Execute runs the %s tool.\n", t.Name)
+	fmt.Fprintf(&b, "func (t *%s) Execute(args map[string]interface{}) ([]mcp.Content, error) {\n", toolType)
+	fmt.Fprintf(&b, "\tvar a %s\n", argsType)
+	fmt.Fprintf(&b, "\tif err := mcp.DecodeArgs(args, &a); err != nil {\n\t\treturn nil, err\n\t}\n")
+	fmt.Fprintf(&b, "\treturn nil, errors.New(%s)\n}\n", strconv.Quote(t.Name+" is not implemented"))
+
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// structType declares a struct type named name for an object schema.
+// Nested objects with properties become struct types of their own.
+func (g *generator) structType(name, doc string, schema map[string]interface{}) {
+	index := len(g.types)
+	g.types = append(g.types, "") // keep declarations in nesting order
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := map[string]bool{}
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, r := range list {
+			if s, ok := r.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for n := range properties {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n", comment(doc))
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	used := map[string]bool{}
+	for _, n := range names {
+		prop, _ := properties[n].(map[string]interface{})
+		field := identifier(n, true)
+		for i := 2; used[field]; i++ {
+			field = identifier(n, true) + strconv.Itoa(i)
+		}
+		used[field] = true
+		typ := g.goType(name+field, n, prop)
+		if !required[n] && typ == name+field {
+			typ = "*" + typ // optional nested objects are pointers, like optional JSON values
+		}
+		tag := `json:"` + n
+		if !required[n] {
+			tag += ",omitempty"
+		}
+		tag += `"` + schemaTags(prop)
+		fmt.Fprintf(&b, "\t%s %s `%s`\n", field, typ, tag)
+	}
+	fmt.Fprintf(&b, "}\n\n")
+	g.types[index] = b.String()
+}
+
+// goType returns the Go type for values matching schema, declaring struct
+// types for nested objects under the name typeName.
+func (g *generator) goType(typeName, property string, schema map[string]interface{}) string {
+	typ, _ := schema["type"].(string)
+	if list, ok := schema["type"].([]interface{}); ok {
+		// A union such as ["string", "null"] maps to its first non-null type.
+		for _, t := range list {
+			if s, _ := t.(string); s != "null" {
+				typ = s
+				break
+			}
+		}
+	}
+	switch typ {
+	case "string":
+		return "string"
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		items, _ := schema["items"].(map[string]interface{})
+		return "[]" + g.goType(typeName+"Item", property, items)
+	case "object":
+		if props, ok := schema["properties"].(map[string]interface{}); ok && len(props) > 0 {
+			g.structType(typeName, fmt.Sprintf("%s holds the %s property.", typeName, property), schema)
+			return typeName
+		}
+		if extra, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			return "map[string]" + g.goType(typeName+"Value", property, extra)
+		}
+		return "map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+// schemaTags returns the struct tags that make mcp.SchemaFor reproduce the
+// description and constraints of schema, each preceded by a space.
+func schemaTags(schema map[string]interface{}) string {
+	var b strings.Builder
+	typ, _ := schema["type"].(string)
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 && (typ == "string" || typ == "integer" || typ == "number") {
+		values := make([]string, 0, len(enum))
+		for _, v := range enum {
+			values = append(values, fmt.Sprint(v))
+		}
+		fmt.Fprintf(&b, " enum:%s", strconv.Quote(strings.Join(values, ",")))
+	}
+	for _, key := range []string{"minimum", "maximum"} {
+		if n, ok := schema[key].(float64); ok {
+			fmt.Fprintf(&b, " %s:\"%s\"", key, strconv.FormatFloat(n, 'g', -1, 64))
+		}
+	}
+	switch d := schema["default"].(type) {
+	case string:
+		if typ == "string" {
+			fmt.Fprintf(&b, " default:%s", strconv.Quote(d))
+		}
+	case float64:
+		if typ == "integer" || typ == "number" {
+			fmt.Fprintf(&b, " default:\"%s\"", strconv.FormatFloat(d, 'g', -1, 64))
+		}
+	case bool:
+		if typ == "boolean" {
+			fmt.Fprintf(&b, " default:\"%t\"", d)
+		}
+	}
+	if d, ok := schema["description"].(string); ok && d != "" {
+		// Struct tags are raw strings, which cannot contain backquotes.
+		fmt.Fprintf(&b, " description:%s", strconv.Quote(strings.ReplaceAll(d, "`", "'")))
+	}
+	return b.String()
+}
+
+// commonInitialisms are written in upper case in Go identifiers.
+var commonInitialisms = map[string]bool{
+	"api": true, "html": true, "http": true, "id": true, "ip": true,
+	"json": true, "sql": true, "uri": true, "url": true, "uuid": true,
+}
+
+// identifier converts a tool or property name such as "get_user-id" into a
+// Go identifier, "GetUserID" if exported, or "getUserID" otherwise.
+func identifier(name string, exported bool) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for i, w := range words {
+		r := []rune(w)
+		switch {
+		case i == 0 && !exported:
+			r[0] = unicode.ToLower(r[0])
+		case commonInitialisms[strings.ToLower(w)]:
+			r = []rune(strings.ToUpper(w))
+		default:
+			r[0] = unicode.ToUpper(r[0])
+		}
+		b.WriteString(string(r))
+	}
+	id := b.String()
+	if id == "" || unicode.IsDigit([]rune(id)[0]) {
+		if exported {
+			return "X" + id
+		}
+		return "x" + id
+	}
+	return id
+}
+
+// fileName returns the name of the file generated for a tool, such as
+// "get_user.go" for "get-user".
+func fileName(tool string) string {
+	words := strings.FieldsFunc(strings.ToLower(tool), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		words = []string{"tool"}
+	}
+	name := strings.Join(words, "_")
+	if strings.HasSuffix(name, "_test") {
+		name += "_tool" // a _test.go suffix would hide the stub from builds
+	}
+	return name + ".go"
+}
+
+// comment formats text as a line comment, wrapped at about 80 columns.
+func comment(text string) string {
+	var lines []string
+	line := "//"
+	for _, w := range strings.Fields(text) {
+		if len(line)+1+len(w) > 78 && line != "//" {
+			lines = append(lines, line)
+			line = "//"
+		}
+		line += " " + w
+	}
+	return strings.Join(append(lines, line), "\n")
+}
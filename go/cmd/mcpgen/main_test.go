@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test the conversion of tool and property names
+func TestIdentifier(t *testing.T) {
+	cases := []struct {
+		name     string
+		exported bool
+		want     string
+	}{
+		{"count_text", false, "countText"},
+		{"get-user.id", true, "GetUserID"},
+		{"api_url", false, "apiURL"},
+		{"3d", true, "X3d"},
+		{"---", false, "x"},
+		{"élan", true, "Élan"},
+	}
+	for _, c := range cases {
+		if got := identifier(c.name, c.exported); got != c.want {
+			t.Errorf("identifier(%q, %v) = %q, want %q", c.name, c.exported, got, c.want)
+		}
+	}
+	if got := fileName("Get-User"); got != "get_user.go" {
+		t.Errorf("fileName = %q", got)
+	}
+	if got := fileName("run_test"); got != "run_test_tool.go" {
+		t.Errorf("fileName = %q", got)
+	}
+}
+
+// Test the accepted input formats
+func TestParseInput(t *testing.T) {
+	response := `{"jsonrpc":"2.0","id":1,"result":{"tools":[{"name":"a","inputSchema":{"type":"object"}}]}}`
+	tools, err := parseInput([]byte(response), "", "")
+	if err != nil || len(tools) != 1 || tools[0].Name != "a" {
+		t.Errorf("response: got %v, %v", tools, err)
+	}
+	tools, err = parseInput([]byte(`{"tools":[{"name":"b"}]}`), "", "")
+	if err != nil || len(tools) != 1 || tools[0].Name != "b" {
+		t.Errorf("result: got %v, %v", tools, err)
+	}
+	tools, err = parseInput([]byte(`{"type":"object"}`), "c", "Does c")
+	if err != nil || len(tools) != 1 || tools[0].Name != "c" || tools[0].Description != "Does c" {
+		t.Errorf("schema: got %v, %v", tools, err)
+	}
+	for _, bad := range []string{`{"type":"object"}`, `{"tools":[{"name":"x-y"},{"name":"x_y"}]}`, `[`} {
+		if _, err := parseInput([]byte(bad), "", ""); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+// generateTools is a tools/list result exercising nested objects, arrays,
+// enums, defaults, optional properties, and annotations.
+const generateTools = `{"tools":[
+{"name":"resize_image","description":"Resizes an image","annotations":{"title":"Resize","readOnlyHint":true,"openWorldHint":false},
+ "inputSchema":{"type":"object","required":["url","size"],"properties":{
+  "url":{"type":"string","description":"Image ` + "`URL`" + `"},
+  "size":{"type":"object","required":["width"],"properties":{"width":{"type":"integer","minimum":1},"height":{"type":"integer"}}},
+  "format":{"type":"string","enum":["png","jpeg"],"default":"png"},
+  "quality":{"type":"number","maximum":1,"default":0.8},
+  "crop":{"type":"object","properties":{"x":{"type":"integer"}}},
+  "tags":{"type":"array","items":{"type":"string"}},
+  "points":{"type":"array","items":{"type":"object","properties":{"x":{"type":"number"}},"required":["x"]}},
+  "metadata":{"type":"object","additionalProperties":{"type":"string"}},
+  "extra":{}}}},
+{"name":"ping","annotations":{"destructiveHint":false},"inputSchema":{"type":"object"}}
+]}`
+
+// Test that the generated stubs compile and reproduce the input schemas
+func TestGenerate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("building the generated code is slow")
+	}
+	tools, err := parseInput([]byte(generateTools), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module gen\n\ngo 1.23\n\nrequire mcp-minimal-server-go v0.0.0\n\nreplace mcp-minimal-server-go => " + root + "\n",
+		"main.go": `package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+func main() {
+	for _, t := range []mcp.Tool{&resizeImageTool{}, &pingTool{}} {
+		out, _ := json.Marshal(map[string]interface{}{"name": t.Name(), "description": t.Description(),
+			"inputSchema": t.InputSchema(), "annotations": t.(interface{ Annotations() mcp.ToolAnnotations }).Annotations()})
+		fmt.Println(string(out))
+	}
+	if _, err := (&pingTool{}).Execute(nil); err != nil {
+		fmt.Println(err)
+	}
+}
+`,
+	}
+	for _, tool := range tools {
+		src, err := generate(tool, "main")
+		if err != nil {
+			t.Fatalf("generate %s: %v", tool.Name, err)
+		}
+		files[fileName(tool.Name)] = string(src)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off", "GOPROXY=off")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s\n%s", err, out, files["resize_image.go"])
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	want := []string{
+		`{"annotations":{"title":"Resize","readOnlyHint":true,"openWorldHint":false},"description":"Resizes an image",` +
+			`"inputSchema":{"properties":{` +
+			`"crop":{"properties":{"x":{"type":"integer"}},"type":"object"},` +
+			`"extra":{},` +
+			`"format":{"default":"png","enum":["png","jpeg"],"type":"string"},` +
+			`"metadata":{"additionalProperties":{"type":"string"},"type":"object"},` +
+			`"points":{"items":{"properties":{"x":{"type":"number"}},"required":["x"],"type":"object"},"type":"array"},` +
+			`"quality":{"default":0.8,"maximum":1,"type":"number"},` +
+			`"size":{"properties":{"height":{"type":"integer"},"width":{"minimum":1,"type":"integer"}},"required":["width"],"type":"object"},` +
+			`"tags":{"items":{"type":"string"},"type":"array"},` +
+			`"url":{"description":"Image 'URL'","type":"string"}` +
+			`},"required":["size","url"],"type":"object"},"name":"resize_image"}`,
+		`{"annotations":{"destructiveHint":false},"description":"","inputSchema":{"properties":{},"type":"object"},"name":"ping"}`,
+		`ping is not implemented`,
+	}
+	for i, line := range lines[:2] {
+		var got, expected interface{}
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d: %v: %s", i, err, line)
+		}
+		json.Unmarshal([]byte(want[i]), &expected)
+		g, _ := json.Marshal(got)
+		e, _ := json.Marshal(expected)
+		if string(g) != string(e) {
+			t.Errorf("tool %d:\n got %s\nwant %s", i, g, e)
+		}
+	}
+	if lines[2] != want[2] {
+		t.Errorf("Execute: got %q, want %q", lines[2], want[2])
+	}
+}
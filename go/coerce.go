@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WithArgumentCoercion converts the arguments of calls to the tools
+// matching one of patterns to the types their input schema declares
+// before they are validated: the string "5" becomes the integer 5, "true"
+// the boolean true, a number the string it spells, and a string holding a
+// JSON array or object the array or object. Models often produce the right
+// value in the wrong JSON type. Values that do not convert are left as
+// they are.
+func WithArgumentCoercion(patterns ...string) Option {
+	return func(s *Server) {
+		s.coerce = patterns
+	}
+}
+
+// coerceArguments converts args in place to the types of the properties of
+// schema, including nested ones, and returns the names of the top-level
+// arguments it changed.
+func coerceArguments(schema map[string]interface{}, args map[string]interface{}) []string {
+	properties, _ := schema["properties"].(map[string]interface{})
+	var changed []string
+	for _, name := range sortedKeys(args) {
+		prop, ok := properties[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if v, ok := coerceValue(prop, args[name]); ok {
+			args[name] = v
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// coerceValue returns v converted to the type schema declares and whether
+// it differs from v. Objects and arrays are converted element by element.
+func coerceValue(schema map[string]interface{}, v interface{}) (interface{}, bool) {
+	types := schemaTypes(schema)
+	changed := false
+	if !matchesAnyType(types, v) {
+		for _, typ := range types {
+			if converted, ok := convertValue(typ, v); ok {
+				v, changed = converted, true
+				break
+			}
+		}
+	}
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if len(coerceArguments(schema, value)) > 0 {
+			changed = true
+		}
+	case []interface{}:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			break
+		}
+		for i, item := range value {
+			if converted, ok := coerceValue(items, item); ok {
+				value[i] = converted
+				changed = true
+			}
+		}
+	}
+	return v, changed
+}
+
+// schemaTypes returns the types schema declares, which "type" gives as one
+// name or a list of them.
+func schemaTypes(schema map[string]interface{}) []string {
+	switch typ := schema["type"].(type) {
+	case string:
+		return []string{typ}
+	case []interface{}:
+		types := make([]string, 0, len(typ))
+		for _, t := range typ {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	case []string:
+		return typ
+	}
+	return nil
+}
+
+// matchesAnyType reports whether v, as decoded from JSON, is of one of
+// types. No types match anything.
+func matchesAnyType(types []string, v interface{}) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, typ := range types {
+		switch v := v.(type) {
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case float64:
+			if typ == "number" || typ == "integer" && v == math.Trunc(v) {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// convertValue converts v to the JSON type typ, if it can be without
+// losing anything.
+func convertValue(typ string, v interface{}) (interface{}, bool) {
+	switch typ {
+	case "integer", "number":
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) || typ == "integer" && f != math.Trunc(f) {
+			return nil, false
+		}
+		return f, true
+	case "boolean":
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	case "string":
+		switch v := v.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+	case "array", "object":
+		s, ok := v.(string)
+		if !ok {
+			return nil, false
+		}
+		var decoded interface{}
+		if json.Unmarshal([]byte(s), &decoded) != nil || !matchesAnyType([]string{typ}, decoded) {
+			return nil, false
+		}
+		return decoded, true
+	}
+	return nil, false
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// coercionSchema declares a property of each type.
+const coercionSchema = `{"type":"object","properties":{
+	"count": {"type": "integer"},
+	"ratio": {"type": "number"},
+	"force": {"type": "boolean"},
+	"label": {"type": "string"},
+	"tags": {"type": "array", "items": {"type": "integer"}},
+	"options": {"type": "object", "properties": {"dry": {"type": "boolean"}}},
+	"limit": {"type": ["integer", "null"]}
+}}`
+
+// Test converting arguments to the types of their schema
+func TestCoerceArguments(t *testing.T) {
+	args := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(`{
+		"count": " 5", "ratio": "0.25", "force": "TRUE", "label": 42,
+		"tags": "[1, \"2\"]", "options": {"dry": "false"}, "limit": null,
+		"extra": "7"
+	}`), &args)
+	changed := coerceArguments(decodeSchema(t, coercionSchema), args)
+	want := map[string]interface{}{
+		"count": float64(5), "ratio": 0.25, "force": true, "label": "42",
+		"tags": []interface{}{float64(1), float64(2)}, "options": map[string]interface{}{"dry": false}, "limit": nil,
+		"extra": "7",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("coerced arguments %v, expected %v", args, want)
+	}
+	if strings.Join(changed, ",") != "count,force,label,options,ratio,tags" {
+		t.Errorf("unexpected changed arguments %v", changed)
+	}
+
+	// Values that do not convert are left for the tool to reject.
+	args = map[string]interface{}{"count": "5.5", "force": "yes", "tags": "{}", "ratio": "NaN"}
+	if changed := coerceArguments(decodeSchema(t, coercionSchema), args); len(changed) != 0 {
+		t.Errorf("expected nothing to be coerced, got %v in %v", changed, args)
+	}
+}
+
+// Test that only the tools configured for it get their arguments coerced
+func TestArgumentCoercion(t *testing.T) {
+	newTool := func(name string) *mockTool {
+		return &mockTool{cfg: mockToolConfig{
+			Name:         name,
+			InputSchema:  decodeSchema(t, coercionSchema),
+			mockResponse: mockResponse{Response: "{{tags}}"},
+		}}
+	}
+	s := NewServer(WithTools(newTool("resize"), newTool("delete")), WithArgumentCoercion("res*"))
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"resize","arguments":{"tags":"[1,\"2\"]"}},"id":1}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"delete","arguments":{"tags":"[1,\"2\"]"}},"id":2}
+`)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses, got %q", lines)
+	}
+	// The calls run concurrently, so their responses may come in any order.
+	out := strings.Join(lines, "\n")
+	if !strings.Contains(out, `{"id":1,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"[1,2]"}]}}`) ||
+		!strings.Contains(out, `"id":2,"error":{"code":-32602,"message":"Invalid parameter 'tags': expected array, got string"`) {
+		t.Errorf("expected only resize to get coerced arguments, got %q", lines)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandToolConfig defines a tool backed by an external executable in the
+// "commandTools" section of the config file.
+type commandToolConfig struct {
+	Name        string                 `json:"name"`
+	Namespace   string                 `json:"namespace"`
+	Description string                 `json:"description"`
+	Command     []string               `json:"command"`     // executable and its arguments
+	Template    string                 `json:"template"`    // shell command line with {{arg}} placeholders
+	InputSchema map[string]interface{} `json:"inputSchema"` // defaults to an object with any properties
+	Timeout     duration               `json:"timeout"`     // zero uses the server's request timeout
+	Annotations *ToolAnnotations       `json:"annotations"` // e.g. {"readOnlyHint": true}
+	Env         map[string]string      `json:"env"`
+	InheritEnv  bool                   `json:"inheritEnv"` // pass the server's whole environment
+	Dir         string                 `json:"dir"`
+	ToolVersion                        // version, deprecated, and replacedBy
+}
+
+// validate reports missing or malformed fields.
+func (c *commandToolConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("command tool without a name")
+	}
+	if (len(c.Command) == 0 || c.Command[0] == "") == (c.Template == "") {
+		return fmt.Errorf("command tool %q: exactly one of command and template must be set", c.Name)
+	}
+	if c.Template != "" {
+		if _, _, err := compileTemplate(c.Template); err != nil {
+			return fmt.Errorf("command tool %q: %w", c.Name, err)
+		}
+	}
+	if err := validateNamespace(c.Namespace); err != nil {
+		return fmt.Errorf("command tool %q: %w", c.Name, err)
+	}
+	return nil
+}
+
+// commandTool runs an external executable for each call. The arguments are
+// written to its standard input as a JSON object and its standard output is
+// returned as text content. A non-zero exit status fails the call with a
+// result flagged as an error, giving the status and the executable's
+// standard error. Tools defined by a template run it with
+// sh -c instead, passing the placeholder values as positional parameters.
+type commandTool struct {
+	cfg commandToolConfig
+}
+
+// newCommandTool returns the tool defined by cfg.
+func newCommandTool(cfg commandToolConfig) *commandTool {
+	return &commandTool{cfg: cfg}
+}
+
+// Name returns the configured tool name.
+func (c *commandTool) Name() string {
+	return c.cfg.Name
+}
+
+// Description returns the configured description.
+func (c *commandTool) Description() string {
+	if c.cfg.Description == "" {
+		if c.cfg.Template != "" {
+			return "Runs " + c.cfg.Template
+		}
+		return "Runs " + c.cfg.Command[0]
+	}
+	return c.cfg.Description
+}
+
+// InputSchema returns the configured schema.
+func (c *commandTool) InputSchema() map[string]interface{} {
+	if c.cfg.InputSchema == nil {
+		return map[string]interface{}{"type": "object"}
+	}
+	return c.cfg.InputSchema
+}
+
+// Annotations returns the configured annotations. Without any, the tool is
+// assumed to modify its environment.
+func (c *commandTool) Annotations() ToolAnnotations {
+	if c.cfg.Annotations == nil {
+		return ToolAnnotations{}
+	}
+	return *c.cfg.Annotations
+}
+
+// Version returns the configured version and deprecation.
+func (c *commandTool) Version() ToolVersion {
+	return c.cfg.ToolVersion
+}
+
+// Timeout returns the configured time limit.
+func (c *commandTool) Timeout() time.Duration {
+	return time.Duration(c.cfg.Timeout)
+}
+
+// Execute runs the command without a deadline of its own.
+func (c *commandTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return c.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the command, killing it when ctx is done.
+func (c *commandTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	argv, payload, err := c.prepare(args)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Dir = c.cfg.Dir
+	cmd.Env = c.environ()
+	cmd.Stdin = bytes.NewReader(payload)
+	// Do not wait for children that keep the output pipes open after the
+	// command itself was killed.
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("%s: %v", c.cfg.Name, err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, toolFailure("%s exited with status %d: %s", c.cfg.Name, exitErr.ExitCode(), msg)
+		}
+		return nil, toolFailure("%s exited with status %d", c.cfg.Name, exitErr.ExitCode())
+	}
+	return []ToolContent{{Type: "text", Text: stdout.String()}}, nil
+}
+
+// DryRun tells which command a call would run, without running it.
+func (c *commandTool) DryRun(args map[string]interface{}) (string, error) {
+	argv, payload, err := c.prepare(args)
+	if err != nil {
+		return "", err
+	}
+	words := make([]string, len(argv))
+	for i, arg := range argv {
+		words[i] = strconv.Quote(arg)
+	}
+	description := "would run " + strings.Join(words, " ")
+	if c.cfg.Dir != "" {
+		description += " in " + c.cfg.Dir
+	}
+	return description + " with " + string(payload) + " on its standard input", nil
+}
+
+// prepare returns the command line of a call and the arguments as JSON
+// for its standard input.
+func (c *commandTool) prepare(args map[string]interface{}) (argv []string, payload []byte, err error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if payload, err = json.Marshal(args); err != nil {
+		return nil, nil, err
+	}
+	argv = c.cfg.Command
+	if c.cfg.Template != "" {
+		if argv, err = templateArgv(c.cfg.Template, args); err != nil {
+			return nil, nil, err
+		}
+	}
+	return argv, payload, nil
+}
+
+// environ returns the environment of the command: the server's environment
+// if InheritEnv is set, otherwise only PATH and HOME, plus the configured
+// variables.
+func (c *commandTool) environ() []string {
+	var env []string
+	if c.cfg.InheritEnv {
+		env = os.Environ()
+	} else {
+		for _, name := range []string{"PATH", "HOME"} {
+			if v, ok := os.LookupEnv(name); ok {
+				env = append(env, name+"="+v)
+			}
+		}
+	}
+	for _, name := range sortedKeys(c.cfg.Env) {
+		env = append(env, name+"="+c.cfg.Env[name])
+	}
+	return env
+}
+
+// compileTemplate turns tmpl into a shell script that reads the value of
+// each {{name}} placeholder from a positional parameter, and returns the
+// names in parameter order. The values are passed to sh as arguments rather
+// than spliced into the script, so they are never parsed by the shell, even
+// inside double quotes. A placeholder is expanded as a single word whether
+// or not it is quoted; placeholders inside single quotes, where the shell
+// expands nothing, are rejected.
+func compileTemplate(tmpl string) (script string, names []string, err error) {
+	var b strings.Builder
+	index := map[string]int{}
+	var single, double bool
+	for i := 0; i < len(tmpl); i++ {
+		c := tmpl[i]
+		switch {
+		case c == '\\' && !single && i+1 < len(tmpl):
+			b.WriteString(tmpl[i : i+2])
+			i++
+			continue
+		case c == '\'' && !double:
+			single = !single
+		case c == '"' && !single:
+			double = !double
+		case c == '{' && strings.HasPrefix(tmpl[i:], "{{"):
+			end := strings.Index(tmpl[i:], "}}")
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated placeholder in template %q", tmpl)
+			}
+			name := strings.TrimSpace(tmpl[i+2 : i+end])
+			if name == "" {
+				return "", nil, fmt.Errorf("empty placeholder in template %q", tmpl)
+			}
+			if single {
+				return "", nil, fmt.Errorf("placeholder {{%s}} inside single quotes in template %q", name, tmpl)
+			}
+			n, ok := index[name]
+			if !ok {
+				names = append(names, name)
+				n = len(names)
+				index[name] = n
+			}
+			if double {
+				fmt.Fprintf(&b, "${%d}", n)
+			} else {
+				fmt.Fprintf(&b, `"${%d}"`, n)
+			}
+			i += end + 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), names, nil
+}
+
+// templateArgv returns the command line running tmpl with args. Missing
+// arguments expand to an empty string. Non-string values are JSON-encoded.
+func templateArgv(tmpl string, args map[string]interface{}) ([]string, error) {
+	script, names, err := compileTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	// The first argument after the script is $0.
+	argv := []string{"sh", "-c", script, "sh"}
+	for _, name := range names {
+		var value string
+		if v, ok := args[name]; ok {
+			value = paramString(v)
+		}
+		argv = append(argv, value)
+	}
+	return argv, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that arguments reach the command on stdin and its output is returned
+func TestCommandTool(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	t.Setenv("SECRET", "hidden")
+	tool := newCommandTool(commandToolConfig{
+		Name:    "shell",
+		Command: []string{"sh", "-c", `cat; echo " $GREETING $SECRET"`},
+		Env:     map[string]string{"GREETING": "hi"},
+	})
+	content, err := tool.ExecuteContext(context.Background(), map[string]interface{}{"a": "b"})
+	if err != nil {
+		t.Fatalf("ExecuteContext error: %v", err)
+	}
+	if len(content) != 1 || content[0].Text != `{"a":"b"} hi `+"\n" {
+		t.Errorf("unexpected content %+v", content)
+	}
+
+	tool.cfg.Command = []string{"sh", "-c", "echo oops key=abc123 >&2; exit 3"}
+	_, err = tool.ExecuteContext(context.Background(), nil)
+	var resultErr *toolResultError
+	if !errors.As(err, &resultErr) || err.Error() != "shell exited with status 3: oops key=abc123" {
+		t.Errorf("expected an error result with the command's status and stderr, got %v", err)
+	}
+	r, err := newRedactor(nil, []string{`key=\w+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"shell","arguments":{}},"id":1}` + "\n"
+	lines := runServerInput(t, NewServer(WithTools(tool), WithRedactor(r)), input)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"text":"shell exited with status 3: oops [REDACTED]"}],"isError":true`) {
+		t.Errorf("expected a redacted error result, got %v", lines)
+	}
+
+	tool.cfg.Command = []string{"sh", "-c", "sleep 5"}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := tool.ExecuteContext(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
+
+// Test that command tools are read from the config file
+func TestLoadConfigCommandTools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"commandTools":[{"name":"date","command":["date","-u"],"timeout":"3s"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig([]string{"--config", path}, io.Discard)
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	opts, err := cfg.serverOptions(slog.Default(), nil)
+	if err != nil {
+		t.Fatalf("serverOptions error: %v", err)
+	}
+	s := NewServer(opts...)
+	last := s.tools[len(s.tools)-1]
+	if last.Name() != "date" || last.(TimeoutTool).Timeout() != 3*time.Second {
+		t.Errorf("expected the date command tool, got %s", last.Name())
+	}
+
+	if err := os.WriteFile(path, []byte(`{"commandTools":[{"name":"date"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadConfig([]string{"--config", path}, io.Discard); err == nil {
+		t.Error("expected an error for a command tool without a command")
+	}
+}
+
+// Test that template placeholders are replaced by safely quoted arguments
+func TestCommandToolTemplate(t *testing.T) {
+	argv, err := templateArgv(`grep -c {{ pattern }} "in {{file}}" {{missing}} {{pattern}}`, map[string]interface{}{
+		"pattern": "it's; rm -rf /",
+		"file":    float64(3),
+	})
+	if err != nil {
+		t.Fatalf("templateArgv error: %v", err)
+	}
+	want := []string{"sh", "-c", `grep -c "${1}" "in ${2}" "${3}" "${1}"`, "sh", "it's; rm -rf /", "3", ""}
+	if !reflect.DeepEqual(argv, want) {
+		t.Errorf("expected %q, got %q", want, argv)
+	}
+	for _, bad := range []string{"echo {{", "echo {{}}", "echo '{{a}}'"} {
+		if _, _, err := compileTemplate(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	tool := newCommandTool(commandToolConfig{Name: "say", Template: "printf '%s|' {{a}} {{b}}"})
+	content, err := tool.Execute(map[string]interface{}{"a": "$(id)", "b": "x y"})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if content[0].Text != "$(id)|x y|" {
+		t.Errorf("unexpected output %q", content[0].Text)
+	}
+	quoted := newCommandTool(commandToolConfig{Name: "say", Template: `printf '%s %s\n' "[{{a}}]" \"{{b}}`})
+	content, err = quoted.Execute(map[string]interface{}{"a": "$(id) `id` \\\"", "b": "*"})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	if want := "[$(id) `id` \\\"] \"*\n"; content[0].Text != want {
+		t.Errorf("expected %q from double-quoted placeholders, got %q", want, content[0].Text)
+	}
+
+	both := commandToolConfig{Name: "both", Command: []string{"true"}, Template: "true"}
+	if err := both.validate(); err == nil {
+		t.Error("expected an error for a tool with both command and template")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// legacyBefore is the first protocol version with tool annotations and
+// audio content. Hosts that agree on an earlier one, 2024-11-05, get
+// responses in that version's shape: tools without annotations, and tool
+// results without structured content or audio blocks, as some of them
+// validate responses strictly. Later versions ignore fields they do not
+// know, so every other host gets the same, full shape.
+const legacyBefore = "2025-03-26"
+
+// legacy reports whether the agreed protocol version predates
+// legacyBefore. Versions are dates, so they order as strings.
+func (l *lifecycle) legacy() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.version != "" && l.version < legacyBefore
+}
+
+// adaptResult returns the content and structured content of a tool result
+// as sent to sess. For a legacy host the structured content is dropped,
+// encoded as text if the result has no other content, and audio blocks
+// become text saying what was left out.
+func (sess *session) adaptResult(content []ToolContent, structured interface{}) ([]ToolContent, interface{}) {
+	if !sess.lifecycle.legacy() {
+		return content, structured
+	}
+	adapted := make([]ToolContent, 0, len(content)+1)
+	for _, c := range content {
+		if c.Type == "audio" {
+			c = ToolContent{Type: "text", Text: fmt.Sprintf("[%s audio left out: the client's protocol version has no audio content]", c.MimeType), Annotations: c.Annotations}
+		}
+		adapted = append(adapted, c)
+	}
+	if len(adapted) == 0 && structured != nil {
+		if encoded, err := json.Marshal(structured); err == nil {
+			adapted = append(adapted, ToolContent{Type: "text", Text: string(encoded)})
+		}
+	}
+	return adapted, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// recordingTool answers with an audio block and structured content.
+type recordingTool struct{ statsTool }
+
+func (recordingTool) Name() string { return "recording" }
+func (recordingTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	return &ToolResult{
+		Content:    []ToolContent{{Type: "audio", Data: "AAAA", MimeType: "audio/wav"}},
+		Structured: map[string]int{"seconds": 1},
+	}, nil
+}
+
+// Test that hosts on the first protocol version get tools without
+// annotations and results without structured content or audio, and later
+// hosts get both
+func TestLegacyProtocol(t *testing.T) {
+	s := NewServer(WithTools(&countTextTool{}, statsTool{}, recordingTool{}))
+	for _, tc := range []struct {
+		version string
+		want    []string
+	}{
+		{"2024-11-05", []string{
+			`"name":"count_text"}`,
+			`{"id":2,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"{\"count\":2}"}]}}`,
+			`{"id":3,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"[audio/wav audio left out: the client's protocol version has no audio content]"}]}}`,
+		}},
+		{"2025-03-26", []string{
+			`"annotations":{"title":"Count text","readOnlyHint":true`,
+			`{"id":2,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"{\"count\":2}"}],"structuredContent":{"count":2}}}`,
+			`{"id":3,"jsonrpc":"2.0","result":{"content":[{"type":"audio","data":"AAAA","mimeType":"audio/wav"}],"structuredContent":{"seconds":1}}}`,
+		}},
+	} {
+		lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"`+tc.version+`"},"id":"init"}`+"\n"+
+			`{"jsonrpc":"2.0","method":"notifications/initialized"}`+"\n"+
+			`{"jsonrpc":"2.0","method":"tools/list","id":1}`+"\n"+
+			`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"stats"},"id":2}`+"\n"+
+			`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"recording"},"id":3}`+"\n")
+		if len(lines) != 3 {
+			t.Fatalf("%s: expected 3 responses, got %q", tc.version, lines)
+		}
+		// Tool calls may finish in any order.
+		byID := map[string]string{}
+		for _, line := range lines {
+			var r struct {
+				ID json.RawMessage `json:"id"`
+			}
+			if err := json.Unmarshal([]byte(line), &r); err != nil {
+				t.Fatalf("%s: expected a response, got %s", tc.version, line)
+			}
+			byID[string(r.ID)] = line
+		}
+		if list := byID["1"]; !strings.Contains(list, tc.want[0]) || strings.Contains(list, `"annotations"`) == (tc.version == "2024-11-05") {
+			t.Errorf("%s: unexpected tools list %s", tc.version, list)
+		}
+		for i, want := range tc.want[1:] {
+			if got := byID[strconv.Itoa(i+2)]; got != want {
+				t.Errorf("%s: expected %s, got %s", tc.version, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"mcp-minimal-server-go/client"
+)
+
+// maxCompletionValues is the most values a completion/complete result
+// holds, as the specification requires.
+const maxCompletionValues = 100
+
+// Completer returns suggested values of a prompt argument for the text
+// value typed so far. args holds the prompt's arguments already filled in,
+// so that suggestions can depend on them.
+type Completer func(ctx context.Context, value string, args map[string]string) ([]string, error)
+
+// completerKey identifies the argument of a prompt a Completer serves.
+type completerKey struct {
+	prompt, argument string
+}
+
+// WithPromptCompleter completes the argument of the named prompt with c,
+// instead of the values declared in a prompt file or the upstream's
+// completions.
+func WithPromptCompleter(prompt, argument string, c Completer) Option {
+	return func(s *Server) {
+		if s.completers == nil {
+			s.completers = map[completerKey]Completer{}
+		}
+		s.completers[completerKey{prompt, argument}] = c
+	}
+}
+
+// completeParams holds the parameters of completion/complete.
+type completeParams struct {
+	Ref struct {
+		Type string `json:"type"`
+		Name string `json:"name,omitempty"` // of a prompt
+		URI  string `json:"uri,omitempty"`  // of a resource template
+	} `json:"ref"`
+	Argument struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"argument"`
+	Context struct {
+		Arguments map[string]string `json:"arguments,omitempty"`
+	} `json:"context"`
+}
+
+// complete answers a completion/complete request. The server has no
+// resource templates, so resource references get no suggestions.
+func (s *Server) complete(w io.Writer, sess *session, id interface{}, raw json.RawMessage) {
+	var params completeParams
+	if err := json.Unmarshal(raw, &params); err != nil || params.Ref.Type == "" || params.Argument.Name == "" {
+		sendError(w, id, -32602, "Invalid parameters: missing ref or argument name")
+		return
+	}
+	switch params.Ref.Type {
+	case "ref/resource":
+		sendCompletion(w, id, nil)
+		return
+	case "ref/prompt":
+	default:
+		sendError(w, id, -32602, fmt.Sprintf("Invalid parameters: unknown reference type %s", params.Ref.Type))
+		return
+	}
+
+	name, arg := params.Ref.Name, params.Argument.Name
+	if c := s.completers[completerKey{name, arg}]; c != nil {
+		if !s.dispatch(sess, priorityInteractive, func() {
+			ctx, cancel := s.upstreamContext(sess.ctx)
+			defer cancel()
+			values, err := c(ctx, params.Argument.Value, params.Context.Arguments)
+			if err != nil {
+				s.logger.Warn("completion failed", "prompt", name, "argument", arg, "error", err)
+				sendError(w, id, -32603, "Completion failed")
+				return
+			}
+			sendCompletion(w, id, values)
+		}) {
+			sendError(w, id, -32603, "Server is shutting down")
+		}
+		return
+	}
+	if s.library != nil {
+		if p := s.library.prompt(name); p != nil {
+			if !p.hasArgument(arg) {
+				sendError(w, id, -32602, fmt.Sprintf("Unknown argument %s of prompt %s", arg, name))
+				return
+			}
+			sendCompletion(w, id, matchingValues(p.values[arg], params.Argument.Value))
+			return
+		}
+	}
+	u, upstreamName := s.promptUpstream(name)
+	if u == nil {
+		sendError(w, id, -32602, fmt.Sprintf("Unknown prompt: %s", name))
+		return
+	}
+	if !s.dispatch(sess, priorityInteractive, func() {
+		ctx, cancel := s.upstreamContext(sess.ctx)
+		defer cancel()
+		completion, err := u.client.CompletePrompt(ctx, upstreamName, arg, params.Argument.Value, params.Context.Arguments)
+		if err == nil {
+			limitCompletion(completion)
+		}
+		s.sendUpstreamResult(w, id, map[string]interface{}{"completion": completion}, err)
+	}) {
+		sendError(w, id, -32603, "Server is shutting down")
+	}
+}
+
+// hasArgument reports whether p has the named argument.
+func (p *libraryPrompt) hasArgument(name string) bool {
+	for _, a := range p.Arguments {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingValues returns the values that start with prefix, ignoring
+// case.
+func matchingValues(values []string, prefix string) []string {
+	var matching []string
+	prefix = strings.ToLower(prefix)
+	for _, v := range values {
+		if strings.HasPrefix(strings.ToLower(v), prefix) {
+			matching = append(matching, v)
+		}
+	}
+	return matching
+}
+
+// limitCompletion cuts the values of c to maxCompletionValues, setting
+// Total and HasMore if it does.
+func limitCompletion(c *client.Completion) {
+	if len(c.Values) > maxCompletionValues {
+		c.Total = max(c.Total, len(c.Values))
+		c.HasMore = true
+		c.Values = c.Values[:maxCompletionValues]
+	}
+}
+
+// sendCompletion writes the completion/complete response suggesting
+// values.
+func sendCompletion(w io.Writer, id interface{}, values []string) {
+	c := &client.Completion{Values: values}
+	limitCompletion(c)
+	if c.Values == nil {
+		c.Values = []string{}
+	}
+	sendResponse(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  map[string]interface{}{"completion": c},
+	})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcp-minimal-server-go/client"
+)
+
+// Test completing prompt arguments from declared values, a Completer, and
+// an upstream
+func TestCompletion(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFiles(t, dir, map[string]string{
+		"deploy.md": "---\narguments:\n  - name: project\n    values: [api, Admin, web]\n  - name: env\n---\nDeploy {{project}} to {{env}}.",
+	})
+	quiet := slog.New(slog.NewTextHandler(io.Discard, nil))
+	library, err := newPromptLibrary(dir, quiet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	envs := func(ctx context.Context, value string, args map[string]string) ([]string, error) {
+		if args["project"] == "" {
+			return nil, errors.New("no project")
+		}
+		var values []string
+		for i := 0; i < 150; i++ {
+			values = append(values, fmt.Sprintf("%s-%s%d", args["project"], value, i))
+		}
+		return values, nil
+	}
+	up := pipeUpstream(t, NewServer(WithTools(), WithLogger(quiet), WithPromptDir(library), WithPromptCompleter("deploy", "env", envs)), upstreamConfig{Name: "up", Namespace: "up"})
+	c := up.client
+
+	complete := func(c *client.Client, name, arg, value string, args map[string]string) *client.Completion {
+		t.Helper()
+		completion, err := c.CompletePrompt(context.Background(), name, arg, value, args)
+		if err != nil {
+			t.Fatalf("%s %s: %v", name, arg, err)
+		}
+		return completion
+	}
+	if got := complete(c, "deploy", "project", "a", nil); !reflect.DeepEqual(got, &client.Completion{Values: []string{"api", "Admin"}}) {
+		t.Errorf("unexpected completion %+v", got)
+	}
+	if got := complete(c, "deploy", "project", "x", nil); got.Values == nil || len(got.Values) != 0 {
+		t.Errorf("expected no values, got %+v", got)
+	}
+	got := complete(c, "deploy", "env", "p", map[string]string{"project": "web"})
+	if len(got.Values) != maxCompletionValues || got.Values[0] != "web-p0" || got.Total != 150 || !got.HasMore {
+		t.Errorf("expected the first %d of 150 values, got %+v", maxCompletionValues, got)
+	}
+	for _, args := range [][3]string{{"deploy", "owner", "unknown argument"}, {"missing", "x", "unknown prompt"}, {"deploy", "env", "completion failed"}} {
+		_, err := c.CompletePrompt(context.Background(), args[0], args[1], "", nil)
+		var rpcErr *client.RPCError
+		if !errors.As(err, &rpcErr) || !strings.Contains(strings.ToLower(rpcErr.Message), args[2]) {
+			t.Errorf("%v: expected an error containing %q, got %v", args, args[2], err)
+		}
+	}
+
+	// A proxy forwards completions to the upstream under its own name.
+	proxy := pipeUpstream(t, NewServer(WithTools(), WithUpstreams(up)), upstreamConfig{Name: "proxy"})
+	if got := complete(proxy.client, "up.deploy", "project", "W", nil); !reflect.DeepEqual(got.Values, []string{"web"}) {
+		t.Errorf("unexpected proxied completion %+v", got)
+	}
+}
@@ -0,0 +1,806 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// config holds the settings of the server binary. Values start from
+// defaults, are overridden by the --config file, then by command-line flags,
+// and finally by MCP_* environment variables.
+type config struct {
+	ConfigFile         string                 `json:"-"`
+	Version            bool                   `json:"-"`
+	REPL               bool                   `json:"-"`
+	Replay             string                 `json:"-"`
+	Daemon             bool                   `json:"-"`
+	Detach             bool                   `json:"-"`
+	Service            bool                   `json:"-"`
+	PIDFile            string                 `json:"pidFile"`
+	LogFile            string                 `json:"logFile"`
+	LogMaxSize         int                    `json:"logMaxSize"`
+	LogMaxBackups      int                    `json:"logMaxBackups"`
+	Transport          string                 `json:"transport"`
+	Addr               string                 `json:"addr"`
+	AllowedHosts       stringList             `json:"allowedHosts"`
+	AllowedOrigins     stringList             `json:"allowedOrigins"`
+	SessionIdleTimeout duration               `json:"sessionIdleTimeout"`
+	ConnIdleTimeout    duration               `json:"connIdleTimeout"`
+	Keepalive          duration               `json:"keepalive"`
+	MaxSessions        int                    `json:"maxSessions"`
+	LogLevel           string                 `json:"logLevel"`
+	Tools              stringList             `json:"tools"`
+	AllowTools         stringList             `json:"allowTools"`
+	DenyTools          stringList             `json:"denyTools"`
+	CoerceArguments    stringList             `json:"coerceArguments"` // patterns of tools whose arguments are coerced
+	PluginsDir         string                 `json:"pluginsDir"`
+	ImageDir           string                 `json:"imageDir"`
+	CalendarDir        string                 `json:"calendarDir"`
+	TextDir            string                 `json:"textDir"`
+	SpreadsheetDir     string                 `json:"spreadsheetDir"`
+	WorkspaceIgnore    stringList             `json:"workspaceIgnore"` // patterns of files the file-oriented tools leave alone
+	WorkspaceRoots     bool                   `json:"workspaceRoots"`  // read files under the client's roots without a directory
+	GoModule           string                 `json:"goModule"`        // enables the Go tools
+	ResourcesDir       string                 `json:"resourcesDir"`    // files served as resources
+	ResourcesPoll      duration               `json:"resourcesPoll"`   // how often to look for changed resource files
+	PromptsDir         string                 `json:"promptsDir"`      // prompt files served as prompts
+	GeoIPDatabases     stringList             `json:"geoipDatabases"`  // MaxMind DB files; enables the geoip tool
+	PluginsNamespace   string                 `json:"pluginsNamespace"`
+	RenameTools        map[string]string      `json:"renameTools"` // namespaced tool name to served name
+	CommandTools       []commandToolConfig    `json:"commandTools"`
+	MockTools          string                 `json:"mockTools"`  // file of mock tools served instead of the built-in ones
+	SchemaDefs         map[string]interface{} `json:"schemaDefs"` // shared input schema definitions, config file only
+	Upstreams          []upstreamConfig       `json:"upstreams"`
+	OpenAPI            []openAPIConfig        `json:"openapi"`
+	GRPC               []grpcConfig           `json:"grpc"`
+	OAuth              *oauthConfig           `json:"oauth"` // authorization for the http transport
+	Email              *emailConfig           `json:"email"` // enables the send_email tool
+	Webhooks           []webhookConfig        `json:"webhooks"`
+	Currency           *currencyConfig        `json:"currency"`      // enables the convert_currency tool
+	SQL                *sqlConfig             `json:"sql"`           // enables the sql_query tool
+	Redis              *redisConfig           `json:"redis"`         // enables the redis tool
+	Kubernetes         *kubernetesConfig      `json:"kubernetes"`    // enables the kubectl_get tool
+	Docker             *dockerConfig          `json:"docker"`        // enables the docker tool
+	ObjectStorage      *objectStorageConfig   `json:"objectStorage"` // enables the object_get, object_list, and object_put tools
+	Search             *searchConfig          `json:"search"`        // enables the index_documents and semantic_search tools
+	TailLog            *tailLogConfig         `json:"tailLog"`       // enables the tail_log tool
+	HTTPMock           *httpMockConfig        `json:"httpMock"`      // enables the http_mock_start, http_mock_requests, and http_mock_stop tools
+	Reminders          *remindersConfig       `json:"reminders"`     // enables the schedule_reminder, list_reminders, and cancel_reminder tools
+	Formatters         map[string][]string    `json:"formatters"`    // language to format_code command, config file only
+	DebugLog           string                 `json:"debugLog"`
+	RequestLog         bool                   `json:"requestLog"`
+	Record             string                 `json:"record"`
+	RedactKeys         stringList             `json:"redactKeys"`
+	RedactPatterns     []string               `json:"redactPatterns"` // regular expressions, config file only
+	MetricsAddr        string                 `json:"metricsAddr"`
+	Pprof              bool                   `json:"pprof"`
+	PprofToken         string                 `json:"pprofToken"` // bearer token the pprof profiles need
+	RESTGateway        bool                   `json:"restGateway"`
+	DrainTimeout       duration               `json:"drainTimeout"`
+	RequestTimeout     duration               `json:"requestTimeout"`
+	Workers            int                    `json:"workers"`
+	Queue              int                    `json:"queue"`
+	MaxMessageSize     int                    `json:"maxMessageSize"`
+	MaxResultSize      int                    `json:"maxResultSize"`
+	StatusTool         bool                   `json:"statusTool"`
+	BatchCallTool      bool                   `json:"batchCallTool"`
+	ClipboardTools     bool                   `json:"clipboardTools"`
+	ReadWebpage        bool                   `json:"readWebpage"`
+	WhoisTool          bool                   `json:"whoisTool"`
+	Instructions       string                 `json:"instructions"` // returned by initialize
+	ServerName         string                 `json:"serverName"`
+	ServerVersion      string                 `json:"serverVersion"`
+	ServerTitle        string                 `json:"serverTitle"`
+	ReadOnly           bool                   `json:"readOnly"`
+	ConfirmDestructive bool                   `json:"confirmDestructive"`
+	ResultMeta         bool                   `json:"resultMeta"`  // report what tool calls cost in their results
+	Diagnostics        int                    `json:"diagnostics"` // tool panics and timeouts kept as a resource
+	RateLimit          string                 `json:"rateLimit"`
+	ToolRateLimits     string                 `json:"toolRateLimits"`
+	SessionBudget      string                 `json:"sessionBudget"`
+	ToolCache          string                 `json:"toolCache"`
+}
+
+// defaultConfig returns the configuration used when nothing is specified.
+func defaultConfig() *config {
+	return &config{
+		Transport:          "stdio",
+		Addr:               "127.0.0.1:8080",
+		SessionIdleTimeout: duration(30 * time.Minute),
+		ConnIdleTimeout:    duration(2 * time.Minute),
+		MaxSessions:        1000,
+		LogLevel:           "info",
+		LogMaxSize:         10 << 20,
+		LogMaxBackups:      3,
+		DrainTimeout:       duration(5 * time.Second),
+		RequestTimeout:     duration(60 * time.Second),
+		ResourcesPoll:      duration(2 * time.Second),
+		Workers:            16,
+		MaxMessageSize:     defaultMaxMessageSize,
+		MaxResultSize:      defaultMaxMessageSize,
+	}
+}
+
+// flagSet returns a FlagSet that stores parsed flags into cfg.
+func (cfg *config) flagSet(output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("mcp-minimal-server", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&cfg.ConfigFile, "config", cfg.ConfigFile, "read settings from the JSON `FILE`; flags take precedence")
+	fs.BoolVar(&cfg.Version, "version", cfg.Version, "print the version and exit")
+	fs.BoolVar(&cfg.REPL, "repl", cfg.REPL, "list and call tools from an interactive prompt instead of serving JSON-RPC")
+	fs.StringVar(&cfg.Transport, "transport", cfg.Transport, "transports to serve on: stdio, http, or both as stdio,http")
+	fs.StringVar(&cfg.Addr, "addr", cfg.Addr, "listen `ADDR` for the http transport")
+	fs.Var(&cfg.AllowedHosts, "allowed-hosts", "comma-separated `HOSTS` accepted in the Host header (default loopback names when listening on loopback)")
+	fs.Var(&cfg.AllowedOrigins, "allowed-origins", "comma-separated `ORIGINS` accepted from browsers (default loopback origins when listening on loopback, otherwise only the server's own)")
+	fs.Var(&cfg.SessionIdleTimeout, "session-idle-timeout", "end http sessions unused for this long (0 for no limit)")
+	fs.Var(&cfg.ConnIdleTimeout, "conn-idle-timeout", "close idle keep-alive http connections after this long (0 for no limit)")
+	fs.Var(&cfg.Keepalive, "keepalive", "ping stdio clients this often, ending the session when one does not answer in time (0 for no pings)")
+	fs.IntVar(&cfg.MaxSessions, "max-sessions", cfg.MaxSessions, "most http sessions at once; beyond it the least recently used one ends (0 for no limit)")
+	fs.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "minimum `LEVEL` of log messages: debug, info, warn, or error")
+	fs.BoolVar(&cfg.Daemon, "daemon", cfg.Daemon, "run as a daemon serving the http transport, which restarts on SIGHUP, rereading its configuration, and can keep a --pid-file and a --log-file")
+	fs.BoolVar(&cfg.Detach, "detach", cfg.Detach, "start the daemon in the background, returning once it has written its --pid-file; needs a --log-file")
+	fs.BoolVar(&cfg.Service, "service", cfg.Service, "run the daemon as a Windows service, which stops on the service manager's stop control and restarts on its paramchange control; needs a --log-file")
+	fs.StringVar(&cfg.PIDFile, "pid-file", cfg.PIDFile, "write the daemon's process ID to `FILE`, refusing to start while the process recorded there runs")
+	fs.StringVar(&cfg.LogFile, "log-file", cfg.LogFile, "write the daemon's log to `FILE` instead of standard error")
+	fs.IntVar(&cfg.LogMaxSize, "log-max-size", cfg.LogMaxSize, "rotate --log-file before it grows past `N` bytes (0 to never rotate)")
+	fs.IntVar(&cfg.LogMaxBackups, "log-max-backups", cfg.LogMaxBackups, "keep `N` rotated log files, FILE.1 the newest")
+	fs.Var(&cfg.Tools, "tools", "comma-separated `NAMES` of the tools to serve (default all)")
+	fs.Var(&cfg.AllowTools, "allow-tools", "expose only tools matching one of the comma-separated glob `PATTERNS`")
+	fs.Var(&cfg.DenyTools, "deny-tools", "never expose tools matching one of the comma-separated glob `PATTERNS`")
+	fs.Var(&cfg.CoerceArguments, "coerce-arguments", "convert the arguments of tools matching one of the comma-separated glob `PATTERNS` to the types their schemas declare, such as \"5\" to 5")
+	fs.StringVar(&cfg.MockTools, "mock-tools", cfg.MockTools, "serve the tools defined in the JSON or YAML `FILE` instead of the built-in tools, answering calls with their canned responses")
+	fs.StringVar(&cfg.PluginsDir, "plugins-dir", cfg.PluginsDir, "load additional tools from the Go (*.so), WebAssembly (*.wasm), and Starlark (*.star) plugins in `DIR`")
+	fs.StringVar(&cfg.PluginsNamespace, "plugins-namespace", cfg.PluginsNamespace, "serve plugin tools as `NS`.name")
+	fs.StringVar(&cfg.ImageDir, "image-dir", cfg.ImageDir, "let image_transform read images from files under `DIR`")
+	fs.Var(&cfg.GeoIPDatabases, "geoip-db", "expose the geoip tool, looking addresses up in the comma-separated MaxMind DB (.mmdb) `FILES`")
+	fs.StringVar(&cfg.CalendarDir, "calendar-dir", cfg.CalendarDir, "let parse_ics read calendars from files under `DIR`")
+	fs.StringVar(&cfg.TextDir, "text-dir", cfg.TextDir, "let chunk_text read text from files under `DIR`")
+	fs.StringVar(&cfg.SpreadsheetDir, "spreadsheet-dir", cfg.SpreadsheetDir, "let read_spreadsheet read .xlsx workbooks under `DIR`")
+	fs.Var(&cfg.WorkspaceIgnore, "workspace-ignore", "keep image_transform, parse_ics, chunk_text, read_spreadsheet, and --resources-dir away from files matching one of the comma-separated glob `PATTERNS`, such as .env or *.key")
+	fs.BoolVar(&cfg.WorkspaceRoots, "workspace-roots", cfg.WorkspaceRoots, "let image_transform, parse_ics, chunk_text, and read_spreadsheet read files under the client's first root when --image-dir, --calendar-dir, --text-dir, or --spreadsheet-dir is not set")
+	fs.StringVar(&cfg.GoModule, "go-module", cfg.GoModule, "expose the go_doc, find_symbol, and go_modules tools for the Go module in `DIR`")
+	fs.StringVar(&cfg.ResourcesDir, "resources-dir", cfg.ResourcesDir, "serve the files under `DIR` as resources, binary ones base64-encoded")
+	fs.Var(&cfg.ResourcesPoll, "resources-poll", "walk --resources-dir this often for added, removed, and changed files, to notify clients; longer intervals cost less on large directories but notify later (0 to not look)")
+	fs.StringVar(&cfg.PromptsDir, "prompts-dir", cfg.PromptsDir, "serve the Markdown and YAML prompt files under `DIR` as prompts, rereading them when they change")
+	fs.StringVar(&cfg.DebugLog, "debug-log", cfg.DebugLog, "record every JSON-RPC message with timestamps to `FILE`")
+	fs.BoolVar(&cfg.RequestLog, "request-log", cfg.RequestLog, "log every request with a correlation ID, its duration, and its outcome, and at debug level its payload")
+	fs.StringVar(&cfg.Record, "record", cfg.Record, "record the session, unredacted, to `FILE` for --replay")
+	fs.StringVar(&cfg.Replay, "replay", cfg.Replay, "feed the client messages recorded in `FILE` to the server and report differing responses")
+	fs.Var(&cfg.RedactKeys, "redact-keys", "comma-separated JSON `KEYS` whose values are redacted from logs, in addition to token, authorization, *password, *secret, ...")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "serve Prometheus metrics on http://`ADDR`/metrics")
+	fs.BoolVar(&cfg.Pprof, "pprof", cfg.Pprof, "serve pprof profiles under /debug/pprof/ on the http transport, to requests bearing --pprof-token")
+	fs.StringVar(&cfg.PprofToken, "pprof-token", cfg.PprofToken, "bearer `TOKEN` the pprof profiles need; best set with MCP_PPROF_TOKEN")
+	fs.BoolVar(&cfg.RESTGateway, "rest-gateway", cfg.RESTGateway, "also serve the tools on the http transport to clients that do not speak MCP: GET /tools lists them and POST /tools/NAME calls one with a JSON object of arguments")
+	fs.Var(&cfg.DrainTimeout, "drain-timeout", "how long to wait for in-flight requests on shutdown")
+	fs.Var(&cfg.RequestTimeout, "request-timeout", "maximum duration of a single request (0 for no limit)")
+	fs.IntVar(&cfg.Workers, "workers", cfg.Workers, "maximum number of tool calls executing at once")
+	fs.IntVar(&cfg.Queue, "queue", cfg.Queue, "queue up to `N` requests waiting for a worker, starting the interactive ones first, so that ping and list requests are answered while every worker is busy (0 to stop reading instead)")
+	fs.IntVar(&cfg.MaxMessageSize, "max-message-size", cfg.MaxMessageSize, "largest inbound message in bytes (0 for no limit)")
+	fs.IntVar(&cfg.MaxResultSize, "max-result-size", cfg.MaxResultSize, "largest tool result in bytes (0 for no limit)")
+	fs.BoolVar(&cfg.ReadOnly, "read-only", cfg.ReadOnly, "expose only tools annotated as read-only")
+	fs.BoolVar(&cfg.ConfirmDestructive, "confirm-destructive", cfg.ConfirmDestructive, "ask the client to confirm each call to a destructive tool")
+	fs.IntVar(&cfg.Diagnostics, "diagnostics", cfg.Diagnostics, "keep the last `N` tool calls that panicked or timed out, served as the diagnostics://recent resource (0 for none)")
+	fs.BoolVar(&cfg.ResultMeta, "result-meta", cfg.ResultMeta, "report the duration, argument and result sizes, and cache use of each tool call in the _meta of its result")
+	fs.StringVar(&cfg.Instructions, "instructions", cfg.Instructions, "return `TEXT` as the instructions on how to use this server's tools")
+	fs.StringVar(&cfg.ServerName, "server-name", cfg.ServerName, "report `NAME` as the server's name (default "+serverName+")")
+	fs.StringVar(&cfg.ServerVersion, "server-version", cfg.ServerVersion, "report `VERSION` as the server's version (default the version of this binary)")
+	fs.StringVar(&cfg.ServerTitle, "server-title", cfg.ServerTitle, "report `TITLE` as the server's display name")
+	fs.BoolVar(&cfg.StatusTool, "status-tool", cfg.StatusTool, "expose the built-in server_status tool")
+	fs.BoolVar(&cfg.BatchCallTool, "batch-call", cfg.BatchCallTool, "expose the built-in batch_call tool, which makes several tool calls at once")
+	fs.BoolVar(&cfg.ReadWebpage, "read-webpage", cfg.ReadWebpage, "expose the read_webpage tool, which fetches public web pages as Markdown")
+	fs.BoolVar(&cfg.WhoisTool, "whois-tool", cfg.WhoisTool, "expose the whois tool, which looks domains and IP addresses up with RDAP and WHOIS")
+	fs.BoolVar(&cfg.ClipboardTools, "clipboard-tools", cfg.ClipboardTools, "expose the clipboard_get and clipboard_set tools, which read and replace the user's clipboard")
+	fs.StringVar(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "limit requests per session to `RATE[:BURST]` per second")
+	fs.StringVar(&cfg.SessionBudget, "session-budget", cfg.SessionBudget, "limit what the tool calls of each session consume in total, as comma-separated `LIMITS` calls=N, time=DURATION, and bytes=N of results")
+	fs.StringVar(&cfg.ToolRateLimits, "tool-rate-limits", cfg.ToolRateLimits, "limit calls per tool, as comma-separated `TOOL=RATE[:BURST]`")
+	fs.StringVar(&cfg.ToolCache, "tool-cache", cfg.ToolCache, "cache results of idempotent tools, as comma-separated `TOOL=TTL[:SIZE]`")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage of %s:\n", fs.Name())
+		fs.PrintDefaults()
+		fmt.Fprintf(output, "\nEvery flag except -version can also be set with an MCP_* environment variable,\ne.g. MCP_LOG_LEVEL for -log-level. Environment variables override flags.\n")
+		fmt.Fprintf(output, "\nTo call a single tool and exit: %s call TOOL [--arg KEY=VALUE ...] [--json] [flags]\n", fs.Name())
+		fmt.Fprintf(output, "To summarize what an MCP server offers: %s inspect [--url URL] [COMMAND [ARGS...]]\n", fs.Name())
+	}
+	return fs
+}
+
+// loadConfig builds the configuration from args and the environment. The
+// flags are parsed once to find the --config file and, if there is one,
+// parsed again after loading it so that explicit flags override the file.
+func loadConfig(args []string, output io.Writer) (*config, error) {
+	cfg := defaultConfig()
+	fs := cfg.flagSet(output)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := applyEnv(fs); err != nil {
+		return nil, err
+	}
+	if cfg.ConfigFile != "" {
+		data, err := os.ReadFile(cfg.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.ConfigFile, err)
+		}
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if err := applyEnv(fs); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, t := range cfg.transports() {
+		switch {
+		case t != "stdio" && t != "http":
+			return nil, fmt.Errorf("unknown transport %q", t)
+		case seen[t]:
+			return nil, fmt.Errorf("transport %q given twice", t)
+		}
+		seen[t] = true
+	}
+	if cfg.REPL && cfg.Transport != "stdio" {
+		return nil, fmt.Errorf("--repl cannot be used with the %s transport", cfg.Transport)
+	}
+	if (cfg.Record != "" || cfg.Replay != "") && (cfg.REPL || cfg.Transport != "stdio") {
+		return nil, fmt.Errorf("--record and --replay need the stdio transport without --repl")
+	}
+	if cfg.Pprof && !cfg.serves("http") {
+		return nil, fmt.Errorf("--pprof needs the http transport")
+	}
+	if cfg.Pprof && cfg.PprofToken == "" {
+		return nil, fmt.Errorf("--pprof needs a --pprof-token")
+	}
+	if cfg.RESTGateway && !cfg.serves("http") {
+		return nil, fmt.Errorf("--rest-gateway needs the http transport")
+	}
+	if err := cfg.validateDaemon(); err != nil {
+		return nil, err
+	}
+	if _, err := parseLogLevel(cfg.LogLevel); err != nil {
+		return nil, err
+	}
+	if err := validateToolPatterns(append(cfg.AllowTools, cfg.DenyTools...)); err != nil {
+		return nil, err
+	}
+	if err := validateToolPatterns(cfg.CoerceArguments); err != nil {
+		return nil, err
+	}
+	if _, err := newRedactor(cfg.RedactKeys, cfg.RedactPatterns); err != nil {
+		return nil, fmt.Errorf("invalid redaction pattern: %w", err)
+	}
+	if err := validateNamespace(cfg.PluginsNamespace); err != nil {
+		return nil, err
+	}
+	for i := range cfg.CommandTools {
+		if err := cfg.CommandTools[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range cfg.Upstreams {
+		if err := cfg.Upstreams[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.OAuth != nil {
+		if err := cfg.OAuth.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Email != nil {
+		if err := cfg.Email.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateWebhooks(cfg.Webhooks); err != nil {
+		return nil, err
+	}
+	if cfg.Currency != nil {
+		if err := cfg.Currency.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SQL != nil {
+		if err := cfg.SQL.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Redis != nil {
+		if err := cfg.Redis.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Kubernetes != nil {
+		if err := cfg.Kubernetes.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Docker != nil {
+		if err := cfg.Docker.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ObjectStorage != nil {
+		if err := cfg.ObjectStorage.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Search != nil {
+		if err := cfg.Search.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.TailLog != nil {
+		if err := cfg.TailLog.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.HTTPMock != nil {
+		if err := cfg.HTTPMock.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Reminders != nil {
+		if err := cfg.Reminders.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateFormatters(cfg.Formatters); err != nil {
+		return nil, err
+	}
+	for i := range cfg.OpenAPI {
+		if err := cfg.OpenAPI[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range cfg.GRPC {
+		if err := cfg.GRPC[i].validate(); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// envAliases maps alternative environment variable names to the flag they
+// set. They apply only when the flag's own variable is unset.
+var envAliases = map[string]string{
+	"MCP_ALLOWED_TOOLS": "allow-tools",
+	"MCP_DENIED_TOOLS":  "deny-tools",
+}
+
+// envName returns the environment variable that overrides the named flag,
+// e.g. MCP_LOG_LEVEL for --log-level.
+func envName(flagName string) string {
+	return "MCP_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets every flag in fs whose MCP_* environment variable is set.
+// Host applications often can only pass environment variables to the stdio
+// servers they spawn, so these take precedence over flags.
+func applyEnv(fs *flag.FlagSet) error {
+	set := map[string]bool{}
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || f.Name == "version" {
+			return
+		}
+		name := envName(f.Name)
+		if v, ok := os.LookupEnv(name); ok {
+			if err = fs.Set(f.Name, v); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+			set[f.Name] = true
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, alias := range sortedKeys(envAliases) {
+		flagName := envAliases[alias]
+		if v, ok := os.LookupEnv(alias); ok && !set[flagName] {
+			if err := fs.Set(flagName, v); err != nil {
+				return fmt.Errorf("%s: %w", alias, err)
+			}
+		}
+	}
+	return nil
+}
+
+// transports returns the transports to serve on.
+func (cfg *config) transports() []string {
+	return strings.Split(cfg.Transport, ",")
+}
+
+// serves reports whether the server is to serve on transport.
+func (cfg *config) serves(transport string) bool {
+	return slices.Contains(cfg.transports(), transport)
+}
+
+// workspace returns the read-only workspace of a tool reading files under
+// dir, in the client's roots if dir is empty and WorkspaceRoots is set, and
+// nil if the tool reads no files.
+func (cfg *config) workspace(dir string) (*Workspace, error) {
+	if dir == "" {
+		if !cfg.WorkspaceRoots {
+			return nil, nil
+		}
+		if err := checkIgnorePatterns(cfg.WorkspaceIgnore); err != nil {
+			return nil, err
+		}
+		return &Workspace{Ignore: cfg.WorkspaceIgnore, ReadOnly: true}, nil
+	}
+	return NewWorkspace(dir, cfg.WorkspaceIgnore, true)
+}
+
+// serverOptions translates the configuration into Server options. The tools
+// of the connected upstreams are served along with the local ones.
+func (cfg *config) serverOptions(logger *slog.Logger, ups []*upstream) ([]Option, error) {
+	if err := checkUpstreams(ups); err != nil {
+		return nil, err
+	}
+	builtin := tools
+	if ws, err := cfg.workspace(cfg.ImageDir); err != nil {
+		return nil, fmt.Errorf("image directory: %w", err)
+	} else if ws != nil {
+		builtin = withImageWorkspace(builtin, ws)
+	}
+	if ws, err := cfg.workspace(cfg.CalendarDir); err != nil {
+		return nil, fmt.Errorf("calendar directory: %w", err)
+	} else if ws != nil {
+		builtin = withCalendarWorkspace(builtin, ws)
+	}
+	if ws, err := cfg.workspace(cfg.TextDir); err != nil {
+		return nil, fmt.Errorf("text directory: %w", err)
+	} else if ws != nil {
+		builtin = withTextWorkspace(builtin, ws)
+	}
+	if ws, err := cfg.workspace(cfg.SpreadsheetDir); err != nil {
+		return nil, fmt.Errorf("spreadsheet directory: %w", err)
+	} else if ws != nil {
+		builtin = withSpreadsheetWorkspace(builtin, ws)
+	}
+	if len(cfg.Formatters) > 0 {
+		builtin = withFormatters(builtin, cfg.Formatters)
+	}
+	sources := []toolSource{{name: "the built-in tools", tools: builtin}}
+	if cfg.MockTools != "" {
+		mocks, err := loadMockTools(cfg.MockTools)
+		if err != nil {
+			return nil, err
+		}
+		sources = []toolSource{{name: "the mock tools", tools: mocks}}
+	}
+	if cfg.ClipboardTools {
+		clipboard, err := clipboardTools()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the clipboard tools", tools: clipboard})
+	}
+	if len(cfg.GeoIPDatabases) > 0 {
+		geoip, err := newGeoIPTool(cfg.GeoIPDatabases)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the geoip tool", tools: []MCPTool{geoip}})
+	}
+	if cfg.GoModule != "" {
+		goTools, err := goModuleTools(cfg.GoModule)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the Go tools", tools: goTools})
+	}
+	if cfg.ReadWebpage {
+		sources = append(sources, toolSource{name: "the read_webpage tool", tools: []MCPTool{newReadWebpageTool()}})
+	}
+	if cfg.WhoisTool {
+		sources = append(sources, toolSource{name: "the whois tool", tools: []MCPTool{newWhoisTool()}})
+	}
+	if cfg.Email != nil {
+		sources = append(sources, toolSource{name: "the email tool", tools: []MCPTool{newSendEmailTool(*cfg.Email)}})
+	}
+	if len(cfg.Webhooks) > 0 {
+		sources = append(sources, toolSource{name: "the webhook tool", tools: []MCPTool{newNotifyWebhookTool(cfg.Webhooks)}})
+	}
+	if cfg.Currency != nil {
+		sources = append(sources, toolSource{name: "the currency tool", tools: []MCPTool{newConvertCurrencyTool(*cfg.Currency)}})
+	}
+	if cfg.SQL != nil {
+		sqlQuery, err := newSQLQueryTool(*cfg.SQL)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the sql_query tool", tools: []MCPTool{sqlQuery}})
+	}
+	if cfg.Redis != nil {
+		sources = append(sources, toolSource{name: "the redis tool", tools: []MCPTool{newRedisTool(*cfg.Redis)}})
+	}
+	if cfg.Kubernetes != nil {
+		kubectlGet, err := newKubectlGetTool(*cfg.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the kubectl_get tool", tools: []MCPTool{kubectlGet}})
+	}
+	if cfg.Docker != nil {
+		docker, err := newDockerTool(*cfg.Docker)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the docker tool", tools: []MCPTool{docker}})
+	}
+	if cfg.ObjectStorage != nil {
+		sources = append(sources, toolSource{name: "the object storage tools", tools: objectStorageTools(*cfg.ObjectStorage)})
+	}
+	if cfg.Search != nil {
+		ws, err := cfg.workspace(cfg.Search.Dir)
+		if err != nil {
+			return nil, fmt.Errorf("search directory: %w", err)
+		}
+		if ws == nil {
+			return nil, fmt.Errorf("search: dir is required without workspaceRoots")
+		}
+		search, err := searchTools(*cfg.Search, ws)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the search tools", tools: search})
+	}
+	if cfg.TailLog != nil {
+		sources = append(sources, toolSource{name: "the tail_log tool", tools: []MCPTool{newTailLogTool(*cfg.TailLog)}})
+	}
+	if cfg.HTTPMock != nil {
+		sources = append(sources, toolSource{name: "the HTTP mock tools", tools: httpMockTools(*cfg.HTTPMock)})
+	}
+	if cfg.Reminders != nil {
+		reminders, err := reminderTools(*cfg.Reminders, logger)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{name: "the reminder tools", tools: reminders})
+	}
+	for _, c := range cfg.CommandTools {
+		sources = append(sources, toolSource{
+			name:      fmt.Sprintf("command tool %q", c.Name),
+			namespace: c.Namespace,
+			tools:     []MCPTool{newCommandTool(c)},
+		})
+	}
+	for _, u := range ups {
+		sources = append(sources, toolSource{
+			name:      fmt.Sprintf("upstream %q", u.cfg.Name),
+			namespace: u.cfg.Namespace,
+			tools:     u.proxyTools(),
+		})
+	}
+	for _, o := range cfg.OpenAPI {
+		generated, err := loadOpenAPITools(o)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{
+			name:      fmt.Sprintf("OpenAPI spec %q", o.Name),
+			namespace: o.Namespace,
+			tools:     generated,
+		})
+	}
+	for _, g := range cfg.GRPC {
+		generated, err := loadGRPCTools(g)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, toolSource{
+			name:      fmt.Sprintf("gRPC server %q", g.Name),
+			namespace: g.Namespace,
+			tools:     generated,
+		})
+	}
+	if cfg.PluginsDir != "" {
+		loaded, err := loadPlugins(cfg.PluginsDir)
+		if err != nil {
+			return nil, err
+		}
+		wasm, err := loadWASMPlugins(cfg.PluginsDir)
+		if err != nil {
+			return nil, err
+		}
+		scripts, err := loadStarlarkPlugins(cfg.PluginsDir)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(append(loaded, wasm...), scripts...)
+		sources = append(sources, toolSource{name: "the plugins", namespace: cfg.PluginsNamespace, tools: loaded})
+	}
+	all, err := registerTools(sources, cfg.RenameTools)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range all {
+		if schema := t.InputSchema(); hasSchemaRef(schema) {
+			if _, err := resolveSchema(schema, cfg.SchemaDefs); err != nil {
+				return nil, fmt.Errorf("input schema of tool %q: %w", t.Name(), err)
+			}
+		}
+	}
+	selected, err := selectTools(all, cfg.Tools)
+	if err != nil {
+		return nil, err
+	}
+	opts := []Option{
+		WithTools(selected...),
+		WithToolFilter(cfg.AllowTools, cfg.DenyTools),
+		WithLogger(logger),
+		WithDrainTimeout(time.Duration(cfg.DrainTimeout)),
+		WithRequestTimeout(time.Duration(cfg.RequestTimeout)),
+		WithMaxWorkers(cfg.Workers),
+		WithKeepalive(time.Duration(cfg.Keepalive)),
+		WithMaxMessageSize(cfg.MaxMessageSize),
+		WithMaxResultSize(cfg.MaxResultSize),
+		WithUpstreams(ups...),
+		WithInstructions(cfg.Instructions),
+		WithServerInfo(cfg.ServerName, cfg.ServerVersion, cfg.ServerTitle),
+		WithSchemaDefs(cfg.SchemaDefs),
+		WithArgumentCoercion(cfg.CoerceArguments...),
+		WithDiagnostics(cfg.Diagnostics),
+	}
+	if cfg.ResourcesDir != "" {
+		files, err := newFileResources(cfg.ResourcesDir, cfg.WorkspaceIgnore)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithResourceDir(files), WithResourceWatch(time.Duration(cfg.ResourcesPoll)))
+	}
+	if cfg.PromptsDir != "" {
+		library, err := newPromptLibrary(cfg.PromptsDir, logger)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithPromptDir(library))
+	}
+	if cfg.Queue > 0 {
+		opts = append(opts, WithPriorityQueue(cfg.Queue))
+	}
+	if cfg.StatusTool {
+		opts = append(opts, WithStatusTool())
+	}
+	if cfg.BatchCallTool {
+		opts = append(opts, WithBatchCallTool())
+	}
+	if cfg.RequestLog {
+		opts = append(opts, WithRequestLog())
+	}
+	if cfg.ReadOnly {
+		opts = append(opts, WithReadOnly())
+	}
+	if cfg.ConfirmDestructive {
+		opts = append(opts, WithConfirmDestructive())
+	}
+	if cfg.ResultMeta {
+		opts = append(opts, WithResultMeta())
+	}
+	if cfg.RateLimit != "" {
+		limit, err := parseRateLimit(cfg.RateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit: %w", err)
+		}
+		opts = append(opts, WithSessionRateLimit(limit))
+	}
+	if cfg.SessionBudget != "" {
+		budget, err := parseBudget(cfg.SessionBudget)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithSessionBudget(budget))
+	}
+	limits, err := parseToolRateLimits(cfg.ToolRateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tool rate limits: %w", err)
+	}
+	for name, limit := range limits {
+		opts = append(opts, WithToolRateLimit(name, limit))
+	}
+	caches, err := parseToolCaches(cfg.ToolCache)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tool cache: %w", err)
+	}
+	for name, policy := range caches {
+		opts = append(opts, WithToolCache(name, policy))
+	}
+	return opts, nil
+}
+
+// selectTools returns the tools in all whose names are listed in names, in
+// the order of all. An empty names selects every tool.
+func selectTools(all []MCPTool, names []string) ([]MCPTool, error) {
+	if len(names) == 0 {
+		return all, nil
+	}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var selected []MCPTool
+	for _, t := range all {
+		if wanted[t.Name()] {
+			selected = append(selected, t)
+			delete(wanted, t.Name())
+		}
+	}
+	if len(wanted) > 0 {
+		return nil, fmt.Errorf("unknown tools: %s", strings.Join(sortedKeys(wanted), ", "))
+	}
+	return selected, nil
+}
+
+// parseLogLevel parses one of debug, info, warn, or error.
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+// stringList is a list of strings given as a comma-separated flag or a JSON
+// array.
+type stringList []string
+
+// String implements flag.Value.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set implements flag.Value.
+func (l *stringList) Set(s string) error {
+	*l = nil
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// duration is a time.Duration given as a flag or a JSON string such as "30s".
+type duration time.Duration
+
+// String implements flag.Value.
+func (d *duration) String() string {
+	return time.Duration(*d).String()
+}
+
+// Set implements flag.Value.
+func (d *duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+	return d.Set(s)
+}
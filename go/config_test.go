@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that the config file overrides defaults and flags override the file
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	data := `{"transport":"http","workers":4,"requestTimeout":"5s","tools":["echo","qr_code"]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig([]string{"--config", path, "--workers", "8"}, io.Discard)
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if cfg.Transport != "http" {
+		t.Errorf("expected transport from the file, got %q", cfg.Transport)
+	}
+	if cfg.Workers != 8 {
+		t.Errorf("expected the flag to override the file, got workers=%d", cfg.Workers)
+	}
+	if time.Duration(cfg.RequestTimeout) != 5*time.Second {
+		t.Errorf("expected requestTimeout=5s, got %v", time.Duration(cfg.RequestTimeout))
+	}
+	if len(cfg.Tools) != 2 || cfg.Tools[1] != "qr_code" {
+		t.Errorf("expected tools from the file, got %v", cfg.Tools)
+	}
+	if cfg.MaxMessageSize != defaultMaxMessageSize {
+		t.Errorf("expected the default max message size, got %d", cfg.MaxMessageSize)
+	}
+}
+
+// Test that invalid settings are rejected
+func TestLoadConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"workerz":4}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"--transport", "carrier-pigeon"},
+		{"--log-level", "loud"},
+		{"--deny-tools", "[qr"},
+		{"--repl", "--transport", "http"},
+		{"--transport", "stdio,stdio"},
+		{"--pprof", "--pprof-token", "t"},
+		{"--pprof", "--transport", "http"},
+		{"--rest-gateway"},
+		{"--pid-file", "server.pid"},
+		{"--daemon"},
+		{"--daemon", "--transport", "http", "--detach", "--pid-file", "server.pid"},
+		{"--daemon", "--transport", "http", "--log-file", "server.log", "--log-max-size", "-1"},
+		{"--config", path},
+		{"extra"},
+	} {
+		if _, err := loadConfig(args, io.Discard); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
+
+// Test that --tools restricts the served tools
+func TestSelectTools(t *testing.T) {
+	selected, err := selectTools(tools, []string{"qr_code", "echo"})
+	if err != nil {
+		t.Fatalf("selectTools error: %v", err)
+	}
+	if len(selected) != 2 || selected[0].Name() != "echo" || selected[1].Name() != "qr_code" {
+		t.Errorf("unexpected selection %v", selected)
+	}
+	if _, err := selectTools(tools, []string{"nope"}); err == nil {
+		t.Error("expected an error for an unknown tool")
+	}
+}
+
+// Test that MCP_* environment variables override flags and the config file
+func TestLoadConfigEnv(t *testing.T) {
+	t.Setenv("MCP_LOG_LEVEL", "debug")
+	t.Setenv("MCP_REQUEST_TIMEOUT", "2s")
+	t.Setenv("MCP_ALLOWED_TOOLS", "echo")
+
+	cfg, err := loadConfig([]string{"--log-level", "warn"}, io.Discard)
+	if err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("expected MCP_LOG_LEVEL to override the flag, got %q", cfg.LogLevel)
+	}
+	if time.Duration(cfg.RequestTimeout) != 2*time.Second {
+		t.Errorf("expected requestTimeout=2s, got %v", time.Duration(cfg.RequestTimeout))
+	}
+	if len(cfg.AllowTools) != 1 || cfg.AllowTools[0] != "echo" {
+		t.Errorf("expected MCP_ALLOWED_TOOLS to set the allowlist, got %v", cfg.AllowTools)
+	}
+
+	t.Setenv("MCP_ALLOW_TOOLS", "qr_*")
+	if cfg, err = loadConfig(nil, io.Discard); err != nil {
+		t.Fatalf("loadConfig error: %v", err)
+	}
+	if len(cfg.AllowTools) != 1 || cfg.AllowTools[0] != "qr_*" {
+		t.Errorf("expected MCP_ALLOW_TOOLS to take precedence over its alias, got %v", cfg.AllowTools)
+	}
+
+	t.Setenv("MCP_WORKERS", "many")
+	if _, err := loadConfig(nil, io.Discard); err == nil || !strings.Contains(err.Error(), "MCP_WORKERS") {
+		t.Errorf("expected an error naming MCP_WORKERS, got %v", err)
+	}
+}
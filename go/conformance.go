@@ -0,0 +1,654 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// conformanceVersions are the protocol versions the conformance suite
+// checks by default.
+var conformanceVersions = []string{"2024-11-05", "2025-03-26", "2025-06-18"}
+
+// conformanceUsage describes the conformance subcommand.
+const conformanceUsage = `Usage: mcp-minimal-server conformance [flags] [COMMAND [ARGS...]]
+
+Checks an MCP server against the protocol specification and prints a
+report. The server is started as COMMAND speaking stdio, reached at --url
+over Streamable HTTP, or, with neither, this server with default settings.
+The exit status is 1 if a required check fails.
+
+`
+
+// conformanceTarget carries raw messages to the server under test. Whatever
+// the server sends back is written to the lineCollector the target was
+// created with.
+type conformanceTarget interface {
+	send(ctx context.Context, msg []byte) error
+	close() error
+}
+
+// conformanceSession is a connection to the server under test, used by the
+// checks of one protocol version.
+type conformanceSession struct {
+	target   conformanceTarget
+	received *lineCollector
+	timeout  time.Duration
+	version  string                 // the requested protocol version
+	init     map[string]interface{} // the initialize result
+	nextID   int
+}
+
+// confResponse is a JSON-RPC response received from the server under test.
+type confResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+// errorCode returns the code of the error r carries. It fails if r is a
+// successful response.
+func (r *confResponse) errorCode() (int, error) {
+	if r.Error == nil {
+		return 0, fmt.Errorf("succeeded with %s", abbreviate(r.Result))
+	}
+	return r.Error.Code, nil
+}
+
+// isResponse reports whether line is a response, returning its ID.
+func isResponse(line []byte) (json.RawMessage, bool) {
+	var msg struct {
+		ID     json.RawMessage `json:"id"`
+		Method *string         `json:"method"`
+	}
+	if json.Unmarshal(line, &msg) != nil || msg.Method != nil || msg.ID == nil {
+		return nil, false
+	}
+	return msg.ID, true
+}
+
+// sameID reports whether two raw JSON IDs are equal.
+func sameID(a, b json.RawMessage) bool {
+	var ca, cb bytes.Buffer
+	return json.Compact(&ca, a) == nil && json.Compact(&cb, b) == nil && bytes.Equal(ca.Bytes(), cb.Bytes())
+}
+
+// exchange sends msg and waits for a response whose ID is one of ids.
+func (cs *conformanceSession) exchange(ctx context.Context, msg []byte, ids ...string) (*confResponse, error) {
+	from := len(cs.received.snapshot())
+	if err := cs.target.send(ctx, msg); err != nil {
+		return nil, err
+	}
+	i := cs.received.waitFor(ctx, from, cs.timeout, func(line []byte) bool {
+		id, ok := isResponse(line)
+		for _, want := range ids {
+			if ok && sameID(id, json.RawMessage(want)) {
+				return true
+			}
+		}
+		return false
+	})
+	if i < 0 {
+		return nil, fmt.Errorf("no response within %v", cs.timeout)
+	}
+	var resp confResponse
+	if err := json.Unmarshal(cs.received.snapshot()[i], &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// request sends a request for method and waits for its response.
+func (cs *conformanceSession) request(ctx context.Context, method string, params interface{}) (*confResponse, error) {
+	cs.nextID++
+	id := fmt.Sprintf(`"conformance-%d"`, cs.nextID)
+	req := map[string]interface{}{"jsonrpc": "2.0", "id": json.RawMessage(id), "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	msg, _ := json.Marshal(req)
+	return cs.exchange(ctx, msg, id)
+}
+
+// result sends a request and decodes its successful result into v.
+func (cs *conformanceSession) result(ctx context.Context, method string, params, v interface{}) error {
+	resp, err := cs.request(ctx, method, params)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("failed with %s (%d)", resp.Error.Message, resp.Error.Code)
+	}
+	if err := json.Unmarshal(resp.Result, v); err != nil {
+		return fmt.Errorf("invalid result: %v", err)
+	}
+	return nil
+}
+
+// expectNoResponse sends the notification msg and fails if the server
+// answers it. A request sent afterwards marks when to stop waiting.
+func (cs *conformanceSession) expectNoResponse(ctx context.Context, msg string) error {
+	from := len(cs.received.snapshot())
+	if err := cs.target.send(ctx, []byte(msg)); err != nil {
+		return err
+	}
+	marker, err := cs.request(ctx, "conformance/marker", nil)
+	if err != nil {
+		return fmt.Errorf("no response to the request following it: %v", err)
+	}
+	for _, line := range cs.received.snapshot()[from:] {
+		if id, ok := isResponse(line); ok && !sameID(id, marker.ID) {
+			return fmt.Errorf("answered with %s", abbreviate(line))
+		}
+	}
+	return nil
+}
+
+// abbreviate shortens a message for the report.
+func abbreviate(msg []byte) string {
+	const limit = 100
+	if len(msg) <= limit {
+		return string(msg)
+	}
+	return string(msg[:limit]) + "..."
+}
+
+// capability reports whether the server declared the named capability.
+func (cs *conformanceSession) capability(name string) bool {
+	caps, _ := cs.init["capabilities"].(map[string]interface{})
+	_, ok := caps[name]
+	return ok
+}
+
+// errSkipped is wrapped by the errors of checks that do not apply.
+var errSkipped = errors.New("skipped")
+
+// conformanceCheck is one check of the conformance suite.
+type conformanceCheck struct {
+	name     string
+	required bool     // a MUST of the specification; otherwise a SHOULD
+	versions []string // the protocol versions it applies to; all if empty
+	run      func(ctx context.Context, cs *conformanceSession) error
+}
+
+// conformanceChecks are run in order after a successful initialize.
+var conformanceChecks = []conformanceCheck{
+	{name: "initialize accepts the requested protocol version", run: func(ctx context.Context, cs *conformanceSession) error {
+		if v := cs.init["protocolVersion"]; v != cs.version {
+			return fmt.Errorf("server chose %v", v)
+		}
+		return nil
+	}},
+	{name: "the initialized notification gets no response", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		return cs.expectNoResponse(ctx, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	}},
+	{name: "ping returns an empty result", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		var result map[string]interface{}
+		if err := cs.result(ctx, "ping", nil, &result); err != nil {
+			return err
+		}
+		if len(result) != 0 {
+			return fmt.Errorf("returned %v", result)
+		}
+		return nil
+	}},
+	{name: "string request IDs are echoed unchanged", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		_, err := cs.exchange(ctx, []byte(`{"jsonrpc":"2.0","id":"conformance id","method":"ping"}`), `"conformance id"`)
+		return err
+	}},
+	{name: "integer request IDs are echoed unchanged", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		// 2^53+1 cannot be represented as a float64.
+		_, err := cs.exchange(ctx, []byte(`{"jsonrpc":"2.0","id":9007199254740993,"method":"ping"}`), `9007199254740993`)
+		return err
+	}},
+	{name: "unknown methods fail with -32601", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		resp, err := cs.request(ctx, "conformance/no-such-method", nil)
+		if err != nil {
+			return err
+		}
+		return expectCode(resp, -32601)
+	}},
+	{name: "malformed JSON fails with -32700 and a null ID", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		resp, err := cs.exchange(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":`), `null`)
+		if err != nil {
+			return err
+		}
+		return expectCode(resp, -32700)
+	}},
+	{name: "requests with the wrong JSON-RPC version fail with -32600", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		resp, err := cs.exchange(ctx, []byte(`{"jsonrpc":"1.0","id":"conformance-v1","method":"ping"}`), `"conformance-v1"`, `null`)
+		if err != nil {
+			return err
+		}
+		return expectCode(resp, -32600)
+	}},
+	{name: "unknown notifications get no response", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		return cs.expectNoResponse(ctx, `{"jsonrpc":"2.0","method":"notifications/conformance"}`)
+	}},
+	{name: "batches get an array of responses", required: true, versions: []string{"2025-03-26"}, run: checkBatch},
+	{name: "tools/list returns every tool across pages", required: true, run: listCheck("tools", "name")},
+	{name: "resources/list returns every resource across pages", required: true, run: listCheck("resources", "uri")},
+	{name: "prompts/list returns every prompt across pages", required: true, run: listCheck("prompts", "name")},
+	{name: "invalid cursors fail with -32602", run: func(ctx context.Context, cs *conformanceSession) error {
+		if !cs.capability("tools") {
+			return fmt.Errorf("%w: no tools capability", errSkipped)
+		}
+		resp, err := cs.request(ctx, "tools/list", map[string]string{"cursor": "conformance-invalid-cursor"})
+		if err != nil {
+			return err
+		}
+		return expectCode(resp, -32602)
+	}},
+	{name: "calling an unknown tool is an error", required: true, run: func(ctx context.Context, cs *conformanceSession) error {
+		if !cs.capability("tools") {
+			return fmt.Errorf("%w: no tools capability", errSkipped)
+		}
+		resp, err := cs.request(ctx, "tools/call", map[string]interface{}{"name": "conformance-no-such-tool", "arguments": map[string]interface{}{}})
+		if err != nil {
+			return err
+		}
+		var result struct {
+			IsError bool `json:"isError"`
+		}
+		if resp.Error == nil && (json.Unmarshal(resp.Result, &result) != nil || !result.IsError) {
+			return fmt.Errorf("succeeded with %s", abbreviate(resp.Result))
+		}
+		return nil
+	}},
+}
+
+// expectCode fails unless resp is an error with the given code.
+func expectCode(resp *confResponse, code int) error {
+	got, err := resp.errorCode()
+	if err != nil {
+		return err
+	}
+	if got != code {
+		return fmt.Errorf("failed with %d (%s)", got, resp.Error.Message)
+	}
+	return nil
+}
+
+// checkBatch sends a batch of two pings and expects both responses in one
+// array.
+func checkBatch(ctx context.Context, cs *conformanceSession) error {
+	from := len(cs.received.snapshot())
+	batch := `[{"jsonrpc":"2.0","id":"conformance-b1","method":"ping"},{"jsonrpc":"2.0","id":"conformance-b2","method":"ping"}]`
+	if err := cs.target.send(ctx, []byte(batch)); err != nil {
+		return err
+	}
+	i := cs.received.waitFor(ctx, from, cs.timeout, func(line []byte) bool {
+		id, ok := isResponse(line)
+		return bytes.HasPrefix(line, []byte("[")) || (ok && sameID(id, json.RawMessage("null")))
+	})
+	if i < 0 {
+		return fmt.Errorf("no response within %v", cs.timeout)
+	}
+	line := cs.received.snapshot()[i]
+	var responses []confResponse
+	if err := json.Unmarshal(line, &responses); err != nil {
+		return fmt.Errorf("answered with %s", abbreviate(line))
+	}
+	if len(responses) != 2 {
+		return fmt.Errorf("answered with %d responses", len(responses))
+	}
+	return nil
+}
+
+// listCheck returns a check that pages through the list method of the named
+// capability, requiring every item to have the key field, unique across
+// pages.
+func listCheck(capability, key string) func(context.Context, *conformanceSession) error {
+	return func(ctx context.Context, cs *conformanceSession) error {
+		if !cs.capability(capability) {
+			return fmt.Errorf("%w: no %s capability", errSkipped, capability)
+		}
+		seen := map[string]bool{}
+		var params map[string]string
+		for page := 1; ; page++ {
+			if page > 100 {
+				return errors.New("more than 100 pages")
+			}
+			var result map[string]json.RawMessage
+			if err := cs.result(ctx, capability+"/list", params, &result); err != nil {
+				return fmt.Errorf("page %d: %v", page, err)
+			}
+			var items []map[string]interface{}
+			if err := json.Unmarshal(result[capability], &items); err != nil || items == nil {
+				return fmt.Errorf("page %d: no %s array", page, capability)
+			}
+			for _, item := range items {
+				k, _ := item[key].(string)
+				if k == "" {
+					return fmt.Errorf("page %d: an item has no %s", page, key)
+				}
+				if seen[k] {
+					return fmt.Errorf("page %d: %q is listed twice", page, k)
+				}
+				seen[k] = true
+				if capability == "tools" {
+					if schema, _ := item["inputSchema"].(map[string]interface{}); schema["type"] != "object" {
+						return fmt.Errorf("tool %q: inputSchema is not an object schema", k)
+					}
+				}
+			}
+			var cursor string
+			if raw, ok := result["nextCursor"]; !ok || json.Unmarshal(raw, &cursor) != nil || cursor == "" {
+				return nil
+			}
+			params = map[string]string{"cursor": cursor}
+		}
+	}
+}
+
+// conformanceReport counts the outcomes of the checks.
+type conformanceReport struct {
+	passed, failed, warnings, skipped int
+}
+
+// print writes the outcome of one check to out.
+func (r *conformanceReport) print(out io.Writer, check conformanceCheck, err error) {
+	status := "PASS"
+	switch {
+	case err == nil:
+		r.passed++
+	case errors.Is(err, errSkipped):
+		status = "SKIP"
+		r.skipped++
+	case check.required:
+		status = "FAIL"
+		r.failed++
+	default:
+		status = "WARN"
+		r.warnings++
+	}
+	if err != nil {
+		fmt.Fprintf(out, "  %s  %s: %v\n", status, check.name, err)
+	} else {
+		fmt.Fprintf(out, "  %s  %s\n", status, check.name)
+	}
+}
+
+// initializeCheck performs the handshake that the other checks rely on.
+var initializeCheck = conformanceCheck{
+	name:     "initialize returns protocolVersion, capabilities, and serverInfo",
+	required: true,
+	run: func(ctx context.Context, cs *conformanceSession) error {
+		params := map[string]interface{}{
+			"protocolVersion": cs.version,
+			"capabilities":    map[string]interface{}{},
+			"clientInfo":      map[string]string{"name": serverName + "-conformance", "version": serverVersion},
+		}
+		if err := cs.result(ctx, "initialize", params, &cs.init); err != nil {
+			return err
+		}
+		if v, _ := cs.init["protocolVersion"].(string); v == "" {
+			return errors.New("no protocolVersion")
+		}
+		if _, ok := cs.init["capabilities"].(map[string]interface{}); !ok {
+			return errors.New("no capabilities object")
+		}
+		info, _ := cs.init["serverInfo"].(map[string]interface{})
+		if name, _ := info["name"].(string); name == "" {
+			return errors.New("no serverInfo name")
+		}
+		if t, ok := cs.target.(*httpConformanceTarget); ok {
+			t.protocolVersion, _ = cs.init["protocolVersion"].(string)
+		}
+		return nil
+	},
+}
+
+// runConformanceSuite runs every check for each version, connecting anew
+// through dial for each, and writes the report to out. It returns whether
+// every required check passed.
+func runConformanceSuite(ctx context.Context, dial func(*lineCollector) (conformanceTarget, error), versions []string, timeout time.Duration, out io.Writer) (bool, error) {
+	var report conformanceReport
+	for _, version := range versions {
+		fmt.Fprintf(out, "Protocol %s\n", version)
+		received := newLineCollector()
+		target, err := dial(received)
+		if err != nil {
+			return false, err
+		}
+		cs := &conformanceSession{target: target, received: received, timeout: timeout, version: version}
+		err = initializeCheck.run(ctx, cs)
+		report.print(out, initializeCheck, err)
+		for _, check := range conformanceChecks {
+			if !appliesTo(check, version) {
+				continue
+			}
+			if err != nil {
+				report.print(out, check, fmt.Errorf("%w: initialize failed", errSkipped))
+				continue
+			}
+			report.print(out, check, check.run(ctx, cs))
+		}
+		target.close()
+	}
+	fmt.Fprintf(out, "%d passed, %d failed, %d warnings, %d skipped\n", report.passed, report.failed, report.warnings, report.skipped)
+	return report.failed == 0, nil
+}
+
+// appliesTo reports whether check runs for the protocol version.
+func appliesTo(check conformanceCheck, version string) bool {
+	if len(check.versions) == 0 {
+		return true
+	}
+	for _, v := range check.versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// runConformance implements the conformance subcommand and returns the
+// process exit code.
+func runConformance(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	url := fs.String("url", "", "check the Streamable HTTP server at `URL`")
+	versions := stringList(conformanceVersions)
+	fs.Var(&versions, "protocol-versions", "comma-separated protocol `VERSIONS` to check (default all known)")
+	timeout := fs.Duration("timeout", 5*time.Second, "how long to wait for each response")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), conformanceUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	var dial func(*lineCollector) (conformanceTarget, error)
+	switch {
+	case *url != "" && fs.NArg() > 0:
+		fmt.Fprintln(os.Stderr, "conformance: give either --url or a command, not both")
+		return 2
+	case *url != "":
+		dial = func(received *lineCollector) (conformanceTarget, error) {
+			return &httpConformanceTarget{url: *url, client: http.DefaultClient, received: received}, nil
+		}
+	case fs.NArg() > 0:
+		dial = func(received *lineCollector) (conformanceTarget, error) {
+			return startStdioConformanceTarget(fs.Arg(0), fs.Args()[1:], received)
+		}
+	default:
+		server, _, done, code := newServerFromConfig(defaultConfig(), os.Stderr)
+		if code != 0 {
+			return code
+		}
+		defer done()
+		dial = func(received *lineCollector) (conformanceTarget, error) {
+			return newPipeConformanceTarget(server, received), nil
+		}
+	}
+
+	ok, err := runConformanceSuite(context.Background(), dial, versions, *timeout, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "conformance: %v\n", err)
+		return 1
+	}
+	if !ok {
+		return 1
+	}
+	return 0
+}
+
+// pipeConformanceTarget serves a Server in-process over a pipe.
+type pipeConformanceTarget struct {
+	w    *io.PipeWriter
+	done chan struct{}
+}
+
+// newPipeConformanceTarget starts serving s, writing its messages to
+// received.
+func newPipeConformanceTarget(s *Server, received *lineCollector) *pipeConformanceTarget {
+	r, w := io.Pipe()
+	t := &pipeConformanceTarget{w: w, done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		s.Serve(context.Background(), r, received)
+		r.Close()
+	}()
+	return t
+}
+
+func (t *pipeConformanceTarget) send(ctx context.Context, msg []byte) error {
+	_, err := t.w.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *pipeConformanceTarget) close() error {
+	t.w.Close()
+	<-t.done
+	return nil
+}
+
+// stdioConformanceTarget talks to a server subprocess over its standard
+// input and output.
+type stdioConformanceTarget struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	done  chan struct{} // closed once stdout is drained
+}
+
+// startStdioConformanceTarget starts command, writing its messages to
+// received.
+func startStdioConformanceTarget(command string, args []string, received *lineCollector) (*stdioConformanceTarget, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	t := &stdioConformanceTarget{cmd: cmd, stdin: stdin, done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		io.Copy(received, stdout)
+	}()
+	return t, nil
+}
+
+func (t *stdioConformanceTarget) send(ctx context.Context, msg []byte) error {
+	_, err := t.stdin.Write(append(msg, '\n'))
+	return err
+}
+
+// close closes the server's standard input and waits for it to exit,
+// killing it after a grace period.
+func (t *stdioConformanceTarget) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		t.cmd.Process.Kill()
+	}
+	return t.cmd.Wait()
+}
+
+// httpConformanceTarget posts each message to a Streamable HTTP endpoint.
+type httpConformanceTarget struct {
+	url             string
+	client          *http.Client
+	received        *lineCollector
+	sessionID       string
+	protocolVersion string // sent after initialization
+}
+
+func (t *httpConformanceTarget) send(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if t.sessionID != "" {
+		req.Header.Set(sessionIDHeader, t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if id := resp.Header.Get(sessionIDHeader); id != "" {
+		t.sessionID = id
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 64<<20)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data:"); ok {
+				fmt.Fprintf(t.received, "%s\n", strings.TrimSpace(data))
+			}
+		}
+		return scanner.Err()
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if body = bytes.TrimSpace(body); len(body) > 0 && (body[0] == '{' || body[0] == '[') {
+		fmt.Fprintf(t.received, "%s\n", body)
+	}
+	return nil
+}
+
+// close ends the HTTP session.
+func (t *httpConformanceTarget) close() error {
+	if t.sessionID == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(sessionIDHeader, t.sessionID)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
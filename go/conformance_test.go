@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// conformancePasses lists checks this server is expected to pass.
+var conformancePasses = []string{
+	"PASS  initialize returns protocolVersion, capabilities, and serverInfo",
+	"PASS  the initialized notification gets no response",
+	"PASS  integer request IDs are echoed unchanged",
+	"PASS  unknown methods fail with -32601",
+	"PASS  malformed JSON fails with -32700 and a null ID",
+	"PASS  requests with the wrong JSON-RPC version fail with -32600",
+	"PASS  unknown notifications get no response",
+	"PASS  tools/list returns every tool across pages",
+	"SKIP  prompts/list returns every prompt across pages",
+	"PASS  calling an unknown tool is an error",
+}
+
+// Test running the suite against this server in-process
+func TestConformanceInProcess(t *testing.T) {
+	s := NewServer(WithTools(&echoTool{}))
+	dial := func(received *lineCollector) (conformanceTarget, error) {
+		return newPipeConformanceTarget(s, received), nil
+	}
+	var report bytes.Buffer
+	ok, err := runConformanceSuite(context.Background(), dial, []string{"2025-03-26"}, time.Second, &report)
+	if err != nil || !ok {
+		t.Fatalf("expected the suite to pass, got %v, %v:\n%s", ok, err, report.String())
+	}
+	for _, want := range append(conformancePasses, "Protocol 2025-03-26\n", "batches get an array of responses") {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, report.String())
+		}
+	}
+}
+
+// Test running the suite over the HTTP transport
+func TestConformanceHTTP(t *testing.T) {
+	ts := httptest.NewServer(newHTTPTransport(NewServer(WithTools(&echoTool{})), nil))
+	defer ts.Close()
+	dial := func(received *lineCollector) (conformanceTarget, error) {
+		return &httpConformanceTarget{url: ts.URL, client: ts.Client(), received: received}, nil
+	}
+	var report bytes.Buffer
+	ok, err := runConformanceSuite(context.Background(), dial, []string{"2025-06-18"}, time.Second, &report)
+	if err != nil || !ok {
+		t.Fatalf("expected the suite to pass, got %v, %v:\n%s", ok, err, report.String())
+	}
+	for _, want := range conformancePasses {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, report.String())
+		}
+	}
+	if strings.Contains(report.String(), "batches") {
+		t.Errorf("the batch check ran for a version without batches:\n%s", report.String())
+	}
+}
+
+// Test that a server that never answers fails initialize and skips the rest
+func TestConformanceNoResponse(t *testing.T) {
+	dial := func(received *lineCollector) (conformanceTarget, error) {
+		return silentTarget{}, nil
+	}
+	var report bytes.Buffer
+	ok, err := runConformanceSuite(context.Background(), dial, []string{"2025-06-18"}, 10*time.Millisecond, &report)
+	if err != nil || ok {
+		t.Fatalf("expected a failed suite, got %v, %v", ok, err)
+	}
+	if !strings.Contains(report.String(), "FAIL  initialize returns protocolVersion, capabilities, and serverInfo: no response") ||
+		!strings.Contains(report.String(), "SKIP  ping returns an empty result: skipped: initialize failed") {
+		t.Errorf("unexpected report:\n%s", report.String())
+	}
+}
+
+// silentTarget is a conformanceTarget that never answers.
+type silentTarget struct{}
+
+func (silentTarget) send(ctx context.Context, msg []byte) error { return nil }
+func (silentTarget) close() error                               { return nil }
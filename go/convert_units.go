@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// unit is a unit convert_units knows, as a multiple of its dimension's base
+// unit plus, for temperatures, an offset: base = value*factor + offset.
+type unit struct {
+	symbol    string
+	dimension string
+	factor    float64
+	offset    float64
+	names     []string // accepted in any case, along with the symbol as is
+}
+
+// units are the units of convert_units. Decimal data prefixes are powers of
+// 1000 and binary ones of 1024; a year is a Julian year of 365.25 days.
+var units = []unit{
+	{"m", "length", 1, 0, []string{"meter", "meters", "metre", "metres"}},
+	{"km", "length", 1000, 0, []string{"kilometer", "kilometers", "kilometre", "kilometres"}},
+	{"cm", "length", 0.01, 0, []string{"centimeter", "centimeters", "centimetre", "centimetres"}},
+	{"mm", "length", 0.001, 0, []string{"millimeter", "millimeters", "millimetre", "millimetres"}},
+	{"µm", "length", 1e-6, 0, []string{"um", "micrometer", "micrometers", "micron", "microns"}},
+	{"nm", "length", 1e-9, 0, []string{"nanometer", "nanometers"}},
+	{"in", "length", 0.0254, 0, []string{"inch", "inches", "\""}},
+	{"ft", "length", 0.3048, 0, []string{"foot", "feet", "'"}},
+	{"yd", "length", 0.9144, 0, []string{"yard", "yards"}},
+	{"mi", "length", 1609.344, 0, []string{"mile", "miles"}},
+	{"nmi", "length", 1852, 0, []string{"nautical mile", "nautical miles"}},
+
+	{"kg", "mass", 1, 0, []string{"kilogram", "kilograms", "kilo", "kilos"}},
+	{"g", "mass", 0.001, 0, []string{"gram", "grams"}},
+	{"mg", "mass", 1e-6, 0, []string{"milligram", "milligrams"}},
+	{"µg", "mass", 1e-9, 0, []string{"ug", "microgram", "micrograms"}},
+	{"t", "mass", 1000, 0, []string{"tonne", "tonnes", "metric ton", "metric tons"}},
+	{"lb", "mass", 0.45359237, 0, []string{"lbs", "pound", "pounds"}},
+	{"oz", "mass", 0.028349523125, 0, []string{"ounce", "ounces"}},
+	{"st", "mass", 6.35029318, 0, []string{"stone", "stones"}},
+
+	{"K", "temperature", 1, 0, []string{"kelvin", "kelvins"}},
+	{"°C", "temperature", 1, 273.15, []string{"C", "celsius", "degc", "degrees celsius"}},
+	{"°F", "temperature", 5.0 / 9, 273.15 - 32*5.0/9, []string{"F", "fahrenheit", "degf", "degrees fahrenheit"}},
+
+	{"B", "data size", 1, 0, []string{"byte", "bytes"}},
+	{"bit", "data size", 0.125, 0, []string{"bits"}},
+	{"kB", "data size", 1e3, 0, []string{"KB", "kilobyte", "kilobytes"}},
+	{"MB", "data size", 1e6, 0, []string{"megabyte", "megabytes"}},
+	{"GB", "data size", 1e9, 0, []string{"gigabyte", "gigabytes"}},
+	{"TB", "data size", 1e12, 0, []string{"terabyte", "terabytes"}},
+	{"PB", "data size", 1e15, 0, []string{"petabyte", "petabytes"}},
+	{"KiB", "data size", 1 << 10, 0, []string{"kibibyte", "kibibytes"}},
+	{"MiB", "data size", 1 << 20, 0, []string{"mebibyte", "mebibytes"}},
+	{"GiB", "data size", 1 << 30, 0, []string{"gibibyte", "gibibytes"}},
+	{"TiB", "data size", 1 << 40, 0, []string{"tebibyte", "tebibytes"}},
+	{"PiB", "data size", 1 << 50, 0, []string{"pebibyte", "pebibytes"}},
+	{"kbit", "data size", 125, 0, []string{"kb", "kilobit", "kilobits"}},
+	{"Mbit", "data size", 125e3, 0, []string{"Mb", "megabit", "megabits"}},
+	{"Gbit", "data size", 125e6, 0, []string{"Gb", "gigabit", "gigabits"}},
+
+	{"ns", "time", 1e-9, 0, []string{"nanosecond", "nanoseconds"}},
+	{"µs", "time", 1e-6, 0, []string{"us", "microsecond", "microseconds"}},
+	{"ms", "time", 1e-3, 0, []string{"millisecond", "milliseconds"}},
+	{"s", "time", 1, 0, []string{"sec", "second", "seconds"}},
+	{"min", "time", 60, 0, []string{"minute", "minutes"}},
+	{"h", "time", 3600, 0, []string{"hr", "hour", "hours"}},
+	{"d", "time", 86400, 0, []string{"day", "days"}},
+	{"wk", "time", 7 * 86400, 0, []string{"week", "weeks"}},
+	{"yr", "time", 365.25 * 86400, 0, []string{"year", "years"}},
+}
+
+// unitsBySymbol and unitsByName index units for lookupUnit. Symbols are
+// case-sensitive, since MB and Mb differ; names are not.
+var unitsBySymbol, unitsByName = indexUnits()
+
+// indexUnits returns the indexes of units.
+func indexUnits() (bySymbol, byName map[string]*unit) {
+	bySymbol, byName = map[string]*unit{}, map[string]*unit{}
+	for i := range units {
+		u := &units[i]
+		bySymbol[u.symbol] = u
+		for _, n := range u.names {
+			if n[0] >= 'A' && n[0] <= 'Z' || len(n) <= 2 {
+				bySymbol[n] = u // an alternative symbol
+			} else {
+				byName[strings.ToLower(n)] = u
+			}
+		}
+	}
+	return bySymbol, byName
+}
+
+// lookupUnit returns the unit with the symbol or name s.
+func lookupUnit(s string) (*unit, bool) {
+	s = strings.TrimSpace(s)
+	if u, ok := unitsBySymbol[s]; ok {
+		return u, true
+	}
+	u, ok := unitsByName[strings.ToLower(s)]
+	return u, ok
+}
+
+// convertUnitsTool converts quantities between units of length, mass,
+// temperature, data size, and time.
+type convertUnitsTool struct{}
+
+// convertUnitsArgs are the arguments of the convert_units tool.
+type convertUnitsArgs struct {
+	Value     float64 `json:"value" description:"The quantity to convert"`
+	From      string  `json:"from" description:"The unit of the value, as a symbol such as km, °F, or MiB, or a name such as miles"`
+	To        string  `json:"to" description:"The unit to convert to"`
+	Precision int     `json:"precision,omitempty" minimum:"1" maximum:"15" description:"Significant digits of the result (default 6)"`
+}
+
+// Name returns the name of the convert_units tool.
+func (t *convertUnitsTool) Name() string {
+	return "convert_units"
+}
+
+// Description returns a brief description of the convert_units tool.
+func (t *convertUnitsTool) Description() string {
+	return "Converts a quantity between units of length, mass, temperature, data size (decimal and binary prefixes), and time"
+}
+
+// InputSchema returns the JSON schema for the convert_units tool's input
+// parameters.
+func (t *convertUnitsTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(convertUnitsArgs{})
+}
+
+// Annotations marks the convert_units tool as read-only.
+func (t *convertUnitsTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Convert units")
+}
+
+// Execute converts the value and returns the conversion as text, such as
+// "5 km = 3.10686 mi".
+func (t *convertUnitsTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	a := convertUnitsArgs{Precision: 6}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Precision < 1 || a.Precision > 15 {
+		return nil, toolFailure("invalid value for 'precision'")
+	}
+	if math.IsNaN(a.Value) || math.IsInf(a.Value, 0) {
+		return nil, toolFailure("invalid value for 'value'")
+	}
+	from, ok := lookupUnit(a.From)
+	if !ok {
+		return nil, toolFailure("invalid value for 'from': unknown unit %q; known units are %s", a.From, knownUnits())
+	}
+	to, ok := lookupUnit(a.To)
+	if !ok {
+		return nil, toolFailure("invalid value for 'to': unknown unit %q; known units are %s", a.To, knownUnits())
+	}
+	result, err := convertUnits(a.Value, from, to)
+	if err != nil {
+		return nil, err
+	}
+	text := fmt.Sprintf("%s %s = %s %s", formatQuantity(a.Value, 15), from.symbol, formatQuantity(result, a.Precision), to.symbol)
+	return []ToolContent{{Type: "text", Text: text}}, nil
+}
+
+// convertUnits converts value from one unit to another of the same
+// dimension.
+func convertUnits(value float64, from, to *unit) (float64, error) {
+	if from.dimension != to.dimension {
+		return 0, toolFailure("cannot convert %s (%s) to %s (%s)", from.dimension, from.symbol, to.dimension, to.symbol)
+	}
+	base := value*from.factor + from.offset
+	// Allow for rounding just below absolute zero, as from -459.67 °F.
+	if from.dimension == "temperature" && base < -1e-9 {
+		return 0, toolFailure("invalid value for 'value': %s %s is below absolute zero", formatQuantity(value, 15), from.symbol)
+	}
+	if from == to {
+		return value, nil
+	}
+	return (base - to.offset) / to.factor, nil
+}
+
+// formatQuantity rounds v to the given significant digits, to nearest with
+// ties to even on its binary value, and formats it without an exponent
+// unless it is very large or small. Trailing zeros are dropped.
+func formatQuantity(v float64, digits int) string {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'e', digits-1, 64), 64)
+	if rounded == 0 {
+		return "0"
+	}
+	if abs := math.Abs(rounded); abs >= 1e21 || abs < 1e-6 {
+		return strconv.FormatFloat(rounded, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(rounded, 'f', -1, 64)
+}
+
+// knownUnits lists the symbols of the units by dimension.
+func knownUnits() string {
+	byDimension := map[string][]string{}
+	for _, u := range units {
+		byDimension[u.dimension] = append(byDimension[u.dimension], u.symbol)
+	}
+	var dims []string
+	for d := range byDimension {
+		dims = append(dims, d)
+	}
+	sort.Strings(dims)
+	parts := make([]string, len(dims))
+	for i, d := range dims {
+		parts[i] = d + ": " + strings.Join(byDimension[d], ", ")
+	}
+	return strings.Join(parts, "; ")
+}
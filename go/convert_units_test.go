@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test conversions in each dimension and the rounding of results
+func TestConvertUnits(t *testing.T) {
+	tool := &convertUnitsTool{}
+	for _, tc := range []struct {
+		args     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"value": 5, "from": "km", "to": "mi"}, "5 km = 3.10686 mi"},
+		{map[string]interface{}{"value": 1, "from": "inch", "to": "mm"}, "1 in = 25.4 mm"},
+		{map[string]interface{}{"value": 6, "from": "Feet", "to": "m", "precision": 3}, "6 ft = 1.83 m"},
+		{map[string]interface{}{"value": 1, "from": "lb", "to": "g", "precision": 15}, "1 lb = 453.59237 g"},
+		{map[string]interface{}{"value": 212, "from": "°F", "to": "C"}, "212 °F = 100 °C"},
+		{map[string]interface{}{"value": -40, "from": "celsius", "to": "fahrenheit"}, "-40 °C = -40 °F"},
+		{map[string]interface{}{"value": 0, "from": "K", "to": "°C"}, "0 K = -273.15 °C"},
+		{map[string]interface{}{"value": 1, "from": "GiB", "to": "MB"}, "1 GiB = 1073.74 MB"},
+		{map[string]interface{}{"value": 100, "from": "Mb", "to": "MB"}, "100 Mbit = 12.5 MB"},
+		{map[string]interface{}{"value": 1, "from": "TiB", "to": "B", "precision": 15}, "1 TiB = 1099511627776 B"},
+		{map[string]interface{}{"value": 1.5, "from": "h", "to": "min"}, "1.5 h = 90 min"},
+		{map[string]interface{}{"value": 1, "from": "yr", "to": "d"}, "1 yr = 365.25 d"},
+		{map[string]interface{}{"value": 1, "from": "ns", "to": "yr"}, "1 ns = 3.16881e-17 yr"},
+		{map[string]interface{}{"value": 2.5, "from": "m", "to": "m"}, "2.5 m = 2.5 m"},
+		{map[string]interface{}{"value": 2, "from": "mi", "to": "km", "precision": 1}, "2 mi = 3 km"},
+	} {
+		content, err := tool.Execute(tc.args)
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		if content[0].Text != tc.expected {
+			t.Errorf("%v: expected %q, got %q", tc.args, tc.expected, content[0].Text)
+		}
+	}
+}
+
+// Test rejecting unknown units, mismatched dimensions and impossible values
+// with results flagged as errors that say what is wrong
+func TestConvertUnitsErrors(t *testing.T) {
+	tool := &convertUnitsTool{}
+	for _, tc := range []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"value": 1, "from": "parsec", "to": "m"}, `invalid value for 'from': unknown unit "parsec"; known units are `},
+		{map[string]interface{}{"value": 1, "from": "m", "to": "mb"}, `invalid value for 'to': unknown unit "mb"`},
+		{map[string]interface{}{"value": 1, "from": "kg", "to": "m"}, "cannot convert mass (kg) to length (m)"},
+		{map[string]interface{}{"value": 1, "from": "MB", "to": "s"}, "cannot convert data size (MB) to time (s)"},
+		{map[string]interface{}{"value": -1, "from": "K", "to": "°C"}, "invalid value for 'value': -1 K is below absolute zero"},
+		{map[string]interface{}{"value": -500, "from": "°F", "to": "K"}, "invalid value for 'value': -500 °F is below absolute zero"},
+		{map[string]interface{}{"value": 1, "from": "m", "to": "km", "precision": 16}, "invalid value for 'precision'"},
+	} {
+		_, err := tool.Execute(tc.args)
+		var failure *toolResultError
+		if !errors.As(err, &failure) || !strings.HasPrefix(failure.content[0].Text, tc.want) {
+			t.Errorf("%v: expected an error result %q, got %v", tc.args, tc.want, err)
+		}
+	}
+	if _, err := tool.Execute(map[string]interface{}{"value": "ten", "from": "m", "to": "km"}); err == nil {
+		t.Error("expected an error for a value that is not a number")
+	}
+	if _, err := tool.Execute(map[string]interface{}{"value": -459.67, "from": "°F", "to": "K"}); err != nil {
+		t.Errorf("expected absolute zero to convert, got %v", err)
+	}
+}
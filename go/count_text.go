@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// countTextTool reports character, word, line, and approximate token counts
+// for a piece of text so that agents can budget context before using it.
+type countTextTool struct{}
+
+// Name returns the name of the count_text tool.
+func (c *countTextTool) Name() string {
+	return "count_text"
+}
+
+// Description returns a brief description of the count_text tool.
+func (c *countTextTool) Description() string {
+	return "Counts characters, words, lines, and approximate tokens in the specified text"
+}
+
+// InputSchema returns the JSON schema for the count_text tool's input parameters.
+func (c *countTextTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"text": map[string]interface{}{
+				"type":        "string",
+				"description": "The text to count",
+			},
+		},
+		"required": []string{"text"},
+	}
+}
+
+// Annotations marks the count_text tool as read-only.
+func (c *countTextTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Count text")
+}
+
+// Execute counts the given text and returns the counts as a text block.
+func (c *countTextTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	text, ok := args["text"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for 'text'")
+	}
+	counts := countText(text)
+	content := ToolContent{
+		Type: "text",
+		Text: fmt.Sprintf("characters: %d\nwords: %d\nlines: %d\ntokens: %d",
+			counts.Characters, counts.Words, counts.Lines, counts.Tokens),
+	}
+	return []ToolContent{content}, nil
+}
+
+// textCounts holds the result of countText.
+type textCounts struct {
+	Characters int
+	Words      int
+	Lines      int
+	Tokens     int
+}
+
+// countText computes the counts for text. Characters are Unicode code points,
+// words are runs of non-space characters, and a trailing newline does not
+// start a new line.
+func countText(text string) textCounts {
+	counts := textCounts{
+		Characters: utf8.RuneCountInString(text),
+		Words:      len(strings.Fields(text)),
+		Tokens:     approximateTokens(text),
+	}
+	if text != "" {
+		counts.Lines = strings.Count(text, "\n")
+		if !strings.HasSuffix(text, "\n") {
+			counts.Lines++
+		}
+	}
+	return counts
+}
+
+// approximateTokens estimates the number of tokens a cl100k-style BPE
+// tokenizer would produce for text. Runs of ASCII letters cost one token per
+// five characters, runs of digits one token per three digits, each
+// punctuation or symbol character costs one token, and other scripts (for
+// example CJK) cost one token per character. Whitespace is folded into the
+// token that follows it, except for newlines which are tokenized on their own.
+func approximateTokens(text string) int {
+	tokens := 0
+	letters, digits := 0, 0
+	flush := func() {
+		tokens += (letters+4)/5 + (digits+2)/3
+		letters, digits = 0, 0
+	}
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			if digits > 0 {
+				flush()
+			}
+			letters++
+		case r < utf8.RuneSelf && unicode.IsDigit(r):
+			if letters > 0 {
+				flush()
+			}
+			digits++
+		case r == '\n':
+			flush()
+			tokens++
+		case unicode.IsSpace(r):
+			flush()
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Test countText against a few representative inputs
+func TestCountText(t *testing.T) {
+	cases := []struct {
+		text string
+		want textCounts
+	}{
+		{"", textCounts{}},
+		{"hello world", textCounts{Characters: 11, Words: 2, Lines: 1, Tokens: 2}},
+		{"tokenization 12345", textCounts{Characters: 18, Words: 2, Lines: 1, Tokens: 5}},
+		{"one\ntwo\n", textCounts{Characters: 8, Words: 2, Lines: 2, Tokens: 4}},
+		{"Hi, you!", textCounts{Characters: 8, Words: 2, Lines: 1, Tokens: 4}},
+		{"日本語", textCounts{Characters: 3, Words: 1, Lines: 1, Tokens: 3}},
+	}
+	for _, c := range cases {
+		if got := countText(c.text); got != c.want {
+			t.Errorf("countText(%q) = %+v, want %+v", c.text, got, c.want)
+		}
+	}
+}
+
+// Test calling the "count_text" tool through tools/call
+func TestToolsCallCountText(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"count_text","arguments":{"text":"hello world"}},"id":1}`
+	lines := runTestInput(t, input)
+
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line output, got %d lines", len(lines))
+	}
+
+	var resp struct {
+		Result struct {
+			Content []ToolContent `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(resp.Result.Content) != 1 {
+		t.Fatalf("expected 1 content item, got %d", len(resp.Result.Content))
+	}
+	want := "characters: 11\nwords: 2\nlines: 1\ntokens: 2"
+	if resp.Result.Content[0].Text != want {
+		t.Errorf("expected text %q, got %q", want, resp.Result.Content[0].Text)
+	}
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// currencyConfig configures the convert_currency tool in the "currency"
+// section of the config file. Rates come from exactly one of a file and an
+// HTTP provider, both in the same JSON form:
+//
+//	{"base": "EUR", "date": "2025-03-10", "rates": {"USD": 1.0857, "JPY": 160.1}}
+//
+// A Unix "timestamp" may stand in for the date; without either, the rates
+// date from when the file changed or was fetched.
+type currencyConfig struct {
+	RatesFile string   `json:"ratesFile"` // reread when it changes
+	URL       string   `json:"url"`       // $VAR expands from the environment, e.g. for an API key
+	CacheTTL  duration `json:"cacheTTL"`  // how long fetched rates are used; defaults to 1h
+	MaxAge    duration `json:"maxAge"`    // older rates come with a warning; defaults to 48h
+}
+
+// validate reports missing or malformed fields.
+func (c *currencyConfig) validate() error {
+	if (c.RatesFile == "") == (c.URL == "") {
+		return errors.New("currency: exactly one of ratesFile and url is required")
+	}
+	if c.URL != "" && !strings.Contains(c.URL, "$") {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("currency: url must be an http(s) URL")
+		}
+	}
+	if c.CacheTTL < 0 || c.MaxAge < 0 {
+		return errors.New("currency: cacheTTL and maxAge must not be negative")
+	}
+	return nil
+}
+
+// Limits of convert_currency.
+const (
+	currencyFetchTimeout = 10 * time.Second
+	maxRatesSize         = 1 << 20
+)
+
+// currencyRates are exchange rates relative to a base currency.
+type currencyRates struct {
+	Base      string             `json:"base"`
+	Date      string             `json:"date"`
+	Timestamp int64              `json:"timestamp"`
+	Rates     map[string]float64 `json:"rates"`
+
+	asOf time.Time // from Date or Timestamp, else when they were read
+}
+
+// convertCurrencyTool converts amounts using the configured rates, keeping
+// the last rates it read to use until they expire, or, with a warning, when
+// reading newer ones fails.
+type convertCurrencyTool struct {
+	cfg    currencyConfig
+	client *http.Client
+	now    func() time.Time // replaced in tests
+
+	mu       sync.Mutex
+	rates    *currencyRates
+	readAt   time.Time // when rates were fetched
+	modTime  time.Time // of the rates file when it was read
+	readFail error     // why rereading them last failed
+}
+
+// newConvertCurrencyTool returns the convert_currency tool for cfg.
+func newConvertCurrencyTool(cfg currencyConfig) *convertCurrencyTool {
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = duration(time.Hour)
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = duration(48 * time.Hour)
+	}
+	return &convertCurrencyTool{cfg: cfg, client: &http.Client{Timeout: currencyFetchTimeout}, now: time.Now}
+}
+
+// convertCurrencyArgs are the arguments of the convert_currency tool.
+type convertCurrencyArgs struct {
+	Amount   float64 `json:"amount" description:"The amount to convert"`
+	From     string  `json:"from" description:"ISO 4217 code of the amount's currency, such as USD"`
+	To       string  `json:"to" description:"ISO 4217 code of the currency to convert to"`
+	Decimals int     `json:"decimals,omitempty" minimum:"0" maximum:"8" description:"Decimal places of the result (default 2)"`
+}
+
+// Name returns the name of the convert_currency tool.
+func (t *convertCurrencyTool) Name() string {
+	return "convert_currency"
+}
+
+// Description returns a brief description of the convert_currency tool.
+func (t *convertCurrencyTool) Description() string {
+	return "Converts an amount between currencies at the configured exchange rates, reporting the rate and its date"
+}
+
+// InputSchema returns the JSON schema for the convert_currency tool's input
+// parameters.
+func (t *convertCurrencyTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(convertCurrencyArgs{})
+}
+
+// Annotations marks the convert_currency tool as read-only. With a rate
+// provider, it reaches outside the server.
+func (t *convertCurrencyTool) Annotations() ToolAnnotations {
+	a := ToolAnnotations{Title: "Convert currency", ReadOnlyHint: true}
+	if t.cfg.URL == "" {
+		closed := false
+		a.OpenWorldHint = &closed
+	}
+	return a
+}
+
+// Execute converts without a deadline of its own.
+func (t *convertCurrencyTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext converts the amount and returns the conversion as text,
+// followed by a warning if the rates are old.
+func (t *convertCurrencyTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	a := convertCurrencyArgs{Decimals: 2}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Decimals < 0 || a.Decimals > 8 {
+		return nil, fmt.Errorf("invalid value for 'decimals'")
+	}
+	if math.IsNaN(a.Amount) || math.IsInf(a.Amount, 0) {
+		return nil, fmt.Errorf("invalid value for 'amount'")
+	}
+	from, to := strings.ToUpper(strings.TrimSpace(a.From)), strings.ToUpper(strings.TrimSpace(a.To))
+	rates, err := t.currentRates(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("no exchange rates: %v", err)
+	}
+	fromRate, ok := rates.rate(from)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for 'from': no rate for %q; known currencies are %s", a.From, rates.currencies())
+	}
+	toRate, ok := rates.rate(to)
+	if !ok {
+		return nil, fmt.Errorf("invalid value for 'to': no rate for %q; known currencies are %s", a.To, rates.currencies())
+	}
+
+	rate := toRate / fromRate
+	text := fmt.Sprintf("%s %s = %s %s (rate %s, as of %s)",
+		strconv.FormatFloat(a.Amount, 'f', -1, 64), from,
+		strconv.FormatFloat(a.Amount*rate, 'f', a.Decimals, 64), to,
+		formatQuantity(rate, 6), rates.asOf.UTC().Format(time.DateOnly))
+	content := []ToolContent{{Type: "text", Text: text}}
+	t.mu.Lock()
+	readFail := t.readFail
+	t.mu.Unlock()
+	if age := t.now().Sub(rates.asOf); age > time.Duration(t.cfg.MaxAge) || readFail != nil {
+		warning := fmt.Sprintf("Warning: the exchange rates are %s old", roundAge(age))
+		if readFail != nil {
+			warning += fmt.Sprintf("; reading newer ones failed: %v", readFail)
+		}
+		content = append(content, ToolContent{Type: "text", Text: warning})
+	}
+	return content, nil
+}
+
+// roundAge formats an age in whole days, or hours below two days.
+func roundAge(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%d hours", int(d/time.Hour))
+}
+
+// rate returns the rate of the currency relative to the base.
+func (r *currencyRates) rate(currency string) (float64, bool) {
+	if currency == r.Base {
+		return 1, true
+	}
+	rate, ok := r.Rates[currency]
+	return rate, ok
+}
+
+// currencies lists the codes of the currencies with rates.
+func (r *currencyRates) currencies() string {
+	codes := []string{r.Base}
+	for c := range r.Rates {
+		if c != r.Base {
+			codes = append(codes, c)
+		}
+	}
+	sort.Strings(codes[1:])
+	return strings.Join(codes, ", ")
+}
+
+// currentRates returns the rates to convert with: those read before if
+// they are fresh, or else newly read ones. When reading fails, the old
+// rates are used if there are any.
+func (t *convertCurrencyTool) currentRates(ctx context.Context) (*currencyRates, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var rates *currencyRates
+	var err error
+	if t.cfg.RatesFile != "" {
+		rates, err = t.readRatesFile()
+	} else {
+		if t.rates != nil && t.now().Sub(t.readAt) < time.Duration(t.cfg.CacheTTL) {
+			return t.rates, nil
+		}
+		rates, err = t.fetchRates(ctx)
+	}
+	if err != nil {
+		t.readFail = err
+		if t.rates == nil || ctx.Err() != nil {
+			return nil, err
+		}
+		return t.rates, nil
+	}
+	t.rates, t.readFail = rates, nil
+	return rates, nil
+}
+
+// readRatesFile returns the rates in the rates file, rereading it only if
+// it changed.
+func (t *convertCurrencyTool) readRatesFile() (*currencyRates, error) {
+	info, err := os.Stat(t.cfg.RatesFile)
+	if err != nil {
+		return nil, err
+	}
+	if t.rates != nil && info.ModTime().Equal(t.modTime) {
+		return t.rates, nil
+	}
+	data, err := os.ReadFile(t.cfg.RatesFile)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := parseCurrencyRates(data, info.ModTime())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", t.cfg.RatesFile, err)
+	}
+	t.modTime = info.ModTime()
+	return rates, nil
+}
+
+// fetchRates fetches the rates from the provider. Errors never include its
+// URL, which may hold an API key.
+func (t *convertCurrencyTool) fetchRates(ctx context.Context) (*currencyRates, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, os.ExpandEnv(t.cfg.URL), nil)
+	if err != nil {
+		return nil, errors.New("invalid rate provider URL")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("rate provider: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rate provider: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRatesSize))
+	if err != nil {
+		return nil, fmt.Errorf("rate provider: %v", err)
+	}
+	now := t.now()
+	rates, err := parseCurrencyRates(data, now)
+	if err != nil {
+		return nil, fmt.Errorf("rate provider: %v", err)
+	}
+	t.readAt = now
+	return rates, nil
+}
+
+// parseCurrencyRates parses rates in the JSON form of currencyConfig,
+// dating them from readAt if they carry no date.
+func parseCurrencyRates(data []byte, readAt time.Time) (*currencyRates, error) {
+	var r currencyRates
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("invalid rates: %v", err)
+	}
+	r.Base = strings.ToUpper(r.Base)
+	if r.Base == "" || len(r.Rates) == 0 {
+		return nil, errors.New("invalid rates: base and rates are required")
+	}
+	rates := make(map[string]float64, len(r.Rates))
+	for c, v := range r.Rates {
+		if v <= 0 || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("invalid rates: the rate of %s is not positive", c)
+		}
+		rates[strings.ToUpper(c)] = v
+	}
+	r.Rates = rates
+	switch {
+	case r.Timestamp > 0:
+		r.asOf = time.Unix(r.Timestamp, 0)
+	case r.Date != "":
+		d, err := time.Parse(time.DateOnly, r.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rates: date %q is not like 2006-01-02", r.Date)
+		}
+		r.asOf = d
+	default:
+		r.asOf = readAt
+	}
+	return &r, nil
+}
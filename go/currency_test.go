@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testRates are rates relative to EUR.
+const testRates = `{"base": "EUR", "date": "2025-03-10", "rates": {"USD": 1.25, "jpy": 160, "GBP": 0.8}}`
+
+// Test converting with rates from a file, which is reread when it changes
+func TestConvertCurrencyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(testRates), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := newConvertCurrencyTool(currencyConfig{RatesFile: path})
+	tool.now = func() time.Time { return time.Date(2025, 3, 11, 12, 0, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		args     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"amount": 100, "from": "eur", "to": "USD"}, "100 EUR = 125.00 USD (rate 1.25, as of 2025-03-10)"},
+		{map[string]interface{}{"amount": 10, "from": "USD", "to": "GBP"}, "10 USD = 6.40 GBP (rate 0.64, as of 2025-03-10)"},
+		{map[string]interface{}{"amount": 1, "from": "GBP", "to": "JPY", "decimals": 0}, "1 GBP = 200 JPY (rate 200, as of 2025-03-10)"},
+	} {
+		content, err := tool.Execute(tc.args)
+		if err != nil || len(content) != 1 || content[0].Text != tc.expected {
+			t.Errorf("%v: expected %q, got %v, %v", tc.args, tc.expected, content, err)
+		}
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(path, []byte(strings.Replace(testRates, "1.25", "1.5", 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, later, later)
+	tool.now = func() time.Time { return time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC) }
+	content, err := tool.Execute(map[string]interface{}{"amount": 2, "from": "EUR", "to": "USD"})
+	if err != nil || content[0].Text != "2 EUR = 3.00 USD (rate 1.5, as of 2025-03-10)" {
+		t.Fatalf("expected the changed rates, got %v, %v", content, err)
+	}
+	if len(content) != 2 || content[1].Text != "Warning: the exchange rates are 10 days old" {
+		t.Errorf("expected a staleness warning, got %v", content)
+	}
+
+	os.Remove(path)
+	content, err = tool.Execute(map[string]interface{}{"amount": 2, "from": "EUR", "to": "USD"})
+	if err != nil || len(content) != 2 || !strings.Contains(content[1].Text, "reading newer ones failed") {
+		t.Errorf("expected the last rates with a warning, got %v, %v", content, err)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"amount": 1, "from": "EUR", "to": "XYZ"},
+		{"amount": 1, "from": "dollars", "to": "EUR"},
+		{"amount": 1, "from": "EUR", "to": "USD", "decimals": 9},
+	} {
+		if _, err := tool.Execute(args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+// Test fetching rates from a provider, caching them, and falling back to
+// them when the provider fails
+func TestConvertCurrencyProvider(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() || r.URL.Query().Get("key") != "secret" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"base": "USD", "timestamp": 1741608000, "rates": {"EUR": 0.8}}`))
+	}))
+	defer ts.Close()
+	t.Setenv("TEST_RATES_KEY", "secret")
+	tool := newConvertCurrencyTool(currencyConfig{URL: ts.URL + "/latest?key=$TEST_RATES_KEY", CacheTTL: duration(time.Hour)})
+	now := time.Unix(1741608000, 0)
+	tool.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		content, err := tool.Execute(map[string]interface{}{"amount": 5, "from": "USD", "to": "EUR"})
+		if err != nil || len(content) != 1 || content[0].Text != "5 USD = 4.00 EUR (rate 0.8, as of 2025-03-10)" {
+			t.Fatalf("unexpected result %v, %v", content, err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected the rates to be cached, got %d requests", n)
+	}
+
+	failing.Store(true)
+	now = now.Add(2 * time.Hour)
+	content, err := tool.Execute(map[string]interface{}{"amount": 5, "from": "EUR", "to": "USD"})
+	if err != nil || len(content) != 2 || !strings.Contains(content[1].Text, "503 Service Unavailable") {
+		t.Errorf("expected the cached rates with a warning, got %v, %v", content, err)
+	}
+	if strings.Contains(content[1].Text, "secret") {
+		t.Errorf("expected the URL to stay secret, got %q", content[1].Text)
+	}
+
+	var failure *toolResultError
+	fresh := newConvertCurrencyTool(currencyConfig{URL: ts.URL})
+	if _, err := fresh.Execute(map[string]interface{}{"amount": 1, "from": "USD", "to": "EUR"}); !errors.As(err, &failure) {
+		t.Errorf("expected an error result without any rates, got %v", err)
+	}
+}
+
+// Test validating the currency section of the config file
+func TestCurrencyConfig(t *testing.T) {
+	for _, c := range []currencyConfig{{RatesFile: "rates.json"}, {URL: "https://rates.example.com/latest"}, {URL: "$RATES_URL"}} {
+		if err := c.validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", c, err)
+		}
+	}
+	for _, c := range []currencyConfig{{}, {RatesFile: "a", URL: "https://rates.example.com"}, {URL: "ftp://rates.example.com"}, {RatesFile: "a", MaxAge: -1}} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+	for _, data := range []string{`{}`, `{"base": "EUR", "rates": {"USD": 0}}`, `{"base": "EUR", "date": "March", "rates": {"USD": 1}}`, `[]`} {
+		if _, err := parseCurrencyRates([]byte(data), time.Now()); err == nil {
+			t.Errorf("%s: expected an error", data)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server usually runs as a child of the host that spawned it, over
+// stdio. As a daemon (--daemon) it instead serves the http transport as
+// shared infrastructure, under a supervisor such as systemd, launchd, or
+// the Windows service manager (--service), or in the background on its own
+// (--detach):
+//
+//   - --pid-file records its process ID, and keeps a second daemon from
+//     starting while the recorded process runs.
+//   - --log-file takes its log, rotated at --log-max-size.
+//   - SIGHUP, or the service manager's paramchange control, restarts it
+//     cleanly: requests in flight are drained, the configuration is read
+//     again, and the server serves anew. An invalid configuration is
+//     reported and the previous one kept. The PID and log files stay those
+//     the daemon started with.
+
+// detachTimeout bounds how long --detach waits for the daemon to start.
+const detachTimeout = 10 * time.Second
+
+// validateDaemon checks the settings of daemon mode.
+func (cfg *config) validateDaemon() error {
+	switch {
+	case !cfg.Daemon && (cfg.Detach || cfg.Service || cfg.PIDFile != "" || cfg.LogFile != ""):
+		return fmt.Errorf("--detach, --service, --pid-file, and --log-file need --daemon")
+	case cfg.Daemon && cfg.serves("stdio"):
+		return fmt.Errorf("--daemon needs the http transport alone, having no client on its standard input")
+	case cfg.Detach && cfg.Service:
+		return fmt.Errorf("--detach cannot be used with --service")
+	case cfg.Service && !servicesSupported:
+		return fmt.Errorf("--service is only supported on Windows")
+	case (cfg.Detach || cfg.Service) && cfg.LogFile == "":
+		return fmt.Errorf("--detach and --service need a --log-file, having no standard error")
+	case cfg.Detach && cfg.PIDFile == "":
+		return fmt.Errorf("--detach needs a --pid-file")
+	case cfg.LogMaxSize < 0 || cfg.LogMaxBackups < 0:
+		return fmt.Errorf("--log-max-size and --log-max-backups cannot be negative")
+	}
+	return nil
+}
+
+// runDaemon runs the daemon described by cfg, the configuration loaded
+// from args, until ctx is done, restarting it on every value received from
+// restart, and returns the process exit code.
+func runDaemon(ctx context.Context, args []string, cfg *config, restart <-chan os.Signal) int {
+	var stderr io.Writer = os.Stderr
+	var log *rotatingLog
+	if cfg.LogFile != "" {
+		var err error
+		if log, err = openRotatingLog(cfg.LogFile, cfg.LogMaxSize, cfg.LogMaxBackups); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open log file: %v\n", err)
+			return 1
+		}
+		defer log.Close()
+		stderr = log
+	}
+	if cfg.PIDFile != "" {
+		pid, err := createPIDFile(cfg.PIDFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to create PID file: %v\n", err)
+			return 1
+		}
+		defer pid.remove()
+	}
+
+	for {
+		serveCtx, cancel := context.WithCancel(ctx)
+		restarted := make(chan bool, 1)
+		go func() {
+			select {
+			case <-restart:
+				cancel()
+				restarted <- true
+			case <-serveCtx.Done():
+				restarted <- false
+			}
+		}()
+		code := serveConfig(serveCtx, cfg, stderr)
+		cancel()
+		if !<-restarted || ctx.Err() != nil {
+			return code
+		}
+
+		if log != nil {
+			if err := log.reopen(); err != nil {
+				fmt.Fprintf(stderr, "Failed to reopen log file: %v\n", err)
+			}
+		}
+		next, err := loadConfig(args, io.Discard)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid configuration: %v; restarting with the previous one\n", err)
+		} else {
+			cfg = next
+		}
+		fmt.Fprintln(stderr, "Restarting")
+	}
+}
+
+// detach starts the daemon described by cfg as a background process, with
+// the same arguments but MCP_DETACH=false, and returns once it has written
+// its PID file, or reports that it exited first.
+func detach(cfg *config) int {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start the daemon: %v\n", err)
+		return 1
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start the daemon: %v\n", err)
+		return 1
+	}
+	defer null.Close()
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envName("detach")+"=") {
+			env = append(env, kv)
+		}
+	}
+	p, err := os.StartProcess(exe, append([]string{exe}, os.Args[1:]...), &os.ProcAttr{
+		Env:   append(env, envName("detach")+"=false"),
+		Files: []*os.File{null, null, null},
+		Sys:   detachAttr(),
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start the daemon: %v\n", err)
+		return 1
+	}
+
+	exited := make(chan *os.ProcessState, 1)
+	go func() {
+		state, _ := p.Wait()
+		exited <- state
+	}()
+	timeout := time.After(detachTimeout)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case state := <-exited:
+			fmt.Fprintf(os.Stderr, "The daemon exited on starting (%v); see %s\n", state, cfg.LogFile)
+			return 1
+		case <-timeout:
+			fmt.Fprintf(os.Stderr, "The daemon did not write %s within %v; see %s\n", cfg.PIDFile, detachTimeout, cfg.LogFile)
+			return 1
+		case <-tick.C:
+			if pid, err := readPIDFile(cfg.PIDFile); err == nil && pid == p.Pid {
+				fmt.Printf("Started the daemon as process %d\n", pid)
+				return 0
+			}
+		}
+	}
+}
+
+// pidFile is the file holding the process ID of the running daemon.
+type pidFile struct {
+	path string
+	pid  int
+}
+
+// createPIDFile writes the process ID to a new file at path. An existing
+// file is replaced if the process it records is no longer running, having
+// not exited cleanly.
+func createPIDFile(path string) (*pidFile, error) {
+	pid := os.Getpid()
+	for stale := false; ; stale = true {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n", pid)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return &pidFile{path: path, pid: pid}, nil
+		}
+		if !errors.Is(err, os.ErrExist) || stale {
+			return nil, err
+		}
+		if other, err := readPIDFile(path); err == nil && other != pid && processAlive(other) {
+			return nil, fmt.Errorf("already running as process %d, according to %s", other, path)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+}
+
+// remove removes the PID file, unless another process has replaced it.
+func (p *pidFile) remove() {
+	if pid, err := readPIDFile(p.path); err == nil && pid == p.pid {
+		os.Remove(p.path)
+	}
+}
+
+// readPIDFile returns the process ID recorded in the file at path.
+func readPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s does not hold a process ID", path)
+	}
+	return pid, nil
+}
+
+// rotatingLog is a log file that is rotated before it grows past maxSize
+// bytes: FILE is renamed FILE.1, FILE.1 FILE.2, and so on, keeping
+// maxBackups of them, and a new FILE is started.
+type rotatingLog struct {
+	path       string
+	maxSize    int64 // 0 for no limit
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// openRotatingLog opens the log file at path, appending to it.
+func openRotatingLog(path string, maxSize, maxBackups int) (*rotatingLog, error) {
+	l := &rotatingLog{path: path, maxSize: int64(maxSize), maxBackups: maxBackups}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// open opens the log file. The caller holds l.mu, or is its only user.
+func (l *rotatingLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, info.Size()
+	return nil
+}
+
+// Write appends p to the log, first rotating it if p would take it past
+// its limit.
+func (l *rotatingLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxSize > 0 && l.size > 0 && l.size+int64(len(p)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the log file and its backups along and starts a new file.
+// Should that fail, writes fail until the file is reopened. The caller
+// holds l.mu.
+func (l *rotatingLog) rotate() error {
+	l.f.Close()
+	backup := func(i int) string { return l.path + "." + strconv.Itoa(i) }
+	if l.maxBackups == 0 {
+		os.Remove(l.path)
+	} else {
+		os.Remove(backup(l.maxBackups))
+		for i := l.maxBackups - 1; i > 0; i-- {
+			os.Rename(backup(i), backup(i+1))
+		}
+		os.Rename(l.path, backup(1))
+	}
+	if err := l.open(); err != nil {
+		l.size = 0
+		return err
+	}
+	return nil
+}
+
+// reopen reopens the log file, so that one moved aside by another program
+// is started anew.
+func (l *rotatingLog) reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	old := l.f
+	if err := l.open(); err != nil {
+		return err
+	}
+	old.Close()
+	return nil
+}
+
+// Close closes the log file.
+func (l *rotatingLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
@@ -0,0 +1,33 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// servicesSupported reports whether this build can run as a service.
+const servicesSupported = false
+
+// runService reports that services are Windows's; elsewhere supervisors
+// such as systemd or launchd run the daemon in the foreground.
+func runService(args []string, cfg *config) int {
+	fmt.Fprintln(os.Stderr, "--service is only supported on Windows")
+	return 2
+}
+
+// processAlive reports whether the process pid is running. A process
+// that may not be signaled still runs.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// detachAttr starts the daemon in a session of its own, without the
+// terminal it was started from.
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
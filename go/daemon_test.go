@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Test that the PID file keeps a second daemon from starting, replaces
+// stale files, and is removed only while it is the daemon's
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.pid")
+	pid, err := createPIDFile(path)
+	if err != nil {
+		t.Fatalf("createPIDFile error: %v", err)
+	}
+	if got, err := readPIDFile(path); err != nil || got != os.Getpid() {
+		t.Errorf("expected the process ID, got %d, %v", got, err)
+	}
+
+	// The process recorded runs, but it is this one: a stale file from a
+	// process that had the same ID.
+	if _, err := createPIDFile(path); err != nil {
+		t.Errorf("expected our own ID to be taken for stale, got %v", err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createPIDFile(path); err == nil || !strings.Contains(err.Error(), "already running as process "+strconv.Itoa(os.Getppid())) {
+		t.Errorf("expected a running daemon to be reported, got %v", err)
+	}
+	pid.remove()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected another daemon's PID file to be left alone, got %v", err)
+	}
+
+	for _, stale := range []string{"1073741823\n", "garbage", ""} {
+		if err := os.WriteFile(path, []byte(stale), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		pid, err := createPIDFile(path)
+		if err != nil {
+			t.Errorf("%q: expected the stale file to be replaced, got %v", stale, err)
+			continue
+		}
+		pid.remove()
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%q: expected the PID file removed, got %v", stale, err)
+		}
+	}
+}
+
+// Test that the log is rotated before it grows past its limit, keeping
+// the newest backups
+func TestRotatingLog(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "server.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	log, err := openRotatingLog(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "a long line\n"} {
+		if _, err := log.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"server.log":   "a long line\n",
+		"server.log.1": "four\n",
+		"server.log.2": "two\nthree\n",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(data) != want {
+			t.Errorf("%s: expected %q, got %q, %v", name, want, data, err)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 3 {
+		t.Errorf("expected the oldest backup dropped, got %v", entries)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("expected the new log readable only by its owner, got %v, %v", info, err)
+	}
+
+	log, err = openRotatingLog(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Write([]byte("truncated\n"))
+	log.Close()
+	if data, _ := os.ReadFile(path); string(data) != "truncated\n" {
+		t.Errorf("expected the log started anew without backups, got %q", data)
+	}
+}
+
+// Test that the daemon keeps its PID and log files, restarts with the
+// configuration read again, keeps the previous one when it is invalid, and
+// cleans up on stopping
+func TestDaemonRestart(t *testing.T) {
+	dir := t.TempDir()
+	config, logFile, pidFile := filepath.Join(dir, "config.json"), filepath.Join(dir, "server.log"), filepath.Join(dir, "server.pid")
+	writeConfig := func(data string) {
+		if err := os.WriteFile(config, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logged := func(s string, n int) func() bool {
+		return func() bool {
+			data, _ := os.ReadFile(logFile)
+			return strings.Count(string(data), s) >= n
+		}
+	}
+	writeConfig(`{"transport":"http","addr":"127.0.0.1:0"}`)
+	args := []string{"--daemon", "--config", config, "--pid-file", pidFile, "--log-file", logFile}
+	cfg, err := loadConfig(args, os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	restart := make(chan os.Signal)
+	done := make(chan int)
+	go func() { done <- runDaemon(ctx, args, cfg, restart) }()
+	if !waitFor(t, logged("serving MCP over HTTP", 1)) {
+		t.Fatal("the daemon did not start")
+	}
+	if pid, err := readPIDFile(pidFile); err != nil || pid != os.Getpid() {
+		t.Errorf("expected the PID file, got %d, %v", pid, err)
+	}
+
+	writeConfig(`{"transport":"http","addr":"127.0.0.1:0","restGateway":true}`)
+	restart <- os.Interrupt
+	if !waitFor(t, logged("serving the tools over REST", 1)) {
+		t.Fatal("the daemon did not restart with the new configuration")
+	}
+	writeConfig(`{"transport":"http","workerz":1}`)
+	restart <- os.Interrupt
+	if !waitFor(t, logged("serving the tools over REST", 2)) {
+		t.Fatal("the daemon did not restart with the previous configuration")
+	}
+	if !logged("Invalid configuration", 1)() || !logged("Restarting", 2)() {
+		t.Error("expected the restarts and the invalid configuration logged")
+	}
+
+	cancel()
+	if code := <-done; code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if _, err := os.Stat(pidFile); !os.IsNotExist(err) {
+		t.Errorf("expected the PID file removed, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// servicesSupported reports whether this build can run as a service.
+const servicesSupported = true
+
+// The service is installed with the server's flags, for example
+//
+//	sc.exe create mcp binPath= "C:\mcp\mcp-minimal-server.exe --daemon --service --transport http --log-file C:\mcp\server.log"
+//
+// and is then started, stopped, and restarted in place with sc.exe start,
+// stop, and control mcp paramchange. The service manager starts it in
+// %SystemRoot%\System32, so paths are best given in full.
+
+var (
+	advapi32                          = syscall.NewLazyDLL("advapi32.dll")
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+)
+
+// Values of the service API, from winsvc.h.
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+	serviceControlParamChange = 6
+
+	serviceAcceptStop        = 1
+	serviceAcceptShutdown    = 4
+	serviceAcceptParamChange = 8
+
+	errorCallNotImplemented   = 120
+	errorServiceSpecificError = 1066
+
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+	detachedProcess                = 0x8
+)
+
+// serviceStatus is a SERVICE_STATUS.
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// serviceTableEntry is a SERVICE_TABLE_ENTRYW.
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+// windowsService is the daemon running as a Windows service. The service
+// manager calls serviceMain and serviceHandler on threads of its own,
+// which find it in runningService.
+type windowsService struct {
+	args    []string
+	cfg     *config
+	cancel  context.CancelFunc
+	restart chan os.Signal
+	code    int
+
+	mu         sync.Mutex
+	handle     uintptr
+	checkPoint uint32
+}
+
+var runningService *windowsService
+
+// runService runs the daemon described by cfg, the configuration loaded
+// from args, as the service the service manager started, and returns the
+// process exit code once it has stopped.
+func runService(args []string, cfg *config) int {
+	runningService = &windowsService{args: args, cfg: cfg, restart: make(chan os.Signal, 1)}
+	table := []serviceTableEntry{{name: new(uint16), proc: syscall.NewCallback(serviceMain)}, {}}
+	if r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+		fmt.Fprintf(os.Stderr, "Failed to run as a service, which the service manager must start: %v\n", err)
+		return 1
+	}
+	return runningService.code
+}
+
+// serviceMain is the ServiceMain of the service, which runs the daemon
+// until it is stopped. argv[0] is the name of the service.
+func serviceMain(argc uint32, argv **uint16) uintptr {
+	s := runningService
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.cancel = cancel
+	handle, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(*argv)), syscall.NewCallback(serviceHandler), 0)
+	if handle == 0 {
+		fmt.Fprintf(os.Stderr, "Failed to register the service's handler: %v\n", err)
+		s.code = 1
+		return 0
+	}
+	s.mu.Lock()
+	s.handle = handle
+	s.mu.Unlock()
+
+	s.setStatus(serviceStartPending, 0)
+	s.setStatus(serviceRunning, 0)
+	s.code = runDaemon(ctx, s.args, s.cfg, s.restart)
+	s.setStatus(serviceStopped, s.code)
+	return 0
+}
+
+// serviceHandler is the HandlerEx of the service, which stops the daemon
+// on the stop and shutdown controls and restarts it on paramchange.
+func serviceHandler(control, _, _, _ uintptr) uintptr {
+	s := runningService
+	switch control {
+	case serviceControlStop, serviceControlShutdown:
+		s.setStatus(serviceStopPending, 0)
+		s.cancel()
+	case serviceControlParamChange:
+		select {
+		case s.restart <- syscall.SIGHUP:
+		default:
+		}
+	case serviceControlInterrogate:
+	default:
+		return errorCallNotImplemented
+	}
+	return 0
+}
+
+// setStatus reports the service's state to the service manager, with
+// code, the daemon's exit code, once it has stopped.
+func (s *windowsService) setStatus(state uint32, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := serviceStatus{serviceType: serviceWin32OwnProcess, currentState: state}
+	switch state {
+	case serviceRunning:
+		status.controlsAccepted = serviceAcceptStop | serviceAcceptShutdown | serviceAcceptParamChange
+	case serviceStartPending, serviceStopPending:
+		s.checkPoint++
+		status.checkPoint = s.checkPoint
+		status.waitHint = uint32((time.Duration(s.cfg.DrainTimeout) + 5*time.Second).Milliseconds())
+	}
+	if code != 0 {
+		status.win32ExitCode = errorServiceSpecificError
+		status.serviceSpecificExitCode = uint32(code)
+	}
+	procSetServiceStatus.Call(s.handle, uintptr(unsafe.Pointer(&status)))
+}
+
+// processAlive reports whether the process pid is running. A process
+// that may not be inspected still runs.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
+
+// detachAttr starts the daemon without a console, in a process group of
+// its own so that the console's interrupts do not reach it.
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP, HideWindow: true}
+}
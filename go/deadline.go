@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requestTimeoutHeader carries how long an HTTP client waits for the
+// response to its request, as a duration such as "30s" or a number of
+// seconds. The request's context gets the corresponding deadline.
+const requestTimeoutHeader = "Mcp-Request-Timeout"
+
+// maxClientTimeout bounds the timeouts clients give, beyond which they
+// are ignored.
+const maxClientTimeout = 24 * time.Hour
+
+// parseRequestTimeout parses the value of requestTimeoutHeader.
+func parseRequestTimeout(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 || secs > float64(maxClientTimeout/time.Second) {
+			return 0, false
+		}
+		return time.Duration(secs * float64(time.Second)), true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 || d > maxClientTimeout {
+		return 0, false
+	}
+	return d, true
+}
+
+// metaDeadline returns the time at which the client gives up on a tool
+// call according to the timeoutMs (milliseconds from now) and deadline
+// (RFC 3339) hints in the _meta of its params, the earlier if both are
+// given, or the zero time if neither is. Malformed hints are ignored, as
+// they are only hints.
+func metaDeadline(timeoutMs, deadline json.RawMessage, now time.Time) time.Time {
+	var earliest time.Time
+	var ms float64
+	if json.Unmarshal(timeoutMs, &ms) == nil && ms > 0 && ms <= float64(maxClientTimeout/time.Millisecond) {
+		earliest = now.Add(time.Duration(ms * float64(time.Millisecond)))
+	}
+	var text string
+	if json.Unmarshal(deadline, &text) == nil {
+		if t, err := time.Parse(time.RFC3339Nano, text); err == nil && (earliest.IsZero() || t.Before(earliest)) {
+			earliest = t
+		}
+	}
+	return earliest
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test parsing the timeout hints of clients
+func TestClientTimeoutHints(t *testing.T) {
+	for v, want := range map[string]time.Duration{
+		"30":    30 * time.Second,
+		"1.5":   1500 * time.Millisecond,
+		"250ms": 250 * time.Millisecond,
+		"":      0,
+		"-1":    0,
+		"soon":  0,
+		"48h":   0,
+	} {
+		if d, ok := parseRequestTimeout(v); d != want || ok != (want > 0) {
+			t.Errorf("parseRequestTimeout(%q) = %v, %v, expected %v", v, d, ok, want)
+		}
+	}
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	raw := func(v interface{}) json.RawMessage {
+		encoded, _ := json.Marshal(v)
+		return encoded
+	}
+	for _, tc := range []struct {
+		timeoutMs, deadline json.RawMessage
+		want                time.Time
+	}{
+		{raw(1500), nil, now.Add(1500 * time.Millisecond)},
+		{nil, raw("2026-01-02T03:04:06Z"), now.Add(time.Second)},
+		{raw(5000), raw("2026-01-02T03:04:06Z"), now.Add(time.Second)},
+		{raw(500), raw("2026-01-02T03:04:06Z"), now.Add(500 * time.Millisecond)},
+		{raw("1500"), raw("tomorrow"), time.Time{}},
+		{raw(0), nil, time.Time{}},
+		{nil, nil, time.Time{}},
+	} {
+		if got := metaDeadline(tc.timeoutMs, tc.deadline, now); !got.Equal(tc.want) {
+			t.Errorf("metaDeadline(%s, %s) = %v, expected %v", tc.timeoutMs, tc.deadline, got, tc.want)
+		}
+	}
+}
+
+// slowMockTool waits an hour unless its context ends first.
+func slowMockTool() *mockTool {
+	return &mockTool{cfg: mockToolConfig{Name: "slow", Delay: duration(time.Hour), mockResponse: mockResponse{Response: "done"}}}
+}
+
+// Test that a tool call stops at the deadline the client gives in _meta
+func TestMetaDeadline(t *testing.T) {
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	lines := runServerInput(t, NewServer(WithTools(slowMockTool())), `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"slow","_meta":{"timeoutMs":20}},"id":1}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"slow","_meta":{"deadline":"`+past+`"}},"id":2}
+`)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses, got %q", lines)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"code":-32001,"message":"Request timed out at the client's deadline"`) {
+			t.Errorf("expected the call to time out at the client's deadline, got %s", line)
+		}
+	}
+}
+
+// Test that an HTTP request stops when the client's timeout header says
+// it stopped waiting
+func TestRequestTimeoutHeader(t *testing.T) {
+	tr := newHTTPTransport(NewServer(WithTools(slowMockTool())), nil)
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+	defer tr.endAll()
+	id := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`).Header.Get(sessionIDHeader)
+	postMCP(t, ts.URL, id, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"slow"},"id":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(sessionIDHeader, id)
+	req.Header.Set(requestTimeoutHeader, "20ms")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "the client's deadline") {
+		t.Errorf("expected the call to time out at the client's deadline, got %s", body)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction markers used in the traffic log.
+const (
+	trafficInbound  = "-->" // client to server
+	trafficOutbound = "<--" // server to client
+)
+
+// trafficLog records every JSON-RPC message passing through the wrapped
+// reader and writer, one message per line, prefixed with a timestamp and a
+// direction marker. Secrets are removed by the redactor, if any.
+type trafficLog struct {
+	mu       sync.Mutex
+	w        io.Writer
+	now      func() time.Time
+	redactor *redactor
+}
+
+// newTrafficLog returns a trafficLog that writes its records to w.
+func newTrafficLog(w io.Writer, r *redactor) *trafficLog {
+	return &trafficLog{w: w, now: time.Now, redactor: r}
+}
+
+// record writes a single message to the log.
+func (l *trafficLog) record(direction string, msg []byte) {
+	msg = bytes.TrimRight(msg, "\r")
+	if len(bytes.TrimSpace(msg)) == 0 {
+		return
+	}
+	msg = l.redactor.redactJSON(msg)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.w, "%s %s %s\n", l.now().UTC().Format(time.RFC3339Nano), direction, msg)
+}
+
+// reader returns a reader that records every line read from r as inbound.
+func (l *trafficLog) reader(r io.Reader) io.Reader {
+	return &trafficReader{r: r, lines: trafficLines{log: l, direction: trafficInbound}}
+}
+
+// writer returns a writer that records every line written to w as outbound.
+func (l *trafficLog) writer(w io.Writer) io.Writer {
+	return &trafficWriter{w: w, lines: trafficLines{log: l, direction: trafficOutbound}}
+}
+
+// trafficLines splits a byte stream into lines and records each complete one.
+type trafficLines struct {
+	log       *trafficLog
+	direction string
+	buf       []byte
+}
+
+// add appends p to the pending data and records every completed line.
+func (t *trafficLines) add(p []byte) {
+	t.buf = append(t.buf, p...)
+	for {
+		i := bytes.IndexByte(t.buf, '\n')
+		if i < 0 {
+			return
+		}
+		t.log.record(t.direction, t.buf[:i])
+		t.buf = t.buf[i+1:]
+	}
+}
+
+// flush records any trailing data that was not terminated by a newline.
+func (t *trafficLines) flush() {
+	if len(t.buf) > 0 {
+		t.log.record(t.direction, t.buf)
+		t.buf = nil
+	}
+}
+
+// trafficReader is the io.Reader returned by trafficLog.reader.
+type trafficReader struct {
+	r     io.Reader
+	lines trafficLines
+}
+
+// Read reads from the underlying reader and records what was read.
+func (t *trafficReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.lines.add(p[:n])
+	if err != nil {
+		t.lines.flush()
+	}
+	return n, err
+}
+
+// trafficWriter is the io.Writer returned by trafficLog.writer.
+type trafficWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	lines trafficLines
+}
+
+// Write records p and writes it to the underlying writer.
+func (t *trafficWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	t.lines.add(p)
+	t.mu.Unlock()
+	return t.w.Write(p)
+}
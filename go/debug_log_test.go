@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that inbound and outbound messages are recorded with direction markers
+func TestTrafficLog(t *testing.T) {
+	var logBuf, out bytes.Buffer
+	traffic := newTrafficLog(&logBuf, nil)
+	traffic.now = func() time.Time { return time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC) }
+
+	input := "{\"jsonrpc\":\"2.0\",\"method\":\"initialize\",\"id\":0}\n\n{\"jsonrpc\":\"2.0\",\"method\":\"notifications/initialized\"}\n{\"jsonrpc\":\"2.0\",\"method\":\"tools/list\",\"id\":1}"
+	if err := runMCPServer(traffic.reader(strings.NewReader(input)), traffic.writer(&out)); err != nil {
+		t.Fatalf("runMCPServer error: %v", err)
+	}
+
+	records := strings.Split(strings.TrimSpace(logBuf.String()), "\n")
+	if len(records) != 5 {
+		t.Fatalf("expected 5 log records, got %d: %q", len(records), logBuf.String())
+	}
+	wantPrefixes := []string{
+		"2025-03-08T12:00:00Z --> {\"jsonrpc\":\"2.0\",\"method\":\"initialize\"",
+		"2025-03-08T12:00:00Z <-- {\"id\":0,\"jsonrpc\":\"2.0\",",
+		"2025-03-08T12:00:00Z --> {\"jsonrpc\":\"2.0\",\"method\":\"notifications/initialized\"}",
+		"2025-03-08T12:00:00Z --> {\"jsonrpc\":\"2.0\",\"method\":\"tools/list\"",
+		"2025-03-08T12:00:00Z <-- {\"id\":1,\"jsonrpc\":\"2.0\",",
+	}
+	for _, want := range wantPrefixes {
+		found := false
+		for _, rec := range records {
+			if strings.HasPrefix(rec, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected a record starting with %q, got %q", want, records)
+		}
+	}
+}
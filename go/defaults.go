@@ -0,0 +1,51 @@
+package main
+
+import "encoding/json"
+
+// applyDefaults returns args with each optional property of schema that
+// args lacks set to the property's default, so that tools need not default
+// omitted arguments themselves. Required arguments are never filled in,
+// since a client omitting one is an error. args is modified in place, or
+// allocated if nil and a default applies.
+func applyDefaults(schema map[string]interface{}, args map[string]interface{}) map[string]interface{} {
+	properties, _ := schema["properties"].(map[string]interface{})
+	required := requiredProperties(schema)
+	for _, name := range sortedKeys(properties) {
+		prop, ok := properties[name].(map[string]interface{})
+		if !ok || required[name] {
+			continue
+		}
+		def, ok := prop["default"]
+		if !ok {
+			continue
+		}
+		if _, present := args[name]; present {
+			continue
+		}
+		if args == nil {
+			args = map[string]interface{}{}
+		}
+		args[name] = copyDefault(def)
+	}
+	return args
+}
+
+// copyDefault returns a copy of the default value v, as decoded from JSON,
+// so that a tool modifying its arguments cannot change the schema. Values
+// from schemas built in Go, such as []string, are normalized to their
+// decoded form on the way.
+func copyDefault(v interface{}) interface{} {
+	switch v.(type) {
+	case string, float64, bool, nil:
+		return v
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var copied interface{}
+	if json.Unmarshal(encoded, &copied) != nil {
+		return v
+	}
+	return copied
+}
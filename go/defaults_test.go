@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test that omitted optional arguments get their defaults
+func TestApplyDefaults(t *testing.T) {
+	schema := decodeSchema(t, `{"type":"object","properties":{
+		"format": {"type": "string", "default": "png"},
+		"size": {"type": "integer", "default": 256},
+		"tags": {"type": "array", "default": ["a"]},
+		"name": {"type": "string", "default": "unused"},
+		"note": {"type": "string"}
+	},"required":["name"]}`)
+	args := applyDefaults(schema, map[string]interface{}{"size": float64(64)})
+	want := map[string]interface{}{"format": "png", "size": float64(64), "tags": []interface{}{"a"}}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("applyDefaults = %v, expected %v", args, want)
+	}
+	args["tags"].([]interface{})[0] = "changed"
+	if again := applyDefaults(schema, nil); again["tags"].([]interface{})[0] != "a" {
+		t.Errorf("expected the default to be copied, got %v", again)
+	}
+
+	goSchema := map[string]interface{}{"properties": map[string]interface{}{
+		"levels": map[string]interface{}{"default": []int{1, 2}},
+	}}
+	if args := applyDefaults(goSchema, nil); !reflect.DeepEqual(args["levels"], []interface{}{float64(1), float64(2)}) {
+		t.Errorf("expected a Go default in its decoded form, got %#v", args["levels"])
+	}
+	if args := applyDefaults(map[string]interface{}{"type": "object"}, nil); args != nil {
+		t.Errorf("expected no arguments without defaults, got %v", args)
+	}
+}
+
+// Test that tools are called with the defaults filled in and that the
+// defaults are listed
+func TestToolDefaults(t *testing.T) {
+	tool := &mockTool{cfg: mockToolConfig{
+		Name:         "render",
+		InputSchema:  decodeSchema(t, `{"type":"object","properties":{"format":{"type":"string","default":"svg"}}}`),
+		mockResponse: mockResponse{Response: "rendered as {{format}}"},
+	}}
+	lines := runServerInput(t, NewServer(WithTools(tool)), `{"jsonrpc":"2.0","method":"tools/list","id":1}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"render"},"id":2}
+`)
+	if len(lines) != 2 || !strings.Contains(lines[0], `"default":"svg"`) || !strings.Contains(lines[1], "rendered as svg") {
+		t.Errorf("expected the default to be listed and applied, got %q", lines)
+	}
+}
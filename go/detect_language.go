@@ -0,0 +1,283 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// languageSamples holds a sample text of each language detect_language
+// tells apart by n-grams, named by its ISO 639-1 code. The model is built
+// from them when the tool is first called.
+//
+//go:embed language_samples/*.txt
+var languageSamples embed.FS
+
+// languageNames are the English names of the languages detect_language
+// reports.
+var languageNames = map[string]string{
+	"ar": "Arabic", "de": "German", "el": "Greek", "en": "English", "es": "Spanish",
+	"fi": "Finnish", "fr": "French", "he": "Hebrew", "hi": "Hindi", "id": "Indonesian",
+	"it": "Italian", "ja": "Japanese", "ko": "Korean", "nl": "Dutch", "pl": "Polish",
+	"pt": "Portuguese", "ru": "Russian", "sv": "Swedish", "th": "Thai", "tr": "Turkish",
+	"uk": "Ukrainian", "zh": "Chinese",
+}
+
+// scriptLanguages maps scripts written by a single language, among those
+// detect_language knows, to that language. Text in the other scripts is
+// told apart by n-grams.
+var scriptLanguages = map[*unicode.RangeTable]string{
+	unicode.Arabic:     "ar",
+	unicode.Greek:      "el",
+	unicode.Hebrew:     "he",
+	unicode.Devanagari: "hi",
+	unicode.Hangul:     "ko",
+	unicode.Thai:       "th",
+}
+
+// Parameters of the n-gram model.
+const (
+	languageNgramSize   = 3
+	languageSmoothing   = 0.5 // added to the count of every n-gram
+	languageMaxEvidence = 40  // n-grams counted in full before confidences are damped
+	minLanguageLetters  = 10  // fewer letters make the result unreliable
+)
+
+// languageModel has the n-gram counts of each sampled language.
+type languageModel struct {
+	counts map[string]map[string]float64 // by language, then n-gram
+	totals map[string]float64
+	script map[string]*unicode.RangeTable // of each language's sample
+}
+
+var (
+	languageModelOnce   sync.Once
+	sharedLanguageModel *languageModel
+)
+
+// loadLanguageModel returns the model built from languageSamples.
+func loadLanguageModel() *languageModel {
+	languageModelOnce.Do(func() {
+		m := &languageModel{counts: map[string]map[string]float64{}, totals: map[string]float64{}, script: map[string]*unicode.RangeTable{}}
+		entries, _ := languageSamples.ReadDir("language_samples")
+		for _, e := range entries {
+			data, err := languageSamples.ReadFile("language_samples/" + e.Name())
+			if err != nil {
+				continue
+			}
+			lang := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+			counts := map[string]float64{}
+			for _, g := range languageNgrams(string(data)) {
+				counts[g]++
+				m.totals[lang]++
+			}
+			m.counts[lang] = counts
+			m.script[lang], _ = dominantScript(string(data))
+		}
+		sharedLanguageModel = m
+	})
+	return sharedLanguageModel
+}
+
+// languageNgrams returns the n-grams of the lowercased words of text, with
+// a space marking the start and end of each word.
+func languageNgrams(text string) []string {
+	var grams []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' }) {
+		runes := []rune(" " + strings.Trim(word, "'") + " ")
+		for i := 0; i+languageNgramSize <= len(runes); i++ {
+			grams = append(grams, string(runes[i:i+languageNgramSize]))
+		}
+	}
+	return grams
+}
+
+// detectionScripts are the scripts dominantScript tells apart.
+var detectionScripts = []*unicode.RangeTable{
+	unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Arabic, unicode.Hebrew,
+	unicode.Devanagari, unicode.Thai, unicode.Hangul, unicode.Han, unicode.Hiragana, unicode.Katakana,
+}
+
+// dominantScript returns the script of most letters of text and how many
+// letters there are. Japanese kana count as Han, so that the mix of both
+// in Japanese stays together; kana then shows the text is Japanese.
+func dominantScript(text string) (*unicode.RangeTable, int) {
+	counts := map[*unicode.RangeTable]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range detectionScripts {
+			if unicode.Is(s, r) {
+				if s == unicode.Hiragana || s == unicode.Katakana {
+					counts[unicode.Hiragana]++
+					s = unicode.Han
+				}
+				counts[s]++
+				break
+			}
+		}
+	}
+	var best *unicode.RangeTable
+	for _, s := range detectionScripts {
+		if counts[s] > counts[best] || best == nil && counts[s] > 0 {
+			best = s
+		}
+	}
+	if best == unicode.Han && counts[unicode.Hiragana] > 0 {
+		return unicode.Hiragana, letters
+	}
+	return best, letters
+}
+
+// languageGuess is a language detect_language considers likely.
+type languageGuess struct {
+	Code       string  `json:"code"`
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// detectLanguage returns the languages text is likely in, most likely
+// first, with confidences that add up to 1. Text without letters, or in a
+// script of no known language, has no guesses.
+func detectLanguage(text string) []languageGuess {
+	script, _ := dominantScript(text)
+	switch script {
+	case nil:
+		return nil
+	case unicode.Han:
+		return []languageGuess{{Code: "zh", Name: languageNames["zh"], Confidence: 1}}
+	case unicode.Hiragana:
+		return []languageGuess{{Code: "ja", Name: languageNames["ja"], Confidence: 1}}
+	}
+	if lang, ok := scriptLanguages[script]; ok {
+		return []languageGuess{{Code: lang, Name: languageNames[lang], Confidence: 1}}
+	}
+
+	m := loadLanguageModel()
+	grams := languageNgrams(text)
+	if len(grams) == 0 {
+		return nil
+	}
+	// Naive Bayes over the n-grams, with the log-likelihoods scaled so
+	// that no more than languageMaxEvidence n-grams count fully: texts
+	// repeat themselves, and the model is small, so the raw posteriors
+	// of long texts would be overconfident.
+	scale := math.Min(1, languageMaxEvidence/float64(len(grams)))
+	scores := map[string]float64{}
+	for lang, counts := range m.counts {
+		if m.script[lang] != script {
+			continue
+		}
+		vocabulary := float64(len(counts)) + 1
+		var logp float64
+		for _, g := range grams {
+			logp += math.Log((counts[g] + languageSmoothing) / (m.totals[lang] + languageSmoothing*vocabulary))
+		}
+		scores[lang] = logp * scale
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+	best := math.Inf(-1)
+	for _, s := range scores {
+		best = math.Max(best, s)
+	}
+	var sum float64
+	for _, s := range scores {
+		sum += math.Exp(s - best)
+	}
+	guesses := make([]languageGuess, 0, len(scores))
+	for lang, s := range scores {
+		guesses = append(guesses, languageGuess{Code: lang, Name: languageNames[lang], Confidence: math.Exp(s-best) / sum})
+	}
+	sort.Slice(guesses, func(i, j int) bool {
+		if guesses[i].Confidence != guesses[j].Confidence {
+			return guesses[i].Confidence > guesses[j].Confidence
+		}
+		return guesses[i].Code < guesses[j].Code
+	})
+	return guesses
+}
+
+// detectLanguageTool guesses the language of a text.
+type detectLanguageTool struct{}
+
+// detectLanguageArgs are the arguments of the detect_language tool.
+type detectLanguageArgs struct {
+	Text       string `json:"text" description:"The text to identify the language of"`
+	MaxResults int    `json:"max_results,omitempty" minimum:"1" maximum:"10" description:"Most languages to return (default 3)"`
+}
+
+// Name returns the name of the detect_language tool.
+func (t *detectLanguageTool) Name() string {
+	return "detect_language"
+}
+
+// Description returns a brief description of the detect_language tool.
+func (t *detectLanguageTool) Description() string {
+	return "Identifies the most likely languages of a text, with confidences, among " + strings.Join(knownLanguages(), ", ")
+}
+
+// knownLanguages returns the names of the languages detect_language
+// reports, sorted.
+func knownLanguages() []string {
+	names := make([]string, 0, len(languageNames))
+	for _, n := range languageNames {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InputSchema returns the JSON schema for the detect_language tool's input
+// parameters.
+func (t *detectLanguageTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(detectLanguageArgs{})
+}
+
+// Annotations marks the detect_language tool as read-only.
+func (t *detectLanguageTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Detect language")
+}
+
+// Execute returns the likely languages as indented JSON text. Guesses
+// under 1% confidence are left out, and the result is marked unreliable
+// for very short texts.
+func (t *detectLanguageTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	a := detectLanguageArgs{MaxResults: 3}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.MaxResults < 1 || a.MaxResults > 10 {
+		return nil, fmt.Errorf("invalid value for 'max_results'")
+	}
+	result := struct {
+		Languages []languageGuess `json:"languages"`
+		Reliable  bool            `json:"reliable"`
+	}{Languages: []languageGuess{}}
+	for _, g := range detectLanguage(a.Text) {
+		if len(result.Languages) == a.MaxResults || g.Confidence < 0.01 {
+			break
+		}
+		g.Confidence = math.Round(g.Confidence*1000) / 1000
+		result.Languages = append(result.Languages, g)
+	}
+	_, letters := dominantScript(a.Text)
+	result.Reliable = len(result.Languages) > 0 && letters >= minLanguageLetters
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
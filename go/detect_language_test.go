@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// Test identifying the languages of short texts
+func TestDetectLanguage(t *testing.T) {
+	for text, expected := range map[string]string{
+		"The quick brown fox jumps over the lazy dog": "en",
+		"Je voudrais un café, s'il vous plaît.":       "fr",
+		"Ich habe heute leider keine Zeit.":           "de",
+		"¿Dónde está la estación de tren?":            "es",
+		"Dove si trova la stazione?":                  "it",
+		"Onde fica a estação de comboios?":            "pt",
+		"Waar is het station?":                        "nl",
+		"Var ligger tågstationen?":                    "sv",
+		"Gdzie jest dworzec kolejowy?":                "pl",
+		"Bugün hava çok güzel, dışarı çıkalım mı?":    "tr",
+		"Di mana stasiun kereta api?":                 "id",
+		"Missä on rautatieasema?":                     "fi",
+		"Где находится вокзал? Я не знаю, куда идти.": "ru",
+		"Де знаходиться вокзал? Я не знаю, куди йти.": "uk",
+		"東京駅はどこですか":                                   "ja",
+		"我们明天去北京":                                     "zh",
+		"안녕하세요, 만나서 반갑습니다":                            "ko",
+		"Καλημέρα σας":                                "el",
+		"مرحبا بكم":                                   "ar",
+	} {
+		guesses := detectLanguage(text)
+		if len(guesses) == 0 || guesses[0].Code != expected {
+			t.Errorf("%q: expected %s, got %v", text, expected, guesses)
+		}
+	}
+	if guesses := detectLanguage("1234 !?"); guesses != nil {
+		t.Errorf("expected no guesses without letters, got %v", guesses)
+	}
+}
+
+// Test the detect_language tool's result
+func TestDetectLanguageTool(t *testing.T) {
+	tool := &detectLanguageTool{}
+	var result struct {
+		Languages []languageGuess `json:"languages"`
+		Reliable  bool            `json:"reliable"`
+	}
+	content, err := tool.Execute(map[string]interface{}{"text": "Muchas gracias por todo lo que has hecho por nosotros.", "max_results": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(content[0].Text), &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Languages) == 0 || len(result.Languages) > 2 || result.Languages[0].Code != "es" || result.Languages[0].Name != "Spanish" || !result.Reliable {
+		t.Errorf("unexpected result %s", content[0].Text)
+	}
+	sum := 0.0
+	for _, g := range result.Languages {
+		sum += g.Confidence
+	}
+	if sum > 1.0005 || result.Languages[0].Confidence < 0.5 {
+		t.Errorf("unexpected confidences %s", content[0].Text)
+	}
+
+	content, err = tool.Execute(map[string]interface{}{"text": "ok"})
+	if err != nil || json.Unmarshal([]byte(content[0].Text), &result) != nil || result.Reliable {
+		t.Errorf("expected a short text to be unreliable, got %v, %v", content, err)
+	}
+	if _, err := tool.Execute(map[string]interface{}{"text": "x", "max_results": 11}); err == nil {
+		t.Error("expected an error for max_results")
+	}
+}
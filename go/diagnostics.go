@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/client"
+)
+
+// diagnosticsURI is the URI of the resource listing recent failures.
+const diagnosticsURI = "diagnostics://recent"
+
+// WithDiagnostics keeps a record of the last size tool calls that panicked
+// or timed out, served as the diagnostics://recent resource, so that
+// operators can look into failures through MCP rather than the server's
+// logs.
+func WithDiagnostics(size int) Option {
+	return func(s *Server) {
+		if size > 0 {
+			s.diagnostics = &diagnosticsLog{records: make([]diagnostic, 0, size)}
+		}
+	}
+}
+
+// diagnostic records one failed tool call. The arguments are only
+// digested, as they may hold secrets.
+type diagnostic struct {
+	Time       string  `json:"time"`
+	Kind       string  `json:"kind"` // "panic" or "timeout"
+	Tool       string  `json:"tool"`
+	Session    string  `json:"session,omitempty"`
+	Message    string  `json:"message"`
+	Stack      string  `json:"stack,omitempty"`      // of a panic
+	ArgsDigest string  `json:"argsDigest"`           // SHA-256 of the arguments as JSON
+	ArgsBytes  int     `json:"argsBytes"`            // size of the arguments as JSON
+	DurationMs float64 `json:"durationMs"`           // from the start of the call to the failure
+	TimeoutMs  float64 `json:"timeoutMs,omitempty"`  // the limit a timed out call ran into
+	DeadlineBy string  `json:"deadlineBy,omitempty"` // "server" or "client", for a timeout
+}
+
+// diagnosticsLog is a ring buffer of the most recent diagnostics. It is
+// safe for concurrent use.
+type diagnosticsLog struct {
+	mu      sync.Mutex
+	records []diagnostic // oldest first once full, from next on
+	next    int
+}
+
+// add records d, dropping the oldest record if the log is full.
+func (l *diagnosticsLog) add(d diagnostic) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.records) < cap(l.records) {
+		l.records = append(l.records, d)
+		return
+	}
+	l.records[l.next] = d
+	l.next = (l.next + 1) % len(l.records)
+}
+
+// recent returns the records, newest first.
+func (l *diagnosticsLog) recent() []diagnostic {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent := make([]diagnostic, 0, len(l.records))
+	for i := len(l.records) - 1; i >= 0; i-- {
+		recent = append(recent, l.records[(l.next+i)%len(l.records)])
+	}
+	return recent
+}
+
+// argsDigest returns the SHA-256 of args as JSON, in hex, and the size of
+// the JSON.
+func argsDigest(args map[string]interface{}) (string, int) {
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return "", 0
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), len(encoded)
+}
+
+// recordDiagnostic adds d, completed from the call to the session, if the
+// server keeps diagnostics.
+func (s *Server) recordDiagnostic(sess *session, args map[string]interface{}, start time.Time, d diagnostic) {
+	if s.diagnostics == nil {
+		return
+	}
+	d.Time = start.UTC().Format(time.RFC3339Nano)
+	d.Session = sess.state.ID()
+	d.Message = s.redactor.redactString(d.Message)
+	d.ArgsDigest, d.ArgsBytes = argsDigest(args)
+	d.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	s.diagnostics.add(d)
+}
+
+// diagnosticsResource returns the resources/list entry of the diagnostics.
+func diagnosticsResource() client.Resource {
+	return client.Resource{
+		URI:         diagnosticsURI,
+		Name:        "Recent tool failures",
+		Description: "The latest tool calls that panicked or timed out, newest first",
+		MimeType:    "application/json",
+	}
+}
+
+// sendDiagnostics writes the resources/read response of the diagnostics.
+func (s *Server) sendDiagnostics(w io.Writer, id interface{}) {
+	encoded, err := json.MarshalIndent(map[string]interface{}{"diagnostics": s.diagnostics.recent()}, "", "  ")
+	if err != nil {
+		sendError(w, id, -32603, "Internal error: "+err.Error())
+		return
+	}
+	sendResponse(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result": map[string]interface{}{"contents": []client.ResourceContents{
+			{URI: diagnosticsURI, MimeType: "application/json", Text: string(encoded)},
+		}},
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that the diagnostics log keeps the newest records
+func TestDiagnosticsLog(t *testing.T) {
+	l := &diagnosticsLog{records: make([]diagnostic, 0, 3)}
+	tools := func() []string {
+		var names []string
+		for _, d := range l.recent() {
+			names = append(names, d.Tool)
+		}
+		return names
+	}
+	if got := tools(); len(got) != 0 {
+		t.Errorf("expected no records, got %v", got)
+	}
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		l.add(diagnostic{Tool: name})
+		if i == 1 {
+			if got, want := tools(), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		}
+	}
+	if got, want := tools(), []string{"e", "d", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// Test that panics and timeouts are served as the diagnostics resource
+func TestDiagnosticsResource(t *testing.T) {
+	s := NewServer(WithTools(&panickingTool{}, slowMockTool()), WithDiagnostics(2),
+		WithRequestTimeout(20*time.Millisecond), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	for _, input := range []string{
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"panic","arguments":{}},"id":1}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"slow","arguments":{}},"id":2}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"panic","arguments":{"token":"hunter2"}},"id":3}`,
+	} {
+		runServerInput(t, s, input)
+	}
+
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"resources/list","id":1}
+{"jsonrpc":"2.0","method":"resources/read","params":{"uri":"diagnostics://recent"},"id":2}`)
+	if len(lines) != 2 || !strings.Contains(lines[0], `"uri":"diagnostics://recent"`) {
+		t.Fatalf("expected the diagnostics to be listed, got %v", lines)
+	}
+	if strings.Contains(lines[1], "hunter2") {
+		t.Errorf("expected the arguments not to be recorded, got %s", lines[1])
+	}
+	var resp struct {
+		Result struct {
+			Contents []struct {
+				MimeType string `json:"mimeType"`
+				Text     string `json:"text"`
+			} `json:"contents"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &resp); err != nil || len(resp.Result.Contents) != 1 {
+		t.Fatalf("unexpected response %s: %v", lines[1], err)
+	}
+	var read struct {
+		Diagnostics []diagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal([]byte(resp.Result.Contents[0].Text), &read); err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Diagnostics) != 2 {
+		t.Fatalf("expected the two newest records, got %+v", read.Diagnostics)
+	}
+	panicked, timedOut := read.Diagnostics[0], read.Diagnostics[1]
+	digest, size := argsDigest(map[string]interface{}{"token": "hunter2"})
+	if panicked.Kind != "panic" || panicked.Tool != "panic" || panicked.Message != "tool panicked: boom" ||
+		!strings.Contains(panicked.Stack, "goroutine") || panicked.ArgsDigest != digest || panicked.ArgsBytes != size {
+		t.Errorf("unexpected panic record %+v", panicked)
+	}
+	if timedOut.Kind != "timeout" || timedOut.Tool != "slow" || timedOut.DeadlineBy != "server" || timedOut.TimeoutMs != 20 ||
+		timedOut.DurationMs < 20 || timedOut.Stack != "" {
+		t.Errorf("unexpected timeout record %+v", timedOut)
+	}
+}
+
+// Test that the diagnostics resource is absent unless enabled
+func TestDiagnosticsDisabled(t *testing.T) {
+	lines := runServerInput(t, NewServer(WithDiagnostics(0)), `{"jsonrpc":"2.0","method":"resources/read","params":{"uri":"diagnostics://recent"},"id":1}`)
+	if len(lines) != 1 || !strings.Contains(lines[0], "Unknown resource") {
+		t.Errorf("expected an unknown resource, got %v", lines)
+	}
+}
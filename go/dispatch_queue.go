@@ -0,0 +1,116 @@
+package main
+
+import "sync"
+
+// priority orders the work waiting for a worker in the priority queue.
+// Lower values run first.
+type priority int
+
+const (
+	priorityInteractive priority = iota // completions, resource reads and prompts, which a user waits on
+	priorityTool                        // tool calls
+	numPriorities
+)
+
+// dispatchQueue holds work waiting for a worker. With it the server keeps
+// reading requests while every worker is busy, so the ones it answers
+// itself, such as ping, tools/list and cancellations, are never stuck
+// behind a backlog of tool calls, and a free worker takes the most urgent
+// work first. Work of the same priority runs in the order it came.
+type dispatchQueue struct {
+	admit chan struct{} // holds a token for each queued job, bounding them
+
+	mu   sync.Mutex
+	jobs [numPriorities][]func()
+}
+
+// WithPriorityQueue queues up to n requests waiting for a worker instead
+// of blocking until one is free, and starts the interactive ones before
+// tool calls. Once n are queued, the server stops reading requests as it
+// does without the queue.
+func WithPriorityQueue(n int) Option {
+	return func(s *Server) {
+		if n < 1 {
+			n = 1
+		}
+		s.queue = &dispatchQueue{admit: make(chan struct{}, n)}
+	}
+}
+
+// enqueue queues fn, blocking while the queue is full. It reports false
+// without queueing fn if the session shuts down first. Queued work counts
+// as in flight, so that draining the session waits for it.
+func (s *Server) enqueue(sess *session, p priority, fn func()) bool {
+	q := s.queue
+	select {
+	case q.admit <- struct{}{}:
+	case <-sess.shutdown:
+		return false
+	}
+	sess.inflight.Add(1)
+	q.mu.Lock()
+	q.jobs[p] = append(q.jobs[p], func() {
+		defer sess.inflight.Done()
+		fn()
+	})
+	q.mu.Unlock()
+	s.startQueued()
+	return true
+}
+
+// startQueued hands the free workers to queued work, the most urgent
+// first. It runs whenever work is queued and whenever a worker finishes,
+// so no queued work waits while a worker is free.
+func (s *Server) startQueued() {
+	q := s.queue
+	for {
+		select {
+		case s.workers <- struct{}{}:
+		default:
+			return
+		}
+		job := q.pop()
+		if job == nil {
+			<-s.workers
+			// Work queued after pop found none may have seen this worker
+			// busy.
+			if q.empty() {
+				return
+			}
+			continue
+		}
+		<-q.admit
+		go func() {
+			defer s.startQueued()
+			defer func() { <-s.workers }()
+			job()
+		}()
+	}
+}
+
+// pop removes and returns the most urgent job, or nil if there is none.
+func (q *dispatchQueue) pop() func() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for p, jobs := range q.jobs {
+		if len(jobs) > 0 {
+			job := jobs[0]
+			jobs[0] = nil
+			q.jobs[p] = jobs[1:]
+			return job
+		}
+	}
+	return nil
+}
+
+// empty reports whether no work is queued.
+func (q *dispatchQueue) empty() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, jobs := range q.jobs {
+		if len(jobs) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Test that the queue keeps answering requests while every worker is busy,
+// and starts interactive work before queued tool calls
+func TestPriorityQueue(t *testing.T) {
+	tool := newBlockingTool()
+	complete := func(ctx context.Context, value string, args map[string]string) ([]string, error) {
+		return []string{"fast"}, nil
+	}
+	s := NewServer(WithTools(tool, &echoTool{}), WithMaxWorkers(1), WithPriorityQueue(8),
+		WithPromptCompleter("greet", "name", complete))
+	pw, out, errc := serveInBackground(context.Background(), s)
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"block","arguments":{}},"id":1}`)
+	<-tool.started
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"a"}},"id":2}`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"b"}},"id":3}`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"completion/complete","params":{"ref":{"type":"ref/prompt","name":"greet"},"argument":{"name":"name","value":""}},"id":4}`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"ping","id":5}`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/list","id":6}`)
+	waitForOutput(t, out, regexp.MustCompile(`"id":6`), 1)
+	if strings.Contains(out.String(), `"id":2`) || strings.Contains(out.String(), `"id":4`) {
+		t.Fatalf("expected the queued work to wait for the worker, got:\n%s", out.String())
+	}
+
+	close(tool.release)
+	waitForOutput(t, out, regexp.MustCompile(`"id":3`), 1)
+	var order []string
+	for _, m := range regexp.MustCompile(`\{"id":(\d)`).FindAllStringSubmatch(out.String(), -1) {
+		order = append(order, m[1])
+	}
+	if got := strings.Join(order, ","); got != "5,6,1,4,2,3" {
+		t.Errorf("expected responses in the order 5,6,1,4,2,3, got %s", got)
+	}
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// dockerConfig configures the docker tool in the "docker" section of the
+// config file.
+type dockerConfig struct {
+	Host     string   `json:"host"`     // unix:// or tcp://, defaults to $DOCKER_HOST, then the local socket
+	ShowEnv  bool     `json:"showEnv"`  // shows the values of environment variables in inspect
+	MaxItems int      `json:"maxItems"` // listed by containers and images, defaults to 100
+	MaxBytes int      `json:"maxBytes"` // of a response, defaults to 256 KiB
+	LogLines int      `json:"logLines"` // most log lines, defaults to 200
+	Timeout  duration `json:"timeout"`  // per call, defaults to 10s
+}
+
+// Defaults of the docker tool.
+const (
+	defaultDockerHost     = "unix:///var/run/docker.sock"
+	defaultDockerMaxItems = 100
+	defaultDockerMaxBytes = 256 << 10
+	defaultDockerLogLines = 200
+	defaultDockerTimeout  = 10 * time.Second
+)
+
+// dockerName matches container names and IDs.
+var dockerName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// validate reports malformed fields.
+func (c *dockerConfig) validate() error {
+	if c.Host != "" {
+		if _, _, err := dockerEndpoint(c.Host); err != nil {
+			return err
+		}
+	}
+	if c.MaxItems < 0 || c.MaxBytes < 0 || c.LogLines < 0 || c.Timeout < 0 {
+		return errors.New("docker: maxItems, maxBytes, logLines, and timeout must not be negative")
+	}
+	return nil
+}
+
+// dockerEndpoint returns the network and address of a Docker host.
+func dockerEndpoint(host string) (network, addr string, err error) {
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		return "unix", strings.TrimPrefix(host, "unix://"), nil
+	case strings.HasPrefix(host, "tcp://"):
+		return "tcp", strings.TrimPrefix(host, "tcp://"), nil
+	}
+	return "", "", fmt.Errorf("docker: unsupported host %q; expected unix:// or tcp://", host)
+}
+
+// dockerTool inspects the containers and images of a Docker daemon through
+// its API. It only reads: it cannot start, stop, or remove anything.
+type dockerTool struct {
+	cfg    dockerConfig
+	client *http.Client
+}
+
+// newDockerTool returns the docker tool for cfg.
+func newDockerTool(cfg dockerConfig) (*dockerTool, error) {
+	if cfg.Host == "" {
+		cfg.Host = os.Getenv("DOCKER_HOST")
+	}
+	if cfg.Host == "" {
+		cfg.Host = defaultDockerHost
+	}
+	if cfg.MaxItems == 0 {
+		cfg.MaxItems = defaultDockerMaxItems
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultDockerMaxBytes
+	}
+	if cfg.LogLines == 0 {
+		cfg.LogLines = defaultDockerLogLines
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = duration(defaultDockerTimeout)
+	}
+	network, addr, err := dockerEndpoint(cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+	// Every request goes to the daemon, whatever the host of its URL.
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+	return &dockerTool{cfg: cfg, client: &http.Client{Transport: &http.Transport{DialContext: dial}}}, nil
+}
+
+// dockerArgs are the arguments of the docker tool.
+type dockerArgs struct {
+	Action    string `json:"action" enum:"containers,images,inspect,logs" description:"What to do: list containers or images, inspect a container, or get the recent logs of a container"`
+	Container string `json:"container,omitempty" description:"Name or ID of the container, for inspect and logs"`
+	All       bool   `json:"all,omitempty" description:"List stopped containers too"`
+	TailLines int    `json:"tail_lines,omitempty" minimum:"1" description:"Number of log lines to return, from the end (default the server's limit)"`
+	Since     string `json:"since,omitempty" description:"Only logs newer than this duration, such as 10m"`
+}
+
+// Name returns the name of the docker tool.
+func (t *dockerTool) Name() string {
+	return "docker"
+}
+
+// Description returns a brief description of the docker tool.
+func (t *dockerTool) Description() string {
+	return fmt.Sprintf("Inspects the Docker daemon at %s: lists containers and images, inspects containers, and returns their recent logs. Read-only", t.cfg.Host)
+}
+
+// InputSchema returns the JSON schema for the docker tool's input
+// parameters.
+func (t *dockerTool) InputSchema() map[string]interface{} {
+	schema := mcp.SchemaFor(dockerArgs{})
+	schema["properties"].(map[string]interface{})["tail_lines"].(map[string]interface{})["maximum"] = t.cfg.LogLines
+	return schema
+}
+
+// Annotations marks the docker tool as read-only. Containers come and go,
+// so it is not idempotent.
+func (t *dockerTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "Docker", ReadOnlyHint: true}
+}
+
+// Timeout returns the configured time limit of a call.
+func (t *dockerTool) Timeout() time.Duration {
+	return time.Duration(t.cfg.Timeout)
+}
+
+// Execute runs the action without a deadline of its own.
+func (t *dockerTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the action and returns its result as text.
+func (t *dockerTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult runs the action and returns its result: lists and
+// containers as structured content, and logs as text.
+func (t *dockerTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a dockerArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	switch {
+	case (a.Action == "inspect" || a.Action == "logs") && a.Container == "":
+		return nil, fmt.Errorf("'container' is required for %s", a.Action)
+	case a.Container != "" && !dockerName.MatchString(a.Container):
+		return nil, errors.New("invalid value for 'container'")
+	case a.TailLines < 0 || a.TailLines > t.cfg.LogLines:
+		return nil, fmt.Errorf("invalid value for 'tail_lines': expected 1 to %d", t.cfg.LogLines)
+	}
+	var since time.Duration
+	if a.Since != "" {
+		var err error
+		if since, err = time.ParseDuration(a.Since); err != nil || since <= 0 {
+			return nil, errors.New("invalid value for 'since': expected a duration such as 10m")
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.Timeout))
+	defer cancel()
+	var result *ToolResult
+	var err error
+	switch a.Action {
+	case "containers":
+		result, err = t.containers(ctx, a.All)
+	case "images":
+		result, err = t.images(ctx)
+	case "inspect":
+		var c map[string]interface{}
+		if c, err = t.inspect(ctx, a.Container); err == nil {
+			result = mcp.NewResult().WithStructured(c)
+		}
+	case "logs":
+		result, err = t.logs(ctx, a, since)
+	default:
+		return nil, errors.New("invalid value for 'action': expected containers, images, inspect, or logs")
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return result, err
+}
+
+// get fetches the API path p with query and returns the body. Bodies
+// longer than the configured limit fail unless clip is set, which cuts
+// them.
+func (t *dockerTool) get(ctx context.Context, p string, query url.Values, clip bool) ([]byte, bool, error) {
+	u := "http://docker" + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, false, toolFailure("docker at %s: %v", t.cfg.Host, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.cfg.MaxBytes)+1))
+	if err != nil {
+		return nil, false, toolFailure("docker at %s: %v", t.cfg.Host, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Message != "" {
+			return nil, false, toolFailure("docker: %s", failure.Message)
+		}
+		return nil, false, toolFailure("docker: %s", resp.Status)
+	}
+	truncated := len(body) > t.cfg.MaxBytes
+	if truncated && !clip {
+		return nil, false, toolFailure("docker: the response is larger than %d bytes", t.cfg.MaxBytes)
+	}
+	if truncated {
+		body = body[:t.cfg.MaxBytes]
+	}
+	return body, truncated, nil
+}
+
+// shortID returns the first 12 hex digits of a Docker ID, as docker ps
+// shows them.
+func shortID(id string) string {
+	id = strings.TrimPrefix(id, "sha256:")
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// dockerContainer is a container as the containers action lists it.
+type dockerContainer struct {
+	ID      string   `json:"id"`
+	Names   []string `json:"names"`
+	Image   string   `json:"image"`
+	State   string   `json:"state"`
+	Status  string   `json:"status"`
+	Created string   `json:"created"`
+}
+
+// containers lists up to the configured number of containers, the
+// running ones only unless all is set.
+func (t *dockerTool) containers(ctx context.Context, all bool) (*ToolResult, error) {
+	// One more than the limit tells whether there are more.
+	query := url.Values{"limit": {strconv.Itoa(t.cfg.MaxItems + 1)}}
+	if all {
+		query.Set("all", "true")
+	}
+	body, _, err := t.get(ctx, "/containers/json", query, false)
+	if err != nil {
+		return nil, err
+	}
+	var list []struct {
+		ID      string   `json:"Id"`
+		Names   []string `json:"Names"`
+		Image   string   `json:"Image"`
+		State   string   `json:"State"`
+		Status  string   `json:"Status"`
+		Created int64    `json:"Created"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, toolFailure("docker: malformed container list: %v", err)
+	}
+	containers := []dockerContainer{}
+	for _, c := range list {
+		names := make([]string, len(c.Names))
+		for i, n := range c.Names {
+			names[i] = strings.TrimPrefix(n, "/")
+		}
+		containers = append(containers, dockerContainer{
+			ID: shortID(c.ID), Names: names, Image: c.Image, State: c.State, Status: c.Status,
+			Created: time.Unix(c.Created, 0).UTC().Format(time.RFC3339),
+		})
+	}
+	truncated := len(containers) > t.cfg.MaxItems
+	if truncated {
+		containers = containers[:t.cfg.MaxItems]
+	}
+	return listResult("containers", containers, truncated), nil
+}
+
+// dockerImage is an image as the images action lists it.
+type dockerImage struct {
+	ID      string   `json:"id"`
+	Tags    []string `json:"tags"`
+	Size    int64    `json:"size"`
+	Created string   `json:"created"`
+}
+
+// images lists up to the configured number of images.
+func (t *dockerTool) images(ctx context.Context) (*ToolResult, error) {
+	body, _, err := t.get(ctx, "/images/json", nil, false)
+	if err != nil {
+		return nil, err
+	}
+	var list []struct {
+		ID       string   `json:"Id"`
+		RepoTags []string `json:"RepoTags"`
+		Size     int64    `json:"Size"`
+		Created  int64    `json:"Created"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, toolFailure("docker: malformed image list: %v", err)
+	}
+	images := []dockerImage{}
+	for _, img := range list {
+		tags := img.RepoTags
+		if tags == nil {
+			tags = []string{}
+		}
+		images = append(images, dockerImage{ID: shortID(img.ID), Tags: tags, Size: img.Size, Created: time.Unix(img.Created, 0).UTC().Format(time.RFC3339)})
+	}
+	truncated := len(images) > t.cfg.MaxItems
+	if truncated {
+		images = images[:t.cfg.MaxItems]
+	}
+	return listResult("images", images, truncated), nil
+}
+
+// listResult returns the result of listing items under key.
+func listResult(key string, items interface{}, truncated bool) *ToolResult {
+	result := map[string]interface{}{key: items}
+	if truncated {
+		result["truncated"] = true
+	}
+	return mcp.NewResult().WithStructured(result)
+}
+
+// inspect returns the low-level information of a container. The values
+// of its environment variables are redacted unless configured otherwise,
+// since they often hold credentials.
+func (t *dockerTool) inspect(ctx context.Context, container string) (map[string]interface{}, error) {
+	body, _, err := t.get(ctx, "/containers/"+url.PathEscape(container)+"/json", nil, false)
+	if err != nil {
+		return nil, err
+	}
+	var c map[string]interface{}
+	if err := json.Unmarshal(body, &c); err != nil {
+		return nil, toolFailure("docker: malformed container: %v", err)
+	}
+	if config, ok := c["Config"].(map[string]interface{}); ok && !t.cfg.ShowEnv {
+		if env, ok := config["Env"].([]interface{}); ok {
+			for i, v := range env {
+				if s, ok := v.(string); ok {
+					name, _, _ := strings.Cut(s, "=")
+					env[i] = name + "=" + redactedValue
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// logs returns the recent logs of the container in a, cut to the
+// configured size.
+func (t *dockerTool) logs(ctx context.Context, a dockerArgs, since time.Duration) (*ToolResult, error) {
+	c, err := t.inspect(ctx, a.Container)
+	if err != nil {
+		return nil, err
+	}
+	tty := false
+	if config, ok := c["Config"].(map[string]interface{}); ok {
+		tty, _ = config["Tty"].(bool)
+	}
+	lines := a.TailLines
+	if lines == 0 {
+		lines = t.cfg.LogLines
+	}
+	query := url.Values{"stdout": {"true"}, "stderr": {"true"}, "tail": {strconv.Itoa(lines)}}
+	if since > 0 {
+		query.Set("since", strconv.FormatInt(time.Now().Add(-since).Unix(), 10))
+	}
+	body, truncated, err := t.get(ctx, "/containers/"+url.PathEscape(a.Container)+"/logs", query, true)
+	if err != nil {
+		return nil, err
+	}
+	text := string(body)
+	if !tty {
+		text = demuxDockerLogs(body)
+	}
+	if truncated {
+		text += fmt.Sprintf("\n[logs cut at %d bytes]", t.cfg.MaxBytes)
+	}
+	return mcp.NewResult(mcp.NewTextContent(text)), nil
+}
+
+// demuxDockerLogs joins the frames of the logs of a container without a
+// TTY, each of which has an 8-byte header with its stream and length. A
+// frame cut short at the end is kept as far as it goes.
+func demuxDockerLogs(data []byte) string {
+	var b strings.Builder
+	for len(data) >= 8 {
+		n := int(binary.BigEndian.Uint32(data[4:8]))
+		data = data[8:]
+		if n > len(data) {
+			n = len(data)
+		}
+		b.Write(data[:n])
+		data = data[n:]
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dockerFrame encodes text as a frame of the logs of a container without a
+// TTY.
+func dockerFrame(stream byte, text string) string {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(text)))
+	return string(header) + text
+}
+
+// startFakeDocker serves a few paths of the Docker API on a unix socket
+// and returns its host.
+func startFakeDocker(t *testing.T) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets are not available: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			if r.URL.Query().Get("all") != "true" || r.URL.Query().Get("limit") != "3" {
+				t.Errorf("unexpected container query %v", r.URL.Query())
+			}
+			fmt.Fprint(w, `[
+				{"Id":"0123456789abcdef0123","Names":["/web"],"Image":"nginx:1.27","State":"running","Status":"Up 2 hours","Created":1760436000},
+				{"Id":"fedcba9876543210fedc","Names":["/worker"],"Image":"app:dev","State":"exited","Status":"Exited (1) 5 minutes ago","Created":1760436000},
+				{"Id":"aaaaaaaaaaaaaaaaaaaa","Names":["/db"],"Image":"postgres:16","State":"running","Status":"Up 2 hours","Created":1760436000}]`)
+		case "/images/json":
+			fmt.Fprint(w, `[{"Id":"sha256:99999999999999999999","RepoTags":["nginx:1.27"],"Size":192000000,"Created":1760436000},{"Id":"sha256:8888888888888888","RepoTags":null,"Size":10,"Created":1760436000}]`)
+		case "/containers/worker/json", "/containers/web/json":
+			tty := r.URL.Path == "/containers/web/json"
+			fmt.Fprintf(w, `{"Id":"fedcba9876543210fedc","Name":"/worker","Config":{"Tty":%t,"Env":["PATH=/usr/bin","DATABASE_URL=postgres://u:hunter2@db/app"]},"State":{"ExitCode":1}}`, tty)
+		case "/containers/worker/logs":
+			if q := r.URL.Query(); q.Get("tail") != "5" || q.Get("stderr") != "true" || q.Get("since") == "" {
+				t.Errorf("unexpected log query %v", q)
+			}
+			fmt.Fprint(w, dockerFrame(1, "starting\n")+dockerFrame(2, "panic: no database\n"))
+		case "/containers/web/logs":
+			fmt.Fprint(w, strings.Repeat("GET / 200\n", 100))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message":"No such container: %s"}`, strings.Split(r.URL.Path, "/")[2])
+		}
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return "unix://" + socket
+}
+
+// callDocker calls tool and returns its structured result as JSON, or its
+// text if it has none.
+func callDocker(t *testing.T, tool *dockerTool, args map[string]interface{}) (string, error) {
+	t.Helper()
+	result, err := tool.ExecuteResult(context.Background(), args)
+	if err != nil {
+		return "", err
+	}
+	if result.Structured == nil {
+		return result.Content[0].Text, nil
+	}
+	encoded, _ := json.Marshal(result.Structured)
+	return string(encoded), nil
+}
+
+// Test that the tool lists, inspects, and shows the logs of containers
+// within the limits, without showing their environment
+func TestDockerTool(t *testing.T) {
+	tool, err := newDockerTool(dockerConfig{Host: startFakeDocker(t), MaxItems: 2, MaxBytes: 500, LogLines: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"action": "containers", "all": true},
+			`{"containers":[{"id":"0123456789ab","names":["web"],"image":"nginx:1.27","state":"running","status":"Up 2 hours","created":"2025-10-14T10:00:00Z"},` +
+				`{"id":"fedcba987654","names":["worker"],"image":"app:dev","state":"exited","status":"Exited (1) 5 minutes ago","created":"2025-10-14T10:00:00Z"}],"truncated":true}`},
+		{map[string]interface{}{"action": "images"},
+			`{"images":[{"id":"999999999999","tags":["nginx:1.27"],"size":192000000,"created":"2025-10-14T10:00:00Z"},{"id":"888888888888","tags":[],"size":10,"created":"2025-10-14T10:00:00Z"}]}`},
+		{map[string]interface{}{"action": "inspect", "container": "worker"},
+			`{"Config":{"Env":["PATH=[REDACTED]","DATABASE_URL=[REDACTED]"],"Tty":false},"Id":"fedcba9876543210fedc","Name":"/worker","State":{"ExitCode":1}}`},
+		{map[string]interface{}{"action": "logs", "container": "worker", "tail_lines": 5, "since": "10m"},
+			"starting\npanic: no database\n"},
+		{map[string]interface{}{"action": "logs", "container": "web"},
+			strings.Repeat("GET / 200\n", 50) + "\n[logs cut at 500 bytes]"},
+	} {
+		got, err := callDocker(t, tool, tc.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tc.args, err)
+		} else if got != tc.want {
+			t.Errorf("%v: expected\n%s\ngot\n%s", tc.args, tc.want, got)
+		}
+	}
+
+	for want, args := range map[string]map[string]interface{}{
+		"No such container: gone":       {"action": "inspect", "container": "gone"},
+		"'container' is required":       {"action": "logs"},
+		"invalid value for 'container'": {"action": "inspect", "container": "../images"},
+		"invalid value for 'since'":     {"action": "logs", "container": "web", "since": "yesterday"},
+		"invalid value for 'action'":    {"action": "rm", "container": "web"},
+	} {
+		if _, err := tool.ExecuteResult(context.Background(), args); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected an error containing %q, got %v", args, want, err)
+		}
+	}
+
+	tool.cfg.ShowEnv = true
+	if got, _ := callDocker(t, tool, map[string]interface{}{"action": "inspect", "container": "worker"}); !strings.Contains(got, "hunter2") {
+		t.Errorf("expected the environment to be shown, got %s", got)
+	}
+}
+
+// Test that hosts other than unix and tcp ones are rejected
+func TestDockerConfig(t *testing.T) {
+	for _, host := range []string{"unix:///run/user/1000/docker.sock", "tcp://127.0.0.1:2375"} {
+		if err := (&dockerConfig{Host: host}).validate(); err != nil {
+			t.Errorf("%s: unexpected error %v", host, err)
+		}
+	}
+	for _, c := range []dockerConfig{{Host: "ssh://me@host"}, {Host: "/var/run/docker.sock"}, {LogLines: -1}} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import "mcp-minimal-server-go/mcp"
+
+// dryRunArgument is the argument that asks a DryRunTool for a dry run.
+const dryRunArgument = "dryRun"
+
+// dryRunSupport returns t as a DryRunTool, looking through renames, and
+// false if it cannot do dry runs.
+func dryRunSupport(t MCPTool) (DryRunTool, bool) {
+	if r, ok := t.(*renamedTool); ok {
+		t = r.MCPTool
+	}
+	dr, ok := t.(DryRunTool)
+	return dr, ok
+}
+
+// withDryRunProperty returns a copy of schema with the dryRun argument
+// among its properties.
+func withDryRunProperty(schema map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema)+1)
+	for k, v := range schema {
+		copied[k] = v
+	}
+	props := map[string]interface{}{}
+	if existing, ok := schema["properties"].(map[string]interface{}); ok {
+		for k, v := range existing {
+			props[k] = v
+		}
+	}
+	props[dryRunArgument] = map[string]interface{}{
+		"type":        "boolean",
+		"description": "Describe what the call would do without doing it",
+	}
+	copied["properties"] = props
+	return copied
+}
+
+// checkDryRun reports whether args ask t for a dry run, and returns the
+// arguments for the tool itself. It returns false for ok if a dry run is
+// asked of a tool that cannot do one and has no dryRun argument of its own,
+// which must be refused rather than run for real.
+func checkDryRun(t MCPTool, schema, args map[string]interface{}) (dryRun bool, toolArgs map[string]interface{}, ok bool) {
+	requested, _ := args[dryRunArgument].(bool)
+	if _, supported := dryRunSupport(t); !supported {
+		props, _ := schema["properties"].(map[string]interface{})
+		_, own := props[dryRunArgument]
+		return false, args, own || !requested
+	}
+	if _, given := args[dryRunArgument]; !given {
+		return false, args, true
+	}
+	rest := make(map[string]interface{}, len(args)-1)
+	for k, v := range args {
+		if k != dryRunArgument {
+			rest[k] = v
+		}
+	}
+	return requested, rest, true
+}
+
+// dryRunner stands in for a tool whose call is a dry run: executing it
+// returns the tool's description of the call.
+type dryRunner struct {
+	MCPTool
+	dr DryRunTool
+}
+
+// Execute describes the call.
+func (d dryRunner) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	description, err := d.dr.DryRun(args)
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{mcp.NewTextContent("Dry run: " + description)}, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test that dry runs describe the call without running it, and that tools
+// without support refuse them
+func TestDryRun(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	touch := newCommandTool(commandToolConfig{Name: "touch", Template: "touch {{path}}"})
+	var calls []*ToolCall
+	s := NewServer(WithTools(touch, &echoTool{}), WithHooks(Hooks{AfterToolCall: func(_ context.Context, call *ToolCall) {
+		calls = append(calls, call)
+	}}))
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/list","id":1}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"touch","arguments":{"path":"`+marker+`","dryRun":true}},"id":2}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi","dryRun":true}},"id":3}
+`)
+	out := strings.Join(lines, "\n")
+	for _, want := range []string{
+		`"dryRun":{"description":"Describe what the call would do without doing it","type":"boolean"}`,
+		`"id":2,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Dry run: would run \"sh\" \"-c\"`,
+		`"id":3,"error":{"code":-32602,"message":"Invalid parameter 'dryRun': tool 'echo' does not support dry runs"}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in output:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, `"dryRun":{`) != 1 {
+		t.Errorf("expected the dryRun argument only for the command tool:\n%s", out)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("expected the command not to run, got %v", err)
+	}
+	if len(calls) != 1 || !calls[0].DryRun || calls[0].Arguments["dryRun"] != nil {
+		t.Errorf("expected the hook to see a dry run without the dryRun argument, got %+v", calls)
+	}
+}
+
+// Test the dry runs of the email and webhook tools
+func TestDryRunDescriptions(t *testing.T) {
+	email := newSendEmailTool(emailConfig{Host: "localhost", Port: 25, TLS: "none",
+		From: "alerts@example.com", AllowedDomains: stringList{"example.com"}})
+	got, err := email.DryRun(map[string]interface{}{"to": []interface{}{"ann@example.com"}, "subject": "Hi", "body": "Hello"})
+	if want := `would send "Hi" (5 bytes of body) from alerts@example.com to ann@example.com`; err != nil || got != want {
+		t.Errorf("got %q, %v, expected %q", got, err, want)
+	}
+	if _, err := email.DryRun(map[string]interface{}{"to": []interface{}{"eve@example.net"}}); err == nil {
+		t.Error("expected the recipient to be refused as in a call")
+	}
+
+	hook := newNotifyWebhookTool([]webhookConfig{{Name: "ops", URL: "http://127.0.0.1:0"}})
+	got, err = hook.DryRun(map[string]interface{}{"webhook": "ops", "message": "héllo"})
+	if want := "would post a message of 5 characters to ops"; err != nil || got != want {
+		t.Errorf("got %q, %v, expected %q", got, err, want)
+	}
+}
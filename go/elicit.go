@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// clientRequests tracks requests the server sends to the client of a
+// stdio session, such as elicitations, until their responses arrive.
+type clientRequests struct {
+	done chan struct{} // closed when the client can no longer respond
+
+	mu      sync.Mutex
+	next    int
+	pending map[string]chan clientResponse
+	closed  bool
+}
+
+// clientResponse is the client's answer to a server-initiated request.
+type clientResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *JSONRPCError   `json:"error"`
+}
+
+// errNoResponse is returned when the session ends before the client
+// answers.
+var errNoResponse = errors.New("client did not respond")
+
+// newClientRequests returns an empty tracker.
+func newClientRequests() *clientRequests {
+	return &clientRequests{done: make(chan struct{}), pending: map[string]chan clientResponse{}}
+}
+
+// close fails every pending and future request.
+func (c *clientRequests) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+}
+
+// open reports whether requests can still be sent to the client.
+func (c *clientRequests) open() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.closed
+}
+
+// waiting reports whether any request is waiting for its response.
+func (c *clientRequests) waiting() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending) > 0
+}
+
+// deliver hands a response message to the request waiting for it. It
+// reports whether line was a response at all; responses are never
+// answered, even if nothing waits for them.
+func (c *clientRequests) deliver(id json.RawMessage, line []byte) bool {
+	var resp clientResponse
+	if err := json.Unmarshal(line, &resp); err != nil || (resp.Result == nil && resp.Error == nil) {
+		return false
+	}
+	if c == nil {
+		return true
+	}
+	c.mu.Lock()
+	ch, ok := c.pending[string(id)]
+	delete(c.pending, string(id))
+	c.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+	return true
+}
+
+// call sends a request to the client over w and waits for the response.
+func (c *clientRequests) call(ctx context.Context, w io.Writer, method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errNoResponse
+	}
+	c.next++
+	id := strconv.Quote("srv-" + strconv.Itoa(c.next))
+	ch := make(chan clientResponse, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	sendResponse(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      json.RawMessage(id),
+		"method":  method,
+		"params":  params,
+	})
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return nil, fmt.Errorf("client error %d: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return resp.Result, nil
+	case <-c.done:
+		return nil, errNoResponse
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// WithConfirmDestructive makes the server ask the client to confirm every
+// call to a destructive tool through an elicitation request before running
+// it. Calls are refused if the user declines or the client cannot elicit.
+func WithConfirmDestructive() Option {
+	return func(s *Server) {
+		s.confirmDestructive = true
+	}
+}
+
+// isDestructive reports whether t may make destructive changes. Following
+// the MCP defaults, tools that are not read-only are destructive unless
+// they say otherwise.
+func isDestructive(t MCPTool) bool {
+	at, ok := t.(AnnotatedTool)
+	if !ok {
+		return true
+	}
+	a := at.Annotations()
+	if a.ReadOnlyHint {
+		return false
+	}
+	return a.DestructiveHint == nil || *a.DestructiveHint
+}
+
+// confirmCall asks the client whether the call to t with args may run and
+// returns an error explaining why not if it may not.
+func (s *Server) confirmCall(ctx context.Context, sess *session, t MCPTool, args map[string]interface{}) error {
+	if !sess.state.SupportsElicitation() {
+		return fmt.Errorf("the tool '%s' requires confirmation, but the client does not support elicitation", t.Name())
+	}
+	encoded, _ := json.MarshalIndent(args, "", "  ")
+	encoded = s.redactor.redactJSON(encoded)
+	params := map[string]interface{}{
+		"message": fmt.Sprintf("The tool '%s' may make destructive changes. Run it with these arguments?\n%s", t.Name(), encoded),
+		"requestedSchema": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"confirm": map[string]interface{}{
+					"type":        "boolean",
+					"title":       "Run " + t.Name(),
+					"description": "Allow this call to run",
+				},
+			},
+			"required": []string{"confirm"},
+		},
+	}
+	if s.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.requestTimeout)
+		defer cancel()
+	}
+	raw, err := sess.requests.call(ctx, sess.clientWriter(), "elicitation/create", params)
+	if err != nil {
+		return fmt.Errorf("the call to '%s' was not confirmed: %v", t.Name(), err)
+	}
+	var result struct {
+		Action  string `json:"action"`
+		Content struct {
+			Confirm bool `json:"confirm"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil || result.Action != "accept" || !result.Content.Confirm {
+		return fmt.Errorf("the call to '%s' was declined by the user", t.Name())
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-minimal-server-go/mcpmock"
+)
+
+// deleteTool is an unannotated tool, and therefore destructive.
+type deleteTool struct{}
+
+func (deleteTool) Name() string        { return "delete" }
+func (deleteTool) Description() string { return "Pretends to delete something" }
+func (deleteTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (deleteTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return []ToolContent{{Type: "text", Text: "deleted"}}, nil
+}
+
+// waitForOutput polls out until it contains n matches of re.
+func waitForOutput(t *testing.T, out *syncBuffer, re *regexp.Regexp, n int) [][]string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if m := re.FindAllStringSubmatch(out.String(), -1); len(m) >= n {
+			return m
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s in output:\n%s", re, out.String())
+	return nil
+}
+
+// Test that destructive calls run only after the client confirms them
+func TestConfirmDestructive(t *testing.T) {
+	s := NewServer(WithTools(&echoTool{}, deleteTool{}), WithConfirmDestructive())
+	pw, out, errc := serveInBackground(context.Background(), s)
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"safe"}},"id":2}`)
+	waitForOutput(t, out, regexp.MustCompile(`Echo: safe`), 1)
+
+	elicitation := regexp.MustCompile(`"id":"(srv-\d+)","jsonrpc":"2.0","method":"elicitation/create"`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"delete","arguments":{}},"id":3}`)
+	m := waitForOutput(t, out, elicitation, 1)
+	fmt.Fprintf(pw, `{"jsonrpc":"2.0","id":%q,"result":{"action":"accept","content":{"confirm":true}}}`+"\n", m[0][1])
+	waitForOutput(t, out, regexp.MustCompile(`"id":3,"jsonrpc":"2.0","result":\{"content":\[\{"type":"text","text":"deleted"`), 1)
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"delete","arguments":{}},"id":4}`)
+	m = waitForOutput(t, out, elicitation, 2)
+	fmt.Fprintf(pw, `{"jsonrpc":"2.0","id":%q,"result":{"action":"decline"}}`+"\n", m[1][1])
+	waitForOutput(t, out, regexp.MustCompile(`"id":4,.*declined by the user.*"isError":true`), 1)
+
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+	if strings.Contains(out.String(), `"error"`) {
+		t.Errorf("expected the responses not to be answered, got:\n%s", out.String())
+	}
+}
+
+// Test that destructive calls are refused when the client cannot confirm
+func TestConfirmDestructiveUnsupported(t *testing.T) {
+	s := NewServer(WithTools(deleteTool{}), WithConfirmDestructive())
+	input := `{"jsonrpc":"2.0","method":"initialize","params":{"capabilities":{}},"id":1}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"delete","arguments":{}},"id":2}` + "\n"
+	lines := runServerInput(t, s, input)
+	if len(lines) != 2 || !strings.Contains(lines[1], "does not support elicitation") || !strings.Contains(lines[1], `"isError":true`) {
+		t.Errorf("expected a refusal, got %v", lines)
+	}
+}
+
+// Test the confirmation round trip with a scripted client
+func TestConfirmDestructiveMock(t *testing.T) {
+	c := mcpmock.New(NewServer(WithTools(deleteTool{}), WithConfirmDestructive()).Serve,
+		mcpmock.Respond("elicitation/create", map[string]interface{}{"action": "accept", "content": map[string]bool{"confirm": true}}),
+		mcpmock.Expect("elicitation/create", 1),
+	)
+	ctx := context.Background()
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize error: %v", err)
+	}
+	result, err := c.CallTool(ctx, "delete", map[string]interface{}{"path": "/tmp/x"})
+	if err != nil || result.IsError || len(result.Content) != 1 || result.Content[0].Text != "deleted" {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+	if err := c.Verify(); err != nil {
+		t.Error(err)
+	}
+	if params := c.Requests("elicitation/create"); len(params) != 1 || !strings.Contains(string(params[0]), "/tmp/x") {
+		t.Errorf("expected the arguments in the elicitation, got %s", params)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+}
+
+// Test that a confirmation completes while the only worker is held by the
+// call awaiting it and another call waits for the worker
+func TestConfirmDestructiveOneWorker(t *testing.T) {
+	s := NewServer(WithTools(deleteTool{}), WithConfirmDestructive(), WithMaxWorkers(1))
+	pw, out, errc := serveInBackground(context.Background(), s)
+
+	elicitation := regexp.MustCompile(`"id":"(srv-\d+)","jsonrpc":"2.0","method":"elicitation/create"`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"delete","arguments":{}},"id":1}`)
+	m := waitForOutput(t, out, elicitation, 1)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"delete","arguments":{}},"id":2}`)
+	fmt.Fprintf(pw, `{"jsonrpc":"2.0","id":%q,"result":{"action":"accept","content":{"confirm":true}}}`+"\n", m[0][1])
+	waitForOutput(t, out, regexp.MustCompile(`"id":1,"jsonrpc":"2.0","result":\{"content":\[\{"type":"text","text":"deleted"`), 1)
+
+	m = waitForOutput(t, out, elicitation, 2)
+	fmt.Fprintf(pw, `{"jsonrpc":"2.0","id":%q,"result":{"action":"decline"}}`+"\n", m[1][1])
+	waitForOutput(t, out, regexp.MustCompile(`"id":2,.*declined by the user`), 1)
+
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+}
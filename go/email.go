@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// emailConfig configures the send_email tool in the "email" section of the
+// config file. The credentials stay here, out of the tool's arguments.
+type emailConfig struct {
+	Host           string     `json:"host"`
+	Port           int        `json:"port"`           // defaults to 465 for implicit TLS, otherwise 587
+	TLS            string     `json:"tls"`            // starttls (default), implicit, or none for a local relay
+	Username       string     `json:"username"`       // $VAR expands from the environment
+	Password       string     `json:"password"`       // $VAR expands from the environment
+	From           string     `json:"from"`           // e.g. "Alerts <alerts@example.com>"
+	AllowedDomains stringList `json:"allowedDomains"` // the only domains mail may be sent to
+}
+
+// validate reports missing or malformed fields.
+func (c *emailConfig) validate() error {
+	if c.Host == "" || c.From == "" {
+		return errors.New("email: host and from are required")
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("email: invalid from address: %w", err)
+	}
+	if len(c.AllowedDomains) == 0 {
+		return errors.New("email: allowedDomains must list the domains mail may be sent to")
+	}
+	switch c.TLS {
+	case "", "starttls", "implicit", "none":
+	default:
+		return fmt.Errorf("email: unknown tls mode %q", c.TLS)
+	}
+	return nil
+}
+
+// emailTimeout bounds a whole SMTP session unless the call's context ends
+// it sooner.
+const emailTimeout = 30 * time.Second
+
+// maxEmailRecipients bounds the recipients of one message.
+const maxEmailRecipients = 50
+
+// sendEmailTool sends plain text mail through the configured SMTP server
+// to recipients at the allowed domains.
+type sendEmailTool struct {
+	cfg       emailConfig
+	tlsConfig *tls.Config // overrides the defaults in tests
+}
+
+// newSendEmailTool returns the send_email tool for cfg.
+func newSendEmailTool(cfg emailConfig) *sendEmailTool {
+	return &sendEmailTool{cfg: cfg}
+}
+
+// sendEmailArgs are the arguments of the send_email tool.
+type sendEmailArgs struct {
+	To      []string `json:"to" description:"Addresses to send to"`
+	Cc      []string `json:"cc,omitempty" description:"Addresses to send copies to"`
+	Subject string   `json:"subject" description:"The subject line"`
+	Body    string   `json:"body" description:"The message as plain text"`
+}
+
+// Name returns the name of the send_email tool.
+func (t *sendEmailTool) Name() string {
+	return "send_email"
+}
+
+// Description returns a brief description of the send_email tool, naming
+// the domains it may send to.
+func (t *sendEmailTool) Description() string {
+	return "Sends a plain text email to addresses at " + strings.Join(t.cfg.AllowedDomains, ", ")
+}
+
+// InputSchema returns the JSON schema for the send_email tool's input
+// parameters.
+func (t *sendEmailTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(sendEmailArgs{})
+}
+
+// Annotations marks the send_email tool as reaching outside the server.
+// Sending adds a message without changing others, so it is not
+// destructive, but every call sends one more.
+func (t *sendEmailTool) Annotations() ToolAnnotations {
+	destructive := false
+	return ToolAnnotations{Title: "Send email", DestructiveHint: &destructive}
+}
+
+// Execute sends the message without a deadline of its own.
+func (t *sendEmailTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext checks the recipients against the allowed domains,
+// composes the message, and sends it.
+func (t *sendEmailTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	r, err := t.parse(args)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := composeEmail(r.from, r.to, r.cc, r.Subject, r.Body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	rcpts := emailAddresses(append(r.to, r.cc...))
+	if err := t.send(ctx, r.from.Address, rcpts, msg); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("sending failed: %v", err)
+	}
+	return []ToolContent{{Type: "text", Text: fmt.Sprintf("Sent %q to %s", r.Subject, strings.Join(rcpts, ", "))}}, nil
+}
+
+// DryRun checks the message like a call would and tells whom it would be
+// sent to, without sending it.
+func (t *sendEmailTool) DryRun(args map[string]interface{}) (string, error) {
+	r, err := t.parse(args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("would send %q (%d bytes of body) from %s to %s", r.Subject, len(r.Body), r.from.Address,
+		strings.Join(emailAddresses(append(r.to, r.cc...)), ", ")), nil
+}
+
+// emailRequest is a checked call of the send_email tool.
+type emailRequest struct {
+	sendEmailArgs
+	from   *mail.Address
+	to, cc []*mail.Address
+}
+
+// parse decodes and checks the arguments of a call.
+func (t *sendEmailTool) parse(args map[string]interface{}) (*emailRequest, error) {
+	var r emailRequest
+	if err := mcp.DecodeArgs(args, &r.sendEmailArgs); err != nil {
+		return nil, err
+	}
+	if len(r.To) == 0 {
+		return nil, errors.New("invalid value for 'to': at least one address is required")
+	}
+	if len(r.To)+len(r.Cc) > maxEmailRecipients {
+		return nil, fmt.Errorf("at most %d recipients are allowed", maxEmailRecipients)
+	}
+	if strings.ContainsAny(r.Subject, "\r\n") {
+		return nil, errors.New("invalid value for 'subject': line breaks are not allowed")
+	}
+	var err error
+	if r.to, err = t.recipients("to", r.To); err != nil {
+		return nil, err
+	}
+	if r.cc, err = t.recipients("cc", r.Cc); err != nil {
+		return nil, err
+	}
+	r.from, _ = mail.ParseAddress(t.cfg.From)
+	return &r, nil
+}
+
+// emailAddresses returns the bare addresses of addrs.
+func emailAddresses(addrs []*mail.Address) []string {
+	var list []string
+	for _, addr := range addrs {
+		list = append(list, addr.Address)
+	}
+	return list
+}
+
+// recipients parses the addresses of the named argument, refusing any at
+// a domain that is not allowed.
+func (t *sendEmailTool) recipients(arg string, list []string) ([]*mail.Address, error) {
+	var addrs []*mail.Address
+	for _, s := range list {
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for '%s': %q: %v", arg, s, err)
+		}
+		domain := strings.ToLower(addr.Address[strings.LastIndexByte(addr.Address, '@')+1:])
+		allowed := false
+		for _, d := range t.cfg.AllowedDomains {
+			allowed = allowed || strings.EqualFold(d, domain)
+		}
+		if !allowed {
+			return nil, fmt.Errorf("invalid value for '%s': %s is not at an allowed domain", arg, addr.Address)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// composeEmail returns the message in Internet Message Format, with the
+// body as quoted-printable UTF-8 and the subject encoded if it is not
+// ASCII. Bcc is not supported, so every recipient is in a header.
+func composeEmail(from *mail.Address, to, cc []*mail.Address, subject, body string, now time.Time) ([]byte, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	join := func(addrs []*mail.Address) string {
+		s := make([]string, len(addrs))
+		for i, a := range addrs {
+			s[i] = a.String()
+		}
+		return strings.Join(s, ", ")
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", join(to))
+	if len(cc) > 0 {
+		fmt.Fprintf(&b, "Cc: %s\r\n", join(cc))
+	}
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), from.Address[strings.LastIndexByte(from.Address, '@')+1:])
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n")
+	qp := quotedprintable.NewWriter(&b)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(body, "\r\n", "\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// send delivers msg to rcpts in one SMTP session, authenticating if a
+// username is configured. Without TLS, net/smtp sends credentials only to
+// a server on the loopback interface.
+func (t *sendEmailTool) send(ctx context.Context, from string, rcpts []string, msg []byte) error {
+	port := t.cfg.Port
+	if port == 0 {
+		port = 587
+		if t.cfg.TLS == "implicit" {
+			port = 465
+		}
+	}
+	addr := net.JoinHostPort(t.cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: t.cfg.Host}
+	if t.tlsConfig != nil {
+		tlsConfig = t.tlsConfig
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if t.cfg.TLS == "implicit" {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	// net/smtp has no contexts: end the session when ctx is done.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, t.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if t.cfg.TLS == "" || t.cfg.TLS == "starttls" {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if t.cfg.Username != "" {
+		auth := smtp.PlainAuth("", os.ExpandEnv(t.cfg.Username), os.ExpandEnv(t.cfg.Password), t.cfg.Host)
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, r := range rcpts {
+		if err := c.Rcpt(r); err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
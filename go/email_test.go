@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// testSMTPMessage is what testSMTPServer received in a session.
+type testSMTPMessage struct {
+	auth  string // the decoded AUTH PLAIN response
+	from  string
+	rcpts []string
+	data  string
+}
+
+// testSMTPServer accepts SMTP sessions on the loopback interface, without
+// TLS, and sends what each delivered on the returned channel. Recipients
+// at reject.example.com are refused.
+func testSMTPServer(t *testing.T) (host string, port int, received chan testSMTPMessage) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received = make(chan testSMTPMessage, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+				var m testSMTPMessage
+				reply("220 test ESMTP")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimRight(line, "\r\n")
+					verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+					switch {
+					case verb == "EHLO":
+						reply("250-test\r\n250 AUTH PLAIN")
+					case verb == "AUTH":
+						creds, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+						m.auth = string(creds)
+						reply("235 ok")
+					case verb == "MAIL":
+						m.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+						reply("250 ok")
+					case verb == "RCPT":
+						rcpt := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+						if strings.HasSuffix(rcpt, "@reject.example.com") {
+							reply("550 no such user")
+							continue
+						}
+						m.rcpts = append(m.rcpts, rcpt)
+						reply("250 ok")
+					case verb == "DATA":
+						reply("354 go ahead")
+						var data strings.Builder
+						for {
+							line, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if line == ".\r\n" {
+								break
+							}
+							data.WriteString(strings.TrimPrefix(line, "."))
+						}
+						m.data = data.String()
+						received <- m
+						reply("250 queued")
+					case verb == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("502 unknown")
+					}
+				}
+			}()
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, received
+}
+
+// Test sending a message through the configured server
+func TestSendEmail(t *testing.T) {
+	host, port, received := testSMTPServer(t)
+	t.Setenv("TEST_SMTP_PASSWORD", "s3cret")
+	tool := newSendEmailTool(emailConfig{
+		Host: host, Port: port, TLS: "none",
+		Username: "bot", Password: "$TEST_SMTP_PASSWORD",
+		From: "Alerts <alerts@example.com>", AllowedDomains: stringList{"example.com", "Example.org"},
+	})
+
+	content, err := tool.Execute(map[string]interface{}{
+		"to":      []interface{}{"Ann <ann@example.com>"},
+		"cc":      []interface{}{"bob@EXAMPLE.ORG"},
+		"subject": "Déploiement terminé",
+		"body":    "Done.\n.hidden line\nThé end",
+	})
+	if err != nil || !strings.Contains(content[0].Text, "ann@example.com, bob@EXAMPLE.ORG") {
+		t.Fatalf("unexpected result %v, %v", content, err)
+	}
+	m := <-received
+	if m.auth != "\x00bot\x00s3cret" || m.from != "alerts@example.com" || strings.Join(m.rcpts, ",") != "ann@example.com,bob@EXAMPLE.ORG" {
+		t.Errorf("unexpected envelope %+v", m)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(m.data))
+	if err != nil {
+		t.Fatalf("cannot parse the message %q: %v", m.data, err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "Déploiement terminé" {
+		t.Errorf("unexpected subject %q", msg.Header.Get("Subject"))
+	}
+	if msg.Header.Get("Cc") != "<bob@EXAMPLE.ORG>" || msg.Header.Get("Message-ID") == "" {
+		t.Errorf("unexpected headers %v", msg.Header)
+	}
+	body, _ := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if strings.TrimSuffix(string(body), "\r\n") != "Done.\r\n.hidden line\r\nThé end" {
+		t.Errorf("unexpected body %q", body)
+	}
+}
+
+// Test that recipients outside the allowed domains and malformed arguments
+// are refused before anything is sent, and that the server's refusals fail
+// the call
+func TestSendEmailErrors(t *testing.T) {
+	host, port, received := testSMTPServer(t)
+	tool := newSendEmailTool(emailConfig{Host: host, Port: port, TLS: "none", From: "alerts@example.com",
+		AllowedDomains: stringList{"example.com", "reject.example.com"}})
+	for _, args := range []map[string]interface{}{
+		{"to": []interface{}{"eve@evil.com"}, "subject": "s", "body": "b"},
+		{"to": []interface{}{"ann@example.com.evil.com"}, "subject": "s", "body": "b"},
+		{"to": []interface{}{"ann@example.com"}, "cc": []interface{}{"eve@sub.example.com"}, "subject": "s", "body": "b"},
+		{"to": []interface{}{"not an address"}, "subject": "s", "body": "b"},
+		{"to": []interface{}{}, "subject": "s", "body": "b"},
+		{"to": []interface{}{"ann@example.com"}, "subject": "s\r\nBcc: eve@evil.com", "body": "b"},
+	} {
+		if _, err := tool.Execute(args); err == nil {
+			t.Errorf("expected %v to be refused", args)
+		}
+	}
+	if len(received) != 0 {
+		t.Errorf("expected nothing to be sent, got %+v", <-received)
+	}
+
+	var failure *toolResultError
+	_, err := tool.Execute(map[string]interface{}{"to": []interface{}{"x@reject.example.com"}, "subject": "s", "body": "b"})
+	if !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "550") {
+		t.Errorf("expected the refusal as an error result, got %v", err)
+	}
+
+}
+
+// Test the validation of the email section of the config file
+func TestEmailConfig(t *testing.T) {
+	valid := emailConfig{Host: "smtp.example.com", From: "alerts@example.com", AllowedDomains: stringList{"example.com"}}
+	if err := valid.validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for name, c := range map[string]emailConfig{
+		"no host":    {From: valid.From, AllowedDomains: valid.AllowedDomains},
+		"bad from":   {Host: valid.Host, From: "nobody", AllowedDomains: valid.AllowedDomains},
+		"no domains": {Host: valid.Host, From: valid.From},
+		"bad tls":    {Host: valid.Host, From: valid.From, AllowedDomains: valid.AllowedDomains, TLS: "ssl"},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("expected the config with %s to be rejected", name)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of the find_symbol tool.
+const (
+	defaultSymbolResults = 100
+	maxSymbolResults     = 1000
+)
+
+// findSymbolTool finds where Go functions, types, methods, fields,
+// variables, and constants are defined and used in a module. References
+// are found by name, without type checking: a method reference is any
+// selector with the method's name.
+type findSymbolTool struct {
+	dir string
+}
+
+// findSymbolArgs are the arguments of the find_symbol tool.
+type findSymbolArgs struct {
+	Name       string `json:"name" description:"The symbol: a name like NewServer, a method or field like Server.Serve, or a name qualified by its package like mcp.SchemaFor"`
+	Kind       string `json:"kind,omitempty" enum:"func,method,type,field,var,const" description:"Only definitions of this kind (default any)"`
+	References *bool  `json:"references,omitempty" description:"Also find the references to the symbol (default true)"`
+	Limit      int    `json:"limit,omitempty" minimum:"1" maximum:"1000" description:"Most definitions and most references to return (default 100)"`
+}
+
+// Name returns the name of the find_symbol tool.
+func (t *findSymbolTool) Name() string {
+	return "find_symbol"
+}
+
+// Description returns a brief description of the find_symbol tool.
+func (t *findSymbolTool) Description() string {
+	return "Finds the definitions of a Go function, type, method, field, variable, or constant in the module " + filepath.Base(t.dir) + ", and the references to it by name, with file and line"
+}
+
+// InputSchema returns the JSON schema for the find_symbol tool's input
+// parameters.
+func (t *findSymbolTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(findSymbolArgs{})
+}
+
+// Annotations marks the find_symbol tool as read-only.
+func (t *findSymbolTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Find Go symbol")
+}
+
+// symbolDefinition is where a symbol is defined.
+type symbolDefinition struct {
+	Name      string `json:"name"` // qualified by its receiver or type for methods and fields
+	Kind      string `json:"kind"`
+	Package   string `json:"package"`
+	File      string `json:"file"` // relative to the module, with slashes
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	Signature string `json:"signature,omitempty"`
+	Doc       string `json:"doc,omitempty"` // the first paragraph
+}
+
+// symbolReference is a use of a symbol's name.
+type symbolReference struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Text   string `json:"text"` // the line, trimmed
+}
+
+// findSymbolResult is the result of the find_symbol tool.
+type findSymbolResult struct {
+	Definitions []symbolDefinition `json:"definitions"`
+	References  []symbolReference  `json:"references,omitempty"`
+	Truncated   bool               `json:"truncated,omitempty"`
+}
+
+// Execute searches the module without a deadline of its own.
+func (t *findSymbolTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext parses the module's Go files and returns the definitions
+// of and references to the symbol as indented JSON text. Directories that
+// the go command ignores (testdata, vendor, and names starting with . or
+// _) and nested modules are skipped.
+func (t *findSymbolTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	a := findSymbolArgs{Limit: defaultSymbolResults}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Limit < 1 || a.Limit > maxSymbolResults {
+		return nil, fmt.Errorf("invalid value for 'limit'")
+	}
+	switch a.Kind {
+	case "", "func", "method", "type", "field", "var", "const":
+	default:
+		return nil, fmt.Errorf("invalid value for 'kind'")
+	}
+	qualifier, name, qualified := strings.Cut(strings.TrimSpace(a.Name), ".")
+	if !qualified {
+		qualifier, name = "", qualifier
+	}
+	if !token.IsIdentifier(name) || qualified && !token.IsIdentifier(qualifier) {
+		return nil, fmt.Errorf("invalid value for 'name': %q is not a Go name, Type.Name, or package.Name", a.Name)
+	}
+	s := &symbolSearch{qualifier: qualifier, name: name, kind: a.Kind, references: a.References == nil || *a.References, limit: a.Limit}
+	s.result.Definitions = []symbolDefinition{}
+	err := filepath.WalkDir(t.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			base := d.Name()
+			if path != t.dir && (base == "testdata" || base == "vendor" || strings.HasPrefix(base, ".") || strings.HasPrefix(base, "_")) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil && path != t.dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, _ := filepath.Rel(t.dir, path)
+		return s.searchFile(path, filepath.ToSlash(rel))
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("%v", err)
+	}
+	encoded, err := json.MarshalIndent(s.result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
+
+// symbolSearch collects the definitions of and references to a symbol.
+type symbolSearch struct {
+	qualifier, name string // the qualifier is a type or package name, or empty
+	kind            string
+	references      bool
+	limit           int
+	result          findSymbolResult
+}
+
+// searchFile adds the definitions and references in a file. Files that
+// do not parse are skipped.
+func (s *symbolSearch) searchFile(path, rel string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	pkg := file.Name.Name
+	defined := map[*ast.Ident]bool{}
+	define := func(id *ast.Ident, container, kind, signature string, doc *ast.CommentGroup) {
+		defined[id] = true
+		if id.Name != s.name || s.kind != "" && s.kind != kind {
+			return
+		}
+		if s.qualifier != "" && s.qualifier != container && (s.qualifier != pkg || container != "") {
+			return
+		}
+		if len(s.result.Definitions) >= s.limit {
+			s.result.Truncated = true
+			return
+		}
+		name := id.Name
+		if container != "" {
+			name = container + "." + name
+		}
+		pos := fset.Position(id.Pos())
+		s.result.Definitions = append(s.result.Definitions, symbolDefinition{
+			Name: name, Kind: kind, Package: pkg, File: rel, Line: pos.Line, Column: pos.Column,
+			Signature: signature, Doc: firstParagraph(doc),
+		})
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			kind, container := "func", ""
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				kind, container = "method", receiverTypeName(decl.Recv.List[0].Type)
+			}
+			header := *decl
+			header.Doc, header.Body = nil, nil
+			define(decl.Name, container, kind, nodeString(fset, &header), decl.Doc)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					doc := spec.Doc
+					if doc == nil && len(decl.Specs) == 1 {
+						doc = decl.Doc
+					}
+					define(spec.Name, "", "type", "type "+spec.Name.Name+" "+typeSummary(fset, spec.Type), doc)
+					s.defineMembers(spec, define, fset)
+				case *ast.ValueSpec:
+					kind := "var"
+					if decl.Tok == token.CONST {
+						kind = "const"
+					}
+					doc := spec.Doc
+					if doc == nil && len(decl.Specs) == 1 {
+						doc = decl.Doc
+					}
+					for _, id := range spec.Names {
+						define(id, "", kind, "", doc)
+					}
+				}
+			}
+		}
+	}
+	if !s.references {
+		return nil
+	}
+
+	// pkg.Name names a package's symbol; x.Name may be a method or field
+	// of any type.
+	selected, skipped := map[*ast.Ident]bool{}, map[*ast.Ident]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == s.name {
+			selected[sel.Sel] = true
+			if x, ok := sel.X.(*ast.Ident); ok && s.qualifier != "" && x.Name != s.qualifier && isImportName(file, x.Name) {
+				skipped[sel.Sel] = true
+			}
+		}
+		return true
+	})
+	defined[file.Name] = true
+	lines := bytes.Split(src, []byte("\n"))
+	ast.Inspect(file, func(n ast.Node) bool {
+		id, ok := n.(*ast.Ident)
+		if !ok || id.Name != s.name || defined[id] || skipped[id] {
+			return true
+		}
+		// Unqualified names only refer to a package-qualified symbol
+		// inside its package.
+		if !selected[id] && s.qualifier != "" && s.qualifier != pkg {
+			return true
+		}
+		if len(s.result.References) >= s.limit {
+			s.result.Truncated = true
+			return false
+		}
+		pos := fset.Position(id.Pos())
+		text := ""
+		if pos.Line-1 < len(lines) {
+			text = strings.TrimSpace(string(lines[pos.Line-1]))
+		}
+		s.result.References = append(s.result.References, symbolReference{File: rel, Line: pos.Line, Column: pos.Column, Text: text})
+		return true
+	})
+	return nil
+}
+
+// defineMembers defines the fields of a struct type and the methods of an
+// interface type.
+func (s *symbolSearch) defineMembers(spec *ast.TypeSpec, define func(*ast.Ident, string, string, string, *ast.CommentGroup), fset *token.FileSet) {
+	var fields *ast.FieldList
+	kind := "field"
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		fields = t.Fields
+	case *ast.InterfaceType:
+		fields, kind = t.Methods, "method"
+	default:
+		return
+	}
+	for _, f := range fields.List {
+		doc := f.Doc
+		if doc == nil {
+			doc = f.Comment
+		}
+		for _, id := range f.Names {
+			define(id, spec.Name.Name, kind, id.Name+" "+nodeString(fset, f.Type), doc)
+		}
+	}
+}
+
+// isImportName reports whether name is the name of a package file
+// imports.
+func isImportName(file *ast.File, name string) bool {
+	for _, imp := range file.Imports {
+		if imp.Name != nil {
+			if imp.Name.Name == name {
+				return true
+			}
+			continue
+		}
+		path := strings.Trim(imp.Path.Value, `"`)
+		if path[strings.LastIndexByte(path, '/')+1:] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// receiverTypeName returns the name of a method receiver's type, without
+// pointers and type parameters.
+func receiverTypeName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// typeSummary returns a type's definition, abbreviating struct and
+// interface types to their keyword.
+func typeSummary(fset *token.FileSet, expr ast.Expr) string {
+	switch expr.(type) {
+	case *ast.StructType:
+		return "struct"
+	case *ast.InterfaceType:
+		return "interface"
+	}
+	return nodeString(fset, expr)
+}
+
+// nodeString prints a syntax tree node as Go source.
+func nodeString(fset *token.FileSet, node interface{}) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// firstParagraph returns the first paragraph of a doc comment, on one
+// line.
+func firstParagraph(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	text := doc.Text()
+	if i := strings.Index(text, "\n\n"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
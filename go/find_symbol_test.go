@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// writeSymbolModule writes a module with two packages, a vendored copy
+// the search must skip, and a file that does not parse.
+func writeSymbolModule(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod": "module example.com/zoo\n\ngo 1.21\n",
+		"zoo.go": `package zoo
+
+import "example.com/zoo/keeper"
+
+// Animal is an animal of the zoo.
+//
+// Animals are fed daily.
+type Animal struct {
+	Name string // what visitors call it
+}
+
+// Feed feeds the animal.
+func (a *Animal) Feed(food string) error {
+	return keeper.Feed(a.Name, food)
+}
+
+// Feeder is anything that eats.
+type Feeder interface {
+	Feed(food string) error
+}
+`,
+		"keeper/keeper.go": `package keeper
+
+// Feed records a feeding.
+func Feed(name, food string) error {
+	return nil
+}
+
+const maxMeals = 3
+
+func schedule() { _ = Feed("lion", "meat") }
+`,
+		"vendor/other/other.go": "package other\n\nfunc Feed() {}\n",
+		"broken.go":             "package zoo\n\nfunc Feed( {\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Test finding definitions and references by plain and qualified names
+func TestFindSymbol(t *testing.T) {
+	tool := &findSymbolTool{dir: writeSymbolModule(t)}
+	search := func(args map[string]interface{}) findSymbolResult {
+		t.Helper()
+		content, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		var result findSymbolResult
+		if err := json.Unmarshal([]byte(content[0].Text), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	locations := func(defs []symbolDefinition) string {
+		var parts []string
+		for _, d := range defs {
+			parts = append(parts, d.Kind+" "+d.Name+" "+d.File+":"+strconv.Itoa(d.Line))
+		}
+		return strings.Join(parts, ", ")
+	}
+	refLocations := func(refs []symbolReference) string {
+		var parts []string
+		for _, r := range refs {
+			parts = append(parts, r.File+":"+strconv.Itoa(r.Line))
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	all := search(map[string]interface{}{"name": "Feed"})
+	if got, expected := locations(all.Definitions), "func Feed keeper/keeper.go:4, method Animal.Feed zoo.go:13, method Feeder.Feed zoo.go:19"; got != expected {
+		t.Errorf("expected definitions %s, got %s", expected, got)
+	}
+	if got, expected := refLocations(all.References), "keeper/keeper.go:10, zoo.go:14"; got != expected {
+		t.Errorf("expected references %s, got %s", expected, got)
+	}
+
+	method := search(map[string]interface{}{"name": "Animal.Feed"})
+	if len(method.Definitions) != 1 {
+		t.Fatalf("expected one definition, got %+v", method.Definitions)
+	}
+	def := method.Definitions[0]
+	if def.Signature != "func (a *Animal) Feed(food string) error" || def.Doc != "Feed feeds the animal." || def.Package != "zoo" || def.Column != 18 {
+		t.Errorf("unexpected definition %+v", def)
+	}
+	// The call in zoo.go is keeper.Feed, not a method.
+	if len(method.References) != 0 {
+		t.Errorf("expected no references, got %+v", method.References)
+	}
+
+	pkgFunc := search(map[string]interface{}{"name": "keeper.Feed", "references": false})
+	if got := locations(pkgFunc.Definitions); got != "func Feed keeper/keeper.go:4" || pkgFunc.References != nil {
+		t.Errorf("unexpected result %s, %+v", got, pkgFunc.References)
+	}
+	pkgRefs := search(map[string]interface{}{"name": "keeper.Feed"})
+	if got := refLocations(pkgRefs.References); got != "keeper/keeper.go:10, zoo.go:14" {
+		t.Errorf("unexpected references %s", got)
+	}
+	if pkgRefs.References[1].Text != "return keeper.Feed(a.Name, food)" {
+		t.Errorf("unexpected reference text %q", pkgRefs.References[1].Text)
+	}
+
+	animal := search(map[string]interface{}{"name": "Animal", "kind": "type"})
+	if len(animal.Definitions) != 1 || animal.Definitions[0].Signature != "type Animal struct" || animal.Definitions[0].Doc != "Animal is an animal of the zoo." {
+		t.Errorf("unexpected definitions %+v", animal.Definitions)
+	}
+	field := search(map[string]interface{}{"name": "Animal.Name"})
+	if got := locations(field.Definitions); got != "field Animal.Name zoo.go:9" || field.Definitions[0].Doc != "what visitors call it" {
+		t.Errorf("unexpected definitions %+v", field.Definitions)
+	}
+	if got := locations(search(map[string]interface{}{"name": "maxMeals"}).Definitions); got != "const maxMeals keeper/keeper.go:8" {
+		t.Errorf("unexpected definitions %s", got)
+	}
+
+	limited := search(map[string]interface{}{"name": "Feed", "limit": 1})
+	if len(limited.Definitions) != 1 || len(limited.References) != 1 || !limited.Truncated {
+		t.Errorf("expected truncated results, got %+v", limited)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"name": "a.b.c"},
+		{"name": "1x"},
+		{"name": "Feed", "kind": "package"},
+		{"name": "Feed", "limit": 0},
+	} {
+		if _, err := tool.Execute(args); err == nil || !strings.HasPrefix(err.Error(), "invalid value for") {
+			t.Errorf("%v: expected an invalid value error, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// formatterTimeout bounds a run of an external formatter.
+const formatterTimeout = 30 * time.Second
+
+// formatCodeTool formats source code: Go with gofmt or gofumpt and JSON
+// built in, and other languages with the formatter commands of the
+// config file's "formatters" section.
+type formatCodeTool struct {
+	formatters map[string][]string // language to command line
+}
+
+// withFormatters returns a copy of list in which format_code also uses
+// the given formatter commands.
+func withFormatters(list []MCPTool, formatters map[string][]string) []MCPTool {
+	out := make([]MCPTool, len(list))
+	for i, t := range list {
+		if _, ok := t.(*formatCodeTool); ok {
+			t = &formatCodeTool{formatters: formatters}
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// validateFormatters reports formatters without a command.
+func validateFormatters(formatters map[string][]string) error {
+	for _, lang := range sortedKeys(formatters) {
+		if len(formatters[lang]) == 0 || formatters[lang][0] == "" {
+			return fmt.Errorf("formatter %q without a command", lang)
+		}
+		if lang != strings.ToLower(lang) {
+			return fmt.Errorf("formatter %q: languages must be lowercase", lang)
+		}
+	}
+	return nil
+}
+
+// formatCodeArgs are the arguments of the format_code tool.
+type formatCodeArgs struct {
+	Language string `json:"language" description:"The language of the source, such as go or json"`
+	Source   string `json:"source" description:"The source code to format"`
+	Gofumpt  bool   `json:"gofumpt,omitempty" description:"For Go, apply gofumpt's stricter rules instead of gofmt's (needs gofumpt installed)"`
+}
+
+// Name returns the name of the format_code tool.
+func (t *formatCodeTool) Name() string {
+	return "format_code"
+}
+
+// Description returns a brief description of the format_code tool, naming
+// its languages.
+func (t *formatCodeTool) Description() string {
+	return "Formats source code and reports whether formatting changed it. Languages: " + strings.Join(t.languages(), ", ")
+}
+
+// languages returns the languages the tool formats, sorted.
+func (t *formatCodeTool) languages() []string {
+	langs := []string{"go", "json"}
+	for lang := range t.formatters {
+		if lang != "go" && lang != "json" {
+			langs = append(langs, lang)
+		}
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// InputSchema returns the JSON schema for the format_code tool's input
+// parameters.
+func (t *formatCodeTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(formatCodeArgs{})
+}
+
+// Annotations marks the format_code tool as read-only. It returns the
+// formatted source rather than writing any file.
+func (t *formatCodeTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Format code")
+}
+
+// Timeout bounds a call, which may run an external formatter.
+func (t *formatCodeTool) Timeout() time.Duration {
+	return formatterTimeout
+}
+
+// formatResult describes the formatting of a source.
+type formatResult struct {
+	Language  string `json:"language"`
+	Formatter string `json:"formatter"`
+	Changed   bool   `json:"changed"`
+}
+
+// Execute formats the source without a deadline of its own.
+func (t *formatCodeTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext formats the source and returns two texts: a JSON object
+// naming the formatter and whether the source changed, then the formatted
+// source. A source the formatter cannot parse fails the call. A configured
+// formatter replaces the built-in one of its language.
+func (t *formatCodeTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a formatCodeArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	lang := strings.ToLower(strings.TrimSpace(a.Language))
+	if lang == "golang" {
+		lang = "go"
+	}
+	result := formatResult{Language: lang}
+	var formatted string
+	var err error
+	switch command, ok := t.formatters[lang]; {
+	case lang == "go" && a.Gofumpt:
+		result.Formatter = "gofumpt"
+		if _, lookErr := exec.LookPath("gofumpt"); lookErr != nil {
+			return nil, toolFailure("gofumpt is not installed")
+		}
+		formatted, err = runFormatter(ctx, []string{"gofumpt"}, a.Source)
+	case ok:
+		result.Formatter = command[0]
+		formatted, err = runFormatter(ctx, command, a.Source)
+	case lang == "go":
+		result.Formatter = "gofmt"
+		var out []byte
+		if out, err = format.Source([]byte(a.Source)); err != nil {
+			err = toolFailure("go: %v", err)
+		}
+		formatted = string(out)
+	case lang == "json":
+		result.Formatter = "json"
+		formatted, err = formatJSON(a.Source)
+	default:
+		return nil, fmt.Errorf("invalid value for 'language': no formatter for %q; languages are %s", a.Language, strings.Join(t.languages(), ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Changed = formatted != a.Source
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}, {Type: "text", Text: formatted}}, nil
+}
+
+// formatJSON indents JSON by two spaces, keeping the order of keys and
+// the representation of numbers, and ends it with a newline.
+func formatJSON(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(source), "", "  "); err != nil {
+		return "", toolFailure("json: %v", err)
+	}
+	buf.WriteByte('\n')
+	return buf.String(), nil
+}
+
+// runFormatter runs a formatter command with the source on its standard
+// input and returns its standard output. It gets the server's PATH and
+// HOME only.
+func runFormatter(ctx context.Context, command []string, source string) (string, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	for _, name := range []string{"PATH", "HOME"} {
+		if v, ok := os.LookupEnv(name); ok {
+			cmd.Env = append(cmd.Env, name+"="+v)
+		}
+	}
+	cmd.Stdin = strings.NewReader(source)
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", toolFailure("%s: %v", command[0], err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", toolFailure("%s exited with status %d: %s", command[0], exitErr.ExitCode(), msg)
+		}
+		return "", toolFailure("%s exited with status %d", command[0], exitErr.ExitCode())
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// Test the built-in formatters and configured formatter commands
+func TestFormatCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to run formatter commands")
+	}
+	tool := withFormatters([]MCPTool{&formatCodeTool{}}, map[string][]string{
+		"sql":  {"sh", "-c", "tr a-z A-Z"},
+		"yaml": {"sh", "-c", "echo 'line 2: mapping values are not allowed' >&2; exit 3"},
+	})[0].(*formatCodeTool)
+	if !strings.HasSuffix(tool.Description(), "Languages: go, json, sql, yaml") {
+		t.Errorf("unexpected description %q", tool.Description())
+	}
+
+	for _, tc := range []struct {
+		language, source, expected string
+		formatter                  string
+	}{
+		{"go", "package main\nfunc main(){\nx:=1\n_ = x}\n", "package main\n\nfunc main() {\n\tx := 1\n\t_ = x\n}\n", "gofmt"},
+		{"Go", "package main\n", "package main\n", "gofmt"},
+		{"json", `{"b":[1,2.50],"a":{}}`, "{\n  \"b\": [\n    1,\n    2.50\n  ],\n  \"a\": {}\n}\n", "json"},
+		{"sql", "select 1;\n", "SELECT 1;\n", "sh"},
+	} {
+		content, err := tool.Execute(map[string]interface{}{"language": tc.language, "source": tc.source})
+		if err != nil {
+			t.Errorf("%s: %v", tc.language, err)
+			continue
+		}
+		meta := fmt.Sprintf("\"formatter\": %q,\n  \"changed\": %t", tc.formatter, tc.source != tc.expected)
+		if len(content) != 2 || !strings.Contains(content[0].Text, meta) || content[1].Text != tc.expected {
+			t.Errorf("%s: expected %s and %q, got %v", tc.language, meta, tc.expected, content)
+		}
+	}
+
+	for _, tc := range []struct{ language, source, message string }{
+		{"go", "package main\nfunc {", "go: "},
+		{"json", "{'a': 1}", "json: "},
+		{"yaml", "a: b: c\n", "sh exited with status 3: line 2"},
+	} {
+		_, err := tool.Execute(map[string]interface{}{"language": tc.language, "source": tc.source})
+		var failure *toolResultError
+		if !errors.As(err, &failure) || !strings.HasPrefix(failure.content[0].Text, tc.message) {
+			t.Errorf("%s: expected a tool failure starting %q, got %v", tc.language, tc.message, err)
+		}
+	}
+	if _, err := tool.Execute(map[string]interface{}{"language": "cobol", "source": "x"}); err == nil || !strings.Contains(err.Error(), "languages are go, json, sql, yaml") {
+		t.Errorf("expected the languages in the error, got %v", err)
+	}
+	if err := validateFormatters(map[string][]string{"toml": {}}); err == nil {
+		t.Error("expected an error for a formatter without a command")
+	}
+}
+
+// Test formatting Go with gofumpt when it is installed
+func TestFormatCodeGofumpt(t *testing.T) {
+	if _, err := exec.LookPath("gofumpt"); err != nil {
+		t.Skip("gofumpt is not installed")
+	}
+	content, err := (&formatCodeTool{}).Execute(map[string]interface{}{"language": "go", "source": "package main\nfunc main() {\n\n\tprintln()\n}\n", "gofumpt": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content[1].Text != "package main\n\nfunc main() {\n\tprintln()\n}\n" {
+		t.Errorf("unexpected result %q", content[1].Text)
+	}
+}
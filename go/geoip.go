@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"strings"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// geoipTool looks up addresses in local MaxMind DB databases, such as
+// GeoLite2-City and GeoLite2-ASN, and merges what they know about each.
+type geoipTool struct {
+	dbs   []*mmdbReader
+	names []string // of the database files, for the description
+}
+
+// newGeoIPTool opens the databases at paths.
+func newGeoIPTool(paths []string) (*geoipTool, error) {
+	t := &geoipTool{}
+	for _, p := range paths {
+		db, err := openMMDB(p)
+		if err != nil {
+			return nil, fmt.Errorf("geoip: %w", err)
+		}
+		t.dbs = append(t.dbs, db)
+		name := db.databaseType
+		if name == "" {
+			name = filepath.Base(p)
+		}
+		t.names = append(t.names, name)
+	}
+	return t, nil
+}
+
+// geoipArgs are the arguments of the geoip tool.
+type geoipArgs struct {
+	IP string `json:"ip" description:"The IPv4 or IPv6 address to look up"`
+}
+
+// Name returns the name of the geoip tool.
+func (t *geoipTool) Name() string {
+	return "geoip"
+}
+
+// Description returns a brief description of the geoip tool, naming its
+// databases.
+func (t *geoipTool) Description() string {
+	return "Looks up the country, city, and autonomous system (ASN) of an IP address in local databases (" + strings.Join(t.names, ", ") + ")"
+}
+
+// InputSchema returns the JSON schema for the geoip tool's input
+// parameters.
+func (t *geoipTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(geoipArgs{})
+}
+
+// Annotations marks the geoip tool as read-only. The databases are local,
+// so it does not reach outside the server.
+func (t *geoipTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("GeoIP lookup")
+}
+
+// geoipLocation is where an address is, as far as the databases know.
+type geoipLocation struct {
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	AccuracyRadius uint64  `json:"accuracyRadiusKm,omitempty"`
+	TimeZone       string  `json:"timeZone,omitempty"`
+}
+
+// geoipResult is the result of the geoip tool.
+type geoipResult struct {
+	IP           string         `json:"ip"`
+	Found        bool           `json:"found"`
+	Network      string         `json:"network,omitempty"` // of the first database to know the address
+	Continent    string         `json:"continent,omitempty"`
+	CountryCode  string         `json:"countryCode,omitempty"`
+	Country      string         `json:"country,omitempty"`
+	Subdivision  string         `json:"subdivision,omitempty"`
+	City         string         `json:"city,omitempty"`
+	PostalCode   string         `json:"postalCode,omitempty"`
+	Location     *geoipLocation `json:"location,omitempty"`
+	ASN          uint64         `json:"asn,omitempty"`
+	Organization string         `json:"organization,omitempty"`
+	Private      bool           `json:"private,omitempty"` // not routed on the internet, so in no database
+}
+
+// Execute looks the address up in every database and returns what they
+// know as indented JSON text.
+func (t *geoipTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	var a geoipArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	ip, err := netip.ParseAddr(strings.TrimSpace(a.IP))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for 'ip': %q is not an IP address", a.IP)
+	}
+	ip = ip.Unmap().WithZone("")
+	result := geoipResult{IP: ip.String()}
+	result.Private = ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast()
+	for _, db := range t.dbs {
+		if ip.Is6() && db.ipVersion == 4 {
+			continue
+		}
+		value, bits, err := db.lookup(ip)
+		if err != nil {
+			return nil, toolFailure("%s: %v", db.databaseType, err)
+		}
+		record, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if !result.Found {
+			result.Found = true
+			prefix, _ := ip.Prefix(bits)
+			result.Network = prefix.String()
+		}
+		result.merge(record)
+	}
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
+
+// merge fills in the fields of r that are still empty from a record of a
+// GeoIP2 or GeoLite2 City, Country, ASN, or ISP database.
+func (r *geoipResult) merge(record map[string]interface{}) {
+	set := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	country := geoipMap(record, "country")
+	if country == nil {
+		country = geoipMap(record, "registered_country")
+	}
+	set(&r.CountryCode, geoipString(country, "iso_code"))
+	set(&r.Country, geoipName(country))
+	set(&r.Continent, geoipName(geoipMap(record, "continent")))
+	set(&r.City, geoipName(geoipMap(record, "city")))
+	set(&r.PostalCode, geoipString(geoipMap(record, "postal"), "code"))
+	if subs, ok := record["subdivisions"].([]interface{}); ok && len(subs) > 0 {
+		sub, _ := subs[0].(map[string]interface{})
+		set(&r.Subdivision, geoipName(sub))
+	}
+	if loc := geoipMap(record, "location"); loc != nil && r.Location == nil {
+		lat, okLat := loc["latitude"].(float64)
+		lon, okLon := loc["longitude"].(float64)
+		if okLat && okLon {
+			r.Location = &geoipLocation{Latitude: lat, Longitude: lon, TimeZone: geoipString(loc, "time_zone")}
+			r.Location.AccuracyRadius, _ = loc["accuracy_radius"].(uint64)
+		}
+	}
+	if asn, ok := record["autonomous_system_number"].(uint64); ok && r.ASN == 0 {
+		r.ASN = asn
+	}
+	set(&r.Organization, geoipString(record, "autonomous_system_organization"))
+	set(&r.Organization, geoipString(record, "organization"))
+	set(&r.Organization, geoipString(record, "isp"))
+}
+
+// geoipMap returns the map under key in m, or nil.
+func geoipMap(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+// geoipString returns the string under key in m, or "".
+func geoipString(m map[string]interface{}, key string) string {
+	v, _ := m[key].(string)
+	return v
+}
+
+// geoipName returns the English name of a place record.
+func geoipName(m map[string]interface{}) string {
+	return geoipString(geoipMap(m, "names"), "en")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestMMDB writes a test database to a file and returns its path.
+func writeTestMMDB(t *testing.T, name, dbType string, networks []testMMDBNetwork) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, buildTestMMDB(t, 28, dbType, networks), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Test merging what a City and an ASN database know about addresses
+func TestGeoIP(t *testing.T) {
+	city := writeTestMMDB(t, "city.mmdb", "GeoLite2-City", testCityNetworks)
+	asn := writeTestMMDB(t, "asn.mmdb", "GeoLite2-ASN", []testMMDBNetwork{
+		{"81.2.0.0/16", map[string]interface{}{"autonomous_system_number": uint64(20712), "autonomous_system_organization": "Andrews & Arnold Ltd"}},
+	})
+	tool, err := newGeoIPTool([]string{city, asn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tool.Description(), "GeoLite2-City, GeoLite2-ASN") {
+		t.Errorf("expected the databases in the description, got %q", tool.Description())
+	}
+
+	for ip, expected := range map[string]geoipResult{
+		"81.2.69.160": {
+			IP: "81.2.69.160", Found: true, Network: "81.2.69.0/24", CountryCode: "GB", Country: "United Kingdom", City: "London",
+			Location: &geoipLocation{Latitude: 51.5142, Longitude: -0.0931, AccuracyRadius: 10, TimeZone: "Europe/London"},
+			ASN:      20712, Organization: "Andrews & Arnold Ltd",
+		},
+		"81.2.1.1":      {IP: "81.2.1.1", Found: true, Network: "81.2.0.0/16", ASN: 20712, Organization: "Andrews & Arnold Ltd"},
+		" 2001:db8::5 ": {IP: "2001:db8::5", Found: true, Network: "2001:db8::/32", CountryCode: "SE", Country: "Sweden"},
+		"192.168.1.1":   {IP: "192.168.1.1", Private: true},
+	} {
+		content, err := tool.Execute(map[string]interface{}{"ip": ip})
+		if err != nil {
+			t.Fatalf("%s: %v", ip, err)
+		}
+		var got geoipResult
+		if err := json.Unmarshal([]byte(content[0].Text), &got); err != nil {
+			t.Fatal(err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		expectedJSON, _ := json.Marshal(expected)
+		if string(gotJSON) != string(expectedJSON) {
+			t.Errorf("%s: expected %s, got %s", ip, expectedJSON, gotJSON)
+		}
+	}
+	if _, err := tool.Execute(map[string]interface{}{"ip": "example.com"}); err == nil {
+		t.Error("expected an error for a host name")
+	}
+}
+
+// Test that unreadable databases fail at startup
+func TestGeoIPDatabases(t *testing.T) {
+	bad := filepath.Join(t.TempDir(), "bad.mmdb")
+	os.WriteFile(bad, []byte("not a database"), 0o644)
+	for _, path := range []string{bad, filepath.Join(t.TempDir(), "missing.mmdb")} {
+		if _, err := newGeoIPTool([]string{path}); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
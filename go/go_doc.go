@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of the tools that run the go command.
+const (
+	goCommandTimeout = time.Minute
+	maxGoOutput      = 1 << 20
+)
+
+// goModuleTools returns the tools that work on the Go module in dir.
+func goModuleTools(dir string) ([]MCPTool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); err != nil {
+		return nil, fmt.Errorf("go module: %s has no go.mod", dir)
+	}
+	return []MCPTool{&goDocTool{dir: dir}, &findSymbolTool{dir: dir}, &goModulesTool{dir: dir}}, nil
+}
+
+// runGo runs the go command with args in dir and returns its standard
+// output, at most maxGoOutput bytes of it, and whether it was cut short.
+// A failing command fails the call with its standard error.
+func runGo(ctx context.Context, dir string, args ...string) (string, bool, error) {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	// Never download a newer toolchain the module asks for during a call.
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	cmd.WaitDelay = time.Second
+	stdout := &limitedBuffer{limit: maxGoOutput}
+	var stderr bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", false, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return "", false, fmt.Errorf("go %s: %v", args[0], err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", false, toolFailure("go %s: %s", args[0], msg)
+		}
+		return "", false, toolFailure("go %s exited with status %d", args[0], exitErr.ExitCode())
+	}
+	return stdout.String(), stdout.truncated, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and discards
+// the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write keeps what fits in the buffer and reports the whole of p written,
+// so that the writer is not stopped.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.Buffer.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// goDocTool shows the documentation of packages and symbols in a Go
+// module and its dependencies with go doc.
+type goDocTool struct {
+	dir string
+}
+
+// goDocArgs are the arguments of the go_doc tool.
+type goDocArgs struct {
+	Query      string `json:"query,omitempty" description:"What to document, as for go doc: a package (net/http, or ./internal/x in the module), a symbol (http.Client, or Client in the module's root package), or a method (http.Client.Do). Empty documents the module's root package"`
+	All        bool   `json:"all,omitempty" description:"Show the documentation of everything in the package, not just a summary"`
+	Source     bool   `json:"source,omitempty" description:"Show the source code of the symbol"`
+	Unexported bool   `json:"unexported,omitempty" description:"Include unexported symbols"`
+}
+
+// Name returns the name of the go_doc tool.
+func (t *goDocTool) Name() string {
+	return "go_doc"
+}
+
+// Description returns a brief description of the go_doc tool.
+func (t *goDocTool) Description() string {
+	return "Shows the Go documentation of a package, type, function, or method, as go doc does, in the module " + filepath.Base(t.dir) + " and its dependencies"
+}
+
+// InputSchema returns the JSON schema for the go_doc tool's input
+// parameters.
+func (t *goDocTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(goDocArgs{})
+}
+
+// Annotations marks the go_doc tool as read-only. It reads the module and
+// the module cache.
+func (t *goDocTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Go documentation")
+}
+
+// Timeout bounds a call, which may need to load many packages.
+func (t *goDocTool) Timeout() time.Duration {
+	return goCommandTimeout
+}
+
+// Execute runs go doc without a deadline of its own.
+func (t *goDocTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs go doc in the module and returns its output.
+func (t *goDocTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a goDocArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	query := strings.Fields(a.Query)
+	if len(query) > 2 {
+		return nil, fmt.Errorf("invalid value for 'query': expected a package, a symbol, or both")
+	}
+	for _, q := range query {
+		if strings.HasPrefix(q, "-") {
+			return nil, fmt.Errorf("invalid value for 'query': %q is not a package or symbol", q)
+		}
+		// Keep relative package paths inside the module.
+		if strings.HasPrefix(q, ".") && !filepath.IsLocal(filepath.Clean(q)) && filepath.Clean(q) != "." {
+			return nil, fmt.Errorf("invalid value for 'query': %q is outside the module", q)
+		}
+	}
+	argv := []string{"doc"}
+	if a.All {
+		argv = append(argv, "-all")
+	}
+	if a.Source {
+		argv = append(argv, "-src")
+	}
+	if a.Unexported {
+		argv = append(argv, "-u")
+	}
+	argv = append(argv, query...)
+	out, truncated, err := runGo(ctx, t.dir, argv...)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		out += "\n[truncated]\n"
+	}
+	return []ToolContent{{Type: "text", Text: out}}, nil
+}
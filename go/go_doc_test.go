@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestModule writes a small Go module and returns its directory.
+func writeTestModule(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is not installed")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod": "module example.com/shapes\n\ngo 1.21\n",
+		"shapes.go": `// Package shapes measures shapes.
+package shapes
+
+// Square is a square.
+type Square struct {
+	Side float64
+}
+
+// Area returns the area of the square.
+func (s Square) Area() float64 {
+	return s.Side * s.Side
+}
+
+// scale multiplies the side of s by k.
+func (s *Square) scale(k float64) {
+	s.Side *= k
+}
+`,
+		"internal/units/units.go": "// Package units converts units.\npackage units\n\n// Inch is an inch in meters.\nconst Inch = 0.0254\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Test documenting the packages and symbols of a module
+func TestGoDoc(t *testing.T) {
+	tools, err := goModuleTools(writeTestModule(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tool := tools[0].(*goDocTool)
+	for _, tc := range []struct {
+		args     map[string]interface{}
+		expected []string
+		absent   string
+	}{
+		{map[string]interface{}{}, []string{"Package shapes measures shapes.", "type Square struct"}, "scale"},
+		{map[string]interface{}{"query": "Square.Area"}, []string{"func (s Square) Area() float64", "Area returns the area"}, ""},
+		{map[string]interface{}{"query": "Square.Area", "source": true}, []string{"return s.Side * s.Side"}, ""},
+		{map[string]interface{}{"query": "Square", "unexported": true}, []string{"func (s *Square) scale(k float64)"}, ""},
+		{map[string]interface{}{"query": "./internal/units", "all": true}, []string{"const Inch = 0.0254", "Inch is an inch in meters."}, ""},
+		{map[string]interface{}{"query": "strings.Cut"}, []string{"func Cut(s, sep string)"}, ""},
+	} {
+		content, err := tool.Execute(tc.args)
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		for _, e := range tc.expected {
+			if !strings.Contains(content[0].Text, e) {
+				t.Errorf("%v: expected %q in %q", tc.args, e, content[0].Text)
+			}
+		}
+		if tc.absent != "" && strings.Contains(content[0].Text, tc.absent) {
+			t.Errorf("%v: expected no %q in %q", tc.args, tc.absent, content[0].Text)
+		}
+	}
+
+	_, err = tool.Execute(map[string]interface{}{"query": "Circle"})
+	var failure *toolResultError
+	if !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "Circle") {
+		t.Errorf("expected a tool failure naming the symbol, got %v", err)
+	}
+	for _, query := range []string{"-cmd", "../other", "a b c"} {
+		if _, err := tool.Execute(map[string]interface{}{"query": query}); err == nil || !strings.HasPrefix(err.Error(), "invalid value for 'query'") {
+			t.Errorf("%q: expected an invalid value error, got %v", query, err)
+		}
+	}
+	if _, err := goModuleTools(t.TempDir()); err == nil {
+		t.Error("expected an error for a directory without go.mod")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// goModulesTool lists the dependencies of a Go module with go list -m and
+// go mod graph.
+type goModulesTool struct {
+	dir string
+}
+
+// goModulesArgs are the arguments of the go_modules tool.
+type goModulesArgs struct {
+	Updates    bool   `json:"updates,omitempty" description:"Look up the latest version of each dependency (asks the module proxy, so it is slower)"`
+	DirectOnly bool   `json:"direct_only,omitempty" description:"Only the dependencies go.mod requires directly"`
+	Filter     string `json:"filter,omitempty" description:"Only modules whose path contains this text"`
+}
+
+// Name returns the name of the go_modules tool.
+func (t *goModulesTool) Name() string {
+	return "go_modules"
+}
+
+// Description returns a brief description of the go_modules tool.
+func (t *goModulesTool) Description() string {
+	return "Lists the dependencies of the Go module " + filepath.Base(t.dir) + " with their versions, replacements, the modules that require them, and optionally available updates"
+}
+
+// InputSchema returns the JSON schema for the go_modules tool's input
+// parameters.
+func (t *goModulesTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(goModulesArgs{})
+}
+
+// Annotations marks the go_modules tool as read-only. Looking up updates
+// queries the module proxy.
+func (t *goModulesTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "Go module dependencies", ReadOnlyHint: true, IdempotentHint: true}
+}
+
+// Timeout bounds a call, which may look up many modules.
+func (t *goModulesTool) Timeout() time.Duration {
+	return goCommandTimeout
+}
+
+// goListModule is a module as go list -m -json prints it.
+type goListModule struct {
+	Path      string
+	Version   string
+	Main      bool
+	Indirect  bool
+	GoVersion string
+	Replace   *goListModule
+	Update    *goListModule
+	Error     *struct{ Err string }
+}
+
+// goModule is a dependency in the go_modules result.
+type goModule struct {
+	Path       string   `json:"path"`
+	Version    string   `json:"version,omitempty"`
+	Indirect   bool     `json:"indirect,omitempty"`
+	Replace    string   `json:"replace,omitempty"` // path[@version] of the replacement
+	Update     string   `json:"update,omitempty"`  // the latest version, if newer
+	GoVersion  string   `json:"goVersion,omitempty"`
+	RequiredBy []string `json:"requiredBy,omitempty"` // path@version of the requiring modules
+	Error      string   `json:"error,omitempty"`
+}
+
+// goModulesResult is the result of the go_modules tool.
+type goModulesResult struct {
+	Module       string     `json:"module"`
+	GoVersion    string     `json:"goVersion,omitempty"`
+	Dependencies []goModule `json:"dependencies"`
+	Updates      int        `json:"updates,omitempty"` // how many dependencies have one
+}
+
+// Execute lists the dependencies without a deadline of its own.
+func (t *goModulesTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext lists the module's build list, as chosen by minimal
+// version selection, and returns it as indented JSON text.
+func (t *goModulesTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a goModulesArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	argv := []string{"list", "-m", "-json"}
+	if a.Updates {
+		argv = append(argv, "-u")
+	}
+	out, truncated, err := runGo(ctx, t.dir, append(argv, "all")...)
+	if err != nil {
+		return nil, err
+	}
+	graph, graphTruncated, err := runGo(ctx, t.dir, "mod", "graph")
+	if err != nil {
+		return nil, err
+	}
+	if truncated || graphTruncated {
+		return nil, toolFailure("the module has too many dependencies to list")
+	}
+	requiredBy := map[string][]string{}
+	for _, line := range strings.Split(graph, "\n") {
+		from, to, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		// The main module appears without a version.
+		requiredBy[to] = append(requiredBy[to], from)
+	}
+
+	var result goModulesResult
+	result.Dependencies = []goModule{}
+	dec := json.NewDecoder(strings.NewReader(out))
+	for {
+		var m goListModule
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("go list: %v", err)
+		}
+		if m.Main {
+			result.Module, result.GoVersion = m.Path, m.GoVersion
+			continue
+		}
+		if a.DirectOnly && m.Indirect || a.Filter != "" && !strings.Contains(m.Path, a.Filter) {
+			continue
+		}
+		dep := goModule{Path: m.Path, Version: m.Version, Indirect: m.Indirect, GoVersion: m.GoVersion}
+		if m.Replace != nil {
+			dep.Replace = m.Replace.Path
+			if m.Replace.Version != "" {
+				dep.Replace += "@" + m.Replace.Version
+			}
+			dep.GoVersion = m.Replace.GoVersion
+		}
+		if m.Update != nil {
+			dep.Update = m.Update.Version
+			result.Updates++
+		}
+		if m.Error != nil {
+			dep.Error = m.Error.Err
+		}
+		dep.RequiredBy = requiredBy[m.Path+"@"+m.Version]
+		sort.Strings(dep.RequiredBy)
+		result.Dependencies = append(result.Dependencies, dep)
+	}
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
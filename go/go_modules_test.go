@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Test listing dependencies that are replaced by local directories, so
+// that nothing is downloaded
+func TestGoModules(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("the go command is not installed")
+	}
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"app/go.mod": "module example.com/app\n\ngo 1.21\n\nrequire (\n\texample.com/lib v1.2.0\n\texample.com/util v0.3.0 // indirect\n)\n\n" +
+			"replace (\n\texample.com/lib => ../lib\n\texample.com/util => ../util\n)\n",
+		"app/app.go":   "package app\n\nimport _ \"example.com/lib\"\n",
+		"lib/go.mod":   "module example.com/lib\n\ngo 1.21\n\nrequire example.com/util v0.3.0\n",
+		"lib/lib.go":   "package lib\n\nimport _ \"example.com/util\"\n",
+		"util/go.mod":  "module example.com/util\n\ngo 1.20\n",
+		"util/util.go": "package util\n",
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tool := &goModulesTool{dir: filepath.Join(dir, "app")}
+
+	list := func(args map[string]interface{}) goModulesResult {
+		t.Helper()
+		content, err := tool.Execute(args)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		var result goModulesResult
+		if err := json.Unmarshal([]byte(content[0].Text), &result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	result := list(map[string]interface{}{})
+	expected := goModulesResult{
+		Module:    "example.com/app",
+		GoVersion: "1.21",
+		Dependencies: []goModule{
+			{Path: "example.com/lib", Version: "v1.2.0", Replace: "../lib", GoVersion: "1.21", RequiredBy: []string{"example.com/app"}},
+			{Path: "example.com/util", Version: "v0.3.0", Indirect: true, Replace: "../util", GoVersion: "1.20", RequiredBy: []string{"example.com/app", "example.com/lib@v1.2.0"}},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result)
+	}
+	if direct := list(map[string]interface{}{"direct_only": true}); len(direct.Dependencies) != 1 || direct.Dependencies[0].Path != "example.com/lib" {
+		t.Errorf("expected only the direct dependency, got %+v", direct.Dependencies)
+	}
+	if filtered := list(map[string]interface{}{"filter": "util"}); len(filtered.Dependencies) != 1 || filtered.Dependencies[0].Path != "example.com/util" {
+		t.Errorf("expected only the matching dependency, got %+v", filtered.Dependencies)
+	}
+}
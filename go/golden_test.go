@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The golden tests serve each testdata/golden/<name>.in to a server with the
+// default tools and compare its output with <name>.out. Both files hold one
+// JSON-RPC message per line; blank lines and lines starting with # are
+// ignored, so a scenario can document itself. Messages are compared as JSON,
+// not as text, and responses are matched to their requests by ID, since the
+// server answers requests concurrently and in no fixed order.
+//
+// To add a scenario, write its .in file and run
+//
+//	go test -run TestGolden -update
+//
+// then check the generated .out file.
+
+// update makes TestGolden write the server's output to the .out files.
+var update = flag.Bool("update", false, "rewrite the golden files of TestGolden")
+
+// readMessages returns the messages of a golden file.
+func readMessages(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return messageLines(data, true)
+}
+
+// messageLines splits data into its non-empty lines, skipping comments if
+// comments is set.
+func messageLines(data []byte, comments bool) [][]byte {
+	var msgs [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || (comments && line[0] == '#') {
+			continue
+		}
+		msgs = append(msgs, append([]byte(nil), line...))
+	}
+	return msgs
+}
+
+// Test the scenarios in testdata/golden against their expected output
+func TestGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "golden", "*.in"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no golden scenarios found")
+	}
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".in")
+		t.Run(name, func(t *testing.T) {
+			var in, out bytes.Buffer
+			for _, msg := range readMessages(t, input) {
+				in.Write(msg)
+				in.WriteByte('\n')
+			}
+			if err := NewServer().Serve(context.Background(), &in, &out); err != nil {
+				t.Fatalf("Serve error: %v", err)
+			}
+			got := messageLines(out.Bytes(), false)
+
+			golden := strings.TrimSuffix(input, ".in") + ".out"
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var report strings.Builder
+			if diffs := compareMessages(readMessages(t, golden), got, &report); diffs > 0 {
+				// The report compares the golden file as "recorded" with the
+				// output as "replayed".
+				t.Errorf("output differs from %s in %d messages (rerun with -update to accept it):\n%s", golden, diffs, report.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// grpcConfig defines a gRPC server whose unary methods are exposed as tools
+// in the "grpc" section of the config file. The server must have reflection
+// enabled.
+type grpcConfig struct {
+	Name      string            `json:"name"`
+	Address   string            `json:"address"`   // host:port of the server
+	TLS       bool              `json:"tls"`       // connect with TLS instead of plaintext HTTP/2
+	CAFile    string            `json:"caFile"`    // PEM certificates to trust instead of the system's; implies tls
+	Services  []string          `json:"services"`  // fully qualified services to expose, default all
+	Namespace string            `json:"namespace"` // qualifies the generated tool names
+	Metadata  map[string]string `json:"metadata"`  // sent with every call; $VAR expands from the environment
+}
+
+// validate reports missing or malformed fields.
+func (c *grpcConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("grpc entry without a name")
+	}
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		return fmt.Errorf("grpc %q: address must be host:port: %w", c.Name, err)
+	}
+	if err := validateNamespace(c.Namespace); err != nil {
+		return fmt.Errorf("grpc %q: %w", c.Name, err)
+	}
+	return nil
+}
+
+// grpcDiscoveryTimeout bounds listing a server's methods at startup.
+const grpcDiscoveryTimeout = 30 * time.Second
+
+// grpcReflectionServices are the reflection services tried in order, the
+// newer first. Their messages are the same.
+var grpcReflectionServices = []string{"grpc.reflection.v1.ServerReflection", "grpc.reflection.v1alpha.ServerReflection"}
+
+// grpcClient holds the connection to a gRPC server, redialing it when it
+// fails.
+type grpcClient struct {
+	cfg      grpcConfig
+	tls      *tls.Config // nil for plaintext
+	metadata []hpackField
+
+	mu         sync.Mutex
+	conn       *h2Conn
+	reflection string // the reflection service that answered
+}
+
+// newGRPCClient returns a client for the server described by cfg, without
+// connecting yet.
+func newGRPCClient(cfg grpcConfig) (*grpcClient, error) {
+	c := &grpcClient{cfg: cfg}
+	if cfg.TLS || cfg.CAFile != "" {
+		c.tls = &tls.Config{}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			c.tls.RootCAs = x509.NewCertPool()
+			if !c.tls.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates in %s", cfg.CAFile)
+			}
+		}
+	}
+	for _, name := range sortedKeys(cfg.Metadata) {
+		c.metadata = append(c.metadata, hpackField{strings.ToLower(name), os.ExpandEnv(cfg.Metadata[name])})
+	}
+	return c, nil
+}
+
+// connection returns the open connection, dialing the server if there is
+// none or it has failed.
+func (c *grpcClient) connection(ctx context.Context) (*h2Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil && c.conn.usable() {
+		return c.conn, nil
+	}
+	if c.conn != nil {
+		c.conn.close()
+	}
+	conn, err := dialH2(ctx, c.cfg.Address, c.tls)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+// invoke calls the method at path, such as "/pkg.Service/Method".
+func (c *grpcClient) invoke(ctx context.Context, path string, request []byte) ([][]byte, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return invokeGRPC(ctx, conn, path, c.metadata, request)
+}
+
+// close closes the connection, if any.
+func (c *grpcClient) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.close()
+		c.conn = nil
+	}
+}
+
+// reflect sends a ServerReflectionRequest and returns the response. The
+// first reflection service the server implements is used from then on.
+func (c *grpcClient) reflect(ctx context.Context, request []byte) ([]protoValue, error) {
+	c.mu.Lock()
+	services := grpcReflectionServices
+	if c.reflection != "" {
+		services = []string{c.reflection}
+	}
+	c.mu.Unlock()
+	var err error
+	for _, service := range services {
+		var messages [][]byte
+		messages, err = c.invoke(ctx, "/"+service+"/ServerReflectionInfo", request)
+		var status *grpcStatusError
+		if errors.As(err, &status) && status.code == grpcUnimplemented {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(messages) != 1 {
+			return nil, fmt.Errorf("reflection answered with %d messages", len(messages))
+		}
+		c.mu.Lock()
+		c.reflection = service
+		c.mu.Unlock()
+		values, err := decodeProto(messages[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			if v.num == 7 { // error_response
+				return nil, reflectionError(v.b)
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("server reflection is not enabled: %w", err)
+}
+
+// reflectionError converts an ErrorResponse.
+func reflectionError(data []byte) error {
+	values, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+	e := &grpcStatusError{}
+	for _, v := range values {
+		switch v.num {
+		case 1:
+			e.code = int(v.u)
+		case 2:
+			e.message = string(v.b)
+		}
+	}
+	return e
+}
+
+// listServices returns the services the server offers.
+func (c *grpcClient) listServices(ctx context.Context) ([]string, error) {
+	values, err := c.reflect(ctx, appendProtoString(nil, 7, "")) // list_services
+	if err != nil {
+		return nil, err
+	}
+	var services []string
+	for _, v := range values {
+		if v.num != 6 { // list_services_response
+			continue
+		}
+		responses, err := decodeProto(v.b)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range responses {
+			fields, err := decodeProto(r.b)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range fields {
+				if f.num == 1 {
+					services = append(services, string(f.b))
+				}
+			}
+		}
+	}
+	return services, nil
+}
+
+// loadFiles adds the files the server returns for a request, and then the
+// files they depend on, to reg.
+func (c *grpcClient) loadFiles(ctx context.Context, reg *protoRegistry, request []byte) error {
+	values, err := c.reflect(ctx, request)
+	if err != nil {
+		return err
+	}
+	var deps []string
+	for _, v := range values {
+		if v.num != 4 { // file_descriptor_response
+			continue
+		}
+		files, err := decodeProto(v.b)
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			_, fileDeps, err := reg.addFile(f.b)
+			if err != nil {
+				return err
+			}
+			deps = append(deps, fileDeps...)
+		}
+	}
+	for _, dep := range deps {
+		if !reg.files[dep] {
+			if err := c.loadFiles(ctx, reg, appendProtoString(nil, 3, dep)); err != nil { // file_by_filename
+				return fmt.Errorf("%s: %w", dep, err)
+			}
+		}
+	}
+	return nil
+}
+
+// loadGRPCTools connects to the server described by cfg and returns one
+// tool per unary method of its services. Streaming methods are skipped.
+func loadGRPCTools(cfg grpcConfig) ([]MCPTool, error) {
+	c, err := newGRPCClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("grpc %q: %w", cfg.Name, err)
+	}
+	generated, err := c.tools()
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("grpc %q: %w", cfg.Name, err)
+	}
+	return generated, nil
+}
+
+// tools lists the server's services through reflection and builds their
+// tools.
+func (c *grpcClient) tools() ([]MCPTool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), grpcDiscoveryTimeout)
+	defer cancel()
+	services := c.cfg.Services
+	if len(services) == 0 {
+		all, err := c.listServices(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range all {
+			if !strings.HasPrefix(name, "grpc.reflection.") {
+				services = append(services, name)
+			}
+		}
+	}
+	reg := newProtoRegistry()
+	for _, name := range services {
+		if _, ok := reg.services[name]; ok {
+			continue
+		}
+		if err := c.loadFiles(ctx, reg, appendProtoString(nil, 4, name)); err != nil { // file_containing_symbol
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+	}
+
+	var generated []MCPTool
+	for _, name := range services {
+		s, ok := reg.services[name]
+		if !ok {
+			return nil, fmt.Errorf("service %s not found", name)
+		}
+		for _, m := range s.methods {
+			if m.clientStreaming || m.serverStreaming {
+				continue
+			}
+			t, err := newGRPCTool(c, reg, s, m)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", s.fullName, m.name, err)
+			}
+			generated = append(generated, t)
+		}
+	}
+	return generated, nil
+}
+
+// grpcTool calls one unary method of a gRPC service. Arguments are the JSON
+// form of the request message and the result is the JSON form of the
+// response.
+type grpcTool struct {
+	client *grpcClient
+	reg    *protoRegistry
+	name   string
+	path   string // as in "/pkg.Service/Method"
+	method protoMethod
+	schema map[string]interface{}
+}
+
+// newGRPCTool builds the tool for method m of service s.
+func newGRPCTool(c *grpcClient, reg *protoRegistry, s *protoService, m protoMethod) (*grpcTool, error) {
+	if _, err := reg.message(m.input); err != nil {
+		return nil, err
+	}
+	if _, err := reg.message(m.output); err != nil {
+		return nil, err
+	}
+	name := strings.Trim(nonNameChars.ReplaceAllString(s.fullName+"_"+m.name, "_"), "_")
+	return &grpcTool{
+		client: c,
+		reg:    reg,
+		name:   name,
+		path:   "/" + s.fullName + "/" + m.name,
+		method: m,
+		schema: reg.schema(m.input, 0),
+	}, nil
+}
+
+// Name returns the service and method name, as in "pkg_Service_Method".
+func (t *grpcTool) Name() string {
+	return t.name
+}
+
+// Description names the method and its message types.
+func (t *grpcTool) Description() string {
+	return fmt.Sprintf("Calls the gRPC method %s with a %s, returning a %s",
+		strings.TrimPrefix(t.path, "/"), strings.TrimPrefix(t.method.input, "."), strings.TrimPrefix(t.method.output, "."))
+}
+
+// InputSchema returns the schema of the request message's JSON form.
+func (t *grpcTool) InputSchema() map[string]interface{} {
+	return t.schema
+}
+
+// Annotations derives hints from the method's idempotency level. Methods
+// without one are assumed to modify their environment.
+func (t *grpcTool) Annotations() ToolAnnotations {
+	a := ToolAnnotations{}
+	switch t.method.idempotency {
+	case protoNoSideEffects:
+		a.ReadOnlyHint, a.IdempotentHint = true, true
+	case protoIdempotent:
+		a.IdempotentHint = true
+	}
+	return a
+}
+
+// Execute calls the method without a deadline of its own.
+func (t *grpcTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext calls the method. Arguments that do not fit the request
+// message, and statuses other than OK, fail the call with a result flagged
+// as an error.
+func (t *grpcTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	request, err := t.reg.encodeJSON(t.method.input, args, 0)
+	if err != nil {
+		return nil, toolFailure("Invalid request: %v", err)
+	}
+	messages, err := t.client.invoke(ctx, t.path, request)
+	var status *grpcStatusError
+	if errors.As(err, &status) {
+		return nil, toolFailure("%s: %v", strings.TrimPrefix(t.path, "/"), status)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) != 1 {
+		return nil, fmt.Errorf("%s: expected one response message, got %d", t.path, len(messages))
+	}
+	response, err := t.reg.decodeJSON(t.method.output, messages[0], 0)
+	if err != nil {
+		return nil, err
+	}
+	text, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(text)}}, nil
+}
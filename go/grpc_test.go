@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeGRPCServer serves the Greeter service of testProtoFiles, with
+// reflection only under its v1alpha name.
+func fakeGRPCServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	reg := testProtoRegistry(t)
+	common, greeter := testProtoFiles()
+
+	reply := func(w http.ResponseWriter, message []byte) {
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		frame := make([]byte, 5, 5+len(message))
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+		w.Write(append(frame, message...))
+		w.Header().Set("Grpc-Status", "0")
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.ProtoMajor != 2 || r.Header.Get("Content-Type") != "application/grpc" || len(body) < 5 {
+			http.Error(w, "not a gRPC request", http.StatusBadRequest)
+			return
+		}
+		request := body[5:]
+		switch r.URL.Path {
+		case "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo":
+			values, _ := decodeProto(request)
+			var response []byte
+			for _, v := range values {
+				switch {
+				case v.num == 7:
+					services := appendProtoBytes(nil, 1, appendProtoString(nil, 1, "test.Greeter"))
+					services = appendProtoBytes(services, 1, appendProtoString(nil, 1, "grpc.reflection.v1alpha.ServerReflection"))
+					response = appendProtoBytes(nil, 6, services)
+				case v.num == 4 && string(v.b) == "test.Greeter":
+					response = appendProtoBytes(nil, 4, appendProtoBytes(nil, 1, greeter))
+				case v.num == 3 && string(v.b) == "common.proto":
+					response = appendProtoBytes(nil, 4, appendProtoBytes(nil, 1, common))
+				case v.num == 3 || v.num == 4:
+					response = appendProtoBytes(nil, 7, appendProtoString(appendProtoVarint(nil, 1, 5), 2, "not found"))
+				}
+			}
+			reply(w, response)
+		case "/test.Greeter/SayHello":
+			obj, err := reg.decodeJSON(".test.HelloRequest", request, 0)
+			if err != nil {
+				t.Errorf("decodeJSON error: %v", err)
+			}
+			args, _ := json.Marshal(obj)
+			message := "Hello " + string(args) + " " + r.Header.Get("X-Token")
+			reply(w, appendProtoString(nil, 1, message))
+		case "/test.Greeter/Fail":
+			// A trailers-only response.
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", "5")
+			w.Header().Set("Grpc-Message", "no%20such%20greeting")
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// grpcTestConfig returns a config for ts that trusts its certificate.
+func grpcTestConfig(t *testing.T, ts *httptest.Server) grpcConfig {
+	t.Helper()
+	ca := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(ca, certificate, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return grpcConfig{
+		Name:     "greeter",
+		Address:  ts.Listener.Addr().String(),
+		CAFile:   ca,
+		Metadata: map[string]string{"X-Token": "$GRPC_TEST_TOKEN"},
+	}
+}
+
+// Test generating tools from a server's reflection and calling them
+func TestGRPCTools(t *testing.T) {
+	t.Setenv("GRPC_TEST_TOKEN", "secret")
+	ts := fakeGRPCServer(t)
+	generated, err := loadGRPCTools(grpcTestConfig(t, ts))
+	if err != nil {
+		t.Fatalf("loadGRPCTools error: %v", err)
+	}
+	t.Cleanup(generated[0].(*grpcTool).client.close)
+	var names []string
+	for _, tool := range generated {
+		names = append(names, tool.Name())
+	}
+	if strings.Join(names, ",") != "test_Greeter_SayHello,test_Greeter_Fail" {
+		t.Fatalf("expected the unary methods as tools, got %v", names)
+	}
+
+	hello := generated[0].(*grpcTool)
+	if a := hello.Annotations(); !a.ReadOnlyHint || !a.IdempotentHint {
+		t.Errorf("expected a method without side effects to be read-only, got %+v", a)
+	}
+	if a := generated[1].(*grpcTool).Annotations(); a.ReadOnlyHint || a.IdempotentHint {
+		t.Errorf("expected no hints for a method without an idempotency level, got %+v", a)
+	}
+	if _, ok := hello.InputSchema()["properties"].(map[string]interface{})["replyTo"]; !ok {
+		t.Errorf("expected the request fields in the schema, got %v", hello.InputSchema())
+	}
+
+	content, err := hello.Execute(map[string]interface{}{"name": "Ada", "mood": "HAPPY"})
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	expected := `{"message":"Hello {\"mood\":\"HAPPY\",\"name\":\"Ada\"} secret"}`
+	if len(content) != 1 || content[0].Text != expected {
+		t.Errorf("expected %s, got %+v", expected, content)
+	}
+
+	// Larger than the initial flow control windows in both directions.
+	long := strings.Repeat("a", 200000)
+	if content, err := hello.Execute(map[string]interface{}{"name": long}); err != nil || !strings.Contains(content[0].Text, long) {
+		t.Errorf("expected a long name to be echoed, got %v", err)
+	}
+
+	_, err = generated[1].Execute(map[string]interface{}{})
+	var failure *toolResultError
+	if !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "NOT_FOUND: no such greeting") {
+		t.Errorf("expected the status as an error result, got %v", err)
+	}
+	if _, err := hello.Execute(map[string]interface{}{"nmae": "Ada"}); !errors.As(err, &failure) {
+		t.Errorf("expected invalid arguments to fail the call, got %v", err)
+	}
+}
+
+// Test that calls fail once the context is done
+func TestGRPCCancel(t *testing.T) {
+	ts := fakeGRPCServer(t)
+	generated, err := loadGRPCTools(grpcTestConfig(t, ts))
+	if err != nil {
+		t.Fatalf("loadGRPCTools error: %v", err)
+	}
+	hello := generated[0].(*grpcTool)
+	t.Cleanup(hello.client.close)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := hello.ExecuteContext(ctx, map[string]interface{}{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the cancellation, got %v", err)
+	}
+	if _, err := hello.Execute(map[string]interface{}{"name": "Ada"}); err != nil {
+		t.Errorf("expected the connection to remain usable, got %v", err)
+	}
+}
+
+// Test the errors of unusable servers
+func TestGRPCLoadErrors(t *testing.T) {
+	ts := fakeGRPCServer(t)
+	cfg := grpcTestConfig(t, ts)
+	cfg.Services = []string{"test.Missing"}
+	if _, err := loadGRPCTools(cfg); err == nil || !strings.Contains(err.Error(), "NOT_FOUND") {
+		t.Errorf("expected an unknown service to be reported, got %v", err)
+	}
+
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+	cfg = grpcConfig{Name: "plain", Address: plain.Listener.Addr().String()}
+	if _, err := loadGRPCTools(cfg); err == nil {
+		t.Error("expected a server that does not speak HTTP/2 to be reported")
+	}
+	if err := (&grpcConfig{Name: "x", Address: "localhost"}).validate(); err == nil {
+		t.Error("expected an address without a port to be rejected")
+	}
+}
@@ -0,0 +1,727 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP/2 frame types (RFC 9113, Section 6).
+const (
+	h2Data         = 0x0
+	h2Headers      = 0x1
+	h2RSTStream    = 0x3
+	h2Settings     = 0x4
+	h2PushPromise  = 0x5
+	h2Ping         = 0x6
+	h2GoAway       = 0x7
+	h2WindowUpdate = 0x8
+	h2Continuation = 0x9
+)
+
+// HTTP/2 frame flags.
+const (
+	h2FlagAck        = 0x1 // SETTINGS and PING
+	h2FlagEndStream  = 0x1
+	h2FlagEndHeaders = 0x4
+	h2FlagPadded     = 0x8
+	h2FlagPriority   = 0x20
+)
+
+// HTTP/2 settings the client reads or sends.
+const (
+	h2SettingEnablePush        = 0x2
+	h2SettingInitialWindowSize = 0x4
+	h2SettingMaxFrameSize      = 0x5
+)
+
+const (
+	// h2Preface starts every HTTP/2 connection.
+	h2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	// h2DefaultWindow is the initial flow-control window of RFC 9113.
+	h2DefaultWindow = 65535
+	// h2MaxFrameSize is the frame size the client receives, the default.
+	h2MaxFrameSize = 16384
+	// h2CancelCode is the RST_STREAM error code for cancelled requests.
+	h2CancelCode = 0x8
+	// maxGRPCResponse bounds the body of a gRPC response.
+	maxGRPCResponse = 16 << 20
+)
+
+// errH2Closed is returned for requests on a connection that has failed or
+// been shut down by the server.
+var errH2Closed = errors.New("http2: connection closed")
+
+// h2Conn is a minimal HTTP/2 client connection, enough to make gRPC calls
+// without depending on golang.org/x/net or requiring TLS: requests are
+// sent whole and responses are buffered until the server ends the stream.
+// It is safe for concurrent use; requests are multiplexed on the
+// connection.
+type h2Conn struct {
+	conn      net.Conn
+	scheme    string
+	authority string
+
+	wmu sync.Mutex // serializes frame writes
+	bw  *bufio.Writer
+
+	mu           sync.Mutex
+	cond         *sync.Cond // signalled when windows grow or streams end
+	streams      map[uint32]*h2Stream
+	nextID       uint32
+	window       int64 // the connection's send window
+	streamWindow int64 // the initial send window of new streams
+	maxFrame     int   // the largest frame the server accepts
+	err          error // why the connection is unusable, if it is
+}
+
+// h2Stream is one request on an h2Conn.
+type h2Stream struct {
+	id      uint32
+	window  int64 // send window
+	headers []hpackField
+	trailer []hpackField
+	body    []byte
+	done    chan struct{} // closed when the response is complete or failed
+	err     error
+}
+
+// h2Response is a complete HTTP/2 response.
+type h2Response struct {
+	headers []hpackField // including :status
+	trailer []hpackField
+	body    []byte
+}
+
+// header returns the value of the named header field of a header list.
+func header(fields []hpackField, name string) (string, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f.value, true
+		}
+	}
+	return "", false
+}
+
+// dialH2 connects to addr and starts an HTTP/2 connection, over TLS if
+// tlsConfig is not nil, over plaintext with prior knowledge otherwise.
+func dialH2(ctx context.Context, addr string, tlsConfig *tls.Config) (*h2Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.NextProtos = []string{"h2"}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if p := tc.ConnectionState().NegotiatedProtocol; p != "h2" {
+			tc.Close()
+			return nil, fmt.Errorf("%s does not speak HTTP/2 over TLS", addr)
+		}
+		conn = tc
+	}
+	c := &h2Conn{
+		conn:         conn,
+		scheme:       scheme,
+		authority:    addr,
+		bw:           bufio.NewWriter(conn),
+		streams:      map[uint32]*h2Stream{},
+		nextID:       1,
+		window:       h2DefaultWindow,
+		streamWindow: h2DefaultWindow,
+		maxFrame:     h2MaxFrameSize,
+	}
+	c.cond = sync.NewCond(&c.mu)
+
+	c.bw.WriteString(h2Preface)
+	settings := binary.BigEndian.AppendUint16(nil, h2SettingEnablePush)
+	settings = binary.BigEndian.AppendUint32(settings, 0)
+	if err := c.writeFrame(h2Settings, 0, 0, settings); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	go c.readLoop()
+	return c, nil
+}
+
+// close shuts the connection down, failing requests in flight.
+func (c *h2Conn) close() error {
+	c.fail(errH2Closed)
+	return c.conn.Close()
+}
+
+// usable reports whether new requests can be sent on the connection.
+func (c *h2Conn) usable() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err == nil
+}
+
+// fail makes the connection unusable and fails every stream with err.
+func (c *h2Conn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+	for _, st := range c.streams {
+		c.finish(st, err)
+	}
+	c.cond.Broadcast()
+}
+
+// finish ends a stream with err, which is nil on success. It is called with
+// c.mu held.
+func (c *h2Conn) finish(st *h2Stream, err error) {
+	delete(c.streams, st.id)
+	st.err = err
+	close(st.done)
+	c.cond.Broadcast()
+}
+
+// writeFrame writes and flushes one frame.
+func (c *h2Conn) writeFrame(typ, flags byte, stream uint32, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeFrameLocked(typ, flags, stream, payload, true)
+}
+
+// writeFrameLocked writes one frame with c.wmu held, flushing it if asked.
+func (c *h2Conn) writeFrameLocked(typ, flags byte, stream uint32, payload []byte, flush bool) error {
+	var head [9]byte
+	head[0], head[1], head[2] = byte(len(payload)>>16), byte(len(payload)>>8), byte(len(payload))
+	head[3], head[4] = typ, flags
+	binary.BigEndian.PutUint32(head[5:], stream&0x7fffffff)
+	c.bw.Write(head[:])
+	c.bw.Write(payload)
+	if !flush {
+		return nil
+	}
+	return c.bw.Flush()
+}
+
+// roundTrip sends a POST request for path with the given headers and body
+// and waits for the complete response. If ctx is done first the stream is
+// reset.
+func (c *h2Conn) roundTrip(ctx context.Context, path string, headers []hpackField, body []byte) (*h2Response, error) {
+	block := appendHPACKLiteral(nil, ":method", "POST")
+	block = appendHPACKLiteral(block, ":scheme", c.scheme)
+	block = appendHPACKLiteral(block, ":path", path)
+	block = appendHPACKLiteral(block, ":authority", c.authority)
+	for _, h := range headers {
+		block = appendHPACKLiteral(block, h.name, h.value)
+	}
+
+	// Streams must be opened in the order of their IDs, so the ID is
+	// taken while holding the write lock.
+	c.wmu.Lock()
+	c.mu.Lock()
+	if c.nextID > 1<<31-1 && c.err == nil {
+		c.err = errors.New("http2: stream IDs exhausted") // the next call redials
+	}
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		c.wmu.Unlock()
+		return nil, err
+	}
+	st := &h2Stream{id: c.nextID, window: c.streamWindow, done: make(chan struct{})}
+	c.nextID += 2
+	c.streams[st.id] = st
+	maxFrame := c.maxFrame
+	c.mu.Unlock()
+	flags := byte(h2FlagEndHeaders)
+	if len(body) == 0 {
+		flags |= h2FlagEndStream
+	}
+	err := c.writeHeaderBlock(st.id, flags, block, maxFrame)
+	c.wmu.Unlock()
+	if err != nil {
+		c.fail(err)
+		return nil, err
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer stop()
+	if err := c.writeBody(ctx, st, body); err != nil {
+		c.reset(st, err)
+		return nil, err
+	}
+
+	select {
+	case <-st.done:
+	case <-ctx.Done():
+		c.reset(st, ctx.Err())
+		<-st.done
+	}
+	if st.err != nil {
+		return nil, st.err
+	}
+	return &h2Response{headers: st.headers, trailer: st.trailer, body: st.body}, nil
+}
+
+// writeHeaderBlock writes a HEADERS frame and any CONTINUATION frames the
+// block needs, with c.wmu held.
+func (c *h2Conn) writeHeaderBlock(stream uint32, flags byte, block []byte, maxFrame int) error {
+	typ := byte(h2Headers)
+	endStream := flags & h2FlagEndStream
+	for {
+		chunk := block
+		if len(chunk) > maxFrame {
+			chunk = chunk[:maxFrame]
+		}
+		block = block[len(chunk):]
+		f := endStream
+		if len(block) == 0 {
+			f |= h2FlagEndHeaders
+		}
+		if err := c.writeFrameLocked(typ, f, stream, chunk, len(block) == 0); err != nil {
+			return err
+		}
+		if len(block) == 0 {
+			return nil
+		}
+		typ, endStream = h2Continuation, 0
+	}
+}
+
+// writeBody sends body as DATA frames within the flow-control windows,
+// ending the stream with the last one.
+func (c *h2Conn) writeBody(ctx context.Context, st *h2Stream, body []byte) error {
+	for len(body) > 0 {
+		c.mu.Lock()
+		for c.err == nil && ctx.Err() == nil && !st.closed() && (c.window <= 0 || st.window <= 0) {
+			c.cond.Wait()
+		}
+		switch {
+		case c.err != nil:
+			c.mu.Unlock()
+			return c.err
+		case ctx.Err() != nil:
+			c.mu.Unlock()
+			return ctx.Err()
+		case st.closed():
+			// The server answered before reading the whole request.
+			c.mu.Unlock()
+			return nil
+		}
+		n := int64(len(body))
+		n = min(n, c.window, st.window, int64(c.maxFrame))
+		c.window -= n
+		st.window -= n
+		c.mu.Unlock()
+
+		var flags byte
+		if n == int64(len(body)) {
+			flags = h2FlagEndStream
+		}
+		if err := c.writeFrame(h2Data, flags, st.id, body[:n]); err != nil {
+			c.fail(err)
+			return err
+		}
+		body = body[n:]
+	}
+	return nil
+}
+
+// closed reports whether the stream has ended. It is called with c.mu held.
+func (st *h2Stream) closed() bool {
+	select {
+	case <-st.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// reset cancels a stream that has not ended.
+func (c *h2Conn) reset(st *h2Stream, err error) {
+	c.mu.Lock()
+	_, open := c.streams[st.id]
+	if open {
+		c.finish(st, err)
+	}
+	c.mu.Unlock()
+	if open {
+		c.writeFrame(h2RSTStream, 0, st.id, binary.BigEndian.AppendUint32(nil, h2CancelCode))
+	}
+}
+
+// readLoop reads frames from the server until the connection fails.
+func (c *h2Conn) readLoop() {
+	br := bufio.NewReader(c.conn)
+	dec := newHPACKDecoder()
+	err := func() error {
+		for {
+			typ, flags, stream, payload, err := readH2Frame(br)
+			if err != nil {
+				return err
+			}
+			switch typ {
+			case h2Data:
+				if err := c.handleData(flags, stream, payload); err != nil {
+					return err
+				}
+			case h2Headers:
+				block, err := h2HeaderFragment(flags, payload)
+				if err != nil {
+					return err
+				}
+				end := flags&h2FlagEndStream != 0
+				for flags&h2FlagEndHeaders == 0 {
+					var next byte
+					var more []byte
+					if next, flags, _, more, err = readH2Frame(br); err != nil {
+						return err
+					}
+					if next != h2Continuation {
+						return errors.New("http2: expected CONTINUATION frame")
+					}
+					block = append(block, more...)
+				}
+				fields, err := dec.decode(block)
+				if err != nil {
+					return err
+				}
+				c.handleHeaders(stream, end, fields)
+			case h2RSTStream:
+				if len(payload) != 4 {
+					return errors.New("http2: malformed RST_STREAM frame")
+				}
+				c.handleReset(stream, binary.BigEndian.Uint32(payload))
+			case h2Settings:
+				if err := c.handleSettings(flags, payload); err != nil {
+					return err
+				}
+			case h2Ping:
+				if flags&h2FlagAck == 0 {
+					if err := c.writeFrame(h2Ping, h2FlagAck, 0, payload); err != nil {
+						return err
+					}
+				}
+			case h2GoAway:
+				if len(payload) < 8 {
+					return errors.New("http2: malformed GOAWAY frame")
+				}
+				c.handleGoAway(binary.BigEndian.Uint32(payload) & 0x7fffffff)
+			case h2WindowUpdate:
+				if len(payload) != 4 {
+					return errors.New("http2: malformed WINDOW_UPDATE frame")
+				}
+				c.handleWindowUpdate(stream, int64(binary.BigEndian.Uint32(payload)&0x7fffffff))
+			case h2PushPromise:
+				return errors.New("http2: server push was disabled")
+			}
+		}
+	}()
+	if err == io.EOF {
+		err = errH2Closed
+	}
+	c.fail(err)
+	c.conn.Close()
+}
+
+// readH2Frame reads one frame.
+func readH2Frame(br *bufio.Reader) (typ, flags byte, stream uint32, payload []byte, err error) {
+	var head [9]byte
+	if _, err = io.ReadFull(br, head[:]); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	length := int(head[0])<<16 | int(head[1])<<8 | int(head[2])
+	if length > h2MaxFrameSize {
+		return 0, 0, 0, nil, fmt.Errorf("http2: frame of %d bytes exceeds the maximum frame size", length)
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(br, payload); err != nil {
+		return 0, 0, 0, nil, err
+	}
+	return head[3], head[4], binary.BigEndian.Uint32(head[5:]) & 0x7fffffff, payload, nil
+}
+
+// h2Unpad removes the padding of a padded frame.
+func h2Unpad(flags byte, payload []byte) ([]byte, error) {
+	if flags&h2FlagPadded == 0 {
+		return payload, nil
+	}
+	if len(payload) == 0 || int(payload[0]) > len(payload)-1 {
+		return nil, errors.New("http2: malformed padding")
+	}
+	return payload[1 : len(payload)-int(payload[0])], nil
+}
+
+// h2HeaderFragment returns the header block fragment of a HEADERS frame.
+func h2HeaderFragment(flags byte, payload []byte) ([]byte, error) {
+	payload, err := h2Unpad(flags, payload)
+	if err != nil {
+		return nil, err
+	}
+	if flags&h2FlagPriority != 0 {
+		if len(payload) < 5 {
+			return nil, errors.New("http2: malformed HEADERS frame")
+		}
+		payload = payload[5:]
+	}
+	return append([]byte(nil), payload...), nil
+}
+
+// handleData appends a DATA frame to its stream and returns the flow
+// control credit it used.
+func (c *h2Conn) handleData(flags byte, stream uint32, payload []byte) error {
+	size := len(payload)
+	data, err := h2Unpad(flags, payload)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	st, ok := c.streams[stream]
+	if ok {
+		st.body = append(st.body, data...)
+		switch {
+		case len(st.body) > maxGRPCResponse:
+			c.finish(st, fmt.Errorf("response exceeds %d bytes", maxGRPCResponse))
+		case flags&h2FlagEndStream != 0:
+			c.finish(st, nil)
+		}
+	}
+	c.mu.Unlock()
+	if size == 0 {
+		return nil
+	}
+	// Consumed data is credited back at once, as the whole response is
+	// buffered anyway.
+	inc := binary.BigEndian.AppendUint32(nil, uint32(size))
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.writeFrameLocked(h2WindowUpdate, 0, 0, inc, false)
+	if ok && flags&h2FlagEndStream == 0 {
+		c.writeFrameLocked(h2WindowUpdate, 0, stream, inc, false)
+	}
+	return c.bw.Flush()
+}
+
+// handleHeaders records a header block: the response headers first, the
+// trailer after the body.
+func (c *h2Conn) handleHeaders(stream uint32, end bool, fields []hpackField) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.streams[stream]
+	if !ok {
+		return
+	}
+	switch {
+	case st.headers == nil && strings.HasPrefix(mustHeader(fields, ":status"), "1"):
+		return // an informational response precedes the real one
+	case st.headers == nil:
+		st.headers = fields
+	default:
+		st.trailer = fields
+	}
+	if end {
+		c.finish(st, nil)
+	}
+}
+
+// mustHeader returns the value of a header field, or "" if absent.
+func mustHeader(fields []hpackField, name string) string {
+	v, _ := header(fields, name)
+	return v
+}
+
+// handleReset fails a stream the server reset.
+func (c *h2Conn) handleReset(stream uint32, code uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if st, ok := c.streams[stream]; ok {
+		c.finish(st, fmt.Errorf("http2: stream reset by the server with code %d", code))
+	}
+}
+
+// handleSettings applies the server's settings and acknowledges them.
+func (c *h2Conn) handleSettings(flags byte, payload []byte) error {
+	if flags&h2FlagAck != 0 {
+		return nil
+	}
+	if len(payload)%6 != 0 {
+		return errors.New("http2: malformed SETTINGS frame")
+	}
+	c.mu.Lock()
+	for p := payload; len(p) > 0; p = p[6:] {
+		id, v := binary.BigEndian.Uint16(p), binary.BigEndian.Uint32(p[2:])
+		switch id {
+		case h2SettingInitialWindowSize:
+			if v > 0x7fffffff {
+				c.mu.Unlock()
+				return errors.New("http2: invalid initial window size")
+			}
+			delta := int64(v) - c.streamWindow
+			c.streamWindow = int64(v)
+			for _, st := range c.streams {
+				st.window += delta
+			}
+		case h2SettingMaxFrameSize:
+			if v < h2MaxFrameSize || v > 1<<24-1 {
+				c.mu.Unlock()
+				return errors.New("http2: invalid maximum frame size")
+			}
+			c.maxFrame = int(v)
+		}
+	}
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	return c.writeFrame(h2Settings, h2FlagAck, 0, nil)
+}
+
+// handleGoAway stops new requests and fails those the server will not
+// process.
+func (c *h2Conn) handleGoAway(lastStream uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = errH2Closed
+	}
+	for id, st := range c.streams {
+		if id > lastStream {
+			c.finish(st, errH2Closed)
+		}
+	}
+	c.cond.Broadcast()
+}
+
+// handleWindowUpdate grows a send window.
+func (c *h2Conn) handleWindowUpdate(stream uint32, inc int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stream == 0 {
+		c.window += inc
+	} else if st, ok := c.streams[stream]; ok {
+		st.window += inc
+	}
+	c.cond.Broadcast()
+}
+
+// grpcStatusError is a call that ended with a gRPC status other than OK.
+type grpcStatusError struct {
+	code    int
+	message string
+}
+
+// grpcCodeNames names the gRPC status codes.
+var grpcCodeNames = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED",
+	"NOT_FOUND", "ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED",
+	"FAILED_PRECONDITION", "ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED",
+	"INTERNAL", "UNAVAILABLE", "DATA_LOSS", "UNAUTHENTICATED",
+}
+
+// gRPC status codes the bridge acts on.
+const (
+	grpcUnimplemented = 12
+)
+
+// Error implements the error interface.
+func (e *grpcStatusError) Error() string {
+	name := "code " + strconv.Itoa(e.code)
+	if e.code >= 0 && e.code < len(grpcCodeNames) {
+		name = grpcCodeNames[e.code]
+	}
+	if e.message == "" {
+		return name
+	}
+	return name + ": " + e.message
+}
+
+// invokeGRPC makes a unary gRPC call, or a server-streaming one whose
+// messages are all returned, sending request as the single request
+// message.
+func invokeGRPC(ctx context.Context, c *h2Conn, path string, metadata []hpackField, request []byte) ([][]byte, error) {
+	headers := []hpackField{
+		{"content-type", "application/grpc"},
+		{"te", "trailers"},
+		{"user-agent", serverName + "/" + serverVersion},
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		ms := time.Until(deadline).Milliseconds()
+		if ms < 1 {
+			return nil, context.DeadlineExceeded
+		}
+		if ms > 99999999 {
+			ms = 99999999 // the most digits grpc-timeout allows
+		}
+		headers = append(headers, hpackField{"grpc-timeout", strconv.FormatInt(ms, 10) + "m"})
+	}
+	headers = append(headers, metadata...)
+
+	body := make([]byte, 5, 5+len(request))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(request)))
+	body = append(body, request...)
+	resp, err := c.roundTrip(ctx, path, headers, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// A response without a body may carry its status in the headers.
+	status := resp.trailer
+	if _, ok := header(status, "grpc-status"); !ok {
+		status = resp.headers
+	}
+	code, ok := header(status, "grpc-status")
+	if !ok {
+		httpStatus := mustHeader(resp.headers, ":status")
+		if httpStatus == "404" {
+			return nil, &grpcStatusError{code: grpcUnimplemented, message: "HTTP status 404"}
+		}
+		return nil, fmt.Errorf("response without a gRPC status, HTTP status %s", httpStatus)
+	}
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gRPC status %q", code)
+	}
+	if n != 0 {
+		message := mustHeader(status, "grpc-message")
+		if unescaped, err := url.PathUnescape(message); err == nil {
+			message = unescaped
+		}
+		return nil, &grpcStatusError{code: n, message: message}
+	}
+
+	var messages [][]byte
+	for data := resp.body; len(data) > 0; {
+		if len(data) < 5 {
+			return nil, errors.New("truncated gRPC message")
+		}
+		if data[0] != 0 {
+			return nil, errors.New("compressed gRPC message")
+		}
+		length := binary.BigEndian.Uint32(data[1:])
+		if uint64(length) > uint64(len(data)-5) {
+			return nil, errors.New("truncated gRPC message")
+		}
+		messages = append(messages, data[5:5+length])
+		data = data[5+length:]
+	}
+	return messages, nil
+}
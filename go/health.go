@@ -0,0 +1,55 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// serverCounts are the request and error counts of one Server.
+type serverCounts struct {
+	requests atomic.Uint64 // JSON-RPC requests and notifications handled
+	errors   atomic.Uint64 // error responses sent
+}
+
+// memStatsMaxAge is how long health results reuse memory statistics, since
+// reading them briefly stops the world.
+const memStatsMaxAge = time.Second
+
+// memStatsCache holds the latest memory statistics.
+type memStatsCache struct {
+	mu     sync.Mutex
+	stats  runtime.MemStats
+	readAt time.Time
+}
+
+// get returns memory statistics at most memStatsMaxAge old.
+func (c *memStatsCache) get() runtime.MemStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.readAt) >= memStatsMaxAge {
+		runtime.ReadMemStats(&c.stats)
+		c.readAt = now
+	}
+	return c.stats
+}
+
+// healthResult builds the result of the "health" method: a cheap snapshot
+// that supervisors can poll over the same channel as regular requests.
+func (s *Server) healthResult() map[string]interface{} {
+	mem := s.memStats.get()
+	return map[string]interface{}{
+		"status":        "ok",
+		"uptimeSeconds": time.Since(s.started).Seconds(),
+		"tools":         len(s.tools),
+		"requests":      s.counts.requests.Load(),
+		"errors":        s.counts.errors.Load(),
+		"memory": map[string]interface{}{
+			"heapAllocBytes": mem.HeapAlloc,
+			"sysBytes":       mem.Sys,
+			"numGC":          mem.NumGC,
+			"goroutines":     runtime.NumGoroutine(),
+		},
+	}
+}
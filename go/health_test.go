@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"runtime"
+	"testing"
+)
+
+// Test the "health" method
+func TestHealth(t *testing.T) {
+	lines := runTestInput(t, `{"jsonrpc":"2.0","method":"tools/list","id":1}
+{"jsonrpc":"2.0","method":"health","id":2}`)
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines output, got %d lines", len(lines))
+	}
+	var resp struct {
+		Result struct {
+			Status   string                 `json:"status"`
+			Uptime   float64                `json:"uptimeSeconds"`
+			Tools    int                    `json:"tools"`
+			Requests uint64                 `json:"requests"`
+			Memory   map[string]interface{} `json:"memory"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Result.Status != "ok" {
+		t.Errorf("expected status=ok, got %q", resp.Result.Status)
+	}
+	if resp.Result.Tools != len(tools) {
+		t.Errorf("expected tools=%d, got %d", len(tools), resp.Result.Tools)
+	}
+	if resp.Result.Requests < 2 {
+		t.Errorf("expected at least 2 handled requests, got %d", resp.Result.Requests)
+	}
+	if _, ok := resp.Result.Memory["heapAllocBytes"]; !ok {
+		t.Errorf("expected memory usage in the result, got %v", resp.Result.Memory)
+	}
+}
+
+// Test that each server reports only its own requests and errors
+func TestHealthCounts(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"no_such_method","id":1}
+{"jsonrpc":"2.0","method":"health","id":2}`
+	for i := 0; i < 2; i++ {
+		lines := runServerInput(t, NewServer(), input)
+		var resp struct {
+			Result struct {
+				Requests uint64 `json:"requests"`
+				Errors   uint64 `json:"errors"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &resp); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		// The handshake, the unknown method, and the health request.
+		if resp.Result.Requests != 4 || resp.Result.Errors != 1 {
+			t.Errorf("server %d: expected 4 requests and 1 error, got %+v", i, resp.Result)
+		}
+	}
+}
+
+// Test that memory statistics are reused for a while
+func TestMemStatsCache(t *testing.T) {
+	var c memStatsCache
+	first := c.get()
+	runtime.GC()
+	if second := c.get(); second.NumGC != first.NumGC {
+		t.Errorf("expected cached statistics, got %d GCs after %d", second.NumGC, first.NumGC)
+	}
+	c.readAt = c.readAt.Add(-memStatsMaxAge)
+	if third := c.get(); third.NumGC == first.NumGC {
+		t.Errorf("expected fresh statistics once they are old, got %d GCs", third.NumGC)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Hooks are callbacks with which an application embedding the server sets
+// up per-session state, enforces policy, and records what clients do. Any
+// of them may be nil. They run on the goroutine handling the request, so
+// they should return quickly.
+type Hooks struct {
+	// OnSessionStart is called when a session begins: when a stdio client
+	// connects, or when an HTTP client's initialize creates its session.
+	OnSessionStart func(ctx context.Context, sess *Session)
+
+	// OnSessionEnd is called when a session ends: when a stdio client
+	// disconnects, or when an HTTP session is deleted, expires, is
+	// evicted, or the server shuts down. It runs once the session's
+	// requests have returned or the drain timeout gave up on them.
+	OnSessionEnd func(sess *Session)
+
+	// OnInitialize is called for each initialize request, once the
+	// session holds what the client sent.
+	OnInitialize func(ctx context.Context, sess *Session)
+
+	// BeforeToolCall is called before a tool runs, or before its cached
+	// result is returned. An error refuses the call: the client gets it
+	// as the result, flagged as an error.
+	BeforeToolCall func(ctx context.Context, call *ToolCall) error
+
+	// AfterToolCall is called once a call that BeforeToolCall let through
+	// has finished, with its result.
+	AfterToolCall func(ctx context.Context, call *ToolCall)
+}
+
+// ToolCall describes a tool call to the BeforeToolCall and AfterToolCall
+// hooks. The hooks must not modify Arguments.
+type ToolCall struct {
+	Session   *Session
+	Tool      string
+	Arguments map[string]interface{}
+	DryRun    bool // the call only describes what it would do
+
+	// Set for AfterToolCall.
+	Content  []ToolContent // the result, which for a failed call is its error result, if any
+	Err      error         // why the call failed, if it did
+	Duration time.Duration // zero for a cached result
+}
+
+// WithHooks registers h. Hooks registered by several options all run, in
+// the order of the options.
+func WithHooks(h Hooks) Option {
+	return func(s *Server) {
+		s.hooks = append(s.hooks, h)
+	}
+}
+
+// sessionStarted runs the OnSessionStart hooks.
+func (s *Server) sessionStarted(ctx context.Context, sess *Session) {
+	for _, h := range s.hooks {
+		if h.OnSessionStart != nil {
+			h.OnSessionStart(ctx, sess)
+		}
+	}
+}
+
+// sessionEnded runs the OnSessionEnd hooks, then cancels the session's
+// background tasks and releases its values.
+func (s *Server) sessionEnded(sess *Session) {
+	for _, h := range s.hooks {
+		if h.OnSessionEnd != nil {
+			h.OnSessionEnd(sess)
+		}
+	}
+	if s.tasks != nil {
+		s.tasks.endSession(sess)
+	}
+	if s.watcher != nil {
+		s.watcher.remove(sess)
+	}
+	sess.release(s.logger)
+}
+
+// initialized runs the OnInitialize hooks.
+func (s *Server) initialized(ctx context.Context, sess *Session) {
+	for _, h := range s.hooks {
+		if h.OnInitialize != nil {
+			h.OnInitialize(ctx, sess)
+		}
+	}
+}
+
+// beforeToolCall runs the BeforeToolCall hooks, stopping at the first
+// that refuses the call.
+func (s *Server) beforeToolCall(ctx context.Context, call *ToolCall) error {
+	for _, h := range s.hooks {
+		if h.BeforeToolCall != nil {
+			if err := h.BeforeToolCall(ctx, call); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// afterToolCall runs the AfterToolCall hooks.
+func (s *Server) afterToolCall(ctx context.Context, call *ToolCall) {
+	for _, h := range s.hooks {
+		if h.AfterToolCall != nil {
+			h.AfterToolCall(ctx, call)
+		}
+	}
+}
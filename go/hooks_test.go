@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// hookRecorder records the hooks called, in order.
+type hookRecorder struct {
+	mu     sync.Mutex
+	events []string
+	calls  []ToolCall
+}
+
+func (r *hookRecorder) record(event string) {
+	r.mu.Lock()
+	r.events = append(r.events, event)
+	r.mu.Unlock()
+}
+
+// hooks returns hooks recording into r. BeforeToolCall refuses calls of
+// echo with the message "secret".
+func (r *hookRecorder) hooks() Hooks {
+	return Hooks{
+		OnSessionStart: func(ctx context.Context, sess *Session) { r.record("start") },
+		OnSessionEnd:   func(sess *Session) { r.record("end") },
+		OnInitialize: func(ctx context.Context, sess *Session) {
+			info := sess.ClientInfo()
+			r.record("initialize " + info.Name + " " + info.Version)
+		},
+		BeforeToolCall: func(ctx context.Context, call *ToolCall) error {
+			r.record("before " + call.Tool)
+			if call.Arguments["message"] == "secret" {
+				return errors.New("policy forbids secrets")
+			}
+			return nil
+		},
+		AfterToolCall: func(ctx context.Context, call *ToolCall) {
+			r.mu.Lock()
+			r.calls = append(r.calls, *call)
+			r.mu.Unlock()
+			r.record("after " + call.Tool)
+		},
+	}
+}
+
+// Test the hooks of a stdio session
+func TestHooks(t *testing.T) {
+	var r hookRecorder
+	var second []*Session
+	// One call at a time keeps the hooks in order.
+	s := NewServer(WithTools(&echoTool{}, failingTool{}), WithMaxWorkers(1), WithHooks(r.hooks()),
+		WithHooks(Hooks{OnSessionStart: func(ctx context.Context, sess *Session) { second = append(second, sess) }}))
+	input := `{"jsonrpc":"2.0","method":"initialize","params":{"clientInfo":{"name":"host","version":"1.2"}},"id":0}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":1}` + "\n" +
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"secret"}},"id":2}` + "\n" +
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"fail","arguments":{}},"id":3}` + "\n"
+	output := strings.Join(runServerInput(t, s, input), "\n")
+
+	expected := "start, initialize host 1.2, before echo, after echo, before echo, before fail, after fail, end"
+	if got := strings.Join(r.events, ", "); got != expected {
+		t.Errorf("expected hooks %s, got %s", expected, got)
+	}
+	if !strings.Contains(output, `{"id":2,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Refused: policy forbids secrets"}],"isError":true}}`) {
+		t.Errorf("expected the refused call's error result, got %s", output)
+	}
+	if len(r.calls) != 2 || r.calls[0].Content[0].Text != "Echo: hi" || r.calls[0].Err != nil || r.calls[1].Err == nil || r.calls[1].Content[0].Text != "disk full" {
+		t.Errorf("unexpected calls %+v", r.calls)
+	}
+	if len(second) != 1 || len(r.calls) == 0 || second[0] != r.calls[0].Session || second[0].ID() == "" {
+		t.Errorf("expected every registration's hooks to see the session, got %v", second)
+	}
+}
+
+// Test that HTTP sessions start at initialize and end when deleted or at
+// shutdown
+func TestHTTPSessionHooks(t *testing.T) {
+	var r hookRecorder
+	tr := newHTTPTransport(NewServer(WithHooks(r.hooks())), nil)
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+
+	first := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{"clientInfo":{"name":"a","version":"1"}},"id":1}`).Header.Get(sessionIDHeader)
+	postMCP(t, ts.URL, first, `{"jsonrpc":"2.0","method":"ping","id":2}`)
+	postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`)
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(sessionIDHeader, first)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := strings.Join(r.events, ", "); got != "start, initialize a 1, start, initialize  , end" {
+		t.Errorf("unexpected hooks %s", got)
+	}
+	tr.endAll()
+	if got := strings.Join(r.events, ", "); !strings.HasSuffix(got, "end, end") || len(tr.sessions) != 0 {
+		t.Errorf("expected the remaining session to end, got %s", got)
+	}
+}
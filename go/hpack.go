@@ -0,0 +1,387 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// hpackField is a header field of an HTTP/2 header block.
+type hpackField struct {
+	name, value string
+}
+
+// hpackDefaultTableSize is the dynamic table size of RFC 7541 that every
+// HTTP/2 peer starts with, and the one the gRPC client keeps.
+const hpackDefaultTableSize = 4096
+
+// errHPACK is returned for malformed header blocks.
+var errHPACK = errors.New("hpack: malformed header block")
+
+// hpackDecoder decodes the header blocks a peer sends on one HTTP/2
+// connection (RFC 7541). It keeps the connection's dynamic table, so blocks
+// must be decoded in the order they arrive.
+type hpackDecoder struct {
+	dynamic []hpackField // newest first
+	size    int          // the table size as RFC 7541 counts it
+	maxSize int          // as set by the peer's size updates
+}
+
+// newHPACKDecoder returns a decoder with an empty dynamic table.
+func newHPACKDecoder() *hpackDecoder {
+	return &hpackDecoder{maxSize: hpackDefaultTableSize}
+}
+
+// decode decodes a complete header block.
+func (d *hpackDecoder) decode(block []byte) ([]hpackField, error) {
+	var fields []hpackField
+	for len(block) > 0 {
+		var f hpackField
+		var err error
+		switch b := block[0]; {
+		case b&0x80 != 0: // indexed field
+			var index uint64
+			if index, block, err = hpackInteger(block, 7); err != nil {
+				return nil, err
+			}
+			if f, err = d.field(index); err != nil {
+				return nil, err
+			}
+		case b&0xc0 == 0x40: // literal added to the table
+			if f, block, err = d.literal(block, 6); err != nil {
+				return nil, err
+			}
+			d.add(f)
+		case b&0xe0 == 0x20: // table size update
+			var size uint64
+			if size, block, err = hpackInteger(block, 5); err != nil {
+				return nil, err
+			}
+			if size > hpackDefaultTableSize {
+				return nil, errHPACK
+			}
+			d.maxSize = int(size)
+			d.evict()
+			continue
+		default: // literal not added, possibly never to be indexed
+			if f, block, err = d.literal(block, 4); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// field returns the table entry at index, which counts the static table
+// first.
+func (d *hpackDecoder) field(index uint64) (hpackField, error) {
+	switch {
+	case index == 0:
+		return hpackField{}, errHPACK
+	case index <= uint64(len(hpackStaticTable)):
+		return hpackStaticTable[index-1], nil
+	case index-uint64(len(hpackStaticTable)) <= uint64(len(d.dynamic)):
+		return d.dynamic[index-uint64(len(hpackStaticTable))-1], nil
+	}
+	return hpackField{}, errHPACK
+}
+
+// literal decodes a literal field whose name index has an n-bit prefix.
+func (d *hpackDecoder) literal(p []byte, n uint) (hpackField, []byte, error) {
+	index, p, err := hpackInteger(p, n)
+	if err != nil {
+		return hpackField{}, nil, err
+	}
+	var f hpackField
+	if index == 0 {
+		if f.name, p, err = hpackString(p); err != nil {
+			return hpackField{}, nil, err
+		}
+	} else {
+		named, err := d.field(index)
+		if err != nil {
+			return hpackField{}, nil, err
+		}
+		f.name = named.name
+	}
+	if f.value, p, err = hpackString(p); err != nil {
+		return hpackField{}, nil, err
+	}
+	return f, p, nil
+}
+
+// add inserts f into the dynamic table, evicting the oldest entries to
+// make room.
+func (d *hpackDecoder) add(f hpackField) {
+	d.dynamic = append([]hpackField{f}, d.dynamic...)
+	d.size += hpackEntrySize(f)
+	d.evict()
+}
+
+// evict removes the oldest entries until the table fits its maximum size.
+func (d *hpackDecoder) evict() {
+	for d.size > d.maxSize && len(d.dynamic) > 0 {
+		last := len(d.dynamic) - 1
+		d.size -= hpackEntrySize(d.dynamic[last])
+		d.dynamic = d.dynamic[:last]
+	}
+}
+
+// hpackEntrySize is the size of a table entry as RFC 7541 counts it.
+func hpackEntrySize(f hpackField) int {
+	return len(f.name) + len(f.value) + 32
+}
+
+// hpackInteger decodes an integer with an n-bit prefix from p.
+func hpackInteger(p []byte, n uint) (uint64, []byte, error) {
+	if len(p) == 0 {
+		return 0, nil, errHPACK
+	}
+	max := uint64(1)<<n - 1
+	v := uint64(p[0]) & max
+	p = p[1:]
+	if v < max {
+		return v, p, nil
+	}
+	for shift := uint(0); len(p) > 0; shift += 7 {
+		if shift > 28 {
+			return 0, nil, errHPACK // more than any table or string needs
+		}
+		b := p[0]
+		p = p[1:]
+		v += uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, p, nil
+		}
+	}
+	return 0, nil, errHPACK
+}
+
+// hpackString decodes a string literal from p.
+func hpackString(p []byte) (string, []byte, error) {
+	if len(p) == 0 {
+		return "", nil, errHPACK
+	}
+	huffman := p[0]&0x80 != 0
+	length, p, err := hpackInteger(p, 7)
+	if err != nil {
+		return "", nil, err
+	}
+	if length > uint64(len(p)) {
+		return "", nil, errHPACK
+	}
+	s, p := p[:length], p[length:]
+	if !huffman {
+		return string(s), p, nil
+	}
+	decoded, err := hpackHuffmanDecode(s)
+	return decoded, p, err
+}
+
+// hpackNode is a node of the Huffman decoding tree: an inner node has
+// children, a leaf a symbol.
+type hpackNode struct {
+	children [2]int32 // indexes into the tree, 0 if absent
+	sym      int16    // -1 for inner nodes
+}
+
+var (
+	hpackTreeOnce sync.Once
+	hpackTree     []hpackNode // the root is hpackTree[0]
+)
+
+// buildHPACKTree builds the decoding tree of the Huffman code.
+func buildHPACKTree() {
+	hpackTree = []hpackNode{{sym: -1}}
+	for sym, code := range hpackHuffmanCodes {
+		node := int32(0)
+		for i := int(hpackHuffmanLengths[sym]) - 1; i >= 0; i-- {
+			bit := code >> uint(i) & 1
+			next := hpackTree[node].children[bit]
+			if next == 0 {
+				hpackTree = append(hpackTree, hpackNode{sym: -1})
+				next = int32(len(hpackTree) - 1)
+				hpackTree[node].children[bit] = next
+			}
+			node = next
+		}
+		hpackTree[node].sym = int16(sym)
+	}
+}
+
+// hpackHuffmanDecode decodes a Huffman-coded string. The code is padded
+// to a whole byte with fewer than 8 one bits, the start of the EOS code.
+func hpackHuffmanDecode(p []byte) (string, error) {
+	hpackTreeOnce.Do(buildHPACKTree)
+	out := make([]byte, 0, len(p)*8/5)
+	node, pending, ones := int32(0), 0, true
+	for _, b := range p {
+		for i := 7; i >= 0; i-- {
+			bit := b >> uint(i) & 1
+			node = hpackTree[node].children[bit]
+			if node == 0 {
+				return "", errHPACK
+			}
+			pending++
+			ones = ones && bit == 1
+			if sym := hpackTree[node].sym; sym >= 0 {
+				out = append(out, byte(sym))
+				node, pending, ones = 0, 0, true
+			}
+		}
+	}
+	if pending > 7 || !ones {
+		return "", errHPACK
+	}
+	return string(out), nil
+}
+
+// appendHPACKLiteral appends f to a header block as a literal that is not
+// added to the table, so the encoder needs no state of its own.
+func appendHPACKLiteral(dst []byte, name, value string) []byte {
+	dst = append(dst, 0)
+	dst = appendHPACKString(dst, name)
+	return appendHPACKString(dst, value)
+}
+
+// appendHPACKString appends s as a string literal without Huffman coding.
+func appendHPACKString(dst []byte, s string) []byte {
+	dst = appendHPACKInteger(dst, 0, 7, uint64(len(s)))
+	return append(dst, s...)
+}
+
+// appendHPACKInteger appends v with an n-bit prefix, the other bits of the
+// first byte being those of first.
+func appendHPACKInteger(dst []byte, first byte, n uint, v uint64) []byte {
+	max := uint64(1)<<n - 1
+	if v < max {
+		return append(dst, first|byte(v))
+	}
+	dst = append(dst, first|byte(max))
+	for v -= max; v >= 0x80; v >>= 7 {
+		dst = append(dst, byte(v)|0x80)
+	}
+	return append(dst, byte(v))
+}
+
+// hpackStaticTable is the static table of RFC 7541, Appendix A. Index 1
+// is its first entry.
+var hpackStaticTable = [...]hpackField{
+	{":authority", ""},
+	{":method", "GET"},
+	{":method", "POST"},
+	{":path", "/"},
+	{":path", "/index.html"},
+	{":scheme", "http"},
+	{":scheme", "https"},
+	{":status", "200"},
+	{":status", "204"},
+	{":status", "206"},
+	{":status", "304"},
+	{":status", "400"},
+	{":status", "404"},
+	{":status", "500"},
+	{"accept-charset", ""},
+	{"accept-encoding", "gzip, deflate"},
+	{"accept-language", ""},
+	{"accept-ranges", ""},
+	{"accept", ""},
+	{"access-control-allow-origin", ""},
+	{"age", ""},
+	{"allow", ""},
+	{"authorization", ""},
+	{"cache-control", ""},
+	{"content-disposition", ""},
+	{"content-encoding", ""},
+	{"content-language", ""},
+	{"content-length", ""},
+	{"content-location", ""},
+	{"content-range", ""},
+	{"content-type", ""},
+	{"cookie", ""},
+	{"date", ""},
+	{"etag", ""},
+	{"expect", ""},
+	{"expires", ""},
+	{"from", ""},
+	{"host", ""},
+	{"if-match", ""},
+	{"if-modified-since", ""},
+	{"if-none-match", ""},
+	{"if-range", ""},
+	{"if-unmodified-since", ""},
+	{"last-modified", ""},
+	{"link", ""},
+	{"location", ""},
+	{"max-forwards", ""},
+	{"proxy-authenticate", ""},
+	{"proxy-authorization", ""},
+	{"range", ""},
+	{"referer", ""},
+	{"refresh", ""},
+	{"retry-after", ""},
+	{"server", ""},
+	{"set-cookie", ""},
+	{"strict-transport-security", ""},
+	{"transfer-encoding", ""},
+	{"user-agent", ""},
+	{"vary", ""},
+	{"via", ""},
+	{"www-authenticate", ""},
+}
+
+// hpackHuffmanCodes and hpackHuffmanLengths are the Huffman code of each
+// byte, from RFC 7541, Appendix B.
+var hpackHuffmanCodes = [256]uint32{
+	0x1ff8, 0x7fffd8, 0xfffffe2, 0xfffffe3, 0xfffffe4, 0xfffffe5, 0xfffffe6, 0xfffffe7,
+	0xfffffe8, 0xffffea, 0x3ffffffc, 0xfffffe9, 0xfffffea, 0x3ffffffd, 0xfffffeb, 0xfffffec,
+	0xfffffed, 0xfffffee, 0xfffffef, 0xffffff0, 0xffffff1, 0xffffff2, 0x3ffffffe, 0xffffff3,
+	0xffffff4, 0xffffff5, 0xffffff6, 0xffffff7, 0xffffff8, 0xffffff9, 0xffffffa, 0xffffffb,
+	0x14, 0x3f8, 0x3f9, 0xffa, 0x1ff9, 0x15, 0xf8, 0x7fa,
+	0x3fa, 0x3fb, 0xf9, 0x7fb, 0xfa, 0x16, 0x17, 0x18,
+	0x0, 0x1, 0x2, 0x19, 0x1a, 0x1b, 0x1c, 0x1d,
+	0x1e, 0x1f, 0x5c, 0xfb, 0x7ffc, 0x20, 0xffb, 0x3fc,
+	0x1ffa, 0x21, 0x5d, 0x5e, 0x5f, 0x60, 0x61, 0x62,
+	0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69, 0x6a,
+	0x6b, 0x6c, 0x6d, 0x6e, 0x6f, 0x70, 0x71, 0x72,
+	0xfc, 0x73, 0xfd, 0x1ffb, 0x7fff0, 0x1ffc, 0x3ffc, 0x22,
+	0x7ffd, 0x3, 0x23, 0x4, 0x24, 0x5, 0x25, 0x26,
+	0x27, 0x6, 0x74, 0x75, 0x28, 0x29, 0x2a, 0x7,
+	0x2b, 0x76, 0x2c, 0x8, 0x9, 0x2d, 0x77, 0x78,
+	0x79, 0x7a, 0x7b, 0x7ffe, 0x7fc, 0x3ffd, 0x1ffd, 0xffffffc,
+	0xfffe6, 0x3fffd2, 0xfffe7, 0xfffe8, 0x3fffd3, 0x3fffd4, 0x3fffd5, 0x7fffd9,
+	0x3fffd6, 0x7fffda, 0x7fffdb, 0x7fffdc, 0x7fffdd, 0x7fffde, 0xffffeb, 0x7fffdf,
+	0xffffec, 0xffffed, 0x3fffd7, 0x7fffe0, 0xffffee, 0x7fffe1, 0x7fffe2, 0x7fffe3,
+	0x7fffe4, 0x1fffdc, 0x3fffd8, 0x7fffe5, 0x3fffd9, 0x7fffe6, 0x7fffe7, 0xffffef,
+	0x3fffda, 0x1fffdd, 0xfffe9, 0x3fffdb, 0x3fffdc, 0x7fffe8, 0x7fffe9, 0x1fffde,
+	0x7fffea, 0x3fffdd, 0x3fffde, 0xfffff0, 0x1fffdf, 0x3fffdf, 0x7fffeb, 0x7fffec,
+	0x1fffe0, 0x1fffe1, 0x3fffe0, 0x1fffe2, 0x7fffed, 0x3fffe1, 0x7fffee, 0x7fffef,
+	0xfffea, 0x3fffe2, 0x3fffe3, 0x3fffe4, 0x7ffff0, 0x3fffe5, 0x3fffe6, 0x7ffff1,
+	0x3ffffe0, 0x3ffffe1, 0xfffeb, 0x7fff1, 0x3fffe7, 0x7ffff2, 0x3fffe8, 0x1ffffec,
+	0x3ffffe2, 0x3ffffe3, 0x3ffffe4, 0x7ffffde, 0x7ffffdf, 0x3ffffe5, 0xfffff1, 0x1ffffed,
+	0x7fff2, 0x1fffe3, 0x3ffffe6, 0x7ffffe0, 0x7ffffe1, 0x3ffffe7, 0x7ffffe2, 0xfffff2,
+	0x1fffe4, 0x1fffe5, 0x3ffffe8, 0x3ffffe9, 0xffffffd, 0x7ffffe3, 0x7ffffe4, 0x7ffffe5,
+	0xfffec, 0xfffff3, 0xfffed, 0x1fffe6, 0x3fffe9, 0x1fffe7, 0x1fffe8, 0x7ffff3,
+	0x3fffea, 0x3fffeb, 0x1ffffee, 0x1ffffef, 0xfffff4, 0xfffff5, 0x3ffffea, 0x7ffff4,
+	0x3ffffeb, 0x7ffffe6, 0x3ffffec, 0x3ffffed, 0x7ffffe7, 0x7ffffe8, 0x7ffffe9, 0x7ffffea,
+	0x7ffffeb, 0xffffffe, 0x7ffffec, 0x7ffffed, 0x7ffffee, 0x7ffffef, 0x7fffff0, 0x3ffffee,
+}
+
+var hpackHuffmanLengths = [256]uint8{
+	13, 23, 28, 28, 28, 28, 28, 28, 28, 24, 30, 28, 28, 30, 28, 28,
+	28, 28, 28, 28, 28, 28, 30, 28, 28, 28, 28, 28, 28, 28, 28, 28,
+	6, 10, 10, 12, 13, 6, 8, 11, 10, 10, 8, 11, 8, 6, 6, 6,
+	5, 5, 5, 6, 6, 6, 6, 6, 6, 6, 7, 8, 15, 6, 12, 10,
+	13, 6, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 7,
+	7, 7, 7, 7, 7, 7, 7, 7, 8, 7, 8, 13, 19, 13, 14, 6,
+	15, 5, 6, 5, 6, 5, 6, 6, 6, 5, 7, 7, 6, 6, 6, 5,
+	6, 7, 6, 5, 5, 6, 7, 7, 7, 7, 7, 15, 11, 14, 13, 28,
+	20, 22, 20, 20, 22, 22, 22, 23, 22, 23, 23, 23, 23, 23, 24, 23,
+	24, 24, 22, 23, 24, 23, 23, 23, 23, 21, 22, 23, 22, 23, 23, 24,
+	22, 21, 20, 22, 22, 23, 23, 21, 23, 22, 22, 24, 21, 22, 23, 23,
+	21, 21, 22, 21, 23, 22, 23, 23, 20, 22, 22, 22, 23, 22, 22, 23,
+	26, 26, 20, 19, 22, 23, 22, 25, 26, 26, 26, 27, 27, 26, 24, 25,
+	19, 21, 26, 27, 27, 26, 27, 24, 21, 21, 26, 26, 28, 27, 27, 27,
+	20, 24, 20, 21, 22, 21, 21, 23, 22, 22, 25, 25, 24, 24, 26, 23,
+	26, 27, 26, 26, 27, 27, 27, 27, 27, 28, 27, 27, 27, 27, 27, 26,
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+// Test decoding the requests of RFC 7541 appendix C.4, which share a
+// dynamic table and Huffman-code their strings
+func TestHPACKDecode(t *testing.T) {
+	d := newHPACKDecoder()
+	for _, tc := range []struct {
+		block    string
+		expected []hpackField
+	}{
+		{"828684418cf1e3c2e5f23a6ba0ab90f4ff", []hpackField{
+			{":method", "GET"}, {":scheme", "http"}, {":path", "/"}, {":authority", "www.example.com"},
+		}},
+		{"828684be5886a8eb10649cbf", []hpackField{
+			{":method", "GET"}, {":scheme", "http"}, {":path", "/"}, {":authority", "www.example.com"},
+			{"cache-control", "no-cache"},
+		}},
+		{"828785bf408825a849e95ba97d7f8925a849e95bb8e8b4bf", []hpackField{
+			{":method", "GET"}, {":scheme", "https"}, {":path", "/index.html"}, {":authority", "www.example.com"},
+			{"custom-key", "custom-value"},
+		}},
+	} {
+		block, _ := hex.DecodeString(tc.block)
+		fields, err := d.decode(block)
+		if err != nil {
+			t.Fatalf("decode(%s) error: %v", tc.block, err)
+		}
+		if !reflect.DeepEqual(fields, tc.expected) {
+			t.Errorf("decode(%s) = %v, expected %v", tc.block, fields, tc.expected)
+		}
+	}
+	if d.size != 164 {
+		t.Errorf("expected a dynamic table of 164 bytes, got %d", d.size)
+	}
+}
+
+// Test that encoded fields decode to themselves
+func TestHPACKRoundTrip(t *testing.T) {
+	fields := []hpackField{{":path", "/pkg.Service/Method"}, {"long", string(make([]byte, 300))}}
+	var block []byte
+	for _, f := range fields {
+		block = appendHPACKLiteral(block, f.name, f.value)
+	}
+	decoded, err := newHPACKDecoder().decode(block)
+	if err != nil || !reflect.DeepEqual(decoded, fields) {
+		t.Errorf("decode = %v, %v, expected %v", decoded, err, fields)
+	}
+}
+
+// Test that malformed blocks are rejected
+func TestHPACKMalformed(t *testing.T) {
+	for _, block := range []string{
+		"80",         // index 0
+		"be",         // index 62, beyond an empty dynamic table
+		"418cf1e3",   // truncated string
+		"4181fe",     // Huffman padding that is not all ones
+		"3fe21f",     // table size above the limit
+		"ffffffffff", // unterminated integer
+	} {
+		b, _ := hex.DecodeString(block)
+		if _, err := newHPACKDecoder().decode(b); err == nil {
+			t.Errorf("expected decode(%s) to fail", block)
+		}
+	}
+}
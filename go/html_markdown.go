@@ -0,0 +1,667 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The standard library has no HTML parser, so read_webpage uses this one.
+// It is lenient rather than conformant: it builds a tree from the tags it
+// finds, closes the elements HTML lets authors leave open where it matters
+// for the text (paragraphs, list items, table cells), and ignores end tags
+// with no open element. That is enough to find a page's main content and
+// render it as Markdown.
+
+// htmlNode is an element, or a text node if tag is empty.
+type htmlNode struct {
+	tag      string
+	attrs    map[string]string
+	text     string
+	children []*htmlNode
+	parent   *htmlNode
+}
+
+// attr returns the value of the named attribute, or "".
+func (n *htmlNode) attr(name string) string {
+	return n.attrs[name]
+}
+
+// htmlVoidElements have no content and no end tag.
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// htmlRawTextElements contain text up to their end tag, never markup.
+var htmlRawTextElements = map[string]bool{"script": true, "style": true, "textarea": true, "title": true, "xmp": true}
+
+// htmlClosesParagraph lists the elements whose start tag ends an open p.
+var htmlClosesParagraph = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "div": true, "dl": true,
+	"fieldset": true, "figure": true, "footer": true, "form": true, "h1": true, "h2": true, "h3": true,
+	"h4": true, "h5": true, "h6": true, "header": true, "hr": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+// parseHTML parses s into a tree under a root node with an empty tag and
+// no text.
+func parseHTML(s string) *htmlNode {
+	root := &htmlNode{}
+	cur := root
+	appendChild := func(n *htmlNode) {
+		n.parent = cur
+		cur.children = append(cur.children, n)
+	}
+	// closeTo closes the innermost open element named tag, unless one of
+	// the elements in scope is open inside it first.
+	closeTo := func(tag string, scope ...string) bool {
+		for n := cur; n != root; n = n.parent {
+			if n.tag == tag {
+				cur = n.parent
+				return true
+			}
+			for _, s := range scope {
+				if n.tag == s {
+					return false
+				}
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(s); {
+		if s[i] != '<' {
+			end := strings.IndexByte(s[i:], '<')
+			if end < 0 {
+				end = len(s) - i
+			}
+			appendChild(&htmlNode{text: html.UnescapeString(s[i : i+end])})
+			i += end
+			continue
+		}
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return root
+			}
+			i += 4 + end + 3
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+		case len(rest) > 2 && rest[1] == '/' && isASCIILetter(rest[2]):
+			name, n := htmlTagName(rest[2:])
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += max(end+1, 2+n)
+			switch name {
+			case "li":
+				closeTo(name, "ul", "ol")
+			case "td", "th":
+				closeTo(name, "tr", "table")
+			case "tr":
+				closeTo(name, "table")
+			default:
+				closeTo(name)
+			}
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			name, n := htmlTagName(rest[1:])
+			attrs, selfClosing, size := htmlAttributes(rest[1+n:])
+			i += 1 + n + size
+			switch {
+			case name == "li":
+				closeTo("li", "ul", "ol")
+			case name == "dt" || name == "dd":
+				if !closeTo("dt", "dl") {
+					closeTo("dd", "dl")
+				}
+			case name == "td" || name == "th":
+				if !closeTo("td", "tr", "table") {
+					closeTo("th", "tr", "table")
+				}
+			case name == "tr":
+				closeTo("tr", "table")
+			case name == "option":
+				closeTo("option", "select")
+			}
+			if htmlClosesParagraph[name] {
+				closeTo("p", "div", "li", "td", "th", "blockquote", "section", "article", "main")
+			}
+			n2 := &htmlNode{tag: name, attrs: attrs}
+			appendChild(n2)
+			if htmlRawTextElements[name] {
+				end := indexFold(s[i:], "</"+name)
+				if end < 0 {
+					end = len(s) - i
+				}
+				text := s[i : i+end]
+				if name == "title" || name == "textarea" {
+					text = html.UnescapeString(text)
+				}
+				n2.children = []*htmlNode{{text: text, parent: n2}}
+				i += end
+				if gt := strings.IndexByte(s[i:], '>'); gt >= 0 {
+					i += gt + 1
+				}
+				continue
+			}
+			if !selfClosing && !htmlVoidElements[name] {
+				cur = n2
+			}
+		default:
+			appendChild(&htmlNode{text: "<"})
+			i++
+		}
+	}
+	return root
+}
+
+// isASCIILetter reports whether c is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// htmlTagName returns the lowercased tag name at the start of s and its
+// length.
+func htmlTagName(s string) (string, int) {
+	n := 0
+	for n < len(s) && !strings.ContainsRune(" \t\r\n\f/>", rune(s[n])) {
+		n++
+	}
+	return strings.ToLower(s[:n]), n
+}
+
+// htmlAttributes parses the attributes of a start tag from s, which follows
+// its name, up to and including its '>'. It returns them by lowercased
+// name, whether the tag ends in "/>", and the bytes consumed.
+func htmlAttributes(s string) (map[string]string, bool, int) {
+	attrs := map[string]string{}
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '>':
+			return attrs, false, i + 1
+		case c == '/' && i+1 < len(s) && s[i+1] == '>':
+			return attrs, true, i + 2
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == '/':
+			i++
+			continue
+		}
+		start := i
+		for i < len(s) && !strings.ContainsRune(" \t\r\n\f/>=", rune(s[i])) {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		for i < len(s) && strings.ContainsRune(" \t\r\n\f", rune(s[i])) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && strings.ContainsRune(" \t\r\n\f", rune(s[i])) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				q := s[i]
+				end := strings.IndexByte(s[i+1:], q)
+				if end < 0 {
+					return attrs, false, len(s)
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !strings.ContainsRune(" \t\r\n\f>", rune(s[i])) {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		if _, dup := attrs[name]; !dup && name != "" {
+			attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return attrs, false, len(s)
+}
+
+// indexFold is strings.Index ignoring ASCII case, for lowercase substr.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// find returns the first element named tag in the tree under n, depth
+// first.
+func (n *htmlNode) find(tag string) *htmlNode {
+	for _, c := range n.children {
+		if c.tag == tag {
+			return c
+		}
+		if f := c.find(tag); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// findAll returns the elements named tag under n, in document order.
+func (n *htmlNode) findAll(tag string, out []*htmlNode) []*htmlNode {
+	for _, c := range n.children {
+		if c.tag == tag {
+			out = append(out, c)
+		}
+		out = c.findAll(tag, out)
+	}
+	return out
+}
+
+// textContent returns the text under n as written.
+func (n *htmlNode) textContent() string {
+	if n.tag == "" && n.children == nil {
+		return n.text
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.textContent())
+	}
+	return b.String()
+}
+
+// htmlSkippedElements never hold a page's reading content.
+var htmlSkippedElements = map[string]bool{
+	"aside": true, "button": true, "canvas": true, "dialog": true, "embed": true, "footer": true,
+	"form": true, "head": true, "header": true, "iframe": true, "input": true, "link": true, "menu": true,
+	"meta": true, "nav": true, "noscript": true, "object": true, "script": true, "select": true,
+	"style": true, "svg": true, "template": true, "textarea": true,
+}
+
+// htmlBoilerplateRoles are ARIA landmark roles around, not in, the content.
+var htmlBoilerplateRoles = map[string]bool{
+	"banner": true, "complementary": true, "contentinfo": true, "dialog": true, "navigation": true, "search": true,
+}
+
+// htmlBoilerplateWords, found as a word of an element's class or id, mark
+// the navigation, advertising and other chrome of a page.
+var htmlBoilerplateWords = map[string]bool{
+	"ad": true, "ads": true, "advert": true, "advertisement": true, "banner": true, "breadcrumb": true,
+	"breadcrumbs": true, "comments": true, "cookie": true, "cookies": true, "footer": true, "menu": true,
+	"modal": true, "nav": true, "navbar": true, "navigation": true, "newsletter": true, "popup": true,
+	"promo": true, "related": true, "share": true, "sidebar": true, "social": true, "sponsored": true,
+}
+
+// isBoilerplate reports whether the element n is page chrome to leave out.
+func isBoilerplate(n *htmlNode) bool {
+	if htmlSkippedElements[n.tag] || htmlBoilerplateRoles[n.attr("role")] {
+		return true
+	}
+	if _, hidden := n.attrs["hidden"]; hidden || n.attr("aria-hidden") == "true" {
+		return true
+	}
+	words := strings.FieldsFunc(strings.ToLower(n.attr("class")+" "+n.attr("id")), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '_' || r == '\t' || r == '\n'
+	})
+	for _, w := range words {
+		if htmlBoilerplateWords[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// mainContent returns the element holding the page's reading content: its
+// main element, else its article with the most text, else its body.
+func mainContent(root *htmlNode) *htmlNode {
+	if m := root.find("main"); m != nil {
+		return m
+	}
+	var best *htmlNode
+	bestLen := 0
+	for _, a := range root.findAll("article", nil) {
+		if l := len(strings.TrimSpace(a.textContent())); l > bestLen {
+			best, bestLen = a, l
+		}
+	}
+	if best != nil {
+		return best
+	}
+	if b := root.find("body"); b != nil {
+		return b
+	}
+	return root
+}
+
+// htmlToMarkdown renders the content of n as Markdown, leaving out
+// boilerplate and resolving links against base.
+func htmlToMarkdown(n *htmlNode, base *url.URL) string {
+	r := &markdownRenderer{base: base}
+	return strings.Join(r.blocks(n), "\n\n")
+}
+
+// markdownRenderer renders a tree as Markdown blocks.
+type markdownRenderer struct {
+	base *url.URL
+}
+
+// htmlInlineElements are rendered within the text of a block.
+var htmlInlineElements = map[string]bool{
+	"a": true, "abbr": true, "b": true, "bdi": true, "bdo": true, "br": true, "cite": true, "code": true,
+	"data": true, "del": true, "dfn": true, "em": true, "font": true, "i": true, "img": true, "ins": true,
+	"kbd": true, "label": true, "mark": true, "q": true, "s": true, "samp": true, "small": true,
+	"span": true, "strike": true, "strong": true, "sub": true, "sup": true, "time": true, "tt": true,
+	"u": true, "var": true, "wbr": true,
+}
+
+// blocks renders the children of n as a list of Markdown blocks: runs of
+// text and inline elements become paragraphs.
+func (r *markdownRenderer) blocks(n *htmlNode) []string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if p := tidyInline(inline.String()); p != "" {
+			out = append(out, p)
+		}
+		inline.Reset()
+	}
+	for _, c := range n.children {
+		if c.tag == "" || htmlInlineElements[c.tag] {
+			if c.tag == "" || !isBoilerplate(c) {
+				inline.WriteString(r.inline(c))
+			}
+			continue
+		}
+		flush()
+		if !isBoilerplate(c) {
+			out = append(out, r.block(c)...)
+		}
+	}
+	flush()
+	return out
+}
+
+// block renders the block element n.
+func (r *markdownRenderer) block(n *htmlNode) []string {
+	switch n.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		if text := tidyInline(r.inlineChildren(n)); text != "" {
+			level, _ := strconv.Atoi(n.tag[1:])
+			return []string{strings.Repeat("#", level) + " " + strings.ReplaceAll(text, "\n", " ")}
+		}
+		return nil
+	case "p":
+		if text := tidyInline(r.inlineChildren(n)); text != "" {
+			return []string{text}
+		}
+		return nil
+	case "hr":
+		return []string{"---"}
+	case "pre":
+		code := strings.Trim(n.textContent(), "\n")
+		if strings.TrimSpace(code) == "" {
+			return nil
+		}
+		fence := "```"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return []string{fence + "\n" + code + "\n" + fence}
+	case "blockquote":
+		inner := strings.Join(r.blocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		return []string{prefixLines(inner, "> ", "> ")}
+	case "ul", "ol":
+		return r.list(n)
+	case "table":
+		return r.table(n)
+	case "dt":
+		if text := tidyInline(r.inlineChildren(n)); text != "" {
+			return []string{"**" + text + "**"}
+		}
+		return nil
+	case "img":
+		return []string{r.inline(n)}
+	}
+	return r.blocks(n)
+}
+
+// list renders a ul or ol element as one block.
+func (r *markdownRenderer) list(n *htmlNode) []string {
+	number := 1
+	if start, err := strconv.Atoi(n.attr("start")); err == nil {
+		number = start
+	}
+	var items []string
+	for _, c := range n.children {
+		if c.tag != "li" || isBoilerplate(c) {
+			continue
+		}
+		body := strings.Join(r.blocks(c), "\n")
+		if body == "" {
+			continue
+		}
+		marker := "- "
+		if n.tag == "ol" {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		items = append(items, prefixLines(body, marker, strings.Repeat(" ", len(marker))))
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return []string{strings.Join(items, "\n")}
+}
+
+// table renders a table as a Markdown table whose first row is the
+// header.
+func (r *markdownRenderer) table(n *htmlNode) []string {
+	var rows [][]string
+	width := 0
+	for _, tr := range n.findAll("tr", nil) {
+		var cells []string
+		for _, c := range tr.children {
+			if c.tag == "td" || c.tag == "th" {
+				text := tidyInline(r.inlineChildren(c))
+				cells = append(cells, strings.ReplaceAll(strings.ReplaceAll(text, "\n", " "), "|", `\|`))
+			}
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+			width = max(width, len(cells))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |")
+		if i == 0 {
+			b.WriteString("\n|" + strings.Repeat(" --- |", width))
+		}
+		if i < len(rows)-1 {
+			b.WriteByte('\n')
+		}
+	}
+	return []string{b.String()}
+}
+
+// inlineChildren renders the children of n as inline text.
+func (r *markdownRenderer) inlineChildren(n *htmlNode) string {
+	var b strings.Builder
+	for _, c := range n.children {
+		if c.tag == "" || !isBoilerplate(c) {
+			b.WriteString(r.inline(c))
+		}
+	}
+	return b.String()
+}
+
+// inline renders n as inline text. Whitespace is collapsed later, by
+// tidyInline; a line break is kept as a newline.
+func (r *markdownRenderer) inline(n *htmlNode) string {
+	switch n.tag {
+	case "":
+		return escapeMarkdown(n.text)
+	case "br":
+		return "\n"
+	case "img":
+		src := r.resolve(n.attr("src"))
+		if src == "" {
+			return ""
+		}
+		return "![" + escapeMarkdown(collapseSpace(n.attr("alt"))) + "](" + src + ")"
+	}
+	text := r.inlineChildren(n)
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	switch n.tag {
+	case "a":
+		href := r.resolve(n.attr("href"))
+		if href == "" || strings.HasPrefix(href, "#") {
+			return text
+		}
+		return "[" + strings.TrimSpace(text) + "](" + href + ")"
+	case "strong", "b":
+		return surround(text, "**")
+	case "em", "i", "cite", "dfn":
+		return surround(text, "*")
+	case "del", "s", "strike":
+		return surround(text, "~~")
+	case "code", "kbd", "samp", "tt":
+		code := strings.TrimSpace(n.textContent())
+		fence := "`"
+		for strings.Contains(code, fence) {
+			fence += "`"
+		}
+		return leadingSpace(text) + fence + code + fence + trailingSpace(text)
+	}
+	if !htmlInlineElements[n.tag] {
+		// A block inside inline content: keep its words apart.
+		return " " + text + " "
+	}
+	return text
+}
+
+// resolve returns ref resolved against the page's URL, or "" for
+// references that lead nowhere a reader could follow.
+func (r *markdownRenderer) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ref
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if r.base != nil {
+		u = r.base.ResolveReference(u)
+	}
+	switch u.Scheme {
+	case "http", "https", "mailto":
+	default:
+		return ""
+	}
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(u.String())
+}
+
+// surround wraps the words of text in the marker, keeping the spaces
+// around them outside.
+func surround(text, marker string) string {
+	return leadingSpace(text) + marker + strings.TrimSpace(text) + marker + trailingSpace(text)
+}
+
+// leadingSpace returns " " if text starts with whitespace.
+func leadingSpace(text string) string {
+	if t := strings.TrimLeft(text, " \t\r\n\f"); len(t) < len(text) {
+		return " "
+	}
+	return ""
+}
+
+// trailingSpace returns " " if text ends with whitespace.
+func trailingSpace(text string) string {
+	if t := strings.TrimRight(text, " \t\r\n\f"); len(t) < len(text) {
+		return " "
+	}
+	return ""
+}
+
+// markdownEscaper escapes the characters that would otherwise format text.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", "&lt;")
+
+// escapeMarkdown escapes text for Markdown, collapsing its whitespace
+// into single spaces.
+func escapeMarkdown(text string) string {
+	return markdownEscaper.Replace(collapseSpace(text))
+}
+
+// collapseSpace replaces each run of whitespace in s with a single space.
+func collapseSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' || r == '\u00a0' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	if space {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// tidyInline collapses the spaces of rendered inline text and trims its
+// lines.
+func tidyInline(s string) string {
+	lines := strings.Split(s, "\n")
+	var kept []string
+	for _, l := range lines {
+		if l = strings.TrimSpace(collapseSpace(l)); l != "" {
+			kept = append(kept, l)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// prefixLines prefixes the first line of s with first and the others with
+// rest.
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		if l == "" {
+			lines[i] = strings.TrimRight(p, " ")
+		} else {
+			lines[i] = p + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// Test rendering the main content of pages as Markdown
+func TestHTMLToMarkdown(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+	for _, tc := range []struct {
+		name, html, expected string
+	}{
+		{
+			name:     "headings and paragraphs",
+			html:     "<h1>Title</h1><p>Some <b>bold</b> and <em>emphasized</em>\n   text.<p>Another &amp; last.",
+			expected: "# Title\n\nSome **bold** and *emphasized* text.\n\nAnother & last.",
+		},
+		{
+			name:     "links and images resolve against the base",
+			html:     `<p>See <a href="../about">the page</a> and <img src="/a.png" alt="a chart">.</p>`,
+			expected: "See [the page](https://example.com/about) and ![a chart](https://example.com/a.png).",
+		},
+		{
+			name:     "lists",
+			html:     "<ul><li>one<li>two<ol><li>nested</ol></ul>",
+			expected: "- one\n- two\n  1. nested",
+		},
+		{
+			name:     "tables",
+			html:     "<table><tr><th>Name<th>Size<tr><td>a|b<td>1</table>",
+			expected: "| Name | Size |\n| --- | --- |\n| a\\|b | 1 |",
+		},
+		{
+			name:     "code",
+			html:     "<p>Run <code>go test</code>:</p><pre><code>go test ./...\n  -v</code></pre>",
+			expected: "Run `go test`:\n\n```\ngo test ./...\n  -v\n```",
+		},
+		{
+			name:     "Markdown in text is escaped",
+			html:     "<p>2 * 3 = [six]</p>",
+			expected: `2 \* 3 = \[six\]`,
+		},
+		{
+			name: "boilerplate is dropped",
+			html: `<body><nav><a href="/">Home</a></nav><div class="sidebar">Ads</div>
+				<script>var x = "<p>no</p>";</script><p>Content</p><p hidden>Hidden</p>
+				<footer>Copyright</footer></body>`,
+			expected: "Content",
+		},
+		{
+			name:     "main is preferred",
+			html:     "<body><p>Intro</p><main><p>Article</p></main></body>",
+			expected: "Article",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := htmlToMarkdown(mainContent(parseHTML(tc.html)), base)
+			if got != tc.expected {
+				t.Errorf("expected\n%s\ngot\n%s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// Test that malformed markup parses without losing text
+func TestParseHTMLMalformed(t *testing.T) {
+	for _, s := range []string{
+		"<div><p>unclosed <b>bold",
+		"</p></div>stray end tags",
+		"<p a=1 b='2' c=\"3\" d>attributes</p>",
+		"a < b and <!-- comment --> c",
+		"<p>text<",
+		"<script>never closed",
+	} {
+		root := parseHTML(s)
+		if text := root.textContent(); strings.Contains(s, "text") && !strings.Contains(text, "text") {
+			t.Errorf("%q: lost the text, got %q", s, text)
+		}
+	}
+	p := parseHTML(`<p a=1 b='2' c="3" d>x</p>`).find("p")
+	if p == nil || p.attr("a") != "1" || p.attr("b") != "2" || p.attr("c") != "3" {
+		t.Errorf("unexpected attributes %v", p)
+	}
+}
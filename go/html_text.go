@@ -0,0 +1,299 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of the html_to_text tool.
+const (
+	defaultHTMLTextLength = 20000
+	maxHTMLTextLength     = 200000
+)
+
+// htmlNonTextElements hold no text a reader sees, even outside the page's
+// main content.
+var htmlNonTextElements = map[string]bool{
+	"canvas": true, "embed": true, "head": true, "iframe": true, "link": true, "meta": true, "noscript": true,
+	"object": true, "script": true, "style": true, "svg": true, "template": true,
+}
+
+// htmlToTextTool converts HTML to plain text laid out for reading.
+type htmlToTextTool struct{}
+
+// htmlToTextArgs are the arguments of the html_to_text tool.
+type htmlToTextArgs struct {
+	HTML        string `json:"html" description:"The HTML document or fragment"`
+	BaseURL     string `json:"base_url,omitempty" description:"The URL of the document, to make relative links absolute"`
+	MainContent bool   `json:"main_content,omitempty" description:"Keep only the main content, leaving out navigation, headers, footers, sidebars, and forms"`
+	Links       string `json:"links,omitempty" enum:"inline,references,none" description:"Show link targets after the link text, as numbered references at the end, or not at all (default inline)"`
+	MaxLength   int    `json:"max_length,omitempty" minimum:"100" maximum:"200000" description:"Most characters of text to return (default 20000)"`
+}
+
+// Name returns the name of the html_to_text tool.
+func (t *htmlToTextTool) Name() string {
+	return "html_to_text"
+}
+
+// Description returns a brief description of the html_to_text tool.
+func (t *htmlToTextTool) Description() string {
+	return "Converts HTML to readable plain text, keeping headings, lists, tables, and links, optionally only the main content"
+}
+
+// InputSchema returns the JSON schema for the html_to_text tool's input
+// parameters.
+func (t *htmlToTextTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(htmlToTextArgs{})
+}
+
+// Annotations marks the html_to_text tool as read-only. It fetches
+// nothing, not even the links it shows.
+func (t *htmlToTextTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("HTML to text")
+}
+
+// Execute converts the HTML and returns the text, cut at max_length
+// characters with a final "[…]".
+func (t *htmlToTextTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	a := htmlToTextArgs{Links: "inline", MaxLength: defaultHTMLTextLength}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.MaxLength < 100 || a.MaxLength > maxHTMLTextLength {
+		return nil, fmt.Errorf("invalid value for 'max_length'")
+	}
+	switch a.Links {
+	case "inline", "references", "none":
+	default:
+		return nil, fmt.Errorf("invalid value for 'links'")
+	}
+	var base *url.URL
+	if a.BaseURL != "" {
+		u, err := url.Parse(a.BaseURL)
+		if err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("invalid value for 'base_url': expected an absolute URL")
+		}
+		base = u
+	}
+
+	root := parseHTML(a.HTML)
+	r := &textRenderer{markdownRenderer: markdownRenderer{base: base}, links: a.Links, skip: isNonText}
+	content := root
+	if a.MainContent {
+		content, r.skip = mainContent(root), isBoilerplate
+	} else if body := root.find("body"); body != nil {
+		content = body
+	}
+	text := strings.Join(r.blocks(content), "\n\n")
+	if len(r.refs) > 0 {
+		var b strings.Builder
+		for i, ref := range r.refs {
+			fmt.Fprintf(&b, "\n[%d] %s", i+1, ref)
+		}
+		text += "\n\nLinks:" + b.String()
+	}
+	if utf8.RuneCountInString(text) > a.MaxLength {
+		text = truncateRunes(text, a.MaxLength) + "\n\n[…]"
+	}
+	return []ToolContent{{Type: "text", Text: text}}, nil
+}
+
+// isNonText reports whether the element n holds no visible text.
+func isNonText(n *htmlNode) bool {
+	_, hidden := n.attrs["hidden"]
+	return htmlNonTextElements[n.tag] || hidden
+}
+
+// textRenderer renders a tree as plain text blocks. It shares the link
+// resolution of markdownRenderer.
+type textRenderer struct {
+	markdownRenderer
+	links string               // inline, references, or none
+	skip  func(*htmlNode) bool // elements to leave out
+	refs  []string             // link targets, for references
+}
+
+// blocks renders the children of n as a list of text blocks: runs of text
+// and inline elements become paragraphs.
+func (r *textRenderer) blocks(n *htmlNode) []string {
+	var out []string
+	var inline strings.Builder
+	flush := func() {
+		if p := tidyInline(inline.String()); p != "" {
+			out = append(out, p)
+		}
+		inline.Reset()
+	}
+	for _, c := range n.children {
+		if c.tag != "" && r.skip(c) {
+			continue
+		}
+		if c.tag == "" || htmlInlineElements[c.tag] {
+			inline.WriteString(r.inline(c))
+			continue
+		}
+		flush()
+		out = append(out, r.block(c)...)
+	}
+	flush()
+	return out
+}
+
+// block renders the block element n. The top headings are underlined, as
+// in plain text documents.
+func (r *textRenderer) block(n *htmlNode) []string {
+	switch n.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.ReplaceAll(tidyInline(r.inlineChildren(n)), "\n", " ")
+		switch {
+		case text == "":
+			return nil
+		case n.tag == "h1":
+			return []string{text + "\n" + strings.Repeat("=", utf8.RuneCountInString(text))}
+		case n.tag == "h2":
+			return []string{text + "\n" + strings.Repeat("-", utf8.RuneCountInString(text))}
+		}
+		return []string{text}
+	case "p", "dt":
+		if text := tidyInline(r.inlineChildren(n)); text != "" {
+			return []string{text}
+		}
+		return nil
+	case "dd":
+		if inner := strings.Join(r.blocks(n), "\n\n"); inner != "" {
+			return []string{prefixLines(inner, "    ", "    ")}
+		}
+		return nil
+	case "hr":
+		return []string{"* * *"}
+	case "pre":
+		if code := strings.Trim(n.textContent(), "\n"); strings.TrimSpace(code) != "" {
+			return []string{code}
+		}
+		return nil
+	case "blockquote":
+		if inner := strings.Join(r.blocks(n), "\n\n"); inner != "" {
+			return []string{prefixLines(inner, "> ", "> ")}
+		}
+		return nil
+	case "ul", "ol":
+		return r.list(n)
+	case "table":
+		return r.table(n)
+	case "img":
+		if alt := r.inline(n); alt != "" {
+			return []string{alt}
+		}
+		return nil
+	}
+	return r.blocks(n)
+}
+
+// list renders a ul or ol element as one block, indenting nested lists.
+func (r *textRenderer) list(n *htmlNode) []string {
+	number := 1
+	if start, err := strconv.Atoi(n.attr("start")); err == nil {
+		number = start
+	}
+	var items []string
+	for _, c := range n.children {
+		if c.tag != "li" || r.skip(c) {
+			continue
+		}
+		body := strings.Join(r.blocks(c), "\n")
+		if body == "" {
+			continue
+		}
+		marker := "- "
+		if n.tag == "ol" {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		items = append(items, prefixLines(body, marker, strings.Repeat(" ", len(marker))))
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return []string{strings.Join(items, "\n")}
+}
+
+// table renders a table one row per line, with its cells separated by
+// " | ".
+func (r *textRenderer) table(n *htmlNode) []string {
+	var rows []string
+	for _, tr := range n.findAll("tr", nil) {
+		var cells []string
+		for _, c := range tr.children {
+			if c.tag == "td" || c.tag == "th" {
+				cells = append(cells, strings.ReplaceAll(tidyInline(r.inlineChildren(c)), "\n", " "))
+			}
+		}
+		if strings.TrimSpace(strings.Join(cells, "")) != "" {
+			rows = append(rows, strings.Join(cells, " | "))
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return []string{strings.Join(rows, "\n")}
+}
+
+// inlineChildren renders the children of n as inline text.
+func (r *textRenderer) inlineChildren(n *htmlNode) string {
+	var b strings.Builder
+	for _, c := range n.children {
+		if c.tag == "" || !r.skip(c) {
+			b.WriteString(r.inline(c))
+		}
+	}
+	return b.String()
+}
+
+// inline renders n as inline text. Whitespace is collapsed later, by
+// tidyInline; a line break is kept as a newline. Images are shown by
+// their alternative text.
+func (r *textRenderer) inline(n *htmlNode) string {
+	switch n.tag {
+	case "":
+		return collapseSpace(n.text)
+	case "br":
+		return "\n"
+	case "img":
+		if alt := strings.TrimSpace(collapseSpace(n.attr("alt"))); alt != "" {
+			return "[" + alt + "]"
+		}
+		return ""
+	}
+	text := r.inlineChildren(n)
+	if strings.TrimSpace(text) == "" {
+		return text
+	}
+	if n.tag == "a" && r.links != "none" {
+		href := r.resolve(n.attr("href"))
+		if ref := strings.TrimSpace(n.attr("href")); href == "" && r.base == nil {
+			// Without a base URL, relative links are shown as written.
+			if u, err := url.Parse(ref); err == nil && u.Scheme == "" {
+				href = ref
+			}
+		}
+		words := strings.TrimSpace(text)
+		if href == "" || strings.HasPrefix(href, "#") || href == words || "mailto:"+words == href {
+			return text
+		}
+		if r.links == "references" {
+			r.refs = append(r.refs, href)
+			return leadingSpace(text) + words + "[" + strconv.Itoa(len(r.refs)) + "]" + trailingSpace(text)
+		}
+		return leadingSpace(text) + words + " (" + href + ")" + trailingSpace(text)
+	}
+	if !htmlInlineElements[n.tag] {
+		// A block inside inline content: keep its words apart.
+		return " " + text + " "
+	}
+	return text
+}
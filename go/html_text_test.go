@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testArticle is a page with chrome around its content.
+const testArticle = `<!DOCTYPE html>
+<html><head><title>Notes</title><style>p { color: red }</style></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<main>
+<h1>Release notes</h1>
+<p>Version <b>2.0</b> is out.<br>Read the <a href="/guide">upgrade guide</a> or mail <a href="mailto:dev@example.com">dev@example.com</a>.</p>
+<h2>Changes</h2>
+<ul><li>Faster <code>parse</code></li><li>New tools<ol start="3"><li>semver</li><li>html_to_text</li></ol></li></ul>
+<h3>Numbers</h3>
+<table><tr><th>Tool</th><th>Calls</th></tr><tr><td>echo</td><td>12</td></tr></table>
+<pre>
+  indented
+    code
+</pre>
+<blockquote><p>Quoted</p></blockquote>
+<img src="chart.png" alt="A chart"><script>alert(1)</script>
+</main>
+<footer>Copyright</footer>
+</body></html>`
+
+// Test the layout of headings, lists, tables, and links
+func TestHTMLToText(t *testing.T) {
+	tool := &htmlToTextTool{}
+	content, err := tool.Execute(map[string]interface{}{"html": testArticle, "main_content": true, "base_url": "https://example.com/news/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `Release notes
+=============
+
+Version 2.0 is out.
+Read the upgrade guide (https://example.com/guide) or mail dev@example.com.
+
+Changes
+-------
+
+- Faster parse
+- New tools
+  3. semver
+  4. html_to_text
+
+Numbers
+
+Tool | Calls
+echo | 12
+
+  indented
+    code
+
+> Quoted
+
+[A chart]`
+	if content[0].Text != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, content[0].Text)
+	}
+
+	content, err = tool.Execute(map[string]interface{}{"html": testArticle, "links": "references"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := content[0].Text
+	for _, want := range []string{"Home[1] About[2]", "upgrade guide[3]", "Copyright", "\n\nLinks:\n[1] /\n[2] /about\n[3] /guide"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"color: red", "alert", "Notes"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("expected no %q in\n%s", unwanted, text)
+		}
+	}
+
+	content, err = tool.Execute(map[string]interface{}{"html": "<p>See <a href='https://example.com/'>this</a></p>", "links": "none"})
+	if err != nil || content[0].Text != "See this" {
+		t.Errorf("unexpected result %v, %v", content, err)
+	}
+}
+
+// Test the length limit and argument errors
+func TestHTMLToTextLimits(t *testing.T) {
+	tool := &htmlToTextTool{}
+	content, err := tool.Execute(map[string]interface{}{"html": "<p>" + strings.Repeat("word ", 100) + "</p>", "max_length": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := content[0].Text; !strings.HasSuffix(text, "\n\n[…]") || len(text) != 100+len("\n\n[…]") {
+		t.Errorf("expected a truncated text, got %q", text)
+	}
+	for _, args := range []map[string]interface{}{
+		{"html": "x", "max_length": 10},
+		{"html": "x", "links": "footnotes"},
+		{"html": "x", "base_url": "/relative"},
+	} {
+		if _, err := tool.Execute(args); err == nil || !strings.HasPrefix(err.Error(), "invalid value for") {
+			t.Errorf("%v: expected an invalid value error, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// httpMockConfig configures the HTTP mock tools in the "httpMock" section
+// of the config file.
+type httpMockConfig struct {
+	MaxServers  int      `json:"maxServers"`  // mock servers running at once, defaults to 5
+	MaxRequests int      `json:"maxRequests"` // requests kept by a server, the latest ones, defaults to 1000
+	MaxBodySize int      `json:"maxBodySize"` // bytes of a recorded request body, defaults to 64 KiB
+	Lifetime    duration `json:"lifetime"`    // after which a server stops by itself, defaults to 1h
+}
+
+// Defaults and limits of the HTTP mock tools.
+const (
+	defaultMockServers     = 5
+	defaultMockRequests    = 1000
+	defaultMockBodySize    = 64 << 10
+	defaultMockLifetime    = time.Hour
+	maxMockDelay           = 30 * time.Second
+	defaultMockRequestList = 100
+)
+
+// validate reports malformed fields.
+func (c *httpMockConfig) validate() error {
+	if c.MaxServers < 0 || c.MaxRequests < 0 || c.MaxBodySize < 0 || c.Lifetime < 0 {
+		return errors.New("httpMock: maxServers, maxRequests, maxBodySize, and lifetime must not be negative")
+	}
+	return nil
+}
+
+// httpMocks runs the mock servers the HTTP mock tools start, on the
+// loopback interface.
+type httpMocks struct {
+	cfg     httpMockConfig
+	mu      sync.Mutex
+	servers map[string]*mockServer
+	next    int
+}
+
+// httpMockTools returns the http_mock_start, http_mock_requests, and
+// http_mock_stop tools for cfg.
+func httpMockTools(cfg httpMockConfig) []MCPTool {
+	if cfg.MaxServers == 0 {
+		cfg.MaxServers = defaultMockServers
+	}
+	if cfg.MaxRequests == 0 {
+		cfg.MaxRequests = defaultMockRequests
+	}
+	if cfg.MaxBodySize == 0 {
+		cfg.MaxBodySize = defaultMockBodySize
+	}
+	if cfg.Lifetime == 0 {
+		cfg.Lifetime = duration(defaultMockLifetime)
+	}
+	m := &httpMocks{cfg: cfg, servers: map[string]*mockServer{}}
+	return []MCPTool{&httpMockStartTool{m}, &httpMockRequestsTool{m}, &httpMockStopTool{m}}
+}
+
+// httpMockRoute is a canned response of a mock server.
+type httpMockRoute struct {
+	Method  string            `json:"method,omitempty" description:"HTTP method the route answers, such as GET (default any)"`
+	Path    string            `json:"path" description:"Path the route answers, such as /users/1, or a prefix ending in *, such as /users/*"`
+	Status  int               `json:"status,omitempty" minimum:"100" maximum:"599" description:"Status code of the response (default 200)"`
+	Headers map[string]string `json:"headers,omitempty" description:"Headers of the response; Content-Type defaults to application/json for a JSON body"`
+	Body    string            `json:"body,omitempty" description:"Body of the response"`
+	Delay   int               `json:"delay,omitempty" minimum:"0" maximum:"30000" description:"Milliseconds to wait before responding"`
+}
+
+// matches reports whether the route answers r.
+func (rt *httpMockRoute) matches(r *http.Request) bool {
+	if rt.Method != "" && !strings.EqualFold(rt.Method, r.Method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(rt.Path, "*"); ok {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	}
+	return r.URL.Path == rt.Path
+}
+
+// recordedRequest is a request a mock server received. Route is the index
+// of the route that answered it, or -1.
+type recordedRequest struct {
+	Seq           int               `json:"seq"`
+	Time          string            `json:"time"`
+	Method        string            `json:"method"`
+	Path          string            `json:"path"`
+	Query         string            `json:"query,omitempty"`
+	Headers       map[string]string `json:"headers"`
+	Body          string            `json:"body,omitempty"`
+	BodyTruncated bool              `json:"bodyTruncated,omitempty"`
+	Route         int               `json:"route"`
+}
+
+// mockServer is a running mock server with the requests it received.
+type mockServer struct {
+	id     string
+	url    string
+	routes []httpMockRoute
+	srv    *http.Server
+	timer  *time.Timer
+
+	mu       sync.Mutex
+	requests []recordedRequest
+	seq      int // requests received
+}
+
+// serve returns the handler of s, which records each request and answers
+// it with the first route matching it, or 404.
+func (s *mockServer) serve(maxRequests, maxBody int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(io.LimitReader(r.Body, int64(maxBody)+1))
+		rec := recordedRequest{
+			Time:    time.Now().UTC().Format(time.RFC3339Nano),
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   r.URL.RawQuery,
+			Headers: map[string]string{},
+			Route:   -1,
+		}
+		if len(body) > maxBody {
+			body, rec.BodyTruncated = body[:maxBody], true
+		}
+		rec.Body = string(body)
+		for name, values := range r.Header {
+			rec.Headers[name] = strings.Join(values, ", ")
+		}
+		if r.Host != "" {
+			rec.Headers["Host"] = r.Host
+		}
+		for i := range s.routes {
+			if s.routes[i].matches(r) {
+				rec.Route = i
+				break
+			}
+		}
+		s.mu.Lock()
+		s.seq++
+		rec.Seq = s.seq
+		s.requests = append(s.requests, rec)
+		if len(s.requests) > maxRequests {
+			s.requests = s.requests[len(s.requests)-maxRequests:]
+		}
+		s.mu.Unlock()
+
+		if rec.Route < 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("no mock route for %s %s", r.Method, r.URL.Path)})
+			return
+		}
+		rt := s.routes[rec.Route]
+		if rt.Delay > 0 {
+			select {
+			case <-time.After(time.Duration(rt.Delay) * time.Millisecond):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		for name, value := range rt.Headers {
+			w.Header().Set(name, value)
+		}
+		if w.Header().Get("Content-Type") == "" && json.Valid([]byte(rt.Body)) {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		status := rt.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, rt.Body)
+	}
+}
+
+// start listens on the loopback interface, on port if it is not 0, and
+// serves routes until stopped or the lifetime ends.
+func (m *httpMocks) start(routes []httpMockRoute, port int) (*mockServer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.servers) >= m.cfg.MaxServers {
+		return nil, toolFailure("%d mock servers are running already, the most there can be; stop one with http_mock_stop", len(m.servers))
+	}
+	ln, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return nil, toolFailure("cannot listen: %v", err)
+	}
+	m.next++
+	s := &mockServer{
+		id:     fmt.Sprintf("mock-%d", m.next),
+		url:    "http://" + ln.Addr().String(),
+		routes: routes,
+	}
+	s.srv = &http.Server{Handler: s.serve(m.cfg.MaxRequests, m.cfg.MaxBodySize), ReadHeaderTimeout: 10 * time.Second}
+	s.timer = time.AfterFunc(time.Duration(m.cfg.Lifetime), func() { m.stop(s.id) })
+	m.servers[s.id] = s
+	go s.srv.Serve(ln)
+	return s, nil
+}
+
+// get returns the running server with id.
+func (m *httpMocks) get(id string) (*mockServer, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s := m.servers[id]; s != nil {
+		return s, nil
+	}
+	return nil, fmt.Errorf("invalid value for 'id': no mock server %q is running", id)
+}
+
+// stop closes the server with id and forgets it.
+func (m *httpMocks) stop(id string) (*mockServer, error) {
+	m.mu.Lock()
+	s := m.servers[id]
+	delete(m.servers, id)
+	m.mu.Unlock()
+	if s == nil {
+		return nil, fmt.Errorf("invalid value for 'id': no mock server %q is running", id)
+	}
+	s.timer.Stop()
+	s.srv.Close()
+	return s, nil
+}
+
+// running returns the IDs of the running servers, in order.
+func (m *httpMocks) running() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.servers))
+	for id := range m.servers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// httpMockStartTool starts a mock server.
+type httpMockStartTool struct {
+	m *httpMocks
+}
+
+// httpMockStartArgs are the arguments of the http_mock_start tool.
+type httpMockStartArgs struct {
+	Routes []httpMockRoute `json:"routes" description:"Routes of the server, the first matching one answering a request; requests matching none get 404"`
+	Port   int             `json:"port,omitempty" minimum:"0" maximum:"65535" description:"Port to listen on (default any free one)"`
+}
+
+// Name returns the name of the http_mock_start tool.
+func (t *httpMockStartTool) Name() string {
+	return "http_mock_start"
+}
+
+// Description returns a brief description of the http_mock_start tool.
+func (t *httpMockStartTool) Description() string {
+	return "Starts a local HTTP server answering requests with canned responses and recording them, to test HTTP clients against; returns its ID and base URL"
+}
+
+// InputSchema returns the JSON schema for the http_mock_start tool's input
+// parameters.
+func (t *httpMockStartTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(httpMockStartArgs{})
+}
+
+// Annotations marks the http_mock_start tool as not destructive. It only
+// listens on the loopback interface.
+func (t *httpMockStartTool) Annotations() ToolAnnotations {
+	destructive, openWorld := false, false
+	return ToolAnnotations{Title: "Start HTTP mock", DestructiveHint: &destructive, OpenWorldHint: &openWorld}
+}
+
+// Execute starts the server without a deadline of its own.
+func (t *httpMockStartTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext starts the server and returns the result as JSON text.
+func (t *httpMockStartTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult checks the routes and starts a server answering with them.
+func (t *httpMockStartTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a httpMockStartArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if len(a.Routes) == 0 {
+		return nil, errors.New("'routes' is required")
+	}
+	for i, rt := range a.Routes {
+		switch {
+		case !strings.HasPrefix(rt.Path, "/"):
+			return nil, fmt.Errorf("invalid value for 'routes[%d].path': expected a path starting with /", i)
+		case rt.Status != 0 && (rt.Status < 100 || rt.Status > 599):
+			return nil, fmt.Errorf("invalid value for 'routes[%d].status': expected 100 to 599", i)
+		case rt.Delay < 0 || time.Duration(rt.Delay)*time.Millisecond > maxMockDelay:
+			return nil, fmt.Errorf("invalid value for 'routes[%d].delay': expected 0 to %d", i, maxMockDelay.Milliseconds())
+		case strings.ContainsAny(rt.Method, " \t\r\n"):
+			return nil, fmt.Errorf("invalid value for 'routes[%d].method'", i)
+		}
+	}
+	if a.Port < 0 || a.Port > 65535 {
+		return nil, errors.New("invalid value for 'port': expected 0 to 65535")
+	}
+	s, err := t.m.start(a.Routes, a.Port)
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewResult().WithStructured(map[string]interface{}{
+		"id":      s.id,
+		"url":     s.url,
+		"expires": time.Now().Add(time.Duration(t.m.cfg.Lifetime)).UTC().Format(time.RFC3339),
+	}), nil
+}
+
+// httpMockRequestsTool lists the requests a mock server received.
+type httpMockRequestsTool struct {
+	m *httpMocks
+}
+
+// httpMockRequestsArgs are the arguments of the http_mock_requests tool.
+type httpMockRequestsArgs struct {
+	ID    string `json:"id" description:"ID of the mock server, as http_mock_start returned it"`
+	After int    `json:"after,omitempty" minimum:"0" description:"Only list requests with a seq greater than this, to see the new ones"`
+	Limit int    `json:"limit,omitempty" minimum:"1" maximum:"1000" description:"Most requests to list, the first ones after 'after' (default 100)"`
+}
+
+// Name returns the name of the http_mock_requests tool.
+func (t *httpMockRequestsTool) Name() string {
+	return "http_mock_requests"
+}
+
+// Description returns a brief description of the http_mock_requests tool.
+func (t *httpMockRequestsTool) Description() string {
+	return "Lists the requests a mock server started by http_mock_start received, in order, with their headers, bodies, and the routes that answered them"
+}
+
+// InputSchema returns the JSON schema for the http_mock_requests tool's
+// input parameters.
+func (t *httpMockRequestsTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(httpMockRequestsArgs{})
+}
+
+// Annotations marks the http_mock_requests tool as read-only. New requests
+// change its results.
+func (t *httpMockRequestsTool) Annotations() ToolAnnotations {
+	openWorld := false
+	return ToolAnnotations{Title: "HTTP mock requests", ReadOnlyHint: true, OpenWorldHint: &openWorld}
+}
+
+// Execute lists the requests without a deadline of its own.
+func (t *httpMockRequestsTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext lists the requests and returns the result as JSON text.
+func (t *httpMockRequestsTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult returns the requests of the server after the given one.
+// Dropped counts the requests no longer kept.
+func (t *httpMockRequestsTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	a := httpMockRequestsArgs{Limit: defaultMockRequestList}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Limit < 1 || a.Limit > defaultMockRequests {
+		return nil, fmt.Errorf("invalid value for 'limit': expected 1 to %d", defaultMockRequests)
+	}
+	s, err := t.m.get(a.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []recordedRequest{}
+	for _, r := range s.requests {
+		if r.Seq > a.After && len(list) < a.Limit {
+			list = append(list, r)
+		}
+	}
+	result := map[string]interface{}{"requests": list, "received": s.seq}
+	if dropped := s.seq - len(s.requests); dropped > 0 {
+		result["dropped"] = dropped
+	}
+	return mcp.NewResult().WithStructured(result), nil
+}
+
+// httpMockStopTool stops a mock server.
+type httpMockStopTool struct {
+	m *httpMocks
+}
+
+// httpMockStopArgs are the arguments of the http_mock_stop tool.
+type httpMockStopArgs struct {
+	ID string `json:"id" description:"ID of the mock server, as http_mock_start returned it"`
+}
+
+// Name returns the name of the http_mock_stop tool.
+func (t *httpMockStopTool) Name() string {
+	return "http_mock_stop"
+}
+
+// Description returns a brief description of the http_mock_stop tool.
+func (t *httpMockStopTool) Description() string {
+	return "Stops a mock server started by http_mock_start, forgetting the requests it received"
+}
+
+// InputSchema returns the JSON schema for the http_mock_stop tool's input
+// parameters.
+func (t *httpMockStopTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(httpMockStopArgs{})
+}
+
+// Annotations marks the http_mock_stop tool as not destructive: it stops
+// only what http_mock_start started.
+func (t *httpMockStopTool) Annotations() ToolAnnotations {
+	destructive, openWorld := false, false
+	return ToolAnnotations{Title: "Stop HTTP mock", DestructiveHint: &destructive, OpenWorldHint: &openWorld}
+}
+
+// Execute stops the server and says how many requests it received.
+func (t *httpMockStopTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	var a httpMockStopArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	s, err := t.m.stop(a.ID)
+	if err != nil {
+		if running := t.m.running(); len(running) > 0 {
+			err = fmt.Errorf("%w; running: %s", err, strings.Join(running, ", "))
+		}
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []ToolContent{{Type: "text", Text: fmt.Sprintf("Stopped %s at %s after %d requests", s.id, s.url, s.seq)}}, nil
+}
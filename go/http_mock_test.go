@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that a mock server answers with its routes, records the requests,
+// and stops
+func TestHTTPMockTools(t *testing.T) {
+	tools := httpMockTools(httpMockConfig{MaxServers: 1, MaxRequests: 2, MaxBodySize: 8})
+	start, requests, stop := tools[0].(*httpMockStartTool), tools[1].(*httpMockRequestsTool), tools[2].(*httpMockStopTool)
+	result, err := start.ExecuteResult(context.Background(), map[string]interface{}{"routes": []interface{}{
+		map[string]interface{}{"method": "get", "path": "/users/1", "body": `{"id":1}`},
+		map[string]interface{}{"method": "POST", "path": "/users/*", "status": 201, "headers": map[string]interface{}{"Location": "/users/2"}, "body": "created"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := result.Structured.(map[string]interface{})
+	id, url := started["id"].(string), started["url"].(string)
+	if id != "mock-1" || !strings.HasPrefix(url, "http://127.0.0.1:") {
+		t.Fatalf("unexpected server %v", started)
+	}
+
+	for _, tc := range []struct {
+		method, path, body string
+		want               string
+	}{
+		{"GET", "/users/1", "", `200 application/json {"id":1}`},
+		{"POST", "/users/new?dry=1", "name=Ada Lovelace", "201 text/plain; charset=utf-8 created"},
+		{"DELETE", "/users/1", "", `404 application/json {"error":"no mock route for DELETE /users/1"}` + "\n"},
+	} {
+		req, _ := http.NewRequest(tc.method, url+tc.path, strings.NewReader(tc.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if got := resp.Status[:3] + " " + resp.Header.Get("Content-Type") + " " + string(body); got != tc.want {
+			t.Errorf("%s %s: expected %q, got %q", tc.method, tc.path, tc.want, got)
+		}
+	}
+
+	result, err = requests.ExecuteResult(context.Background(), map[string]interface{}{"id": id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := result.Structured.(map[string]interface{})
+	recorded := list["requests"].([]recordedRequest)
+	if len(recorded) != 2 || list["received"] != 3 || list["dropped"] != 1 {
+		t.Fatalf("expected the last two of three requests, got %v", list)
+	}
+	if r := recorded[0]; r.Seq != 2 || r.Method != "POST" || r.Path != "/users/new" || r.Query != "dry=1" || r.Body != "name=Ada" || !r.BodyTruncated || r.Route != 1 || r.Headers["Content-Length"] != "17" {
+		t.Errorf("unexpected request %+v", r)
+	}
+	if recorded[1].Route != -1 {
+		t.Errorf("expected the unmatched request to have no route, got %d", recorded[1].Route)
+	}
+	result, _ = requests.ExecuteResult(context.Background(), map[string]interface{}{"id": id, "after": 2})
+	if encoded, _ := json.Marshal(result.Structured); !strings.Contains(string(encoded), `"requests":[{"seq":3,`) {
+		t.Errorf("expected only the request after seq 2, got %s", encoded)
+	}
+
+	if _, err := start.Execute(map[string]interface{}{"routes": []interface{}{map[string]interface{}{"path": "/"}}}); err == nil || !strings.Contains(err.Error(), "1 mock servers are running") {
+		t.Errorf("expected the limit of servers, got %v", err)
+	}
+	content, err := stop.Execute(map[string]interface{}{"id": id})
+	if err != nil || content[0].Text != "Stopped mock-1 at "+url+" after 3 requests" {
+		t.Errorf("unexpected result %+v, %v", content, err)
+	}
+	if _, err := http.Get(url + "/users/1"); err == nil {
+		t.Error("expected the stopped server to refuse connections")
+	}
+
+	for _, tc := range []struct {
+		tool MCPTool
+		args map[string]interface{}
+		want string
+	}{
+		{stop, map[string]interface{}{"id": id}, `no mock server "mock-1" is running`},
+		{requests, map[string]interface{}{"id": "mock-9"}, `no mock server "mock-9" is running`},
+		{start, map[string]interface{}{}, "'routes' is required"},
+		{start, map[string]interface{}{"routes": []interface{}{map[string]interface{}{"path": "users"}}}, "invalid value for 'routes[0].path'"},
+		{start, map[string]interface{}{"routes": []interface{}{map[string]interface{}{"path": "/", "status": 42}}}, "invalid value for 'routes[0].status'"},
+		{start, map[string]interface{}{"routes": []interface{}{map[string]interface{}{"path": "/", "delay": 60000}}}, "invalid value for 'routes[0].delay'"},
+	} {
+		if _, err := tc.tool.Execute(tc.args); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s %v: expected an error containing %q, got %v", tc.tool.Name(), tc.args, tc.want, err)
+		}
+	}
+}
+
+// Test that servers stop by themselves at the end of their lifetime
+func TestHTTPMockLifetime(t *testing.T) {
+	tools := httpMockTools(httpMockConfig{Lifetime: duration(50 * time.Millisecond)})
+	start, stop := tools[0].(*httpMockStartTool), tools[2].(*httpMockStopTool)
+	if _, err := start.Execute(map[string]interface{}{"routes": []interface{}{map[string]interface{}{"path": "/"}}}); err != nil {
+		t.Fatal(err)
+	}
+	m := start.m
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.running()) > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := stop.Execute(map[string]interface{}{"id": "mock-1"}); err == nil {
+		t.Error("expected the server to have stopped")
+	}
+}
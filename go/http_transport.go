@@ -0,0 +1,423 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sessionIDHeader carries the session ID of the Streamable HTTP transport.
+const sessionIDHeader = "Mcp-Session-Id"
+
+// readHeaderTimeout bounds how long a client may take to send the headers
+// of a request, so that slow clients cannot hold connections open.
+const readHeaderTimeout = 10 * time.Second
+
+// httpTransport serves a Server over a minimal Streamable HTTP transport:
+// each POST carries one JSON-RPC message and its response is returned as an
+// application/json body. Server-sent event streams are not supported.
+type httpTransport struct {
+	server   *Server
+	shutdown <-chan struct{}
+	auth     *oauthVerifier // if set, requests need a valid access token
+
+	idleTimeout time.Duration // sessions unused for longer end; 0 for no limit
+	maxSessions int           // the least recently used session ends beyond this; 0 for no limit
+	now         func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession // by session ID
+	ended    []*httpSession          // sessions ended while mu is held, for unlock to finish
+}
+
+// httpSession is the state an HTTP session keeps between requests.
+type httpSession struct {
+	state     *Session
+	limiter   *tokenBucket
+	lifecycle *lifecycle
+	lastUsed  time.Time
+
+	done   context.Context // cancelled when the session ends, cancelling its requests
+	cancel context.CancelFunc
+	active sync.WaitGroup // requests being handled; added to only under the transport's mu
+	inUse  int            // requests being handled, guarded by the transport's mu
+}
+
+// newHTTPTransport returns a transport for s. Requests arriving after
+// shutdown is closed are refused.
+func newHTTPTransport(s *Server, shutdown <-chan struct{}) *httpTransport {
+	return &httpTransport{server: s, shutdown: shutdown, sessions: map[string]*httpSession{}, now: time.Now}
+}
+
+// addSession registers hs under a new ID, first ending idle sessions and,
+// at the session limit, the least recently used one. The caller handles a
+// request of hs and calls requestDone when it is finished.
+func (t *httpTransport) addSession(hs *httpSession) string {
+	id := newSessionID()
+	t.mu.Lock()
+	defer t.unlock()
+	now := t.now()
+	var oldest string
+	for sid, other := range t.sessions {
+		if t.expired(other, now) {
+			t.endSession(sid)
+		} else if oldest == "" || other.lastUsed.Before(t.sessions[oldest].lastUsed) {
+			oldest = sid
+		}
+	}
+	if t.maxSessions > 0 && len(t.sessions) >= t.maxSessions {
+		t.endSession(oldest)
+	}
+	hs.lastUsed = now
+	hs.state = newSession(id, hs.lifecycle, nil, nil)
+	hs.done, hs.cancel = context.WithCancel(context.Background())
+	hs.active.Add(1)
+	hs.inUse++
+	t.sessions[id] = hs
+	metrics.activeSessions.add(1)
+	return id
+}
+
+// session returns the live session with the given ID and marks it used.
+// The caller handles a request of it and calls requestDone when it is
+// finished.
+func (t *httpTransport) session(id string) (*httpSession, bool) {
+	t.mu.Lock()
+	defer t.unlock()
+	hs, ok := t.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	now := t.now()
+	if t.expired(hs, now) {
+		t.endSession(id)
+		return nil, false
+	}
+	hs.lastUsed = now
+	hs.active.Add(1)
+	hs.inUse++
+	return hs, true
+}
+
+// requestDone records that a request of hs is finished. The session's idle
+// time starts once its last request is.
+func (t *httpTransport) requestDone(hs *httpSession) {
+	t.mu.Lock()
+	hs.inUse--
+	hs.lastUsed = t.now()
+	t.mu.Unlock()
+	hs.active.Done()
+}
+
+// expired reports whether hs has been idle for longer than the timeout.
+// Sessions with requests in progress are not idle.
+func (t *httpTransport) expired(hs *httpSession, now time.Time) bool {
+	return t.idleTimeout > 0 && hs.inUse == 0 && now.Sub(hs.lastUsed) > t.idleTimeout
+}
+
+// endSession removes the session with the given ID. t.mu must be held;
+// the OnSessionEnd hooks run once unlock releases it.
+func (t *httpTransport) endSession(id string) bool {
+	hs, ok := t.sessions[id]
+	if !ok {
+		return false
+	}
+	delete(t.sessions, id)
+	t.ended = append(t.ended, hs)
+	metrics.activeSessions.add(-1)
+	return true
+}
+
+// sweep ends the idle sessions every interval until ctx is done, so that
+// the sessions of clients that vanished without deleting them are cleaned
+// up even when no other client comes along.
+func (t *httpTransport) sweep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.endIdle()
+		}
+	}
+}
+
+// endIdle ends the sessions idle for longer than the timeout.
+func (t *httpTransport) endIdle() {
+	t.mu.Lock()
+	defer t.unlock()
+	now := t.now()
+	for id, hs := range t.sessions {
+		if t.expired(hs, now) {
+			t.endSession(id)
+		}
+	}
+}
+
+// endAll ends every session, as the server shuts down.
+func (t *httpTransport) endAll() {
+	t.mu.Lock()
+	defer t.unlock()
+	for id := range t.sessions {
+		t.endSession(id)
+	}
+}
+
+// unlock releases t.mu, then finishes the sessions ended while it was
+// held: their requests are cancelled and given up to the drain timeout to
+// return before the OnSessionEnd hooks run. Hooks may use the transport.
+func (t *httpTransport) unlock() {
+	ended := t.ended
+	t.ended = nil
+	t.mu.Unlock()
+	for _, hs := range ended {
+		hs.cancel()
+		done := make(chan struct{})
+		go func() {
+			hs.active.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(t.server.drainTimeout):
+			t.server.logger.Warn("requests of an ended session did not return", "session", hs.state.ID())
+		}
+		t.server.sessionEnded(hs.state)
+	}
+}
+
+// ServeHTTP implements http.Handler. Every method is authenticated first,
+// so unauthenticated clients can neither end sessions nor make the server
+// read large bodies.
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	access, ok := t.authenticate(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r, access)
+	case http.MethodDelete:
+		t.mu.Lock()
+		ok := t.endSession(r.Header.Get(sessionIDHeader))
+		t.unlock()
+		if !ok {
+			http.Error(w, "Unknown session", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// authenticate checks the access token of r, if the transport requires
+// one, and returns the tools it grants. Otherwise it answers r and returns
+// false.
+func (t *httpTransport) authenticate(w http.ResponseWriter, r *http.Request) (*toolFilter, bool) {
+	if t.auth == nil {
+		return nil, true
+	}
+	claims, err := t.auth.authenticate(r)
+	if errors.Is(err, errKeysUnavailable) {
+		t.server.logger.Error("cannot verify access token", "error", err)
+		http.Error(w, "Authorization temporarily unavailable", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	if err != nil {
+		t.auth.challenge(w, r, err)
+		return nil, false
+	}
+	return t.auth.toolFilter(claims.Scopes), true
+}
+
+// handlePost processes one JSON-RPC message from a client granted access.
+func (t *httpTransport) handlePost(w http.ResponseWriter, r *http.Request, access *toolFilter) {
+	s := t.server
+	var body io.Reader = r.Body
+	if s.maxMessageSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, int64(s.maxMessageSize))
+	}
+	data, err := io.ReadAll(body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, -32600, "Invalid Request: message too large",
+			sizeLimitData{Limit: s.maxMessageSize})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+
+	var peek struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal(data, &peek)
+
+	sessionID := r.Header.Get(sessionIDHeader)
+	var hs *httpSession
+	if peek.Method == "initialize" && sessionID == "" {
+		hs = &httpSession{limiter: newTokenBucket(s.sessionRateLimit), lifecycle: &lifecycle{}}
+		sessionID = t.addSession(hs)
+		w.Header().Set(sessionIDHeader, sessionID)
+		s.sessionStarted(contextWithSession(r.Context(), hs.state), hs.state)
+	} else {
+		if sessionID == "" {
+			http.Error(w, "Missing "+sessionIDHeader+" header", http.StatusBadRequest)
+			return
+		}
+		var ok bool
+		if hs, ok = t.session(sessionID); !ok {
+			http.Error(w, "Unknown session", http.StatusNotFound)
+			return
+		}
+	}
+	// A session whose client sent exit ends once the request is done.
+	defer func() {
+		if exited, _ := hs.lifecycle.exited(); exited {
+			t.mu.Lock()
+			t.endSession(sessionID)
+			t.unlock()
+		}
+	}()
+	defer t.requestDone(hs)
+
+	// The request ends with the session as well as with its connection,
+	// and when the client stops waiting for it.
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	defer context.AfterFunc(hs.done, cancel)()
+	if d, ok := parseRequestTimeout(r.Header.Get(requestTimeoutHeader)); ok {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, d)
+		defer stop()
+	}
+
+	var out bytes.Buffer
+	sess := &session{
+		state:     hs.state,
+		ctx:       contextWithSession(ctx, hs.state),
+		cancel:    cancel,
+		shutdown:  t.shutdown,
+		w:         s.sessionWriter(&out),
+		limiter:   hs.limiter,
+		access:    access,
+		lifecycle: hs.lifecycle,
+	}
+	s.handleLine(sess, data)
+	sess.inflight.Wait()
+
+	if out.Len() == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(bytes.TrimSpace(out.Bytes()))
+}
+
+// writeJSONError writes a JSON-RPC error with a null id as an HTTP response.
+func writeJSONError(w http.ResponseWriter, status, code int, message string, data interface{}) {
+	var out bytes.Buffer
+	sendErrorData(&out, nil, code, message, data)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(bytes.TrimSpace(out.Bytes()))
+}
+
+// newSessionID returns a random, hard to guess session ID.
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// httpOptions configures serveHTTP.
+type httpOptions struct {
+	addr            string
+	auth            *oauthVerifier
+	allowedHosts    []string
+	allowedOrigins  []string
+	idleTimeout     time.Duration // of sessions
+	connIdleTimeout time.Duration // of keep-alive connections, zero for none
+	maxSessions     int
+	pprofToken      string // if set, serves the pprof profiles to requests bearing it
+	restGateway     bool   // also serve the tools under /tools
+}
+
+// serveHTTP serves s over the HTTP transport until ctx is cancelled, then
+// shuts down, giving in-flight requests up to the drain timeout. With
+// authorization, the protected resource metadata is served on the same
+// listener, as are the pprof profiles and the REST gateway if enabled.
+// Metrics are not: they are unauthenticated, so they are only served on
+// the listener of --metrics-addr.
+func serveHTTP(ctx context.Context, s *Server, opts httpOptions) error {
+	ln, err := net.Listen("tcp", opts.addr)
+	if err != nil {
+		return err
+	}
+	transport := newHTTPTransport(s, ctx.Done())
+	transport.auth = opts.auth
+	transport.idleTimeout, transport.maxSessions = opts.idleTimeout, opts.maxSessions
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", transport)
+	if opts.auth != nil {
+		mux.Handle("/.well-known/oauth-protected-resource", opts.auth)
+		if path := opts.auth.metadataPath(); path != "/.well-known/oauth-protected-resource" {
+			mux.Handle(path, opts.auth)
+		}
+	}
+	if opts.pprofToken != "" {
+		mux.Handle("/debug/pprof/", pprofHandler(opts.pprofToken))
+	}
+	if opts.restGateway {
+		newRESTGateway(s, opts.auth, ctx.Done()).register(mux)
+	}
+	srv := &http.Server{
+		Handler:           newOriginCheck(mux, opts.addr, opts.allowedHosts, opts.allowedOrigins),
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       opts.connIdleTimeout,
+	}
+	s.logger.Info("serving MCP over HTTP", "addr", "http://"+ln.Addr().String()+"/mcp")
+	if opts.restGateway {
+		s.logger.Info("serving the tools over REST", "addr", "http://"+ln.Addr().String()+"/tools")
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+	if transport.idleTimeout > 0 {
+		go transport.sweep(ctx, max(transport.idleTimeout/2, time.Second))
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	defer transport.endAll()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return errDrainTimeout
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// postMCP sends body to the transport and returns the response.
+func postMCP(t *testing.T, url, sessionID, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if sessionID != "" {
+		req.Header.Set(sessionIDHeader, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// Test a session over the HTTP transport
+func TestHTTPTransport(t *testing.T) {
+	ts := httptest.NewServer(newHTTPTransport(NewServer(), nil))
+	defer ts.Close()
+
+	resp := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`)
+	sessionID := resp.Header.Get(sessionIDHeader)
+	if resp.StatusCode != http.StatusOK || sessionID == "" {
+		t.Fatalf("expected 200 with a session ID, got %d %q", resp.StatusCode, sessionID)
+	}
+
+	resp = postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("expected 202 for a notification, got %d", resp.StatusCode)
+	}
+
+	resp = postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":2}`)
+	body, _ := io.ReadAll(resp.Body)
+	var callResp struct {
+		Result struct {
+			Content []ToolContent `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(body, &callResp); err != nil {
+		t.Fatalf("failed to unmarshal response %q: %v", body, err)
+	}
+	if len(callResp.Result.Content) != 1 || callResp.Result.Content[0].Text != "Echo: hi" {
+		t.Errorf("unexpected tools/call response %s", body)
+	}
+
+	resp = postMCP(t, ts.URL, sessionID, "\ufeff"+`{"jsonrpc":"2.0","method":"tools/list","id":3}`)
+	if body, _ := io.ReadAll(resp.Body); !bytes.Contains(body, []byte(`"tools"`)) {
+		t.Errorf("expected a body with a byte order mark to be accepted, got %s", body)
+	}
+
+	if resp := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"tools/list","id":3}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 without a session ID, got %d", resp.StatusCode)
+	}
+	if resp := postMCP(t, ts.URL, "bogus", `{"jsonrpc":"2.0","method":"tools/list","id":3}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown session, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(sessionIDHeader, sessionID)
+	delResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delResp.Body.Close()
+	if resp := postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"tools/list","id":4}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 after the session was deleted, got %d", resp.StatusCode)
+	}
+}
+
+// Test that idle sessions and sessions beyond the limit end
+func TestHTTPSessionLimits(t *testing.T) {
+	tr := newHTTPTransport(NewServer(), nil)
+	tr.idleTimeout, tr.maxSessions = time.Minute, 2
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+	initialize := func() string {
+		return postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`).Header.Get(sessionIDHeader)
+	}
+	status := func(sessionID string) int {
+		return postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"ping","id":2}`).StatusCode
+	}
+
+	idle := initialize()
+	now = now.Add(2 * time.Minute)
+	if got := status(idle); got != http.StatusNotFound {
+		t.Errorf("expected 404 for an idle session, got %d", got)
+	}
+
+	first := initialize()
+	now = now.Add(time.Second)
+	second := initialize()
+	now = now.Add(time.Second)
+	status(first)
+	third := initialize()
+	if got := status(second); got != http.StatusNotFound {
+		t.Errorf("expected the least recently used session to end, got %d", got)
+	}
+	for _, id := range []string{first, third} {
+		if got := status(id); got != http.StatusOK {
+			t.Errorf("expected the recently used sessions to remain, got %d", got)
+		}
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+		req.Header.Set(sessionIDHeader, id)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if n := len(tr.sessions); n != 0 {
+		t.Errorf("expected no sessions left, got %d", n)
+	}
+}
+
+// startingHang is a hangingTool that reports when it starts.
+type startingHang struct {
+	hangingTool
+	started chan struct{}
+}
+
+func (h *startingHang) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	close(h.started)
+	return h.hangingTool.ExecuteContext(ctx, args)
+}
+
+// Test that ending an HTTP session cancels its in-flight requests before
+// the end hooks run and its values are closed
+func TestHTTPSessionEndCancels(t *testing.T) {
+	tool := &startingHang{hangingTool{cancelled: make(chan struct{})}, make(chan struct{})}
+	closed := make(chan struct{})
+	var cancelledFirst atomic.Bool
+	s := NewServer(WithTools(tool), WithHooks(Hooks{
+		OnSessionStart: func(ctx context.Context, sess *Session) {
+			sess.Values.Store("conn", closerFunc(func() error {
+				close(closed)
+				return nil
+			}))
+		},
+		OnSessionEnd: func(sess *Session) {
+			// The tool notices the cancellation on a goroutine of its own.
+			select {
+			case <-tool.cancelled:
+				cancelledFirst.Store(true)
+			case <-time.After(time.Second):
+			}
+		},
+	}))
+	ts := httptest.NewServer(newHTTPTransport(s, nil))
+	defer ts.Close()
+
+	sessionID := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`).Header.Get(sessionIDHeader)
+	postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	bodies := make(chan string, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"hang","arguments":{}},"id":2}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(sessionIDHeader, sessionID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			bodies <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		bodies <- string(body)
+	}()
+	<-tool.started
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(sessionIDHeader, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	select {
+	case <-closed:
+	default:
+		t.Error("expected the session's values to be closed once it was deleted")
+	}
+	if !cancelledFirst.Load() {
+		t.Error("expected the in-flight call to be cancelled before the end hooks ran")
+	}
+	if body := <-bodies; !strings.Contains(body, `"id":2`) {
+		t.Errorf("expected the cancelled call to be answered, got %s", body)
+	}
+}
+
+// Test that idle sessions end without another request coming along, but
+// not while one of theirs is in progress
+func TestHTTPSessionSweep(t *testing.T) {
+	tr := newHTTPTransport(NewServer(), nil)
+	tr.idleTimeout = time.Minute
+	now := time.Now()
+	tr.now = func() time.Time { return now }
+	idle := tr.addSession(&httpSession{lifecycle: &lifecycle{}})
+	tr.requestDone(tr.sessions[idle])
+	busy := tr.addSession(&httpSession{lifecycle: &lifecycle{}})
+
+	now = now.Add(2 * time.Minute)
+	tr.endIdle()
+	if _, ok := tr.sessions[idle]; ok {
+		t.Error("expected the idle session to end")
+	}
+	hs, ok := tr.sessions[busy]
+	if !ok {
+		t.Fatal("expected the session with a request in progress to remain")
+	}
+	tr.requestDone(hs)
+	now = now.Add(30 * time.Second)
+	if tr.endIdle(); len(tr.sessions) != 1 {
+		t.Error("expected the idle time to start when the request finished")
+	}
+	now = now.Add(time.Minute)
+	if tr.endIdle(); len(tr.sessions) != 0 {
+		t.Error("expected the session to end once idle")
+	}
+}
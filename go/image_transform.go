@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of image_transform, so that a small compressed image cannot make
+// the server allocate gigabytes.
+const (
+	maxImageFileSize = 32 << 20
+	maxImagePixels   = 40_000_000
+	maxImageSide     = webpMaxSize
+)
+
+// imageTransformTool crops, resizes and converts images. Images come from
+// the arguments as base64, or from files in ws, if one is configured.
+type imageTransformTool struct {
+	ws *Workspace
+}
+
+// withImageWorkspace returns a copy of list in which image_transform reads
+// files in ws.
+func withImageWorkspace(list []MCPTool, ws *Workspace) []MCPTool {
+	out := make([]MCPTool, len(list))
+	for i, t := range list {
+		if _, ok := t.(*imageTransformTool); ok {
+			t = &imageTransformTool{ws: ws}
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// Name returns the name of the image_transform tool.
+func (t *imageTransformTool) Name() string {
+	return "image_transform"
+}
+
+// Description returns a brief description of the image_transform tool.
+func (t *imageTransformTool) Description() string {
+	return "Crops, resizes, and converts a PNG, JPEG, or GIF image to PNG, JPEG, or WebP, and reports its dimensions"
+}
+
+// imageTransformArgs are the arguments of the image_transform tool.
+type imageTransformArgs struct {
+	Image      string `json:"image,omitempty" description:"The image as base64, optionally as a data: URL"`
+	Path       string `json:"path,omitempty" description:"The image's path, relative to the server's image directory"`
+	CropX      int    `json:"crop_x,omitempty" minimum:"0" description:"Left edge of the area to keep, in pixels"`
+	CropY      int    `json:"crop_y,omitempty" minimum:"0" description:"Top edge of the area to keep, in pixels"`
+	CropWidth  int    `json:"crop_width,omitempty" minimum:"1" description:"Width of the area to keep (default to the right edge)"`
+	CropHeight int    `json:"crop_height,omitempty" minimum:"1" description:"Height of the area to keep (default to the bottom edge)"`
+	Width      int    `json:"width,omitempty" minimum:"1" maximum:"16384" description:"Width to resize to; alone, the height follows the aspect ratio"`
+	Height     int    `json:"height,omitempty" minimum:"1" maximum:"16384" description:"Height to resize to; alone, the width follows the aspect ratio"`
+	Format     string `json:"format,omitempty" enum:"png,jpeg,webp" description:"Format of the result (default the input's, or png for GIF); WebP is written lossless"`
+	Quality    int    `json:"quality,omitempty" minimum:"1" maximum:"100" description:"JPEG quality (default 90)"`
+}
+
+// InputSchema returns the JSON schema for the image_transform tool's input
+// parameters.
+func (t *imageTransformTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(imageTransformArgs{})
+}
+
+// Annotations marks the image_transform tool as read-only.
+func (t *imageTransformTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Image transform")
+}
+
+// Execute transforms the image outside a session.
+func (t *imageTransformTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext decodes the image, crops it, then resizes it, and returns
+// the result as image content after a text giving the dimensions before
+// and after. Without a crop, a size, or a format, only the dimensions are
+// returned.
+func (t *imageTransformTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	a := imageTransformArgs{Quality: 90}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	data, err := t.load(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	cfg, inFormat, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported image: %v", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, fmt.Errorf("image of %dx%d pixels is too large", cfg.Width, cfg.Height)
+	}
+	summary := fmt.Sprintf("%s image of %dx%d pixels", inFormat, cfg.Width, cfg.Height)
+	crop := a.CropX != 0 || a.CropY != 0 || a.CropWidth != 0 || a.CropHeight != 0
+	if !crop && a.Width == 0 && a.Height == 0 && a.Format == "" {
+		return []ToolContent{{Type: "text", Text: summary}}, nil
+	}
+
+	format := a.Format
+	if format == "" {
+		format = inFormat
+		if format == "gif" {
+			format = "png"
+		}
+	}
+	switch format {
+	case "png", "jpeg", "webp":
+	default:
+		return nil, fmt.Errorf("invalid value for 'format'")
+	}
+	if a.Quality < 1 || a.Quality > 100 {
+		return nil, fmt.Errorf("invalid value for 'quality'")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	if crop {
+		if img, err = cropImage(img, a.CropX, a.CropY, a.CropWidth, a.CropHeight); err != nil {
+			return nil, err
+		}
+	}
+	if a.Width != 0 || a.Height != 0 {
+		width, height, err := resizeDimensions(img.Bounds(), a.Width, a.Height)
+		if err != nil {
+			return nil, err
+		}
+		img = resizeImage(img, width, height)
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: a.Quality})
+	case "webp":
+		err = encodeWebP(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	summary += fmt.Sprintf(" transformed to %s image of %dx%d pixels", format, b.Dx(), b.Dy())
+	return []ToolContent{
+		{Type: "text", Text: summary},
+		{Type: "image", Data: base64.StdEncoding.EncodeToString(buf.Bytes()), MimeType: "image/" + format},
+	}, nil
+}
+
+// load returns the image given by exactly one of the image and path
+// arguments.
+func (t *imageTransformTool) load(ctx context.Context, a imageTransformArgs) ([]byte, error) {
+	switch {
+	case (a.Image == "") == (a.Path == ""):
+		return nil, errors.New("exactly one of 'image' and 'path' must be specified")
+	case a.Image != "":
+		s := a.Image
+		if strings.HasPrefix(s, "data:") {
+			i := strings.Index(s, ";base64,")
+			if i < 0 {
+				return nil, errors.New("invalid value for 'image': data URL without base64 data")
+			}
+			s = s[i+len(";base64,"):]
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for 'image': %v", err)
+		}
+		return data, nil
+	}
+
+	path, err := t.resolve(ctx, a.Path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, toolFailure("%s: %v", a.Path, errors.Unwrap(err))
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxImageFileSize+1))
+	if err != nil {
+		return nil, toolFailure("%s: %v", a.Path, err)
+	}
+	if len(data) > maxImageFileSize {
+		return nil, toolFailure("%s is larger than %d bytes", a.Path, maxImageFileSize)
+	}
+	return data, nil
+}
+
+// resolve returns the file that name refers to in the image directory,
+// refusing names, and symbolic links, that lead outside it.
+func (t *imageTransformTool) resolve(ctx context.Context, name string) (string, error) {
+	if t.ws == nil {
+		return "", errors.New("reading images from paths is disabled; start the server with --image-dir")
+	}
+	ws, err := t.ws.For(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no image directory: %w", err)
+	}
+	return ws.Resolve(name, "image")
+}
+
+// cropImage returns the part of img at x, y of the given size, relative to
+// its top left corner. A zero width or height extends the area to the
+// image's edge.
+func cropImage(img image.Image, x, y, width, height int) (image.Image, error) {
+	b := img.Bounds()
+	if width == 0 {
+		width = b.Dx() - x
+	}
+	if height == 0 {
+		height = b.Dy() - y
+	}
+	r := image.Rect(x, y, x+width, y+height).Add(b.Min)
+	if x < 0 || y < 0 || width < 1 || height < 1 || !r.In(b) {
+		return nil, fmt.Errorf("crop area %dx%d at %d,%d is outside the %dx%d image", width, height, x, y, b.Dx(), b.Dy())
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst, nil
+}
+
+// resizeDimensions returns the size to resize an image with bounds b to.
+// If only one of width and height is given, the other keeps the aspect
+// ratio.
+func resizeDimensions(b image.Rectangle, width, height int) (int, int, error) {
+	switch {
+	case width == 0:
+		width = max(1, int(math.Round(float64(b.Dx())*float64(height)/float64(b.Dy()))))
+	case height == 0:
+		height = max(1, int(math.Round(float64(b.Dy())*float64(width)/float64(b.Dx()))))
+	}
+	if width < 1 || height < 1 || width > maxImageSide || height > maxImageSide || width*height > maxImagePixels {
+		return 0, 0, fmt.Errorf("cannot resize to %dx%d pixels", width, height)
+	}
+	return width, height, nil
+}
+
+// resizeImage scales img to width by height pixels with a triangle filter,
+// which interpolates linearly when enlarging and averages the pixels each
+// one covers when shrinking. Colors are weighted by their alpha so that
+// transparent pixels do not darken the edges of opaque ones.
+func resizeImage(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	// Resize the rows, then the columns, of premultiplied channels.
+	rows := resample(src.Pix, b.Dx(), b.Dy(), width, 4, src.Stride)
+	cols := resample(transpose(rows, width, b.Dy()), b.Dy(), width, height, 4, b.Dy()*4)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for c := 0; c < 4; c++ {
+				v := cols[(x*height+y)*4+c]
+				dst.Pix[y*dst.Stride+x*4+c] = uint8(min(255, max(0, math.Round(float64(v)))))
+			}
+		}
+	}
+	return dst
+}
+
+// resample scales each of the n rows of pix, srcLen pixels of channels
+// bytes at stride bytes apart, to dstLen pixels of float32 channels.
+func resample(pix []uint8, srcLen, n, dstLen, channels, stride int) []float32 {
+	scale := float64(srcLen) / float64(dstLen)
+	support := max(1, scale)
+	type tap struct {
+		start   int
+		weights []float32
+	}
+	taps := make([]tap, dstLen)
+	for i := range taps {
+		center := (float64(i)+0.5)*scale - 0.5
+		start := max(0, int(math.Ceil(center-support)))
+		end := min(srcLen-1, int(math.Floor(center+support)))
+		var weights []float32
+		var sum float64
+		for j := start; j <= end; j++ {
+			w := 1 - math.Abs(float64(j)-center)/support
+			if w < 0 {
+				w = 0
+			}
+			weights = append(weights, float32(w))
+			sum += w
+		}
+		for k := range weights {
+			weights[k] /= float32(sum)
+		}
+		taps[i] = tap{start: start, weights: weights}
+	}
+
+	out := make([]float32, n*dstLen*channels)
+	for row := 0; row < n; row++ {
+		line := pix[row*stride:]
+		for i, t := range taps {
+			o := out[(row*dstLen+i)*channels:]
+			for k, w := range t.weights {
+				p := line[(t.start+k)*channels:]
+				for c := 0; c < channels; c++ {
+					o[c] += w * float32(p[c])
+				}
+			}
+		}
+	}
+	return out
+}
+
+// transpose turns the height rows of width four-channel pixels in pix into
+// width rows of height pixels, rounding the channels back to bytes.
+func transpose(pix []float32, width, height int) []uint8 {
+	out := make([]uint8, len(pix))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for c := 0; c < 4; c++ {
+				out[(x*height+y)*4+c] = uint8(min(255, max(0, math.Round(float64(pix[(y*width+x)*4+c])))))
+			}
+		}
+	}
+	return out
+}
+
+// flatten draws img over white, since JPEG has no transparency.
+func flatten(img image.Image) image.Image {
+	b := img.Bounds()
+	dst := image.NewRGBA(b)
+	draw.Draw(dst, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, b, img, b.Min, draw.Over)
+	return dst
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testImagePNG returns a 40x20 PNG, red on the left and blue on the right.
+func testImagePNG(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.NRGBA{255, 0, 0, 255}
+			if x >= 20 {
+				c = color.NRGBA{0, 0, 255, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// transformImage calls image_transform with args and decodes the image it
+// returns.
+func transformImage(t *testing.T, tool *imageTransformTool, args map[string]interface{}) (string, image.Image) {
+	t.Helper()
+	content, err := tool.Execute(args)
+	if err != nil {
+		t.Fatalf("Execute(%v) error: %v", args, err)
+	}
+	if len(content) != 2 || content[1].Type != "image" {
+		t.Fatalf("expected a text and an image, got %v", content)
+	}
+	data, err := base64.StdEncoding.DecodeString(content[1].Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var img image.Image
+	switch content[1].MimeType {
+	case "image/png":
+		img, err = png.Decode(bytes.NewReader(data))
+	case "image/jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+	case "image/webp":
+		img, err = decodeTestWebP(data)
+	}
+	if err != nil || img == nil {
+		t.Fatalf("cannot decode the %s result: %v", content[1].MimeType, err)
+	}
+	return content[0].Text, img
+}
+
+// Test reporting dimensions, cropping, resizing, and converting
+func TestImageTransform(t *testing.T) {
+	tool := &imageTransformTool{}
+	src := base64.StdEncoding.EncodeToString(testImagePNG(t))
+
+	content, err := tool.Execute(map[string]interface{}{"image": "data:image/png;base64," + src})
+	if err != nil || len(content) != 1 || content[0].Text != "png image of 40x20 pixels" {
+		t.Errorf("expected only the dimensions, got %v, %v", content, err)
+	}
+
+	text, img := transformImage(t, tool, map[string]interface{}{"image": src, "crop_x": float64(20), "crop_height": float64(10)})
+	if img.Bounds().Dx() != 20 || img.Bounds().Dy() != 10 || !strings.HasSuffix(text, "transformed to png image of 20x10 pixels") {
+		t.Errorf("unexpected crop %v: %s", img.Bounds(), text)
+	}
+	if r, _, b, _ := img.At(0, 0).RGBA(); r != 0 || b != 0xffff {
+		t.Errorf("expected the blue half, got %v", img.At(0, 0))
+	}
+
+	_, img = transformImage(t, tool, map[string]interface{}{"image": src, "width": float64(10)})
+	if img.Bounds().Dx() != 10 || img.Bounds().Dy() != 5 {
+		t.Errorf("expected the aspect ratio to be kept, got %v", img.Bounds())
+	}
+	if r, _, b, _ := img.At(0, 2).RGBA(); r != 0xffff || b != 0 {
+		t.Errorf("expected red on the left, got %v", img.At(0, 2))
+	}
+	if r, _, b, _ := img.At(9, 2).RGBA(); r != 0 || b != 0xffff {
+		t.Errorf("expected blue on the right, got %v", img.At(9, 2))
+	}
+
+	_, img = transformImage(t, tool, map[string]interface{}{"image": src, "width": float64(80), "height": float64(80), "format": "jpeg"})
+	if img.Bounds().Dx() != 80 || img.Bounds().Dy() != 80 {
+		t.Errorf("expected an 80x80 JPEG, got %v", img.Bounds())
+	}
+
+	_, img = transformImage(t, tool, map[string]interface{}{"image": src, "format": "webp"})
+	if img.Bounds().Dx() != 40 || img.At(39, 19) != (color.NRGBA{0, 0, 255, 255}) {
+		t.Errorf("unexpected WebP conversion %v", img.Bounds())
+	}
+
+	for _, args := range []map[string]interface{}{
+		{},
+		{"image": src, "path": "a.png"},
+		{"image": "not base64!"},
+		{"image": base64.StdEncoding.EncodeToString([]byte("not an image"))},
+		{"image": src, "crop_x": float64(30), "crop_width": float64(20)},
+		{"image": src, "width": float64(20000)},
+		{"image": src, "format": "bmp"},
+		{"path": "a.png"},
+	} {
+		if _, err := tool.Execute(args); err == nil {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
+
+// Test that paths are read only from within the image directory
+func TestImageTransformPath(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	data := testImagePNG(t)
+	for _, path := range []string{filepath.Join(dir, "sub", "a.png"), filepath.Join(outside, "secret.png")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.png"), filepath.Join(dir, "link.png")); err != nil {
+		t.Skip("symbolic links unavailable:", err)
+	}
+	ws := &Workspace{Root: dir}
+	all := withImageWorkspace(tools, ws)
+	var tool *imageTransformTool
+	for _, tl := range all {
+		if it, ok := tl.(*imageTransformTool); ok {
+			tool = it
+		}
+	}
+	if tool == nil || tool.ws != ws {
+		t.Fatalf("expected image_transform to read under %s, got %+v", dir, tool)
+	}
+
+	content, err := tool.Execute(map[string]interface{}{"path": "sub/a.png"})
+	if err != nil || content[0].Text != "png image of 40x20 pixels" {
+		t.Errorf("expected the image to be read, got %v, %v", content, err)
+	}
+	for _, path := range []string{"link.png", "../" + filepath.Base(outside) + "/secret.png", filepath.Join(outside, "secret.png")} {
+		if _, err := tool.Execute(map[string]interface{}{"path": path}); err == nil || !strings.Contains(err.Error(), "not a path within") {
+			t.Errorf("expected %s to be refused, got %v", path, err)
+		}
+	}
+	var failure *toolResultError
+	if _, err := tool.Execute(map[string]interface{}{"path": "missing.png"}); !errors.As(err, &failure) || strings.Contains(failure.content[0].Text, dir) {
+		t.Errorf("expected a missing file to fail without revealing the directory, got %v", err)
+	}
+}
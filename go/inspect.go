@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"mcp-minimal-server-go/client"
+)
+
+// inspectUsage describes the inspect subcommand.
+const inspectUsage = `Usage: mcp-minimal-server inspect [flags] [COMMAND [ARGS...]]
+
+Connects to an MCP server, performs the handshake, and prints what it
+offers: its info, capabilities, tools with their arguments, resources and
+prompts. The server is started as COMMAND speaking stdio, reached at --url
+over Streamable HTTP, or, with neither, this server with default settings.
+The exit status is 1 if the handshake fails.
+
+`
+
+// runInspect implements the inspect subcommand and returns the process
+// exit code.
+func runInspect(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	url := fs.String("url", "", "inspect the Streamable HTTP server at `URL`")
+	timeout := fs.Duration("timeout", 10*time.Second, "how long to wait for the server in all")
+	schemas := fs.Bool("schemas", false, "print each tool's input schema as JSON")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), inspectUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	opt := client.WithClientInfo(serverName+"-inspect", serverVersion)
+	var c *client.Client
+	switch {
+	case *url != "" && fs.NArg() > 0:
+		fmt.Fprintln(os.Stderr, "inspect: give either --url or a command, not both")
+		return 2
+	case *url != "":
+		c = client.NewHTTP(*url, nil, opt)
+	case fs.NArg() > 0:
+		var err error
+		if c, err = client.NewStdio(fs.Arg(0), fs.Args()[1:], nil, opt); err != nil {
+			fmt.Fprintf(os.Stderr, "inspect: %v\n", err)
+			return 1
+		}
+	default:
+		server, _, done, code := newServerFromConfig(defaultConfig(), os.Stderr)
+		if code != 0 {
+			return code
+		}
+		defer done()
+		c = newInProcessClient(ctx, server, opt)
+	}
+	defer c.Close()
+
+	if err := inspect(ctx, c, *schemas, out); err != nil {
+		fmt.Fprintf(os.Stderr, "inspect: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// inspect initializes c and writes a summary of what its server offers to
+// out, with the tools' input schemas if schemas is set. Only a failed
+// handshake is an error; a listing that fails is noted in the summary.
+func inspect(ctx context.Context, c *client.Client, schemas bool, out io.Writer) error {
+	init, err := c.Initialize(ctx)
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	fmt.Fprintf(out, "Server:   %s %s\n", init.ServerInfo.Name, init.ServerInfo.Version)
+	fmt.Fprintf(out, "Protocol: %s\n", init.ProtocolVersion)
+
+	fmt.Fprintln(out, "\nCapabilities:")
+	names := make([]string, 0, len(init.Capabilities))
+	for name := range init.Capabilities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		if flags := capabilityFlags(init.Capabilities[name]); flags != "" {
+			fmt.Fprintf(tw, "  %s\t%s\n", name, flags)
+		} else {
+			fmt.Fprintf(tw, "  %s\n", name)
+		}
+	}
+	tw.Flush()
+	if len(names) == 0 {
+		fmt.Fprintln(out, "  (none)")
+	}
+
+	if _, ok := init.Capabilities["tools"]; ok {
+		tools, err := c.ListTools(ctx)
+		fmt.Fprintf(out, "\nTools (%d):\n", len(tools))
+		for _, t := range tools {
+			describeTool(indented(out), t)
+			if schemas {
+				encoded, _ := json.MarshalIndent(t.InputSchema, "    ", "  ")
+				fmt.Fprintf(out, "    %s\n", encoded)
+			}
+		}
+		printListError(out, err)
+	}
+	if _, ok := init.Capabilities["resources"]; ok {
+		resources, err := c.ListResources(ctx)
+		fmt.Fprintf(out, "\nResources (%d):\n", len(resources))
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		for _, r := range resources {
+			desc := r.Description
+			if desc == "" {
+				desc = r.Name
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%s\n", r.URI, r.MimeType, desc)
+		}
+		tw.Flush()
+		printListError(out, err)
+	}
+	if _, ok := init.Capabilities["prompts"]; ok {
+		prompts, err := c.ListPrompts(ctx)
+		fmt.Fprintf(out, "\nPrompts (%d):\n", len(prompts))
+		for _, p := range prompts {
+			fmt.Fprintf(out, "  %s: %s\n", p.Name, p.Description)
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for _, a := range p.Arguments {
+				required := ""
+				if a.Required {
+					required = ", required"
+				}
+				fmt.Fprintf(tw, "    %s\t(string%s)\t%s\n", a.Name, required, a.Description)
+			}
+			tw.Flush()
+		}
+		printListError(out, err)
+	}
+	return nil
+}
+
+// capabilityFlags returns the options of a capability, such as
+// "listChanged", as a short line.
+func capabilityFlags(raw json.RawMessage) string {
+	var options map[string]interface{}
+	if json.Unmarshal(raw, &options) != nil || len(options) == 0 {
+		return ""
+	}
+	var flags []string
+	for name, value := range options {
+		switch v := value.(type) {
+		case bool:
+			if v {
+				flags = append(flags, name)
+			}
+		default:
+			encoded, _ := json.Marshal(v)
+			flags = append(flags, name+"="+string(encoded))
+		}
+	}
+	sort.Strings(flags)
+	return strings.Join(flags, ", ")
+}
+
+// printListError notes a listing that failed.
+func printListError(out io.Writer, err error) {
+	if err != nil {
+		fmt.Fprintf(out, "  (listing failed: %v)\n", err)
+	}
+}
+
+// indented returns a writer that indents every line written to out by two
+// spaces, to nest describeTool's output under a heading.
+func indented(out io.Writer) io.Writer {
+	return &indentWriter{w: out, start: true}
+}
+
+// indentWriter prefixes each line with two spaces.
+type indentWriter struct {
+	w     io.Writer
+	start bool // at the start of a line
+}
+
+// Write implements io.Writer.
+func (iw *indentWriter) Write(p []byte) (int, error) {
+	var b strings.Builder
+	for _, c := range string(p) {
+		if iw.start {
+			b.WriteString("  ")
+		}
+		b.WriteRune(c)
+		iw.start = c == '\n'
+	}
+	if _, err := io.WriteString(iw.w, b.String()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mcp-minimal-server-go/client"
+)
+
+// Test that inspect summarizes the server, its tools, resources, and
+// prompts
+func TestInspect(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.md"), []byte("# Notes"), 0o644)
+	files, err := newFileResources(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	promptDir := t.TempDir()
+	writePromptFiles(t, promptDir, map[string]string{
+		"greet.md": "---\ndescription: Greet someone\narguments:\n  - name: name\n    required: true\n---\nSay hello to {{name}}.",
+	})
+	library, err := newPromptLibrary(promptDir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(WithTools(&echoTool{}), WithResourceDir(files), WithPromptDir(library))
+
+	ctx := context.Background()
+	c := newInProcessClient(ctx, s)
+	defer c.Close()
+	var out strings.Builder
+	if err := inspect(ctx, c, true, &out); err != nil {
+		t.Fatalf("inspect error: %v", err)
+	}
+	for _, want := range []string{
+		"Server:   " + serverName + " " + serverVersion + "\n",
+		"Protocol: " + client.ProtocolVersion + "\n",
+		"\nCapabilities:\n",
+		"  prompts\n",
+		"\nTools (1):\n  echo: Returns the specified message as is\n    message  (string, required)  The string to echo\n",
+		`      "required": [`,
+		"/notes.md  text/markdown  notes.md\n",
+		"\nPrompts (1):\n  greet: Greet someone\n    name  (string, required)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the summary, got:\n%s", want, out.String())
+		}
+	}
+}
+
+// Test that a failed handshake is an error
+func TestInspectFailure(t *testing.T) {
+	clientIn, serverOut := io.Pipe()
+	serverOut.Close()
+	c := client.New(clientIn, io.Discard, nil)
+	if err := inspect(context.Background(), c, false, io.Discard); err == nil || !strings.Contains(err.Error(), "initialize") {
+		t.Errorf("expected the handshake to fail, got %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errClientUnresponsive is returned by Serve when the client stops
+// answering keepalive pings.
+var errClientUnresponsive = errors.New("the client stopped answering pings")
+
+// WithKeepalive pings the clients of sessions that carry requests to the
+// client, such as stdio ones, every interval once they are initialized,
+// and ends the session when a client does not answer within the interval,
+// so that a hung client does not hold on to its session. HTTP sessions
+// cannot be pinged; their idle timeout ends them instead. Zero disables
+// pings.
+func WithKeepalive(interval time.Duration) Option {
+	return func(s *Server) {
+		s.keepalive = interval
+	}
+}
+
+// pingClient pings the client of sess every keepalive interval until stop
+// is closed, and closes the returned channel once a ping goes unanswered.
+// Any response, even an error, shows the client is there.
+func (s *Server) pingClient(sess *session, stop <-chan struct{}) <-chan struct{} {
+	gone := make(chan struct{})
+	if s.keepalive <= 0 || sess.requests == nil {
+		return gone
+	}
+	go func() {
+		ticker := time.NewTicker(s.keepalive)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if !sess.lifecycle.operating() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(sess.ctx, s.keepalive)
+			_, err := sess.requests.call(ctx, sess.clientWriter(), "ping", map[string]interface{}{})
+			cancel()
+			if errors.Is(err, context.DeadlineExceeded) {
+				s.logger.Warn("client did not answer ping; ending its session", "session", sess.state.ID(), "timeout", s.keepalive)
+				close(gone)
+				return
+			}
+		}
+	}()
+	return gone
+}
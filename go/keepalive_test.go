@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// Test that a client answering pings keeps its session, and one that stops
+// answering loses it
+func TestKeepalive(t *testing.T) {
+	ping := regexp.MustCompile(`"id":"(srv-\d+)","jsonrpc":"2.0","method":"ping","params":\{\}`)
+
+	pw, out, errc := serveInBackground(context.Background(), NewServer(WithKeepalive(50*time.Millisecond)))
+	m := waitForOutput(t, out, ping, 1)
+	fmt.Fprintf(pw, `{"jsonrpc":"2.0","id":%q,"result":{}}`+"\n", m[0][1])
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Errorf("expected the session to end cleanly, got %v", err)
+	}
+
+	pw, out, errc = serveInBackground(context.Background(), NewServer(WithKeepalive(20*time.Millisecond), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))))
+	defer pw.Close()
+	waitForOutput(t, out, ping, 1)
+	select {
+	case err := <-errc:
+		if err != errClientUnresponsive {
+			t.Errorf("expected errClientUnresponsive, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the session to end when the client stopped answering")
+	}
+}
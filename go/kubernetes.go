@@ -0,0 +1,691 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// kubernetesConfig configures the kubectl_get tool in the "kubernetes"
+// section of the config file.
+type kubernetesConfig struct {
+	Kubeconfig string   `json:"kubeconfig"` // defaults to $KUBECONFIG, then ~/.kube/config
+	Context    string   `json:"context"`    // defaults to the current context
+	Kinds      []string `json:"kinds"`      // resources the tool may read, such as pods
+	Namespaces []string `json:"namespaces"` // namespaces it may read, or "*" for all
+	MaxItems   int      `json:"maxItems"`   // listed by get, defaults to 100
+	MaxBytes   int      `json:"maxBytes"`   // of a response, defaults to 256 KiB
+	LogLines   int      `json:"logLines"`   // most log lines, defaults to 200
+	Timeout    duration `json:"timeout"`    // per call, defaults to 15s
+}
+
+// Defaults of the kubectl_get tool.
+const (
+	defaultKubernetesMaxItems = 100
+	defaultKubernetesMaxBytes = 256 << 10
+	defaultKubernetesLogLines = 200
+	defaultKubernetesTimeout  = 15 * time.Second
+	maxKubernetesEvents       = 20 // most recent, shown by describe
+)
+
+// kubernetesKind is a resource the kubectl_get tool knows the API path of.
+type kubernetesKind struct {
+	group      string // the path of its API group version
+	kind       string // the Kind of its objects
+	namespaced bool
+}
+
+// kubernetesKinds are the resources the tool may be allowed to read, by
+// their plural names. Secrets are left out so that their values cannot
+// reach a model.
+var kubernetesKinds = map[string]kubernetesKind{
+	"pods":                   {"/api/v1", "Pod", true},
+	"services":               {"/api/v1", "Service", true},
+	"configmaps":             {"/api/v1", "ConfigMap", true},
+	"events":                 {"/api/v1", "Event", true},
+	"persistentvolumeclaims": {"/api/v1", "PersistentVolumeClaim", true},
+	"nodes":                  {"/api/v1", "Node", false},
+	"namespaces":             {"/api/v1", "Namespace", false},
+	"persistentvolumes":      {"/api/v1", "PersistentVolume", false},
+	"deployments":            {"/apis/apps/v1", "Deployment", true},
+	"replicasets":            {"/apis/apps/v1", "ReplicaSet", true},
+	"statefulsets":           {"/apis/apps/v1", "StatefulSet", true},
+	"daemonsets":             {"/apis/apps/v1", "DaemonSet", true},
+	"jobs":                   {"/apis/batch/v1", "Job", true},
+	"cronjobs":               {"/apis/batch/v1", "CronJob", true},
+	"ingresses":              {"/apis/networking.k8s.io/v1", "Ingress", true},
+}
+
+// kubernetesName matches the names of objects, namespaces, and containers.
+var kubernetesName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// validate reports malformed fields and kinds the tool cannot read.
+func (c *kubernetesConfig) validate() error {
+	if len(c.Kinds) == 0 || len(c.Namespaces) == 0 {
+		return errors.New("kubernetes: kinds and namespaces are required")
+	}
+	for _, k := range c.Kinds {
+		if k == "secrets" {
+			return errors.New("kubernetes: secrets cannot be read by the kubectl_get tool")
+		}
+		if _, ok := kubernetesKinds[k]; !ok {
+			return fmt.Errorf("kubernetes: unknown kind %q", k)
+		}
+	}
+	for _, ns := range c.Namespaces {
+		if ns != "*" && !kubernetesName.MatchString(ns) {
+			return fmt.Errorf("kubernetes: invalid namespace %q", ns)
+		}
+	}
+	if c.MaxItems < 0 || c.MaxBytes < 0 || c.LogLines < 0 || c.Timeout < 0 {
+		return errors.New("kubernetes: maxItems, maxBytes, logLines, and timeout must not be negative")
+	}
+	return nil
+}
+
+// kubeconfig is the part of a kubeconfig file the tool reads. The *-data
+// fields are base64 in the file, which encoding/json decodes into bytes.
+type kubeconfig struct {
+	CurrentContext string `json:"current-context"`
+	Clusters       []struct {
+		Name    string `json:"name"`
+		Cluster struct {
+			Server                   string `json:"server"`
+			TLSServerName            string `json:"tls-server-name"`
+			InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify"`
+			CertificateAuthority     string `json:"certificate-authority"`
+			CertificateAuthorityData []byte `json:"certificate-authority-data"`
+		} `json:"cluster"`
+	} `json:"clusters"`
+	Contexts []struct {
+		Name    string `json:"name"`
+		Context struct {
+			Cluster   string `json:"cluster"`
+			User      string `json:"user"`
+			Namespace string `json:"namespace"`
+		} `json:"context"`
+	} `json:"contexts"`
+	Users []struct {
+		Name string `json:"name"`
+		User struct {
+			Token                 string          `json:"token"`
+			TokenFile             string          `json:"tokenFile"`
+			Username              string          `json:"username"`
+			Password              string          `json:"password"`
+			ClientCertificate     string          `json:"client-certificate"`
+			ClientCertificateData []byte          `json:"client-certificate-data"`
+			ClientKey             string          `json:"client-key"`
+			ClientKeyData         []byte          `json:"client-key-data"`
+			Exec                  json.RawMessage `json:"exec"`
+			AuthProvider          json.RawMessage `json:"auth-provider"`
+		} `json:"user"`
+	} `json:"users"`
+}
+
+// kubectlGetTool reads resources of the allowed kinds and namespaces from
+// the cluster of a kubeconfig context, the way kubectl get, describe, and
+// logs do, through the Kubernetes API.
+type kubectlGetTool struct {
+	cfg       kubernetesConfig
+	context   string
+	server    string
+	namespace string // of the context
+	client    *http.Client
+	auth      func(*http.Request) error
+}
+
+// newKubectlGetTool reads the kubeconfig file and returns the kubectl_get
+// tool for the context of cfg.
+func newKubectlGetTool(cfg kubernetesConfig) (*kubectlGetTool, error) {
+	if cfg.MaxItems == 0 {
+		cfg.MaxItems = defaultKubernetesMaxItems
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultKubernetesMaxBytes
+	}
+	if cfg.LogLines == 0 {
+		cfg.LogLines = defaultKubernetesLogLines
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = duration(defaultKubernetesTimeout)
+	}
+	path, err := kubeconfigPath(cfg.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %v", err)
+	}
+	doc, err := parseYAML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes: %s: %v", path, err)
+	}
+	var kc kubeconfig
+	encoded, _ := json.Marshal(doc)
+	if err := json.Unmarshal(encoded, &kc); err != nil {
+		return nil, fmt.Errorf("kubernetes: %s: %v", path, err)
+	}
+	t := &kubectlGetTool{cfg: cfg}
+	if err := t.load(&kc, filepath.Dir(path)); err != nil {
+		return nil, fmt.Errorf("kubernetes: %s: %v", path, err)
+	}
+	return t, nil
+}
+
+// kubeconfigPath returns the kubeconfig file to read: the configured one,
+// the first in $KUBECONFIG, or ~/.kube/config.
+func kubeconfigPath(configured string) (string, error) {
+	if configured != "" {
+		return os.ExpandEnv(configured), nil
+	}
+	if env := filepath.SplitList(os.Getenv("KUBECONFIG")); len(env) > 0 && env[0] != "" {
+		return env[0], nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("kubernetes: no kubeconfig: %v", err)
+	}
+	return filepath.Join(home, ".kube", "config"), nil
+}
+
+// load sets up the client for the context of the tool in kc, reading the
+// files it names relative to dir.
+func (t *kubectlGetTool) load(kc *kubeconfig, dir string) error {
+	t.context = t.cfg.Context
+	if t.context == "" {
+		t.context = kc.CurrentContext
+	}
+	var kctx *struct {
+		Cluster   string `json:"cluster"`
+		User      string `json:"user"`
+		Namespace string `json:"namespace"`
+	}
+	for i := range kc.Contexts {
+		if kc.Contexts[i].Name == t.context {
+			kctx = &kc.Contexts[i].Context
+		}
+	}
+	if kctx == nil {
+		return fmt.Errorf("no context %q", t.context)
+	}
+	t.namespace = kctx.Namespace
+	if t.namespace == "" {
+		t.namespace = "default"
+	}
+
+	file := func(name string) string {
+		if name == "" || filepath.IsAbs(name) {
+			return name
+		}
+		return filepath.Join(dir, name)
+	}
+	readData := func(data []byte, name string) ([]byte, error) {
+		if len(data) > 0 || name == "" {
+			return data, nil
+		}
+		return os.ReadFile(file(name))
+	}
+
+	tlsConfig := &tls.Config{}
+	found := false
+	for _, c := range kc.Clusters {
+		if c.Name != kctx.Cluster {
+			continue
+		}
+		found = true
+		t.server = strings.TrimSuffix(c.Cluster.Server, "/")
+		tlsConfig.ServerName = c.Cluster.TLSServerName
+		tlsConfig.InsecureSkipVerify = c.Cluster.InsecureSkipTLSVerify
+		ca, err := readData(c.Cluster.CertificateAuthorityData, c.Cluster.CertificateAuthority)
+		if err != nil {
+			return err
+		}
+		if len(ca) > 0 {
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return fmt.Errorf("cluster %q: no certificates in its certificate authority", c.Name)
+			}
+		}
+	}
+	if !found || t.server == "" {
+		return fmt.Errorf("context %q has no cluster with a server", t.context)
+	}
+
+	t.auth = func(*http.Request) error { return nil }
+	for _, u := range kc.Users {
+		if u.Name != kctx.User {
+			continue
+		}
+		user := u.User
+		switch {
+		case len(user.Exec) > 0 && string(user.Exec) != "null", len(user.AuthProvider) > 0 && string(user.AuthProvider) != "null":
+			return fmt.Errorf("user %q authenticates through a plugin, which the kubectl_get tool does not run; use a token or a client certificate", u.Name)
+		case user.Token != "":
+			t.auth = func(req *http.Request) error {
+				req.Header.Set("Authorization", "Bearer "+user.Token)
+				return nil
+			}
+		case user.TokenFile != "":
+			// Token files are read per request, since they are rotated.
+			path := file(user.TokenFile)
+			t.auth = func(req *http.Request) error {
+				token, err := os.ReadFile(path)
+				if err != nil {
+					return err
+				}
+				req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+				return nil
+			}
+		case user.Username != "":
+			t.auth = func(req *http.Request) error {
+				req.SetBasicAuth(user.Username, user.Password)
+				return nil
+			}
+		}
+		cert, err := readData(user.ClientCertificateData, user.ClientCertificate)
+		if err != nil {
+			return err
+		}
+		key, err := readData(user.ClientKeyData, user.ClientKey)
+		if err != nil {
+			return err
+		}
+		if len(cert) > 0 {
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return fmt.Errorf("user %q: %v", u.Name, err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{pair}
+		}
+	}
+	t.client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}}
+	return nil
+}
+
+// kubectlGetArgs are the arguments of the kubectl_get tool.
+type kubectlGetArgs struct {
+	Action        string `json:"action" enum:"get,describe,logs" description:"What to do: get lists resources or returns one, describe returns one with its recent events, and logs returns the logs of a pod"`
+	Kind          string `json:"kind" description:"The kind of resource, such as pods"`
+	Name          string `json:"name,omitempty" description:"Name of the resource; required for describe and logs"`
+	Namespace     string `json:"namespace,omitempty" description:"Namespace of the resource (default the context's)"`
+	LabelSelector string `json:"label_selector,omitempty" description:"Label selector of the resources to list, such as app=web"`
+	Container     string `json:"container,omitempty" description:"Container of the pod whose logs to return (default its only one)"`
+	TailLines     int    `json:"tail_lines,omitempty" minimum:"1" description:"Number of log lines to return, from the end (default the server's limit)"`
+	Previous      bool   `json:"previous,omitempty" description:"Return the logs of the previous instance of the container, after a restart"`
+}
+
+// Name returns the name of the kubectl_get tool.
+func (t *kubectlGetTool) Name() string {
+	return "kubectl_get"
+}
+
+// Description returns a brief description of the kubectl_get tool.
+func (t *kubectlGetTool) Description() string {
+	namespaces := strings.Join(t.cfg.Namespaces, ", ")
+	if slices.Contains(t.cfg.Namespaces, "*") {
+		namespaces = "any namespace"
+	}
+	return fmt.Sprintf("Reads the Kubernetes cluster of context %s like kubectl get, describe, and logs. Kinds: %s; namespaces: %s", t.context, strings.Join(t.cfg.Kinds, ", "), namespaces)
+}
+
+// InputSchema returns the JSON schema for the kubectl_get tool's input
+// parameters, with the allowed kinds as an enum.
+func (t *kubectlGetTool) InputSchema() map[string]interface{} {
+	schema := mcp.SchemaFor(kubectlGetArgs{})
+	props := schema["properties"].(map[string]interface{})
+	props["kind"].(map[string]interface{})["enum"] = t.cfg.Kinds
+	props["tail_lines"].(map[string]interface{})["maximum"] = t.cfg.LogLines
+	if !slices.Contains(t.cfg.Namespaces, "*") {
+		props["namespace"].(map[string]interface{})["enum"] = t.cfg.Namespaces
+	}
+	return schema
+}
+
+// Annotations marks the kubectl_get tool as read-only. The cluster is
+// outside this server and changes, so the tool is neither closed nor
+// idempotent.
+func (t *kubectlGetTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "Kubernetes", ReadOnlyHint: true}
+}
+
+// Timeout returns the configured time limit of a call.
+func (t *kubectlGetTool) Timeout() time.Duration {
+	return time.Duration(t.cfg.Timeout)
+}
+
+// Execute runs the action without a deadline of its own.
+func (t *kubectlGetTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the action and returns its result as text.
+func (t *kubectlGetTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult runs the action and returns its result: a table or an
+// object as structured content, or logs as text.
+func (t *kubectlGetTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a kubectlGetArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	kind, err := t.check(&a)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.Timeout))
+	defer cancel()
+	var result *ToolResult
+	switch a.Action {
+	case "get":
+		if a.Name == "" {
+			result, err = t.list(ctx, kind, a)
+		} else {
+			var obj map[string]interface{}
+			if obj, err = t.object(ctx, kind, a); err == nil {
+				result = mcp.NewResult().WithStructured(map[string]interface{}{"object": obj})
+			}
+		}
+	case "describe":
+		result, err = t.describe(ctx, kind, a)
+	default:
+		result, err = t.logs(ctx, a)
+	}
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return result, err
+}
+
+// check reports invalid arguments and returns the kind of a, filling in
+// its namespace.
+func (t *kubectlGetTool) check(a *kubectlGetArgs) (kubernetesKind, error) {
+	if a.Action != "get" && a.Action != "describe" && a.Action != "logs" {
+		return kubernetesKind{}, errors.New("invalid value for 'action': expected get, describe, or logs")
+	}
+	if !slices.Contains(t.cfg.Kinds, a.Kind) {
+		return kubernetesKind{}, fmt.Errorf("invalid value for 'kind': expected one of %s", strings.Join(t.cfg.Kinds, ", "))
+	}
+	kind := kubernetesKinds[a.Kind]
+	switch {
+	case a.Name != "" && !kubernetesName.MatchString(a.Name):
+		return kind, errors.New("invalid value for 'name'")
+	case a.Container != "" && !kubernetesName.MatchString(a.Container):
+		return kind, errors.New("invalid value for 'container'")
+	case a.Action != "get" && a.Name == "":
+		return kind, fmt.Errorf("'name' is required for %s", a.Action)
+	case a.Action == "logs" && a.Kind != "pods":
+		return kind, errors.New("logs are only available for pods")
+	case a.TailLines < 0 || a.TailLines > t.cfg.LogLines:
+		return kind, fmt.Errorf("invalid value for 'tail_lines': expected 1 to %d", t.cfg.LogLines)
+	}
+	if !kind.namespaced {
+		a.Namespace = ""
+		return kind, nil
+	}
+	if a.Namespace == "" {
+		a.Namespace = t.namespace
+	}
+	if !slices.Contains(t.cfg.Namespaces, "*") && !slices.Contains(t.cfg.Namespaces, a.Namespace) {
+		return kind, fmt.Errorf("namespace %q is not allowed; expected one of %s", a.Namespace, strings.Join(t.cfg.Namespaces, ", "))
+	}
+	if !kubernetesName.MatchString(a.Namespace) {
+		return kind, errors.New("invalid value for 'namespace'")
+	}
+	return kind, nil
+}
+
+// resourcePath returns the API path of the resources of kind in namespace,
+// or of the one named name.
+func resourcePath(kind kubernetesKind, resource, namespace, name string) string {
+	p := kind.group
+	if namespace != "" {
+		p += "/namespaces/" + namespace
+	}
+	p += "/" + resource
+	if name != "" {
+		p += "/" + name
+	}
+	return p
+}
+
+// get fetches the API path p with query, accepting content of type accept,
+// and returns the body, failing when it is longer than the configured
+// limit unless clip is set.
+func (t *kubectlGetTool) get(ctx context.Context, p string, query url.Values, accept string, clip bool) ([]byte, bool, error) {
+	u := t.server + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", accept)
+	if err := t.auth(req); err != nil {
+		return nil, false, toolFailure("kubernetes: %v", err)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, false, toolFailure("kubernetes API at %s: %v", t.server, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.cfg.MaxBytes)+1))
+	if err != nil {
+		return nil, false, toolFailure("kubernetes API at %s: %v", t.server, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Failures come as a Status object with a message.
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &status) == nil && status.Message != "" {
+			return nil, false, toolFailure("kubernetes: %s", status.Message)
+		}
+		return nil, false, toolFailure("kubernetes: %s", resp.Status)
+	}
+	truncated := len(body) > t.cfg.MaxBytes
+	if truncated && !clip {
+		return nil, false, toolFailure("kubernetes: the response is larger than %d bytes; narrow it with a name or a label_selector", t.cfg.MaxBytes)
+	}
+	if truncated {
+		body = body[:t.cfg.MaxBytes]
+	}
+	return body, truncated, nil
+}
+
+// kubernetesTable is a list the API server rendered as kubectl prints it.
+type kubernetesTable struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	ColumnDefinitions []struct {
+		Name     string `json:"name"`
+		Priority int    `json:"priority"`
+	} `json:"columnDefinitions"`
+	Rows []struct {
+		Cells []interface{} `json:"cells"`
+	} `json:"rows"`
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	} `json:"items"` // of servers that return a plain list instead
+}
+
+// list lists up to the configured number of resources as the default
+// columns of kubectl get.
+func (t *kubectlGetTool) list(ctx context.Context, kind kubernetesKind, a kubectlGetArgs) (*ToolResult, error) {
+	query := url.Values{"limit": {strconv.Itoa(t.cfg.MaxItems)}}
+	if a.LabelSelector != "" {
+		query.Set("labelSelector", a.LabelSelector)
+	}
+	body, _, err := t.get(ctx, resourcePath(kind, a.Kind, a.Namespace, ""), query, "application/json;as=Table;v=v1;g=meta.k8s.io, application/json", false)
+	if err != nil {
+		return nil, err
+	}
+	var table kubernetesTable
+	if err := json.Unmarshal(body, &table); err != nil {
+		return nil, toolFailure("kubernetes: malformed list: %v", err)
+	}
+	columns := []string{}
+	rows := [][]interface{}{}
+	if table.Kind == "Table" {
+		var keep []int
+		for i, c := range table.ColumnDefinitions {
+			if c.Priority == 0 {
+				keep = append(keep, i)
+				columns = append(columns, c.Name)
+			}
+		}
+		for _, r := range table.Rows {
+			row := make([]interface{}, 0, len(keep))
+			for _, i := range keep {
+				if i < len(r.Cells) {
+					row = append(row, r.Cells[i])
+				}
+			}
+			rows = append(rows, row)
+		}
+	} else {
+		columns = append(columns, "Name")
+		for _, item := range table.Items {
+			rows = append(rows, []interface{}{item.Metadata.Name})
+		}
+	}
+	result := map[string]interface{}{"kind": a.Kind, "columns": columns, "rows": rows}
+	if a.Namespace != "" {
+		result["namespace"] = a.Namespace
+	}
+	if table.Metadata.Continue != "" {
+		result["truncated"] = true
+	}
+	return mcp.NewResult().WithStructured(result), nil
+}
+
+// object returns the resource named in a, without the fields kept for
+// the server's own bookkeeping.
+func (t *kubectlGetTool) object(ctx context.Context, kind kubernetesKind, a kubectlGetArgs) (map[string]interface{}, error) {
+	body, _, err := t.get(ctx, resourcePath(kind, a.Kind, a.Namespace, a.Name), nil, "application/json", false)
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return nil, toolFailure("kubernetes: malformed object: %v", err)
+	}
+	if meta, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(meta, "managedFields")
+		if annotations, ok := meta["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+		}
+	}
+	return obj, nil
+}
+
+// kubernetesEvent is the part of an event describe reports.
+type kubernetesEvent struct {
+	Type           string `json:"type"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message"`
+	Count          int    `json:"count,omitempty"`
+	LastTimestamp  string `json:"lastTimestamp,omitempty"`
+	EventTime      string `json:"eventTime,omitempty"`
+	InvolvedObject *struct {
+		Name string `json:"name"`
+	} `json:"involvedObject,omitempty"`
+}
+
+// describe returns the resource named in a with its most recent events.
+func (t *kubectlGetTool) describe(ctx context.Context, kind kubernetesKind, a kubectlGetArgs) (*ToolResult, error) {
+	obj, err := t.object(ctx, kind, a)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{
+		"fieldSelector": {"involvedObject.name=" + a.Name + ",involvedObject.kind=" + kind.kind},
+		"limit":         {strconv.Itoa(t.cfg.MaxItems)},
+	}
+	eventsPath := "/api/v1/events"
+	if a.Namespace != "" {
+		eventsPath = "/api/v1/namespaces/" + a.Namespace + "/events"
+	}
+	body, _, err := t.get(ctx, eventsPath, query, "application/json", false)
+	if err != nil {
+		return nil, err
+	}
+	var list struct {
+		Items []kubernetesEvent `json:"items"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, toolFailure("kubernetes: malformed events: %v", err)
+	}
+	events := list.Items
+	for i := range events {
+		if events[i].LastTimestamp == "" {
+			events[i].LastTimestamp = events[i].EventTime
+		}
+		events[i].EventTime, events[i].InvolvedObject = "", nil
+	}
+	// RFC 3339 times in UTC sort as strings.
+	sort.SliceStable(events, func(i, j int) bool { return events[i].LastTimestamp < events[j].LastTimestamp })
+	if len(events) > maxKubernetesEvents {
+		events = events[len(events)-maxKubernetesEvents:]
+	}
+	return mcp.NewResult().WithStructured(map[string]interface{}{"object": obj, "events": events}), nil
+}
+
+// logs returns the last lines of the logs of the pod named in a, cut to
+// the configured size.
+func (t *kubectlGetTool) logs(ctx context.Context, a kubectlGetArgs) (*ToolResult, error) {
+	lines := a.TailLines
+	if lines == 0 {
+		lines = t.cfg.LogLines
+	}
+	query := url.Values{
+		"tailLines":  {strconv.Itoa(lines)},
+		"limitBytes": {strconv.Itoa(t.cfg.MaxBytes)},
+	}
+	if a.Container != "" {
+		query.Set("container", a.Container)
+	}
+	if a.Previous {
+		query.Set("previous", "true")
+	}
+	body, truncated, err := t.get(ctx, resourcePath(kubernetesKinds["pods"], "pods", a.Namespace, a.Name)+"/log", query, "text/plain", true)
+	if err != nil {
+		return nil, err
+	}
+	text := string(body)
+	if truncated || len(body) == t.cfg.MaxBytes {
+		text += fmt.Sprintf("\n[logs cut at %d bytes]", t.cfg.MaxBytes)
+	}
+	return mcp.NewResult(mcp.NewTextContent(text)), nil
+}
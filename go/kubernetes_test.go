@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startFakeKubernetes serves a few API paths of a cluster with pods in the
+// web namespace, over TLS with a bearer token, and returns a kubeconfig
+// file for it with a context named test.
+func startFakeKubernetes(t *testing.T, user string) string {
+	t.Helper()
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"kind":"Status","message":"Unauthorized"}`)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/namespaces/web/pods":
+			if !strings.Contains(r.Header.Get("Accept"), "as=Table") || r.URL.Query().Get("limit") != "2" {
+				t.Errorf("unexpected list request %v %q", r.URL, r.Header.Get("Accept"))
+			}
+			fmt.Fprint(w, `{"kind":"Table","metadata":{"continue":"more"},
+				"columnDefinitions":[{"name":"Name","priority":0},{"name":"Status","priority":0},{"name":"IP","priority":1}],
+				"rows":[{"cells":["web-1","Running","10.0.0.1"]},{"cells":["web-2","CrashLoopBackOff","10.0.0.2"]}]}`)
+		case "/api/v1/namespaces/web/pods/web-2":
+			fmt.Fprint(w, `{"kind":"Pod","metadata":{"name":"web-2","managedFields":[{}],
+				"annotations":{"team":"web","kubectl.kubernetes.io/last-applied-configuration":"{}"}},"status":{"phase":"Running"}}`)
+		case "/api/v1/namespaces/web/events":
+			if want := "involvedObject.name=web-2,involvedObject.kind=Pod"; r.URL.Query().Get("fieldSelector") != want {
+				t.Errorf("unexpected field selector %q", r.URL.Query().Get("fieldSelector"))
+			}
+			fmt.Fprint(w, `{"items":[
+				{"type":"Warning","reason":"BackOff","message":"Back-off restarting","count":7,"lastTimestamp":"2026-10-14T10:05:00Z"},
+				{"type":"Normal","reason":"Pulled","message":"Image pulled","eventTime":"2026-10-14T10:00:00Z"}]}`)
+		case "/api/v1/namespaces/web/pods/web-2/log":
+			if q := r.URL.Query(); q.Get("tailLines") != "5" || q.Get("container") != "app" || q.Get("previous") != "true" {
+				t.Errorf("unexpected log query %v", q)
+			}
+			fmt.Fprint(w, strings.Repeat("panic: boom\n", 100))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"kind":"Status","message":"%s not found"}`, r.URL.Path)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+    namespace: web
+users:
+- name: test
+  user:
+%s
+preferences: {}
+`, ts.URL, base64.StdEncoding.EncodeToString(ca), user)
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(kubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// callKubectlGet calls tool and returns its structured result as JSON, or
+// its text if it has none.
+func callKubectlGet(t *testing.T, tool *kubectlGetTool, args map[string]interface{}) (string, error) {
+	t.Helper()
+	result, err := tool.ExecuteResult(context.Background(), args)
+	if err != nil {
+		return "", err
+	}
+	if result.Structured == nil {
+		return result.Content[0].Text, nil
+	}
+	encoded, _ := json.Marshal(result.Structured)
+	return string(encoded), nil
+}
+
+// Test that the tool lists, describes, and shows the logs of the allowed
+// resources through the API server of the kubeconfig context
+func TestKubectlGet(t *testing.T) {
+	path := startFakeKubernetes(t, "    token: t0ken")
+	tool, err := newKubectlGetTool(kubernetesConfig{Kubeconfig: path, Kinds: []string{"pods", "deployments"}, Namespaces: []string{"web"}, MaxItems: 2, MaxBytes: 1000, LogLines: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Reads the Kubernetes cluster of context test like kubectl get, describe, and logs. Kinds: pods, deployments; namespaces: web"; tool.Description() != want {
+		t.Errorf("unexpected description %q", tool.Description())
+	}
+
+	for _, tc := range []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"action": "get", "kind": "pods"},
+			`{"columns":["Name","Status"],"kind":"pods","namespace":"web","rows":[["web-1","Running"],["web-2","CrashLoopBackOff"]],"truncated":true}`},
+		{map[string]interface{}{"action": "get", "kind": "pods", "name": "web-2"},
+			`{"object":{"kind":"Pod","metadata":{"annotations":{"team":"web"},"name":"web-2"},"status":{"phase":"Running"}}}`},
+		{map[string]interface{}{"action": "describe", "kind": "pods", "name": "web-2", "namespace": "web"},
+			`{"events":[{"type":"Normal","reason":"Pulled","message":"Image pulled","lastTimestamp":"2026-10-14T10:00:00Z"},` +
+				`{"type":"Warning","reason":"BackOff","message":"Back-off restarting","count":7,"lastTimestamp":"2026-10-14T10:05:00Z"}],` +
+				`"object":{"kind":"Pod","metadata":{"annotations":{"team":"web"},"name":"web-2"},"status":{"phase":"Running"}}}`},
+		{map[string]interface{}{"action": "logs", "kind": "pods", "name": "web-2", "container": "app", "tail_lines": 5, "previous": true},
+			strings.Repeat("panic: boom\n", 100)[:1000] + "\n[logs cut at 1000 bytes]"},
+	} {
+		got, err := callKubectlGet(t, tool, tc.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tc.args, err)
+		} else if got != tc.want {
+			t.Errorf("%v: expected\n%s\ngot\n%s", tc.args, tc.want, got)
+		}
+	}
+
+	for want, args := range map[string]map[string]interface{}{
+		"namespace \"kube-system\" is not allowed": {"action": "get", "kind": "pods", "namespace": "kube-system"},
+		"expected one of pods, deployments":        {"action": "get", "kind": "secrets"},
+		"only available for pods":                  {"action": "logs", "kind": "deployments", "name": "web"},
+		"'name' is required for describe":          {"action": "describe", "kind": "pods"},
+		"invalid value for 'name'":                 {"action": "get", "kind": "pods", "name": "../secrets"},
+		"/deployments/api not found":               {"action": "get", "kind": "deployments", "name": "api"},
+		"invalid value for 'action'":               {"action": "delete", "kind": "pods", "name": "web-2"},
+	} {
+		if _, err := tool.ExecuteResult(context.Background(), args); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected an error containing %q, got %v", args, want, err)
+		}
+	}
+}
+
+// Test that kubeconfig users are authenticated the ways the tool supports
+func TestKubectlGetUsers(t *testing.T) {
+	cfg := kubernetesConfig{Kinds: []string{"pods"}, Namespaces: []string{"*"}}
+
+	path := startFakeKubernetes(t, "    tokenFile: token")
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "token"), []byte("t0ken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", path)
+	tool, err := newKubectlGetTool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.ExecuteResult(context.Background(), map[string]interface{}{"action": "get", "kind": "pods", "name": "web-2"}); err != nil {
+		t.Errorf("expected the token file to be read relative to the kubeconfig, got %v", err)
+	}
+
+	path = startFakeKubernetes(t, "    token: wrong")
+	cfg.Kubeconfig = path
+	tool, err = newKubectlGetTool(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tool.ExecuteResult(context.Background(), map[string]interface{}{"action": "get", "kind": "pods", "name": "web-2"}); err == nil || !strings.Contains(err.Error(), "Unauthorized") {
+		t.Errorf("expected the API server's refusal, got %v", err)
+	}
+
+	path = startFakeKubernetes(t, "    exec:\n      command: aws")
+	cfg.Kubeconfig = path
+	if _, err := newKubectlGetTool(cfg); err == nil || !strings.Contains(err.Error(), "plugin") {
+		t.Errorf("expected exec credentials to be refused, got %v", err)
+	}
+	cfg.Context = "prod"
+	if _, err := newKubectlGetTool(cfg); err == nil || !strings.Contains(err.Error(), `no context "prod"`) {
+		t.Errorf("expected a missing context to be reported, got %v", err)
+	}
+}
+
+// Test that malformed config sections are rejected
+func TestKubernetesConfig(t *testing.T) {
+	if err := (&kubernetesConfig{Kinds: []string{"pods", "nodes"}, Namespaces: []string{"*"}}).validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, c := range []kubernetesConfig{
+		{Kinds: []string{"pods"}},
+		{Kinds: []string{"secrets"}, Namespaces: []string{"web"}},
+		{Kinds: []string{"widgets"}, Namespaces: []string{"web"}},
+		{Kinds: []string{"pods"}, Namespaces: []string{"Web/1"}},
+		{Kinds: []string{"pods"}, Namespaces: []string{"web"}, MaxBytes: -1},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import "sync"
+
+// lifecycleState is a stage of the initialization handshake.
+type lifecycleState int
+
+const (
+	awaitingInitialize  lifecycleState = iota // nothing but initialize and ping yet
+	awaitingInitialized                       // initialize answered, the client has not confirmed
+	operating                                 // the handshake is complete
+	shuttingDown                              // shutdown answered, so only exit and ping are accepted
+)
+
+// lifecycle enforces the MCP lifecycle on a session: the first request must
+// be initialize, and other requests are only accepted once the client has
+// sent the initialized notification. Clients may end the session as in
+// the Language Server Protocol, with a shutdown request after which no
+// other requests are accepted, then an exit notification. Pings and exit
+// are accepted at any time. It is safe for concurrent use.
+type lifecycle struct {
+	mu      sync.Mutex
+	state   lifecycleState
+	version string // the protocol version agreed in initialize
+	exit    bool   // the client sent exit
+	inOrder bool   // it sent shutdown first
+}
+
+// batchesRemovedIn is the first protocol version without JSON-RPC batches.
+const batchesRemovedIn = "2025-06-18"
+
+// setVersion records the protocol version agreed in initialize.
+func (l *lifecycle) setVersion(version string) {
+	l.mu.Lock()
+	l.version = version
+	l.mu.Unlock()
+}
+
+// allowsBatches reports whether the agreed protocol version has JSON-RPC
+// batches. Versions are dates, so they order as strings.
+func (l *lifecycle) allowsBatches() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.version != "" && l.version < batchesRemovedIn
+}
+
+// admit reports whether a message for method may be handled in the
+// session's current state, advancing the state for the handshake's own
+// messages. Rejected requests are answered with message as an Invalid
+// Request error; rejected notifications are dropped.
+func (l *lifecycle) admit(method string) (ok bool, message string) {
+	if method == "ping" || method == "exit" {
+		return true, ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.state == shuttingDown:
+		return false, "Invalid Request: the server is shutting down"
+	case method == "shutdown" && l.state == operating:
+		l.state = shuttingDown
+		return true, ""
+	case method == "initialize":
+		if l.state != awaitingInitialize {
+			return false, "Invalid Request: the session is already initialized"
+		}
+		l.state = awaitingInitialized
+		return true, ""
+	case method == "notifications/initialized" || method == "initialized":
+		if l.state == awaitingInitialized {
+			l.state = operating
+		}
+		return true, ""
+	case l.state == awaitingInitialize:
+		return false, "Invalid Request: the session must be initialized first"
+	case l.state == awaitingInitialized:
+		return false, "Invalid Request: waiting for the initialized notification"
+	}
+	return true, ""
+}
+
+// operating reports whether the handshake is complete and the session is
+// not shutting down.
+func (l *lifecycle) operating() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state == operating
+}
+
+// exitRequested records that the client sent exit.
+func (l *lifecycle) exitRequested() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.exit, l.inOrder = true, l.state == shuttingDown
+}
+
+// exited reports whether the client sent exit and, if so, whether it sent
+// shutdown first.
+func (l *lifecycle) exited() (exited, inOrder bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.exit, l.inOrder
+}
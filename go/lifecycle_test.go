@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the lifecycle state machine
+func TestLifecycleAdmit(t *testing.T) {
+	var l lifecycle
+	for _, step := range []struct {
+		method string
+		ok     bool
+	}{
+		{"tools/list", false},
+		{"ping", true},
+		{"initialize", true},
+		{"tools/list", false},
+		{"initialize", false},
+		{"notifications/initialized", true},
+		{"tools/list", true},
+		{"initialize", false},
+		{"shutdown", true},
+		{"tools/list", false},
+		{"ping", true},
+		{"exit", true},
+	} {
+		if ok, message := l.admit(step.method); ok != step.ok {
+			t.Errorf("admit(%q) = %v, %q, expected %v", step.method, ok, message, step.ok)
+		}
+	}
+}
+
+// Test that each HTTP session goes through its own handshake
+func TestLifecycleHTTP(t *testing.T) {
+	ts := httptest.NewServer(newHTTPTransport(NewServer(), nil))
+	defer ts.Close()
+
+	first := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`).Header.Get(sessionIDHeader)
+	second := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`).Header.Get(sessionIDHeader)
+	postMCP(t, ts.URL, first, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	list := `{"jsonrpc":"2.0","method":"tools/list","id":2}`
+	if body, _ := io.ReadAll(postMCP(t, ts.URL, first, list).Body); !strings.Contains(string(body), `"tools":[`) {
+		t.Errorf("expected the tools of the initialized session, got %s", body)
+	}
+	if body, _ := io.ReadAll(postMCP(t, ts.URL, second, list).Body); !strings.Contains(string(body), "waiting for the initialized notification") {
+		t.Errorf("expected the second session to wait for its notification, got %s", body)
+	}
+	if resp := postMCP(t, ts.URL, first, `{"jsonrpc":"2.0","method":"initialize","params":{},"id":3}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected a repeated initialize to be answered in the session, got %d", resp.StatusCode)
+	} else if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "already initialized") {
+		t.Errorf("expected a repeated initialize to be rejected, got %s", body)
+	}
+
+	for _, id := range []string{first, second} {
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+		req.Header.Set(sessionIDHeader, id)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+}
+
+// Test that shutdown stops admitting requests and exit then ends Serve
+// while the input is still open
+func TestShutdownExit(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"in order", `{"jsonrpc":"2.0","method":"shutdown","id":1}` + "\n" +
+			`{"jsonrpc":"2.0","method":"tools/list","id":2}` + "\n" +
+			`{"jsonrpc":"2.0","method":"exit"}` + "\n", nil},
+		{"without shutdown", `{"jsonrpc":"2.0","method":"exit"}` + "\n", errExitWithoutShutdown},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pw, out, errc := serveInBackground(context.Background(), NewServer())
+			defer pw.Close()
+			io.WriteString(pw, tc.input)
+			select {
+			case err := <-errc:
+				if !errors.Is(err, tc.err) {
+					t.Errorf("expected %v, got %v", tc.err, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected exit to end Serve")
+			}
+			if tc.err != nil {
+				return
+			}
+			lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+			if len(lines) != 2 || !strings.Contains(lines[0], `"result":{}`) || !strings.Contains(lines[1], "the server is shutting down") {
+				t.Errorf("expected shutdown to be answered and tools/list rejected, got %q", lines)
+			}
+		})
+	}
+}
+
+// Test that an HTTP session ends when its client sends exit
+func TestShutdownExitHTTP(t *testing.T) {
+	ts := httptest.NewServer(newHTTPTransport(NewServer(), nil))
+	defer ts.Close()
+
+	id := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`).Header.Get(sessionIDHeader)
+	postMCP(t, ts.URL, id, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	postMCP(t, ts.URL, id, `{"jsonrpc":"2.0","method":"shutdown","id":2}`)
+	postMCP(t, ts.URL, id, `{"jsonrpc":"2.0","method":"exit"}`)
+	if resp := postMCP(t, ts.URL, id, `{"jsonrpc":"2.0","method":"ping","id":3}`); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected the session to be gone after exit, got %d", resp.StatusCode)
+	}
+}
+
+// failingReader fails every read.
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("input/output error") }
+
+// Test that read failures are distinguished and buffered output is flushed
+func TestServeErrors(t *testing.T) {
+	if err := NewServer().Serve(context.Background(), failingReader{}, io.Discard); !errors.Is(err, errReadFailed) {
+		t.Errorf("expected a read failure, got %v", err)
+	}
+
+	var out strings.Builder
+	w := bufio.NewWriterSize(&out, 1<<16)
+	input := `{"jsonrpc":"2.0","method":"ping","id":1}` + "\n"
+	if err := NewServer().Serve(context.Background(), strings.NewReader(input), w); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"id":1`) {
+		t.Errorf("expected the buffered response to be flushed, got %q", out.String())
+	}
+}
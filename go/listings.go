@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+)
+
+// listings caches the encoded results of tools/list, resources/list and
+// prompts/list. Building them walks every tool's schema, so with a large
+// registry they are encoded once, on first use, and reused until
+// invalidate is called.
+type listings struct {
+	mu      sync.Mutex
+	current *encodedLists // nil until built
+}
+
+// encodedLists is one immutable set of encoded lists.
+type encodedLists struct {
+	err       error        // the error encoding the lists, if any
+	tools     []listedTool // in registry order
+	allTools  []byte       // the tools/list result for sessions that may use every tool
+	legacy    []byte       // the same for legacy hosts
+	resources []byte       // the resources/list result
+	prompts   []byte       // the prompts/list result
+}
+
+// listedTool is the encoded tools/list entry of one tool.
+type listedTool struct {
+	name   string
+	entry  []byte
+	legacy []byte // the entry for legacy hosts, if it differs
+}
+
+// invalidate discards the cached lists. It must be called whenever the
+// server's tools or the upstreams' resources or prompts change.
+func (l *listings) invalidate() {
+	l.mu.Lock()
+	l.current = nil
+	l.mu.Unlock()
+}
+
+// cachedListings returns the cached lists, building them first if needed.
+func (s *Server) cachedListings() (*encodedLists, error) {
+	s.lists.mu.Lock()
+	defer s.lists.mu.Unlock()
+	if s.lists.current == nil {
+		s.lists.current = encodeLists(s)
+	}
+	return s.lists.current, s.lists.current.err
+}
+
+// encodeLists encodes the lists of s.
+func encodeLists(s *Server) *encodedLists {
+	l := &encodedLists{tools: make([]listedTool, 0, len(s.tools))}
+	for _, t := range s.tools {
+		entry := map[string]interface{}{
+			"name":        t.Name(),
+			"description": t.Description(),
+			"inputSchema": s.inputSchema(t),
+		}
+		if v := toolVersion(t); v != (ToolVersion{}) {
+			entry["_meta"] = versionMeta(v)
+			if notice := deprecationNotice(v); notice != "" {
+				entry["description"] = strings.TrimSpace(notice + " " + t.Description())
+			}
+		}
+		listed := listedTool{name: t.Name()}
+		var err error
+		if at, ok := t.(AnnotatedTool); ok {
+			if listed.legacy, err = json.Marshal(entry); err != nil {
+				return &encodedLists{err: err}
+			}
+			entry["annotations"] = at.Annotations()
+		}
+		if listed.entry, err = json.Marshal(entry); err != nil {
+			return &encodedLists{err: err}
+		}
+		l.tools = append(l.tools, listed)
+	}
+	l.allTools = l.toolsResult(nil, false)
+	l.legacy = l.toolsResult(nil, true)
+
+	var err error
+	if l.resources, err = json.Marshal(map[string]interface{}{"resources": s.listResources()}); err != nil {
+		return &encodedLists{err: err}
+	}
+	if l.prompts, err = json.Marshal(map[string]interface{}{"prompts": s.listPrompts()}); err != nil {
+		return &encodedLists{err: err}
+	}
+	return l
+}
+
+// toolsResult assembles a tools/list result from the cached entries of the
+// tools allowed by allows, or of every tool if allows is nil, in the shape
+// for legacy hosts if legacy is true.
+func (l *encodedLists) toolsResult(allows func(name string) bool, legacy bool) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"tools":[`)
+	first := true
+	for _, t := range l.tools {
+		if allows != nil && !allows(t.name) {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		if legacy && t.legacy != nil {
+			buf.Write(t.legacy)
+		} else {
+			buf.Write(t.entry)
+		}
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+// sendListResult sends an encoded list result, or an internal error if the
+// list could not be encoded.
+func (s *Server) sendListResult(w io.Writer, id interface{}, result []byte, err error) {
+	if err != nil {
+		s.logger.Error("encoding list failed", "error", err)
+		sendError(w, id, -32603, "Internal error: cannot encode the list")
+		return
+	}
+	sendResponse(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  json.RawMessage(result),
+	})
+}
+
+// toolsListResult returns the tools/list result for sess.
+func (s *Server) toolsListResult(sess *session) (json.RawMessage, error) {
+	l, err := s.cachedListings()
+	if err != nil {
+		return nil, err
+	}
+	legacy := sess.lifecycle.legacy()
+	switch {
+	case sess.access != nil:
+		return l.toolsResult(sess.allowsTool, legacy), nil
+	case legacy:
+		return l.legacy, nil
+	}
+	return l.allTools, nil
+}
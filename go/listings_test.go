@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// schemaCountingTool counts how often its schema is requested.
+type schemaCountingTool struct {
+	echoTool
+	schemas atomic.Int32
+	schema  map[string]interface{}
+}
+
+func (c *schemaCountingTool) InputSchema() map[string]interface{} {
+	c.schemas.Add(1)
+	if c.schema != nil {
+		return c.schema
+	}
+	return c.echoTool.InputSchema()
+}
+
+// Test that the tools list is encoded once and rebuilt after invalidation
+func TestToolsListCached(t *testing.T) {
+	tool := &schemaCountingTool{}
+	s := NewServer(WithTools(tool, &countTextTool{}))
+	input := strings.Repeat(`{"jsonrpc":"2.0","method":"tools/list","id":1}`+"\n", 3)
+	lines := runServerInput(t, s, input)
+	if len(lines) != 3 || lines[0] != lines[2] || !strings.Contains(lines[0], `"name":"count_text"`) {
+		t.Fatalf("unexpected responses %v", lines)
+	}
+	if got := tool.schemas.Load(); got != 1 {
+		t.Errorf("expected the schema to be requested once, got %d", got)
+	}
+	s.lists.invalidate()
+	runServerInput(t, s, input)
+	if got := tool.schemas.Load(); got != 2 {
+		t.Errorf("expected the list to be rebuilt after invalidation, got %d schema requests", got)
+	}
+}
+
+// Test that a session restricted to some tools lists only those
+func TestToolsListFiltered(t *testing.T) {
+	s := NewServer(WithTools(&echoTool{}, &countTextTool{}))
+	l, err := s.cachedListings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(l.toolsResult(func(name string) bool { return name == "count_text" }, false))
+	if !strings.HasPrefix(got, `{"tools":[{`) || strings.Contains(got, `"name":"echo"`) || !strings.Contains(got, `"name":"count_text"`) {
+		t.Errorf("unexpected filtered list %s", got)
+	}
+	if got := string(l.toolsResult(func(string) bool { return false }, false)); got != `{"tools":[]}` {
+		t.Errorf("expected an empty list, got %s", got)
+	}
+}
+
+// Test that a schema that cannot be encoded yields an internal error
+func TestToolsListEncodingError(t *testing.T) {
+	tool := &schemaCountingTool{schema: map[string]interface{}{"maximum": math.Inf(1)}}
+	var logBuf bytes.Buffer
+	s := NewServer(WithTools(tool), WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))))
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/list","id":1}`+"\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"code":-32603`) {
+		t.Errorf("expected an internal error, got %v", lines)
+	}
+	if !strings.Contains(logBuf.String(), "encoding list failed") {
+		t.Errorf("expected the error to be logged, got %q", logBuf.String())
+	}
+}
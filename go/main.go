@@ -1,26 +1,53 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// The tool types are defined in package mcp so that plugins can implement
+// them; these aliases keep the names used throughout the server.
+type (
+	ToolContent            = mcp.Content
+	MCPTool                = mcp.Tool
+	ContextTool            = mcp.ContextTool
+	TimeoutTool            = mcp.TimeoutTool
+	DryRunTool             = mcp.DryRunTool
+	ConcurrencyLimitedTool = mcp.ConcurrencyLimitedTool
+	ToolAnnotations        = mcp.ToolAnnotations
+	AnnotatedTool          = mcp.AnnotatedTool
+	ToolVersion            = mcp.ToolVersion
+	VersionedTool          = mcp.VersionedTool
+	ToolResult             = mcp.Result
+	ResultTool             = mcp.ResultTool
+	ToolError              = mcp.ToolError
 )
 
-// ToolContent represents the content returned by an MCP tool.
-type ToolContent struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+// readOnlyAnnotations returns the annotations of a tool that neither
+// modifies anything nor reaches outside the server.
+func readOnlyAnnotations(title string) ToolAnnotations {
+	closed := false
+	return ToolAnnotations{Title: title, ReadOnlyHint: true, IdempotentHint: true, OpenWorldHint: &closed}
 }
 
-// MCPTool defines the interface that a tool must implement.
-type MCPTool interface {
-	Name() string
-	Description() string
-	InputSchema() map[string]interface{}
-	Execute(args map[string]interface{}) ([]ToolContent, error)
+// isReadOnly reports whether t is annotated as read-only.
+func isReadOnly(t MCPTool) bool {
+	at, ok := t.(AnnotatedTool)
+	return ok && at.Annotations().ReadOnlyHint
 }
 
 // echoTool is equivalent to the "echo" tool in the TypeScript sample.
@@ -50,6 +77,11 @@ func (e *echoTool) InputSchema() map[string]interface{} {
 	}
 }
 
+// Annotations marks the echo tool as read-only.
+func (e *echoTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Echo")
+}
+
 // Execute performs the actual echo operation based on the given arguments.
 func (e *echoTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
 	msg, ok := args["message"].(string)
@@ -66,20 +98,47 @@ func (e *echoTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
 // tools is a list of available tools.
 var tools = []MCPTool{
 	&echoTool{},
+	&countTextTool{},
+	&qrCodeTool{},
+	&imageTransformTool{},
+	&parseICSTool{},
+	&convertUnitsTool{},
+	&detectLanguageTool{},
+	&semverTool{},
+	&formatCodeTool{},
+	&htmlToTextTool{},
+	&chunkTextTool{},
+	&readSpreadsheetTool{},
 }
 
-// JSONRPCRequest represents a generic JSON-RPC request.
+// JSONRPCRequest represents a generic JSON-RPC request. ID keeps the raw
+// JSON of the id so that responses echo it byte for byte; decoding it into
+// interface{} would round large integers through float64.
 type JSONRPCRequest struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params,omitempty"`
-	ID      interface{}     `json:"id,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// validID reports whether id is absent or a JSON string, number, or null,
+// the only id types JSON-RPC allows.
+func validID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+	switch c := id[0]; {
+	case c == '"', c == '-', c >= '0' && c <= '9':
+		return true
+	}
+	return string(id) == "null"
 }
 
 // JSONRPCError represents the "error" field of a JSON-RPC response.
 type JSONRPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 // JSONRPCErrorResponse represents a JSON-RPC error response object.
@@ -89,204 +148,241 @@ type JSONRPCErrorResponse struct {
 	Error   JSONRPCError `json:"error"`
 }
 
-// sendResponse writes a JSON-RPC result response to the given writer.
+// sendResponse writes a JSON-RPC message to the given writer. Sessions
+// write through a Responder; any other writer gets a *messageWriter for the
+// message.
 func sendResponse(w io.Writer, response interface{}) {
-	bytes, err := json.Marshal(response)
-	if err != nil {
+	if err := responder(w).Send(response); errors.Is(err, errUnencodable) {
 		fmt.Fprintf(w, "Failed to marshal response: %v\n", err)
-		return
 	}
-	fmt.Fprintf(w, "%s\n", string(bytes))
 }
 
 // sendError writes a JSON-RPC error response to the given writer.
 func sendError(w io.Writer, id interface{}, code int, message string) {
+	sendErrorData(w, id, code, message, nil)
+}
+
+// sendErrorData writes a JSON-RPC error response carrying additional
+// structured data to the given writer.
+func sendErrorData(w io.Writer, id interface{}, code int, message string, data interface{}) {
+	metrics.errors.inc(strconv.Itoa(code))
+	if mw, ok := w.(*messageWriter); ok && mw.counts != nil {
+		mw.counts.errors.Add(1)
+	}
 	errResp := JSONRPCErrorResponse{
 		JSONRPC: "2.0",
 		ID:      id,
 		Error: JSONRPCError{
 			Code:    code,
 			Message: message,
+			Data:    data,
 		},
 	}
 	sendResponse(w, errResp)
 }
 
-// toolsCallParams holds the parameters expected by "tools/call".
-type toolsCallParams struct {
-	Name      string                 `json:"name"`
-	Arguments map[string]interface{} `json:"arguments"`
-}
-
-// runMCPServer reads JSON-RPC requests from r and writes responses to w.
-func runMCPServer(r io.Reader, w io.Writer) error {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) == "" {
-			continue
-		}
-
-		var req JSONRPCRequest
-		if err := json.Unmarshal([]byte(line), &req); err != nil {
-			// Parse error: -32700
-			sendError(w, nil, -32700, "Parse error")
-			continue
-		}
-
-		if req.JSONRPC != "2.0" || req.Method == "" {
-			sendError(w, req.ID, -32600, "Invalid Request")
-			continue
+// main parses the command line and runs the MCP server, by default over
+// standard input/output.
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "call":
+			os.Exit(runCall(os.Args[2:], os.Stdout))
+		case "conformance":
+			os.Exit(runConformance(os.Args[2:], os.Stdout))
+		case "inspect":
+			os.Exit(runInspect(os.Args[2:], os.Stdout))
 		}
+	}
+	cfg, err := loadConfig(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+	if cfg.Version {
+		fmt.Println(versionString())
+		return
+	}
+	switch {
+	case cfg.Service:
+		os.Exit(runService(os.Args[1:], cfg))
+	case cfg.Detach:
+		os.Exit(detach(cfg))
+	}
+	os.Exit(run(cfg))
+}
 
-		method := req.Method
-		id := req.ID
-		isNotification := (id == nil)
-
-		switch method {
-		case "initialize":
-			// Example: parse protocolVersion and respond with initialization info
-			var params map[string]interface{}
-			_ = json.Unmarshal(req.Params, &params)
-			clientProtocol, _ := params["protocolVersion"].(string)
-			protocolVersion := clientProtocol
-			if protocolVersion == "" {
-				protocolVersion = "2025-03-08"
-			}
-
-			initResponse := map[string]interface{}{
-				"jsonrpc": "2.0",
-				"id":      id,
-				"result": map[string]interface{}{
-					"protocolVersion": protocolVersion,
-					"serverInfo": map[string]string{
-						"name":    "simple-mcp-server",
-						"version": "0.1.0",
-					},
-					"capabilities": map[string]interface{}{
-						"tools": map[string]interface{}{},
-					},
-				},
-			}
-			sendResponse(w, initResponse)
-
-		case "initialized", "notifications/initialized":
-			// No response
-			continue
-
-		case "cancelled":
-			// No specific handling
-			continue
-
-		case "tools/list":
-			// Return the list of tools
-			toolList := make([]map[string]interface{}, 0, len(tools))
-			for _, t := range tools {
-				toolList = append(toolList, map[string]interface{}{
-					"name":        t.Name(),
-					"description": t.Description(),
-					"inputSchema": t.InputSchema(),
-				})
-			}
-			listResp := map[string]interface{}{
-				"jsonrpc": "2.0",
-				"id":      id,
-				"result": map[string]interface{}{
-					"tools": toolList,
-				},
-			}
-			sendResponse(w, listResp)
-
-		case "resources/list":
-			resp := map[string]interface{}{
-				"jsonrpc": "2.0",
-				"id":      id,
-				"result": map[string]interface{}{
-					"resources": []interface{}{},
-				},
-			}
-			sendResponse(w, resp)
+// newServerFromConfig connects the upstreams and builds the server
+// described by cfg, logging to stderr. Errors are reported on stderr and
+// returned as a process exit code; on success the code is 0 and the
+// returned function must be called to disconnect the upstreams once the
+// server is done.
+func newServerFromConfig(cfg *config, stderr io.Writer) (*Server, *redactor, func(), int) {
+	level, _ := parseLogLevel(cfg.LogLevel)
+	redactor, err := newRedactor(cfg.RedactKeys, cfg.RedactPatterns)
+	if err != nil {
+		fmt.Fprintf(stderr, "Invalid configuration: %v\n", err)
+		return nil, nil, nil, 2
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level, ReplaceAttr: redactor.replaceAttr}))
+	ups, err := connectUpstreams(cfg.Upstreams, time.Duration(cfg.RequestTimeout))
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to connect to upstream: %v\n", err)
+		return nil, nil, nil, 1
+	}
+	opts, err := cfg.serverOptions(logger, ups)
+	if err != nil {
+		closeUpstreams(ups)
+		fmt.Fprintf(stderr, "Invalid configuration: %v\n", err)
+		return nil, nil, nil, 2
+	}
+	return NewServer(append(opts, WithRedactor(redactor))...), redactor, func() { closeUpstreams(ups) }, 0
+}
 
-		case "prompts/list":
-			resp := map[string]interface{}{
-				"jsonrpc": "2.0",
-				"id":      id,
-				"result": map[string]interface{}{
-					"prompts": []interface{}{},
-				},
-			}
-			sendResponse(w, resp)
+// run starts the server described by cfg and returns the process exit code.
+func run(cfg *config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.Daemon {
+		restart := make(chan os.Signal, 1)
+		signal.Notify(restart, syscall.SIGHUP)
+		defer signal.Stop(restart)
+		return runDaemon(ctx, os.Args[1:], cfg, restart)
+	}
+	return serveConfig(ctx, cfg, os.Stderr)
+}
 
-		case "tools/call":
-			var params toolsCallParams
-			if err := json.Unmarshal(req.Params, &params); err != nil {
-				sendError(w, id, -32602, "Invalid parameters")
-				continue
-			}
-			if params.Name == "" || params.Arguments == nil {
-				sendError(w, id, -32602, "Invalid parameters: missing tool name or arguments")
-				continue
-			}
+// serveConfig serves the server described by cfg until ctx is done or the
+// server stops, reporting on stderr, and returns the process exit code.
+func serveConfig(ctx context.Context, cfg *config, stderr io.Writer) int {
+	server, redactor, done, code := newServerFromConfig(cfg, stderr)
+	if code != 0 {
+		return code
+	}
+	defer done()
 
-			// Search for the tool
-			var foundTool MCPTool
-			for _, t := range tools {
-				if t.Name() == params.Name {
-					foundTool = t
-					break
-				}
-			}
-			if foundTool == nil {
-				sendError(w, id, -32601, fmt.Sprintf("Method not found: tool '%s' is not available", params.Name))
-				continue
+	if cfg.MetricsAddr != "" {
+		ln, err := net.Listen("tcp", cfg.MetricsAddr)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to listen for metrics: %v\n", err)
+			return 1
+		}
+		defer ln.Close()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics)
+		go func() {
+			if err := http.Serve(ln, mux); err != nil && !errors.Is(err, net.ErrClosed) {
+				fmt.Fprintf(stderr, "Metrics server stopped: %v\n", err)
 			}
+		}()
+	}
 
-			// Validate required fields
-			schema := foundTool.InputSchema()
-			required, _ := schema["required"].([]string)
-			missingParam := false
-			for _, field := range required {
-				if _, ok := params.Arguments[field]; !ok {
-					sendError(w, id, -32602, fmt.Sprintf("Missing required parameter: '%s'", field))
-					missingParam = true
-					break
-				}
-			}
-			if missingParam {
-				// Stop processing this request
-				continue
-			}
+	if cfg.Replay != "" {
+		return runReplay(ctx, server, cfg.Replay, os.Stdout)
+	}
+	if cfg.REPL {
+		if err := runREPL(ctx, server, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(stderr, "REPL stopped: %v\n", err)
+			return 1
+		}
+		return 0
+	}
 
-			// Execute the tool
-			resultContent, err := foundTool.Execute(params.Arguments)
+	var serve []func(context.Context) error
+	if cfg.serves("http") {
+		opts := httpOptions{addr: cfg.Addr, allowedHosts: cfg.AllowedHosts, allowedOrigins: cfg.AllowedOrigins,
+			idleTimeout: time.Duration(cfg.SessionIdleTimeout), connIdleTimeout: time.Duration(cfg.ConnIdleTimeout),
+			maxSessions: cfg.MaxSessions, restGateway: cfg.RESTGateway}
+		if cfg.Pprof {
+			opts.pprofToken = cfg.PprofToken
+		}
+		if cfg.OAuth != nil {
+			opts.auth = newOAuthVerifier(*cfg.OAuth)
+		}
+		serve = append(serve, func(ctx context.Context) error { return serveHTTP(ctx, server, opts) })
+	}
+	if cfg.serves("stdio") {
+		var r io.Reader = os.Stdin
+		var w io.Writer = os.Stdout
+		if cfg.DebugLog != "" {
+			f, err := os.OpenFile(cfg.DebugLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
 			if err != nil {
-				sendError(w, id, -32603, "Internal error during tool execution")
-				continue
-			}
-
-			// Return success response
-			callResp := map[string]interface{}{
-				"jsonrpc": "2.0",
-				"id":      id,
-				"result": map[string]interface{}{
-					"content": resultContent,
-				},
+				fmt.Fprintf(stderr, "Failed to open debug log: %v\n", err)
+				return 1
 			}
-			sendResponse(w, callResp)
-
-		default:
-			if !isNotification {
-				sendError(w, id, -32601, fmt.Sprintf("Method not found: %s", method))
+			defer f.Close()
+			traffic := newTrafficLog(f, redactor)
+			r = traffic.reader(r)
+			w = traffic.writer(w)
+		}
+		if cfg.Record != "" {
+			f, err := os.OpenFile(cfg.Record, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+			if err != nil {
+				fmt.Fprintf(stderr, "Failed to create recording: %v\n", err)
+				return 1
 			}
+			defer f.Close()
+			recording := newTrafficLog(f, nil)
+			r = recording.reader(r)
+			w = recording.writer(w)
 		}
+		serve = append(serve, func(ctx context.Context) error { return server.Serve(ctx, r, w) })
 	}
 
-	return scanner.Err()
+	if err := serveAll(ctx, serve...); err != nil {
+		fmt.Fprintf(stderr, "Server stopped: %v\n", err)
+		return exitCode(err)
+	}
+	return 0
 }
 
-// main uses standard input/output for the MCP server.
-func main() {
-	if err := runMCPServer(os.Stdin, os.Stdout); err != nil {
-		os.Exit(1)
+// Exit codes of a server that stopped with an error, which tell
+// supervisors why without them parsing its log. 2 is for invalid
+// configurations.
+const (
+	exitFailed             = 1 // any other failure, such as a listener failing
+	exitReadFailed         = 3 // reading from the client failed
+	exitWriteFailed        = 4 // writing to the client failed, usually because it is gone
+	exitDrainTimeout       = 5 // requests in flight did not finish in time
+	exitClientUnresponsive = 6 // the client stopped answering pings
+)
+
+// exitCode returns the exit code for the error a server stopped with.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errReadFailed):
+		return exitReadFailed
+	case errors.Is(err, errWriteFailed):
+		return exitWriteFailed
+	case errors.Is(err, errClientUnresponsive):
+		return exitClientUnresponsive
+	case errors.Is(err, errDrainTimeout):
+		return exitDrainTimeout
+	}
+	return exitFailed
+}
+
+// serveAll runs the serve functions of several transports of one server
+// at once. When one returns, because its client disconnected or it failed,
+// the others are stopped, so that the stdio client closing the server's
+// input ends the process as it does with stdio alone. The errors of all of
+// them are returned.
+func serveAll(ctx context.Context, serve ...func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(serve))
+	for _, f := range serve {
+		go func() {
+			errs <- f(ctx)
+			cancel()
+		}()
+	}
+	var all []error
+	for range serve {
+		all = append(all, <-errs)
 	}
+	return errors.Join(all...)
 }
@@ -2,17 +2,24 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"regexp"
 	"strings"
 	"testing"
 )
 
-// runTestInput is a helper function that feeds the input string to runMCPServer
-// and returns all lines of output.
+// runTestInput is a helper function that feeds the input string to runMCPServer,
+// after testHandshake unless it initializes the session itself, and returns all
+// lines of output.
 func runTestInput(t *testing.T, input string) []string {
 	t.Helper()
 	var out bytes.Buffer
-	err := runMCPServer(strings.NewReader(input), &out)
+	err := runMCPServer(strings.NewReader(withHandshake(input)), handshakeFilter{&out})
 	if err != nil {
 		t.Fatalf("runMCPServer error: %v", err)
 	}
@@ -53,6 +60,18 @@ func TestInitialize(t *testing.T) {
 	if result["protocolVersion"] != "2023-10-10" {
 		t.Errorf("expected protocolVersion=2023-10-10, got %v", result["protocolVersion"])
 	}
+	if _, ok := result["instructions"]; ok {
+		t.Errorf("expected no instructions by default, got %v", result["instructions"])
+	}
+}
+
+// Test that configured instructions are returned by initialize
+func TestInitializeInstructions(t *testing.T) {
+	s := NewServer(WithInstructions("Call echo to repeat a message."))
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-03-26"},"id":1}`+"\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"instructions":"Call echo to repeat a message."`) {
+		t.Errorf("expected the instructions in the initialize result, got %q", lines)
+	}
 }
 
 // 2) Test the "tools/list" method
@@ -84,8 +103,8 @@ func TestToolsList(t *testing.T) {
 	if !ok {
 		t.Fatalf("expected 'tools' to be an array, got %T", result["tools"])
 	}
-	if len(toolsVal) != 1 {
-		t.Errorf("expected 1 tool (echo), got %d", len(toolsVal))
+	if len(toolsVal) != len(tools) {
+		t.Errorf("expected %d tools, got %d", len(tools), len(toolsVal))
 	}
 	toolObj, ok := toolsVal[0].(map[string]interface{})
 	if !ok {
@@ -186,3 +205,116 @@ func TestToolsCallUnknownTool(t *testing.T) {
 		t.Errorf("expected code=-32601, got %d", errResp.Error.Code)
 	}
 }
+
+// 6) Test that messages longer than bufio.Scanner's 64KB limit are handled
+func TestLargeMessage(t *testing.T) {
+	msg := strings.Repeat("a", 100*1024)
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"` + msg + `"}},"id":6}`
+	lines := runTestInput(t, input+"\n"+`{"jsonrpc":"2.0","method":"tools/list","id":7}`)
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines output, got %d lines", len(lines))
+	}
+	if !strings.Contains(strings.Join(lines, "\n"), "Echo: "+msg) {
+		t.Errorf("expected the full message to be echoed")
+	}
+}
+
+// 7) Test that request IDs are echoed byte for byte
+func TestRequestIDFidelity(t *testing.T) {
+	ids := []string{`9007199254740993`, `"req-1"`, `1.50`, `-0`}
+	for _, id := range ids {
+		lines := runTestInput(t, `{"jsonrpc":"2.0","method":"tools/list","id":`+id+`}`)
+		if len(lines) != 1 {
+			t.Fatalf("expected 1 line output, got %d lines", len(lines))
+		}
+		if !strings.HasPrefix(lines[0], `{"id":`+id+`,`) {
+			t.Errorf("expected id %s to be echoed verbatim, got %s", id, lines[0][:40])
+		}
+	}
+}
+
+// 8) Test that an id that is neither string, number, nor null is rejected
+func TestInvalidRequestID(t *testing.T) {
+	lines := runTestInput(t, `{"jsonrpc":"2.0","method":"tools/list","id":{"a":1}}`)
+
+	var errResp JSONRPCErrorResponse
+	if err := json.Unmarshal([]byte(lines[0]), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if errResp.ID != nil || errResp.Error.Code != -32600 {
+		t.Errorf("expected Invalid Request with a null id, got id=%v code=%d", errResp.ID, errResp.Error.Code)
+	}
+}
+
+// 9) Test that one server serves stdio and HTTP at once, keeping their
+// sessions apart, and stops HTTP when the stdio client disconnects
+func TestServeAll(t *testing.T) {
+	s := NewServer(WithTools(sessionTool{}))
+	tr := newHTTPTransport(s, nil)
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+	defer tr.endAll()
+	pr, pw := io.Pipe()
+	out := &syncBuffer{}
+	done := make(chan error, 1)
+	httpStopped := false
+	go func() {
+		done <- serveAll(context.Background(),
+			func(ctx context.Context) error { return s.Serve(ctx, pr, out) },
+			func(ctx context.Context) error {
+				<-ctx.Done()
+				httpStopped = true
+				return nil
+			})
+	}()
+
+	io.WriteString(pw, testHandshake)
+	call := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"whoami","arguments":{}},"id":1}`
+	io.WriteString(pw, call+"\n"+strings.Replace(call, `"id":1`, `"id":2`, 1)+"\n")
+	waitForOutput(t, out, regexp.MustCompile(`calls=2`), 1)
+
+	sessionID := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{"capabilities":{"roots":{}}},"id":1}`).Header.Get(sessionIDHeader)
+	postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	body, _ := io.ReadAll(postMCP(t, ts.URL, sessionID, call).Body)
+	if !bytes.Contains(body, []byte("calls=1 roots="+ErrNoRoots.Error())) {
+		t.Errorf("expected a session of its own over HTTP, got %s", body)
+	}
+
+	pw.Close()
+	if err := <-done; err != nil || !httpStopped {
+		t.Errorf("expected both transports to stop cleanly, got %v, stopped %v", err, httpStopped)
+	}
+}
+
+// 10) Test that a transport failing stops the others and is reported
+func TestServeAllError(t *testing.T) {
+	err := serveAll(context.Background(),
+		func(ctx context.Context) error { return errors.New("address in use") },
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+	if err == nil || err.Error() != "address in use" {
+		t.Errorf("expected the failure, got %v", err)
+	}
+}
+
+// Test the exit codes of the errors a server stops with
+func TestExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{fmt.Errorf("%w: %w", errReadFailed, io.ErrUnexpectedEOF), exitReadFailed},
+		{fmt.Errorf("%w: broken pipe", errWriteFailed), exitWriteFailed},
+		{errClientUnresponsive, exitClientUnresponsive},
+		{errors.Join(nil, errDrainTimeout), exitDrainTimeout},
+		{errExitWithoutShutdown, exitFailed},
+		{errors.New("address in use"), exitFailed},
+	} {
+		if code := exitCode(tc.err); code != tc.code {
+			t.Errorf("exitCode(%v) = %d, expected %d", tc.err, code, tc.code)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// ResourceContents are the contents of a resource embedded in a tool
+// result: Text for text resources, or Blob, base64-encoded, for binary
+// ones.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// NewTextContent returns a text content block.
+func NewTextContent(text string) Content {
+	return Content{Type: "text", Text: text}
+}
+
+// NewImageContent returns an image content block holding data, whose
+// MIME type is mimeType, such as "image/png".
+func NewImageContent(data []byte, mimeType string) Content {
+	return Content{Type: "image", Data: base64.StdEncoding.EncodeToString(data), MimeType: mimeType}
+}
+
+// NewResourceContent returns a content block embedding the text resource
+// at uri.
+func NewResourceContent(uri, mimeType, text string) Content {
+	return Content{Type: "resource", Resource: &ResourceContents{URI: uri, MimeType: mimeType, Text: text}}
+}
+
+// NewBlobResourceContent returns a content block embedding the binary
+// resource at uri.
+func NewBlobResourceContent(uri, mimeType string, data []byte) Content {
+	return Content{Type: "resource", Resource: &ResourceContents{URI: uri, MimeType: mimeType, Blob: base64.StdEncoding.EncodeToString(data)}}
+}
+
+// WithAudience returns c marked as meant for roles, "user" for blocks to
+// show to the user, "assistant" for those to give the model.
+func (c Content) WithAudience(roles ...string) Content {
+	a := c.annotations()
+	a.Audience = roles
+	c.Annotations = a
+	return c
+}
+
+// WithPriority returns c with the importance p, from 0 for blocks that
+// may be left out to 1 for those that are required.
+func (c Content) WithPriority(p float64) Content {
+	a := c.annotations()
+	a.Priority = &p
+	c.Annotations = a
+	return c
+}
+
+// WithLastModified returns c marked as last modified at t.
+func (c Content) WithLastModified(t time.Time) Content {
+	a := c.annotations()
+	a.LastModified = t.UTC().Format(time.RFC3339)
+	c.Annotations = a
+	return c
+}
+
+// annotations returns a copy of the annotations of c, so that blocks
+// built from the same one do not share them.
+func (c Content) annotations() *Annotations {
+	if c.Annotations == nil {
+		return &Annotations{}
+	}
+	a := *c.Annotations
+	return &a
+}
+
+// MarshalJSON encodes c with the fields its type has, and only those: a
+// text block always has its text, even if empty, an image block its data
+// and MIME type, and a resource block its resource. Annotations are kept
+// for every type, and blocks of other types keep every field that is set.
+func (c Content) MarshalJSON() ([]byte, error) {
+	switch c.Type {
+	case "text":
+		return json.Marshal(struct {
+			Type        string       `json:"type"`
+			Text        string       `json:"text"`
+			Annotations *Annotations `json:"annotations,omitempty"`
+		}{c.Type, c.Text, c.Annotations})
+	case "image", "audio":
+		return json.Marshal(struct {
+			Type        string       `json:"type"`
+			Data        string       `json:"data"`
+			MimeType    string       `json:"mimeType"`
+			Annotations *Annotations `json:"annotations,omitempty"`
+		}{c.Type, c.Data, c.MimeType, c.Annotations})
+	case "resource":
+		resource := c.Resource
+		if resource == nil {
+			resource = &ResourceContents{}
+		}
+		return json.Marshal(struct {
+			Type        string            `json:"type"`
+			Resource    *ResourceContents `json:"resource"`
+			Annotations *Annotations      `json:"annotations,omitempty"`
+		}{c.Type, resource, c.Annotations})
+	}
+	type plain Content // without this method
+	return json.Marshal(plain(c))
+}
+
+// Result is the result of a tool call: its content blocks and, optionally,
+// structured content, a JSON object for clients to process.
+type Result struct {
+	Content    []Content
+	Structured interface{}
+}
+
+// NewResult returns a result holding content.
+func NewResult(content ...Content) *Result {
+	return &Result{Content: content}
+}
+
+// WithStructured sets the structured content of r to v, which must encode
+// as a JSON object, and returns r. A result without content blocks gets v
+// encoded as text as well, for clients that do not read structured content.
+func (r *Result) WithStructured(v interface{}) *Result {
+	r.Structured = v
+	if len(r.Content) == 0 {
+		if encoded, err := json.Marshal(v); err == nil {
+			r.Content = []Content{NewTextContent(string(encoded))}
+		}
+	}
+	return r
+}
+
+// ResultTool is implemented by tools whose results hold more than content
+// blocks. The server calls ExecuteResult instead of ExecuteContext or
+// Execute for such tools.
+type ResultTool interface {
+	ExecuteResult(ctx context.Context, args map[string]interface{}) (*Result, error)
+}
+
+// ToolError is an error for a call that failed in a way the model should
+// see, such as a file that does not exist. The server answers it with a
+// result flagged as an error holding Content, rather than with a protocol
+// error.
+type ToolError struct {
+	Content []Content
+}
+
+// Error returns the text of the error's content.
+func (e *ToolError) Error() string {
+	var texts []string
+	for _, c := range e.Content {
+		if c.Text != "" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,64 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Test that each kind of content block encodes with its own fields only
+func TestContentJSON(t *testing.T) {
+	for _, tc := range []struct {
+		content Content
+		want    string
+	}{
+		{NewTextContent(""), `{"type":"text","text":""}`},
+		{NewTextContent("hi"), `{"type":"text","text":"hi"}`},
+		{NewImageContent([]byte("png"), "image/png"), `{"type":"image","data":"cG5n","mimeType":"image/png"}`},
+		{Content{Type: "image", Text: "stray", Data: "cG5n", MimeType: "image/png"}, `{"type":"image","data":"cG5n","mimeType":"image/png"}`},
+		{NewResourceContent("file:///a.txt", "text/plain", "a"), `{"type":"resource","resource":{"uri":"file:///a.txt","mimeType":"text/plain","text":"a"}}`},
+		{NewBlobResourceContent("file:///a.bin", "", []byte("png")), `{"type":"resource","resource":{"uri":"file:///a.bin","blob":"cG5n"}}`},
+		{Content{Type: "resource"}, `{"type":"resource","resource":{"uri":""}}`},
+		{NewTextContent("hi").WithAudience("user").WithPriority(0), `{"type":"text","text":"hi","annotations":{"audience":["user"],"priority":0}}`},
+		{NewResourceContent("file:///a.txt", "", "a").WithLastModified(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)),
+			`{"type":"resource","resource":{"uri":"file:///a.txt","text":"a"},"annotations":{"lastModified":"2026-01-02T03:04:05Z"}}`},
+	} {
+		got, err := json.Marshal(tc.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("got %s, expected %s", got, tc.want)
+		}
+		var back Content
+		if err := json.Unmarshal(got, &back); err != nil || back.Type != tc.content.Type {
+			t.Errorf("%s does not decode back: %v", got, err)
+		}
+	}
+}
+
+// Test that structured content is repeated as text only for results
+// without content blocks
+func TestResultWithStructured(t *testing.T) {
+	structured := map[string]int{"count": 2}
+	r := NewResult().WithStructured(structured)
+	if len(r.Content) != 1 || r.Content[0].Text != `{"count":2}` {
+		t.Errorf("expected the structured content as text, got %+v", r.Content)
+	}
+	r = NewResult(NewTextContent("two")).WithStructured(structured)
+	if len(r.Content) != 1 || r.Content[0].Text != "two" || r.Structured == nil {
+		t.Errorf("expected the content to be kept, got %+v", r)
+	}
+}
+
+// Test that blocks built from the same one do not share annotations
+func TestContentAnnotations(t *testing.T) {
+	base := NewTextContent("hi").WithAudience("assistant")
+	user := base.WithAudience("user").WithPriority(1)
+	if base.Annotations.Audience[0] != "assistant" || base.Annotations.Priority != nil {
+		t.Errorf("the base block changed: %+v", base.Annotations)
+	}
+	if user.Annotations.Audience[0] != "user" || *user.Annotations.Priority != 1 {
+		t.Errorf("unexpected annotations %+v", user.Annotations)
+	}
+}
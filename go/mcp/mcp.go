@@ -0,0 +1,94 @@
+// Package mcp defines the types shared between the MCP server and tools
+// built outside of it, such as Go plugins.
+package mcp
+
+import (
+	"context"
+	"time"
+)
+
+// Content represents the content returned by an MCP tool. NewTextContent,
+// NewImageContent, and NewResourceContent build the blocks of each type.
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Data     string            `json:"data,omitempty"`     // base64-encoded data for "image" content
+	MimeType string            `json:"mimeType,omitempty"` // MIME type of Data, e.g. "image/png"
+	Resource *ResourceContents `json:"resource,omitempty"` // for "resource" content
+
+	Annotations *Annotations `json:"annotations,omitempty"`
+}
+
+// Annotations tell clients how to use a content block or resource. Like
+// tool annotations, they are hints.
+type Annotations struct {
+	Audience     []string `json:"audience,omitempty"`     // "user", "assistant", or both
+	Priority     *float64 `json:"priority,omitempty"`     // from 0, optional, to 1, required
+	LastModified string   `json:"lastModified,omitempty"` // RFC 3339
+}
+
+// Tool defines the interface that a tool must implement.
+type Tool interface {
+	Name() string
+	Description() string
+	InputSchema() map[string]interface{}
+	Execute(args map[string]interface{}) ([]Content, error)
+}
+
+// ContextTool is implemented by tools that can stop early when the request
+// is cancelled or times out. The server calls ExecuteContext instead of
+// Execute for such tools.
+type ContextTool interface {
+	ExecuteContext(ctx context.Context, args map[string]interface{}) ([]Content, error)
+}
+
+// DryRunTool is implemented by tools with side effects that can tell what
+// a call would do without doing it. The server adds a boolean dryRun
+// argument to their input schemas, and answers the calls that set it with
+// the description DryRun returns instead of executing them.
+type DryRunTool interface {
+	DryRun(args map[string]interface{}) (string, error)
+}
+
+// TimeoutTool is implemented by tools that need a time limit other than the
+// server's request timeout. A zero Timeout falls back to the server's.
+type TimeoutTool interface {
+	Timeout() time.Duration
+}
+
+// ConcurrencyLimitedTool is implemented by tools that must not run more than
+// MaxConcurrency invocations at once. Further calls wait for a free slot.
+type ConcurrencyLimitedTool interface {
+	MaxConcurrency() int
+}
+
+// ToolAnnotations describe the behavior of a tool to clients. They are
+// hints: clients must not rely on them for security.
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint,omitempty"`    // the tool does not modify its environment
+	DestructiveHint *bool  `json:"destructiveHint,omitempty"` // updates may be destructive; defaults to true
+	IdempotentHint  bool   `json:"idempotentHint,omitempty"`  // repeated calls have no additional effect
+	OpenWorldHint   *bool  `json:"openWorldHint,omitempty"`   // the tool reaches external entities; defaults to true
+}
+
+// ToolVersion describes the contract of a tool: its version and, once the
+// tool is on its way out, why and which tool replaces it.
+type ToolVersion struct {
+	Version    string `json:"version,omitempty"`
+	Deprecated string `json:"deprecated,omitempty"` // why the tool is deprecated, empty if it is not
+	ReplacedBy string `json:"replacedBy,omitempty"` // the tool to use instead
+}
+
+// VersionedTool is implemented by tools that declare their version or
+// deprecation. Deprecated tools remain callable, but their descriptions and
+// results warn of the deprecation.
+type VersionedTool interface {
+	Version() ToolVersion
+}
+
+// AnnotatedTool is implemented by tools that describe their behavior.
+// Tools without annotations are assumed to modify their environment.
+type AnnotatedTool interface {
+	Annotations() ToolAnnotations
+}
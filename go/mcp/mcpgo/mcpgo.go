@@ -0,0 +1,222 @@
+// Package mcpgo adapts tools between package mcp and the
+// github.com/mark3labs/mcp-go SDK, so that tools written for either can be
+// served by the other. A plugin can serve SDK tools with
+//
+//	func Tools() []mcp.Tool {
+//		return mcpgo.Tools(server.ServerTool{Tool: weather, Handler: handleWeather})
+//	}
+//
+// and an SDK server can serve this server's tools with
+// s.AddTools(mcpgo.ServerTools(tools...)...).
+//
+// The server itself does not depend on the SDK, so the package is a module
+// of its own, requiring the SDK and the server's module from this tree.
+// It is built and tested from its directory:
+//
+//	cd mcp/mcpgo && go test ./...
+package mcpgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	sdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Tools returns the SDK tools as tools of package mcp.
+func Tools(tools ...server.ServerTool) []mcp.Tool {
+	adapted := make([]mcp.Tool, len(tools))
+	for i, t := range tools {
+		adapted[i] = FromServerTool(t)
+	}
+	return adapted
+}
+
+// FromServerTool returns t as a tool of package mcp. Results flagged as
+// errors become ToolErrors, and errors from the handler are returned as
+// they are.
+func FromServerTool(t server.ServerTool) mcp.Tool {
+	return &sdkTool{tool: t.Tool, handler: t.Handler, schema: inputSchema(t.Tool)}
+}
+
+// sdkTool is an SDK tool as a tool of package mcp.
+type sdkTool struct {
+	tool    sdk.Tool
+	handler server.ToolHandlerFunc
+	schema  map[string]interface{}
+}
+
+func (t *sdkTool) Name() string                        { return t.tool.Name }
+func (t *sdkTool) Description() string                 { return t.tool.Description }
+func (t *sdkTool) InputSchema() map[string]interface{} { return t.schema }
+
+func (t *sdkTool) Annotations() mcp.ToolAnnotations {
+	a := t.tool.Annotations
+	return mcp.ToolAnnotations{
+		Title:           a.Title,
+		ReadOnlyHint:    a.ReadOnlyHint != nil && *a.ReadOnlyHint,
+		DestructiveHint: a.DestructiveHint,
+		IdempotentHint:  a.IdempotentHint != nil && *a.IdempotentHint,
+		OpenWorldHint:   a.OpenWorldHint,
+	}
+}
+
+func (t *sdkTool) Execute(args map[string]interface{}) ([]mcp.Content, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+func (t *sdkTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]mcp.Content, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+func (t *sdkTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*mcp.Result, error) {
+	var req sdk.CallToolRequest
+	req.Method = "tools/call"
+	req.Params.Name = t.tool.Name
+	req.Params.Arguments = args
+	res, err := t.handler(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return &mcp.Result{}, nil
+	}
+	content, err := fromSDKContent(res.Content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.tool.Name, err)
+	}
+	if res.IsError {
+		return nil, &mcp.ToolError{Content: content}
+	}
+	return &mcp.Result{Content: content, Structured: res.StructuredContent}, nil
+}
+
+// inputSchema returns the input schema of t, whichever way it was given.
+func inputSchema(t sdk.Tool) map[string]interface{} {
+	var encoded struct {
+		InputSchema map[string]interface{} `json:"inputSchema"`
+	}
+	if data, err := json.Marshal(t); err == nil {
+		_ = json.Unmarshal(data, &encoded)
+	}
+	if encoded.InputSchema == nil {
+		return map[string]interface{}{"type": "object"}
+	}
+	return encoded.InputSchema
+}
+
+// fromSDKContent converts SDK content blocks through their JSON encoding,
+// which the two packages share.
+func fromSDKContent(blocks []sdk.Content) ([]mcp.Content, error) {
+	content := make([]mcp.Content, 0, len(blocks))
+	for _, b := range blocks {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		var c mcp.Content
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, err
+		}
+		content = append(content, c)
+	}
+	return content, nil
+}
+
+// ServerTools returns tools of package mcp as SDK tools.
+func ServerTools(tools ...mcp.Tool) []server.ServerTool {
+	adapted := make([]server.ServerTool, len(tools))
+	for i, t := range tools {
+		adapted[i] = ToServerTool(t)
+	}
+	return adapted
+}
+
+// ToServerTool returns t as an SDK tool. Errors from t become results
+// flagged as errors, holding the content of a ToolError or else the
+// error's text, as the SDK expects of failed calls.
+func ToServerTool(t mcp.Tool) server.ServerTool {
+	schema, err := json.Marshal(t.InputSchema())
+	if err != nil {
+		schema = []byte(`{"type":"object"}`)
+	}
+	tool := sdk.NewToolWithRawSchema(t.Name(), t.Description(), schema)
+	if at, ok := t.(mcp.AnnotatedTool); ok {
+		a := at.Annotations()
+		tool.Annotations = sdk.ToolAnnotation{
+			Title:           a.Title,
+			ReadOnlyHint:    &a.ReadOnlyHint,
+			DestructiveHint: a.DestructiveHint,
+			IdempotentHint:  &a.IdempotentHint,
+			OpenWorldHint:   a.OpenWorldHint,
+		}
+	}
+	return server.ServerTool{Tool: tool, Handler: handler(t)}
+}
+
+// handler returns an SDK handler calling t.
+func handler(t mcp.Tool) server.ToolHandlerFunc {
+	return func(ctx context.Context, req sdk.CallToolRequest) (*sdk.CallToolResult, error) {
+		result, err := execute(ctx, t, req.GetArguments())
+		if err != nil {
+			var toolErr *mcp.ToolError
+			if !errors.As(err, &toolErr) {
+				return sdk.NewToolResultError(err.Error()), nil
+			}
+			res, convErr := toSDKResult(&mcp.Result{Content: toolErr.Content})
+			if convErr != nil {
+				return nil, convErr
+			}
+			res.IsError = true
+			return res, nil
+		}
+		return toSDKResult(result)
+	}
+}
+
+// execute calls t as the server does, through the richest method it has.
+func execute(ctx context.Context, t mcp.Tool, args map[string]interface{}) (*mcp.Result, error) {
+	switch tool := t.(type) {
+	case mcp.ResultTool:
+		result, err := tool.ExecuteResult(ctx, args)
+		if result == nil && err == nil {
+			result = &mcp.Result{}
+		}
+		return result, err
+	case mcp.ContextTool:
+		content, err := tool.ExecuteContext(ctx, args)
+		return &mcp.Result{Content: content}, err
+	}
+	content, err := t.Execute(args)
+	return &mcp.Result{Content: content}, err
+}
+
+// toSDKResult converts a result to the SDK's type.
+func toSDKResult(result *mcp.Result) (*sdk.CallToolResult, error) {
+	res := &sdk.CallToolResult{Content: make([]sdk.Content, 0, len(result.Content)), StructuredContent: result.Structured}
+	for _, c := range result.Content {
+		data, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		block, err := sdk.ParseContent(fields)
+		if err != nil {
+			return nil, err
+		}
+		res.Content = append(res.Content, block)
+	}
+	return res, nil
+}
@@ -0,0 +1,100 @@
+package mcpgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	sdk "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// greetTool greets by name, failing for an empty one.
+type greetTool struct{}
+
+func (greetTool) Name() string        { return "greet" }
+func (greetTool) Description() string { return "Greets someone" }
+func (greetTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(struct {
+		Name string `json:"name"`
+	}{})
+}
+func (greetTool) Annotations() mcp.ToolAnnotations {
+	return mcp.ToolAnnotations{Title: "Greet", ReadOnlyHint: true}
+}
+func (greetTool) Execute(args map[string]interface{}) ([]mcp.Content, error) {
+	name, _ := args["name"].(string)
+	switch name {
+	case "":
+		return nil, errors.New("'name' is required")
+	case "nobody":
+		return nil, &mcp.ToolError{Content: []mcp.Content{mcp.NewTextContent("Nobody is here")}}
+	}
+	return []mcp.Content{mcp.NewTextContent("Hello, " + name)}, nil
+}
+
+// Test that SDK tools are called with their arguments and their results
+// and failures converted
+func TestFromServerTool(t *testing.T) {
+	tool := FromServerTool(server.ServerTool{
+		Tool: sdk.NewTool("lookup",
+			sdk.WithDescription("Looks up a record"),
+			sdk.WithString("id", sdk.Required()),
+			sdk.WithReadOnlyHintAnnotation(true),
+		),
+		Handler: func(ctx context.Context, req sdk.CallToolRequest) (*sdk.CallToolResult, error) {
+			id, err := req.RequireString("id")
+			if err != nil {
+				return nil, err
+			}
+			if id == "0" {
+				return sdk.NewToolResultError("No record 0"), nil
+			}
+			return sdk.NewToolResultStructured(map[string]string{"id": id}, "Record "+id), nil
+		},
+	})
+	if schema, _ := json.Marshal(tool.InputSchema()); !strings.Contains(string(schema), `"required":["id"]`) {
+		t.Errorf("unexpected schema %s", schema)
+	}
+	if a := tool.(mcp.AnnotatedTool).Annotations(); !a.ReadOnlyHint || a.Title != "" {
+		t.Errorf("unexpected annotations %+v", a)
+	}
+	result, err := tool.(mcp.ResultTool).ExecuteResult(context.Background(), map[string]interface{}{"id": "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded, _ := json.Marshal(result); string(encoded) != `{"Content":[{"type":"text","text":"Record 7"}],"Structured":{"id":"7"}}` {
+		t.Errorf("unexpected result %s", encoded)
+	}
+	var toolErr *mcp.ToolError
+	if _, err := tool.Execute(map[string]interface{}{"id": "0"}); !errors.As(err, &toolErr) || err.Error() != "No record 0" {
+		t.Errorf("expected a ToolError, got %v", err)
+	}
+	if _, err := tool.Execute(map[string]interface{}{}); err == nil || errors.As(err, &toolErr) {
+		t.Errorf("expected the handler's error, got %v", err)
+	}
+}
+
+// Test that tools of package mcp are served by an SDK server
+func TestToServerTool(t *testing.T) {
+	s := server.NewMCPServer("test", "1")
+	s.AddTools(ServerTools(greetTool{})...)
+	for args, want := range map[string]string{
+		`{"name":"Ada"}`:    `"result":{"content":[{"type":"text","text":"Hello, Ada"}]}`,
+		`{"name":"nobody"}`: `"result":{"content":[{"type":"text","text":"Nobody is here"}],"isError":true}`,
+		`{}`:                `"result":{"content":[{"type":"text","text":"'name' is required"}],"isError":true}`,
+	} {
+		response := s.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"greet","arguments":`+args+`}}`))
+		if encoded, _ := json.Marshal(response); !strings.Contains(string(encoded), want) {
+			t.Errorf("%s: expected %s, got %s", args, want, encoded)
+		}
+	}
+	response := s.HandleMessage(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`))
+	if encoded, _ := json.Marshal(response); !strings.Contains(string(encoded), `"annotations":{"title":"Greet","readOnlyHint":true,"idempotentHint":false}`) {
+		t.Errorf("unexpected tools list %s", encoded)
+	}
+}
@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaFor returns the JSON Schema of a tool's arguments, declared as a Go
+// struct. v is a value or pointer of the struct type. Properties are named
+// after the json tag of each field, and fields are required unless they are
+// pointers, tagged omitempty, or have a default. These tags add constraints:
+//
+//	description:"..."  the property's description
+//	enum:"a,b,c"       the allowed values, comma separated
+//	minimum:"1"        the smallest allowed number
+//	maximum:"32"       the largest allowed number
+//	default:"..."      the value the server fills in when the argument is
+//	                   omitted, for string, number, and boolean fields
+//
+// SchemaFor panics if v is not a struct or a tag cannot be parsed, as either
+// is a mistake in the tool's code.
+func SchemaFor(v interface{}) map[string]interface{} {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("mcp: SchemaFor needs a struct, got %v", t))
+	}
+	return schemaForType(t, map[reflect.Type]bool{})
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	rawType  = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// schemaForType returns the schema of values of type t. seen holds the
+// struct types being expanded, so that recursive types terminate.
+func schemaForType(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawType:
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes byte slices as base64 strings.
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaForType(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaForType(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		properties := map[string]interface{}{}
+		required := []string{}
+		addFields(t, properties, &required, seen)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{}
+}
+
+// addFields adds the properties of the fields of struct type t, including
+// those promoted from embedded structs, as encoding/json would name them.
+func addFields(t reflect.Type, properties map[string]interface{}, required *[]string, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(ft, properties, required, seen)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := schemaForType(f.Type, seen)
+		applyTags(prop, f)
+		properties[name] = prop
+		_, defaulted := f.Tag.Lookup("default")
+		if f.Type.Kind() != reflect.Pointer && !defaulted && !strings.Contains(","+opts+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// applyTags adds the constraints declared in the tags of field f to prop.
+func applyTags(prop map[string]interface{}, f reflect.StructField) {
+	if d, ok := f.Tag.Lookup("description"); ok {
+		prop["description"] = d
+	}
+	if e, ok := f.Tag.Lookup("enum"); ok {
+		values := strings.Split(e, ",")
+		switch prop["type"] {
+		case "string":
+			prop["enum"] = values
+		case "integer", "number":
+			nums := make([]float64, len(values))
+			for i, v := range values {
+				nums[i] = parseNumberTag(f, "enum", v)
+			}
+			prop["enum"] = nums
+		default:
+			panic(fmt.Sprintf("mcp: enum tag on field %s of type %v", f.Name, f.Type))
+		}
+	}
+	for _, key := range []string{"minimum", "maximum"} {
+		if v, ok := f.Tag.Lookup(key); ok {
+			prop[key] = parseNumberTag(f, key, v)
+		}
+	}
+	if d, ok := f.Tag.Lookup("default"); ok {
+		switch prop["type"] {
+		case "string":
+			prop["default"] = d
+		case "integer", "number":
+			prop["default"] = parseNumberTag(f, "default", d)
+		case "boolean":
+			b, err := strconv.ParseBool(d)
+			if err != nil {
+				panic(fmt.Sprintf("mcp: invalid default tag %q on field %s", d, f.Name))
+			}
+			prop["default"] = b
+		default:
+			panic(fmt.Sprintf("mcp: default tag on field %s of type %v", f.Name, f.Type))
+		}
+	}
+}
+
+// parseNumberTag parses the value of a numeric tag of field f.
+func parseNumberTag(f reflect.StructField, key, v string) float64 {
+	n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		panic(fmt.Sprintf("mcp: invalid %s tag %q on field %s", key, v, f.Name))
+	}
+	return n
+}
+
+// DecodeArgs decodes the arguments of a tool call into the struct pointed
+// to by dst, the same struct passed to SchemaFor. Arguments of the wrong
+// type are reported by the name of their property.
+func DecodeArgs(args map[string]interface{}, dst interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(data, dst)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fmt.Errorf("invalid type for '%s'", typeErr.Field)
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+type schemaAddress struct {
+	City string `json:"city"`
+}
+
+type schemaBase struct {
+	ID string `json:"id" description:"Record ID"`
+}
+
+type schemaArgs struct {
+	schemaBase
+	Name     string          `json:"name" description:"The name"`
+	Level    string          `json:"level,omitempty" enum:"low,high" default:"low"`
+	Count    int             `json:"count,omitempty" minimum:"1" maximum:"10" default:"5"`
+	Verbose  bool            `json:"verbose" default:"true"`
+	Ratio    float64         `json:"ratio"`
+	Enabled  *bool           `json:"enabled"`
+	Tags     []string        `json:"tags,omitempty"`
+	Labels   map[string]int  `json:"labels,omitempty"`
+	Address  *schemaAddress  `json:"address"`
+	Data     []byte          `json:"data,omitempty"`
+	When     time.Time       `json:"when,omitempty"`
+	Extra    json.RawMessage `json:"extra,omitempty"`
+	Ignored  string          `json:"-"`
+	Untagged bool            `json:",omitempty"`
+	internal string
+	Children []schemaRecursive `json:"children,omitempty"`
+}
+
+type schemaRecursive struct {
+	Next *schemaRecursive `json:"next"`
+}
+
+// Test the schema generated for a struct with every supported kind of field
+func TestSchemaFor(t *testing.T) {
+	got, _ := json.Marshal(SchemaFor(&schemaArgs{}))
+	want := `{"properties":{` +
+		`"Untagged":{"type":"boolean"},` +
+		`"address":{"properties":{"city":{"type":"string"}},"required":["city"],"type":"object"},` +
+		`"children":{"items":{"properties":{"next":{"type":"object"}},"type":"object"},"type":"array"},` +
+		`"count":{"default":5,"maximum":10,"minimum":1,"type":"integer"},` +
+		`"data":{"contentEncoding":"base64","type":"string"},` +
+		`"enabled":{"type":"boolean"},` +
+		`"extra":{},` +
+		`"id":{"description":"Record ID","type":"string"},` +
+		`"labels":{"additionalProperties":{"type":"integer"},"type":"object"},` +
+		`"level":{"default":"low","enum":["low","high"],"type":"string"},` +
+		`"name":{"description":"The name","type":"string"},` +
+		`"ratio":{"type":"number"},` +
+		`"tags":{"items":{"type":"string"},"type":"array"},` +
+		`"verbose":{"default":true,"type":"boolean"},` +
+		`"when":{"format":"date-time","type":"string"}` +
+		`},"required":["id","name","ratio"],"type":"object"}`
+	if string(got) != want {
+		t.Errorf("SchemaFor:\n got %s\nwant %s", got, want)
+	}
+}
+
+// Test that SchemaFor rejects non-struct types and malformed tags
+func TestSchemaForPanics(t *testing.T) {
+	cases := map[string]interface{}{
+		"not a struct": "text",
+		"nil":          nil,
+		"bad minimum": struct {
+			N int `json:"n" minimum:"one"`
+		}{},
+		"enum on bool": struct {
+			B bool `json:"b" enum:"true"`
+		}{},
+		"bad default": struct {
+			B bool `json:"b" default:"maybe"`
+		}{},
+		"default on slice": struct {
+			S []string `json:"s" default:"a"`
+		}{},
+	}
+	for name, v := range cases {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			SchemaFor(v)
+		}()
+	}
+}
+
+// Test decoding arguments into the struct the schema was generated from
+func TestDecodeArgs(t *testing.T) {
+	var a schemaArgs
+	err := DecodeArgs(map[string]interface{}{
+		"name":    "x",
+		"count":   float64(3),
+		"address": map[string]interface{}{"city": "Osaka"},
+	}, &a)
+	if err != nil {
+		t.Fatalf("DecodeArgs error: %v", err)
+	}
+	if a.Name != "x" || a.Count != 3 || a.Address == nil || a.Address.City != "Osaka" {
+		t.Errorf("unexpected result: %+v", a)
+	}
+
+	err = DecodeArgs(map[string]interface{}{"count": 1.5}, &a)
+	if err == nil || err.Error() != "invalid type for 'count'" {
+		t.Errorf("expected a type error for 'count', got %v", err)
+	}
+	err = DecodeArgs(map[string]interface{}{"address": map[string]interface{}{"city": 1}}, &a)
+	if err == nil || err.Error() != "invalid type for 'address.city'" {
+		t.Errorf("expected a type error for 'address.city', got %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+// Package mcpmock provides a scripted fake MCP client for unit testing
+// servers in process. Besides making requests like any client, it answers
+// the requests a server sends back to its client, such as elicitations and
+// sampling, with canned answers, and checks that the server sent the
+// requests it was expected to.
+package mcpmock
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"mcp-minimal-server-go/client"
+)
+
+// ServeFunc serves an MCP session, reading client messages from r and
+// writing server messages to w until r is closed. The Serve method of a
+// server usually is one.
+type ServeFunc func(ctx context.Context, r io.Reader, w io.Writer) error
+
+// Responder answers a request from the server with a result to encode as
+// JSON or an error. A *client.RPCError is sent as is; other errors are sent
+// as internal errors.
+type Responder func(params json.RawMessage) (interface{}, error)
+
+// capabilities maps methods the server may call on the client to the client
+// capability they require.
+var capabilities = map[string]string{
+	"elicitation/create":     "elicitation",
+	"sampling/createMessage": "sampling",
+	"roots/list":             "roots",
+}
+
+// Client is a fake client connected to a server. It embeds a
+// *client.Client for making requests. It is safe for concurrent use.
+type Client struct {
+	*client.Client
+
+	responders map[string]Responder
+	expected   map[string]int
+
+	toServer *io.PipeWriter
+	wmu      sync.Mutex
+	cancel   context.CancelFunc
+	served   chan error
+	forward  sync.WaitGroup
+
+	mu         sync.Mutex
+	received   map[string][]json.RawMessage
+	unexpected []string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// Respond answers every server request for method with result.
+func Respond(method string, result interface{}) Option {
+	return RespondFunc(method, func(json.RawMessage) (interface{}, error) {
+		return result, nil
+	})
+}
+
+// RespondError answers every server request for method with an error.
+func RespondError(method string, code int, message string) Option {
+	return RespondFunc(method, func(json.RawMessage) (interface{}, error) {
+		return nil, &client.RPCError{Code: code, Message: message}
+	})
+}
+
+// RespondFunc answers server requests for method with f.
+func RespondFunc(method string, f Responder) Option {
+	return func(c *Client) {
+		c.responders[method] = f
+	}
+}
+
+// Expect makes Verify fail unless the server sends exactly n requests for
+// method.
+func Expect(method string, n int) Option {
+	return func(c *Client) {
+		c.expected[method] = n
+	}
+}
+
+// New starts serve in the background and returns a client connected to it.
+// Server requests for methods without a responder are answered with a
+// "Method not found" error and make Verify fail.
+func New(serve ServeFunc, opts ...Option) *Client {
+	c := &Client{
+		responders: map[string]Responder{},
+		expected:   map[string]int{},
+		served:     make(chan error, 1),
+		received:   map[string][]json.RawMessage{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	serverIn, toServer := io.Pipe()
+	fromServer, serverOut := io.Pipe()
+	clientIn, toClient := io.Pipe()
+	c.toServer = toServer
+	go func() {
+		err := serve(ctx, serverIn, serverOut)
+		serverIn.Close()
+		serverOut.Close()
+		c.served <- err
+	}()
+	go c.route(fromServer, toClient)
+	c.Client = client.New(clientIn, lockedWriter{c}, func() error {
+		toServer.Close()
+		return nil
+	})
+	return c
+}
+
+// lockedWriter serializes the writes of the embedded client with the
+// responses written by the mock.
+type lockedWriter struct {
+	c *Client
+}
+
+// Write implements io.Writer.
+func (w lockedWriter) Write(p []byte) (int, error) {
+	return w.c.write(p)
+}
+
+// write sends p to the server.
+func (c *Client) write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.toServer.Write(p)
+}
+
+// route answers the requests among the server's messages and passes the
+// rest on to the embedded client.
+func (c *Client) route(r io.Reader, toClient *io.PipeWriter) {
+	defer toClient.Close()
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+				Params json.RawMessage `json:"params"`
+			}
+			if json.Unmarshal(line, &msg) == nil && msg.Method != "" && msg.ID != nil {
+				c.forward.Add(1)
+				go func() {
+					defer c.forward.Done()
+					c.answer(msg.ID, msg.Method, msg.Params)
+				}()
+			} else if _, werr := toClient.Write(line); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// answer records a request from the server and sends its response.
+func (c *Client) answer(id json.RawMessage, method string, params json.RawMessage) {
+	c.mu.Lock()
+	c.received[method] = append(c.received[method], params)
+	f, ok := c.responders[method]
+	if !ok {
+		c.unexpected = append(c.unexpected, method)
+	}
+	c.mu.Unlock()
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	var result interface{}
+	err := error(&client.RPCError{Code: -32601, Message: "Method not found: " + method})
+	if ok {
+		result, err = f(params)
+	}
+	var rpcErr *client.RPCError
+	switch {
+	case err == nil:
+		resp["result"] = result
+	case errors.As(err, &rpcErr):
+		resp["error"] = rpcErr
+	default:
+		resp["error"] = &client.RPCError{Code: -32603, Message: err.Error()}
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		data, _ = json.Marshal(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"error":   &client.RPCError{Code: -32603, Message: "mcpmock: cannot encode result: " + err.Error()},
+		})
+	}
+	c.write(append(data, '\n'))
+}
+
+// Initialize performs the initialization handshake, declaring the client
+// capabilities needed for the methods the mock has responders for.
+func (c *Client) Initialize(ctx context.Context) (*client.InitializeResult, error) {
+	caps := map[string]interface{}{}
+	for method := range c.responders {
+		if capability, ok := capabilities[method]; ok {
+			caps[capability] = map[string]interface{}{}
+		}
+	}
+	var result client.InitializeResult
+	params := map[string]interface{}{
+		"protocolVersion": client.ProtocolVersion,
+		"clientInfo":      client.Implementation{Name: "mcpmock", Version: "0.1.0"},
+		"capabilities":    caps,
+	}
+	if err := c.Call(ctx, "initialize", params, &result); err != nil {
+		return nil, err
+	}
+	if err := c.Notify(ctx, "notifications/initialized", nil); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Requests returns the params of the requests for method the server has
+// sent so far.
+func (c *Client) Requests(method string) []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]json.RawMessage(nil), c.received[method]...)
+}
+
+// Verify checks the requests the server has sent against the expectations
+// and reports requests no responder was set up for.
+func (c *Client) Verify() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var problems []string
+	methods := make([]string, 0, len(c.expected))
+	for method := range c.expected {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		if got, want := len(c.received[method]), c.expected[method]; got != want {
+			problems = append(problems, fmt.Sprintf("expected %d %s requests, got %d", want, method, got))
+		}
+	}
+	for _, method := range c.unexpected {
+		problems = append(problems, "unexpected "+method+" request")
+	}
+	if len(problems) > 0 {
+		return errors.New("mcpmock: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Close ends the session and returns the error serve returned. If serve
+// does not stop once its input is closed, Close cancels its context.
+func (c *Client) Close() error {
+	c.Client.Close()
+	c.forward.Wait()
+	select {
+	case err := <-c.served:
+		c.cancel()
+		return err
+	default:
+	}
+	c.cancel()
+	return <-c.served
+}
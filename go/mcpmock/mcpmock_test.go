@@ -0,0 +1,161 @@
+package mcpmock
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"mcp-minimal-server-go/client"
+)
+
+// askingServer is a sequential fake server. It answers initialize and, for
+// every "ask" request, sends its params as a request for the method named
+// in them to the client and answers with the client's response.
+func askingServer(ctx context.Context, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	next := 0
+	for scanner.Scan() {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Method string `json:"method"`
+			} `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return err
+		}
+		switch req.Method {
+		case "initialize":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-03-26","capabilities":{},"serverInfo":{"name":"asking","version":"1"}}}`+"\n", req.ID)
+		case "ask":
+			next++
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":"s%d","method":%q,"params":{"n":%d}}`+"\n", next, req.Params.Method, next)
+			if !scanner.Scan() {
+				return scanner.Err()
+			}
+			var resp struct {
+				ID     string          `json:"id"`
+				Result json.RawMessage `json:"result"`
+				Error  json.RawMessage `json:"error"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+				return err
+			}
+			if resp.ID != fmt.Sprintf("s%d", next) {
+				return fmt.Errorf("response to %q, expected s%d", resp.ID, next)
+			}
+			answer := resp.Result
+			if resp.Error != nil {
+				answer = resp.Error
+			}
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"answer":%s}}`+"\n", req.ID, answer)
+		default:
+			fmt.Fprintln(w, `{"jsonrpc":"2.0","method":"notifications/message","params":{}}`)
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"Method not found"}}`+"\n", req.ID)
+		}
+	}
+	return scanner.Err()
+}
+
+// ask makes the server ask the client for method and returns the answer.
+func ask(t *testing.T, c *Client, method string) string {
+	t.Helper()
+	var result struct {
+		Answer json.RawMessage `json:"answer"`
+	}
+	if err := c.Call(context.Background(), "ask", map[string]string{"method": method}, &result); err != nil {
+		t.Fatalf("ask %s: %v", method, err)
+	}
+	return string(result.Answer)
+}
+
+// Test that server requests get their canned answers and are counted
+func TestClient(t *testing.T) {
+	c := New(askingServer,
+		Respond("elicitation/create", map[string]interface{}{"action": "accept"}),
+		RespondError("roots/list", -32000, "no roots"),
+		RespondFunc("sampling/createMessage", func(params json.RawMessage) (interface{}, error) {
+			return json.RawMessage(params), nil
+		}),
+		Expect("elicitation/create", 2),
+	)
+	ctx := context.Background()
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatalf("Initialize error: %v", err)
+	}
+
+	if got := ask(t, c, "elicitation/create"); got != `{"action":"accept"}` {
+		t.Errorf("unexpected elicitation answer %s", got)
+	}
+	if got := ask(t, c, "sampling/createMessage"); got != `{"n":2}` {
+		t.Errorf("unexpected sampling answer %s", got)
+	}
+	if got := ask(t, c, "roots/list"); got != `{"code":-32000,"message":"no roots"}` {
+		t.Errorf("unexpected roots answer %s", got)
+	}
+	var rpcErr *client.RPCError
+	if err := c.Call(ctx, "other", nil, nil); err == nil || !strings.Contains(err.Error(), "Method not found") {
+		t.Errorf("expected the server's error, got %v", err)
+	} else if !errors.As(err, &rpcErr) || rpcErr.Code != -32601 {
+		t.Errorf("expected a Method not found RPCError, got %#v", err)
+	}
+
+	if err := c.Verify(); err == nil || !strings.Contains(err.Error(), "expected 2 elicitation/create requests, got 1") {
+		t.Errorf("expected an unmet expectation, got %v", err)
+	}
+	ask(t, c, "elicitation/create")
+	if err := c.Verify(); err != nil {
+		t.Errorf("Verify error: %v", err)
+	}
+	if params := c.Requests("elicitation/create"); len(params) != 2 || string(params[1]) != `{"n":4}` {
+		t.Errorf("unexpected recorded params %s", params)
+	}
+
+	if got := ask(t, c, "roots/unknown"); !strings.Contains(got, "-32601") {
+		t.Errorf("expected Method not found for an unscripted request, got %s", got)
+	}
+	if err := c.Verify(); err == nil || !strings.Contains(err.Error(), "unexpected roots/unknown request") {
+		t.Errorf("expected an unexpected request, got %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+}
+
+// Test that initialize declares the capabilities of the scripted methods
+func TestInitializeCapabilities(t *testing.T) {
+	var got map[string]interface{}
+	serve := func(ctx context.Context, r io.Reader, w io.Writer) error {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			var req struct {
+				ID     json.RawMessage `json:"id"`
+				Params struct {
+					Capabilities map[string]interface{} `json:"capabilities"`
+				} `json:"params"`
+			}
+			json.Unmarshal(scanner.Bytes(), &req)
+			if req.ID != nil {
+				got = req.Params.Capabilities
+				fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{}}`+"\n", req.ID)
+			}
+		}
+		return nil
+	}
+	c := New(serve, Respond("elicitation/create", nil), Respond("custom/method", nil))
+	if _, err := c.Initialize(context.Background()); err != nil {
+		t.Fatalf("Initialize error: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if len(got) != 1 || got["elicitation"] == nil {
+		t.Errorf("expected only the elicitation capability, got %v", got)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// serverMetrics holds the counters and histograms exposed on /metrics in the
+// Prometheus text exposition format.
+type serverMetrics struct {
+	requests       *counterVec
+	errors         *counterVec
+	cacheHits      *counterVec
+	toolDuration   *histogramVec
+	messageSize    *histogramVec
+	activeSessions *gauge
+	tools          *toolStats // of every server, for latency quantiles and error rates
+}
+
+// newServerMetrics returns an empty set of server metrics.
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests: newCounterVec("mcp_requests_total",
+			"JSON-RPC requests received, by method.", "method"),
+		errors: newCounterVec("mcp_errors_total",
+			"JSON-RPC error responses sent, by error code.", "code"),
+		cacheHits: newCounterVec("mcp_tool_cache_hits_total",
+			"Tool calls answered from the result cache, by tool.", "tool"),
+		toolDuration: newHistogramVec("mcp_tool_call_duration_seconds",
+			"Tool execution latency in seconds, by tool.", "tool",
+			[]float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30}),
+		messageSize: newHistogramVec("mcp_message_size_bytes",
+			"Size of JSON-RPC messages in bytes, by direction.", "direction",
+			[]float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}),
+		activeSessions: &gauge{name: "mcp_active_sessions",
+			help: "Number of currently connected sessions."},
+		tools: &toolStats{},
+	}
+}
+
+// metrics collects metrics for every server running in this process.
+var metrics = newServerMetrics()
+
+// ServeHTTP writes all metrics in the Prometheus text format.
+func (m *serverMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// write writes all metrics in the Prometheus text format to w.
+func (m *serverMetrics) write(w io.Writer) {
+	m.requests.write(w)
+	m.errors.write(w)
+	m.cacheHits.write(w)
+	m.toolDuration.write(w)
+	m.messageSize.write(w)
+	m.activeSessions.write(w)
+	writeToolStats(w, m.tools.snapshot())
+}
+
+// writeToolStats writes the per-tool call and error counters, a summary of
+// recent latencies and the recent error rate in the Prometheus text
+// format, so that alerts can hold individual tools to their objectives.
+func writeToolStats(w io.Writer, snap map[string]toolStatSnapshot) {
+	tools := sortedKeys(snap)
+	window := fmt.Sprintf("%d minutes", int((statsWindows * statsWindow).Minutes()))
+	fmt.Fprintf(w, "# HELP mcp_tool_calls_total Tool calls, by tool.\n# TYPE mcp_tool_calls_total counter\n")
+	for _, tool := range tools {
+		fmt.Fprintf(w, "mcp_tool_calls_total{tool=%s} %d\n", quoteLabel(tool), snap[tool].Calls)
+	}
+	fmt.Fprintf(w, "# HELP mcp_tool_call_errors_total Tool calls that failed, by tool.\n# TYPE mcp_tool_call_errors_total counter\n")
+	for _, tool := range tools {
+		fmt.Fprintf(w, "mcp_tool_call_errors_total{tool=%s} %d\n", quoteLabel(tool), snap[tool].Errors)
+	}
+	fmt.Fprintf(w, "# HELP mcp_tool_call_latency_seconds Tool execution latency quantiles over the last %s, by tool.\n# TYPE mcp_tool_call_latency_seconds summary\n", window)
+	for _, tool := range tools {
+		st, label := snap[tool], quoteLabel(tool)
+		for _, q := range []struct {
+			quantile string
+			ms       float64
+		}{{"0.5", st.P50Ms}, {"0.9", st.P90Ms}, {"0.99", st.P99Ms}, {"0.999", st.P999Ms}} {
+			fmt.Fprintf(w, "mcp_tool_call_latency_seconds{tool=%s,quantile=\"%s\"} %s\n", label, q.quantile, formatFloat(q.ms/1000))
+		}
+		fmt.Fprintf(w, "mcp_tool_call_latency_seconds_sum{tool=%s} %s\n", label, formatFloat(st.TotalMs/1000))
+		fmt.Fprintf(w, "mcp_tool_call_latency_seconds_count{tool=%s} %d\n", label, st.Calls)
+	}
+	fmt.Fprintf(w, "# HELP mcp_tool_error_ratio Fraction of tool calls that failed over the last %s, by tool.\n# TYPE mcp_tool_error_ratio gauge\n", window)
+	for _, tool := range tools {
+		fmt.Fprintf(w, "mcp_tool_error_ratio{tool=%s} %s\n", quoteLabel(tool), formatFloat(snap[tool].ErrorRate))
+	}
+}
+
+// counterVec is a set of counters partitioned by the value of one label.
+type counterVec struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// newCounterVec returns an empty counterVec.
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: map[string]uint64{}}
+}
+
+// inc increments the counter for the given label value.
+func (c *counterVec) inc(labelValue string) {
+	c.mu.Lock()
+	c.values[labelValue]++
+	c.mu.Unlock()
+}
+
+// get returns the current value of the counter for the given label value.
+func (c *counterVec) get(labelValue string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelValue]
+}
+
+// total returns the sum of the counters for all label values.
+func (c *counterVec) total() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var sum uint64
+	for _, v := range c.values {
+		sum += v
+	}
+	return sum
+}
+
+// write writes the counters in the Prometheus text format.
+func (c *counterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	for _, lv := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", c.name, c.label, quoteLabel(lv), c.values[lv])
+	}
+}
+
+// histogramVec is a set of histograms partitioned by the value of one label.
+type histogramVec struct {
+	name, help, label string
+	buckets           []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+// histogram holds cumulative bucket counts for one label value.
+type histogram struct {
+	counts []uint64 // counts[i] observations <= buckets[i]; last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// newHistogramVec returns an empty histogramVec with the given upper bounds.
+func newHistogramVec(name, help, label string, buckets []float64) *histogramVec {
+	return &histogramVec{name: name, help: help, label: label, buckets: buckets, values: map[string]*histogram{}}
+}
+
+// observe records v for the given label value.
+func (h *histogramVec) observe(labelValue string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist, ok := h.values[labelValue]
+	if !ok {
+		hist = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.values[labelValue] = hist
+	}
+	for i, ub := range h.buckets {
+		if v <= ub {
+			hist.counts[i]++
+		}
+	}
+	hist.counts[len(h.buckets)]++
+	hist.sum += v
+	hist.count++
+}
+
+// write writes the histograms in the Prometheus text format.
+func (h *histogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, lv := range sortedKeys(h.values) {
+		hist := h.values[lv]
+		for i, ub := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket{%s=%s,le=\"%s\"} %d\n", h.name, h.label, quoteLabel(lv), formatFloat(ub), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s=%s,le=\"+Inf\"} %d\n", h.name, h.label, quoteLabel(lv), hist.counts[len(h.buckets)])
+		fmt.Fprintf(w, "%s_sum{%s=%s} %s\n", h.name, h.label, quoteLabel(lv), formatFloat(hist.sum))
+		fmt.Fprintf(w, "%s_count{%s=%s} %d\n", h.name, h.label, quoteLabel(lv), hist.count)
+	}
+}
+
+// gauge is a single value that can go up and down.
+type gauge struct {
+	name, help string
+
+	mu    sync.Mutex
+	value int64
+}
+
+// add adds delta to the gauge.
+func (g *gauge) add(delta int64) {
+	g.mu.Lock()
+	g.value += delta
+	g.mu.Unlock()
+}
+
+// get returns the current value of the gauge.
+func (g *gauge) get() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+// write writes the gauge in the Prometheus text format.
+func (g *gauge) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", g.name, g.help, g.name, g.name, g.get())
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quoteLabel quotes a label value as required by the text format.
+func quoteLabel(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
+
+// formatFloat formats a sample value as required by the text format.
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
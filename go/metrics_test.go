@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test that requests, errors, and tool latency are recorded and exposed
+func TestMetrics(t *testing.T) {
+	callsBefore := metrics.requests.get("tools/call")
+	otherBefore := metrics.requests.get("other")
+	notFoundBefore := metrics.errors.get("-32601")
+
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":1}
+{"jsonrpc":"2.0","method":"no/such/method","id":2}`
+	runTestInput(t, input)
+
+	if got := metrics.requests.get("tools/call") - callsBefore; got != 1 {
+		t.Errorf("expected 1 tools/call request, got %d", got)
+	}
+	if got := metrics.requests.get("other") - otherBefore; got != 1 {
+		t.Errorf("expected unknown method counted as other, got %d", got)
+	}
+	if got := metrics.errors.get("-32601") - notFoundBefore; got != 1 {
+		t.Errorf("expected 1 error with code -32601, got %d", got)
+	}
+	if got := metrics.activeSessions.get(); got != 0 {
+		t.Errorf("expected 0 active sessions after the server returned, got %d", got)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE mcp_requests_total counter",
+		`mcp_requests_total{method="tools/call"}`,
+		`mcp_errors_total{code="-32601"}`,
+		`mcp_tool_call_duration_seconds_bucket{tool="echo",le="+Inf"}`,
+		`mcp_message_size_bytes_count{direction="inbound"}`,
+		"mcp_active_sessions 0",
+		`mcp_tool_calls_total{tool="echo"}`,
+		`mcp_tool_call_latency_seconds{tool="echo",quantile="0.99"}`,
+		`mcp_tool_error_ratio{tool="echo"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics output to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+)
+
+// mmdbMetadataMarker precedes the metadata at the end of a MaxMind DB
+// file.
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// mmdbMaxDepth bounds the nesting of decoded values, so that a corrupt
+// file whose pointers form a cycle cannot recurse forever.
+const mmdbMaxDepth = 32
+
+// mmdbReader looks up addresses in a MaxMind DB file, the format of the
+// GeoIP2 and GeoLite2 databases: a binary search tree over the bits of the
+// address whose leaves point into a data section of typed values.
+type mmdbReader struct {
+	databaseType string
+	ipVersion    int
+	buildEpoch   uint64
+
+	tree       []byte
+	nodeCount  uint64
+	recordSize int
+	data       mmdbDecoder
+	ipv4Start  uint64 // the node of ::/96, where IPv4 addresses start
+}
+
+// openMMDB reads the MaxMind DB file at path into memory.
+func openMMDB(path string) (*mmdbReader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newMMDBReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return r, nil
+}
+
+// newMMDBReader returns a reader of the MaxMind DB in buf.
+func newMMDBReader(buf []byte) (*mmdbReader, error) {
+	// The marker can only occur in the last 128 KiB, which hold the
+	// metadata; its last occurrence starts the metadata.
+	search := buf[max(0, len(buf)-128<<10):]
+	i := bytes.LastIndex(search, mmdbMetadataMarker)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB file")
+	}
+	metaStart := len(buf) - len(search) + i
+	meta, _, err := mmdbDecoder{buf[metaStart+len(mmdbMetadataMarker):]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %v", err)
+	}
+	m, ok := meta.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid metadata")
+	}
+	uintField := func(name string) uint64 {
+		v, _ := m[name].(uint64)
+		return v
+	}
+	r := &mmdbReader{
+		nodeCount:  uintField("node_count"),
+		recordSize: int(uintField("record_size")),
+		ipVersion:  int(uintField("ip_version")),
+		buildEpoch: uintField("build_epoch"),
+	}
+	r.databaseType, _ = m["database_type"].(string)
+	if uintField("binary_format_major_version") != 2 {
+		return nil, fmt.Errorf("unsupported format version %d", uintField("binary_format_major_version"))
+	}
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.ipVersion)
+	}
+	treeSize := r.nodeCount * uint64(r.recordSize) / 4
+	if treeSize+16 > uint64(metaStart) {
+		return nil, errors.New("search tree larger than the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = mmdbDecoder{buf[treeSize+16 : metaStart]}
+
+	if r.ipVersion == 6 {
+		node := uint64(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (r *mmdbReader) record(node uint64, bit byte) uint64 {
+	b := r.tree[node*uint64(r.recordSize)/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint64(b[0])<<16 | uint64(b[1])<<8 | uint64(b[2])
+	case 28:
+		if bit == 0 {
+			return uint64(b[3]>>4)<<24 | uint64(b[0])<<16 | uint64(b[1])<<8 | uint64(b[2])
+		}
+		return uint64(b[3]&0x0f)<<24 | uint64(b[4])<<16 | uint64(b[5])<<8 | uint64(b[6])
+	}
+	return uint64(binary.BigEndian.Uint32(b[bit*4:]))
+}
+
+// lookup returns the data for ip and the length of the prefix of the
+// network it was found for, or nil if the database has no data for ip.
+func (r *mmdbReader) lookup(ip netip.Addr) (interface{}, int, error) {
+	ip = ip.Unmap()
+	node, bits := uint64(0), 128
+	switch {
+	case ip.Is4() && r.ipVersion == 6:
+		node, bits = r.ipv4Start, 32
+	case ip.Is4():
+		bits = 32
+	case r.ipVersion == 4:
+		return nil, 0, errors.New("the database has only IPv4 addresses")
+	}
+	addr := ip.AsSlice()
+	depth := 0
+	for ; depth < bits && node < r.nodeCount; depth++ {
+		node = r.record(node, (addr[depth/8]>>(7-depth%8))&1)
+	}
+	if node == r.nodeCount {
+		return nil, depth, nil
+	}
+	if node < r.nodeCount {
+		return nil, 0, errors.New("invalid search tree")
+	}
+	value, _, err := r.data.decode(int(node-r.nodeCount-16), 0)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid data: %v", err)
+	}
+	return value, depth, nil
+}
+
+// mmdbDecoder decodes the values of a data section, whose pointers are
+// offsets from its start.
+type mmdbDecoder struct {
+	buf []byte
+}
+
+// Types of MaxMind DB values.
+const (
+	mmdbExtended = iota
+	mmdbPointer
+	mmdbString
+	mmdbDouble
+	mmdbBytes
+	mmdbUint16
+	mmdbUint32
+	mmdbMap
+	mmdbInt32
+	mmdbUint64
+	mmdbUint128
+	mmdbArray
+	mmdbContainer
+	mmdbEndMarker
+	mmdbBool
+	mmdbFloat
+)
+
+// errMMDBTruncated reports a value that runs past the end of its section.
+var errMMDBTruncated = errors.New("value past the end of the data")
+
+// decode returns the value at offset and the offset after it. Maps decode
+// as map[string]interface{}, arrays as []interface{}, unsigned integers as
+// uint64 or, for 128 bits, *big.Int, signed ones as int64, and floats as
+// float64.
+func (d mmdbDecoder) decode(offset, depth int) (interface{}, int, error) {
+	if depth > mmdbMaxDepth {
+		return nil, 0, errors.New("values nested too deeply")
+	}
+	if offset < 0 || offset >= len(d.buf) {
+		return nil, 0, errMMDBTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := int(ctrl >> 5)
+	if typ == mmdbPointer {
+		size := int(ctrl>>3) & 3
+		if offset+size+1 > len(d.buf) {
+			return nil, 0, errMMDBTruncated
+		}
+		var target int
+		b := d.buf[offset : offset+size+1]
+		switch size {
+		case 0:
+			target = int(ctrl&7)<<8 | int(b[0])
+		case 1:
+			target = (int(ctrl&7)<<16 | int(b[0])<<8 | int(b[1])) + 2048
+		case 2:
+			target = (int(ctrl&7)<<24 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])) + 526336
+		case 3:
+			target = int(binary.BigEndian.Uint32(b))
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, offset + size + 1, err
+	}
+	if typ == mmdbExtended {
+		if offset >= len(d.buf) {
+			return nil, 0, errMMDBTruncated
+		}
+		typ = 7 + int(d.buf[offset])
+		offset++
+	}
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > len(d.buf) {
+			return nil, 0, errMMDBTruncated
+		}
+		extra := 0
+		for _, b := range d.buf[offset : offset+n] {
+			extra = extra<<8 | int(b)
+		}
+		size = []int{29, 285, 65821}[n-1] + extra
+		offset += n
+	}
+
+	switch typ {
+	case mmdbMap:
+		m := make(map[string]interface{}, min(size, 64))
+		for i := 0; i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			if m[k], offset, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case mmdbArray:
+		a := make([]interface{}, 0, min(size, 64))
+		for i := 0; i < size; i++ {
+			v, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case mmdbBool:
+		return size != 0, offset, nil
+	case mmdbContainer, mmdbEndMarker:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, errMMDBTruncated
+	}
+	b := d.buf[offset : offset+size]
+	offset += size
+	switch typ {
+	case mmdbString:
+		return string(b), offset, nil
+	case mmdbBytes:
+		return append([]byte(nil), b...), offset, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, errors.New("double of the wrong size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, errors.New("float of the wrong size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64, mmdbInt32:
+		if size > []int{mmdbUint16: 2, mmdbUint32: 4, mmdbInt32: 4, mmdbUint64: 8}[typ] {
+			return nil, 0, errors.New("integer of the wrong size")
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		if typ == mmdbInt32 {
+			return int64(int32(uint32(v))), offset, nil
+		}
+		return v, offset, nil
+	case mmdbUint128:
+		if size > 16 {
+			return nil, 0, errors.New("integer of the wrong size")
+		}
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+	return nil, 0, fmt.Errorf("unknown data type %d", typ)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// encodeMMDBValue appends v in the MaxMind DB data format: maps with
+// sorted keys, arrays, strings, bools, float64s as doubles, uint64s as the
+// smallest unsigned type that fits, and int32s.
+func encodeMMDBValue(b []byte, v interface{}) []byte {
+	control := func(b []byte, typ, size int) []byte {
+		var ctrl byte
+		var ext []byte
+		if typ > 7 {
+			ext = []byte{byte(typ - 7)}
+		} else {
+			ctrl = byte(typ) << 5
+		}
+		switch {
+		case size < 29:
+			ctrl |= byte(size)
+		case size < 285:
+			ctrl |= 29
+			ext = append(ext, byte(size-29))
+		case size < 65821:
+			ctrl |= 30
+			ext = binary.BigEndian.AppendUint16(ext, uint16(size-285))
+		default:
+			ctrl |= 31
+			s := size - 65821
+			ext = append(ext, byte(s>>16), byte(s>>8), byte(s))
+		}
+		return append(append(b, ctrl), ext...)
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = control(b, mmdbMap, len(v))
+		for _, k := range keys {
+			b = encodeMMDBValue(b, k)
+			b = encodeMMDBValue(b, v[k])
+		}
+	case []interface{}:
+		b = control(b, mmdbArray, len(v))
+		for _, e := range v {
+			b = encodeMMDBValue(b, e)
+		}
+	case string:
+		b = append(control(b, mmdbString, len(v)), v...)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		b = control(b, mmdbBool, size)
+	case float64:
+		b = binary.BigEndian.AppendUint64(control(b, mmdbDouble, 8), math.Float64bits(v))
+	case int32:
+		b = binary.BigEndian.AppendUint32(control(b, mmdbInt32, 4), uint32(v))
+	case uint64:
+		var digits []byte
+		for ; v > 0; v >>= 8 {
+			digits = append([]byte{byte(v)}, digits...)
+		}
+		typ := mmdbUint64
+		if len(digits) <= 2 {
+			typ = mmdbUint16
+		} else if len(digits) <= 4 {
+			typ = mmdbUint32
+		}
+		b = append(control(b, typ, len(digits)), digits...)
+	default:
+		panic("unsupported type")
+	}
+	return b
+}
+
+// encodeMMDBPointer returns a pointer to offset in the data section.
+func encodeMMDBPointer(offset int) []byte {
+	switch {
+	case offset < 2048:
+		return []byte{1<<5 | byte(offset>>8), byte(offset)}
+	case offset < 526336:
+		o := offset - 2048
+		return []byte{1<<5 | 1<<3 | byte(o>>16), byte(o >> 8), byte(o)}
+	}
+	o := offset - 526336
+	return []byte{1<<5 | 2<<3 | byte(o>>24), byte(o >> 16), byte(o >> 8), byte(o)}
+}
+
+// testMMDBNetwork is a network and its data in a test database.
+type testMMDBNetwork struct {
+	prefix string
+	data   map[string]interface{}
+}
+
+// buildTestMMDB returns an IPv6 MaxMind DB with the given record size that
+// maps the networks to their data. IPv4 networks are stored under ::/96.
+func buildTestMMDB(t *testing.T, recordSize int, dbType string, networks []testMMDBNetwork) []byte {
+	t.Helper()
+	// A trie whose nodes have two children: another node's index, or
+	// -1 for nothing, or -2-i for the data of network i.
+	type node [2]int
+	nodes := []node{{-1, -1}}
+	for i, n := range networks {
+		p := netip.MustParsePrefix(n.prefix)
+		addr, bits := p.Addr(), p.Bits()
+		if addr.Is4() {
+			// As16 maps IPv4 into ::ffff:0:0/96; the database keeps it
+			// under ::/96.
+			a := addr.As16()
+			a[10], a[11] = 0, 0
+			addr, bits = netip.AddrFrom16(a), bits+96
+		}
+		a := addr.As16()
+		cur := 0
+		for depth := 0; depth < bits; depth++ {
+			bit := (a[depth/8] >> (7 - depth%8)) & 1
+			if depth == bits-1 {
+				nodes[cur][bit] = -2 - i
+				break
+			}
+			if nodes[cur][bit] < 0 {
+				nodes = append(nodes, node{-1, -1})
+				nodes[cur][bit] = len(nodes) - 1
+			}
+			cur = nodes[cur][bit]
+		}
+	}
+
+	// The records point to pointers to the data, so that lookups follow
+	// pointers too.
+	var data []byte
+	offsets := make([]int, len(networks))
+	for i, n := range networks {
+		start := len(data)
+		data = encodeMMDBValue(data, n.data)
+		offsets[i] = len(data)
+		data = append(data, encodeMMDBPointer(start)...)
+	}
+
+	count := len(nodes)
+	recordValue := func(child int) uint64 {
+		switch {
+		case child == -1:
+			return uint64(count)
+		case child <= -2:
+			return uint64(count + 16 + offsets[-2-child])
+		}
+		return uint64(child)
+	}
+	var tree []byte
+	for _, n := range nodes {
+		l, r := recordValue(n[0]), recordValue(n[1])
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			tree = append(tree, byte(l>>16), byte(l>>8), byte(l), byte(l>>24)<<4|byte(r>>24)&0x0f, byte(r>>16), byte(r>>8), byte(r))
+		case 32:
+			tree = binary.BigEndian.AppendUint32(tree, uint32(l))
+			tree = binary.BigEndian.AppendUint32(tree, uint32(r))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, 16))
+	buf.Write(data)
+	buf.Write(mmdbMetadataMarker)
+	buf.Write(encodeMMDBValue(nil, map[string]interface{}{
+		"binary_format_major_version": uint64(2),
+		"binary_format_minor_version": uint64(0),
+		"build_epoch":                 uint64(1741608000),
+		"database_type":               dbType,
+		"ip_version":                  uint64(6),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint64(count),
+		"record_size":                 uint64(recordSize),
+	}))
+	return buf.Bytes()
+}
+
+// testCityNetworks are the networks of a small City database.
+var testCityNetworks = []testMMDBNetwork{
+	{"81.2.69.0/24", map[string]interface{}{
+		"city":    map[string]interface{}{"names": map[string]interface{}{"en": "London"}},
+		"country": map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom"}},
+		"location": map[string]interface{}{
+			"latitude": 51.5142, "longitude": -0.0931, "accuracy_radius": uint64(10), "time_zone": "Europe/London",
+		},
+	}},
+	{"2001:db8::/32", map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "SE", "names": map[string]interface{}{"en": "Sweden"}},
+	}},
+}
+
+// Test looking addresses up with each record size
+func TestMMDBLookup(t *testing.T) {
+	for _, size := range []int{24, 28, 32} {
+		r, err := newMMDBReader(buildTestMMDB(t, size, "Test-City", testCityNetworks))
+		if err != nil {
+			t.Fatalf("record size %d: %v", size, err)
+		}
+		if r.databaseType != "Test-City" || r.ipVersion != 6 || r.buildEpoch != 1741608000 {
+			t.Errorf("record size %d: unexpected metadata %+v", size, r)
+		}
+		value, bits, err := r.lookup(netip.MustParseAddr("81.2.69.160"))
+		if err != nil || bits != 24 {
+			t.Fatalf("record size %d: unexpected lookup %v, %d, %v", size, value, bits, err)
+		}
+		if !reflect.DeepEqual(value, testCityNetworks[0].data) {
+			t.Errorf("record size %d: expected %v, got %v", size, testCityNetworks[0].data, value)
+		}
+		if value, _, err := r.lookup(netip.MustParseAddr("::ffff:81.2.69.1")); err != nil || value == nil {
+			t.Errorf("record size %d: expected mapped IPv4 addresses to be found, got %v, %v", size, value, err)
+		}
+		if value, bits, err := r.lookup(netip.MustParseAddr("2001:db8::1")); err != nil || bits != 32 || value == nil {
+			t.Errorf("record size %d: unexpected IPv6 lookup %v, %d, %v", size, value, bits, err)
+		}
+		for _, ip := range []string{"81.2.70.1", "8.8.8.8", "2001:db9::1"} {
+			if value, _, err := r.lookup(netip.MustParseAddr(ip)); err != nil || value != nil {
+				t.Errorf("record size %d: %s: expected nothing, got %v, %v", size, ip, value, err)
+			}
+		}
+	}
+}
+
+// Test decoding each type of value, pointers, and long sizes
+func TestMMDBDecode(t *testing.T) {
+	str := string(bytes.Repeat([]byte("x"), 300))
+	value := map[string]interface{}{
+		"array":  []interface{}{uint64(1), uint64(70000), uint64(1 << 40)},
+		"bool":   true,
+		"double": 1.5,
+		"int":    int32(-5),
+		"long":   str,
+		"nested": map[string]interface{}{"a": false},
+	}
+	data := encodeMMDBValue(nil, value)
+	got, next, err := mmdbDecoder{data}.decode(0, 0)
+	if err != nil || next != len(data) {
+		t.Fatalf("unexpected decode %v, %d, %v", got, next, err)
+	}
+	value["int"] = int64(-5)
+	if !reflect.DeepEqual(got, value) {
+		t.Errorf("expected %v, got %v", value, got)
+	}
+
+	for _, offset := range []int{5, 3000, 600000} {
+		data := make([]byte, offset)
+		data = encodeMMDBValue(data, "target")
+		start := len(data)
+		data = append(data, encodeMMDBPointer(offset)...)
+		if got, next, err := (mmdbDecoder{data}).decode(start, 0); err != nil || got != "target" || next != len(data) {
+			t.Errorf("pointer to %d: unexpected decode %v, %d, %v", offset, got, next, err)
+		}
+	}
+
+	uint128 := append([]byte{16, mmdbUint128 - 7}, bytes.Repeat([]byte{0xff}, 16)...)
+	if got, _, err := (mmdbDecoder{uint128}).decode(0, 0); err != nil || got.(*big.Int).BitLen() != 128 {
+		t.Errorf("unexpected uint128 %v, %v", got, err)
+	}
+
+	loop := encodeMMDBPointer(0)
+	for _, bad := range [][]byte{{}, {0x5f}, loop, {0xe1}, {0x43, 'a'}} {
+		if _, _, err := (mmdbDecoder{bad}).decode(0, 0); err == nil {
+			t.Errorf("%x: expected an error", bad)
+		}
+	}
+	if _, err := newMMDBReader([]byte("not a database")); err == nil {
+		t.Error("expected an error for a file without metadata")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// mockPlaceholder matches the {{argument}} placeholders of a mock
+// response. Dotted paths such as {{user.name}} reach into object
+// arguments.
+var mockPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*(?:\.[A-Za-z_][A-Za-z0-9_-]*)*)\s*\}\}`)
+
+// mockToolFile is the JSON or YAML file read by --mock-tools.
+type mockToolFile struct {
+	Tools []mockToolConfig `json:"tools"`
+}
+
+// mockResponse is what a mock tool answers a call with: the text of
+// Response, or the canned Content, with placeholders in their text
+// replaced by the call's arguments.
+type mockResponse struct {
+	Response string        `json:"response"`
+	Content  []ToolContent `json:"content"` // e.g. an image; used instead of response
+	IsError  bool          `json:"isError"` // answer with a failed result
+}
+
+// mockCase is a response given only to calls whose arguments have the
+// values in Match.
+type mockCase struct {
+	Match map[string]interface{} `json:"match"`
+	mockResponse
+}
+
+// mockToolConfig defines a tool that is listed like a real one but only
+// answers with canned responses. The first case matching a call's
+// arguments answers it, and the tool's own response the calls no case
+// matches.
+type mockToolConfig struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"` // defaults to an object with any properties
+	Annotations *ToolAnnotations       `json:"annotations"`
+	Delay       duration               `json:"delay"` // simulated latency of each call
+	Cases       []mockCase             `json:"cases"`
+	ToolVersion                        // version, deprecated, and replacedBy
+	mockResponse
+}
+
+// validate reports missing fields.
+func (c *mockToolConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("mock tool without a name")
+	}
+	if c.Response == "" && len(c.Content) == 0 && len(c.Cases) == 0 {
+		return fmt.Errorf("mock tool %q: no response, content, or cases", c.Name)
+	}
+	for i, mc := range c.Cases {
+		if mc.Response == "" && len(mc.Content) == 0 {
+			return fmt.Errorf("mock tool %q: case %d has no response or content", c.Name, i+1)
+		}
+	}
+	return nil
+}
+
+// loadMockTools reads the tools defined in the file at path, which is YAML
+// if its name ends in .yaml or .yml and JSON otherwise.
+func loadMockTools(path string) ([]MCPTool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file mockToolFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = decodeYAML(string(data), &file)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(file.Tools) == 0 {
+		return nil, fmt.Errorf("%s: no tools defined", path)
+	}
+	mocks := make([]MCPTool, 0, len(file.Tools))
+	for _, cfg := range file.Tools {
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		mocks = append(mocks, &mockTool{cfg: cfg})
+	}
+	return mocks, nil
+}
+
+// mockTool answers calls with the canned responses of its configuration
+// without executing anything, so that hosts can be developed against a
+// realistic tool list before the tools exist.
+type mockTool struct {
+	cfg mockToolConfig
+}
+
+// Name returns the configured tool name.
+func (m *mockTool) Name() string {
+	return m.cfg.Name
+}
+
+// Description returns the configured description.
+func (m *mockTool) Description() string {
+	return m.cfg.Description
+}
+
+// InputSchema returns the configured schema.
+func (m *mockTool) InputSchema() map[string]interface{} {
+	if m.cfg.InputSchema == nil {
+		return map[string]interface{}{"type": "object"}
+	}
+	return m.cfg.InputSchema
+}
+
+// Annotations returns the configured annotations.
+func (m *mockTool) Annotations() ToolAnnotations {
+	if m.cfg.Annotations == nil {
+		return ToolAnnotations{}
+	}
+	return *m.cfg.Annotations
+}
+
+// Version returns the configured version and deprecation.
+func (m *mockTool) Version() ToolVersion {
+	return m.cfg.ToolVersion
+}
+
+// Execute answers the call without a deadline.
+func (m *mockTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return m.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext waits for the configured delay, unless ctx is done first,
+// and answers the call.
+func (m *mockTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	if d := time.Duration(m.cfg.Delay); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	resp := m.cfg.mockResponse
+	for _, mc := range m.cfg.Cases {
+		if mockMatches(mc.Match, args) {
+			resp = mc.mockResponse
+			break
+		}
+	}
+	if resp.Response == "" && len(resp.Content) == 0 {
+		return nil, toolFailure("no canned response of %s matches the arguments", m.cfg.Name)
+	}
+	content := []ToolContent{{Type: "text", Text: expandMock(resp.Response, args)}}
+	if len(resp.Content) > 0 {
+		content = make([]ToolContent, len(resp.Content))
+		for i, c := range resp.Content {
+			c.Text = expandMock(c.Text, args)
+			content[i] = c
+		}
+	}
+	if resp.IsError {
+		return nil, &toolResultError{content: content}
+	}
+	return content, nil
+}
+
+// mockMatches reports whether args has every value in match. Values are
+// compared by their JSON encoding, so that the integers read from YAML
+// match the float64 numbers of decoded arguments.
+func mockMatches(match, args map[string]interface{}) bool {
+	for name, want := range match {
+		if got, ok := args[name]; !ok || !jsonEqual(want, got) {
+			return false
+		}
+	}
+	return true
+}
+
+// expandMock replaces each placeholder in text with the argument it names:
+// strings as they are, other values as JSON, and missing ones with nothing.
+func expandMock(text string, args map[string]interface{}) string {
+	return mockPlaceholder.ReplaceAllStringFunc(text, func(ph string) string {
+		var v interface{} = args
+		for _, key := range strings.Split(mockPlaceholder.FindStringSubmatch(ph)[1], ".") {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			if v, ok = obj[key]; !ok {
+				return ""
+			}
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	})
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockToolsYAML defines a weather tool answering by city and a failing
+// deployment tool.
+const mockToolsYAML = `tools:
+  - name: get_weather
+    description: Looks up the current weather
+    inputSchema:
+      type: object
+      properties:
+        city:
+          type: string
+        days:
+          type: integer
+      required: [city]
+    annotations:
+      readOnlyHint: true
+    response: "{{city}}: sunny for {{ days }} days ({{missing}})"
+    cases:
+      - match:
+          city: Oslo
+          days: 2
+        response: "{{city}}: snow"
+  - name: deploy
+    isError: true
+    response: deployment of {{target.name}} failed
+`
+
+// Test that mock tools are listed as defined and answer with their canned
+// responses
+func TestMockTools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tools.yaml")
+	if err := os.WriteFile(path, []byte(mockToolsYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mocks, err := loadMockTools(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mocks) != 2 || mocks[0].Name() != "get_weather" || !isReadOnly(mocks[0]) {
+		t.Fatalf("expected the two mock tools, got %v", mocks)
+	}
+	if props := mocks[0].InputSchema()["properties"].(map[string]interface{}); props["days"] == nil {
+		t.Errorf("expected the configured schema, got %v", mocks[0].InputSchema())
+	}
+
+	for _, tc := range []struct {
+		args map[string]interface{}
+		text string
+	}{
+		{map[string]interface{}{"city": "Rome", "days": float64(3)}, "Rome: sunny for 3 days ()"},
+		{map[string]interface{}{"city": "Oslo", "days": float64(2)}, "Oslo: snow"},
+		{map[string]interface{}{"city": "Oslo"}, "Oslo: sunny for  days ()"},
+	} {
+		content, err := mocks[0].Execute(tc.args)
+		if err != nil || len(content) != 1 || content[0].Text != tc.text {
+			t.Errorf("Execute(%v) = %v, %v, expected %q", tc.args, content, err, tc.text)
+		}
+	}
+
+	_, err = mocks[1].Execute(map[string]interface{}{"target": map[string]interface{}{"name": "prod"}})
+	var failure *toolResultError
+	if !errors.As(err, &failure) || failure.Error() != "deployment of prod failed" {
+		t.Errorf("expected a failed result, got %v", err)
+	}
+}
+
+// Test that a mock tool's delay is cut short by cancellation
+func TestMockToolDelay(t *testing.T) {
+	tool := &mockTool{cfg: mockToolConfig{Name: "slow", Delay: duration(time.Hour), mockResponse: mockResponse{Response: "done"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := tool.ExecuteContext(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a deadline error, got %v", err)
+	}
+}
+
+// Test that --mock-tools replaces the built-in tools and rejects
+// incomplete definitions
+func TestLoadConfigMockTools(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tools.json")
+	data := `{"tools":[{"name":"search","content":[{"type":"text","text":"results for {{q}}"}]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig([]string{"--mock-tools", path}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := cfg.serverOptions(slog.Default(), nil)
+	if err != nil {
+		t.Fatalf("serverOptions error: %v", err)
+	}
+	s := NewServer(opts...)
+	if len(s.tools) != 1 || s.tools[0].Name() != "search" {
+		t.Errorf("expected only the mock tool, got %d tools", len(s.tools))
+	}
+
+	for name, data := range map[string]string{
+		"empty.json":   `{"tools":[]}`,
+		"unnamed.json": `{"tools":[{"response":"x"}]}`,
+		"silent.json":  `{"tools":[{"name":"x"}]}`,
+		"case.json":    `{"tools":[{"name":"x","cases":[{"match":{"a":1}}]}]}`,
+		"typo.json":    `{"tools":[{"name":"x","respone":"x"}]}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadMockTools(path); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("expected an error naming %s, got %v", name, err)
+		}
+	}
+}
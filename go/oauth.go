@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthConfig configures bearer token authorization for the HTTP transport
+// as described by the MCP authorization specification.
+type oauthConfig struct {
+	Issuer     string              `json:"issuer"`     // expected "iss" of access tokens
+	JWKSURL    string              `json:"jwksURL"`    // defaults to the jwks_uri of the issuer's metadata
+	Resource   string              `json:"resource"`   // canonical URL of this server, expected in "aud"
+	ScopeTools map[string][]string `json:"scopeTools"` // scope to the tool name patterns it grants
+}
+
+// validate reports missing fields.
+func (c *oauthConfig) validate() error {
+	if c.Issuer == "" || c.Resource == "" {
+		return errors.New("oauth: issuer and resource are required")
+	}
+	if _, err := url.Parse(c.Resource); err != nil {
+		return fmt.Errorf("oauth: invalid resource: %w", err)
+	}
+	for scope, patterns := range c.ScopeTools {
+		if err := validateToolPatterns(patterns); err != nil {
+			return fmt.Errorf("oauth: scope %q: %w", scope, err)
+		}
+	}
+	return nil
+}
+
+// oauthVerifier validates access tokens: JWTs signed with a key from the
+// issuer's JWKS. Keys are fetched on first use and refetched when a token
+// names an unknown key, at most once per minute.
+type oauthVerifier struct {
+	cfg    oauthConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // by key ID
+	fetchedAt time.Time
+	fetching  chan struct{} // closed when the running fetch ends
+	fetchErr  error         // of the last fetch
+}
+
+// newOAuthVerifier returns a verifier for cfg.
+func newOAuthVerifier(cfg oauthConfig) *oauthVerifier {
+	return &oauthVerifier{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}, now: time.Now}
+}
+
+// tokenClaims are the validated claims of an access token.
+type tokenClaims struct {
+	Subject string
+	Scopes  []string
+}
+
+// errInvalidToken is wrapped by every token validation error.
+var errInvalidToken = errors.New("invalid token")
+
+// errNoToken is returned for requests without a bearer token. It wraps
+// errInvalidToken, but the challenge reports no error for it, as RFC 6750
+// asks.
+var errNoToken = fmt.Errorf("%w: missing bearer token", errInvalidToken)
+
+// errKeysUnavailable is wrapped by failures to fetch the signing keys,
+// which say nothing about the token.
+var errKeysUnavailable = errors.New("signing keys unavailable")
+
+// authenticate validates the bearer token of r.
+func (v *oauthVerifier) authenticate(r *http.Request) (*tokenClaims, error) {
+	auth := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, errNoToken
+	}
+	return v.verify(r.Context(), token)
+}
+
+// verify checks the signature, issuer, audience, and lifetime of token.
+func (v *oauthVerifier) verify(ctx context.Context, token string) (*tokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JWT", errInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", errInvalidToken)
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims struct {
+		Iss   string          `json:"iss"`
+		Sub   string          `json:"sub"`
+		Aud   json.RawMessage `json:"aud"`
+		Exp   *float64        `json:"exp"`
+		Nbf   *float64        `json:"nbf"`
+		Scope string          `json:"scope"`
+		Scp   []string        `json:"scp"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	now := float64(v.now().Unix())
+	switch {
+	case claims.Iss != v.cfg.Issuer:
+		return nil, fmt.Errorf("%w: unexpected issuer", errInvalidToken)
+	case !audienceContains(claims.Aud, v.cfg.Resource):
+		return nil, fmt.Errorf("%w: token is not for this resource", errInvalidToken)
+	case claims.Exp == nil || now >= *claims.Exp:
+		return nil, fmt.Errorf("%w: token expired", errInvalidToken)
+	case claims.Nbf != nil && now < *claims.Nbf:
+		return nil, fmt.Errorf("%w: token not yet valid", errInvalidToken)
+	}
+	scopes := strings.Fields(claims.Scope)
+	if len(scopes) == 0 {
+		scopes = claims.Scp
+	}
+	return &tokenClaims{Subject: claims.Sub, Scopes: scopes}, nil
+}
+
+// toolFilter returns the filter granting the tools of the given scopes.
+// Without scopeTools every tool is granted.
+func (v *oauthVerifier) toolFilter(scopes []string) *toolFilter {
+	if v.cfg.ScopeTools == nil {
+		return nil
+	}
+	var allow []string
+	for _, scope := range scopes {
+		allow = append(allow, v.cfg.ScopeTools[scope]...)
+	}
+	if len(allow) == 0 {
+		return &toolFilter{deny: []string{"*"}}
+	}
+	return &toolFilter{allow: allow}
+}
+
+// metadata returns the OAuth protected resource metadata (RFC 9728).
+func (v *oauthVerifier) metadata() map[string]interface{} {
+	scopes := sortedKeys(v.cfg.ScopeTools)
+	md := map[string]interface{}{
+		"resource":                 v.cfg.Resource,
+		"authorization_servers":    []string{v.cfg.Issuer},
+		"bearer_methods_supported": []string{"header"},
+	}
+	if len(scopes) > 0 {
+		md["scopes_supported"] = scopes
+	}
+	return md
+}
+
+// metadataPath returns the well-known path of the resource metadata for
+// the configured resource, e.g. /.well-known/oauth-protected-resource/mcp.
+func (v *oauthVerifier) metadataPath() string {
+	u, _ := url.Parse(v.cfg.Resource)
+	return "/.well-known/oauth-protected-resource" + strings.TrimSuffix(u.Path, "/")
+}
+
+// challenge writes a 401 response pointing the client to the metadata.
+func (v *oauthVerifier) challenge(w http.ResponseWriter, r *http.Request, err error) {
+	u, _ := url.Parse(v.cfg.Resource)
+	metadataURL := u.Scheme + "://" + u.Host + v.metadataPath()
+	header := fmt.Sprintf("Bearer resource_metadata=%q", metadataURL)
+	if !errors.Is(err, errNoToken) {
+		header += fmt.Sprintf(`, error="invalid_token", error_description=%q`,
+			strings.TrimPrefix(err.Error(), errInvalidToken.Error()+": "))
+	}
+	w.Header().Set("WWW-Authenticate", header)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// ServeHTTP serves the protected resource metadata.
+func (v *oauthVerifier) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v.metadata())
+}
+
+// key returns the verification key with the given ID. Concurrent callers
+// share one fetch, which runs without holding v.mu, so lookups of known
+// keys never wait on the network.
+func (v *oauthVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.lookup(kid)
+	if !ok && (v.keys == nil || v.now().Sub(v.fetchedAt) > time.Minute) {
+		done := v.fetching
+		if done == nil {
+			done = make(chan struct{})
+			v.fetching = done
+			go v.refresh(context.WithoutCancel(ctx), done)
+		}
+		v.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			v.mu.Lock()
+			return nil, ctx.Err()
+		}
+		v.mu.Lock()
+		if v.fetchErr != nil && v.keys == nil {
+			return nil, fmt.Errorf("%w: %v", errKeysUnavailable, v.fetchErr)
+		}
+		key, ok = v.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown signing key", errInvalidToken)
+	}
+	return key, nil
+}
+
+// refresh fetches the keys, swaps them in, and closes done.
+func (v *oauthVerifier) refresh(ctx context.Context, done chan struct{}) {
+	keys, err := v.fetchKeys(ctx)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fetchErr = err
+	if err == nil {
+		v.keys = keys
+	}
+	if err == nil || v.keys != nil {
+		// A failed refetch keeps the old keys and waits as long as a
+		// successful one before trying again.
+		v.fetchedAt = v.now()
+	}
+	v.fetching = nil
+	close(done)
+}
+
+// lookup finds the key with the given ID. Tokens without a key ID match
+// the only key of a single-key set.
+func (v *oauthVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+// fetchKeys downloads the JWKS, discovering its URL from the issuer's
+// authorization server metadata if none is configured.
+func (v *oauthVerifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := v.cfg.JWKSURL
+	if jwksURL == "" {
+		var md struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		base := strings.TrimSuffix(v.cfg.Issuer, "/")
+		for _, suffix := range []string{"/.well-known/oauth-authorization-server", "/.well-known/openid-configuration"} {
+			if err := v.getJSON(ctx, base+suffix, &md); err == nil && md.JWKSURI != "" {
+				break
+			}
+		}
+		if md.JWKSURI == "" {
+			return nil, errors.New("no jwks_uri in the issuer's metadata")
+		}
+		jwksURL = md.JWKSURI
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, jwksURL, &set); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// getJSON fetches url and decodes the JSON response into v.
+func (v *oauthVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonWebKey is an RSA or EC public key in JWK form.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKey converts the JWK into a crypto public key.
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	num := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("malformed key")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := num(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := num(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("malformed key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := num(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := num(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifySignature checks a JWS signature made with alg.
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", errInvalidToken, alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	ok := false
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch {
+		case strings.HasPrefix(alg, "RS"):
+			ok = rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+		case strings.HasPrefix(alg, "PS"):
+			ok = rsa.VerifyPSS(k, hash, digest, sig, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if strings.HasPrefix(alg, "ES") && len(sig) == 2*size {
+			r := new(big.Int).SetBytes(sig[:size])
+			s := new(big.Int).SetBytes(sig[size:])
+			ok = ecdsa.Verify(k, digest, r, s)
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%w: bad signature", errInvalidToken)
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT.
+func decodeSegment(seg string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil || json.Unmarshal(data, v) != nil {
+		return fmt.Errorf("%w: malformed token", errInvalidToken)
+	}
+	return nil
+}
+
+// audienceContains reports whether the "aud" claim, a string or an array
+// of strings, contains resource.
+func audienceContains(aud json.RawMessage, resource string) bool {
+	var one string
+	if json.Unmarshal(aud, &one) == nil {
+		return one == resource
+	}
+	var many []string
+	if json.Unmarshal(aud, &many) == nil {
+		for _, a := range many {
+			if a == resource {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testIssuer is an authorization server with one RSA and one EC key.
+type testIssuer struct {
+	*httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+}
+
+// newTestIssuer starts an issuer serving its metadata and JWKS.
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testIssuer{rsaKey: rsaKey, ecKey: ecKey}
+	b64 := func(n *big.Int, size int) string {
+		return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, size)))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": iss.URL, "jwks_uri": iss.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa", "n": b64(rsaKey.N, 256), "e": "AQAB"},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X, 32), "y": b64(ecKey.Y, 32)},
+		}})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+// token returns a JWT with claims signed by the key kid.
+func (iss *testIssuer) token(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	alg := map[string]string{"rsa": "RS256", "ec": "ES256"}[kid]
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var sig []byte
+	if kid == "ec" {
+		r, s, err := ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	} else {
+		var err error
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKey, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// claims returns valid claims for resource with the given scope.
+func (iss *testIssuer) claims(resource, scope string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": iss.URL, "sub": "alice", "aud": resource, "scope": scope,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+// Test token validation
+func TestOAuthVerify(t *testing.T) {
+	iss := newTestIssuer(t)
+	const resource = "https://mcp.example.com/mcp"
+	v := newOAuthVerifier(oauthConfig{Issuer: iss.URL, Resource: resource})
+	ctx := context.Background()
+
+	for _, kid := range []string{"rsa", "ec"} {
+		claims, err := v.verify(ctx, iss.token(t, kid, iss.claims(resource, "read write")))
+		if err != nil {
+			t.Fatalf("%s: verify error: %v", kid, err)
+		}
+		if claims.Subject != "alice" || strings.Join(claims.Scopes, " ") != "read write" {
+			t.Errorf("%s: unexpected claims %+v", kid, claims)
+		}
+	}
+
+	bad := map[string]string{}
+	c := iss.claims("https://other.example.com/mcp", "")
+	bad["audience"] = iss.token(t, "rsa", c)
+	c = iss.claims(resource, "")
+	c["exp"] = time.Now().Add(-time.Minute).Unix()
+	bad["expired"] = iss.token(t, "rsa", c)
+	c = iss.claims(resource, "")
+	c["iss"] = "https://evil.example.com"
+	bad["issuer"] = iss.token(t, "ec", c)
+	good := iss.token(t, "rsa", iss.claims(resource, ""))
+	bad["signature"] = good[:len(good)-4] + "AAAA"
+	bad["garbage"] = "not-a-token"
+	for name, token := range bad {
+		if _, err := v.verify(ctx, token); !errors.Is(err, errInvalidToken) {
+			t.Errorf("%s: expected an invalid token error, got %v", name, err)
+		}
+	}
+}
+
+// Test that the HTTP transport requires tokens and maps scopes to tools
+func TestOAuthHTTPTransport(t *testing.T) {
+	iss := newTestIssuer(t)
+	const resource = "https://mcp.example.com/mcp"
+	tr := newHTTPTransport(NewServer(), nil)
+	tr.auth = newOAuthVerifier(oauthConfig{
+		Issuer:     iss.URL,
+		Resource:   resource,
+		ScopeTools: map[string][]string{"text": {"echo", "count_*"}},
+	})
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+
+	post := func(token, sessionID, body string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if sessionID != "" {
+			req.Header.Set(sessionIDHeader, sessionID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	resp := post("bogus", "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a bad token, got %d", resp.StatusCode)
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if !strings.Contains(challenge, `resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource/mcp"`) {
+		t.Errorf("unexpected challenge %q", challenge)
+	}
+
+	token := iss.token(t, "ec", iss.claims(resource, "text"))
+	resp = post(token, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`)
+	sessionID := resp.Header.Get(sessionIDHeader)
+	post(token, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	resp = post(token, sessionID, `{"jsonrpc":"2.0","method":"tools/list","id":2}`)
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"name":"count_text"`) || strings.Contains(string(body), `"name":"qr_code"`) {
+		t.Errorf("expected only the tools granted by the scope, got %s", body)
+	}
+
+	none := iss.token(t, "rsa", iss.claims(resource, "other"))
+	resp = post(none, sessionID, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":3}`)
+	body, _ = io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"code":-32601`) {
+		t.Errorf("expected the tool to be unavailable without its scope, got %s", body)
+	}
+
+	// Ending a session needs a token as well.
+	end := func(token string) int {
+		req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(sessionIDHeader, sessionID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := end("bogus"); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a DELETE with a bad token, got %d", code)
+	}
+	if resp := post(token, sessionID, `{"jsonrpc":"2.0","method":"ping","id":4}`); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the session to survive the unauthorized DELETE, got %d", resp.StatusCode)
+	}
+	if code := end(token); code != http.StatusNoContent {
+		t.Errorf("expected 204 for a DELETE with a token, got %d", code)
+	}
+
+	var md map[string]interface{}
+	rec := httptest.NewRecorder()
+	tr.auth.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tr.auth.metadataPath(), nil))
+	if err := json.Unmarshal(rec.Body.Bytes(), &md); err != nil || md["resource"] != resource {
+		t.Errorf("unexpected metadata %s", rec.Body)
+	}
+}
+
+// Test the responses to requests that cannot be authorized
+func TestOAuthHTTPErrors(t *testing.T) {
+	iss := newTestIssuer(t)
+	const resource = "https://mcp.example.com/mcp"
+	var logBuf bytes.Buffer
+	post := func(auth *oauthVerifier, token, body string) *http.Response {
+		tr := newHTTPTransport(NewServer(WithMaxMessageSize(64), WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil)))), nil)
+		tr.auth = auth
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		tr.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+	initialize := `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`
+
+	v := newOAuthVerifier(oauthConfig{Issuer: iss.URL, Resource: resource})
+	resp := post(v, "", initialize)
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode != http.StatusUnauthorized || strings.Contains(challenge, "error=") {
+		t.Errorf("expected a challenge without an error for a missing token, got %d %q", resp.StatusCode, challenge)
+	}
+	if resp = post(v, "", strings.Repeat(" ", 1000)+initialize); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 before the size check, got %d", resp.StatusCode)
+	}
+
+	down := newOAuthVerifier(oauthConfig{Issuer: iss.URL, JWKSURL: iss.URL + "/missing", Resource: resource})
+	resp = post(down, iss.token(t, "rsa", iss.claims(resource, "")), initialize)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusServiceUnavailable || strings.Contains(string(body), "404") {
+		t.Errorf("expected a generic 503 when the keys cannot be fetched, got %d %s", resp.StatusCode, body)
+	}
+	if !strings.Contains(logBuf.String(), "404") {
+		t.Errorf("expected the fetch failure to be logged, got %q", logBuf.String())
+	}
+}
+
+// Test that known keys are served while unknown ones are being fetched
+func TestOAuthKeyFetch(t *testing.T) {
+	iss := newTestIssuer(t)
+	const resource = "https://mcp.example.com/mcp"
+	release := make(chan struct{})
+	var fetches atomic.Int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		http.NotFound(w, r)
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	v := newOAuthVerifier(oauthConfig{Issuer: iss.URL, JWKSURL: slow.URL, Resource: resource})
+	keys, err := newOAuthVerifier(oauthConfig{Issuer: iss.URL, Resource: resource}).fetchKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(keys, "ec")
+	v.keys = keys
+	ctx := context.Background()
+
+	unknown := iss.token(t, "ec", iss.claims(resource, ""))
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := v.verify(ctx, unknown)
+			errs <- err
+		}()
+	}
+	for fetches.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := v.verify(ctx, iss.token(t, "rsa", iss.claims(resource, ""))); err != nil {
+		t.Errorf("expected the known key while fetching, got %v", err)
+	}
+
+	release <- struct{}{}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, errInvalidToken) {
+			t.Errorf("expected the key to stay unknown, got %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected one shared fetch, got %d", n)
+	}
+}
@@ -0,0 +1,684 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// objectStorageConfig configures the object_get, object_list, and
+// object_put tools in the "objectStorage" section of the config file. The
+// credentials stay here, out of the tools' arguments.
+type objectStorageConfig struct {
+	Endpoint        string               `json:"endpoint"`        // such as https://s3.us-east-1.amazonaws.com
+	Region          string               `json:"region"`          // defaults to us-east-1
+	AccessKeyID     string               `json:"accessKeyId"`     // $VAR expands from the environment
+	SecretAccessKey string               `json:"secretAccessKey"` // $VAR expands from the environment
+	SessionToken    string               `json:"sessionToken"`    // $VAR expands; for temporary credentials
+	PathStyle       bool                 `json:"pathStyle"`       // puts the bucket in the path, as MinIO needs
+	Buckets         []objectBucketConfig `json:"buckets"`
+	MaxBytes        int                  `json:"maxBytes"` // of an object read or written, defaults to 1 MiB
+	MaxKeys         int                  `json:"maxKeys"`  // listed at a time, defaults to 100
+	Timeout         duration             `json:"timeout"`  // per call, defaults to 30s
+}
+
+// objectBucketConfig is a bucket the tools may use.
+type objectBucketConfig struct {
+	Name     string   `json:"name"`
+	Prefixes []string `json:"prefixes"` // the keys may start with, or the whole bucket if empty
+	Writable bool     `json:"writable"` // lets object_put write under the prefixes
+}
+
+// Defaults of the object storage tools.
+const (
+	defaultObjectRegion   = "us-east-1"
+	defaultObjectMaxBytes = 1 << 20
+	defaultObjectMaxKeys  = 100
+	defaultObjectTimeout  = 30 * time.Second
+	maxObjectListSize     = 4 << 20 // of a ListObjectsV2 response
+)
+
+// validate reports malformed fields.
+func (c *objectStorageConfig) validate() error {
+	u, err := url.Parse(os.ExpandEnv(c.Endpoint))
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("objectStorage: invalid endpoint %q", c.Endpoint)
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return errors.New("objectStorage: accessKeyId and secretAccessKey are required")
+	}
+	if len(c.Buckets) == 0 {
+		return errors.New("objectStorage: at least one bucket is required")
+	}
+	seen := map[string]bool{}
+	for _, b := range c.Buckets {
+		if b.Name == "" || strings.ContainsAny(b.Name, "/ ") {
+			return fmt.Errorf("objectStorage: invalid bucket name %q", b.Name)
+		}
+		if seen[b.Name] {
+			return fmt.Errorf("objectStorage: duplicate bucket %q", b.Name)
+		}
+		seen[b.Name] = true
+		for _, p := range b.Prefixes {
+			if p == "" || strings.HasPrefix(p, "/") {
+				return fmt.Errorf("objectStorage: bucket %q: invalid prefix %q", b.Name, p)
+			}
+		}
+	}
+	if c.MaxBytes < 0 || c.MaxKeys < 0 || c.Timeout < 0 {
+		return errors.New("objectStorage: maxBytes, maxKeys, and timeout must not be negative")
+	}
+	return nil
+}
+
+// objectStore is an S3-compatible service the object storage tools share,
+// signing their requests with AWS Signature Version 4.
+type objectStore struct {
+	cfg    objectStorageConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// newObjectStore returns the store for cfg.
+func newObjectStore(cfg objectStorageConfig) *objectStore {
+	if cfg.Region == "" {
+		cfg.Region = defaultObjectRegion
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultObjectMaxBytes
+	}
+	if cfg.MaxKeys == 0 {
+		cfg.MaxKeys = defaultObjectMaxKeys
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = duration(defaultObjectTimeout)
+	}
+	return &objectStore{cfg: cfg, client: &http.Client{}, now: time.Now}
+}
+
+// objectStorageTools returns the tools for cfg: object_put only when a
+// bucket is writable.
+func objectStorageTools(cfg objectStorageConfig) []MCPTool {
+	s := newObjectStore(cfg)
+	tools := []MCPTool{&objectGetTool{s}, &objectListTool{s}}
+	for _, b := range cfg.Buckets {
+		if b.Writable {
+			return append(tools, &objectPutTool{s})
+		}
+	}
+	return tools
+}
+
+// bucket returns the configured bucket named name.
+func (s *objectStore) bucket(name string, write bool) (*objectBucketConfig, error) {
+	if name == "" && len(s.cfg.Buckets) == 1 {
+		name = s.cfg.Buckets[0].Name
+	}
+	for i, b := range s.cfg.Buckets {
+		if b.Name != name {
+			continue
+		}
+		if write && !b.Writable {
+			return nil, fmt.Errorf("bucket %q is read-only", name)
+		}
+		return &s.cfg.Buckets[i], nil
+	}
+	return nil, fmt.Errorf("invalid value for 'bucket': expected one of %s", strings.Join(s.bucketNames(false), ", "))
+}
+
+// bucketNames returns the names of the configured buckets, only the
+// writable ones if write is set.
+func (s *objectStore) bucketNames(write bool) []string {
+	var names []string
+	for _, b := range s.cfg.Buckets {
+		if b.Writable || !write {
+			names = append(names, b.Name)
+		}
+	}
+	return names
+}
+
+// allowed reports whether key is under a prefix of b. Keys with . or ..
+// path segments are refused, since some services clean them up before
+// matching.
+func (b *objectBucketConfig) allowed(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return errors.New("invalid value for 'key'")
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "." || segment == ".." {
+			return errors.New("invalid value for 'key': no . or .. segments")
+		}
+	}
+	if len(b.Prefixes) == 0 {
+		return nil
+	}
+	for _, p := range b.Prefixes {
+		if strings.HasPrefix(key, p) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is outside the prefixes of bucket %q: %s", key, b.Name, strings.Join(b.Prefixes, ", "))
+}
+
+// objectURL returns the URL of key, or of the bucket if key is empty.
+func (s *objectStore) objectURL(bucket, key string, query url.Values) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(os.ExpandEnv(s.cfg.Endpoint), "/"))
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.PathStyle {
+		u.Path += "/" + bucket + "/" + key
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path += "/" + key
+	}
+	u.RawPath = s3Escape(u.Path, false)
+	u.RawQuery = s3Query(query)
+	return u, nil
+}
+
+// do sends a signed request and returns the response, failing on a status
+// other than 200 with the message of the service.
+func (s *objectStore) do(ctx context.Context, method string, u *url.URL, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	s.sign(req, body)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, toolFailure("object storage at %s: %v", u.Host, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var failure struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(data, &failure) == nil && failure.Code != "" {
+		return nil, toolFailure("object storage: %s: %s", failure.Code, failure.Message)
+	}
+	return nil, toolFailure("object storage: %s", resp.Status)
+}
+
+// sign adds the headers of AWS Signature Version 4 to req, whose body is
+// body. Host, x-amz-*, Content-Type, and Range headers are signed.
+func (s *objectStore) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	date := now.Format("20060102")
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if token := os.ExpandEnv(s.cfg.SessionToken); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		k = strings.ToLower(k)
+		if strings.HasPrefix(k, "x-amz-") || k == "content-type" || k == "range" {
+			headers[k] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, false),
+		s3Query(req.URL.Query()),
+		canonicalHeaders.String(),
+		signed,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := []byte("AWS4" + os.ExpandEnv(s.cfg.SecretAccessKey))
+	for _, part := range []string{date, s.cfg.Region, "s3", "aws4_request", toSign} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		os.ExpandEnv(s.cfg.AccessKeyID), scope, signed, hex.EncodeToString(key)))
+}
+
+// s3Escape percent-encodes s the way Signature Version 4 canonicalizes
+// it: every byte but letters, digits, "-._~", and, unless slash is set,
+// "/" is encoded.
+func s3Escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 || c == '/' && !slash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Query encodes query sorted by key, as Signature Version 4 signs it.
+func s3Query(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// objectGetArgs are the arguments of the object_get tool.
+type objectGetArgs struct {
+	Bucket string `json:"bucket,omitempty" description:"Bucket of the object (default the only one)"`
+	Key    string `json:"key" description:"Key of the object"`
+}
+
+// objectGetTool reads an object of an allowed bucket and prefix.
+type objectGetTool struct {
+	s *objectStore
+}
+
+// Name returns the name of the object_get tool.
+func (t *objectGetTool) Name() string {
+	return "object_get"
+}
+
+// Description returns a brief description of the object_get tool.
+func (t *objectGetTool) Description() string {
+	return fmt.Sprintf("Reads an object of up to %d bytes from object storage, as text or base64. Buckets: %s", t.s.cfg.MaxBytes, describeBuckets(t.s.cfg.Buckets, false))
+}
+
+// describeBuckets lists buckets with their prefixes, only the writable
+// ones if write is set.
+func describeBuckets(buckets []objectBucketConfig, write bool) string {
+	var parts []string
+	for _, b := range buckets {
+		if write && !b.Writable {
+			continue
+		}
+		if len(b.Prefixes) == 0 {
+			parts = append(parts, b.Name)
+		} else {
+			parts = append(parts, b.Name+" ("+strings.Join(b.Prefixes, ", ")+")")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// bucketSchema sets the configured bucket names as the enum of the bucket
+// property of schema.
+func (s *objectStore) bucketSchema(schema map[string]interface{}, write bool) map[string]interface{} {
+	schema["properties"].(map[string]interface{})["bucket"].(map[string]interface{})["enum"] = s.bucketNames(write)
+	return schema
+}
+
+// InputSchema returns the JSON schema for the object_get tool's input
+// parameters.
+func (t *objectGetTool) InputSchema() map[string]interface{} {
+	return t.s.bucketSchema(mcp.SchemaFor(objectGetArgs{}), false)
+}
+
+// Annotations marks the object_get tool as read-only.
+func (t *objectGetTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "Get object", ReadOnlyHint: true}
+}
+
+// Timeout returns the configured time limit of a call.
+func (t *objectGetTool) Timeout() time.Duration {
+	return time.Duration(t.s.cfg.Timeout)
+}
+
+// Execute reads the object without a deadline of its own.
+func (t *objectGetTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext reads the object and returns it embedded as a resource
+// whose URI is s3://bucket/key.
+func (t *objectGetTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a objectGetArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	b, err := t.s.bucket(a.Bucket, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.allowed(a.Key); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.s.cfg.Timeout))
+	defer cancel()
+	u, err := t.s.objectURL(b.Name, a.Key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.s.do(ctx, http.MethodGet, u, nil, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.ContentLength > int64(t.s.cfg.MaxBytes) {
+		return nil, toolFailure("%s is %d bytes, more than the limit of %d", a.Key, resp.ContentLength, t.s.cfg.MaxBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.s.cfg.MaxBytes)+1))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("object storage: %v", err)
+	}
+	if len(data) > t.s.cfg.MaxBytes {
+		return nil, toolFailure("%s is more than the limit of %d bytes", a.Key, t.s.cfg.MaxBytes)
+	}
+	uri := "s3://" + b.Name + "/" + a.Key
+	mimeType := resp.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if isTextMIMEType(mimeType) && utf8.Valid(data) {
+		return []ToolContent{mcp.NewResourceContent(uri, mimeType, string(data))}, nil
+	}
+	return []ToolContent{mcp.NewBlobResourceContent(uri, mimeType, data)}, nil
+}
+
+// objectListArgs are the arguments of the object_list tool.
+type objectListArgs struct {
+	Bucket            string `json:"bucket,omitempty" description:"Bucket to list (default the only one)"`
+	Prefix            string `json:"prefix,omitempty" description:"Prefix of the keys to list (default the bucket's only allowed prefix)"`
+	Delimiter         string `json:"delimiter,omitempty" description:"Groups keys up to this string, such as / for folders, into common prefixes"`
+	MaxKeys           int    `json:"max_keys,omitempty" minimum:"1" description:"Most keys to list (default the server's limit)"`
+	ContinuationToken string `json:"continuation_token,omitempty" description:"nextToken of the previous, truncated listing"`
+}
+
+// objectListTool lists the objects of an allowed bucket under an allowed
+// prefix.
+type objectListTool struct {
+	s *objectStore
+}
+
+// Name returns the name of the object_list tool.
+func (t *objectListTool) Name() string {
+	return "object_list"
+}
+
+// Description returns a brief description of the object_list tool.
+func (t *objectListTool) Description() string {
+	return fmt.Sprintf("Lists the objects in object storage under a prefix, %d at a time. Buckets: %s", t.s.cfg.MaxKeys, describeBuckets(t.s.cfg.Buckets, false))
+}
+
+// InputSchema returns the JSON schema for the object_list tool's input
+// parameters.
+func (t *objectListTool) InputSchema() map[string]interface{} {
+	schema := t.s.bucketSchema(mcp.SchemaFor(objectListArgs{}), false)
+	schema["properties"].(map[string]interface{})["max_keys"].(map[string]interface{})["maximum"] = t.s.cfg.MaxKeys
+	return schema
+}
+
+// Annotations marks the object_list tool as read-only.
+func (t *objectListTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "List objects", ReadOnlyHint: true}
+}
+
+// Timeout returns the configured time limit of a call.
+func (t *objectListTool) Timeout() time.Duration {
+	return time.Duration(t.s.cfg.Timeout)
+}
+
+// Execute lists the objects without a deadline of its own.
+func (t *objectListTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext lists the objects and returns them as text.
+func (t *objectListTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// objectListResult is the result of the object_list tool.
+type objectListResult struct {
+	Objects   []objectInfo `json:"objects"`
+	Prefixes  []string     `json:"prefixes,omitempty"` // common prefixes, with a delimiter
+	Truncated bool         `json:"truncated,omitempty"`
+	NextToken string       `json:"nextToken,omitempty"`
+}
+
+// objectInfo is an object as object_list reports it.
+type objectInfo struct {
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	LastModified string `json:"lastModified"`
+	ETag         string `json:"etag,omitempty"`
+}
+
+// ExecuteResult lists the objects with ListObjectsV2 and returns them as
+// structured content.
+func (t *objectListTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a objectListArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	b, err := t.s.bucket(a.Bucket, false)
+	if err != nil {
+		return nil, err
+	}
+	if a.Prefix == "" && len(b.Prefixes) == 1 {
+		a.Prefix = b.Prefixes[0]
+	}
+	if len(b.Prefixes) > 0 {
+		if err := b.allowed(a.Prefix); err != nil {
+			return nil, fmt.Errorf("invalid value for 'prefix': %v", err)
+		}
+	}
+	if a.MaxKeys < 0 || a.MaxKeys > t.s.cfg.MaxKeys {
+		return nil, fmt.Errorf("invalid value for 'max_keys': expected 1 to %d", t.s.cfg.MaxKeys)
+	}
+	if a.MaxKeys == 0 {
+		a.MaxKeys = t.s.cfg.MaxKeys
+	}
+	query := url.Values{"list-type": {"2"}, "max-keys": {strconv.Itoa(a.MaxKeys)}}
+	for k, v := range map[string]string{"prefix": a.Prefix, "delimiter": a.Delimiter, "continuation-token": a.ContinuationToken} {
+		if v != "" {
+			query.Set(k, v)
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.s.cfg.Timeout))
+	defer cancel()
+	u, err := t.s.objectURL(b.Name, "", query)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.s.do(ctx, http.MethodGet, u, nil, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var list struct {
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+		Contents              []struct {
+			Key          string `xml:"Key"`
+			Size         int64  `xml:"Size"`
+			LastModified string `xml:"LastModified"`
+			ETag         string `xml:"ETag"`
+		} `xml:"Contents"`
+		CommonPrefixes []struct {
+			Prefix string `xml:"Prefix"`
+		} `xml:"CommonPrefixes"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, maxObjectListSize)).Decode(&list); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("object storage: malformed listing: %v", err)
+	}
+	result := objectListResult{Objects: []objectInfo{}, Truncated: list.IsTruncated, NextToken: list.NextContinuationToken}
+	for _, c := range list.Contents {
+		result.Objects = append(result.Objects, objectInfo{Key: c.Key, Size: c.Size, LastModified: c.LastModified, ETag: strings.Trim(c.ETag, `"`)})
+	}
+	for _, p := range list.CommonPrefixes {
+		result.Prefixes = append(result.Prefixes, p.Prefix)
+	}
+	return mcp.NewResult().WithStructured(result), nil
+}
+
+// objectPutArgs are the arguments of the object_put tool.
+type objectPutArgs struct {
+	Bucket      string `json:"bucket,omitempty" description:"Bucket to write to (default the only writable one)"`
+	Key         string `json:"key" description:"Key of the object"`
+	Content     string `json:"content" description:"Content of the object"`
+	Encoding    string `json:"encoding,omitempty" enum:"text,base64" description:"Encoding of content (default text)"`
+	ContentType string `json:"content_type,omitempty" description:"MIME type of the object (default text/plain for text, application/octet-stream for base64)"`
+}
+
+// objectPutTool writes an object to a writable bucket under an allowed
+// prefix.
+type objectPutTool struct {
+	s *objectStore
+}
+
+// Name returns the name of the object_put tool.
+func (t *objectPutTool) Name() string {
+	return "object_put"
+}
+
+// Description returns a brief description of the object_put tool.
+func (t *objectPutTool) Description() string {
+	return fmt.Sprintf("Writes an object of up to %d bytes to object storage, replacing any object with its key. Buckets: %s", t.s.cfg.MaxBytes, describeBuckets(t.s.cfg.Buckets, true))
+}
+
+// InputSchema returns the JSON schema for the object_put tool's input
+// parameters.
+func (t *objectPutTool) InputSchema() map[string]interface{} {
+	return t.s.bucketSchema(mcp.SchemaFor(objectPutArgs{}), true)
+}
+
+// Annotations marks the object_put tool as idempotent: writing the same
+// object twice leaves the same object. It replaces objects, so it stays
+// destructive.
+func (t *objectPutTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "Put object", IdempotentHint: true}
+}
+
+// Timeout returns the configured time limit of a call.
+func (t *objectPutTool) Timeout() time.Duration {
+	return time.Duration(t.s.cfg.Timeout)
+}
+
+// Execute writes the object without a deadline of its own.
+func (t *objectPutTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext writes the object and reports its ETag.
+func (t *objectPutTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a objectPutArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Bucket == "" {
+		if writable := t.s.bucketNames(true); len(writable) == 1 {
+			a.Bucket = writable[0]
+		}
+	}
+	b, err := t.s.bucket(a.Bucket, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.allowed(a.Key); err != nil {
+		return nil, err
+	}
+	data := []byte(a.Content)
+	contentType := "text/plain; charset=utf-8"
+	switch a.Encoding {
+	case "", "text":
+	case "base64":
+		if data, err = base64.StdEncoding.DecodeString(a.Content); err != nil {
+			return nil, errors.New("invalid value for 'content': not base64")
+		}
+		contentType = "application/octet-stream"
+	default:
+		return nil, errors.New("invalid value for 'encoding': expected text or base64")
+	}
+	if len(data) > t.s.cfg.MaxBytes {
+		return nil, fmt.Errorf("the content is %d bytes, more than the limit of %d", len(data), t.s.cfg.MaxBytes)
+	}
+	if a.ContentType != "" {
+		contentType = a.ContentType
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.s.cfg.Timeout))
+	defer cancel()
+	u, err := t.s.objectURL(b.Name, a.Key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.s.do(ctx, http.MethodPut, u, data, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	resp.Body.Close()
+	text := fmt.Sprintf("Wrote %d bytes to s3://%s/%s", len(data), b.Name, a.Key)
+	if etag := strings.Trim(resp.Header.Get("ETag"), `"`); etag != "" {
+		text += " (ETag " + etag + ")"
+	}
+	return []ToolContent{{Type: "text", Text: text}}, nil
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// openAPIConfig defines a REST API exposed as tools in the "openapi"
+// section of the config file.
+type openAPIConfig struct {
+	Name      string            `json:"name"`
+	Spec      string            `json:"spec"`      // path or http(s) URL of an OpenAPI 3 document in JSON
+	BaseURL   string            `json:"baseURL"`   // defaults to the first server in the spec
+	Namespace string            `json:"namespace"` // qualifies the generated tool names
+	Headers   map[string]string `json:"headers"`   // sent with every call; $VAR expands from the environment
+}
+
+// validate reports missing or malformed fields.
+func (c *openAPIConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("openapi entry without a name")
+	}
+	if c.Spec == "" {
+		return fmt.Errorf("openapi %q: missing spec", c.Name)
+	}
+	if err := validateNamespace(c.Namespace); err != nil {
+		return fmt.Errorf("openapi %q: %w", c.Name, err)
+	}
+	return nil
+}
+
+// openAPIDocument is the part of an OpenAPI 3 document needed to generate
+// tools.
+type openAPIDocument struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components map[string]map[string]json.RawMessage `json:"components"`
+}
+
+// openAPIOperation is one operation of a path item.
+type openAPIOperation struct {
+	OperationID string             `json:"operationId"`
+	Summary     string             `json:"summary"`
+	Description string             `json:"description"`
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema json.RawMessage `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+// openAPIParameter is a path, query, or header parameter.
+type openAPIParameter struct {
+	Ref         string          `json:"$ref"`
+	Name        string          `json:"name"`
+	In          string          `json:"in"`
+	Description string          `json:"description"`
+	Required    bool            `json:"required"`
+	Schema      json.RawMessage `json:"schema"`
+}
+
+// openAPIMethods lists the operations of a path item that become tools.
+var openAPIMethods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+// loadOpenAPITools reads the spec described by cfg and returns one tool per
+// operation.
+func loadOpenAPITools(cfg openAPIConfig) ([]MCPTool, error) {
+	data, err := readSpec(cfg.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("openapi %q: %w", cfg.Name, err)
+	}
+	var doc openAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("openapi %q: spec must be OpenAPI 3 JSON: %w", cfg.Name, err)
+	}
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		if len(doc.Servers) == 0 {
+			return nil, fmt.Errorf("openapi %q: no baseURL and no servers in the spec", cfg.Name)
+		}
+		if baseURL, err = serverURL(cfg.Spec, doc.Servers[0].URL); err != nil {
+			return nil, fmt.Errorf("openapi %q: %w", cfg.Name, err)
+		}
+	}
+
+	var generated []MCPTool
+	for _, path := range sortedKeys(doc.Paths) {
+		item := doc.Paths[path]
+		var shared []openAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("openapi %q: %s: %w", cfg.Name, path, err)
+			}
+		}
+		for _, method := range openAPIMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op openAPIOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("openapi %q: %s %s: %w", cfg.Name, method, path, err)
+			}
+			tool, err := newOpenAPITool(&doc, cfg, baseURL, method, path, op, shared)
+			if err != nil {
+				return nil, fmt.Errorf("openapi %q: %s %s: %w", cfg.Name, method, path, err)
+			}
+			generated = append(generated, tool)
+		}
+	}
+	return generated, nil
+}
+
+// specFetchTimeout bounds fetching a spec over HTTP, which happens at
+// startup.
+const specFetchTimeout = 30 * time.Second
+
+// specClient fetches specs given by URL.
+var specClient = &http.Client{Timeout: specFetchTimeout}
+
+// isURL reports whether a spec location is an http(s) URL rather than a
+// path.
+func isURL(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// readSpec reads a spec from a file or an http(s) URL.
+func readSpec(location string) ([]byte, error) {
+	if !isURL(location) {
+		return os.ReadFile(location)
+	}
+	resp, err := specClient.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", location, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// serverURL returns the base URL given by the spec's first server. A
+// relative URL, which OpenAPI resolves against the location of the spec,
+// requires the spec to have been fetched by URL.
+func serverURL(spec, server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %w", server, err)
+	}
+	if u.IsAbs() {
+		return server, nil
+	}
+	if !isURL(spec) {
+		return "", fmt.Errorf("relative server URL %q in a spec read from a file; set baseURL", server)
+	}
+	base, err := url.Parse(spec)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
+// nonNameChars matches characters not allowed in generated tool names.
+var nonNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// openAPITool calls one operation of a REST API.
+type openAPITool struct {
+	name        string
+	description string
+	schema      map[string]interface{}
+	method      string
+	baseURL     string
+	path        string
+	params      []openAPIParameter
+	hasBody     bool
+	headers     map[string]string
+}
+
+// newOpenAPITool builds the tool for op. Path-level parameters in shared
+// apply unless the operation overrides them.
+func newOpenAPITool(doc *openAPIDocument, cfg openAPIConfig, baseURL, method, path string, op openAPIOperation, shared []openAPIParameter) (*openAPITool, error) {
+	name := op.OperationID
+	if name == "" {
+		name = method + path
+	}
+	name = strings.Trim(nonNameChars.ReplaceAllString(name, "_"), "_")
+	description := op.Summary
+	if description == "" {
+		description = op.Description
+	}
+	if description == "" {
+		description = strings.ToUpper(method) + " " + path
+	}
+
+	params := map[string]openAPIParameter{}
+	var order []string
+	for _, p := range append(shared, op.Parameters...) {
+		if p.Ref != "" {
+			resolved, err := doc.resolve(p.Ref)
+			if err != nil {
+				return nil, err
+			}
+			p = openAPIParameter{}
+			if err := json.Unmarshal(resolved, &p); err != nil {
+				return nil, err
+			}
+		}
+		switch p.In {
+		case "path", "query", "header":
+		default:
+			continue
+		}
+		if _, ok := params[p.Name]; !ok {
+			order = append(order, p.Name)
+		}
+		params[p.Name] = p
+	}
+
+	properties := map[string]interface{}{}
+	required := []string{}
+	t := &openAPITool{
+		name:        name,
+		description: description,
+		method:      strings.ToUpper(method),
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		path:        path,
+		headers:     cfg.Headers,
+	}
+	for _, n := range order {
+		p := params[n]
+		schema, err := doc.inline(p.Schema, 0)
+		if err != nil {
+			return nil, err
+		}
+		prop, _ := schema.(map[string]interface{})
+		if prop == nil {
+			prop = map[string]interface{}{"type": "string"}
+		}
+		if p.Description != "" {
+			prop["description"] = p.Description
+		}
+		properties[n] = prop
+		if p.Required || p.In == "path" {
+			required = append(required, n)
+		}
+		t.params = append(t.params, p)
+	}
+	if op.RequestBody != nil {
+		if media, ok := op.RequestBody.Content["application/json"]; ok {
+			if _, ok := params["body"]; ok {
+				return nil, errors.New(`parameter "body" collides with the request body argument`)
+			}
+			schema, err := doc.inline(media.Schema, 0)
+			if err != nil {
+				return nil, err
+			}
+			if schema == nil {
+				schema = map[string]interface{}{}
+			}
+			properties["body"] = schema
+			if op.RequestBody.Required {
+				required = append(required, "body")
+			}
+			t.hasBody = true
+		}
+	}
+	sort.Strings(required)
+	t.schema = map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+	return t, nil
+}
+
+// resolve returns the component a local reference such as
+// "#/components/schemas/Pet" points to.
+func (doc *openAPIDocument) resolve(ref string) (json.RawMessage, error) {
+	parts := strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+	if !strings.HasPrefix(ref, "#/components/") || len(parts) != 3 {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	raw, ok := doc.Components[parts[1]][parts[2]]
+	if !ok {
+		return nil, fmt.Errorf("unresolved reference %q", ref)
+	}
+	return raw, nil
+}
+
+// maxRefDepth bounds reference inlining so that recursive schemas end.
+const maxRefDepth = 8
+
+// inline decodes a schema, replacing local references by the schemas they
+// point to. References nested deeper than maxRefDepth become empty
+// schemas, which accept anything.
+func (doc *openAPIDocument) inline(raw json.RawMessage, depth int) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return doc.inlineValue(v, depth)
+}
+
+// inlineValue is inline for decoded JSON.
+func (doc *openAPIDocument) inlineValue(v interface{}, depth int) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			if depth >= maxRefDepth {
+				return map[string]interface{}{}, nil
+			}
+			resolved, err := doc.resolve(ref)
+			if err != nil {
+				return nil, err
+			}
+			return doc.inline(resolved, depth+1)
+		}
+		for k, item := range v {
+			inlined, err := doc.inlineValue(item, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = inlined
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			inlined, err := doc.inlineValue(item, depth)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = inlined
+		}
+		return v, nil
+	}
+	return v, nil
+}
+
+// Name returns the name generated from the operation ID.
+func (t *openAPITool) Name() string {
+	return t.name
+}
+
+// Description returns the operation's summary.
+func (t *openAPITool) Description() string {
+	return t.description
+}
+
+// InputSchema returns the schema generated from the parameters and body.
+func (t *openAPITool) InputSchema() map[string]interface{} {
+	return t.schema
+}
+
+// Annotations derives hints from the HTTP method: GET, HEAD, and OPTIONS
+// are read-only, PUT and DELETE are idempotent, and DELETE is destructive.
+func (t *openAPITool) Annotations() ToolAnnotations {
+	a := ToolAnnotations{}
+	switch t.method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		a.ReadOnlyHint, a.IdempotentHint = true, true
+	case http.MethodPut:
+		destructive := false
+		a.IdempotentHint, a.DestructiveHint = true, &destructive
+	case http.MethodDelete:
+		a.IdempotentHint = true
+	default:
+		destructive := false
+		a.DestructiveHint = &destructive
+	}
+	return a
+}
+
+// Execute calls the operation without a deadline of its own.
+func (t *openAPITool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext calls the operation and returns the response body as text.
+// Responses with a status other than 2xx fail the call with a result flagged
+// as an error, giving the status and the response body.
+func (t *openAPITool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	path := t.path
+	query := url.Values{}
+	header := http.Header{}
+	for _, p := range t.params {
+		v, ok := args[p.Name]
+		if !ok {
+			continue
+		}
+		s := paramString(v)
+		switch p.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+p.Name+"}", url.PathEscape(s))
+		case "query":
+			query.Set(p.Name, s)
+		case "header":
+			header.Set(p.Name, s)
+		}
+	}
+	target := t.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if b, ok := args["body"]; ok && t.hasBody {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+		header.Set("Content-Type", "application/json")
+	}
+	req, err := http.NewRequestWithContext(ctx, t.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, os.ExpandEnv(v))
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, toolFailure("%s %s: %s: %s", t.method, path, resp.Status, bytes.TrimSpace(data))
+	}
+	return []ToolContent{{Type: "text", Text: string(data)}}, nil
+}
+
+// paramString formats an argument for a URL or header. Strings are used as
+// is; anything else is JSON-encoded, so 3 becomes "3".
+func paramString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
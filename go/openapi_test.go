@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// petSpec is a small OpenAPI document with path, query, and body inputs.
+const petSpec = `{
+  "openapi": "3.0.3",
+  "servers": [{"url": "http://example.invalid"}],
+  "paths": {
+    "/pets/{petId}": {
+      "parameters": [{"$ref": "#/components/parameters/PetId"}],
+      "get": {
+        "operationId": "getPet",
+        "summary": "Fetch a pet",
+        "parameters": [{"name": "verbose", "in": "query", "schema": {"type": "boolean"}}]
+      }
+    },
+    "/pets": {
+      "post": {
+        "summary": "Create a pet",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Pet"}}}
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "PetId": {"name": "petId", "in": "path", "required": true, "schema": {"type": "integer"}}
+    },
+    "schemas": {
+      "Pet": {"type": "object", "properties": {"name": {"type": "string"}, "friend": {"$ref": "#/components/schemas/Pet"}}}
+    }
+  }
+}`
+
+// Test that operations become tools that call the API
+func TestOpenAPITools(t *testing.T) {
+	var requests []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Authorization")+" "+string(body))
+		if r.URL.Path == "/pets/404" {
+			http.Error(w, "no such pet", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer api.Close()
+
+	path := filepath.Join(t.TempDir(), "pets.json")
+	if err := os.WriteFile(path, []byte(petSpec), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PET_TOKEN", "secret")
+	generated, err := loadOpenAPITools(openAPIConfig{
+		Name: "pets", Spec: path, BaseURL: api.URL + "/",
+		Headers: map[string]string{"Authorization": "Bearer $PET_TOKEN"},
+	})
+	if err != nil {
+		t.Fatalf("loadOpenAPITools error: %v", err)
+	}
+	if len(generated) != 2 || generated[0].Name() != "post_pets" || generated[1].Name() != "getPet" {
+		t.Fatalf("unexpected tools %v", generated)
+	}
+
+	schema, _ := json.Marshal(generated[1].InputSchema())
+	if !strings.Contains(string(schema), `"petId":{"type":"integer"}`) || !strings.Contains(string(schema), `"required":["petId"]`) {
+		t.Errorf("unexpected getPet schema %s", schema)
+	}
+
+	if _, err := generated[1].Execute(map[string]interface{}{"petId": float64(7), "verbose": true}); err != nil {
+		t.Fatalf("getPet error: %v", err)
+	}
+	content, err := generated[0].Execute(map[string]interface{}{"body": map[string]interface{}{"name": "Rex"}})
+	if err != nil || content[0].Text != `{"ok":true}` {
+		t.Fatalf("post_pets returned %v, %v", content, err)
+	}
+	_, err = generated[1].Execute(map[string]interface{}{"petId": "404"})
+	var resultErr *toolResultError
+	if !errors.As(err, &resultErr) || !strings.Contains(err.Error(), "404 Not Found: no such pet") {
+		t.Errorf("expected an error result with the status and response body, got %v", err)
+	}
+
+	want := []string{
+		"GET /pets/7?verbose=true Bearer secret ",
+		`POST /pets Bearer secret {"name":"Rex"}`,
+	}
+	for i, w := range want {
+		if i >= len(requests) || requests[i] != w {
+			t.Errorf("request %d: expected %q, got %q", i, w, requests)
+		}
+	}
+}
+
+// Test that broken specs are reported
+func TestOpenAPIErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, spec := range map[string]string{
+		"yaml":   "openapi: 3.0.0",
+		"noURL":  `{"paths":{}}`,
+		"badRef": `{"servers":[{"url":"http://x"}],"paths":{"/a":{"get":{"parameters":[{"$ref":"#/components/parameters/Nope"}]}}}}`,
+		"extRef": `{"servers":[{"url":"http://x"}],"paths":{"/a":{"get":{"parameters":[{"$ref":"other.json#/P"}]}}}}`,
+		"relURL": `{"servers":[{"url":"/v1"}],"paths":{}}`,
+		"body":   `{"servers":[{"url":"http://x"}],"paths":{"/a":{"post":{"parameters":[{"name":"body","in":"query"}],"requestBody":{"content":{"application/json":{}}}}}}}`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(spec), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadOpenAPITools(openAPIConfig{Name: name, Spec: path}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// Test that a relative server URL is resolved against the spec's URL
+func TestOpenAPIRelativeServer(t *testing.T) {
+	var got string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/specs/pets.json" {
+			w.Write([]byte(`{"servers":[{"url":"../api/v1"}],"paths":{"/pets":{"get":{"operationId":"listPets"}}}}`))
+			return
+		}
+		got = r.URL.Path
+		w.Write([]byte(`[]`))
+	}))
+	defer api.Close()
+
+	generated, err := loadOpenAPITools(openAPIConfig{Name: "pets", Spec: api.URL + "/specs/pets.json"})
+	if err != nil {
+		t.Fatalf("loadOpenAPITools error: %v", err)
+	}
+	if _, err := generated[0].Execute(nil); err != nil {
+		t.Fatalf("listPets error: %v", err)
+	}
+	if got != "/api/v1/pets" {
+		t.Errorf("expected a request to /api/v1/pets, got %q", got)
+	}
+}
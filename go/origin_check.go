@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// originCheck rejects requests whose Host or Origin header is not allowed,
+// protecting servers bound to localhost from DNS rebinding: a malicious page
+// resolving its own domain to 127.0.0.1 still sends its own name in both
+// headers.
+type originCheck struct {
+	next         http.Handler
+	hosts        []string // allowed host names, without ports
+	origins      []string // allowed origins, e.g. "https://app.example.com"
+	loopbackOnly bool     // with no allowlist, accept only loopback names
+}
+
+// newOriginCheck wraps next. Empty allowlists default to loopback names
+// when the server listens on a loopback address. Otherwise any host is
+// accepted but browsers only from the origin of the host they address.
+// Requests without an Origin header, such as those of non-browser clients,
+// are always accepted.
+func newOriginCheck(next http.Handler, listenAddr string, hosts, origins []string) *originCheck {
+	return &originCheck{next: next, hosts: hosts, origins: origins, loopbackOnly: isLoopbackAddr(listenAddr)}
+}
+
+// ServeHTTP implements http.Handler.
+func (c *originCheck) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !c.hostAllowed(r.Host) {
+		http.Error(w, "Host not allowed", http.StatusForbidden)
+		return
+	}
+	if origin := r.Header.Get("Origin"); origin != "" && !c.originAllowed(origin, r.Host) {
+		http.Error(w, "Origin not allowed", http.StatusForbidden)
+		return
+	}
+	c.next.ServeHTTP(w, r)
+}
+
+// hostAllowed checks the Host header.
+func (c *originCheck) hostAllowed(hostport string) bool {
+	host := stripPort(hostport)
+	if len(c.hosts) > 0 {
+		for _, h := range c.hosts {
+			if strings.EqualFold(h, host) {
+				return true
+			}
+		}
+		return false
+	}
+	return !c.loopbackOnly || isLoopbackHost(host)
+}
+
+// originAllowed checks the Origin header of a request to host.
+func (c *originCheck) originAllowed(origin, host string) bool {
+	if len(c.origins) > 0 {
+		for _, o := range c.origins {
+			if o == "*" || strings.EqualFold(strings.TrimSuffix(o, "/"), origin) {
+				return true
+			}
+		}
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if c.loopbackOnly {
+		return isLoopbackHost(u.Hostname())
+	}
+	return strings.EqualFold(u.Host, host)
+}
+
+// stripPort removes the port from host:port, if there is one.
+func stripPort(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.Trim(hostport, "[]")
+}
+
+// isLoopbackHost reports whether host names the local machine.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// isLoopbackAddr reports whether the listen address addr is restricted to
+// the local machine.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return isLoopbackHost(host)
+}
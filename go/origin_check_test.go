@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test that Host and Origin headers are checked against the allowlists
+func TestOriginCheck(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	local := newOriginCheck(ok, "127.0.0.1:8080", nil, nil)
+	public := newOriginCheck(ok, "0.0.0.0:8080", []string{"mcp.example.com"}, []string{"https://app.example.com"})
+	open := newOriginCheck(ok, ":8080", nil, nil)
+
+	cases := []struct {
+		name         string
+		check        *originCheck
+		host, origin string
+		want         int
+	}{
+		{"local host", local, "localhost:8080", "", 200},
+		{"local ipv6", local, "[::1]:8080", "http://[::1]:8080", 200},
+		{"local origin", local, "127.0.0.1:8080", "http://localhost:3000", 200},
+		{"rebinding host", local, "evil.example.com:8080", "", 403},
+		{"rebinding origin", local, "127.0.0.1:8080", "http://evil.example.com", 403},
+		{"listed host", public, "mcp.example.com", "https://app.example.com", 200},
+		{"unlisted host", public, "localhost:8080", "", 403},
+		{"unlisted origin", public, "mcp.example.com", "https://evil.example.com", 403},
+		{"no allowlist", open, "anything.example.com", "", 200},
+		{"no allowlist same origin", open, "anything.example.com:8080", "https://anything.example.com:8080", 200},
+		{"no allowlist cross origin", open, "anything.example.com", "https://anywhere.example.com", 403},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Host = c.host
+		if c.origin != "" {
+			req.Header.Set("Origin", c.origin)
+		}
+		rec := httptest.NewRecorder()
+		c.check.ServeHTTP(rec, req)
+		if rec.Code != c.want {
+			t.Errorf("%s: expected %d, got %d", c.name, c.want, rec.Code)
+		}
+	}
+}
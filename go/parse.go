@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// maxMessageDepth is the deepest nesting of arrays and objects accepted in
+// a message. encoding/json alone allows 10000 levels, enough to make
+// decoding and every later walk over the arguments needlessly expensive.
+const maxMessageDepth = 64
+
+// utf8BOM is the byte order mark that some Windows hosts send before
+// their first message.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// messageFramer follows the nesting of the JSON bytes fed to it, so that
+// messageReader can tell whether a line ends a message or a message
+// pretty-printed over several lines continues on the next.
+type messageFramer struct {
+	depth    int  // arrays and objects open
+	inString bool // within a string
+	escaped  bool // after a backslash within a string
+	last     byte // the last byte that is not whitespace
+}
+
+// feed scans the next bytes of a message.
+func (f *messageFramer) feed(p []byte) {
+	for _, c := range p {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			f.last = c
+		}
+		switch {
+		case f.escaped:
+			f.escaped = false
+		case f.inString:
+			switch c {
+			case '\\':
+				f.escaped = true
+			case '"':
+				f.inString = false
+			}
+		case c == '"':
+			f.inString = true
+		case c == '{' || c == '[':
+			f.depth++
+		case c == '}' || c == ']':
+			if f.depth > 0 {
+				f.depth--
+			}
+		}
+	}
+}
+
+// open reports whether the bytes fed so far, which must end a line, leave
+// the message open. Strings cannot span lines, so a line ending within one
+// ends a malformed message.
+func (f *messageFramer) open() bool {
+	return f.depth > 0 && !f.inString
+}
+
+// pretty reports whether the first line of a message, fed so far, ends as
+// pretty-printed JSON breaks lines: after an opening bracket or a comma.
+// Messages whose first line ends otherwise, such as ones cut off after a
+// key, are malformed and answered at once rather than held open.
+func (f *messageFramer) pretty() bool {
+	return f.open() && (f.last == '{' || f.last == '[' || f.last == ',')
+}
+
+// startsMessage reports whether line, read while a message continued, is
+// instead a whole JSON-RPC message of its own: an object that closes on
+// the same line and has a "jsonrpc" member.
+func startsMessage(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' || !bytes.Contains(line, []byte(`"jsonrpc"`)) {
+		return false
+	}
+	var f messageFramer
+	f.feed(line)
+	return f.depth == 0 && !f.inString
+}
+
+// messageError rejects a message with the JSON-RPC error to answer it with.
+type messageError struct {
+	code    int
+	message string
+}
+
+// Error implements error.
+func (e *messageError) Error() string {
+	return e.message
+}
+
+// parseMessage decodes a JSON-RPC message from a client. All client input
+// goes through it, so it must accept arbitrary bytes without panicking.
+// Beyond what encoding/json checks, it rejects invalid UTF-8, which
+// encoding/json silently replaces; nesting deeper than maxMessageDepth; and
+// duplicate object keys, which JSON parsers resolve differently, so that a
+// message could mean one thing to a proxy in front of the server and
+// another to the server itself.
+func parseMessage(data []byte) (*JSONRPCRequest, *messageError) {
+	if !utf8.Valid(data) {
+		return nil, &messageError{-32700, "Parse error: invalid UTF-8"}
+	}
+	if err := checkMessage(data); err != nil {
+		return nil, err
+	}
+	var req JSONRPCRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		// The JSON is valid, so a member has the wrong type.
+		return nil, &messageError{-32600, "Invalid Request"}
+	}
+	return &req, nil
+}
+
+// jsonFrame is an array or object being walked by checkMessage.
+type jsonFrame struct {
+	object    bool
+	expectKey bool                // the next token of an object is a key
+	keys      map[string]struct{} // the keys of an object seen so far
+}
+
+// checkMessage walks the tokens of data, checking that it is a single JSON
+// object within the depth limit and without duplicate keys.
+func checkMessage(data []byte) *messageError {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // numbers need not fit in a float64
+	var stack []*jsonFrame
+	for first := true; ; first = false {
+		tok, err := dec.Token()
+		if err == io.EOF && !first && len(stack) == 0 {
+			return nil
+		}
+		if err != nil {
+			return &messageError{-32700, "Parse error"}
+		}
+		if first {
+			if d, ok := tok.(json.Delim); !ok || d != '{' {
+				return &messageError{-32600, "Invalid Request: expected an object"}
+			}
+		} else if len(stack) == 0 {
+			return &messageError{-32700, "Parse error: data after the message"}
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if top != nil && top.object {
+			if top.expectKey {
+				key := tok.(string) // the decoder only returns strings as keys
+				if _, dup := top.keys[key]; dup {
+					return &messageError{-32600, fmt.Sprintf("Invalid Request: duplicate key %q", key)}
+				}
+				top.keys[key] = struct{}{}
+				top.expectKey = false
+				continue
+			}
+			top.expectKey = true
+		}
+		if d, ok := tok.(json.Delim); ok {
+			if len(stack) == maxMessageDepth {
+				return &messageError{-32600, fmt.Sprintf("Invalid Request: nested deeper than %d levels", maxMessageDepth)}
+			}
+			frame := &jsonFrame{object: d == '{'}
+			if frame.object {
+				frame.expectKey = true
+				frame.keys = map[string]struct{}{}
+			}
+			stack = append(stack, frame)
+		}
+	}
+}
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of parse_ics.
+const (
+	maxCalendarSize      = 8 << 20
+	calendarFetchTimeout = 20 * time.Second
+	defaultCalendarDays  = 30
+	defaultCalendarLimit = 50
+	maxCalendarLimit     = 500
+	maxRecurrenceSteps   = 100000 // occurrences examined per recurring event
+)
+
+// parseICSTool lists the events of an iCalendar file in a date range,
+// expanding recurring events. The calendar is passed as text, read from a
+// path in ws, or fetched from a public URL.
+type parseICSTool struct {
+	ws           *Workspace
+	allowPrivate bool // lets tests fetch from the loopback interface
+}
+
+// withCalendarWorkspace returns a copy of list in which parse_ics reads
+// files in ws.
+func withCalendarWorkspace(list []MCPTool, ws *Workspace) []MCPTool {
+	out := make([]MCPTool, len(list))
+	for i, t := range list {
+		if _, ok := t.(*parseICSTool); ok {
+			t = &parseICSTool{ws: ws}
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// parseICSArgs are the arguments of the parse_ics tool.
+type parseICSArgs struct {
+	ICS   string `json:"ics,omitempty" description:"The calendar as iCalendar text"`
+	Path  string `json:"path,omitempty" description:"The calendar's path, relative to the server's calendar directory"`
+	URL   string `json:"url,omitempty" description:"An http, https, or webcal URL to fetch the calendar from"`
+	Start string `json:"start,omitempty" description:"Start of the range as an RFC 3339 time or a date (default now)"`
+	End   string `json:"end,omitempty" description:"End of the range, exclusive, as an RFC 3339 time or a date (default 30 days after the start)"`
+	Limit int    `json:"limit,omitempty" minimum:"1" maximum:"500" description:"Most events to return (default 50)"`
+}
+
+// Name returns the name of the parse_ics tool.
+func (t *parseICSTool) Name() string {
+	return "parse_ics"
+}
+
+// Description returns a brief description of the parse_ics tool.
+func (t *parseICSTool) Description() string {
+	return "Lists the events of an iCalendar (.ics) calendar within a date range, including occurrences of recurring events"
+}
+
+// InputSchema returns the JSON schema for the parse_ics tool's input
+// parameters.
+func (t *parseICSTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(parseICSArgs{})
+}
+
+// Annotations marks the parse_ics tool as read-only. Given a URL, it
+// reaches outside the server.
+func (t *parseICSTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "Parse iCalendar", ReadOnlyHint: true}
+}
+
+// calendarEvent is an occurrence of an event as parse_ics returns it.
+// Times are RFC 3339 in the event's time zone; all-day events have dates.
+type calendarEvent struct {
+	Summary     string `json:"summary"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	AllDay      bool   `json:"allDay,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Description string `json:"description,omitempty"`
+	UID         string `json:"uid,omitempty"`
+
+	start time.Time // for sorting
+}
+
+// Execute lists the events without a deadline of its own.
+func (t *parseICSTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext reads the calendar and returns the events in the range,
+// in order of their start, as indented JSON text.
+func (t *parseICSTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	a := parseICSArgs{Limit: defaultCalendarLimit}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Limit < 1 || a.Limit > maxCalendarLimit {
+		return nil, fmt.Errorf("invalid value for 'limit'")
+	}
+	start, end := time.Now(), time.Time{}
+	if a.Start != "" {
+		var err error
+		if start, err = parseCalendarBound(a.Start); err != nil {
+			return nil, fmt.Errorf("invalid value for 'start': %v", err)
+		}
+	}
+	if a.End != "" {
+		var err error
+		if end, err = parseCalendarBound(a.End); err != nil {
+			return nil, fmt.Errorf("invalid value for 'end': %v", err)
+		}
+	} else {
+		end = start.AddDate(0, 0, defaultCalendarDays)
+	}
+	if !end.After(start) {
+		return nil, errors.New("invalid value for 'end': the range ends before it starts")
+	}
+
+	data, err := t.read(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	events, err := parseICS(data)
+	if err != nil {
+		return nil, toolFailure("invalid calendar: %v", err)
+	}
+	result := struct {
+		Events    []calendarEvent `json:"events"`
+		Truncated bool            `json:"truncated,omitempty"`
+	}{Events: []calendarEvent{}}
+	for _, e := range events {
+		result.Events = append(result.Events, e.occurrences(start, end)...)
+	}
+	sort.SliceStable(result.Events, func(i, j int) bool {
+		return result.Events[i].start.Before(result.Events[j].start)
+	})
+	if len(result.Events) > a.Limit {
+		result.Events, result.Truncated = result.Events[:a.Limit], true
+	}
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
+
+// parseCalendarBound parses an RFC 3339 time or a date, which is midnight
+// in the server's time zone.
+func parseCalendarBound(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("expected an RFC 3339 time or a date like 2006-01-02")
+	}
+	return t, nil
+}
+
+// read returns the calendar given by exactly one of the ics, path and url
+// arguments.
+func (t *parseICSTool) read(ctx context.Context, a parseICSArgs) (string, error) {
+	given := 0
+	for _, s := range []string{a.ICS, a.Path, a.URL} {
+		if s != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return "", errors.New("exactly one of 'ics', 'path' and 'url' is required")
+	}
+	switch {
+	case a.ICS != "":
+		return a.ICS, nil
+	case a.Path != "":
+		path, err := t.resolve(ctx, a.Path)
+		if err != nil {
+			return "", err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return "", toolFailure("%s: %v", a.Path, errors.Unwrap(err))
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxCalendarSize+1))
+		if err != nil {
+			return "", toolFailure("%s: %v", a.Path, err)
+		}
+		if len(data) > maxCalendarSize {
+			return "", toolFailure("%s is larger than %d bytes", a.Path, maxCalendarSize)
+		}
+		return string(data), nil
+	}
+	return t.fetch(ctx, a.URL)
+}
+
+// resolve returns the path of the named calendar file, which must be
+// within the calendar directory.
+func (t *parseICSTool) resolve(ctx context.Context, name string) (string, error) {
+	if t.ws == nil {
+		return "", errors.New("reading calendars from paths is disabled; start the server with --calendar-dir")
+	}
+	ws, err := t.ws.For(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no calendar directory: %w", err)
+	}
+	return ws.Resolve(name, "calendar")
+}
+
+// fetch downloads the calendar at rawURL from a public address.
+func (t *parseICSTool) fetch(ctx context.Context, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err == nil && u.Scheme == "webcal" {
+		u.Scheme = "https"
+	}
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("invalid value for 'url': an http, https, or webcal URL is required")
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !t.allowPrivate {
+		dialer.Control = checkPublicAddress
+	}
+	client := &http.Client{
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+		Timeout:   calendarFetchTimeout,
+	}
+	defer client.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "text/calendar, */*;q=0.5")
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", toolFailure("%s: %v", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", toolFailure("%s: %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxCalendarSize+1))
+	if err != nil {
+		return "", toolFailure("%s: %v", u, err)
+	}
+	if len(data) > maxCalendarSize {
+		return "", toolFailure("%s: the calendar is larger than %d bytes", u, maxCalendarSize)
+	}
+	return string(data), nil
+}
+
+// icsProperty is a content line of an iCalendar file.
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// icsEvent is a VEVENT of an iCalendar file.
+type icsEvent struct {
+	uid, summary, location, description string
+
+	start, end time.Time
+	allDay     bool
+	duration   time.Duration // when end is not set
+	rule       *recurrenceRule
+	exdates    map[time.Time]bool
+	rdates     []time.Time
+}
+
+// parseICS returns the events of an iCalendar file. Components other than
+// VEVENT, and properties parse_ics does not use, are skipped.
+func parseICS(data string) ([]*icsEvent, error) {
+	data = strings.TrimPrefix(data, "\ufeff")
+	if !strings.HasPrefix(strings.ToUpper(strings.TrimSpace(data)), "BEGIN:VCALENDAR") {
+		return nil, errors.New("not an iCalendar file")
+	}
+	var events []*icsEvent
+	var cur *icsEvent
+	depth := 0 // of components nested in the current event, such as VALARM
+	for i, line := range unfoldICSLines(data) {
+		if line == "" {
+			continue
+		}
+		p, err := parseICSProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT") && cur == nil:
+			cur = &icsEvent{exdates: map[time.Time]bool{}}
+		case cur == nil:
+		case p.name == "BEGIN":
+			depth++
+		case p.name == "END" && depth > 0:
+			depth--
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			if cur.start.IsZero() {
+				return nil, fmt.Errorf("line %d: event without DTSTART", i+1)
+			}
+			events = append(events, cur)
+			cur = nil
+		case depth > 0:
+		default:
+			if err := cur.set(p); err != nil {
+				return nil, fmt.Errorf("line %d: %s: %v", i+1, p.name, err)
+			}
+		}
+	}
+	return events, nil
+}
+
+// unfoldICSLines splits data into content lines, joining folded ones.
+func unfoldICSLines(data string) []string {
+	raw := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+	var lines []string
+	for _, l := range raw {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, strings.TrimSuffix(l, "\r"))
+	}
+	return lines
+}
+
+// parseICSProperty parses a content line: a name, parameters, and a value.
+func parseICSProperty(line string) (icsProperty, error) {
+	p := icsProperty{params: map[string]string{}}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, fmt.Errorf("malformed line %q", line)
+	}
+	p.name = strings.ToUpper(line[:i])
+	for line[i] == ';' {
+		line = line[i+1:]
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return p, fmt.Errorf("malformed parameter in %s", p.name)
+		}
+		key := strings.ToUpper(line[:eq])
+		line = line[eq+1:]
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("unterminated parameter in %s", p.name)
+			}
+			value, line = line[1:1+end], line[2+end:]
+			i = 0
+			if line == "" {
+				return p, fmt.Errorf("%s without a value", p.name)
+			}
+		} else {
+			i = strings.IndexAny(line, ";:")
+			if i < 0 {
+				return p, fmt.Errorf("%s without a value", p.name)
+			}
+			value = line[:i]
+		}
+		p.params[key] = value
+		if line[i] != ';' && line[i] != ':' {
+			return p, fmt.Errorf("malformed parameter in %s", p.name)
+		}
+	}
+	p.value = line[i+1:]
+	return p, nil
+}
+
+// icsTextUnescaper undoes the escaping of TEXT values.
+var icsTextUnescaper = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+// set records the property p of the event.
+func (e *icsEvent) set(p icsProperty) error {
+	var err error
+	switch p.name {
+	case "UID":
+		e.uid = p.value
+	case "SUMMARY":
+		e.summary = icsTextUnescaper.Replace(p.value)
+	case "LOCATION":
+		e.location = icsTextUnescaper.Replace(p.value)
+	case "DESCRIPTION":
+		e.description = icsTextUnescaper.Replace(p.value)
+	case "DTSTART":
+		e.start, e.allDay, err = parseICSTime(p)
+	case "DTEND":
+		e.end, _, err = parseICSTime(p)
+	case "DURATION":
+		e.duration, err = parseICSDuration(p.value)
+	case "RRULE":
+		e.rule, err = parseRecurrenceRule(p.value)
+	case "EXDATE", "RDATE":
+		for _, v := range strings.Split(p.value, ",") {
+			var t time.Time
+			if t, _, err = parseICSTime(icsProperty{params: p.params, value: v}); err != nil {
+				break
+			}
+			if p.name == "EXDATE" {
+				e.exdates[t.UTC()] = true
+			} else {
+				e.rdates = append(e.rdates, t)
+			}
+		}
+	}
+	return err
+}
+
+// parseICSTime parses a DATE or DATE-TIME value in UTC, in the zone named
+// by its TZID parameter, or, floating, in the server's time zone. Zones
+// the system does not know are taken as UTC.
+func parseICSTime(p icsProperty) (time.Time, bool, error) {
+	v := p.value
+	if p.params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, time.Local)
+		return t, true, err
+	}
+	loc := time.Local
+	if strings.HasSuffix(v, "Z") {
+		loc, v = time.UTC, strings.TrimSuffix(v, "Z")
+	} else if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		} else {
+			loc = time.UTC
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	if err != nil {
+		return t, false, fmt.Errorf("invalid date-time %q", p.value)
+	}
+	return t, false, nil
+}
+
+// parseICSDuration parses a duration such as P1DT2H30M or -PT15M.
+func parseICSDuration(s string) (time.Duration, error) {
+	orig := s
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	s = strings.TrimPrefix(s, "+")
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, c := range s[1:] {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+			continue
+		case c == 'T' && num == "":
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		unit := map[rune]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}[c]
+		if inTime {
+			unit = map[rune]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}[c]
+		}
+		if unit == 0 {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		d += time.Duration(n) * unit
+		num = ""
+	}
+	if num != "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	return sign * d, nil
+}
+
+// recurrenceRule is the part of an RRULE that parse_ics expands: the
+// frequency and interval, a COUNT or UNTIL, and, for weekly rules, the
+// days of the week.
+type recurrenceRule struct {
+	freq     string
+	interval int
+	count    int
+	until    time.Time
+	byDay    []time.Weekday
+}
+
+// icsWeekdays maps the two-letter days of BYDAY to weekdays.
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// parseRecurrenceRule parses an RRULE value.
+func parseRecurrenceRule(s string) (*recurrenceRule, error) {
+	r := &recurrenceRule{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.freq = strings.ToUpper(value)
+		case "INTERVAL":
+			if r.interval, err = strconv.Atoi(value); err == nil && r.interval < 1 {
+				err = errors.New("INTERVAL must be positive")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(value)
+		case "UNTIL":
+			r.until, _, err = parseICSTime(icsProperty{value: value})
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := icsWeekdays[strings.ToUpper(d)]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY %q", d)
+				}
+				r.byDay = append(r.byDay, wd)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", key, err)
+		}
+	}
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("unsupported FREQ %q", r.freq)
+	}
+	if len(r.byDay) > 0 && r.freq != "WEEKLY" {
+		return nil, fmt.Errorf("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	return r, nil
+}
+
+// occurrences returns the occurrences of e that overlap the range from
+// start to end.
+func (e *icsEvent) occurrences(start, end time.Time) []calendarEvent {
+	length := e.duration
+	if !e.end.IsZero() {
+		length = e.end.Sub(e.start)
+	} else if length == 0 && e.allDay {
+		length = 24 * time.Hour
+	}
+	var out []calendarEvent
+	add := func(s time.Time) {
+		// Add dates, not hours, so all-day events keep their days across
+		// changes of daylight saving time.
+		f := s.Add(length)
+		if e.allDay {
+			f = s.AddDate(0, 0, int(length/(24*time.Hour)))
+		}
+		if e.exdates[s.UTC()] || !f.After(start) && !(length == 0 && !s.Before(start)) || !s.Before(end) {
+			return
+		}
+		out = append(out, e.instance(s, f))
+	}
+	for _, s := range e.rdates {
+		add(s)
+	}
+	if e.rule == nil {
+		add(e.start)
+		return out
+	}
+	r := e.rule
+	n := 0
+	for step := 0; step < maxRecurrenceSteps; step++ {
+		var candidates []time.Time
+		switch r.freq {
+		case "DAILY":
+			candidates = []time.Time{e.start.AddDate(0, 0, step*r.interval)}
+		case "WEEKLY":
+			week := e.start.AddDate(0, 0, 7*step*r.interval)
+			if len(r.byDay) == 0 {
+				candidates = []time.Time{week}
+				break
+			}
+			// The days of the event's week, from its first day, Monday.
+			monday := week.AddDate(0, 0, -((int(week.Weekday()) + 6) % 7))
+			for i := 0; i < 7; i++ {
+				d := monday.AddDate(0, 0, i)
+				for _, wd := range r.byDay {
+					if d.Weekday() == wd && !d.Before(e.start) {
+						candidates = append(candidates, d)
+					}
+				}
+			}
+		case "MONTHLY", "YEARLY":
+			months := step * r.interval
+			if r.freq == "YEARLY" {
+				months *= 12
+			}
+			// Skip months that lack the day, such as February 30.
+			if d := e.start.AddDate(0, months, 0); d.Day() == e.start.Day() {
+				candidates = []time.Time{d}
+			}
+		}
+		for _, s := range candidates {
+			if !r.until.IsZero() && s.After(r.until) || r.count > 0 && n >= r.count || !s.Before(end) {
+				return out
+			}
+			n++
+			add(s)
+		}
+	}
+	return out
+}
+
+// instance returns the occurrence of e from s to f.
+func (e *icsEvent) instance(s, f time.Time) calendarEvent {
+	format := time.RFC3339
+	if e.allDay {
+		format = time.DateOnly
+	}
+	return calendarEvent{
+		Summary:     e.summary,
+		Start:       s.Format(format),
+		End:         f.Format(format),
+		AllDay:      e.allDay,
+		Location:    e.location,
+		Description: e.description,
+		UID:         e.uid,
+		start:       s,
+	}
+}
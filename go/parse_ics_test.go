@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCalendar has single, all-day, folded, nested and recurring events.
+const testCalendar = "\ufeffBEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\nTZID:Europe/Berlin\r\nEND:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\nUID:review\r\nDTSTART:20250310T140000Z\r\nDTEND:20250310T150000Z\r\n" +
+	"SUMMARY:Design review\\, part 2\r\nLOCATION:Room 4\r\nDESCRIPTION:Bring the\r\n  slides\\nand notes\r\n" +
+	"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Reminder\r\nEND:VALARM\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:holiday\r\nDTSTART;VALUE=DATE:20250312\r\nDTEND;VALUE=DATE:20250314\r\nSUMMARY:Holiday\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:standup\r\nDTSTART;TZID=UTC:20250303T090000\r\nDURATION:PT15M\r\n" +
+	"RRULE:FREQ=WEEKLY;BYDAY=MO,WE;COUNT=6\r\nEXDATE;TZID=UTC:20250305T090000\r\nSUMMARY:Standup\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:old\r\nDTSTART:20240101T100000Z\r\nSUMMARY:Last year\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+// icsResult is the result of parse_ics.
+type icsResult struct {
+	Events    []calendarEvent `json:"events"`
+	Truncated bool            `json:"truncated"`
+}
+
+// callParseICS calls tool and decodes its result.
+func callParseICS(t *testing.T, tool *parseICSTool, args map[string]interface{}) icsResult {
+	t.Helper()
+	content, err := tool.Execute(args)
+	if err != nil {
+		t.Fatalf("%v: %v", args, err)
+	}
+	var result icsResult
+	if err := json.Unmarshal([]byte(content[0].Text), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// Test listing the events of a calendar in a range
+func TestParseICS(t *testing.T) {
+	tool := &parseICSTool{}
+	result := callParseICS(t, tool, map[string]interface{}{
+		"ics": testCalendar, "start": "2025-03-04T00:00:00Z", "end": "2025-03-13T00:00:00Z",
+	})
+	var got []string
+	for _, e := range result.Events {
+		// The holiday's dates are in the server's time zone, so its place
+		// in the order varies.
+		if e.UID == "holiday" {
+			if !e.AllDay || e.Start != "2025-03-12" || e.End != "2025-03-14" {
+				t.Errorf("unexpected all-day event %+v", e)
+			}
+			continue
+		}
+		got = append(got, e.Summary+" "+e.Start+" "+e.End)
+		if e.UID == "review" && (e.Location != "Room 4" || e.Description != "Bring the slides\nand notes") {
+			t.Errorf("unexpected details %+v", e)
+		}
+	}
+	expected := []string{
+		"Standup 2025-03-10T09:00:00Z 2025-03-10T09:15:00Z",
+		"Design review, part 2 2025-03-10T14:00:00Z 2025-03-10T15:00:00Z",
+		"Standup 2025-03-12T09:00:00Z 2025-03-12T09:15:00Z",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") || len(result.Events) != 4 {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	result = callParseICS(t, tool, map[string]interface{}{
+		"ics": testCalendar, "start": "2025-03-01T00:00:00Z", "end": "2025-04-01T00:00:00Z", "limit": 2,
+	})
+	if len(result.Events) != 2 || !result.Truncated || result.Events[0].Start != "2025-03-03T09:00:00Z" {
+		t.Errorf("expected the first two events, got %+v", result)
+	}
+
+	// The standup repeats six times, one of which is excluded.
+	result = callParseICS(t, tool, map[string]interface{}{
+		"ics": testCalendar, "start": "2025-03-01T00:00:00Z", "end": "2026-01-01T00:00:00Z",
+	})
+	standups := 0
+	for _, e := range result.Events {
+		if e.Summary == "Standup" {
+			standups++
+		}
+	}
+	if standups != 5 {
+		t.Errorf("expected 5 standups, got %d", standups)
+	}
+}
+
+// Test the recurrence rules parse_ics expands
+func TestICSRecurrence(t *testing.T) {
+	for _, tc := range []struct {
+		rule     string
+		expected []string
+	}{
+		{"FREQ=DAILY;INTERVAL=2;COUNT=3", []string{"2025-01-31", "2025-02-02", "2025-02-04"}},
+		{"FREQ=MONTHLY;COUNT=3", []string{"2025-01-31", "2025-03-31", "2025-05-31"}},
+		{"FREQ=YEARLY;UNTIL=20270201T000000Z", []string{"2025-01-31", "2026-01-31", "2027-01-31"}},
+		{"FREQ=WEEKLY;BYDAY=FR,SA;COUNT=3", []string{"2025-01-31", "2025-02-01", "2025-02-07"}},
+	} {
+		cal := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20250131T100000Z\nSUMMARY:x\nRRULE:" + tc.rule + "\nEND:VEVENT\nEND:VCALENDAR\n"
+		result := callParseICS(t, &parseICSTool{}, map[string]interface{}{"ics": cal, "start": "2025-01-01", "end": "2030-01-01"})
+		var got []string
+		for _, e := range result.Events {
+			got = append(got, e.Start[:10])
+		}
+		if strings.Join(got, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("%s: expected %v, got %v", tc.rule, tc.expected, got)
+		}
+	}
+
+	unbounded := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20000101T000000Z\nDURATION:PT1H\nRRULE:FREQ=DAILY\nSUMMARY:x\nEND:VEVENT\nEND:VCALENDAR\n"
+	result := callParseICS(t, &parseICSTool{}, map[string]interface{}{"ics": unbounded, "start": "2025-06-01T00:00:00Z", "end": "2025-06-03T00:00:00Z"})
+	if len(result.Events) != 2 {
+		t.Errorf("expected two days of an unbounded rule, got %+v", result.Events)
+	}
+}
+
+// Test reading calendars from files and URLs
+func TestParseICSSources(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "team.ics"), []byte(testCalendar), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	args := map[string]interface{}{"path": "team.ics", "start": "2025-03-10T00:00:00Z", "end": "2025-03-11T00:00:00Z"}
+	if len(callParseICS(t, &parseICSTool{ws: &Workspace{Root: dir}}, args).Events) != 2 {
+		t.Error("expected the events of the file")
+	}
+	for _, tc := range []struct {
+		tool *parseICSTool
+		path string
+	}{
+		{&parseICSTool{}, "team.ics"},
+		{&parseICSTool{ws: &Workspace{Root: dir}}, "../team.ics"},
+	} {
+		if _, err := tc.tool.Execute(map[string]interface{}{"path": tc.path}); err == nil {
+			t.Errorf("%s: expected an error", tc.path)
+		}
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/team.ics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(testCalendar))
+	}))
+	defer ts.Close()
+	args = map[string]interface{}{"url": ts.URL + "/team.ics", "start": "2025-03-10T00:00:00Z", "end": "2025-03-11T00:00:00Z"}
+	if len(callParseICS(t, &parseICSTool{allowPrivate: true}, args).Events) != 2 {
+		t.Error("expected the events of the URL")
+	}
+	var failure *toolResultError
+	if _, err := (&parseICSTool{}).Execute(args); !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "non-public address") {
+		t.Errorf("expected loopback addresses refused, got %v", err)
+	}
+	if _, err := (&parseICSTool{allowPrivate: true}).Execute(map[string]interface{}{"url": ts.URL + "/missing.ics"}); !errors.As(err, &failure) {
+		t.Errorf("expected a missing calendar to fail, got %v", err)
+	}
+}
+
+// Test rejecting invalid arguments and calendars
+func TestParseICSErrors(t *testing.T) {
+	tool := &parseICSTool{}
+	for _, args := range []map[string]interface{}{
+		{},
+		{"ics": testCalendar, "url": "https://example.com/a.ics"},
+		{"ics": testCalendar, "start": "tomorrow"},
+		{"ics": testCalendar, "start": "2025-03-02", "end": "2025-03-01"},
+		{"ics": testCalendar, "limit": 0},
+		{"url": "ftp://example.com/a.ics"},
+	} {
+		if _, err := tool.Execute(args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+	for _, cal := range []string{
+		"not a calendar",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:no start\nEND:VEVENT\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:yesterday\nEND:VEVENT\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\nBEGIN:VEVENT\nDTSTART:20250101T000000Z\nRRULE:FREQ=HOURLY\nEND:VEVENT\nEND:VCALENDAR",
+	} {
+		var failure *toolResultError
+		if _, err := tool.Execute(map[string]interface{}{"ics": cal}); !errors.As(err, &failure) {
+			t.Errorf("%q: expected an error result, got %v", cal, err)
+		}
+	}
+	if d, err := parseICSDuration("-P1DT2H30M"); err != nil || d != -(26*time.Hour+30*time.Minute) {
+		t.Errorf("unexpected duration %v, %v", d, err)
+	}
+}
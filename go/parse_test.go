@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// Test the messages rejected by the parsing layer and their error codes
+func TestParseMessage(t *testing.T) {
+	cases := []struct {
+		input string
+		code  int // 0 if the message is accepted
+	}{
+		{`{"jsonrpc":"2.0","method":"ping","id":1}`, 0},
+		{`{"jsonrpc":"2.0","method":"tools/call","params":{"arguments":{"n":1e400,"big":123456789012345678901234567890}},"id":1}`, 0},
+		{`  {"jsonrpc":"2.0","method":"ping"}  `, 0},
+		{`{"jsonrpc":"2.0","method":"ping","id":`, -32700},
+		{`{"jsonrpc":"2.0","method":"ping"}{}`, -32700},
+		{`{"jsonrpc":"2.0","method":"ping"} x`, -32700},
+		{"{\"jsonrpc\":\"2.0\",\"method\":\"p\xe2\x82\"}", -32700},
+		{"\xff\xfe", -32700},
+		{``, -32700},
+		{`[{"jsonrpc":"2.0","method":"ping","id":1}]`, -32600},
+		{`"ping"`, -32600},
+		{`{"jsonrpc":"2.0","method":5}`, -32600},
+		{`{"jsonrpc":"2.0","method":"ping","method":"tools/list","id":1}`, -32600},
+		{`{"jsonrpc":"2.0","method":"x","params":{"a":{"k":1,"k":2}},"id":1}`, -32600},
+		{`{"jsonrpc":"2.0","method":"x","params":{"a":{"k":1},"b":{"k":2}},"id":1}`, 0},
+		{`{"params":` + strings.Repeat("[", maxMessageDepth-1) + strings.Repeat("]", maxMessageDepth-1) + `}`, 0},
+		{`{"params":` + strings.Repeat("[", maxMessageDepth) + strings.Repeat("]", maxMessageDepth) + `}`, -32600},
+	}
+	for _, c := range cases {
+		req, err := parseMessage([]byte(c.input))
+		switch {
+		case c.code == 0 && err != nil:
+			t.Errorf("%q: unexpected error %v", c.input, err)
+		case c.code == 0 && req == nil:
+			t.Errorf("%q: no request", c.input)
+		case c.code != 0 && err == nil:
+			t.Errorf("%q: expected error %d, got none", c.input, c.code)
+		case c.code != 0 && err.code != c.code:
+			t.Errorf("%q: expected error %d, got %d (%s)", c.input, c.code, err.code, err.message)
+		}
+	}
+}
+
+// Test that a duplicated method is rejected rather than resolved, so the
+// server never acts on a member another parser would have ignored
+func TestDuplicateKeyRejected(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"tools/list","method":"tools/call","params":{"name":"echo","arguments":{"message":"x"}},"id":1}` + "\n"
+	lines := runServerInput(t, NewServer(WithTools(&echoTool{})), input)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"code":-32600`) || !strings.Contains(lines[0], "duplicate key") {
+		t.Errorf("unexpected output %v", lines)
+	}
+}
+
+// FuzzParseMessage checks that parseMessage never panics and accepts only
+// valid, UTF-8 encoded JSON objects.
+func FuzzParseMessage(f *testing.F) {
+	for _, seed := range []string{
+		`{"jsonrpc":"2.0","method":"ping","id":1}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":"a"}`,
+		`{"a":{"b":[1,2,{"c":null}]},"a":1}`,
+		`[1,2,3]`,
+		"{\"method\":\"\xc3\"}",
+		`{"id":1e999}`,
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req, err := parseMessage(data)
+		if err != nil {
+			if req != nil || (err.code != -32700 && err.code != -32600) {
+				t.Fatalf("unexpected rejection %v of %q", err, data)
+			}
+			return
+		}
+		if !json.Valid(data) || !utf8.Valid(data) {
+			t.Fatalf("accepted invalid input %q", data)
+		}
+		if trimmed := bytes.TrimSpace(data); trimmed[0] != '{' {
+			t.Fatalf("accepted a non-object %q", data)
+		}
+	})
+}
+
+// FuzzServe checks that no input, however malformed, stops the server from
+// answering the next well-formed request.
+func FuzzServe(f *testing.F) {
+	for _, seed := range []string{
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":1}`,
+		`{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-03-26"},"id":"x"}`,
+		`{"jsonrpc":"2.0","result":{},"id":"srv-1"}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":{}}},"id":[]}`,
+		"\x00\xff\r\n\n{",
+		`{"jsonrpc":"2.0","method":"resources/read","params":{"uri":5},"id":2}`,
+	} {
+		f.Add([]byte(seed))
+	}
+	s := NewServer(WithTools(&echoTool{}, &countTextTool{}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		input := testHandshake + string(data) + "\n" + `{"jsonrpc":"2.0","method":"tools/list","id":"fuzz-sentinel"}` + "\n"
+		var out bytes.Buffer
+		if err := s.Serve(context.Background(), strings.NewReader(input), &out); err != nil {
+			t.Fatalf("Serve error: %v", err)
+		}
+		found := false
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if !json.Valid([]byte(line)) {
+				t.Fatalf("invalid output line %q for input %q", line, data)
+			}
+			if strings.Contains(line, `"id":"fuzz-sentinel"`) && strings.Contains(line, `"tools":[`) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("no answer to the request following %q:\n%s", data, out.String())
+		}
+	})
+}
+
+// Test that a byte order mark, CRLF line endings and messages pretty-printed
+// over several lines are accepted
+func TestInputTolerance(t *testing.T) {
+	pretty := "{\r\n  \"jsonrpc\": \"2.0\",\r\n  \"method\": \"tools/call\",\r\n  \"params\": {\r\n" +
+		"    \"name\": \"echo\",\r\n    \"arguments\": {\"message\": \"{\\\"a\\\": [\"}\r\n  },\r\n  \"id\": 1\r\n}\r\n"
+	input := "\ufeff" + strings.ReplaceAll(testHandshake, "\n", "\r\n") + pretty +
+		"{\"jsonrpc\":\"2.0\",\"method\":\"tools/list\",\"id\":2}\r\n"
+	var out bytes.Buffer
+	if err := NewServer(WithTools(&echoTool{})).Serve(context.Background(), strings.NewReader(input), handshakeFilter{&out}); err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+	output := out.String()
+	if strings.Contains(output, `"error"`) || !strings.Contains(output, `{\"a\": [`) || !strings.Contains(output, `"id":2`) {
+		t.Errorf("expected both requests to be answered, got %q", output)
+	}
+}
+
+// Test that a message left open ends at the next whole message, and that a
+// pretty-printed message over the size limit is rejected as a whole
+func TestMultilineMessageErrors(t *testing.T) {
+	list := `{"jsonrpc":"2.0","method":"tools/list","id":2}`
+	lines := runServerInput(t, NewServer(), "{\n  \"jsonrpc\": \"2.0\",\n"+list+"\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"code":-32700`) || !strings.Contains(lines[1], `"id":2`) {
+		t.Errorf("expected a parse error and then the list, got %v", lines)
+	}
+
+	big := "{\n  \"jsonrpc\": \"2.0\",\n  \"method\": \"ping\",\n  \"params\": {\n" +
+		strings.Repeat("    \"padding\": \""+strings.Repeat("a", 100)+"\",\n", 20) + "  },\n  \"id\": 1\n}\n"
+	lines = runServerInput(t, NewServer(WithMaxMessageSize(1024)), big+list+"\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "too large") || !strings.Contains(lines[1], `"id":2`) {
+		t.Errorf("expected the message to be rejected once, got %v", lines)
+	}
+}
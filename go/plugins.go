@@ -0,0 +1,50 @@
+//go:build (linux || darwin || freebsd) && cgo
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+)
+
+// pluginsSupported reports whether this build can load Go plugins.
+const pluginsSupported = true
+
+// loadPlugins opens every .so file in dir and returns the tools they
+// provide. Each plugin must export a function
+//
+//	func Tools() []mcp.Tool
+//
+// and be built with -buildmode=plugin against the same version of package
+// mcp as the server.
+func loadPlugins(dir string) ([]MCPTool, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var loaded []MCPTool
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", path, err)
+		}
+		sym, err := p.Lookup("Tools")
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", path, err)
+		}
+		toolsFunc, ok := sym.(func() []MCPTool)
+		if !ok {
+			return nil, fmt.Errorf("plugin %s: Tools has type %T, want func() []mcp.Tool", path, sym)
+		}
+		loaded = append(loaded, toolsFunc()...)
+	}
+	return loaded, nil
+}
@@ -0,0 +1,25 @@
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// pluginsSupported reports whether this build can load Go plugins.
+const pluginsSupported = false
+
+// loadPlugins reports that Go plugins are unavailable if dir holds any:
+// they need cgo and are only supported on Linux, macOS, and FreeBSD.
+// WebAssembly plugins load regardless.
+func loadPlugins(dir string) ([]MCPTool, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	if paths, _ := filepath.Glob(filepath.Join(dir, "*.so")); len(paths) > 0 {
+		return nil, errors.New("this build does not support Go plugins")
+	}
+	return nil, nil
+}
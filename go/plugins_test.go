@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Test that tools are loaded from a plugin directory
+func TestLoadPlugins(t *testing.T) {
+	if !pluginsSupported {
+		t.Skip("Go plugins are not supported by this build")
+	}
+	if testing.Short() {
+		t.Skip("building a plugin is slow")
+	}
+	dir := t.TempDir()
+	// The plugin must be built like the test binary to be loadable.
+	args := []string{"build", "-buildmode=plugin", "-o", filepath.Join(dir, "reverse.so")}
+	if raceEnabled {
+		args = append(args, "-race")
+	}
+	build := exec.Command("go", append(args, "./testdata/plugin/reverse")...)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Skipf("cannot build plugin: %v\n%s", err, out)
+	}
+
+	loaded, err := loadPlugins(dir)
+	if err != nil {
+		t.Fatalf("loadPlugins error: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].Name() != "reverse" {
+		t.Fatalf("expected the reverse tool, got %v", loaded)
+	}
+	content, err := loaded[0].Execute(map[string]interface{}{"text": "abc"})
+	if err != nil || len(content) != 1 || content[0].Text != "cba" {
+		t.Errorf("unexpected result %v, %v", content, err)
+	}
+}
+
+// Test that broken plugin directories are reported
+func TestLoadPluginsErrors(t *testing.T) {
+	if _, err := loadPlugins(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing directory")
+	}
+	if !pluginsSupported {
+		// Only Go plugins are unavailable.
+		if _, err := loadPlugins(t.TempDir()); err != nil {
+			t.Errorf("expected a directory without Go plugins to load, got %v", err)
+		}
+		return
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bogus.so"), []byte("not a plugin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPlugins(dir); err == nil {
+		t.Error("expected an error for an invalid plugin")
+	}
+}
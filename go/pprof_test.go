@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test that the pprof profiles are served only with the token
+func TestPprofHandler(t *testing.T) {
+	h := pprofHandler("s3cret")
+	for _, tc := range []struct {
+		path, auth string
+		status     int
+	}{
+		{"/debug/pprof/", "", http.StatusUnauthorized},
+		{"/debug/pprof/heap", "Bearer wrong", http.StatusUnauthorized},
+		{"/debug/pprof/heap", "Basic s3cret", http.StatusUnauthorized},
+		{"/debug/pprof/", "Bearer s3cret", http.StatusOK},
+		{"/debug/pprof/heap?debug=1", "bearer s3cret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s with %q: expected status %d, got %d", tc.path, tc.auth, tc.status, rec.Code)
+		}
+		if tc.status == http.StatusUnauthorized && !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Bearer") {
+			t.Errorf("%s with %q: expected a bearer challenge", tc.path, tc.auth)
+		}
+	}
+}
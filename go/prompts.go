@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/client"
+)
+
+// promptPlaceholder matches the {{argument}} placeholders of a prompt
+// template.
+var promptPlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// promptLibrary serves the prompts of the Markdown (.md) and YAML (.yaml,
+// .yml) files under a directory. A Markdown file is one user message,
+// optionally after front matter naming and describing the prompt and its
+// arguments, with their suggested values; a YAML file holds the same fields with a template or a list
+// of messages. Files are reread when they change, so the catalog can be
+// edited while the server runs.
+type promptLibrary struct {
+	dir    string
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	files map[string]*promptFile // by path
+}
+
+// promptFile is a prompt file as last read.
+type promptFile struct {
+	modTime time.Time
+	size    int64
+	prompt  *libraryPrompt // nil if the file is invalid
+}
+
+// libraryPrompt is a prompt of the library.
+type libraryPrompt struct {
+	client.Prompt
+	messages []promptMessage
+	values   map[string][]string // suggested values of arguments, for completion
+	file     string              // relative to the directory
+}
+
+// promptMessage is a message of a prompt file, its content a template.
+type promptMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// promptSpec is the front matter of a Markdown prompt file, or the whole
+// of a YAML one.
+type promptSpec struct {
+	Name        string               `json:"name"`
+	Description string               `json:"description"`
+	Arguments   []promptArgumentSpec `json:"arguments"`
+	Template    string               `json:"template"` // YAML files only
+	Messages    []promptMessage      `json:"messages"` // YAML files only
+}
+
+// promptArgumentSpec is an argument in a prompt file. Its values are
+// offered as completions; others are still accepted.
+type promptArgumentSpec struct {
+	client.PromptArgument
+	Values []string `json:"values"`
+}
+
+// newPromptLibrary returns the library of the prompt files under dir.
+// Invalid files are logged and left out, here and whenever they change.
+func newPromptLibrary(dir string, logger *slog.Logger) (*promptLibrary, error) {
+	if fi, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	l := &promptLibrary{dir: dir, logger: logger, files: map[string]*promptFile{}}
+	l.prompts()
+	return l, nil
+}
+
+// WithPromptDir serves the prompts of l, along with those of the
+// upstreams. A library prompt hides an upstream prompt of the same name.
+func WithPromptDir(l *promptLibrary) Option {
+	return func(s *Server) {
+		s.library = l
+	}
+}
+
+// prompts rereads the changed files and returns the prompts sorted by
+// name. Of prompts with the same name, only the first file's is served.
+func (l *promptLibrary) prompts() []*libraryPrompt {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	seen := map[string]bool{}
+	changed := false
+	filepath.WalkDir(l.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != l.dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".md", ".yaml", ".yml":
+		default:
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		seen[path] = true
+		if f := l.files[path]; f != nil && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+			return nil
+		}
+		changed = true
+		rel, _ := filepath.Rel(l.dir, path)
+		f := &promptFile{modTime: info.ModTime(), size: info.Size()}
+		if f.prompt, err = loadPromptFile(path, rel); err != nil {
+			l.logger.Warn("skipping prompt file", "file", rel, "error", err)
+		}
+		l.files[path] = f
+		return nil
+	})
+
+	var prompts []*libraryPrompt
+	for path, f := range l.files {
+		if !seen[path] {
+			delete(l.files, path)
+			changed = true
+		} else if f.prompt != nil {
+			prompts = append(prompts, f.prompt)
+		}
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		if prompts[i].Name != prompts[j].Name {
+			return prompts[i].Name < prompts[j].Name
+		}
+		return prompts[i].file < prompts[j].file
+	})
+	kept := prompts[:0]
+	for i, p := range prompts {
+		if i > 0 && p.Name == prompts[i-1].Name {
+			if changed {
+				l.logger.Warn("skipping prompt with a duplicate name", "file", p.file, "name", p.Name)
+			}
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// prompt returns the named prompt, or nil if there is none.
+func (l *promptLibrary) prompt(name string) *libraryPrompt {
+	for _, p := range l.prompts() {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// loadPromptFile reads the prompt file at path, rel within the
+// directory. The prompt is named after the file unless the file names
+// it.
+func loadPromptFile(path, rel string) (*libraryPrompt, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec promptSpec
+	if ext := filepath.Ext(path); ext == ".md" {
+		front, body, err := splitFrontMatter(string(data))
+		if err != nil {
+			return nil, err
+		}
+		if front != "" {
+			if err := decodeYAML(front, &spec); err != nil {
+				return nil, fmt.Errorf("front matter: %v", err)
+			}
+			if spec.Template != "" || spec.Messages != nil {
+				return nil, fmt.Errorf("front matter: the Markdown body is the template")
+			}
+		}
+		spec.Messages = []promptMessage{{Role: "user", Content: body}}
+	} else {
+		if err := decodeYAML(string(data), &spec); err != nil {
+			return nil, err
+		}
+		switch {
+		case spec.Template != "" && spec.Messages != nil:
+			return nil, fmt.Errorf("either a template or messages, not both")
+		case spec.Template != "":
+			spec.Messages = []promptMessage{{Role: "user", Content: spec.Template}}
+		case len(spec.Messages) == 0:
+			return nil, fmt.Errorf("a template or messages are required")
+		}
+	}
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if strings.ContainsAny(spec.Name, " \t\r\n") {
+		return nil, fmt.Errorf("invalid prompt name %q", spec.Name)
+	}
+
+	p := &libraryPrompt{Prompt: client.Prompt{Name: spec.Name, Description: spec.Description}, messages: spec.Messages, file: filepath.ToSlash(rel)}
+	declared := map[string]bool{}
+	for _, a := range spec.Arguments {
+		if a.Name == "" || declared[a.Name] {
+			return nil, fmt.Errorf("arguments need distinct names")
+		}
+		declared[a.Name] = true
+		p.Arguments = append(p.Arguments, a.PromptArgument)
+		if len(a.Values) > 0 {
+			if p.values == nil {
+				p.values = map[string][]string{}
+			}
+			p.values[a.Name] = a.Values
+		}
+	}
+	for _, m := range spec.Messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			return nil, fmt.Errorf("invalid message role %q: expected user or assistant", m.Role)
+		}
+		// Placeholders without a declared argument get a required one.
+		for _, match := range promptPlaceholder.FindAllStringSubmatch(m.Content, -1) {
+			if name := match[1]; !declared[name] {
+				declared[name] = true
+				p.Arguments = append(p.Arguments, client.PromptArgument{Name: name, Required: true})
+			}
+		}
+	}
+	return p, nil
+}
+
+// splitFrontMatter splits a Markdown document into its front matter,
+// between two --- lines at the start, and its body.
+func splitFrontMatter(doc string) (front, body string, err error) {
+	doc = strings.TrimPrefix(strings.ReplaceAll(doc, "\r\n", "\n"), "\ufeff")
+	if !strings.HasPrefix(doc, "---\n") {
+		return "", strings.TrimSpace(doc), nil
+	}
+	rest := doc[len("---\n"):]
+	if strings.HasPrefix(rest, "---\n") {
+		return "", strings.TrimSpace(rest[len("---\n"):]), nil
+	}
+	end := strings.Index(rest, "\n---\n")
+	if end < 0 {
+		if !strings.HasSuffix(rest, "\n---") {
+			return "", "", fmt.Errorf("front matter is not closed by a --- line")
+		}
+		return rest[:len(rest)-len("\n---")], "", nil
+	}
+	return rest[:end], strings.TrimSpace(rest[end+len("\n---\n"):]), nil
+}
+
+// decodeYAML decodes the YAML document data into v, through JSON, and
+// fails for fields v does not have.
+func decodeYAML(data string, v interface{}) error {
+	parsed, err := parseYAML(data)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(parsed)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// render returns the prompts/get result of p for the arguments args,
+// replacing each placeholder with its argument. Placeholders of omitted
+// optional arguments become empty.
+func (p *libraryPrompt) render(args map[string]string) (map[string]interface{}, error) {
+	for _, a := range p.Arguments {
+		if a.Required && args[a.Name] == "" {
+			return nil, fmt.Errorf("missing required argument %q", a.Name)
+		}
+	}
+	messages := make([]map[string]interface{}, 0, len(p.messages))
+	for _, m := range p.messages {
+		text := promptPlaceholder.ReplaceAllStringFunc(m.Content, func(ph string) string {
+			return args[promptPlaceholder.FindStringSubmatch(ph)[1]]
+		})
+		messages = append(messages, map[string]interface{}{
+			"role":    m.Role,
+			"content": map[string]interface{}{"type": "text", "text": text},
+		})
+	}
+	result := map[string]interface{}{"messages": messages}
+	if p.Description != "" {
+		result["description"] = p.Description
+	}
+	return result, nil
+}
+
+// promptsListResult returns the encoded prompts/list result. Like the
+// local resources, the library's prompts are listed anew each time.
+func (s *Server) promptsListResult() ([]byte, error) {
+	l, err := s.cachedListings()
+	if err != nil || s.library == nil {
+		return l.prompts, err
+	}
+	prompts := []client.Prompt{}
+	local := map[string]bool{}
+	for _, p := range s.library.prompts() {
+		prompts = append(prompts, p.Prompt)
+		local[p.Name] = true
+	}
+	for _, p := range s.listPrompts() {
+		if !local[p.Name] {
+			prompts = append(prompts, p)
+		}
+	}
+	return json.Marshal(map[string]interface{}{"prompts": prompts})
+}
+
+// sendLibraryPrompt writes the prompts/get response for the library
+// prompt p.
+func sendLibraryPrompt(w io.Writer, id interface{}, p *libraryPrompt, args map[string]string) {
+	result, err := p.render(args)
+	if err != nil {
+		sendError(w, id, -32602, "Invalid parameters: "+err.Error())
+		return
+	}
+	sendResponse(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  result,
+	})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"mcp-minimal-server-go/client"
+)
+
+// writePromptFiles writes prompt files into dir.
+func writePromptFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Test serving Markdown and YAML prompt files, and rereading them
+func TestPromptLibrary(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFiles(t, dir, map[string]string{
+		"review.md": `---
+name: code-review
+description: Review a change
+arguments:
+  - name: code
+    description: The code to review
+    required: true
+  - name: focus
+---
+Review this code, focusing on {{ focus }}:
+
+{{code}}
+`,
+		"team/greet.md": "Say hello to {{name}}.",
+		"chat.yaml": `description: A short exchange
+messages:
+  - role: user
+    content: What is {{topic}}?
+  - role: assistant
+    content: |
+      Let me explain {{topic}}.
+`,
+		"broken.yaml":    "template: [unclosed",
+		"dup.yml":        "name: greet\ntemplate: Hi",
+		".drafts/new.md": "Not yet",
+		"notes.txt":      "Not a prompt",
+	})
+	var log bytes.Buffer
+	library, err := newPromptLibrary(dir, slog.New(slog.NewTextHandler(&log, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), "file=broken.yaml") || !strings.Contains(log.String(), "file=team/greet.md name=greet") {
+		t.Errorf("expected warnings for the invalid and duplicate files, got %s", log.String())
+	}
+	u := pipeUpstream(t, NewServer(WithTools(), WithPromptDir(library)), upstreamConfig{Name: "prompts"})
+
+	expected := []client.Prompt{
+		{Name: "chat", Description: "A short exchange", Arguments: []client.PromptArgument{{Name: "topic", Required: true}}},
+		{Name: "code-review", Description: "Review a change", Arguments: []client.PromptArgument{
+			{Name: "code", Description: "The code to review", Required: true},
+			{Name: "focus"},
+		}},
+		{Name: "greet"},
+	}
+	if !reflect.DeepEqual(u.prompts, expected) {
+		t.Errorf("expected prompts %+v, got %+v", expected, u.prompts)
+	}
+
+	get := func(name string, args map[string]string) []map[string]interface{} {
+		t.Helper()
+		result, err := u.client.GetPrompt(context.Background(), name, args)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var messages []map[string]interface{}
+		if err := json.Unmarshal(result.Messages, &messages); err != nil {
+			t.Fatal(err)
+		}
+		return messages
+	}
+	messages := get("code-review", map[string]string{"code": "x := 1"})
+	if len(messages) != 1 || messages[0]["role"] != "user" ||
+		!reflect.DeepEqual(messages[0]["content"], map[string]interface{}{"type": "text", "text": "Review this code, focusing on :\n\nx := 1"}) {
+		t.Errorf("unexpected messages %v", messages)
+	}
+	messages = get("chat", map[string]string{"topic": "YAML"})
+	if len(messages) != 2 || messages[1]["role"] != "assistant" ||
+		messages[1]["content"].(map[string]interface{})["text"] != "Let me explain YAML.\n" {
+		t.Errorf("unexpected messages %v", messages)
+	}
+	for name, want := range map[string]string{"code-review": `missing required argument "code"`, "missing": "Unknown prompt: missing"} {
+		_, err := u.client.GetPrompt(context.Background(), name, nil)
+		var rpcErr *client.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 || !strings.Contains(rpcErr.Message, want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, want, err)
+		}
+	}
+
+	// Edits, new files, and removals show without a restart.
+	writePromptFiles(t, dir, map[string]string{"team/greet.md": "Say good morning to {{name}}.", "new.md": "Brand new"})
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "team/greet.md"), later, later)
+	os.Remove(filepath.Join(dir, "dup.yml"))
+	os.Remove(filepath.Join(dir, "chat.yaml"))
+	prompts, err := u.client.ListPrompts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range prompts {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "code-review,greet,new" {
+		t.Errorf("unexpected prompts after the changes: %v", names)
+	}
+	if text := get("greet", map[string]string{"name": "Ann"})[0]["content"].(map[string]interface{})["text"]; text != "Say good morning to Ann." {
+		t.Errorf("expected the edited prompt, got %q", text)
+	}
+}
+
+// Test rejecting invalid prompt files
+func TestLoadPromptFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown.md":  "---\ntitle: x\n---\nHi",
+		"unclosed.md": "---\nname: x\nHi",
+		"both.yaml":   "template: a\nmessages:\n  - role: user\n    content: b",
+		"empty.yaml":  "description: nothing",
+		"role.yaml":   "messages:\n  - role: system\n    content: b",
+		"name.yaml":   "name: two words\ntemplate: a",
+		"args.yaml":   "template: a\narguments:\n  - name: x\n  - name: x",
+	} {
+		writePromptFiles(t, dir, map[string]string{name: content})
+		if _, err := loadPromptFile(filepath.Join(dir, name), name); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,920 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Field types of FieldDescriptorProto.
+const (
+	protoDouble   = 1
+	protoFloat    = 2
+	protoInt64    = 3
+	protoUint64   = 4
+	protoInt32    = 5
+	protoFixed64  = 6
+	protoFixed32  = 7
+	protoBool     = 8
+	protoString   = 9
+	protoGroup    = 10
+	protoMessage  = 11
+	protoBytes    = 12
+	protoUint32   = 13
+	protoEnum     = 14
+	protoSfixed32 = 15
+	protoSfixed64 = 16
+	protoSint32   = 17
+	protoSint64   = 18
+)
+
+// protoLabelRepeated is the label of repeated fields.
+const protoLabelRepeated = 3
+
+// errProtoTruncated is returned for messages that end inside a field.
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+// protoValue is one field of an encoded message. Varint and fixed fields
+// have their value in u, length-delimited ones in b.
+type protoValue struct {
+	num  int
+	wire int
+	u    uint64
+	b    []byte
+}
+
+// decodeProto splits an encoded message into its fields, in order. The
+// values of length-delimited fields alias data.
+func decodeProto(data []byte) ([]protoValue, error) {
+	var values []protoValue
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errProtoTruncated
+		}
+		data = data[n:]
+		v := protoValue{num: int(key >> 3), wire: int(key & 7)}
+		if v.num <= 0 || key>>3 > math.MaxInt32 {
+			return nil, fmt.Errorf("protobuf: invalid field number %d", key>>3)
+		}
+		switch v.wire {
+		case wireVarint:
+			if v.u, n = binary.Uvarint(data); n <= 0 {
+				return nil, errProtoTruncated
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, errProtoTruncated
+			}
+			v.u, data = binary.LittleEndian.Uint64(data), data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, errProtoTruncated
+			}
+			v.u, data = uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return nil, errProtoTruncated
+			}
+			v.b, data = data[n:n+int(length)], data[n+int(length):]
+		default:
+			return nil, fmt.Errorf("protobuf: unsupported wire type %d", v.wire)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// appendProtoTag appends the key of a field.
+func appendProtoTag(b []byte, num, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(num)<<3|uint64(wire))
+}
+
+// appendProtoVarint appends a varint field.
+func appendProtoVarint(b []byte, num int, v uint64) []byte {
+	return binary.AppendUvarint(appendProtoTag(b, num, wireVarint), v)
+}
+
+// appendProtoBytes appends a length-delimited field.
+func appendProtoBytes(b []byte, num int, data []byte) []byte {
+	b = binary.AppendUvarint(appendProtoTag(b, num, wireBytes), uint64(len(data)))
+	return append(b, data...)
+}
+
+// appendProtoString appends a string field.
+func appendProtoString(b []byte, num int, s string) []byte {
+	b = binary.AppendUvarint(appendProtoTag(b, num, wireBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// protoField describes a field of a message type.
+type protoField struct {
+	name     string
+	jsonName string
+	number   int
+	repeated bool
+	typ      int
+	typeName string // the fully qualified message or enum type, e.g. ".pkg.Msg"
+}
+
+// protoMessageType describes a message type.
+type protoMessageType struct {
+	fullName string
+	fields   []*protoField // in declaration order
+	byNumber map[int]*protoField
+	byName   map[string]*protoField // by proto and JSON name
+	mapEntry bool                   // the synthesized entry type of a map field
+}
+
+// protoEnumType describes an enum type.
+type protoEnumType struct {
+	names    []string // in declaration order
+	byName   map[string]int32
+	byNumber map[int32]string
+}
+
+// protoService describes a service.
+type protoService struct {
+	fullName string
+	methods  []protoMethod
+}
+
+// protoMethod describes a method of a service.
+type protoMethod struct {
+	name            string
+	input, output   string // fully qualified message types
+	clientStreaming bool
+	serverStreaming bool
+	idempotency     int // MethodOptions.idempotency_level
+}
+
+// Idempotency levels of MethodOptions.
+const (
+	protoNoSideEffects = 1
+	protoIdempotent    = 2
+)
+
+// protoRegistry holds the types and services of a set of file descriptors.
+// Type names are fully qualified with a leading dot, as in descriptors.
+type protoRegistry struct {
+	files    map[string]bool
+	messages map[string]*protoMessageType
+	enums    map[string]*protoEnumType
+	services map[string]*protoService
+}
+
+// newProtoRegistry returns an empty registry.
+func newProtoRegistry() *protoRegistry {
+	return &protoRegistry{
+		files:    map[string]bool{},
+		messages: map[string]*protoMessageType{},
+		enums:    map[string]*protoEnumType{},
+		services: map[string]*protoService{},
+	}
+}
+
+// addFile adds the types and services of an encoded FileDescriptorProto
+// and returns the names of the files it depends on. A file already added
+// is ignored.
+func (r *protoRegistry) addFile(data []byte) (name string, deps []string, err error) {
+	values, err := decodeProto(data)
+	if err != nil {
+		return "", nil, err
+	}
+	var pkg string
+	var messages, enums, services [][]byte
+	for _, v := range values {
+		switch v.num {
+		case 1:
+			name = string(v.b)
+		case 2:
+			pkg = string(v.b)
+		case 3:
+			deps = append(deps, string(v.b))
+		case 4:
+			messages = append(messages, v.b)
+		case 5:
+			enums = append(enums, v.b)
+		case 6:
+			services = append(services, v.b)
+		}
+	}
+	if r.files[name] {
+		return name, deps, nil
+	}
+	r.files[name] = true
+	scope := ""
+	if pkg != "" {
+		scope = "." + pkg
+	}
+	for _, m := range messages {
+		if err := r.addMessage(scope, m); err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for _, e := range enums {
+		if err := r.addEnum(scope, e); err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for _, s := range services {
+		if err := r.addService(scope, s); err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return name, deps, nil
+}
+
+// addMessage adds an encoded DescriptorProto declared in scope, and the
+// types nested in it.
+func (r *protoRegistry) addMessage(scope string, data []byte) error {
+	values, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+	m := &protoMessageType{byNumber: map[int]*protoField{}, byName: map[string]*protoField{}}
+	var nested, enums [][]byte
+	for _, v := range values {
+		switch v.num {
+		case 1:
+			m.fullName = scope + "." + string(v.b)
+		case 2:
+			f, err := decodeProtoField(v.b)
+			if err != nil {
+				return err
+			}
+			m.fields = append(m.fields, f)
+		case 3:
+			nested = append(nested, v.b)
+		case 4:
+			enums = append(enums, v.b)
+		case 7:
+			options, err := decodeProto(v.b)
+			if err != nil {
+				return err
+			}
+			for _, o := range options {
+				if o.num == 7 && o.wire == wireVarint { // map_entry
+					m.mapEntry = o.u != 0
+				}
+			}
+		}
+	}
+	for _, f := range m.fields {
+		m.byNumber[f.number] = f
+		m.byName[f.name] = f
+		m.byName[f.jsonName] = f
+	}
+	r.messages[m.fullName] = m
+	for _, n := range nested {
+		if err := r.addMessage(m.fullName, n); err != nil {
+			return err
+		}
+	}
+	for _, e := range enums {
+		if err := r.addEnum(m.fullName, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeProtoField decodes a FieldDescriptorProto.
+func decodeProtoField(data []byte) (*protoField, error) {
+	values, err := decodeProto(data)
+	if err != nil {
+		return nil, err
+	}
+	f := &protoField{}
+	for _, v := range values {
+		switch v.num {
+		case 1:
+			f.name = string(v.b)
+		case 3:
+			f.number = int(v.u)
+		case 4:
+			f.repeated = v.u == protoLabelRepeated
+		case 5:
+			f.typ = int(v.u)
+		case 6:
+			f.typeName = string(v.b)
+		case 10:
+			f.jsonName = string(v.b)
+		}
+	}
+	if f.jsonName == "" {
+		f.jsonName = protoJSONName(f.name)
+	}
+	if f.typ == protoGroup {
+		return nil, fmt.Errorf("field %s: groups are not supported", f.name)
+	}
+	return f, nil
+}
+
+// protoJSONName returns the JSON name protoc derives from a field name:
+// underscores are dropped and the letter after each is capitalized.
+func protoJSONName(name string) string {
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper && 'a' <= c && c <= 'z':
+			b.WriteRune(c - 'a' + 'A')
+			upper = false
+		default:
+			b.WriteRune(c)
+			upper = false
+		}
+	}
+	return b.String()
+}
+
+// addEnum adds an encoded EnumDescriptorProto declared in scope.
+func (r *protoRegistry) addEnum(scope string, data []byte) error {
+	values, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+	e := &protoEnumType{byName: map[string]int32{}, byNumber: map[int32]string{}}
+	var fullName string
+	for _, v := range values {
+		switch v.num {
+		case 1:
+			fullName = scope + "." + string(v.b)
+		case 2:
+			fields, err := decodeProto(v.b)
+			if err != nil {
+				return err
+			}
+			var name string
+			var number int32
+			for _, f := range fields {
+				switch f.num {
+				case 1:
+					name = string(f.b)
+				case 2:
+					number = int32(f.u)
+				}
+			}
+			e.names = append(e.names, name)
+			e.byName[name] = number
+			if _, ok := e.byNumber[number]; !ok { // the first of aliases
+				e.byNumber[number] = name
+			}
+		}
+	}
+	r.enums[fullName] = e
+	return nil
+}
+
+// addService adds an encoded ServiceDescriptorProto declared in scope.
+func (r *protoRegistry) addService(scope string, data []byte) error {
+	values, err := decodeProto(data)
+	if err != nil {
+		return err
+	}
+	s := &protoService{}
+	for _, v := range values {
+		switch v.num {
+		case 1:
+			s.fullName = strings.TrimPrefix(scope+"."+string(v.b), ".")
+		case 2:
+			fields, err := decodeProto(v.b)
+			if err != nil {
+				return err
+			}
+			var m protoMethod
+			for _, f := range fields {
+				switch f.num {
+				case 1:
+					m.name = string(f.b)
+				case 2:
+					m.input = string(f.b)
+				case 3:
+					m.output = string(f.b)
+				case 4:
+					options, err := decodeProto(f.b)
+					if err != nil {
+						return err
+					}
+					for _, o := range options {
+						if o.num == 34 && o.wire == wireVarint { // idempotency_level
+							m.idempotency = int(o.u)
+						}
+					}
+				case 5:
+					m.clientStreaming = f.u != 0
+				case 6:
+					m.serverStreaming = f.u != 0
+				}
+			}
+			s.methods = append(s.methods, m)
+		}
+	}
+	r.services[s.fullName] = s
+	return nil
+}
+
+// message returns the named message type.
+func (r *protoRegistry) message(name string) (*protoMessageType, error) {
+	m, ok := r.messages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown message type %s", strings.TrimPrefix(name, "."))
+	}
+	return m, nil
+}
+
+// maxProtoDepth bounds the nesting of messages converted to and from JSON,
+// and of the schemas generated for them, so that recursive types end.
+const maxProtoDepth = 32
+
+// schema returns the JSON Schema of the JSON form of message type name.
+// Message fields nested deeper than maxProtoDepth, as recursive types are,
+// accept any object.
+func (r *protoRegistry) schema(name string, depth int) map[string]interface{} {
+	m, ok := r.messages[name]
+	if !ok || depth >= maxProtoDepth {
+		return map[string]interface{}{"type": "object"}
+	}
+	properties := map[string]interface{}{}
+	for _, f := range m.fields {
+		properties[f.jsonName] = r.fieldSchema(f, depth)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// fieldSchema returns the JSON Schema of a field's JSON form.
+func (r *protoRegistry) fieldSchema(f *protoField, depth int) map[string]interface{} {
+	if entry, ok := r.messages[f.typeName]; ok && entry.mapEntry {
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": r.fieldSchema(entry.byNumber[2], depth+1),
+		}
+	}
+	var schema map[string]interface{}
+	switch f.typ {
+	case protoDouble, protoFloat:
+		schema = map[string]interface{}{"type": "number"}
+	case protoInt32, protoSint32, protoSfixed32, protoUint32, protoFixed32:
+		schema = map[string]interface{}{"type": "integer"}
+	case protoInt64, protoSint64, protoSfixed64, protoUint64, protoFixed64:
+		// Encoded as strings, since JSON numbers lose their precision.
+		schema = map[string]interface{}{"type": []string{"integer", "string"}}
+	case protoBool:
+		schema = map[string]interface{}{"type": "boolean"}
+	case protoString:
+		schema = map[string]interface{}{"type": "string"}
+	case protoBytes:
+		schema = map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+	case protoEnum:
+		schema = map[string]interface{}{"type": "string"}
+		if e, ok := r.enums[f.typeName]; ok {
+			schema["enum"] = e.names
+		}
+	default:
+		schema = r.schema(f.typeName, depth+1)
+	}
+	if f.repeated {
+		return map[string]interface{}{"type": "array", "items": schema}
+	}
+	return schema
+}
+
+// encodeJSON encodes the JSON form of a message of type name, such as tool
+// arguments, following the proto3 JSON mapping: fields go by their JSON or
+// proto name, 64-bit integers may be strings, enums are names or numbers,
+// and bytes are base64.
+func (r *protoRegistry) encodeJSON(name string, obj map[string]interface{}, depth int) ([]byte, error) {
+	m, err := r.message(name)
+	if err != nil {
+		return nil, err
+	}
+	if depth >= maxProtoDepth {
+		return nil, errors.New("message nested too deeply")
+	}
+	var b []byte
+	for _, key := range sortedKeys(obj) {
+		v := obj[key]
+		f, ok := m.byName[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", key)
+		}
+		if v == nil {
+			continue
+		}
+		if b, err = r.appendField(b, f, v, depth); err != nil {
+			return nil, fmt.Errorf("field %q: %w", key, err)
+		}
+	}
+	return b, nil
+}
+
+// appendField appends the encoding of a field's JSON value.
+func (r *protoRegistry) appendField(b []byte, f *protoField, v interface{}, depth int) ([]byte, error) {
+	if entry, ok := r.messages[f.typeName]; ok && entry.mapEntry {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("expected an object")
+		}
+		for _, key := range sortedKeys(obj) {
+			e, err := r.appendValue(nil, entry.byNumber[1], key, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			if e, err = r.appendValue(e, entry.byNumber[2], obj[key], depth+1); err != nil {
+				return nil, fmt.Errorf("key %q: %w", key, err)
+			}
+			b = appendProtoBytes(b, f.number, e)
+		}
+		return b, nil
+	}
+	if !f.repeated {
+		return r.appendValue(b, f, v, depth)
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.New("expected an array")
+	}
+	var err error
+	for i, item := range items {
+		if b, err = r.appendValue(b, f, item, depth); err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	return b, nil
+}
+
+// appendValue appends a single, unrepeated value of field f.
+func (r *protoRegistry) appendValue(b []byte, f *protoField, v interface{}, depth int) ([]byte, error) {
+	switch f.typ {
+	case protoMessage:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("expected an object")
+		}
+		data, err := r.encodeJSON(f.typeName, obj, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoBytes(b, f.number, data), nil
+	case protoString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected a string")
+		}
+		return appendProtoString(b, f.number, s), nil
+	case protoBytes:
+		s, ok := v.(string)
+		if !ok {
+			return nil, errors.New("expected a base64 string")
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			if data, err = base64.URLEncoding.DecodeString(s); err != nil {
+				return nil, errors.New("expected a base64 string")
+			}
+		}
+		return appendProtoBytes(b, f.number, data), nil
+	case protoBool:
+		t, ok := v.(bool)
+		if !ok {
+			return nil, errors.New("expected a boolean")
+		}
+		var u uint64
+		if t {
+			u = 1
+		}
+		return appendProtoVarint(b, f.number, u), nil
+	case protoEnum:
+		if s, ok := v.(string); ok {
+			e, ok := r.enums[f.typeName]
+			n, known := int32(0), false
+			if ok {
+				n, known = e.byName[s]
+			}
+			if !known {
+				return nil, fmt.Errorf("unknown enum value %q", s)
+			}
+			return appendProtoVarint(b, f.number, uint64(int64(n))), nil
+		}
+		n, err := jsonInteger(v, 32, true)
+		if err != nil {
+			return nil, err
+		}
+		return appendProtoVarint(b, f.number, uint64(n)), nil
+	case protoDouble, protoFloat:
+		x, err := jsonFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		if f.typ == protoFloat {
+			b = appendProtoTag(b, f.number, wireFixed32)
+			return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(x))), nil
+		}
+		b = appendProtoTag(b, f.number, wireFixed64)
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(x)), nil
+	}
+
+	bits, signed := 64, true
+	switch f.typ {
+	case protoInt32, protoSint32, protoSfixed32:
+		bits = 32
+	case protoUint32, protoFixed32:
+		bits, signed = 32, false
+	case protoUint64, protoFixed64:
+		signed = false
+	}
+	n, err := jsonInteger(v, bits, signed)
+	if err != nil {
+		return nil, err
+	}
+	switch f.typ {
+	case protoSint32, protoSint64:
+		return appendProtoVarint(b, f.number, uint64(n<<1^(n>>63))), nil
+	case protoFixed32, protoSfixed32:
+		b = appendProtoTag(b, f.number, wireFixed32)
+		return binary.LittleEndian.AppendUint32(b, uint32(n)), nil
+	case protoFixed64, protoSfixed64:
+		b = appendProtoTag(b, f.number, wireFixed64)
+		return binary.LittleEndian.AppendUint64(b, uint64(n)), nil
+	}
+	return appendProtoVarint(b, f.number, uint64(n)), nil
+}
+
+// jsonInteger converts a JSON number or numeric string to an integer of
+// the given size, returning unsigned values in the bits of an int64.
+func jsonInteger(v interface{}, bits int, signed bool) (int64, error) {
+	var s string
+	switch v := v.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("expected an integer, got %v", v)
+		}
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return 0, errors.New("expected an integer")
+	}
+	if signed {
+		n, err := strconv.ParseInt(s, 10, bits)
+		if err != nil {
+			return 0, fmt.Errorf("expected a %d-bit integer, got %q", bits, s)
+		}
+		return n, nil
+	}
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err != nil {
+		return 0, fmt.Errorf("expected an unsigned %d-bit integer, got %q", bits, s)
+	}
+	return int64(n), nil
+}
+
+// jsonFloat converts a JSON number, or "NaN", "Infinity", or "-Infinity",
+// to a float.
+func jsonFloat(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case string:
+		switch v {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		if x, err := strconv.ParseFloat(v, 64); err == nil {
+			return x, nil
+		}
+	}
+	return 0, errors.New("expected a number")
+}
+
+// decodeJSON decodes a message of type name into its JSON form, the
+// inverse of encodeJSON. Fields not in the type are dropped.
+func (r *protoRegistry) decodeJSON(name string, data []byte, depth int) (map[string]interface{}, error) {
+	m, err := r.message(name)
+	if err != nil {
+		return nil, err
+	}
+	if depth >= maxProtoDepth {
+		return nil, errors.New("message nested too deeply")
+	}
+	values, err := decodeProto(data)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	for _, v := range values {
+		f, ok := m.byNumber[v.num]
+		if !ok {
+			continue
+		}
+		if entry, ok := r.messages[f.typeName]; ok && entry.mapEntry {
+			if v.wire != wireBytes {
+				return nil, fmt.Errorf("field %s: wrong wire type", f.name)
+			}
+			key, value, err := r.decodeMapEntry(entry, v.b, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			entries, _ := obj[f.jsonName].(map[string]interface{})
+			if entries == nil {
+				entries = map[string]interface{}{}
+				obj[f.jsonName] = entries
+			}
+			entries[key] = value
+			continue
+		}
+		if f.repeated && v.wire == wireBytes && packable(f.typ) {
+			items, _ := obj[f.jsonName].([]interface{})
+			packed, err := unpackProto(f, v.b)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			for _, p := range packed {
+				item, err := r.decodeValue(f, p, depth)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", f.name, err)
+				}
+				items = append(items, item)
+			}
+			obj[f.jsonName] = items
+			continue
+		}
+		item, err := r.decodeValue(f, v, depth)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+		if f.repeated {
+			items, _ := obj[f.jsonName].([]interface{})
+			obj[f.jsonName] = append(items, item)
+		} else {
+			obj[f.jsonName] = item
+		}
+	}
+	return obj, nil
+}
+
+// decodeMapEntry decodes an entry of a map field.
+func (r *protoRegistry) decodeMapEntry(entry *protoMessageType, data []byte, depth int) (string, interface{}, error) {
+	obj, err := r.decodeJSON(entry.fullName, data, depth)
+	if err != nil {
+		return "", nil, err
+	}
+	keyField, valueField := entry.byNumber[1], entry.byNumber[2]
+	if keyField == nil || valueField == nil {
+		return "", nil, errors.New("malformed map entry type")
+	}
+	key := obj[keyField.jsonName]
+	if key == nil {
+		key = zeroJSON(r, keyField)
+	}
+	value := obj[valueField.jsonName]
+	if value == nil {
+		value = zeroJSON(r, valueField)
+	}
+	return fmt.Sprint(key), value, nil
+}
+
+// zeroJSON returns the JSON form of the default value of field f, which
+// proto3 leaves out of the encoding.
+func zeroJSON(r *protoRegistry, f *protoField) interface{} {
+	switch f.typ {
+	case protoString, protoBytes:
+		return ""
+	case protoBool:
+		return false
+	case protoInt64, protoUint64, protoSint64, protoFixed64, protoSfixed64:
+		return "0"
+	case protoEnum:
+		if e, ok := r.enums[f.typeName]; ok {
+			if name, ok := e.byNumber[0]; ok {
+				return name
+			}
+		}
+		return 0
+	case protoMessage:
+		return map[string]interface{}{}
+	}
+	return 0
+}
+
+// packable reports whether repeated fields of type typ may be packed.
+func packable(typ int) bool {
+	switch typ {
+	case protoString, protoBytes, protoMessage, protoGroup:
+		return false
+	}
+	return true
+}
+
+// unpackProto splits the packed values of a repeated scalar field.
+func unpackProto(f *protoField, data []byte) ([]protoValue, error) {
+	var values []protoValue
+	for len(data) > 0 {
+		v := protoValue{num: f.number}
+		switch f.typ {
+		case protoDouble, protoFixed64, protoSfixed64:
+			if len(data) < 8 {
+				return nil, errProtoTruncated
+			}
+			v.wire, v.u, data = wireFixed64, binary.LittleEndian.Uint64(data), data[8:]
+		case protoFloat, protoFixed32, protoSfixed32:
+			if len(data) < 4 {
+				return nil, errProtoTruncated
+			}
+			v.wire, v.u, data = wireFixed32, uint64(binary.LittleEndian.Uint32(data)), data[4:]
+		default:
+			u, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errProtoTruncated
+			}
+			v.wire, v.u, data = wireVarint, u, data[n:]
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// decodeValue converts a single encoded value of field f to JSON.
+func (r *protoRegistry) decodeValue(f *protoField, v protoValue, depth int) (interface{}, error) {
+	want := wireVarint
+	switch f.typ {
+	case protoString, protoBytes, protoMessage:
+		want = wireBytes
+	case protoDouble, protoFixed64, protoSfixed64:
+		want = wireFixed64
+	case protoFloat, protoFixed32, protoSfixed32:
+		want = wireFixed32
+	}
+	if v.wire != want {
+		return nil, errors.New("wrong wire type")
+	}
+	switch f.typ {
+	case protoMessage:
+		return r.decodeJSON(f.typeName, v.b, depth+1)
+	case protoString:
+		return string(v.b), nil
+	case protoBytes:
+		return base64.StdEncoding.EncodeToString(v.b), nil
+	case protoBool:
+		return v.u != 0, nil
+	case protoEnum:
+		if e, ok := r.enums[f.typeName]; ok {
+			if name, ok := e.byNumber[int32(v.u)]; ok {
+				return name, nil
+			}
+		}
+		return int32(v.u), nil
+	case protoDouble:
+		return jsonFloatValue(math.Float64frombits(v.u)), nil
+	case protoFloat:
+		return jsonFloatValue(float64(math.Float32frombits(uint32(v.u)))), nil
+	case protoInt32, protoSfixed32:
+		return int32(v.u), nil
+	case protoSint32:
+		return int32(uint32(v.u)>>1) ^ -int32(v.u&1), nil
+	case protoUint32, protoFixed32:
+		return uint32(v.u), nil
+	case protoInt64, protoSfixed64:
+		return strconv.FormatInt(int64(v.u), 10), nil
+	case protoSint64:
+		return strconv.FormatInt(int64(v.u>>1)^-int64(v.u&1), 10), nil
+	}
+	return strconv.FormatUint(v.u, 10), nil // uint64 and fixed64
+}
+
+// jsonFloatValue returns x, or its name if JSON cannot represent it.
+func jsonFloatValue(x float64) interface{} {
+	switch {
+	case math.IsNaN(x):
+		return "NaN"
+	case math.IsInf(x, 1):
+		return "Infinity"
+	case math.IsInf(x, -1):
+		return "-Infinity"
+	}
+	return x
+}
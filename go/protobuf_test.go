@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testProtoField encodes a FieldDescriptorProto.
+func testProtoField(name string, number, label, typ int, typeName string) []byte {
+	b := appendProtoString(nil, 1, name)
+	b = appendProtoVarint(b, 3, uint64(number))
+	b = appendProtoVarint(b, 4, uint64(label))
+	b = appendProtoVarint(b, 5, uint64(typ))
+	if typeName != "" {
+		b = appendProtoString(b, 6, typeName)
+	}
+	return b
+}
+
+// testProtoMessage encodes a DescriptorProto with the given fields and
+// nested types.
+func testProtoMessage(name string, mapEntry bool, fields [][]byte, nested ...[]byte) []byte {
+	b := appendProtoString(nil, 1, name)
+	for _, f := range fields {
+		b = appendProtoBytes(b, 2, f)
+	}
+	for _, n := range nested {
+		b = appendProtoBytes(b, 3, n)
+	}
+	if mapEntry {
+		b = appendProtoBytes(b, 7, appendProtoVarint(nil, 7, 1))
+	}
+	return b
+}
+
+// testProtoFiles returns the encoded descriptors of two files:
+//
+//	// common.proto
+//	package test;
+//	enum Mood { NEUTRAL = 0; HAPPY = 1; }
+//
+//	// greeter.proto
+//	package test;
+//	import "common.proto";
+//	message HelloRequest {
+//	  string name = 1;
+//	  int64 count = 2;
+//	  Mood mood = 3;
+//	  map<string, int32> tags = 4;
+//	  repeated sint32 nums = 5;
+//	  bytes blob = 6;
+//	  HelloRequest reply_to = 7;
+//	}
+//	message HelloReply { string message = 1; }
+//	service Greeter {
+//	  rpc SayHello(HelloRequest) returns (HelloReply) { option idempotency_level = NO_SIDE_EFFECTS; }
+//	  rpc Fail(HelloRequest) returns (HelloReply);
+//	  rpc Chat(HelloRequest) returns (stream HelloReply);
+//	}
+func testProtoFiles() (common, greeter []byte) {
+	value := func(name string, number int) []byte {
+		return appendProtoVarint(appendProtoString(nil, 1, name), 2, uint64(number))
+	}
+	mood := appendProtoString(nil, 1, "Mood")
+	mood = appendProtoBytes(mood, 2, value("NEUTRAL", 0))
+	mood = appendProtoBytes(mood, 2, value("HAPPY", 1))
+	common = appendProtoString(nil, 1, "common.proto")
+	common = appendProtoString(common, 2, "test")
+	common = appendProtoBytes(common, 5, mood)
+
+	request := testProtoMessage("HelloRequest", false, [][]byte{
+		testProtoField("name", 1, 1, protoString, ""),
+		testProtoField("count", 2, 1, protoInt64, ""),
+		testProtoField("mood", 3, 1, protoEnum, ".test.Mood"),
+		testProtoField("tags", 4, protoLabelRepeated, protoMessage, ".test.HelloRequest.TagsEntry"),
+		testProtoField("nums", 5, protoLabelRepeated, protoSint32, ""),
+		testProtoField("blob", 6, 1, protoBytes, ""),
+		testProtoField("reply_to", 7, 1, protoMessage, ".test.HelloRequest"),
+	}, testProtoMessage("TagsEntry", true, [][]byte{
+		testProtoField("key", 1, 1, protoString, ""),
+		testProtoField("value", 2, 1, protoInt32, ""),
+	}))
+	reply := testProtoMessage("HelloReply", false, [][]byte{testProtoField("message", 1, 1, protoString, "")})
+	method := func(name string, options []byte, serverStreaming bool) []byte {
+		b := appendProtoString(nil, 1, name)
+		b = appendProtoString(b, 2, ".test.HelloRequest")
+		b = appendProtoString(b, 3, ".test.HelloReply")
+		if options != nil {
+			b = appendProtoBytes(b, 4, options)
+		}
+		if serverStreaming {
+			b = appendProtoVarint(b, 6, 1)
+		}
+		return b
+	}
+	service := appendProtoString(nil, 1, "Greeter")
+	service = appendProtoBytes(service, 2, method("SayHello", appendProtoVarint(nil, 34, protoNoSideEffects), false))
+	service = appendProtoBytes(service, 2, method("Fail", nil, false))
+	service = appendProtoBytes(service, 2, method("Chat", nil, true))
+
+	greeter = appendProtoString(nil, 1, "greeter.proto")
+	greeter = appendProtoString(greeter, 2, "test")
+	greeter = appendProtoString(greeter, 3, "common.proto")
+	greeter = appendProtoBytes(greeter, 4, request)
+	greeter = appendProtoBytes(greeter, 4, reply)
+	greeter = appendProtoBytes(greeter, 6, service)
+	return common, greeter
+}
+
+// testProtoRegistry returns a registry of the files of testProtoFiles.
+func testProtoRegistry(t *testing.T) *protoRegistry {
+	t.Helper()
+	reg := newProtoRegistry()
+	common, greeter := testProtoFiles()
+	for _, file := range [][]byte{greeter, common} {
+		if _, _, err := reg.addFile(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return reg
+}
+
+// Test reading descriptors
+func TestProtoRegistry(t *testing.T) {
+	reg := testProtoRegistry(t)
+	s, ok := reg.services["test.Greeter"]
+	if !ok || len(s.methods) != 3 {
+		t.Fatalf("expected the Greeter service with 3 methods, got %+v", s)
+	}
+	if m := s.methods[0]; m.name != "SayHello" || m.input != ".test.HelloRequest" || m.idempotency != protoNoSideEffects {
+		t.Errorf("unexpected method %+v", m)
+	}
+	if !s.methods[2].serverStreaming {
+		t.Error("expected Chat to be server streaming")
+	}
+	if f := reg.messages[".test.HelloRequest"].byName["replyTo"]; f == nil || f.name != "reply_to" {
+		t.Errorf("expected the field by its JSON name, got %+v", f)
+	}
+	if !reg.messages[".test.HelloRequest.TagsEntry"].mapEntry {
+		t.Error("expected the map entry type to be marked")
+	}
+}
+
+// Test converting messages to and from JSON
+func TestProtoJSON(t *testing.T) {
+	reg := testProtoRegistry(t)
+	var args map[string]interface{}
+	json.Unmarshal([]byte(`{
+		"name": "Ada",
+		"count": "9007199254740993",
+		"mood": "HAPPY",
+		"tags": {"a": 1, "b": -2},
+		"nums": [-1, 2, -3],
+		"blob": "aGk=",
+		"reply_to": {"name": "Bob", "count": 7}
+	}`), &args)
+	data, err := reg.encodeJSON(".test.HelloRequest", args, 0)
+	if err != nil {
+		t.Fatalf("encodeJSON error: %v", err)
+	}
+	obj, err := reg.decodeJSON(".test.HelloRequest", data, 0)
+	if err != nil {
+		t.Fatalf("decodeJSON error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"name":    "Ada",
+		"count":   "9007199254740993",
+		"mood":    "HAPPY",
+		"tags":    map[string]interface{}{"a": 1.0, "b": -2.0},
+		"nums":    []interface{}{-1.0, 2.0, -3.0},
+		"blob":    "aGk=",
+		"replyTo": map[string]interface{}{"name": "Bob", "count": "7"},
+	}
+	got, _ := json.Marshal(obj)
+	var normalized map[string]interface{}
+	json.Unmarshal(got, &normalized)
+	if !reflect.DeepEqual(normalized, expected) {
+		t.Errorf("round trip gave %s", got)
+	}
+
+	for _, tc := range []struct {
+		args, err string
+	}{
+		{`{"unknown": 1}`, `unknown field "unknown"`},
+		{`{"mood": "SAD"}`, "SAD"},
+		{`{"count": 1.5}`, "count"},
+		{`{"nums": 1}`, "nums"},
+		{`{"name": 1}`, "name"},
+	} {
+		var args map[string]interface{}
+		json.Unmarshal([]byte(tc.args), &args)
+		if _, err := reg.encodeJSON(".test.HelloRequest", args, 0); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("encodeJSON(%s) error = %v, expected %q", tc.args, err, tc.err)
+		}
+	}
+}
+
+// Test the schema generated for a message type
+func TestProtoSchema(t *testing.T) {
+	reg := testProtoRegistry(t)
+	schema, _ := json.Marshal(reg.schema(".test.HelloRequest", 0))
+	for _, want := range []string{
+		`"count":{"type":["integer","string"]}`,
+		`"mood":{"enum":["NEUTRAL","HAPPY"],"type":"string"}`,
+		`"tags":{"additionalProperties":{"type":"integer"},"type":"object"}`,
+		`"nums":{"items":{"type":"integer"},"type":"array"}`,
+	} {
+		if !strings.Contains(string(schema), want) {
+			t.Errorf("expected %s in the schema, got %s", want, schema)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"mcp-minimal-server-go/client"
+)
+
+// upstreamConfig defines a downstream MCP server in the "upstreams" section
+// of the config file. Exactly one of Command and URL must be set.
+type upstreamConfig struct {
+	Name      string            `json:"name"`
+	Command   []string          `json:"command"`   // spawn this server and talk over stdio
+	Env       map[string]string `json:"env"`       // added to the server's environment
+	URL       string            `json:"url"`       // connect to this Streamable HTTP endpoint
+	Namespace string            `json:"namespace"` // qualifies tool and prompt names, as in "git.status"
+}
+
+// validate reports missing or conflicting fields.
+func (c *upstreamConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("upstream without a name")
+	}
+	if (len(c.Command) == 0) == (c.URL == "") {
+		return fmt.Errorf("upstream %q: exactly one of command and url must be set", c.Name)
+	}
+	if err := validateNamespace(c.Namespace); err != nil {
+		return fmt.Errorf("upstream %q: %w", c.Name, err)
+	}
+	return nil
+}
+
+// upstream is a connected downstream MCP server whose tools, resources, and
+// prompts are proxied. Its lists are fetched once when connecting.
+type upstream struct {
+	cfg       upstreamConfig
+	client    *client.Client
+	tools     []client.Tool
+	resources []client.Resource
+	prompts   []client.Prompt
+}
+
+// connectUpstream starts or connects to the server described by cfg and
+// fetches what it offers.
+func connectUpstream(ctx context.Context, cfg upstreamConfig) (*upstream, error) {
+	// Listing and pinging are retried, so a blip does not fail them.
+	opts := []client.Option{
+		client.WithClientInfo(serverName, serverVersion),
+		client.WithRetry(client.Backoff{Attempts: 2}),
+	}
+	var c *client.Client
+	if cfg.URL != "" {
+		c = client.NewHTTP(cfg.URL, nil, append(opts, client.WithReconnect(client.Backoff{Attempts: 5}))...)
+	} else {
+		env := os.Environ()
+		for _, name := range sortedKeys(cfg.Env) {
+			env = append(env, name+"="+cfg.Env[name])
+		}
+		var err error
+		if c, err = client.NewStdio(cfg.Command[0], cfg.Command[1:], env, opts...); err != nil {
+			return nil, fmt.Errorf("upstream %q: %w", cfg.Name, err)
+		}
+	}
+
+	u := &upstream{cfg: cfg, client: c}
+	if err := u.fetch(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("upstream %q: %w", cfg.Name, err)
+	}
+	return u, nil
+}
+
+// fetch initializes the session and lists the upstream's capabilities.
+func (u *upstream) fetch(ctx context.Context) error {
+	init, err := u.client.Initialize(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := init.Capabilities["tools"]; ok {
+		if u.tools, err = u.client.ListTools(ctx); err != nil {
+			return err
+		}
+	}
+	if _, ok := init.Capabilities["resources"]; ok {
+		if u.resources, err = u.client.ListResources(ctx); err != nil {
+			return err
+		}
+	}
+	if _, ok := init.Capabilities["prompts"]; ok {
+		if u.prompts, err = u.client.ListPrompts(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proxyTools returns a tool for each tool of the upstream. They keep the
+// upstream's names; registerTools applies the namespace.
+func (u *upstream) proxyTools() []MCPTool {
+	ts := make([]MCPTool, 0, len(u.tools))
+	for _, t := range u.tools {
+		ts = append(ts, &proxyTool{upstream: u, tool: t})
+	}
+	return ts
+}
+
+// connectUpstreams connects to every configured upstream, giving each up
+// to timeout, or forever if it is zero. On failure the ones already
+// connected are closed.
+func connectUpstreams(cfgs []upstreamConfig, timeout time.Duration) ([]*upstream, error) {
+	var ups []*upstream
+	for _, cfg := range cfgs {
+		ctx, cancel := context.Background(), func() {}
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		u, err := connectUpstream(ctx, cfg)
+		cancel()
+		if err != nil {
+			closeUpstreams(ups)
+			return nil, err
+		}
+		ups = append(ups, u)
+	}
+	return ups, nil
+}
+
+// closeUpstreams disconnects from every upstream.
+func closeUpstreams(ups []*upstream) {
+	for _, u := range ups {
+		u.client.Close()
+	}
+}
+
+// proxyTool forwards calls to a tool of an upstream.
+type proxyTool struct {
+	upstream *upstream
+	tool     client.Tool
+}
+
+// Name returns the upstream tool's name.
+func (p *proxyTool) Name() string {
+	return p.tool.Name
+}
+
+// Description returns the upstream tool's description.
+func (p *proxyTool) Description() string {
+	return p.tool.Description
+}
+
+// InputSchema returns the upstream tool's schema.
+func (p *proxyTool) InputSchema() map[string]interface{} {
+	return p.tool.InputSchema
+}
+
+// Annotations returns the upstream tool's annotations.
+func (p *proxyTool) Annotations() ToolAnnotations {
+	if p.tool.Annotations == nil {
+		return ToolAnnotations{}
+	}
+	return *p.tool.Annotations
+}
+
+// Execute forwards the call without a deadline of its own.
+func (p *proxyTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return p.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext forwards the call to the upstream.
+func (p *proxyTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := p.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult forwards the call to the upstream, passing on the
+// structured content of its result. A result flagged as an error is passed
+// on as such.
+func (p *proxyTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	result, err := p.upstream.client.CallTool(ctx, p.tool.Name, args)
+	if err != nil {
+		return nil, err
+	}
+	if result.IsError {
+		return nil, &toolResultError{content: result.Content}
+	}
+	res := &ToolResult{Content: result.Content}
+	if len(result.StructuredContent) > 0 {
+		res.Structured = result.StructuredContent
+	}
+	return res, nil
+}
+
+// WithUpstreams proxies the resources and prompts of ups. Their tools are
+// registered separately, through WithTools, so that the usual tool
+// selection applies to them.
+func WithUpstreams(ups ...*upstream) Option {
+	return func(s *Server) {
+		s.upstreams = ups
+	}
+}
+
+// checkUpstreams reports resources offered by more than one upstream, and
+// prompts served under the same name by more than one, since requests for
+// them could only reach the first.
+func checkUpstreams(ups []*upstream) error {
+	resources := map[string]string{} // URI to upstream name
+	prompts := map[string]string{}   // served name to upstream name
+	for _, u := range ups {
+		for _, r := range u.resources {
+			if prev, ok := resources[r.URI]; ok {
+				return fmt.Errorf("resource %q from upstream %q collides with the resource of the same URI from upstream %q", r.URI, u.cfg.Name, prev)
+			}
+			resources[r.URI] = u.cfg.Name
+		}
+		for _, p := range u.prompts {
+			name := u.promptName(p.Name)
+			if prev, ok := prompts[name]; ok {
+				return fmt.Errorf("prompt %q from upstream %q collides with the prompt of the same name from upstream %q; give one of them a namespace", name, u.cfg.Name, prev)
+			}
+			prompts[name] = u.cfg.Name
+		}
+	}
+	return nil
+}
+
+// listResources returns the resources of every upstream.
+func (s *Server) listResources() []client.Resource {
+	resources := []client.Resource{}
+	for _, u := range s.upstreams {
+		resources = append(resources, u.resources...)
+	}
+	return resources
+}
+
+// listPrompts returns the prompts of every upstream, qualified with the
+// upstream's namespace.
+func (s *Server) listPrompts() []client.Prompt {
+	prompts := []client.Prompt{}
+	for _, u := range s.upstreams {
+		for _, p := range u.prompts {
+			p.Name = u.promptName(p.Name)
+			prompts = append(prompts, p)
+		}
+	}
+	return prompts
+}
+
+// promptName returns the name under which the upstream's prompt is served.
+func (u *upstream) promptName(name string) string {
+	if u.cfg.Namespace == "" {
+		return name
+	}
+	return u.cfg.Namespace + "." + name
+}
+
+// resourceUpstream returns the upstream offering the resource at uri.
+func (s *Server) resourceUpstream(uri string) *upstream {
+	for _, u := range s.upstreams {
+		for _, r := range u.resources {
+			if r.URI == uri {
+				return u
+			}
+		}
+	}
+	return nil
+}
+
+// promptUpstream returns the upstream offering the named prompt and the
+// prompt's name on that upstream.
+func (s *Server) promptUpstream(name string) (*upstream, string) {
+	for _, u := range s.upstreams {
+		for _, p := range u.prompts {
+			if u.promptName(p.Name) == name {
+				return u, p.Name
+			}
+		}
+	}
+	return nil, ""
+}
+
+// upstreamContext returns the context for a proxied request, limited by the
+// request timeout.
+func (s *Server) upstreamContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if s.requestTimeout > 0 {
+		return context.WithTimeout(parent, s.requestTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// sendUpstreamResult sends the result of a proxied request, or its error.
+// JSON-RPC errors from the upstream are passed through with secrets
+// redacted from their message; failures to reach it are told apart from
+// errors of its own.
+func (s *Server) sendUpstreamResult(w io.Writer, id interface{}, result interface{}, err error) {
+	var rpcErr *client.RPCError
+	var transportErr *client.TransportError
+	switch {
+	case errors.As(err, &rpcErr):
+		var data interface{}
+		if len(rpcErr.Data) > 0 {
+			data = rpcErr.Data
+		}
+		sendErrorData(w, id, rpcErr.Code, s.redactor.redactString(rpcErr.Message), data)
+	case errors.Is(err, context.DeadlineExceeded):
+		sendError(w, id, codeRequestTimeout, "Upstream request timed out")
+	case errors.As(err, &transportErr):
+		s.logger.Warn("upstream unreachable", "method", transportErr.Method, "error", err)
+		sendError(w, id, -32603, "Upstream server unavailable")
+	case err != nil:
+		sendError(w, id, -32603, "Upstream request failed")
+	default:
+		sendResponse(w, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  result,
+		})
+	}
+}
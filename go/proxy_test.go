@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"mcp-minimal-server-go/client"
+)
+
+// pipeUpstream connects to a Server running in-process and fetches its
+// tools, as connectUpstream does for a subprocess.
+func pipeUpstream(t *testing.T, s *Server, cfg upstreamConfig) *upstream {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	go func() {
+		s.Serve(context.Background(), serverIn, serverOut)
+		serverOut.Close()
+	}()
+	u := &upstream{cfg: cfg, client: client.New(clientIn, clientOut, clientOut.Close)}
+	if err := u.fetch(context.Background()); err != nil {
+		t.Fatalf("fetch error: %v", err)
+	}
+	t.Cleanup(func() { u.client.Close() })
+	return u
+}
+
+// Test that upstream tools are served in the upstream's namespace
+func TestProxyTools(t *testing.T) {
+	u := pipeUpstream(t, NewServer(WithTools(&echoTool{})), upstreamConfig{Name: "up", Namespace: "up"})
+	opts, err := defaultConfig().serverOptions(slog.Default(), []*upstream{u})
+	if err != nil {
+		t.Fatalf("serverOptions error: %v", err)
+	}
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"up.echo","arguments":{"message":"via proxy"}},"id":1}` + "\n"
+	lines := runServerInput(t, NewServer(opts...), input)
+	if len(lines) != 1 || !strings.Contains(lines[0], "Echo: via proxy") {
+		t.Errorf("unexpected output %v", lines)
+	}
+
+	if _, err := defaultConfig().serverOptions(slog.Default(), []*upstream{u, u}); err == nil {
+		t.Error("expected an error for colliding upstream tools")
+	}
+}
+
+// failingTool fails every call with a result flagged as an error.
+type failingTool struct{}
+
+func (failingTool) Name() string        { return "fail" }
+func (failingTool) Description() string { return "Always fails" }
+func (failingTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (failingTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return nil, toolFailure("disk full")
+}
+
+// Test that upstream errors are passed through
+func TestProxyErrors(t *testing.T) {
+	u := pipeUpstream(t, NewServer(WithTools(&echoTool{})), upstreamConfig{Name: "up", Namespace: "up"})
+	u.prompts = []client.Prompt{{Name: "greet"}}
+	s := NewServer(WithTools(), WithUpstreams(u))
+
+	// The upstream does not actually have the prompt, so its error for the
+	// unprefixed name is passed through.
+	input := `{"jsonrpc":"2.0","method":"prompts/get","params":{"name":"up.greet"},"id":1}` + "\n" +
+		`{"jsonrpc":"2.0","method":"prompts/get","params":{"name":"missing"},"id":2}` + "\n"
+	lines := runServerInput(t, s, input)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines output, got %d", len(lines))
+	}
+	output := strings.Join(lines, "\n")
+	if !strings.Contains(output, `"id":1,"error":{"code":-32602,"message":"Unknown prompt: greet"}`) {
+		t.Errorf("expected the upstream's unknown prompt error, got %s", output)
+	}
+	if !strings.Contains(output, "Unknown prompt: missing") {
+		t.Errorf("expected an unknown prompt error, got %s", output)
+	}
+
+	_, err := (&proxyTool{upstream: u, tool: client.Tool{Name: "nope"}}).ExecuteContext(context.Background(), nil)
+	var rpcErr *client.RPCError
+	if !errors.As(err, &rpcErr) {
+		t.Errorf("expected an RPC error for an unknown upstream tool, got %v", err)
+	}
+
+	failing := pipeUpstream(t, NewServer(WithTools(failingTool{})), upstreamConfig{Name: "failing"})
+	input = `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"fail","arguments":{}},"id":3}` + "\n"
+	lines = runServerInput(t, NewServer(WithTools(failing.proxyTools()...)), input)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"content":[{"type":"text","text":"disk full"}],"isError":true`) {
+		t.Errorf("expected the upstream's error result, got %v", lines)
+	}
+
+	// An upstream that went away is told apart from one answering with
+	// an error.
+	clientIn, serverOut := io.Pipe()
+	serverOut.Close()
+	gone := &upstream{cfg: upstreamConfig{Name: "gone"}, client: client.New(clientIn, io.Discard, nil),
+		prompts: []client.Prompt{{Name: "greet"}}}
+	input = `{"jsonrpc":"2.0","method":"prompts/get","params":{"name":"greet"},"id":4}` + "\n"
+	lines = runServerInput(t, NewServer(WithTools(), WithUpstreams(gone), WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))), input)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"message":"Upstream server unavailable"`) {
+		t.Errorf("expected the upstream to be unavailable, got %v", lines)
+	}
+}
+
+// Test that upstreams may not offer the same resource or prompt
+func TestCheckUpstreams(t *testing.T) {
+	a := &upstream{cfg: upstreamConfig{Name: "a"}, resources: []client.Resource{{URI: "file:///x"}}, prompts: []client.Prompt{{Name: "greet"}}}
+	b := &upstream{cfg: upstreamConfig{Name: "b", Namespace: "b"}, resources: []client.Resource{{URI: "file:///y"}}, prompts: []client.Prompt{{Name: "greet"}}}
+	if err := checkUpstreams([]*upstream{a, b}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	b.resources[0].URI = "file:///x"
+	if err := checkUpstreams([]*upstream{a, b}); err == nil || !strings.Contains(err.Error(), `resource "file:///x"`) {
+		t.Errorf("expected a resource collision, got %v", err)
+	}
+	b.resources, b.cfg.Namespace = nil, ""
+	if err := checkUpstreams([]*upstream{a, b}); err == nil || !strings.Contains(err.Error(), `prompt "greet"`) {
+		t.Errorf("expected a prompt collision, got %v", err)
+	}
+}
+
+// Test validation of upstream definitions
+func TestUpstreamConfigValidate(t *testing.T) {
+	for _, c := range []upstreamConfig{
+		{Command: []string{"server"}},
+		{Name: "up"},
+		{Name: "up", Command: []string{"server"}, URL: "http://localhost/mcp"},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// qrCodeTool encodes a string into a QR code and returns it as a PNG image.
+type qrCodeTool struct{}
+
+// Name returns the name of the qr_code tool.
+func (q *qrCodeTool) Name() string {
+	return "qr_code"
+}
+
+// Description returns a brief description of the qr_code tool.
+func (q *qrCodeTool) Description() string {
+	return "Encodes the specified text into a QR code and returns it as a PNG image"
+}
+
+// qrCodeArgs are the arguments of the qr_code tool.
+type qrCodeArgs struct {
+	Text            string `json:"text" description:"The text to encode"`
+	ErrorCorrection string `json:"error_correction,omitempty" enum:"L,M,Q,H" description:"Error correction level (default M)"`
+	Scale           int    `json:"scale,omitempty" minimum:"1" maximum:"32" description:"Size of one module in pixels (default 8)"`
+}
+
+// InputSchema returns the JSON schema for the qr_code tool's input parameters.
+func (q *qrCodeTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(qrCodeArgs{})
+}
+
+// Annotations marks the qr_code tool as read-only.
+func (q *qrCodeTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("QR code")
+}
+
+// Execute encodes the text and returns the QR code as image content.
+func (q *qrCodeTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	a := qrCodeArgs{ErrorCorrection: "M", Scale: 8}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	var ecl qrECL
+	switch a.ErrorCorrection {
+	case "L":
+		ecl = qrECLLow
+	case "M":
+		ecl = qrECLMedium
+	case "Q":
+		ecl = qrECLQuartile
+	case "H":
+		ecl = qrECLHigh
+	default:
+		return nil, fmt.Errorf("invalid value for 'error_correction'")
+	}
+	if a.Scale < 1 || a.Scale > 32 {
+		return nil, fmt.Errorf("invalid value for 'scale'")
+	}
+
+	qr, err := encodeQR([]byte(a.Text), ecl)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, qr.image(a.Scale, 4)); err != nil {
+		return nil, err
+	}
+	content := ToolContent{
+		Type:     "image",
+		Data:     base64.StdEncoding.EncodeToString(buf.Bytes()),
+		MimeType: "image/png",
+	}
+	return []ToolContent{content}, nil
+}
+
+// qrECL is a QR code error correction level. The values index the
+// qrECCCodewordsPerBlock and qrNumECCBlocks tables.
+type qrECL int
+
+const (
+	qrECLLow qrECL = iota
+	qrECLMedium
+	qrECLQuartile
+	qrECLHigh
+)
+
+// formatBits returns the two-bit value the level contributes to the format
+// information.
+func (e qrECL) formatBits() int {
+	return [...]int{1, 0, 3, 2}[e]
+}
+
+// qrECCCodewordsPerBlock holds the number of error correction codewords per
+// block, indexed by level and version. Index 0 of each row is unused.
+var qrECCCodewordsPerBlock = [4][41]int{
+	{-1, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{-1, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{-1, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+// qrNumECCBlocks holds the number of error correction blocks, indexed by
+// level and version. Index 0 of each row is unused.
+var qrNumECCBlocks = [4][41]int{
+	{-1, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{-1, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{-1, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// qrCode is an encoded QR code symbol.
+type qrCode struct {
+	version    int
+	size       int
+	ecl        qrECL
+	modules    [][]bool // modules[y][x], true is dark
+	isFunction [][]bool // marks modules that are not data
+}
+
+// encodeQR encodes data in byte mode using the smallest version that fits at
+// the given error correction level.
+func encodeQR(data []byte, ecl qrECL) (*qrCode, error) {
+	version := 0
+	for v := 1; v <= 40; v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if len(data) < 1<<countBits && 4+countBits+8*len(data) <= qrNumDataCodewords(v, ecl)*8 {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("text too long for a QR code (%d bytes)", len(data))
+	}
+
+	var bb qrBitBuffer
+	bb.append(0x4, 4) // byte mode
+	if version < 10 {
+		bb.append(len(data), 8)
+	} else {
+		bb.append(len(data), 16)
+	}
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	capacity := qrNumDataCodewords(version, ecl) * 8
+	terminator := capacity - len(bb)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bb.append(0, terminator)
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	codewords := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			codewords[i>>3] |= 1 << (7 - uint(i&7))
+		}
+	}
+
+	size := version*4 + 17
+	qr := &qrCode{version: version, size: size, ecl: ecl}
+	qr.modules = make([][]bool, size)
+	qr.isFunction = make([][]bool, size)
+	for i := range qr.modules {
+		qr.modules[i] = make([]bool, size)
+		qr.isFunction[i] = make([]bool, size)
+	}
+	qr.drawFunctionPatterns()
+	qr.drawCodewords(qr.addECCAndInterleave(codewords))
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		qr.applyMask(mask)
+		qr.drawFormatBits(mask)
+		if p := qr.penalty(); bestPenalty < 0 || p < bestPenalty {
+			bestMask, bestPenalty = mask, p
+		}
+		qr.applyMask(mask) // masking is an XOR, so this undoes it
+	}
+	qr.applyMask(bestMask)
+	qr.drawFormatBits(bestMask)
+	return qr, nil
+}
+
+// image renders the symbol with each module scale pixels wide and a quiet
+// zone of border modules on every side.
+func (qr *qrCode) image(scale, border int) image.Image {
+	dim := (qr.size + border*2) * scale
+	img := image.NewGray(image.Rect(0, 0, dim, dim))
+	for y := 0; y < dim; y++ {
+		for x := 0; x < dim; x++ {
+			mx, my := x/scale-border, y/scale-border
+			c := color.Gray{Y: 0xFF}
+			if mx >= 0 && mx < qr.size && my >= 0 && my < qr.size && qr.modules[my][mx] {
+				c = color.Gray{Y: 0x00}
+			}
+			img.SetGray(x, y, c)
+		}
+	}
+	return img
+}
+
+// setFunctionModule sets a module that belongs to a function pattern.
+func (qr *qrCode) setFunctionModule(x, y int, dark bool) {
+	qr.modules[y][x] = dark
+	qr.isFunction[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing, and alignment patterns and
+// reserves the format and version areas.
+func (qr *qrCode) drawFunctionPatterns() {
+	for i := 0; i < qr.size; i++ {
+		qr.setFunctionModule(6, i, i%2 == 0)
+		qr.setFunctionModule(i, 6, i%2 == 0)
+	}
+	qr.drawFinderPattern(3, 3)
+	qr.drawFinderPattern(qr.size-4, 3)
+	qr.drawFinderPattern(3, qr.size-4)
+
+	pos := qrAlignmentPositions(qr.version)
+	n := len(pos)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if (i == 0 && j == 0) || (i == 0 && j == n-1) || (i == n-1 && j == 0) {
+				continue // overlaps a finder pattern
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					qr.setFunctionModule(pos[i]+dx, pos[j]+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	qr.drawFormatBits(0)
+	qr.drawVersion()
+}
+
+// drawFinderPattern draws a finder pattern and its separator centred on x, y.
+func (qr *qrCode) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx >= 0 && xx < qr.size && yy >= 0 && yy < qr.size {
+				dist := max(abs(dx), abs(dy))
+				qr.setFunctionModule(xx, yy, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the format information for mask, plus
+// the dark module.
+func (qr *qrCode) drawFormatBits(mask int) {
+	data := qr.ecl.formatBits()<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+
+	for i := 0; i <= 5; i++ {
+		qr.setFunctionModule(8, i, qrBit(bits, i))
+	}
+	qr.setFunctionModule(8, 7, qrBit(bits, 6))
+	qr.setFunctionModule(8, 8, qrBit(bits, 7))
+	qr.setFunctionModule(7, 8, qrBit(bits, 8))
+	for i := 9; i < 15; i++ {
+		qr.setFunctionModule(14-i, 8, qrBit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		qr.setFunctionModule(qr.size-1-i, 8, qrBit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		qr.setFunctionModule(8, qr.size-15+i, qrBit(bits, i))
+	}
+	qr.setFunctionModule(8, qr.size-8, true)
+}
+
+// drawVersion draws both copies of the version information for versions 7
+// and above.
+func (qr *qrCode) drawVersion() {
+	if qr.version < 7 {
+		return
+	}
+	rem := qr.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+	}
+	bits := qr.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		a, b := qr.size-11+i%3, i/3
+		qr.setFunctionModule(a, b, qrBit(bits, i))
+		qr.setFunctionModule(b, a, qrBit(bits, i))
+	}
+}
+
+// addECCAndInterleave splits data into blocks, appends Reed-Solomon error
+// correction to each, and interleaves the result.
+func (qr *qrCode) addECCAndInterleave(data []byte) []byte {
+	numBlocks := qrNumECCBlocks[qr.ecl][qr.version]
+	blockECCLen := qrECCCodewordsPerBlock[qr.ecl][qr.version]
+	rawCodewords := qrNumRawDataModules(qr.version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := rsDivisor(blockECCLen)
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := range blocks {
+		n := shortBlockLen - blockECCLen
+		if i >= numShortBlocks {
+			n++
+		}
+		dat := append([]byte(nil), data[k:k+n]...)
+		k += n
+		ecc := rsRemainder(dat, divisor)
+		if i < numShortBlocks {
+			dat = append(dat, 0)
+		}
+		blocks[i] = append(dat, ecc...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+	for i := range blocks[0] {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockECCLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+	return result
+}
+
+// drawCodewords places the data codewords in the zigzag order.
+func (qr *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := qr.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < qr.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = qr.size - 1 - vert
+				}
+				if !qr.isFunction[y][x] && i < len(data)*8 {
+					qr.modules[y][x] = qrBit(int(data[i>>3]), 7-(i&7))
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask XORs the data modules with mask pattern mask.
+func (qr *qrCode) applyMask(mask int) {
+	for y := 0; y < qr.size; y++ {
+		for x := 0; x < qr.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !qr.isFunction[y][x] {
+				qr.modules[y][x] = !qr.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol using the four mask evaluation rules; lower
+// scores are easier to scan.
+func (qr *qrCode) penalty() int {
+	size := qr.size
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return qr.modules[x][y]
+		}
+		return qr.modules[y][x]
+	}
+	result := 0
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < size; y++ {
+			run := 1
+			for x := 1; x <= size; x++ {
+				if x < size && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += 3 + run - 5
+				}
+				run = 1
+			}
+			for x := 0; x+10 < size; x++ {
+				var bits int
+				for i := 0; i < 11; i++ {
+					bits <<= 1
+					if at(x+i, y, vertical) {
+						bits |= 1
+					}
+				}
+				if bits == 0x5D0 || bits == 0x05D { // 10111010000, 00001011101
+					result += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := qr.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < size && y+1 < size && c == qr.modules[y][x+1] && c == qr.modules[y+1][x] && c == qr.modules[y+1][x+1] {
+				result += 3
+			}
+		}
+	}
+	total := size * size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += k * 10
+	return result
+}
+
+// qrAlignmentPositions returns the centre coordinates of the alignment
+// patterns for version.
+func qrAlignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+// qrNumRawDataModules returns the number of modules available for data and
+// error correction in version.
+func qrNumRawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+		if version >= 7 {
+			result -= 36
+		}
+	}
+	return result
+}
+
+// qrNumDataCodewords returns the number of data codewords in version at ecl.
+func qrNumDataCodewords(version int, ecl qrECL) int {
+	return qrNumRawDataModules(version)/8 - qrECCCodewordsPerBlock[ecl][version]*qrNumECCBlocks[ecl][version]
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of degree, highest
+// coefficient first and the leading 1 omitted.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the Reed-Solomon error correction codewords for data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i := range result {
+			result[i] ^= gfMultiply(divisor[i], factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies two elements of GF(2^8) modulo x^8+x^4+x^3+x^2+1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>uint(i))&1) * int(x)
+	}
+	return byte(z)
+}
+
+// qrBitBuffer is a sequence of bits.
+type qrBitBuffer []bool
+
+// append appends the low n bits of val, most significant first.
+func (bb *qrBitBuffer) append(val, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*bb = append(*bb, (val>>uint(i))&1 != 0)
+	}
+}
+
+// qrBit reports whether bit i of x is set.
+func qrBit(x, i int) bool {
+	return (x>>uint(i))&1 != 0
+}
+
+// abs returns the absolute value of x.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
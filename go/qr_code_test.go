@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// Test that the capacity tables agree with the values published in the spec
+func TestQRNumDataCodewords(t *testing.T) {
+	cases := []struct {
+		version int
+		ecl     qrECL
+		want    int
+	}{
+		{1, qrECLLow, 19},
+		{1, qrECLHigh, 9},
+		{10, qrECLMedium, 216},
+		{40, qrECLLow, 2956},
+		{40, qrECLQuartile, 1666},
+	}
+	for _, c := range cases {
+		if got := qrNumDataCodewords(c.version, c.ecl); got != c.want {
+			t.Errorf("qrNumDataCodewords(%d, %d) = %d, want %d", c.version, c.ecl, got, c.want)
+		}
+	}
+}
+
+// Test version selection and the finder pattern layout
+func TestEncodeQR(t *testing.T) {
+	qr, err := encodeQR([]byte("hello"), qrECLMedium)
+	if err != nil {
+		t.Fatalf("encodeQR error: %v", err)
+	}
+	if qr.version != 1 || qr.size != 21 {
+		t.Errorf("expected version 1 (21x21), got version %d (%dx%d)", qr.version, qr.size, qr.size)
+	}
+	// The top-left finder pattern is dark on its outer ring and centre.
+	for _, p := range [][2]int{{0, 0}, {6, 0}, {0, 6}, {3, 3}} {
+		if !qr.modules[p[1]][p[0]] {
+			t.Errorf("expected module (%d,%d) to be dark", p[0], p[1])
+		}
+	}
+	if qr.modules[1][1] {
+		t.Errorf("expected module (1,1) to be light")
+	}
+
+	if _, err := encodeQR(make([]byte, 3000), qrECLLow); err == nil {
+		t.Errorf("expected an error for data exceeding version 40 capacity")
+	}
+}
+
+// Test calling the "qr_code" tool through tools/call
+func TestToolsCallQRCode(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"qr_code","arguments":{"text":"hello","scale":2}},"id":1}`
+	lines := runTestInput(t, input)
+
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line output, got %d lines", len(lines))
+	}
+
+	var resp struct {
+		Result struct {
+			Content []ToolContent `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(resp.Result.Content) != 1 {
+		t.Fatalf("expected 1 content item, got %d", len(resp.Result.Content))
+	}
+	item := resp.Result.Content[0]
+	if item.Type != "image" || item.MimeType != "image/png" {
+		t.Errorf("expected image/png image content, got type=%q mimeType=%q", item.Type, item.MimeType)
+	}
+	raw, err := base64.StdEncoding.DecodeString(item.Data)
+	if err != nil {
+		t.Fatalf("failed to decode base64 data: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	// 21 modules plus a 4-module quiet zone on each side, 2 pixels per module
+	if got := img.Bounds().Dx(); got != (21+8)*2 {
+		t.Errorf("expected image width %d, got %d", (21+8)*2, got)
+	}
+}
+
+// qrTestFormat returns the 15-bit format information for the level's two
+// format bits and mask, computed as in ISO/IEC 18004 Annex C.
+func qrTestFormat(eclBits, mask int) int {
+	data := eclBits<<3 | mask
+	rem := data << 10
+	for i := 14; i >= 10; i-- {
+		if rem&(1<<i) != 0 {
+			rem ^= 0x537 << (i - 10)
+		}
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// qrTestMasks are the data mask conditions of the spec, for row i and
+// column j.
+var qrTestMasks = [8]func(i, j int) bool{
+	func(i, j int) bool { return (i+j)%2 == 0 },
+	func(i, j int) bool { return i%2 == 0 },
+	func(i, j int) bool { return j%3 == 0 },
+	func(i, j int) bool { return (i+j)%3 == 0 },
+	func(i, j int) bool { return (i/2+j/3)%2 == 0 },
+	func(i, j int) bool { return i*j%2+i*j%3 == 0 },
+	func(i, j int) bool { return (i*j%2+i*j%3)%2 == 0 },
+	func(i, j int) bool { return ((i+j)%2+i*j%3)%2 == 0 },
+}
+
+// qrTestAlignment are the alignment pattern centres of the versions the
+// tests use, from Annex E.
+var qrTestAlignment = map[int][]int{
+	1: nil, 2: {6, 18}, 7: {6, 22, 38}, 10: {6, 28, 50}, 27: {6, 34, 62, 90, 118},
+}
+
+// qrTestDecode decodes a byte mode symbol independently of the encoder: it
+// reads the format information, unmasks and collects the codewords,
+// checks every block's Reed-Solomon syndromes, and parses the data.
+func qrTestDecode(t *testing.T, modules [][]bool) (qrECL, []byte) {
+	t.Helper()
+	size := len(modules)
+	version := (size - 17) / 4
+	align, ok := qrTestAlignment[version]
+	if !ok {
+		t.Fatalf("no alignment table for version %d", version)
+	}
+	dark := func(x, y int) bool { return modules[y][x] }
+
+	var format int
+	for i := 0; i < 8; i++ {
+		if dark(size-1-i, 8) {
+			format |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if dark(8, size-15+i) {
+			format |= 1 << i
+		}
+	}
+	ecl, mask := qrECL(-1), -1
+	for e := qrECLLow; e <= qrECLHigh; e++ {
+		for m := 0; m < 8; m++ {
+			if qrTestFormat(e.formatBits(), m) == format {
+				ecl, mask = e, m
+			}
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("invalid format information %015b", format)
+	}
+
+	function := func(x, y int) bool {
+		switch {
+		case x == 6 || y == 6:
+			return true
+		case x <= 8 && y <= 8, x >= size-8 && y <= 8, x <= 8 && y >= size-8:
+			return true
+		case version >= 7 && (x >= size-11 && x < size-8 && y < 6 || y >= size-11 && y < size-8 && x < 6):
+			return true
+		}
+		for _, cx := range align {
+			for _, cy := range align {
+				if cx == 6 && cy == 6 || cx == 6 && cy == align[len(align)-1] || cy == 6 && cx == align[len(align)-1] {
+					continue
+				}
+				if abs(x-cx) <= 2 && abs(y-cy) <= 2 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var raw []byte
+	n := 0
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		upward := (size-1-right)/2%2 == 0
+		if right < 6 {
+			upward = (size-2-right)/2%2 == 0
+		}
+		for v := 0; v < size; v++ {
+			y := v
+			if upward {
+				y = size - 1 - v
+			}
+			for x := right; x > right-2; x-- {
+				if function(x, y) {
+					continue
+				}
+				if n%8 == 0 {
+					raw = append(raw, 0)
+				}
+				if dark(x, y) != qrTestMasks[mask](y, x) {
+					raw[n/8] |= 0x80 >> (n % 8)
+				}
+				n++
+			}
+		}
+	}
+	raw = raw[:n/8]
+
+	numBlocks := qrNumECCBlocks[ecl][version]
+	eccLen := qrECCCodewordsPerBlock[ecl][version]
+	shortData := len(raw)/numBlocks - eccLen
+	numShort := numBlocks - len(raw)%numBlocks
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for b := range blocks {
+			if i < shortData || b >= numShort {
+				blocks[b] = append(blocks[b], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	for b := range blocks {
+		data = append(data, blocks[b]...)
+	}
+	for i := 0; i < eccLen; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], raw[k])
+			k++
+		}
+	}
+
+	// Every block is a multiple of the generator, whose roots are the
+	// powers of 2 from 0 to eccLen-1 in GF(256) modulo 0x11d.
+	mul := func(a, b byte) byte {
+		var p byte
+		for ; b > 0; b >>= 1 {
+			if b&1 != 0 {
+				p ^= a
+			}
+			carry := a & 0x80
+			a <<= 1
+			if carry != 0 {
+				a ^= 0x1d
+			}
+		}
+		return p
+	}
+	for b, block := range blocks {
+		root := byte(1)
+		for i := 0; i < eccLen; i++ {
+			var s byte
+			for _, c := range block {
+				s = mul(s, root) ^ c
+			}
+			if s != 0 {
+				t.Fatalf("block %d: syndrome %d is %d", b, i, s)
+			}
+			root = mul(root, 2)
+		}
+	}
+
+	bit := func(i int) int { return int(data[i/8]>>(7-i%8)) & 1 }
+	read := func(pos, n int) int {
+		v := 0
+		for i := 0; i < n; i++ {
+			v = v<<1 | bit(pos+i)
+		}
+		return v
+	}
+	if mode := read(0, 4); mode != 4 {
+		t.Fatalf("expected byte mode, got mode %d", mode)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	count := read(4, countBits)
+	text := make([]byte, count)
+	for i := range text {
+		text[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return ecl, text
+}
+
+// Test that encoded symbols decode to their data
+func TestQRRoundTrip(t *testing.T) {
+	// The format information of level M with mask 0, from Annex C.
+	if got := qrTestFormat(qrECLMedium.formatBits(), 0); got != 0b101010000010010 {
+		t.Fatalf("unexpected format information %015b", got)
+	}
+	cases := []struct {
+		text    string
+		ecl     qrECL
+		version int
+	}{
+		{"hello", qrECLMedium, 1},
+		{"https://example.com/", qrECLLow, 2},
+		{strings.Repeat("0123456789", 8), qrECLQuartile, 7},
+		{strings.Repeat("QR code round trip ", 13), qrECLLow, 10},
+		{strings.Repeat("\x00\xffbinary", 75), qrECLHigh, 27},
+	}
+	for _, c := range cases {
+		qr, err := encodeQR([]byte(c.text), c.ecl)
+		if err != nil {
+			t.Fatalf("encodeQR(%.20q) error: %v", c.text, err)
+		}
+		if qr.version != c.version {
+			t.Errorf("%.20q: expected version %d, got %d", c.text, c.version, qr.version)
+			continue
+		}
+		ecl, text := qrTestDecode(t, qr.modules)
+		if ecl != c.ecl || string(text) != c.text {
+			t.Errorf("%.20q: decoded level %d and %.20q", c.text, ecl, text)
+		}
+	}
+}
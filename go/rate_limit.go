@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// codeRateLimited is the implementation-defined JSON-RPC error code sent
+// when a request is rejected by a rate limit.
+const codeRateLimited = -32002
+
+// RateLimit configures a token bucket: Rate tokens are added per second, up
+// to Burst tokens. A Rate of zero means unlimited.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// rateLimitData is the "data" member of a rate limit error, telling the
+// client which limit was hit and when to retry.
+type rateLimitData struct {
+	Scope      string  `json:"scope"` // "session" or "tool"
+	Tool       string  `json:"tool,omitempty"`
+	RetryAfter float64 `json:"retryAfter"` // seconds
+}
+
+// tokenBucket is a token bucket rate limiter, safe for concurrent use.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newTokenBucket returns a full bucket for limit, or nil if limit is
+// unlimited. A nil *tokenBucket allows everything.
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	if limit.Rate <= 0 {
+		return nil
+	}
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: limit.Rate, burst: burst, tokens: burst, now: time.Now}
+}
+
+// allow takes a token from the bucket if one is available. Otherwise it
+// reports how long until the next token becomes available.
+func (b *tokenBucket) allow() (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) / b.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// parseRateLimit parses a limit of the form "RATE" or "RATE:BURST". The
+// burst defaults to the rate rounded up.
+func parseRateLimit(s string) (RateLimit, error) {
+	rateStr, burstStr, hasBurst := strings.Cut(s, ":")
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || rate < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		return RateLimit{}, fmt.Errorf("invalid rate %q", rateStr)
+	}
+	limit := RateLimit{Rate: rate, Burst: int(math.Ceil(rate))}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burstStr)
+		if err != nil || limit.Burst < 1 {
+			return RateLimit{}, fmt.Errorf("invalid burst %q", burstStr)
+		}
+	}
+	return limit, nil
+}
+
+// parseToolRateLimits parses a comma-separated list of "TOOL=RATE[:BURST]"
+// entries.
+func parseToolRateLimits(s string) (map[string]RateLimit, error) {
+	limits := map[string]RateLimit{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid tool rate limit %q", entry)
+		}
+		limit, err := parseRateLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", name, err)
+		}
+		limits[name] = limit
+	}
+	return limits, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Test that the bucket refills at the configured rate
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(RateLimit{Rate: 2, Burst: 2})
+	b.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.allow(); !ok {
+			t.Fatalf("expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	ok, wait := b.allow()
+	if ok {
+		t.Fatal("expected the request after the burst to be rejected")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("expected retry after 500ms, got %v", wait)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _ := b.allow(); !ok {
+		t.Error("expected a request to be allowed after the bucket refilled")
+	}
+
+	var unlimited *tokenBucket
+	if ok, _ := unlimited.allow(); !ok {
+		t.Error("expected a nil bucket to allow everything")
+	}
+}
+
+// Test parsing of the rate limit flags
+func TestParseToolRateLimits(t *testing.T) {
+	limits, err := parseToolRateLimits("echo=5, qr_code=0.5:3")
+	if err != nil {
+		t.Fatalf("parseToolRateLimits error: %v", err)
+	}
+	if limits["echo"] != (RateLimit{Rate: 5, Burst: 5}) {
+		t.Errorf("unexpected echo limit %+v", limits["echo"])
+	}
+	if limits["qr_code"] != (RateLimit{Rate: 0.5, Burst: 3}) {
+		t.Errorf("unexpected qr_code limit %+v", limits["qr_code"])
+	}
+	for _, bad := range []string{"echo", "=1", "echo=x", "echo=1:0", "echo=NaN", "echo=Inf", "echo=+Inf:3", "echo=1e400"} {
+		if _, err := parseToolRateLimits(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+	for _, bad := range []string{"nan", "inf", "-Inf"} {
+		if _, err := parseRateLimit(bad); err == nil {
+			t.Errorf("expected an error for the session rate limit %q", bad)
+		}
+	}
+}
+
+// Test that session and tool limits produce structured rate limit errors
+func TestRateLimitErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		opt    Option
+		scope  string
+		method string
+	}{
+		{"session", WithSessionRateLimit(RateLimit{Rate: 0.001, Burst: 2}), "session", `"tools/list"`},
+		{"tool", WithToolRateLimit("echo", RateLimit{Rate: 0.001, Burst: 2}), "tool", `"tools/call","params":{"name":"echo","arguments":{"message":"hi"}}`},
+	}
+	for _, c := range cases {
+		var input string
+		for id := 1; id <= 3; id++ {
+			input += fmt.Sprintf(`{"jsonrpc":"2.0","method":%s,"id":%d}`+"\n", c.method, id)
+		}
+		lines := runServerInput(t, NewServer(c.opt), input)
+		if len(lines) != 3 {
+			t.Fatalf("%s: expected 3 lines output, got %d", c.name, len(lines))
+		}
+
+		// Tool calls complete concurrently, so find the third response by id.
+		var errResp struct {
+			ID    int `json:"id"`
+			Error struct {
+				Code int           `json:"code"`
+				Data rateLimitData `json:"data"`
+			} `json:"error"`
+		}
+		for _, line := range lines {
+			if err := json.Unmarshal([]byte(line), &errResp); err != nil {
+				t.Fatalf("%s: failed to unmarshal response: %v", c.name, err)
+			}
+			if errResp.ID == 3 {
+				break
+			}
+		}
+		if errResp.Error.Code != codeRateLimited {
+			t.Errorf("%s: expected code=%d, got %d", c.name, codeRateLimited, errResp.Error.Code)
+		}
+		if errResp.Error.Data.Scope != c.scope || errResp.Error.Data.RetryAfter <= 0 {
+			t.Errorf("%s: unexpected error data %+v", c.name, errResp.Error.Data)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A recording is a traffic log written without redaction by --record. It is
+// replayed with --replay, which feeds the recorded client messages to a
+// fresh server and compares its messages with the recorded ones.
+
+// replayStepTimeout bounds how long replay waits for the server messages
+// that preceded a client message in the recording before sending it anyway.
+var replayStepTimeout = 5 * time.Second
+
+// recordedMessage is one message of a recording.
+type recordedMessage struct {
+	direction string // trafficInbound or trafficOutbound
+	msg       []byte
+}
+
+// readRecording parses a recording written by a trafficLog.
+func readRecording(r io.Reader) ([]recordedMessage, error) {
+	var records []recordedMessage
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		_, rest, ok := strings.Cut(line, " ")
+		direction, msg, ok2 := strings.Cut(rest, " ")
+		if !ok || !ok2 || (direction != trafficInbound && direction != trafficOutbound) {
+			return nil, fmt.Errorf("line %d: not a recorded message", n)
+		}
+		records = append(records, recordedMessage{direction: direction, msg: []byte(msg)})
+	}
+	return records, scanner.Err()
+}
+
+// lineCollector collects the non-empty lines written to it and lets other
+// goroutines wait for them.
+type lineCollector struct {
+	mu      sync.Mutex
+	buf     []byte
+	lines   [][]byte
+	changed chan struct{} // closed and replaced whenever a line completes
+}
+
+// Write implements io.Writer.
+func (o *lineCollector) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.buf = append(o.buf, p...)
+	for {
+		i := bytes.IndexByte(o.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if line := bytes.TrimSpace(o.buf[:i]); len(line) > 0 {
+			o.lines = append(o.lines, append([]byte(nil), line...))
+			close(o.changed)
+			o.changed = make(chan struct{})
+		}
+		o.buf = o.buf[i+1:]
+	}
+}
+
+// newLineCollector returns an empty lineCollector.
+func newLineCollector() *lineCollector {
+	return &lineCollector{changed: make(chan struct{})}
+}
+
+// snapshot returns the lines collected so far.
+func (o *lineCollector) snapshot() [][]byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.lines[:len(o.lines):len(o.lines)]
+}
+
+// waitFor waits until match returns true for a line at index from or later
+// and returns its index, or returns -1 once timeout passes or ctx is done.
+func (o *lineCollector) waitFor(ctx context.Context, from int, timeout time.Duration, match func([]byte) bool) int {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		o.mu.Lock()
+		lines, changed := o.lines, o.changed
+		o.mu.Unlock()
+		for i := from; i < len(lines); i++ {
+			if match(lines[i]) {
+				return i
+			}
+		}
+		if len(lines) > from {
+			from = len(lines)
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return -1
+		case <-ctx.Done():
+			return -1
+		}
+	}
+}
+
+// replay feeds the client messages of records to s, in their recorded order
+// relative to the server's messages, and writes a report of the differences
+// between the recorded and the replayed server messages to out. Responses
+// are matched by ID; notifications and requests from the server are
+// compared in order. It returns the number of differences.
+func replay(ctx context.Context, s *Server, records []recordedMessage, out io.Writer) (int, error) {
+	output := newLineCollector()
+	in, feed := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		err := s.Serve(ctx, in, output)
+		in.Close() // unblock the feed if the server stops early
+		errc <- err
+	}()
+
+	var recorded [][]byte
+	sent := 0
+	for _, rec := range records {
+		if rec.direction == trafficOutbound {
+			recorded = append(recorded, rec.msg)
+			continue
+		}
+		if len(recorded) > 0 {
+			// Wait for as many server messages as were recorded by now.
+			output.waitFor(ctx, len(recorded)-1, replayStepTimeout, func([]byte) bool { return true })
+		}
+		if _, err := fmt.Fprintf(feed, "%s\n", rec.msg); err != nil {
+			return 0, err
+		}
+		sent++
+	}
+	feed.Close()
+	if err := <-errc; err != nil {
+		return 0, err
+	}
+
+	diffs := compareMessages(recorded, output.snapshot(), out)
+	fmt.Fprintf(out, "replayed %d client messages: %d differences\n", sent, diffs)
+	return diffs, nil
+}
+
+// compareMessages reports the differences between two sets of server
+// messages to out and returns their number.
+func compareMessages(recorded, replayed [][]byte, out io.Writer) int {
+	wantResponses, wantOthers := splitResponses(recorded)
+	gotResponses, gotOthers := splitResponses(replayed)
+	diffs := 0
+	for _, id := range sortedKeys(wantResponses) {
+		want := wantResponses[id]
+		got, ok := gotResponses[id]
+		delete(gotResponses, id)
+		switch {
+		case !ok:
+			fmt.Fprintf(out, "missing response to id %s:\n  recorded: %s\n", id, want)
+		case got != want:
+			fmt.Fprintf(out, "response to id %s differs:\n  recorded: %s\n  replayed: %s\n", id, want, got)
+		default:
+			continue
+		}
+		diffs++
+	}
+	for _, id := range sortedKeys(gotResponses) {
+		fmt.Fprintf(out, "unexpected response to id %s:\n  replayed: %s\n", id, gotResponses[id])
+		diffs++
+	}
+	for i := 0; i < len(wantOthers) || i < len(gotOthers); i++ {
+		switch {
+		case i >= len(gotOthers):
+			fmt.Fprintf(out, "missing message:\n  recorded: %s\n", wantOthers[i])
+		case i >= len(wantOthers):
+			fmt.Fprintf(out, "unexpected message:\n  replayed: %s\n", gotOthers[i])
+		case wantOthers[i] != gotOthers[i]:
+			fmt.Fprintf(out, "message %d differs:\n  recorded: %s\n  replayed: %s\n", i+1, wantOthers[i], gotOthers[i])
+		default:
+			continue
+		}
+		diffs++
+	}
+	return diffs
+}
+
+// splitResponses normalizes messages and separates responses, keyed by
+// their ID, from notifications and requests, kept in order.
+func splitResponses(msgs [][]byte) (map[string]string, []string) {
+	responses := map[string]string{}
+	var others []string
+	for _, msg := range msgs {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(msg, &fields); err != nil {
+			others = append(others, string(msg))
+			continue
+		}
+		if result, ok := fields["result"].(map[string]interface{}); ok {
+			maskVolatile(result)
+		}
+		normalized, _ := json.Marshal(fields)
+		id, hasID := fields["id"]
+		if _, isRequest := fields["method"]; hasID && !isRequest {
+			key, _ := json.Marshal(id)
+			responses[string(key)] = string(normalized)
+		} else {
+			others = append(others, string(normalized))
+		}
+	}
+	return responses, others
+}
+
+// volatileFields are the fields of health and stats results that differ
+// between any two runs, masked before comparing.
+var volatileFields = []string{"uptimeSeconds", "memory"}
+
+// volatileToolStats are the fields of the per-tool statistics of stats
+// results that depend on timing: latencies and what falls within the
+// recent windows.
+var volatileToolStats = []string{"recentCalls", "recentErrors", "errorRate", "p50Ms", "p90Ms", "p99Ms", "p999Ms", "maxMs", "totalMs"}
+
+// maskVolatile replaces the values of the volatile fields of result, if
+// present, so that only their presence is compared.
+func maskVolatile(result map[string]interface{}) {
+	for _, name := range volatileFields {
+		if _, ok := result[name]; ok {
+			result[name] = "*"
+		}
+	}
+	toolStats, _ := result["toolStats"].(map[string]interface{})
+	for _, st := range toolStats {
+		st, _ := st.(map[string]interface{})
+		for _, name := range volatileToolStats {
+			if _, ok := st[name]; ok {
+				st[name] = "*"
+			}
+		}
+	}
+}
+
+// runReplay replays the recording in path against s and returns the
+// process exit code: 0 if the server behaved as recorded, 1 otherwise.
+func runReplay(ctx context.Context, s *Server, path string, out io.Writer) int {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open recording: %v\n", err)
+		return 1
+	}
+	records, err := readRecording(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid recording %s: %v\n", path, err)
+		return 1
+	}
+	diffs, err := replay(ctx, s, records, out)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		return 1
+	}
+	if diffs > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+// recordSession serves input on s and returns the recording of the session.
+func recordSession(t *testing.T, s *Server, input string) []recordedMessage {
+	t.Helper()
+	var recording, out bytes.Buffer
+	traffic := newTrafficLog(&recording, nil)
+	if err := s.Serve(context.Background(), traffic.reader(strings.NewReader(input)), traffic.writer(&out)); err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+	records, err := readRecording(&recording)
+	if err != nil {
+		t.Fatalf("readRecording error: %v", err)
+	}
+	return records
+}
+
+// Test that replaying a recording against an unchanged server finds no
+// differences and that changed responses are reported
+func TestReplay(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2024-11-05"},"id":1}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":"two"}`,
+		`{"jsonrpc":"2.0","method":"tools/list","id":3}`,
+	}, "\n") + "\n"
+	records := recordSession(t, NewServer(WithTools(&echoTool{})), input)
+	if len(records) != 7 {
+		t.Fatalf("expected 4 client and 3 server messages, got %d", len(records))
+	}
+
+	var report bytes.Buffer
+	diffs, err := replay(context.Background(), NewServer(WithTools(&echoTool{})), records, &report)
+	if err != nil || diffs != 0 {
+		t.Fatalf("replay against the same server: %d differences, %v\n%s", diffs, err, report.String())
+	}
+	if !strings.Contains(report.String(), "replayed 4 client messages: 0 differences") {
+		t.Errorf("unexpected report %q", report.String())
+	}
+
+	report.Reset()
+	diffs, err = replay(context.Background(), NewServer(WithTools(&countTextTool{})), records, &report)
+	if err != nil {
+		t.Fatalf("replay error: %v", err)
+	}
+	if diffs != 2 {
+		t.Errorf("expected 2 differences, got %d:\n%s", diffs, report.String())
+	}
+	for _, want := range []string{`response to id "two" differs:`, "response to id 3 differs:"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, report.String())
+		}
+	}
+}
+
+// Test comparing notifications and unmatched responses
+func TestCompareMessages(t *testing.T) {
+	recorded := [][]byte{
+		[]byte(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`),
+		[]byte(`{"id":1,"jsonrpc":"2.0","result":{}}`),
+	}
+	replayed := [][]byte{
+		[]byte(`{"method":"notifications/progress","params":{"progress":1},"jsonrpc":"2.0"}`),
+		[]byte(`{"id":2,"jsonrpc":"2.0","result":{}}`),
+		[]byte(`{"jsonrpc":"2.0","method":"notifications/message"}`),
+	}
+	var report bytes.Buffer
+	if diffs := compareMessages(recorded, replayed, &report); diffs != 3 {
+		t.Errorf("expected 3 differences, got %d:\n%s", diffs, report.String())
+	}
+	for _, want := range []string{"missing response to id 1", "unexpected response to id 2", "unexpected message:"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, report.String())
+		}
+	}
+}
+
+// Test that malformed recordings are rejected
+func TestReadRecordingErrors(t *testing.T) {
+	for _, bad := range []string{"not a recording\n", "2025-03-08T12:00:00Z ==> {}\n"} {
+		if _, err := readRecording(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+// Test that fields of health and stats results that change between runs are
+// not reported
+func TestCompareVolatile(t *testing.T) {
+	recorded := [][]byte{
+		[]byte(`{"id":1,"jsonrpc":"2.0","result":{"status":"ok","uptimeSeconds":1.5,"memory":{"numGC":1},"tools":3}}`),
+		[]byte(`{"id":2,"jsonrpc":"2.0","result":{"toolStats":{"echo":{"calls":1,"p50Ms":0.2,"p90Ms":0.2,"p99Ms":0.2}}}}`),
+		[]byte(`{"id":3,"jsonrpc":"2.0","result":{"status":"ok","uptimeSeconds":1.5,"tools":3}}`),
+	}
+	replayed := [][]byte{
+		[]byte(`{"id":1,"jsonrpc":"2.0","result":{"status":"ok","uptimeSeconds":0.1,"memory":{"numGC":7},"tools":3}}`),
+		[]byte(`{"id":2,"jsonrpc":"2.0","result":{"toolStats":{"echo":{"calls":1,"p50Ms":0.9,"p90Ms":1.1,"p99Ms":3}}}}`),
+		[]byte(`{"id":3,"jsonrpc":"2.0","result":{"status":"ok","uptimeSeconds":0.1,"tools":4}}`),
+	}
+	var report bytes.Buffer
+	if diffs := compareMessages(recorded, replayed, &report); diffs != 1 || !strings.Contains(report.String(), "response to id 3 differs") {
+		t.Errorf("expected only the tool count to differ, got %d:\n%s", diffs, report.String())
+	}
+}
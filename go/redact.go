@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// redactedValue replaces redacted secrets.
+const redactedValue = "[REDACTED]"
+
+// defaultRedactKeys are the key names whose values are always redacted.
+// Names such as "token" occur in harmless keys like "max_tokens" and
+// "progressToken", so these only match whole keys.
+var defaultRedactKeys = []string{
+	"token", "accesstoken", "refreshtoken", "idtoken", "authtoken", "bearertoken", "sessiontoken",
+	"authorization", "proxyauthorization", "cookie", "setcookie",
+}
+
+// defaultRedactSuffixes are the key names whose values are always redacted,
+// also at the end of longer keys such as "db_password" or "client_secret".
+var defaultRedactSuffixes = []string{"password", "passwd", "secret", "apikey", "privatekey"}
+
+// redactor removes secrets from text before it is logged. Values of JSON
+// object keys named by one of the keys or ending in one of the suffixes
+// (ignoring case, "_", and "-", so "accesstoken" covers "access_token")
+// are replaced, and so is every match of the patterns.
+type redactor struct {
+	keys     []string
+	suffixes []string
+	patterns []*regexp.Regexp
+}
+
+// newRedactor returns a redactor for the default key names plus keys and
+// the given regular expressions.
+func newRedactor(keys, patterns []string) (*redactor, error) {
+	r := &redactor{suffixes: defaultRedactSuffixes}
+	for _, k := range append(append([]string(nil), defaultRedactKeys...), keys...) {
+		if k = normalizeKey(k); k != "" {
+			r.keys = append(r.keys, k)
+		}
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// normalizeKey lowercases k and removes separators.
+func normalizeKey(k string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(k))
+}
+
+// sensitiveKey reports whether values under key must be redacted.
+func (r *redactor) sensitiveKey(key string) bool {
+	key = normalizeKey(key)
+	for _, k := range r.keys {
+		if key == k {
+			return true
+		}
+	}
+	for _, suffix := range r.suffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString replaces every pattern match in s.
+func (r *redactor) redactString(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, redactedValue)
+	}
+	return s
+}
+
+// redactJSON redacts a JSON message. Messages containing sensitive keys
+// are re-encoded, which sorts their keys; others keep their layout. Text
+// that is not JSON only has the patterns applied.
+func (r *redactor) redactJSON(msg []byte) []byte {
+	if r == nil {
+		return msg
+	}
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	dec.UseNumber()
+	if dec.Decode(&v) == nil && r.redactValue(v) {
+		if encoded, err := json.Marshal(v); err == nil {
+			msg = encoded
+		}
+	}
+	return []byte(r.redactString(string(msg)))
+}
+
+// redactValue replaces the values of sensitive keys in v and reports
+// whether there were any.
+func (r *redactor) redactValue(v interface{}) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if r.sensitiveKey(k) {
+				v[k] = redactedValue
+				changed = true
+			} else if r.redactValue(item) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if r.redactValue(item) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// replaceAttr redacts log attributes, for slog.HandlerOptions.ReplaceAttr.
+func (r *redactor) replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	if r.sensitiveKey(a.Key) {
+		return slog.String(a.Key, redactedValue)
+	}
+	if a.Value.Kind() == slog.KindString {
+		return slog.String(a.Key, r.redactString(a.Value.String()))
+	}
+	return a
+}
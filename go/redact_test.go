@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// Test that sensitive keys and patterns are redacted
+func TestRedactor(t *testing.T) {
+	r, err := newRedactor([]string{"ssn"}, []string{`sk-[A-Za-z0-9]+`})
+	if err != nil {
+		t.Fatalf("newRedactor error: %v", err)
+	}
+	msg := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"login","arguments":{"user":"bob","Password":"hunter2","nested":[{"access_token":"abc","SSN":"123"}],"note":"key sk-123abc"}},"id":1}`
+	got := string(r.redactJSON([]byte(msg)))
+	for _, secret := range []string{"hunter2", `"abc"`, "123abc", `"123"`} {
+		if strings.Contains(got, secret) {
+			t.Errorf("expected %s to be redacted, got %s", secret, got)
+		}
+	}
+	if !strings.Contains(got, `"user":"bob"`) || !strings.Contains(got, "key [REDACTED]") {
+		t.Errorf("unexpected redaction %s", got)
+	}
+
+	clean := `{"jsonrpc":"2.0","method":"tools/list","id":1}`
+	if got := string(r.redactJSON([]byte(clean))); got != clean {
+		t.Errorf("expected a message without secrets to keep its layout, got %s", got)
+	}
+
+	var logBuf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logBuf, &slog.HandlerOptions{ReplaceAttr: r.replaceAttr}))
+	logger.Info("calling upstream", "authorization", "Bearer xyz", "detail", "using sk-999")
+	if strings.Contains(logBuf.String(), "xyz") || strings.Contains(logBuf.String(), "sk-999") {
+		t.Errorf("expected log attributes to be redacted, got %s", logBuf.String())
+	}
+
+	for key, want := range map[string]bool{
+		"token": true, "Access-Token": true, "refresh_token": true, "Set-Cookie": true,
+		"db_password": true, "clientSecret": true, "X-API-Key": true, "ssn": true,
+		"tokens": false, "max_tokens": false, "progressToken": false, "tokenCount": false,
+		"cookies_enabled": false, "secretary": false, "ssn_last4": false,
+	} {
+		if got := r.sensitiveKey(key); got != want {
+			t.Errorf("sensitiveKey(%q) = %v, expected %v", key, got, want)
+		}
+	}
+
+	if _, err := newRedactor(nil, []string{"("}); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+// Test that the traffic log redacts secrets
+func TestTrafficLogRedaction(t *testing.T) {
+	var logBuf, out bytes.Buffer
+	r, _ := newRedactor(nil, nil)
+	traffic := newTrafficLog(&logBuf, r)
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi","token":"t0p"}},"id":1}` + "\n"
+	if err := runMCPServer(traffic.reader(strings.NewReader(withHandshake(input))), traffic.writer(&out)); err != nil {
+		t.Fatalf("runMCPServer error: %v", err)
+	}
+	if strings.Contains(logBuf.String(), "t0p") || !strings.Contains(logBuf.String(), redactedValue) {
+		t.Errorf("expected the token to be redacted from the log, got %s", logBuf.String())
+	}
+	if !strings.Contains(out.String(), "Echo: hi") {
+		t.Errorf("expected the server output to be unaffected, got %s", out.String())
+	}
+}
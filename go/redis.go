@@ -0,0 +1,535 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// redisConfig configures the redis tool in the "redis" section of the
+// config file.
+type redisConfig struct {
+	Address      string   `json:"address"`  // host:port, defaults to localhost:6379
+	Username     string   `json:"username"` // for ACL users of Redis 6 and later
+	Password     string   `json:"password"` // $VAR expands from the environment
+	DB           int      `json:"db"`
+	TLS          bool     `json:"tls"`
+	AllowWrites  bool     `json:"allowWrites"`  // adds the set, del, and expire commands
+	MaxKeys      int      `json:"maxKeys"`      // listed by keys, defaults to 100
+	MaxValueSize int      `json:"maxValueSize"` // returned by get, defaults to 64 KiB
+	Timeout      duration `json:"timeout"`      // per call, defaults to 5s
+}
+
+// Defaults and limits of the redis tool.
+const (
+	defaultRedisAddress   = "localhost:6379"
+	defaultRedisMaxKeys   = 100
+	defaultRedisValueSize = 64 << 10
+	defaultRedisTimeout   = 5 * time.Second
+	maxRedisReplySize     = 1 << 20 // of INFO and other replies
+	maxRedisScans         = 1000    // SCAN calls per keys command
+	maxRedisPatternLength = 256
+)
+
+// validate reports malformed fields.
+func (c *redisConfig) validate() error {
+	if c.Address != "" {
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("redis: invalid address %q: %v", c.Address, err)
+		}
+	}
+	if c.DB < 0 || c.MaxKeys < 0 || c.MaxValueSize < 0 || c.Timeout < 0 {
+		return errors.New("redis: db, maxKeys, maxValueSize, and timeout must not be negative")
+	}
+	return nil
+}
+
+// redisTool inspects a Redis server: it reads values and TTLs, lists keys
+// with SCAN rather than KEYS, which would block the server, and reports
+// INFO. Writes are refused unless the configuration allows them.
+type redisTool struct {
+	cfg       redisConfig
+	tlsConfig *tls.Config // overrides the defaults in tests
+}
+
+// newRedisTool returns the redis tool for cfg.
+func newRedisTool(cfg redisConfig) *redisTool {
+	if cfg.Address == "" {
+		cfg.Address = defaultRedisAddress
+	}
+	if cfg.MaxKeys == 0 {
+		cfg.MaxKeys = defaultRedisMaxKeys
+	}
+	if cfg.MaxValueSize == 0 {
+		cfg.MaxValueSize = defaultRedisValueSize
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = duration(defaultRedisTimeout)
+	}
+	return &redisTool{cfg: cfg}
+}
+
+// redisArgs are the arguments of the redis tool.
+type redisArgs struct {
+	Command    string `json:"command" description:"What to do: get a string value, list keys matching a pattern, get the ttl or type of a key, or get server info"`
+	Key        string `json:"key,omitempty" description:"The key, for every command but keys and info"`
+	Pattern    string `json:"pattern,omitempty" description:"Glob pattern of the keys to list, such as session:* (default *)"`
+	Limit      int    `json:"limit,omitempty" minimum:"1" description:"Most keys to list (default the server's limit)"`
+	Section    string `json:"section,omitempty" description:"INFO section, such as memory, clients, or keyspace (default all)"`
+	Value      string `json:"value,omitempty" description:"The value to set"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty" minimum:"1" description:"Expiry of the key, for set and expire"`
+}
+
+// redisReadCommands and redisWriteCommands are the commands of the tool.
+var (
+	redisReadCommands  = []string{"get", "keys", "ttl", "type", "info"}
+	redisWriteCommands = []string{"set", "del", "expire"}
+)
+
+// commands returns the commands the tool accepts.
+func (t *redisTool) commands() []string {
+	if t.cfg.AllowWrites {
+		return append(append([]string(nil), redisReadCommands...), redisWriteCommands...)
+	}
+	return redisReadCommands
+}
+
+// Name returns the name of the redis tool.
+func (t *redisTool) Name() string {
+	return "redis"
+}
+
+// Description returns a brief description of the redis tool.
+func (t *redisTool) Description() string {
+	d := fmt.Sprintf("Inspects the Redis server at %s: gets string values (up to %d bytes), lists up to %d keys matching a pattern, and reports TTLs, types, and INFO", t.cfg.Address, t.cfg.MaxValueSize, t.cfg.MaxKeys)
+	if t.cfg.AllowWrites {
+		d += "; also sets, deletes, and expires keys"
+	}
+	return d
+}
+
+// InputSchema returns the JSON schema for the redis tool's input
+// parameters, with the allowed commands as an enum.
+func (t *redisTool) InputSchema() map[string]interface{} {
+	schema := mcp.SchemaFor(redisArgs{})
+	props := schema["properties"].(map[string]interface{})
+	props["command"].(map[string]interface{})["enum"] = t.commands()
+	props["limit"].(map[string]interface{})["maximum"] = t.cfg.MaxKeys
+	if !t.cfg.AllowWrites {
+		delete(props, "value")
+		delete(props, "ttl_seconds")
+	}
+	return schema
+}
+
+// Annotations marks the redis tool as read-only unless it may write. The
+// server is outside this one, and its keys change, so it is neither
+// closed nor idempotent.
+func (t *redisTool) Annotations() ToolAnnotations {
+	if t.cfg.AllowWrites {
+		return ToolAnnotations{Title: "Redis"}
+	}
+	return ToolAnnotations{Title: "Redis", ReadOnlyHint: true}
+}
+
+// Timeout returns the configured time limit of a call.
+func (t *redisTool) Timeout() time.Duration {
+	return time.Duration(t.cfg.Timeout)
+}
+
+// Execute runs the command without a deadline of its own.
+func (t *redisTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the command and returns its result as text.
+func (t *redisTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult runs the command over a new connection and returns its
+// result as structured content.
+func (t *redisTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a redisArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if err := t.check(&a); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.Timeout))
+	defer cancel()
+	conn, err := t.dial(ctx)
+	if err != nil {
+		if err := redisTimeout(ctx, err); err != nil {
+			return nil, err
+		}
+		return nil, toolFailure("redis at %s: %v", t.cfg.Address, err)
+	}
+	defer conn.close()
+	result, err := t.run(conn, a)
+	if err != nil {
+		if err := redisTimeout(ctx, err); err != nil {
+			return nil, err
+		}
+		var redisErr redisError
+		if errors.As(err, &redisErr) {
+			return nil, toolFailure("redis: %s", redisErr)
+		}
+		return nil, toolFailure("redis at %s: %v", t.cfg.Address, err)
+	}
+	result["command"] = a.Command
+	return mcp.NewResult().WithStructured(result), nil
+}
+
+// redisTimeout returns the error to answer err with if it is due to the end
+// of ctx, and nil otherwise. The connection's deadline is the context's,
+// so it may fire before the context is done, failing with an i/o timeout.
+func redisTimeout(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// check reports missing and invalid arguments of the command of a.
+func (t *redisTool) check(a *redisArgs) error {
+	allowed := slices.Contains(t.commands(), a.Command)
+	switch {
+	case !allowed && slices.Contains(redisWriteCommands, a.Command):
+		return fmt.Errorf("the %s command is disabled; the redis tool is read-only", a.Command)
+	case !allowed:
+		return fmt.Errorf("invalid value for 'command': expected one of %s", strings.Join(t.commands(), ", "))
+	case a.Command != "keys" && a.Command != "info" && a.Key == "":
+		return fmt.Errorf("'key' is required for %s", a.Command)
+	case a.Command == "expire" && a.TTLSeconds < 1:
+		return errors.New("'ttl_seconds' is required for expire")
+	case a.TTLSeconds < 0:
+		return errors.New("invalid value for 'ttl_seconds'")
+	case len(a.Pattern) > maxRedisPatternLength:
+		return fmt.Errorf("invalid value for 'pattern': longer than %d bytes", maxRedisPatternLength)
+	case a.Limit < 0 || a.Limit > t.cfg.MaxKeys:
+		return fmt.Errorf("invalid value for 'limit': expected 1 to %d", t.cfg.MaxKeys)
+	}
+	if a.Pattern == "" {
+		a.Pattern = "*"
+	}
+	if a.Limit == 0 {
+		a.Limit = t.cfg.MaxKeys
+	}
+	return nil
+}
+
+// run sends the Redis commands of a over conn.
+func (t *redisTool) run(conn *redisConn, a redisArgs) (map[string]interface{}, error) {
+	switch a.Command {
+	case "get":
+		return t.get(conn, a.Key)
+	case "keys":
+		return t.keys(conn, a.Pattern, a.Limit)
+	case "ttl":
+		ttl, err := conn.integer("TTL", a.Key)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]interface{}{"key": a.Key, "exists": ttl != -2}
+		if ttl >= 0 {
+			result["ttlSeconds"] = ttl
+		}
+		return result, nil
+	case "type":
+		reply, err := conn.do("TYPE", a.Key)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"key": a.Key, "type": reply}, nil
+	case "info":
+		cmd := []string{"INFO"}
+		if a.Section != "" {
+			cmd = append(cmd, a.Section)
+		}
+		reply, err := conn.do(cmd...)
+		if err != nil {
+			return nil, err
+		}
+		info, _ := reply.(string)
+		return map[string]interface{}{"info": parseRedisInfo(info)}, nil
+	case "set":
+		cmd := []string{"SET", a.Key, a.Value}
+		if a.TTLSeconds > 0 {
+			cmd = append(cmd, "EX", strconv.Itoa(a.TTLSeconds))
+		}
+		if _, err := conn.do(cmd...); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"key": a.Key, "set": true}, nil
+	case "del":
+		n, err := conn.integer("DEL", a.Key)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"key": a.Key, "deleted": n == 1}, nil
+	default: // expire
+		n, err := conn.integer("EXPIRE", a.Key, strconv.Itoa(a.TTLSeconds))
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"key": a.Key, "exists": n == 1}, nil
+	}
+}
+
+// get returns the string value of key, cut to the configured size without
+// reading more of it.
+func (t *redisTool) get(conn *redisConn, key string) (map[string]interface{}, error) {
+	size, err := conn.integer("STRLEN", key)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		exists, err := conn.integer("EXISTS", key)
+		if err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			return map[string]interface{}{"key": key, "exists": false}, nil
+		}
+	}
+	result := map[string]interface{}{"key": key, "exists": true, "size": size}
+	var reply interface{}
+	if size > int64(t.cfg.MaxValueSize) {
+		reply, err = conn.do("GETRANGE", key, "0", strconv.Itoa(t.cfg.MaxValueSize-1))
+		result["truncated"] = true
+	} else {
+		reply, err = conn.do("GET", key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	result["value"] = reply
+	return result, nil
+}
+
+// keys lists up to limit keys matching pattern, in the order SCAN returns
+// them.
+func (t *redisTool) keys(conn *redisConn, pattern string, limit int) (map[string]interface{}, error) {
+	keys := []string{}
+	cursor := "0"
+	truncated := false
+	for i := 0; i < maxRedisScans; i++ {
+		reply, err := conn.do("SCAN", cursor, "MATCH", pattern, "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return nil, errors.New("malformed SCAN reply")
+		}
+		cursor, _ = parts[0].(string)
+		batch, _ := parts[1].([]interface{})
+		for _, k := range batch {
+			if len(keys) == limit {
+				truncated = true
+				break
+			}
+			if s, ok := k.(string); ok {
+				keys = append(keys, s)
+			}
+		}
+		if truncated || cursor == "0" {
+			break
+		}
+		if i == maxRedisScans-1 {
+			truncated = true
+		}
+	}
+	result := map[string]interface{}{"pattern": pattern, "keys": keys}
+	if truncated {
+		result["truncated"] = true
+	}
+	return result, nil
+}
+
+// parseRedisInfo returns the fields of an INFO reply by section, with the
+// section names in lower case.
+func parseRedisInfo(info string) map[string]map[string]string {
+	sections := map[string]map[string]string{}
+	var section map[string]string
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "# "):
+			section = map[string]string{}
+			sections[strings.ToLower(strings.TrimPrefix(line, "# "))] = section
+		case section != nil:
+			if k, v, ok := strings.Cut(line, ":"); ok {
+				section[k] = v
+			}
+		}
+	}
+	return sections
+}
+
+// redisConn is a connection to a Redis server speaking RESP2.
+type redisConn struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	maxBulk int // longest bulk string read
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+// Error implements the error interface.
+func (e redisError) Error() string {
+	return string(e)
+}
+
+// dial connects to the server, authenticates if configured to, and selects
+// the database. The connection ends with ctx.
+func (t *redisTool) dial(ctx context.Context) (*redisConn, error) {
+	var conn net.Conn
+	var err error
+	if t.cfg.TLS {
+		cfg := t.tlsConfig
+		if cfg == nil {
+			host, _, _ := net.SplitHostPort(t.cfg.Address)
+			cfg = &tls.Config{ServerName: host}
+		}
+		conn, err = (&tls.Dialer{Config: cfg}).DialContext(ctx, "tcp", t.cfg.Address)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", t.cfg.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	maxBulk := t.cfg.MaxValueSize
+	if maxBulk < maxRedisReplySize {
+		maxBulk = maxRedisReplySize
+	}
+	c := &redisConn{conn: conn, r: bufio.NewReader(conn), maxBulk: maxBulk}
+	if password := os.ExpandEnv(t.cfg.Password); password != "" {
+		auth := []string{"AUTH", password}
+		if t.cfg.Username != "" {
+			auth = []string{"AUTH", t.cfg.Username, password}
+		}
+		if _, err := c.do(auth...); err != nil {
+			c.close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+	if t.cfg.DB != 0 {
+		if _, err := c.do("SELECT", strconv.Itoa(t.cfg.DB)); err != nil {
+			c.close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// close closes the connection.
+func (c *redisConn) close() {
+	c.conn.Close()
+}
+
+// do sends the command args and returns the reply: a string, an int64,
+// nil, or a []interface{} of those. An error reply is returned as a
+// redisError.
+func (c *redisConn) do(args ...string) (interface{}, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+	return c.reply(0)
+}
+
+// integer sends the command args, which must reply with an integer.
+func (c *redisConn) integer(args ...string) (int64, error) {
+	reply, err := c.do(args...)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply to %s", args[0])
+	}
+	return n, nil
+}
+
+// reply reads one reply, nested depth arrays deep.
+func (c *redisConn) reply(depth int) (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if line == "" {
+		return nil, errors.New("malformed reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		switch {
+		case err != nil:
+			return nil, errors.New("malformed reply")
+		case n < 0:
+			return nil, nil
+		case n > c.maxBulk:
+			return nil, fmt.Errorf("reply of %d bytes is too large", n)
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		switch {
+		case err != nil || depth > 2:
+			return nil, errors.New("malformed reply")
+		case n < 0:
+			return nil, nil
+		}
+		items := make([]interface{}, 0, min(n, 1024))
+		for i := 0; i < n; i++ {
+			item, err := c.reply(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, errors.New("malformed reply")
+}
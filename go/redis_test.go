@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis serves a few commands over RESP from a map of string values,
+// recording the commands it receives.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	mu       sync.Mutex
+	values   map[string]string
+	ttls     map[string]int
+	commands []string
+}
+
+// startFakeRedis listens on a loopback port until the test ends.
+func startFakeRedis(t *testing.T, values map[string]string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, values: values, ttls: map[string]int{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+// serve answers the commands of one connection.
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := false
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		reply := "-ERR unknown command\r\n"
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			if args[len(args)-1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid username-password pair\r\n"
+			}
+		case !authed && f.password != "":
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "STRLEN":
+			reply = fmt.Sprintf(":%d\r\n", len(f.values[args[1]]))
+		case cmd == "EXISTS":
+			_, ok := f.values[args[1]]
+			reply = fmt.Sprintf(":%d\r\n", map[bool]int{true: 1}[ok])
+		case cmd == "GET":
+			reply = bulk(f.values[args[1]])
+		case cmd == "GETRANGE":
+			end, _ := strconv.Atoi(args[3])
+			reply = bulk(f.values[args[1]][:end+1])
+		case cmd == "TTL":
+			switch ttl, ok := f.ttls[args[1]]; {
+			case ok:
+				reply = fmt.Sprintf(":%d\r\n", ttl)
+			case f.values[args[1]] != "":
+				reply = ":-1\r\n"
+			default:
+				reply = ":-2\r\n"
+			}
+		case cmd == "TYPE":
+			reply = "+string\r\n"
+		case cmd == "INFO":
+			reply = bulk("# Server\r\nredis_version:7.2.4\r\n\r\n# Keyspace\r\ndb0:keys=3,expires=1\r\n")
+		case cmd == "SCAN":
+			// Two keys a call, in name order, the cursor being the next index.
+			var keys []string
+			for k := range f.values {
+				if ok, _ := path.Match(args[3], k); ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			start, _ := strconv.Atoi(args[1])
+			end, next := min(start+2, len(keys)), strconv.Itoa(start+2)
+			if end == len(keys) {
+				next = "0"
+			}
+			reply = fmt.Sprintf("*2\r\n%s*%d\r\n", bulk(next), end-start)
+			for _, k := range keys[start:end] {
+				reply += bulk(k)
+			}
+		case cmd == "SET":
+			f.values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// bulk encodes s as a bulk string.
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+// readRESPCommand reads a command sent as an array of bulk strings.
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 {
+		return nil, fmt.Errorf("malformed command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// callRedis calls tool and returns its structured result as JSON.
+func callRedis(t *testing.T, tool *redisTool, args map[string]interface{}) (string, error) {
+	t.Helper()
+	result, err := tool.ExecuteResult(context.Background(), args)
+	if err != nil {
+		return "", err
+	}
+	encoded, _ := json.Marshal(result.Structured)
+	return string(encoded), nil
+}
+
+// Test that the read commands return values, keys, TTLs, and info within
+// the limits
+func TestRedisTool(t *testing.T) {
+	f := startFakeRedis(t, map[string]string{
+		"session:1": "alice", "session:2": "bob", "session:3": "carol", "big": strings.Repeat("x", 100), "other": "",
+	})
+	f.password, f.ttls["session:1"] = "secret", 300
+	t.Setenv("TEST_REDIS_PASSWORD", "secret")
+	tool := newRedisTool(redisConfig{Address: f.ln.Addr().String(), Password: "$TEST_REDIS_PASSWORD", MaxKeys: 2, MaxValueSize: 10})
+
+	for _, tc := range []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"command": "get", "key": "session:1"}, `{"command":"get","exists":true,"key":"session:1","size":5,"value":"alice"}`},
+		{map[string]interface{}{"command": "get", "key": "big"}, `{"command":"get","exists":true,"key":"big","size":100,"truncated":true,"value":"xxxxxxxxxx"}`},
+		{map[string]interface{}{"command": "get", "key": "missing"}, `{"command":"get","exists":false,"key":"missing"}`},
+		{map[string]interface{}{"command": "keys", "pattern": "session:*"}, `{"command":"keys","keys":["session:1","session:2"],"pattern":"session:*","truncated":true}`},
+		{map[string]interface{}{"command": "keys", "pattern": "session:[23]"}, `{"command":"keys","keys":["session:2","session:3"],"pattern":"session:[23]"}`},
+		{map[string]interface{}{"command": "keys", "limit": 1}, `{"command":"keys","keys":["big"],"pattern":"*","truncated":true}`},
+		{map[string]interface{}{"command": "ttl", "key": "session:1"}, `{"command":"ttl","exists":true,"key":"session:1","ttlSeconds":300}`},
+		{map[string]interface{}{"command": "ttl", "key": "session:2"}, `{"command":"ttl","exists":true,"key":"session:2"}`},
+		{map[string]interface{}{"command": "type", "key": "session:2"}, `{"command":"type","key":"session:2","type":"string"}`},
+		{map[string]interface{}{"command": "info", "section": "keyspace"}, `{"command":"info","info":{"keyspace":{"db0":"keys=3,expires=1"},"server":{"redis_version":"7.2.4"}}}`},
+	} {
+		got, err := callRedis(t, tool, tc.args)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tc.args, err)
+		} else if got != tc.want {
+			t.Errorf("%v: expected %s, got %s", tc.args, tc.want, got)
+		}
+	}
+	if !tool.Annotations().ReadOnlyHint {
+		t.Error("expected the tool to be read-only by default")
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"command": "set", "key": "k", "value": "v"},
+		{"command": "flushall"},
+		{"command": "get"},
+		{"command": "keys", "limit": 3},
+	} {
+		if _, err := tool.ExecuteResult(context.Background(), args); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+	f.mu.Lock()
+	for _, c := range f.commands {
+		if strings.HasPrefix(c, "KEYS") || strings.HasPrefix(c, "SET") {
+			t.Errorf("unexpected command %q", c)
+		}
+	}
+	f.password = "rotated"
+	f.mu.Unlock()
+	if _, err := tool.ExecuteResult(context.Background(), map[string]interface{}{"command": "type", "key": "k"}); err == nil || !strings.Contains(err.Error(), "authentication failed") || strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected the authentication to fail without showing the password, got %v", err)
+	}
+}
+
+// Test that writes are offered only when the configuration allows them
+func TestRedisToolWrites(t *testing.T) {
+	f := startFakeRedis(t, map[string]string{})
+	tool := newRedisTool(redisConfig{Address: f.ln.Addr().String(), AllowWrites: true})
+	if tool.Annotations().ReadOnlyHint {
+		t.Error("expected a writing tool not to be read-only")
+	}
+	enum := tool.InputSchema()["properties"].(map[string]interface{})["command"].(map[string]interface{})["enum"].([]string)
+	if strings.Join(enum, ",") != "get,keys,ttl,type,info,set,del,expire" {
+		t.Errorf("unexpected commands %v", enum)
+	}
+	if got, err := callRedis(t, tool, map[string]interface{}{"command": "set", "key": "k", "value": "v", "ttl_seconds": 60}); err != nil || got != `{"command":"set","key":"k","set":true}` {
+		t.Errorf("unexpected result %s, %v", got, err)
+	}
+	f.mu.Lock()
+	if last := f.commands[len(f.commands)-1]; last != "SET k v EX 60" {
+		t.Errorf("unexpected command %q", last)
+	}
+	f.mu.Unlock()
+	if _, err := tool.ExecuteResult(context.Background(), map[string]interface{}{"command": "expire", "key": "k"}); err == nil {
+		t.Error("expected expire without a TTL to fail")
+	}
+}
+
+// Test that a server that stops answering times the call out
+func TestRedisToolTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			defer conn.Close()
+			io.Copy(io.Discard, conn)
+		}
+	}()
+	tool := newRedisTool(redisConfig{Address: ln.Addr().String(), Timeout: duration(50 * time.Millisecond)})
+	if _, err := tool.ExecuteResult(context.Background(), map[string]interface{}{"command": "info"}); err != context.DeadlineExceeded {
+		t.Errorf("expected the call to time out, got %v", err)
+	}
+}
+
+// Test that the connection's deadline firing before the context's is a
+// timeout all the same
+func TestRedisTimeoutError(t *testing.T) {
+	ctx := context.Background()
+	if err := redisTimeout(ctx, &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}); err != context.DeadlineExceeded {
+		t.Errorf("expected an i/o timeout to be the deadline, got %v", err)
+	}
+	if err := redisTimeout(ctx, io.ErrUnexpectedEOF); err != nil {
+		t.Errorf("expected other errors to be left, got %v", err)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := redisTimeout(cancelled, io.ErrUnexpectedEOF); err != context.Canceled {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+}
+
+// Test that malformed config sections are rejected
+func TestRedisConfig(t *testing.T) {
+	for _, c := range []redisConfig{{}, {Address: "cache.internal:6379", DB: 2}} {
+		if err := c.validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", c, err)
+		}
+	}
+	for _, c := range []redisConfig{{Address: "cache.internal"}, {DB: -1}, {MaxKeys: -5}} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
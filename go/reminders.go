@@ -0,0 +1,541 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// remindersConfig configures the reminder tools in the "reminders" section
+// of the config file.
+type remindersConfig struct {
+	File         string `json:"file"`         // where reminders are kept, defaults to reminders.json in the user's config directory
+	TimeZone     string `json:"timeZone"`     // IANA name of the zone of times without one, defaults to the server's
+	MaxReminders int    `json:"maxReminders"` // pending at once, defaults to 1000
+}
+
+// Defaults of the reminder tools.
+const (
+	defaultMaxReminders = 1000
+	reminderKeep        = 7 * 24 * time.Hour // how long delivered reminders are listed
+	reminderLogger      = "reminders"        // logger of the reminder notifications
+)
+
+// reminderLayouts are the layouts of due times in a zone, besides RFC 3339.
+var reminderLayouts = []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02T15:04:05"}
+
+// validate reports malformed fields.
+func (c *remindersConfig) validate() error {
+	if c.TimeZone != "" {
+		if _, err := time.LoadLocation(c.TimeZone); err != nil {
+			return fmt.Errorf("reminders: unknown timeZone %q", c.TimeZone)
+		}
+	}
+	if c.MaxReminders < 0 {
+		return errors.New("reminders: maxReminders must not be negative")
+	}
+	return nil
+}
+
+// reminder is a one-shot event. Fired is set once it is due, and Delivered
+// once a session has been notified of it.
+type reminder struct {
+	ID        string     `json:"id"`
+	Message   string     `json:"message"`
+	Due       time.Time  `json:"due"`
+	TimeZone  string     `json:"timeZone"`
+	Created   time.Time  `json:"created"`
+	Fired     *time.Time `json:"fired,omitempty"`
+	Delivered bool       `json:"delivered,omitempty"`
+}
+
+// dueIn returns the due time in the reminder's zone.
+func (r *reminder) dueIn() string {
+	if loc, err := time.LoadLocation(r.TimeZone); err == nil {
+		return r.Due.In(loc).Format(time.RFC3339)
+	}
+	return r.Due.Format(time.RFC3339)
+}
+
+// status returns pending, due (fired but not delivered), or delivered.
+func (r *reminder) status() string {
+	switch {
+	case r.Fired == nil:
+		return "pending"
+	case !r.Delivered:
+		return "due"
+	}
+	return "delivered"
+}
+
+// reminders keeps the reminders in a file and notifies the sessions that
+// used the reminder tools when one is due, with a notifications/message of
+// level notice from the "reminders" logger. A reminder due while no such
+// session is connected, or while the server was not running, is delivered
+// to the next session that uses the tools.
+type reminders struct {
+	cfg    remindersConfig
+	loc    *time.Location
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	list     []*reminder
+	sessions map[*Session]bool
+	timer    *time.Timer
+}
+
+// reminderListener is a session's value that stops notifying the session
+// as it ends.
+type reminderListener struct {
+	r    *reminders
+	sess *Session
+}
+
+// remindersKey is the session value key of the reminderListener.
+type remindersKey struct{}
+
+// Close forgets the session.
+func (l *reminderListener) Close() error {
+	l.r.mu.Lock()
+	delete(l.r.sessions, l.sess)
+	l.r.mu.Unlock()
+	return nil
+}
+
+// reminderTools returns the schedule_reminder, list_reminders, and
+// cancel_reminder tools for cfg, loading the reminders kept so far and
+// firing those that came due meanwhile.
+func reminderTools(cfg remindersConfig, logger *slog.Logger) ([]MCPTool, error) {
+	if cfg.MaxReminders == 0 {
+		cfg.MaxReminders = defaultMaxReminders
+	}
+	if cfg.File == "" {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("reminders: no file: %v", err)
+		}
+		cfg.File = filepath.Join(dir, "mcp-minimal-server", "reminders.json")
+	}
+	loc := time.Local
+	if cfg.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.TimeZone); err != nil {
+			return nil, fmt.Errorf("reminders: unknown timeZone %q", cfg.TimeZone)
+		}
+	}
+	r := &reminders{cfg: cfg, loc: loc, logger: logger, sessions: map[*Session]bool{}}
+	data, err := os.ReadFile(cfg.File)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reminders: %v", err)
+	}
+	if err == nil {
+		var kept struct {
+			Reminders []*reminder `json:"reminders"`
+		}
+		if err := json.Unmarshal(data, &kept); err != nil {
+			return nil, fmt.Errorf("reminders: %s: %v", cfg.File, err)
+		}
+		r.list = kept.Reminders
+	}
+	r.mu.Lock()
+	r.fire()
+	r.mu.Unlock()
+	return []MCPTool{&scheduleReminderTool{r}, &listRemindersTool{r}, &cancelReminderTool{r}}, nil
+}
+
+// listen makes the session of ctx be notified of reminders from now on,
+// delivering those due already the first time.
+func (r *reminders) listen(ctx context.Context) {
+	sess := SessionFromContext(ctx)
+	if sess == nil || sess.toClient == nil {
+		return
+	}
+	if _, loaded := sess.Values.LoadOrStore(remindersKey{}, &reminderListener{r, sess}); loaded {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[sess] = true
+	changed := false
+	for _, rem := range r.list {
+		if rem.Fired != nil && !rem.Delivered && r.notify(rem, []*Session{sess}) {
+			changed = true
+		}
+	}
+	if changed {
+		r.save()
+	}
+}
+
+// notify sends rem to sessions and marks it delivered if any was sent it.
+func (r *reminders) notify(rem *reminder, sessions []*Session) bool {
+	for _, sess := range sessions {
+		if sess.log("notice", reminderLogger, map[string]interface{}{"reminder": rem.ID, "message": rem.Message, "due": rem.dueIn()}) {
+			rem.Delivered = true
+		}
+	}
+	return rem.Delivered
+}
+
+// fire fires the reminders that are due, drops those delivered long ago,
+// saves any change, and sets the timer for the next pending one. r.mu must
+// be held.
+func (r *reminders) fire() {
+	now := time.Now()
+	sessions := make([]*Session, 0, len(r.sessions))
+	for sess := range r.sessions {
+		sessions = append(sessions, sess)
+	}
+	changed := false
+	kept := r.list[:0]
+	var next time.Time
+	for _, rem := range r.list {
+		switch {
+		case rem.Fired == nil && !rem.Due.After(now):
+			fired := now.UTC()
+			rem.Fired, changed = &fired, true
+			r.logger.Info("reminder due", "reminder", rem.ID, "due", rem.dueIn())
+			r.notify(rem, sessions)
+		case rem.Fired == nil:
+			if next.IsZero() || rem.Due.Before(next) {
+				next = rem.Due
+			}
+		case rem.Delivered && now.Sub(rem.Due) > reminderKeep:
+			changed = true
+			continue
+		}
+		kept = append(kept, rem)
+	}
+	r.list = kept
+	if changed {
+		r.save()
+	}
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+	if !next.IsZero() {
+		r.timer = time.AfterFunc(time.Until(next), func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.fire()
+		})
+	}
+}
+
+// save writes the reminders to the file, replacing it at once so that a
+// crash leaves the old or the new list. r.mu must be held. Failures are
+// logged: the reminders are still kept in memory.
+func (r *reminders) save() {
+	if err := r.write(); err != nil {
+		r.logger.Error("saving reminders failed", "file", r.cfg.File, "error", err)
+	}
+}
+
+// write writes the reminders to the file.
+func (r *reminders) write() error {
+	dir := filepath.Dir(r.cfg.File)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(map[string]interface{}{"reminders": r.list}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".reminders-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), r.cfg.File); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// newReminderID returns a random ID for a reminder.
+func newReminderID() string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// scheduleReminderTool schedules a reminder.
+type scheduleReminderTool struct {
+	r *reminders
+}
+
+// scheduleReminderArgs are the arguments of the schedule_reminder tool.
+type scheduleReminderArgs struct {
+	Message  string `json:"message" description:"What to remind of"`
+	At       string `json:"at,omitempty" description:"When, as an RFC 3339 time or a local time like 2026-10-15 09:30 in time_zone"`
+	In       string `json:"in,omitempty" description:"When, as a duration from now like 90m or 2h30m"`
+	TimeZone string `json:"time_zone,omitempty" description:"IANA time zone of 'at' without an offset and of the listed times, such as Europe/Berlin (default the server's)"`
+}
+
+// Name returns the name of the schedule_reminder tool.
+func (t *scheduleReminderTool) Name() string {
+	return "schedule_reminder"
+}
+
+// Description returns a brief description of the schedule_reminder tool.
+func (t *scheduleReminderTool) Description() string {
+	return "Schedules a one-time reminder, kept across restarts of the server; when it is due the server sends a log message notification with it to the client"
+}
+
+// InputSchema returns the JSON schema for the schedule_reminder tool's
+// input parameters.
+func (t *scheduleReminderTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(scheduleReminderArgs{})
+}
+
+// Annotations marks the schedule_reminder tool as not destructive.
+func (t *scheduleReminderTool) Annotations() ToolAnnotations {
+	destructive, openWorld := false, false
+	return ToolAnnotations{Title: "Schedule reminder", DestructiveHint: &destructive, OpenWorldHint: &openWorld}
+}
+
+// Execute schedules the reminder without a session to notify.
+func (t *scheduleReminderTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext schedules the reminder and returns it as JSON text.
+func (t *scheduleReminderTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult parses the due time, keeps the reminder, and returns it.
+func (t *scheduleReminderTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a scheduleReminderArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(a.Message) == "" {
+		return nil, errors.New("'message' is required")
+	}
+	loc := t.r.loc
+	if a.TimeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(a.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid value for 'time_zone': unknown time zone %q", a.TimeZone)
+		}
+	}
+	now := time.Now()
+	var due time.Time
+	switch {
+	case (a.At == "") == (a.In == ""):
+		return nil, errors.New("exactly one of 'at' and 'in' is required")
+	case a.In != "":
+		d, err := time.ParseDuration(a.In)
+		if err != nil || d <= 0 {
+			return nil, errors.New("invalid value for 'in': expected a positive duration like 90m")
+		}
+		due = now.Add(d)
+	default:
+		var err error
+		if due, err = parseReminderTime(a.At, loc); err != nil {
+			return nil, err
+		}
+		if !due.After(now) {
+			return nil, fmt.Errorf("invalid value for 'at': %s is in the past", due.In(loc).Format(time.RFC3339))
+		}
+	}
+	t.r.listen(ctx)
+
+	rem := &reminder{ID: newReminderID(), Message: a.Message, Due: due.UTC(), TimeZone: loc.String(), Created: now.UTC()}
+	t.r.mu.Lock()
+	defer t.r.mu.Unlock()
+	pending := 0
+	for _, r := range t.r.list {
+		if r.Fired == nil {
+			pending++
+		}
+	}
+	if pending >= t.r.cfg.MaxReminders {
+		return nil, toolFailure("%d reminders are pending already, the most there can be; cancel some with cancel_reminder", pending)
+	}
+	t.r.list = append(t.r.list, rem)
+	t.r.fire()
+	return mcp.NewResult().WithStructured(reminderView(rem)), nil
+}
+
+// parseReminderTime parses an RFC 3339 time or a time in one of
+// reminderLayouts in loc.
+func parseReminderTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range reminderLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid value for 'at': expected an RFC 3339 time or a time like 2006-01-02 15:04")
+}
+
+// reminderView returns rem as the reminder tools show it.
+func reminderView(rem *reminder) map[string]interface{} {
+	return map[string]interface{}{
+		"id":       rem.ID,
+		"message":  rem.Message,
+		"due":      rem.dueIn(),
+		"timeZone": rem.TimeZone,
+		"status":   rem.status(),
+	}
+}
+
+// listRemindersTool lists the reminders.
+type listRemindersTool struct {
+	r *reminders
+}
+
+// listRemindersArgs are the arguments of the list_reminders tool.
+type listRemindersArgs struct {
+	Status string `json:"status,omitempty" enum:"pending,due,delivered" description:"Only list reminders with this status (default all)"`
+}
+
+// Name returns the name of the list_reminders tool.
+func (t *listRemindersTool) Name() string {
+	return "list_reminders"
+}
+
+// Description returns a brief description of the list_reminders tool.
+func (t *listRemindersTool) Description() string {
+	return "Lists the reminders scheduled with schedule_reminder in order of when they are due: pending ones, due ones not yet sent to a client, and those delivered in the last week"
+}
+
+// InputSchema returns the JSON schema for the list_reminders tool's input
+// parameters.
+func (t *listRemindersTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(listRemindersArgs{})
+}
+
+// Annotations marks the list_reminders tool as read-only.
+func (t *listRemindersTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("List reminders")
+}
+
+// Execute lists the reminders without a session to notify.
+func (t *listRemindersTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext lists the reminders as JSON text.
+func (t *listRemindersTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult returns the reminders with the status asked for.
+func (t *listRemindersTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a listRemindersArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Status != "" && a.Status != "pending" && a.Status != "due" && a.Status != "delivered" {
+		return nil, errors.New("invalid value for 'status': expected pending, due, or delivered")
+	}
+	t.r.listen(ctx)
+	t.r.mu.Lock()
+	defer t.r.mu.Unlock()
+	list := make([]*reminder, 0, len(t.r.list))
+	for _, rem := range t.r.list {
+		if a.Status == "" || rem.status() == a.Status {
+			list = append(list, rem)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Due.Before(list[j].Due) })
+	views := make([]map[string]interface{}, len(list))
+	for i, rem := range list {
+		views[i] = reminderView(rem)
+	}
+	return mcp.NewResult().WithStructured(map[string]interface{}{"reminders": views}), nil
+}
+
+// cancelReminderTool cancels a reminder.
+type cancelReminderTool struct {
+	r *reminders
+}
+
+// cancelReminderArgs are the arguments of the cancel_reminder tool.
+type cancelReminderArgs struct {
+	ID string `json:"id" description:"ID of the reminder, as schedule_reminder returned it"`
+}
+
+// Name returns the name of the cancel_reminder tool.
+func (t *cancelReminderTool) Name() string {
+	return "cancel_reminder"
+}
+
+// Description returns a brief description of the cancel_reminder tool.
+func (t *cancelReminderTool) Description() string {
+	return "Cancels a reminder scheduled with schedule_reminder, or removes it from the list once delivered"
+}
+
+// InputSchema returns the JSON schema for the cancel_reminder tool's input
+// parameters.
+func (t *cancelReminderTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(cancelReminderArgs{})
+}
+
+// Annotations marks the cancel_reminder tool as idempotent.
+func (t *cancelReminderTool) Annotations() ToolAnnotations {
+	openWorld := false
+	return ToolAnnotations{Title: "Cancel reminder", IdempotentHint: true, OpenWorldHint: &openWorld}
+}
+
+// Execute cancels the reminder without a session to notify.
+func (t *cancelReminderTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext removes the reminder and says which it was.
+func (t *cancelReminderTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a cancelReminderArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	t.r.listen(ctx)
+	t.r.mu.Lock()
+	defer t.r.mu.Unlock()
+	for i, rem := range t.r.list {
+		if rem.ID == a.ID {
+			t.r.list = append(t.r.list[:i], t.r.list[i+1:]...)
+			t.r.save()
+			t.r.fire()
+			return []ToolContent{{Type: "text", Text: fmt.Sprintf("Cancelled the reminder %s due %s: %s", rem.ID, rem.dueIn(), rem.Message)}}, nil
+		}
+	}
+	return nil, toolFailure("No reminder %s; list them with list_reminders", a.ID)
+}
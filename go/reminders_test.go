@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond for up to five seconds.
+func waitFor(t *testing.T, cond func() bool) bool {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+// Test that reminders are kept in the file, notified to sessions when due,
+// and delivered after a restart if they came due meanwhile
+func TestReminderTools(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "reminders.json")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tools, err := reminderTools(remindersConfig{File: file, TimeZone: "Asia/Tokyo", MaxReminders: 2}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule, list, cancel := tools[0].(*scheduleReminderTool), tools[1].(*listRemindersTool), tools[2].(*cancelReminderTool)
+	out := &syncBuffer{}
+	ctx := contextWithSession(context.Background(), &Session{toClient: out})
+
+	result, err := schedule.ExecuteResult(ctx, map[string]interface{}{"message": "Stand-up", "in": "100ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	soon := result.Structured.(map[string]interface{})
+	if soon["status"] != "pending" || soon["timeZone"] != "Asia/Tokyo" || !strings.HasSuffix(soon["due"].(string), "+09:00") {
+		t.Errorf("unexpected reminder %v", soon)
+	}
+	result, err = schedule.ExecuteResult(ctx, map[string]interface{}{"message": "Renew the certificate", "at": "2099-01-02 09:30", "time_zone": "Europe/Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if later := result.Structured.(map[string]interface{}); later["due"] != "2099-01-02T09:30:00+01:00" {
+		t.Errorf("unexpected reminder %v", later)
+	}
+	if _, err := schedule.Execute(map[string]interface{}{"message": "Third", "in": "1h"}); err == nil || !strings.Contains(err.Error(), "2 reminders are pending") {
+		t.Errorf("expected the limit of reminders, got %v", err)
+	}
+
+	if !waitFor(t, func() bool { return strings.Contains(out.String(), "Stand-up") }) {
+		t.Fatalf("expected a notification, got %q", out.String())
+	}
+	var note struct {
+		Method string `json:"method"`
+		Params struct {
+			Level  string            `json:"level"`
+			Logger string            `json:"logger"`
+			Data   map[string]string `json:"data"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &note); err != nil {
+		t.Fatal(err)
+	}
+	if note.Method != "notifications/message" || note.Params.Level != "notice" || note.Params.Logger != "reminders" || note.Params.Data["reminder"] != soon["id"] || note.Params.Data["due"] != soon["due"] {
+		t.Errorf("unexpected notification %s", out.String())
+	}
+	result, _ = list.ExecuteResult(ctx, map[string]interface{}{"status": "delivered"})
+	if views := result.Structured.(map[string]interface{})["reminders"].([]map[string]interface{}); len(views) != 1 || views[0]["id"] != soon["id"] {
+		t.Errorf("expected the delivered reminder, got %v", views)
+	}
+
+	// A reminder due while the server was not running is delivered to the
+	// next session using the tools.
+	data, _ := os.ReadFile(file)
+	os.WriteFile(file, []byte(strings.Replace(string(data), `"due": "2099-01-02T08:30:00Z"`, `"due": "2020-01-02T08:30:00Z"`, 1)), 0o600)
+	tools, err = reminderTools(remindersConfig{File: file}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = &syncBuffer{}
+	ctx = contextWithSession(context.Background(), &Session{toClient: out})
+	result, _ = tools[1].(*listRemindersTool).ExecuteResult(ctx, map[string]interface{}{})
+	views := result.Structured.(map[string]interface{})["reminders"].([]map[string]interface{})
+	if len(views) != 2 || views[0]["message"] != "Renew the certificate" || views[0]["status"] != "delivered" || !strings.Contains(out.String(), "Renew the certificate") {
+		t.Errorf("expected the missed reminder to be delivered, got %v and %q", views, out.String())
+	}
+	if _, err := tools[1].Execute(map[string]interface{}{"status": "late"}); err == nil {
+		t.Error("expected an unknown status to be rejected")
+	}
+
+	content, err := cancel.Execute(map[string]interface{}{"id": soon["id"]})
+	if err != nil || !strings.HasPrefix(content[0].Text, "Cancelled the reminder") {
+		t.Errorf("unexpected result %+v, %v", content, err)
+	}
+	for want, args := range map[string]map[string]interface{}{
+		"'message' is required":         {"in": "1h"},
+		"exactly one of 'at' and 'in'":  {"message": "x"},
+		"invalid value for 'in'":        {"message": "x", "in": "-5m"},
+		"is in the past":                {"message": "x", "at": "2001-01-01T00:00:00Z"},
+		"invalid value for 'at'":        {"message": "x", "at": "tomorrow"},
+		"invalid value for 'time_zone'": {"message": "x", "at": "2099-01-01 10:00", "time_zone": "Mars/Olympus"},
+	} {
+		if _, err := schedule.Execute(args); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected an error containing %q, got %v", args, want, err)
+		}
+	}
+	if _, err := cancel.Execute(map[string]interface{}{"id": "nope"}); err == nil || !strings.Contains(err.Error(), "No reminder nope") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// Test that sessions get log messages at or above the level they asked for
+func TestSessionLog(t *testing.T) {
+	out := &syncBuffer{}
+	sess := &Session{toClient: out}
+	if !sess.log("info", "test", "one") {
+		t.Error("expected a message without a level set")
+	}
+	sess.setLogLevel("warning")
+	if sess.log("notice", "test", "two") || !sess.log("error", "test", "three") {
+		t.Error("expected only messages from warning up")
+	}
+	if strings.Contains(out.String(), "two") || !strings.Contains(out.String(), `"level":"error"`) {
+		t.Errorf("unexpected messages %q", out.String())
+	}
+	if (&Session{}).log("error", "test", "four") {
+		t.Error("expected no message without a way to the client")
+	}
+}
+
+// Test that malformed config sections are rejected
+func TestRemindersConfig(t *testing.T) {
+	if err := (&remindersConfig{TimeZone: "America/New_York"}).validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, c := range []remindersConfig{{TimeZone: "Nowhere/City"}, {MaxReminders: -1}} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
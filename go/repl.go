@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"mcp-minimal-server-go/client"
+)
+
+// connectInProcess serves s over a pair of pipes and returns an initialized
+// client talking to it, so that every request goes through the same code
+// paths as one from a real host. Closing the client stops the server.
+func connectInProcess(ctx context.Context, s *Server) (*client.Client, error) {
+	c := newInProcessClient(ctx, s, client.WithClientInfo(serverName+"-repl", serverVersion))
+	if _, err := c.Initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newInProcessClient serves s over a pipe and returns an uninitialized
+// client connected to it. Closing the client stops serving.
+func newInProcessClient(ctx context.Context, s *Server, opts ...client.Option) *client.Client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Serve(ctx, serverIn, serverOut)
+		serverOut.Close()
+	}()
+	closer := func() error {
+		clientOut.Close()
+		<-done
+		return nil
+	}
+	return client.New(clientIn, clientOut, closer, opts...)
+}
+
+// replHelp lists the commands of the REPL.
+const replHelp = `Commands:
+  tools                        list the available tools
+  describe TOOL                show a tool's description and arguments
+  call TOOL [KEY=VALUE ...]    call a tool; "call" may be omitted
+  help                         show this help
+  quit                         leave the REPL
+Values are converted to the type in the tool's schema; objects and arrays
+are written as JSON. Quote values containing spaces: text="hello world".
+`
+
+// runREPL reads commands from in and runs them against s until in ends, the
+// quit command is given, or ctx is cancelled.
+func runREPL(ctx context.Context, s *Server, in io.Reader, out io.Writer) error {
+	c, err := connectInProcess(ctx, s)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s %s: %d tools. Type \"help\" for commands.\n", serverName, serverVersion, len(tools))
+
+	lines := make(chan string)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-stop:
+				return
+			}
+		}
+	}()
+	for {
+		fmt.Fprint(out, "mcp> ")
+		var line string
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return nil
+		case l, ok := <-lines:
+			if !ok {
+				fmt.Fprintln(out)
+				return nil
+			}
+			line = l
+		}
+		words, err := splitCommand(line)
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		if len(words) == 0 {
+			continue
+		}
+		switch cmd := words[0]; cmd {
+		case "quit", "exit":
+			return nil
+		case "help", "?":
+			fmt.Fprint(out, replHelp)
+		case "tools":
+			if tools, err = c.ListTools(ctx); err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			printTools(out, tools)
+		case "describe":
+			if len(words) != 2 {
+				fmt.Fprintln(out, "usage: describe TOOL")
+				continue
+			}
+			if t, ok := findTool(tools, words[1]); ok {
+				describeTool(out, t)
+			} else {
+				fmt.Fprintf(out, "error: unknown tool %q\n", words[1])
+			}
+		default:
+			if cmd == "call" {
+				if len(words) < 2 {
+					fmt.Fprintln(out, "usage: call TOOL [KEY=VALUE ...]")
+					continue
+				}
+				words = words[1:]
+			}
+			t, ok := findTool(tools, words[0])
+			if !ok {
+				fmt.Fprintf(out, "error: unknown tool or command %q\n", words[0])
+				continue
+			}
+			args, err := parseToolArgs(words[1:], t.InputSchema)
+			if err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			result, err := c.CallTool(ctx, t.Name, args)
+			if err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+				continue
+			}
+			printResult(out, result)
+		}
+	}
+}
+
+// findTool returns the tool named name.
+func findTool(tools []client.Tool, name string) (client.Tool, bool) {
+	for _, t := range tools {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return client.Tool{}, false
+}
+
+// printTools writes one line per tool with its description.
+func printTools(w io.Writer, tools []client.Tool) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, t := range tools {
+		fmt.Fprintf(tw, "  %s\t%s\n", t.Name, t.Description)
+	}
+	tw.Flush()
+}
+
+// describeTool writes the description and arguments of t.
+func describeTool(w io.Writer, t client.Tool) {
+	fmt.Fprintf(w, "%s: %s\n", t.Name, t.Description)
+	properties, _ := t.InputSchema["properties"].(map[string]interface{})
+	if len(properties) == 0 {
+		fmt.Fprintln(w, "  (no arguments)")
+		return
+	}
+	required := requiredProperties(t.InputSchema)
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		prop, _ := properties[name].(map[string]interface{})
+		typ, _ := prop["type"].(string)
+		if typ == "" {
+			typ = "any"
+		}
+		if required[name] {
+			typ += ", required"
+		}
+		desc, _ := prop["description"].(string)
+		if enum, ok := prop["enum"].([]interface{}); ok {
+			desc = strings.TrimSpace(fmt.Sprintf("%s %v", desc, enum))
+		}
+		fmt.Fprintf(tw, "  %s\t(%s)\t%s\n", name, typ, desc)
+	}
+	tw.Flush()
+}
+
+// printResult writes the content of a tool result in a readable form.
+func printResult(w io.Writer, result *client.CallToolResult) {
+	if result.IsError {
+		fmt.Fprint(w, "error: ")
+	}
+	for _, c := range result.Content {
+		switch c.Type {
+		case "text":
+			fmt.Fprintln(w, c.Text)
+		case "image", "audio":
+			size := base64.StdEncoding.DecodedLen(len(c.Data))
+			if data, err := base64.StdEncoding.DecodeString(c.Data); err == nil {
+				size = len(data)
+			}
+			fmt.Fprintf(w, "[%s %s, %d bytes]\n", c.Type, c.MimeType, size)
+		default:
+			fmt.Fprintf(w, "[%s content]\n", c.Type)
+		}
+	}
+	if len(result.Content) == 0 {
+		fmt.Fprintln(w, "(no content)")
+	}
+}
+
+// parseToolArgs converts KEY=VALUE pairs into tool arguments, converting
+// each value to the type of its property in schema. Values of untyped or
+// unknown properties are decoded as JSON if possible and kept as strings
+// otherwise.
+func parseToolArgs(pairs []string, schema map[string]interface{}) (map[string]interface{}, error) {
+	properties, _ := schema["properties"].(map[string]interface{})
+	args := map[string]interface{}{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("expected KEY=VALUE, got %q", pair)
+		}
+		prop, _ := properties[key].(map[string]interface{})
+		typ, _ := prop["type"].(string)
+		switch typ {
+		case "string":
+			args[key] = value
+		case "integer", "number":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number for '%s': %q", key, value)
+			}
+			args[key] = n
+		case "boolean":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid boolean for '%s': %q", key, value)
+			}
+			args[key] = b
+		case "object", "array":
+			var v interface{}
+			if err := json.Unmarshal([]byte(value), &v); err != nil {
+				return nil, fmt.Errorf("invalid JSON for '%s': %v", key, err)
+			}
+			args[key] = v
+		default:
+			var v interface{}
+			if err := json.Unmarshal([]byte(value), &v); err != nil {
+				v = value
+			}
+			args[key] = v
+		}
+	}
+	return args, nil
+}
+
+// splitCommand splits a command line into words separated by spaces.
+// Single and double quotes group words, and a backslash escapes the next
+// character outside single quotes.
+func splitCommand(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test listing, describing, and calling tools from the REPL
+func TestREPL(t *testing.T) {
+	input := strings.Join([]string{
+		"tools",
+		"describe qr_code",
+		`call echo message="hello world"`,
+		"count_text text=abc",
+		"qr_code text=hi scale=2",
+		"qr_code text=hi scale=big",
+		"echo",
+		"missing",
+		"quit",
+		"echo message=unreached",
+	}, "\n")
+	var out bytes.Buffer
+	s := NewServer(WithTools(&echoTool{}, &countTextTool{}, &qrCodeTool{}))
+	if err := runREPL(context.Background(), s, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("runREPL error: %v", err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"3 tools.",
+		"  echo        Returns the specified message as is\n",
+		"  scale             (integer)           Size of one module in pixels (default 8)\n",
+		"  text              (string, required)",
+		"Echo: hello world\n",
+		"characters: 3\n",
+		"[image image/png, ",
+		`error: invalid number for 'scale': "big"`,
+		"error: Missing required parameter: 'message'",
+		`error: unknown tool or command "missing"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "unreached") {
+		t.Errorf("commands after quit were run:\n%s", got)
+	}
+}
+
+// Test converting KEY=VALUE pairs to arguments using the tool's schema
+func TestParseToolArgs(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"s": map[string]interface{}{"type": "string"},
+			"n": map[string]interface{}{"type": "integer"},
+			"b": map[string]interface{}{"type": "boolean"},
+			"o": map[string]interface{}{"type": "object"},
+			"a": map[string]interface{}{},
+		},
+	}
+	args, err := parseToolArgs([]string{"s=42", "n=42", "b=true", `o={"k":1}`, "a=[1]", "z=plain", "e="}, schema)
+	if err != nil {
+		t.Fatalf("parseToolArgs error: %v", err)
+	}
+	want := map[string]interface{}{
+		"s": "42", "n": float64(42), "b": true, "o": map[string]interface{}{"k": float64(1)},
+		"a": []interface{}{float64(1)}, "z": "plain", "e": "",
+	}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("got %v, want %v", args, want)
+	}
+	for _, bad := range []string{"novalue", "=x", "n=x", "b=maybe", "o={"} {
+		if _, err := parseToolArgs([]string{bad}, schema); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+// Test splitting command lines with quotes and escapes
+func TestSplitCommand(t *testing.T) {
+	cases := map[string][]string{
+		`call echo message="a b"`: {"call", "echo", "message=a b"},
+		`  x   'y z'  `:           {"x", "y z"},
+		`a\ b "c\"d" 'e\f'`:       {"a b", `c"d`, `e\f`},
+		`k=""`:                    {"k="},
+		"":                        nil,
+	}
+	for line, want := range cases {
+		got, err := splitCommand(line)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("splitCommand(%q) = %q, %v; want %q", line, got, err, want)
+		}
+	}
+	for _, bad := range []string{`"open`, `trailing\`} {
+		if _, err := splitCommand(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// requestIDs are the IDs of a session's requests in flight. JSON-RPC
+// matches responses to requests by ID alone, so while a request is in
+// flight a second one with its ID could not be told apart from it, and
+// tool calls run concurrently. Such requests are refused as invalid.
+type requestIDs struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+// start records the request id as in flight, reporting false if it is
+// already. IDs are compared as sent, so 1 and "1" differ, as in JSON-RPC.
+func (r *requestIDs) start(id json.RawMessage) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ids[string(id)] {
+		return false
+	}
+	if r.ids == nil {
+		r.ids = map[string]bool{}
+	}
+	r.ids[string(id)] = true
+	return true
+}
+
+// end records that the request id is no longer in flight.
+func (r *requestIDs) end(id json.RawMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.ids, string(id))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Test that a request reusing the ID of one in flight is refused, alone
+// or in a batch, while other IDs, and the ID once answered, are served
+func TestDuplicateRequestIDs(t *testing.T) {
+	tool := newBlockingTool()
+	pw, out, errc := serveInBackground(context.Background(), NewServer(WithTools(tool)))
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"block","arguments":{}},"id":1}`)
+	<-tool.started
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/list","id":1}`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"ping","id":"1"}`)
+	fmt.Fprintln(pw, `[{"jsonrpc":"2.0","method":"ping","id":1},{"jsonrpc":"2.0","method":"ping","id":2}]`)
+	waitForOutput(t, out, regexp.MustCompile(`"id":2`), 1)
+	close(tool.release)
+	waitForOutput(t, out, regexp.MustCompile(`released`), 1)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"ping","id":1}`)
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+
+	duplicate := `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"Invalid Request: a request with this id is still in flight"}}`
+	want := []string{
+		duplicate,
+		`{"id":"1","jsonrpc":"2.0","result":{}}`,
+		`[` + duplicate + `,{"id":2,"jsonrpc":"2.0","result":{}}]`,
+		`{"id":1,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"released"}]}}`,
+		`{"id":1,"jsonrpc":"2.0","result":{}}`,
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d responses, got %q", len(want), lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("response %d: expected %s, got %s", i, want[i], lines[i])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// maxLoggedPayload bounds the bytes of params and of the response that a
+// request log record holds at debug level.
+const maxLoggedPayload = 4096
+
+// WithRequestLog logs every request once it is answered, in a single
+// record holding a correlation ID unique to the request, the session,
+// method, request ID, tool name, duration and outcome, so that a session
+// can be followed from the logs alone. At debug level the record also
+// holds the request's params and the response, with secrets redacted.
+// Notifications are logged at debug level as they arrive.
+func WithRequestLog() Option {
+	return func(s *Server) {
+		s.requestLog = true
+	}
+}
+
+// loggedRequest is a request waiting for its response to be logged.
+type loggedRequest struct {
+	server *Server
+	w      io.Writer
+	attrs  []slog.Attr // describing the request
+	params json.RawMessage
+	start  time.Time
+	once   sync.Once
+}
+
+// logRequest returns the writer through which req is answered: w, which
+// logs the request when the response passes through it if request
+// logging is enabled.
+func (s *Server) logRequest(sess *session, req *JSONRPCRequest, w io.Writer) io.Writer {
+	if !s.requestLog {
+		return w
+	}
+	attrs := []slog.Attr{
+		slog.String("correlation", newCorrelationID()),
+		slog.String("session", sess.state.ID()),
+		slog.String("method", req.Method),
+	}
+	if req.Method == "tools/call" {
+		var params struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(req.Params, &params) == nil && params.Name != "" {
+			attrs = append(attrs, slog.String("tool", params.Name))
+		}
+	}
+	if len(req.ID) == 0 {
+		if s.logger.Enabled(sess.ctx, slog.LevelDebug) {
+			attrs = append(attrs, slog.String("params", s.loggedPayload(req.Params)))
+		}
+		s.logger.LogAttrs(sess.ctx, slog.LevelDebug, "notification", attrs...)
+		return w
+	}
+	attrs = append(attrs, slog.String("id", string(req.ID)))
+	return &loggedRequest{server: s, w: w, attrs: attrs, params: req.Params, start: time.Now()}
+}
+
+// Write passes p on and logs the request if p is its response. Responses
+// are written in one call each.
+func (lr *loggedRequest) Write(p []byte) (int, error) {
+	n, err := lr.w.Write(p)
+	lr.once.Do(func() { lr.log(p) })
+	return n, err
+}
+
+// log writes the record of the request answered with resp.
+func (lr *loggedRequest) log(resp []byte) {
+	s := lr.server
+	var msg struct {
+		Error *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+		Result struct {
+			IsError bool `json:"isError"`
+		} `json:"result"`
+	}
+	_ = json.Unmarshal(resp, &msg)
+	attrs := append(lr.attrs, slog.Duration("duration", time.Since(lr.start)))
+	switch {
+	case msg.Error != nil:
+		attrs = append(attrs, slog.String("outcome", "error"), slog.Int("code", msg.Error.Code))
+	case msg.Result.IsError:
+		attrs = append(attrs, slog.String("outcome", "tool_error"))
+	default:
+		attrs = append(attrs, slog.String("outcome", "ok"))
+	}
+	ctx := context.Background()
+	if s.logger.Enabled(ctx, slog.LevelDebug) {
+		attrs = append(attrs,
+			slog.String("params", s.loggedPayload(lr.params)),
+			slog.String("response", s.loggedPayload(bytes.TrimSpace(resp))))
+	}
+	s.logger.LogAttrs(ctx, slog.LevelInfo, "request", attrs...)
+}
+
+// loggedPayload returns msg redacted and cut to maxLoggedPayload bytes.
+func (s *Server) loggedPayload(msg json.RawMessage) string {
+	if len(msg) == 0 {
+		return ""
+	}
+	redacted := s.redactor.redactJSON(msg)
+	if len(redacted) > maxLoggedPayload {
+		return string(redacted[:maxLoggedPayload]) + "..."
+	}
+	return string(redacted)
+}
+
+// newCorrelationID returns a random ID for a logged request.
+func newCorrelationID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
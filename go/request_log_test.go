@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// Test that every answered request is logged once with its outcome, and
+// its payload at debug level
+func TestRequestLog(t *testing.T) {
+	r, err := newRedactor(nil, []string{`sk-[A-Za-z0-9]+`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	s := NewServer(WithRequestLog(), WithLogger(logger), WithRedactor(r), WithTools(&echoTool{}))
+	input := `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}
+{"jsonrpc":"2.0","method":"notifications/initialized"}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"sk-secret"}},"id":2}
+{"jsonrpc":"2.0","method":"no/such/method","id":"three"}`
+	runServerInput(t, s, input)
+
+	// Tool calls are answered on a worker, so records are found by method.
+	records := map[string]map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %s: %v", line, err)
+		}
+		if rec["msg"] == "request" || rec["msg"] == "notification" {
+			records[rec["method"].(string)] = rec
+		}
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected a record per message, got %v", records)
+	}
+	correlations := map[interface{}]bool{}
+	for _, want := range []map[string]interface{}{
+		{"msg": "request", "method": "initialize", "id": "1", "outcome": "ok"},
+		{"msg": "notification", "method": "notifications/initialized"},
+		{"msg": "request", "method": "tools/call", "tool": "echo", "id": "2", "outcome": "ok"},
+		{"msg": "request", "method": "no/such/method", "id": `"three"`, "outcome": "error", "code": float64(-32601)},
+	} {
+		rec := records[want["method"].(string)]
+		for k, v := range want {
+			if rec[k] != v {
+				t.Errorf("%s: expected %s=%v, got %v", want["method"], k, v, rec[k])
+			}
+		}
+		if rec["session"] != records["initialize"]["session"] {
+			t.Errorf("%s: expected the same session, got %v", want["method"], rec["session"])
+		}
+		correlations[rec["correlation"]] = true
+	}
+	if len(correlations) != 4 {
+		t.Errorf("expected a correlation ID per message, got %v", correlations)
+	}
+	call := records["tools/call"]
+	if _, ok := call["duration"]; !ok {
+		t.Error("expected the call's duration")
+	}
+	if strings.Contains(logs.String(), "sk-secret") || !strings.Contains(call["params"].(string), `"name":"echo"`) ||
+		!strings.Contains(call["response"].(string), `"result"`) {
+		t.Errorf("expected the redacted payloads, got %v", call)
+	}
+
+	// Above debug level only the outcome is logged.
+	logs.Reset()
+	logger = slog.New(slog.NewJSONHandler(&logs, nil))
+	runServerInput(t, NewServer(WithRequestLog(), WithLogger(logger), WithTools(&echoTool{})), `{"jsonrpc":"2.0","method":"ping","id":1}`)
+	if !strings.Contains(logs.String(), `"method":"ping"`) || strings.Contains(logs.String(), `"response"`) {
+		t.Errorf("expected a record without payload, got %s", logs.String())
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+// requiredFields returns the properties schema requires, in order. Schemas
+// built in Go list them as a []string, but schemas decoded from JSON, such
+// as those of plugins, upstreams, and configuration files, as a
+// []interface{}.
+func requiredFields(schema map[string]interface{}) []string {
+	switch list := schema["required"].(type) {
+	case []string:
+		return list
+	case []interface{}:
+		fields := make([]string, 0, len(list))
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				fields = append(fields, s)
+			}
+		}
+		return fields
+	}
+	return nil
+}
+
+// requiredProperties returns the set of properties schema requires.
+func requiredProperties(schema map[string]interface{}) map[string]bool {
+	required := map[string]bool{}
+	for _, name := range requiredFields(schema) {
+		required[name] = true
+	}
+	return required
+}
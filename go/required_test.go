@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodedSchemaTool has a schema decoded from JSON, as plugin and
+// configured tools do.
+type decodedSchemaTool struct{}
+
+func (decodedSchemaTool) Name() string        { return "lookup_host" }
+func (decodedSchemaTool) Description() string { return "Looks up a host" }
+func (decodedSchemaTool) InputSchema() map[string]interface{} {
+	var schema map[string]interface{}
+	json.Unmarshal([]byte(`{"type":"object","properties":{"host":{"type":"string"}},"required":["host"]}`), &schema)
+	return schema
+}
+func (decodedSchemaTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return []ToolContent{{Type: "text", Text: "found"}}, nil
+}
+
+// Test extracting required properties from Go and decoded schemas
+func TestRequiredFields(t *testing.T) {
+	for _, c := range []struct {
+		schema map[string]interface{}
+		want   []string
+	}{
+		{map[string]interface{}{"required": []string{"b", "a"}}, []string{"b", "a"}},
+		{map[string]interface{}{"required": []interface{}{"b", 7, "a"}}, []string{"b", "a"}},
+		{map[string]interface{}{"required": "a"}, nil},
+		{map[string]interface{}{}, nil},
+	} {
+		if got := requiredFields(c.schema); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("requiredFields(%v) = %q, expected %q", c.schema, got, c.want)
+		}
+	}
+
+	lines := runServerInput(t, NewServer(WithTools(decodedSchemaTool{})), strings.Join([]string{
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"lookup_host","arguments":{}},"id":1}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"lookup_host","arguments":{"host":"example.com"}},"id":2}`,
+	}, "\n")+"\n")
+	out := strings.Join(lines, "\n")
+	if len(lines) != 2 || !strings.Contains(out, `"id":1,"error":{"code":-32602,"message":"Missing required parameter: 'host'","data":{"errors":[{"field":"host","reason":"missing"}]}}`) || !strings.Contains(out, "found") {
+		t.Errorf("expected the decoded schema to be enforced, got %q", lines)
+	}
+}
+
+// Test that arguments may be omitted unless the schema requires some
+func TestOmittedArguments(t *testing.T) {
+	s := NewServer(WithTools(&countingTool{}, decodedSchemaTool{}))
+	for _, params := range []string{`{"name":"lookup"}`, `{"name":"lookup","arguments":null}`} {
+		lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":`+params+`,"id":1}`+"\n")
+		if len(lines) != 1 || !strings.Contains(lines[0], `"result":{"content":[{"type":"text","text":"call`) {
+			t.Errorf("%s: expected the tool to run, got %q", params, lines)
+		}
+	}
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"lookup_host"},"id":1}`+"\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "Missing required parameter: 'host'") {
+		t.Errorf("expected the required argument to be reported, got %q", lines)
+	}
+	lines = runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"arguments":{}},"id":1}`+"\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "missing tool name") {
+		t.Errorf("expected the missing name to be reported, got %q", lines)
+	}
+}
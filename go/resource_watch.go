@@ -0,0 +1,181 @@
+package main
+
+import (
+	"io/fs"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fileStamp identifies a version of a file: a file whose stamp changed has
+// been written to.
+type fileStamp struct {
+	modTime int64 // in nanoseconds, so that stamps compare with ==
+	size    int64
+}
+
+// resourceWatcher notices files that are added to, removed from, or
+// changed under the resources directory, and notifies the initialized
+// sessions: of added and removed files with notifications/resources/list_changed,
+// and of each changed or removed file with notifications/resources/updated
+// if they subscribed to it. The standard library has no file change
+// events, so it compares the files' modification times and sizes every
+// interval, and only while any session is being notified. Polling works
+// the same on every platform and file system, network ones included, at
+// the cost of notifying up to an interval late and of walking the whole
+// directory each time, so large directories want a longer
+// --resources-poll.
+type resourceWatcher struct {
+	files    *fileResources
+	lists    *listings // invalidated when files are added or removed
+	interval time.Duration
+	ticker   func(time.Duration) (<-chan time.Time, func()) // replaced in tests
+
+	mu       sync.Mutex
+	sessions map[*Session]bool
+	stamps   map[string]fileStamp // by URI, as of the last poll
+	stop     chan struct{}        // closed to stop polling, nil while not polling
+}
+
+// WithResourceWatch looks for changed files under the resources directory
+// every interval, to notify clients. It has no effect without
+// WithResourceDir.
+func WithResourceWatch(interval time.Duration) Option {
+	return func(s *Server) {
+		s.resourcePoll = interval
+	}
+}
+
+// newResourceWatcher returns a watcher of files, polling every interval and
+// invalidating lists when the files listed change.
+func newResourceWatcher(files *fileResources, lists *listings, interval time.Duration) *resourceWatcher {
+	return &resourceWatcher{files: files, lists: lists, interval: interval, ticker: newTicker, sessions: map[*Session]bool{}}
+}
+
+// newTicker returns the channel and the stop function of a time.Ticker.
+func newTicker(d time.Duration) (<-chan time.Time, func()) {
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// add notifies sess of changes from now on, starting to poll if it is the
+// first such session. Sessions that cannot be sent messages, such as those
+// of the http transport, are left out.
+func (rw *resourceWatcher) add(sess *Session) {
+	if sess.toClient == nil {
+		return
+	}
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.sessions[sess] = true
+	if rw.stop == nil {
+		rw.stamps = rw.files.stamps()
+		rw.stop = make(chan struct{})
+		go rw.run(rw.stop)
+	}
+}
+
+// remove stops notifying sess, and stops polling if it was the last
+// session.
+func (rw *resourceWatcher) remove(sess *Session) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	delete(rw.sessions, sess)
+	if len(rw.sessions) == 0 && rw.stop != nil {
+		close(rw.stop)
+		rw.stop = nil
+	}
+}
+
+// run polls every interval until stop is closed.
+func (rw *resourceWatcher) run(stop <-chan struct{}) {
+	ticks, stopTicker := rw.ticker(rw.interval)
+	defer stopTicker()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticks:
+			rw.poll()
+		}
+	}
+}
+
+// poll compares the files with those of the last poll and notifies the
+// sessions of the differences.
+func (rw *resourceWatcher) poll() {
+	current := rw.files.stamps()
+	rw.mu.Lock()
+	previous := rw.stamps
+	rw.stamps = current
+	sessions := make([]*Session, 0, len(rw.sessions))
+	for sess := range rw.sessions {
+		sessions = append(sessions, sess)
+	}
+	rw.mu.Unlock()
+
+	listChanged := false
+	var updated []string
+	for uri, stamp := range current {
+		if old, ok := previous[uri]; !ok {
+			listChanged = true
+		} else if old != stamp {
+			updated = append(updated, uri)
+		}
+	}
+	for uri := range previous {
+		if _, ok := current[uri]; !ok {
+			listChanged = true
+			updated = append(updated, uri)
+		}
+	}
+	sort.Strings(updated)
+	// Sessions notified of the change must not be answered with the
+	// cached list.
+	if listChanged {
+		rw.lists.invalidate()
+	}
+	for _, sess := range sessions {
+		if listChanged {
+			sendNotification(sess.toClient, "notifications/resources/list_changed", nil)
+		}
+		for _, uri := range updated {
+			if sess.subscribed(uri) {
+				sendNotification(sess.toClient, "notifications/resources/updated", map[string]interface{}{"uri": uri})
+			}
+		}
+	}
+}
+
+// stamps returns the stamps of the files, by URI.
+func (r *fileResources) stamps() map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	r.ws.Walk(func(path, rel string, info fs.FileInfo) {
+		stamps[fileURI(path)] = fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+	})
+	return stamps
+}
+
+// subscribe makes the session be notified of changes to the resource uri.
+func (s *Session) subscribe(uri string) {
+	s.mu.Lock()
+	if s.subscriptions == nil {
+		s.subscriptions = map[string]bool{}
+	}
+	s.subscriptions[uri] = true
+	s.mu.Unlock()
+}
+
+// unsubscribe undoes subscribe.
+func (s *Session) unsubscribe(uri string) {
+	s.mu.Lock()
+	delete(s.subscriptions, uri)
+	s.mu.Unlock()
+}
+
+// subscribed reports whether the session subscribed to the resource uri.
+func (s *Session) subscribed(uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscriptions[uri]
+}
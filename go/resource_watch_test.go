@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that sessions are notified of added files and of changes to the
+// files they subscribed to
+func TestResourceWatch(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("old"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := newFileResources(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(WithTools(), WithResourceDir(files), WithResourceWatch(time.Hour))
+	// The test ticks the watcher's clock itself: once a tick is received,
+	// the poll of the one before has finished.
+	ticks := make(chan time.Time)
+	s.watcher.ticker = func(time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+	ctx, cancel := context.WithCancel(context.Background())
+	pw, out, errc := serveInBackground(ctx, s)
+	defer func() {
+		cancel()
+		pw.Close()
+		<-errc
+	}()
+
+	uri := func(name string) string { return fileURI(filepath.Join(files.ws.Root, name)) }
+	fmt.Fprintf(pw, `{"jsonrpc":"2.0","method":"resources/subscribe","params":{"uri":%q},"id":1}`+"\n", uri("a.txt"))
+	fmt.Fprintf(pw, `{"jsonrpc":"2.0","method":"resources/subscribe","params":{"uri":%q},"id":2}`+"\n", uri("missing/../../x"))
+	waitForOutput(t, out, regexp.MustCompile(`"id":2`), 1)
+	if !strings.Contains(out.String(), `{"id":1,"jsonrpc":"2.0","result":{}}`) || !strings.Contains(out.String(), "Unknown resource") {
+		t.Fatalf("unexpected responses %s", out)
+	}
+
+	for name, content := range map[string]string{"a.txt": "new content", "b.txt": "new content", "c.txt": "added"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ticks <- time.Time{}
+	ticks <- time.Time{}
+	if !strings.Contains(out.String(), "notifications/resources/list_changed") {
+		t.Errorf("expected the added file to be notified, got %s", out)
+	}
+	if !regexp.MustCompile(`"method":"notifications/resources/updated","params":\{"uri":"[^"]*/a\.txt"\}`).MatchString(out.String()) {
+		t.Errorf("expected the change to the subscribed a.txt to be notified, got %s", out)
+	}
+	if strings.Contains(out.String(), "b.txt") {
+		t.Errorf("expected no notification for the unsubscribed b.txt, got %s", out)
+	}
+}
+
+// Test that the cached lists are discarded when files are added or
+// removed, but not when they only change
+func TestResourceWatchInvalidates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := newFileResources(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(WithTools(), WithResourceDir(files), WithResourceWatch(time.Hour))
+	s.watcher.stamps = files.stamps()
+	for _, tc := range []struct {
+		change      func() error
+		invalidates bool
+	}{
+		{func() error { return os.WriteFile(filepath.Join(dir, "a.txt"), []byte("new content"), 0o644) }, false},
+		{func() error { return os.WriteFile(filepath.Join(dir, "b.txt"), []byte("added"), 0o644) }, true},
+		{func() error { return os.Remove(filepath.Join(dir, "a.txt")) }, true},
+	} {
+		if _, err := s.cachedListings(); err != nil {
+			t.Fatal(err)
+		}
+		if err := tc.change(); err != nil {
+			t.Fatal(err)
+		}
+		s.watcher.poll()
+		if invalidated := s.lists.current == nil; invalidated != tc.invalidates {
+			t.Errorf("expected invalidated %v after the change, got %v", tc.invalidates, invalidated)
+		}
+	}
+}
+
+// Test that watching is announced in the capabilities
+func TestResourceWatchCapabilities(t *testing.T) {
+	files, err := newFileResources(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		poll time.Duration
+		want string
+	}{
+		{time.Second, `"resources":{"listChanged":true,"subscribe":true}`},
+		{0, `"resources":{}`},
+	} {
+		lines := runServerInput(t, NewServer(WithTools(), WithResourceDir(files), WithResourceWatch(tc.poll)),
+			`{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`+"\n")
+		if len(lines) != 1 || !strings.Contains(lines[0], tc.want) {
+			t.Errorf("poll %v: expected %s, got %q", tc.poll, tc.want, lines)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mcp-minimal-server-go/client"
+	"mcp-minimal-server-go/mcp"
+)
+
+// maxResourceFileSize is the largest file served as a resource. Blobs
+// grow by a third when base64-encoded, so this stays well below
+// defaultMaxMessageSize.
+const maxResourceFileSize = 8 << 20
+
+// resourceTypes are the MIME types of extensions the system tables often
+// lack or map to a type clients do not treat as text.
+var resourceTypes = map[string]string{
+	".go":   "text/x-go",
+	".md":   "text/markdown",
+	".rs":   "text/x-rust",
+	".py":   "text/x-python",
+	".sh":   "text/x-shellscript",
+	".toml": "application/toml",
+	".ts":   "text/x-typescript",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+}
+
+// textApplicationTypes are the application/* MIME types whose content is
+// text.
+var textApplicationTypes = map[string]bool{
+	"application/javascript": true, "application/json": true, "application/sql": true, "application/toml": true,
+	"application/x-sh": true, "application/xml": true, "application/yaml": true, "application/x-yaml": true,
+}
+
+// fileResources serves the regular files of a workspace as resources with
+// file:// URIs. Files and directories whose names start with a dot are
+// left out, as are those the workspace ignores.
+type fileResources struct {
+	ws *Workspace
+}
+
+// newFileResources returns the resources of the files under dir, leaving
+// out those matching the ignore patterns.
+func newFileResources(dir string, ignore []string) (*fileResources, error) {
+	ws, err := NewWorkspace(dir, ignore, true)
+	if err != nil {
+		return nil, err
+	}
+	return &fileResources{ws: ws}, nil
+}
+
+// WithResourceDir serves the files of r as resources, along with those of
+// the upstreams.
+func WithResourceDir(r *fileResources) Option {
+	return func(s *Server) {
+		s.files = r
+	}
+}
+
+// list returns the files as they are now, annotated with their
+// modification times. Files that cannot be read are left out.
+func (r *fileResources) list() []client.Resource {
+	resources := []client.Resource{}
+	r.ws.Walk(func(path, rel string, info fs.FileInfo) {
+		mimeType, err := fileMIMEType(path)
+		if err != nil {
+			return
+		}
+		resources = append(resources, client.Resource{
+			URI:         fileURI(path),
+			Name:        rel,
+			MimeType:    mimeType,
+			Annotations: &mcp.Annotations{LastModified: info.ModTime().UTC().Format(time.RFC3339)},
+		})
+	})
+	return resources
+}
+
+// path returns the file of the resource uri, and false if uri is not a
+// file under the directory.
+func (r *fileResources) path(uri string) (string, bool) {
+	path, ok := localFilePath(uri)
+	if !ok {
+		return "", false
+	}
+	rel, err := filepath.Rel(r.ws.Root, path)
+	if err != nil || !filepath.IsLocal(rel) || r.ws.Ignored(filepath.ToSlash(rel)) {
+		return "", false
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+	return rel, true
+}
+
+// read returns the contents of the file rel, as text if it is text and
+// as a base64 blob otherwise.
+func (r *fileResources) read(uri, rel string) ([]client.ResourceContents, error) {
+	if _, err := os.Lstat(filepath.Join(r.ws.Root, rel)); err != nil {
+		return nil, err
+	}
+	path, err := r.ws.Resolve(rel, "resources")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if fi, err := f.Stat(); err != nil {
+		return nil, err
+	} else if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", rel)
+	} else if fi.Size() > maxResourceFileSize {
+		return nil, fmt.Errorf("%s is larger than %d bytes", rel, maxResourceFileSize)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxResourceFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	return []client.ResourceContents{resourceContents(uri, data, detectMIMEType(path, data))}, nil
+}
+
+// localResource returns the file of the resource uri, and false if it is
+// not one of the local files.
+func (s *Server) localResource(uri string) (string, bool) {
+	if s.files == nil {
+		return "", false
+	}
+	return s.files.path(uri)
+}
+
+// resourcesListResult returns the encoded resources/list result. The
+// upstreams' resources are cached, but the local files are listed anew
+// each time, so that added and removed files show.
+func (s *Server) resourcesListResult() ([]byte, error) {
+	l, err := s.cachedListings()
+	if err != nil || s.files == nil && s.diagnostics == nil {
+		return l.resources, err
+	}
+	resources := s.listResources()
+	if s.files != nil {
+		resources = append(resources, s.files.list()...)
+	}
+	if s.diagnostics != nil {
+		resources = append(resources, diagnosticsResource())
+	}
+	return json.Marshal(map[string]interface{}{"resources": resources})
+}
+
+// sendFileResource writes the resources/read response for the local file
+// rel.
+func (s *Server) sendFileResource(w io.Writer, id interface{}, uri, rel string) {
+	contents, err := s.files.read(uri, rel)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		sendError(w, id, -32602, fmt.Sprintf("Unknown resource: %s", uri))
+	case err != nil:
+		sendError(w, id, -32603, fmt.Sprintf("Cannot read resource %s: %v", uri, err))
+	default:
+		sendResponse(w, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  map[string]interface{}{"contents": contents},
+		})
+	}
+}
+
+// fileURI returns the file:// URI of the absolute path.
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// fileMIMEType returns the MIME type of the file at path, from its
+// extension or else from its first bytes.
+func fileMIMEType(path string) (string, error) {
+	if t := extensionMIMEType(path); t != "" {
+		return t, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// detectMIMEType returns the MIME type of the file name with content
+// data, from its extension or else by sniffing data.
+func detectMIMEType(name string, data []byte) string {
+	if t := extensionMIMEType(name); t != "" {
+		return t
+	}
+	return http.DetectContentType(data)
+}
+
+// extensionMIMEType returns the MIME type of the file name's extension,
+// or "" if it is unknown.
+func extensionMIMEType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if t, ok := resourceTypes[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// isTextMIMEType reports whether content of the MIME type t is text.
+func isTextMIMEType(t string) bool {
+	media, _, err := mime.ParseMediaType(t)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(media, "text/") || textApplicationTypes[media] ||
+		strings.HasSuffix(media, "+json") || strings.HasSuffix(media, "+xml")
+}
+
+// resourceContents returns data as the contents of the resource uri: as
+// text when the MIME type is a text type and data is valid UTF-8, which
+// a misnamed binary file is not, and otherwise as a base64 blob.
+func resourceContents(uri string, data []byte, mimeType string) client.ResourceContents {
+	c := client.ResourceContents{URI: uri, MimeType: mimeType}
+	if isTextMIMEType(mimeType) && utf8.Valid(data) {
+		c.Text = string(data)
+	} else {
+		c.Blob = base64.StdEncoding.EncodeToString(data)
+	}
+	return c
+}
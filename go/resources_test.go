@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mcp-minimal-server-go/client"
+)
+
+// Test listing and reading text and binary files as resources
+func TestFileResources(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for name, content := range map[string][]byte{
+		"notes.md":       []byte("# Notes\n"),
+		"docs/guide.txt": []byte("Read me"),
+		"logo.png":       png,
+		"data":           {0x00, 0x01, 0x02, 0xff},
+		"latin1.txt":     {'c', 'a', 'f', 0xe9},
+		".env":           []byte("TOKEN=x"),
+		".git/config":    []byte("[core]"),
+	} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := newFileResources(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := pipeUpstream(t, NewServer(WithTools(), WithResourceDir(files)), upstreamConfig{Name: "files"})
+
+	types := map[string]string{}
+	for _, r := range u.resources {
+		types[r.Name] = r.MimeType
+		if r.Annotations == nil || r.Annotations.LastModified == "" {
+			t.Errorf("%s: expected its modification time, got %+v", r.Name, r.Annotations)
+		}
+	}
+	expected := map[string]string{
+		"data":           "application/octet-stream",
+		"docs/guide.txt": "text/plain; charset=utf-8",
+		"latin1.txt":     "text/plain; charset=utf-8",
+		"logo.png":       "image/png",
+		"notes.md":       "text/markdown",
+	}
+	if len(types) != len(expected) {
+		t.Errorf("expected resources %v, got %v", expected, types)
+	}
+	for name, mimeType := range expected {
+		if types[name] != mimeType {
+			t.Errorf("%s: expected MIME type %q, got %q", name, mimeType, types[name])
+		}
+	}
+
+	read := func(name string) client.ResourceContents {
+		t.Helper()
+		uri := fileURI(filepath.Join(dir, name))
+		contents, err := u.client.ReadResource(context.Background(), uri)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(contents) != 1 || contents[0].URI != uri {
+			t.Fatalf("%s: unexpected contents %+v", name, contents)
+		}
+		return contents[0]
+	}
+	if c := read("notes.md"); c.Text != "# Notes\n" || c.Blob != "" || c.MimeType != "text/markdown" {
+		t.Errorf("expected text contents, got %+v", c)
+	}
+	if c := read("logo.png"); c.Blob != base64.StdEncoding.EncodeToString(png) || c.Text != "" || c.MimeType != "image/png" {
+		t.Errorf("expected a PNG blob, got %+v", c)
+	}
+	// Text that is not UTF-8 cannot be sent as text.
+	if c := read("latin1.txt"); c.Blob != base64.StdEncoding.EncodeToString([]byte{'c', 'a', 'f', 0xe9}) {
+		t.Errorf("expected a blob, got %+v", c)
+	}
+
+	for _, uri := range []string{
+		fileURI(filepath.Join(dir, ".env")),
+		fileURI(filepath.Join(dir, ".git", "config")),
+		fileURI(filepath.Join(dir, "missing.txt")),
+		fileURI(filepath.Join(filepath.Dir(dir), "other.txt")),
+		"https://example.com/notes.md",
+	} {
+		_, err := u.client.ReadResource(context.Background(), uri)
+		var rpcErr *client.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != -32602 {
+			t.Errorf("%s: expected an unknown resource error, got %v", uri, err)
+		}
+	}
+
+	if _, err := newFileResources(filepath.Join(dir, "notes.md"), nil); err == nil {
+		t.Error("expected an error for a file instead of a directory")
+	}
+}
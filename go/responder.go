@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errUnencodable is returned by messageWriter.send for a message that
+// cannot be encoded as JSON.
+var errUnencodable = errors.New("cannot encode message")
+
+// Responder sends the JSON-RPC messages of a session to its client:
+// responses, notifications, and requests to the client. Implementations
+// must be safe for concurrent use and write each message whole, as one
+// frame, so that tool calls, progress notifications, and requests to the
+// client sent from different goroutines never interleave. Sessions write
+// through a *messageWriter; tests can record messages with a Responder of
+// their own.
+type Responder interface {
+	// Write writes p, which holds whole encoded messages, such as those
+	// of a proxied server.
+	io.Writer
+	// Send encodes v as JSON and writes it as one message.
+	Send(v interface{}) error
+	// Notify sends the notification method with params, which are left
+	// out if nil.
+	Notify(method string, params interface{}) error
+}
+
+// Send encodes v and writes it as one message.
+func (m *messageWriter) Send(v interface{}) error {
+	_, err := m.send(v)
+	return err
+}
+
+// Notify sends the notification method with params.
+func (m *messageWriter) Notify(method string, params interface{}) error {
+	return m.Send(notification(method, params))
+}
+
+// notification returns the notification method with params.
+func notification(method string, params interface{}) map[string]interface{} {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		msg["params"] = params
+	}
+	return msg
+}
+
+// responder returns w as a Responder, wrapping it in a messageWriter if it
+// is not one.
+func responder(w io.Writer) Responder {
+	if r, ok := w.(Responder); ok {
+		return r
+	}
+	return newMessageWriter(w)
+}
+
+// sendNotification writes the notification method with params to w.
+func sendNotification(w io.Writer, method string, params interface{}) {
+	if err := responder(w).Notify(method, params); errors.Is(err, errUnencodable) {
+		fmt.Fprintf(w, "Failed to marshal notification: %v\n", err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingResponder keeps the messages sent to it instead of encoding
+// them.
+type recordingResponder struct {
+	mu            sync.Mutex
+	sent          []interface{}
+	notifications []string
+}
+
+func (r *recordingResponder) Write(p []byte) (int, error) { return len(p), nil }
+func (r *recordingResponder) Send(v interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, v)
+	return nil
+}
+func (r *recordingResponder) Notify(method string, params interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, method)
+	return nil
+}
+
+// Test that messages go through the Responder they are sent to
+func TestResponder(t *testing.T) {
+	rec := &recordingResponder{}
+	sendResponse(rec, map[string]interface{}{"jsonrpc": "2.0", "id": 1, "result": map[string]interface{}{}})
+	sendError(rec, 2, -32601, "Method not found")
+	sendNotification(rec, "notifications/progress", map[string]interface{}{"progress": 1})
+	if len(rec.sent) != 2 || len(rec.notifications) != 1 || rec.notifications[0] != "notifications/progress" {
+		t.Errorf("expected two messages and a notification, got %+v, %v", rec.sent, rec.notifications)
+	}
+	if resp, ok := rec.sent[1].(JSONRPCErrorResponse); !ok || resp.Error.Code != -32601 {
+		t.Errorf("expected the error response, got %+v", rec.sent[1])
+	}
+}
+
+// Test that notifications are encoded as one message, without params when
+// there are none
+func TestMessageWriterNotify(t *testing.T) {
+	var buf bytes.Buffer
+	mw := newMessageWriter(&buf)
+	if err := mw.Notify("notifications/resources/list_changed", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Notify("notifications/resources/updated", map[string]interface{}{"uri": "file:///a"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"jsonrpc":"2.0","method":"notifications/resources/list_changed"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/resources/updated","params":{"uri":"file:///a"}}` + "\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	buf.Reset()
+	sendNotification(&buf, "notifications/progress", map[string]interface{}{"bad": make(chan int)})
+	if !strings.HasPrefix(buf.String(), "Failed to marshal notification") {
+		t.Errorf("expected the encoding failure to be reported, got %q", buf.String())
+	}
+	if _, ok := responder(io.Discard).(*messageWriter); !ok {
+		t.Error("expected a plain writer to get a messageWriter")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// restGateway serves the tools to clients that do not speak MCP, such as
+// scripts and webhooks: GET /tools lists them as tools/list does, and
+// POST /tools/NAME calls one with the JSON object in the body as its
+// arguments. Calls take the same path as tools/call, so the arguments are
+// validated against the tool's input schema and the hooks, limits, and
+// redaction apply. Each request is a session of its own, ending with the
+// request.
+type restGateway struct {
+	server   *Server
+	auth     *oauthVerifier
+	limiter  *tokenBucket    // the session rate limit, shared by every request
+	shutdown <-chan struct{} // closed when the server stops taking requests
+}
+
+// newRESTGateway returns the gateway to the tools of s, authorizing
+// requests with auth if it is not nil, until shutdown is closed.
+func newRESTGateway(s *Server, auth *oauthVerifier, shutdown <-chan struct{}) *restGateway {
+	return &restGateway{server: s, auth: auth, limiter: newTokenBucket(s.sessionRateLimit), shutdown: shutdown}
+}
+
+// register adds the gateway's routes to mux.
+func (g *restGateway) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /tools", g.list)
+	mux.HandleFunc("POST /tools/{name}", g.call)
+}
+
+// session starts the session of a request, returning it and the function
+// ending it, or writes why the request may not have one and returns nil.
+func (g *restGateway) session(w http.ResponseWriter, r *http.Request) (*session, func()) {
+	var access *toolFilter
+	if g.auth != nil {
+		claims, err := g.auth.authenticate(r)
+		if errors.Is(err, errKeysUnavailable) {
+			g.server.logger.Error("cannot verify access token", "error", err)
+			http.Error(w, "Authorization temporarily unavailable", http.StatusServiceUnavailable)
+			return nil, nil
+		}
+		if err != nil {
+			g.auth.challenge(w, r, err)
+			return nil, nil
+		}
+		access = g.auth.toolFilter(claims.Scopes)
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if d, ok := parseRequestTimeout(r.Header.Get(requestTimeoutHeader)); ok {
+		ctx, cancel = context.WithTimeout(r.Context(), d)
+	} else {
+		ctx, cancel = context.WithCancel(r.Context())
+	}
+	l := &lifecycle{state: operating}
+	state := newSession(newSessionID(), l, nil, nil)
+	sess := &session{
+		state:     state,
+		ctx:       contextWithSession(ctx, state),
+		cancel:    cancel,
+		shutdown:  g.shutdown,
+		limiter:   g.limiter,
+		access:    access,
+		lifecycle: l,
+	}
+	g.server.sessionStarted(sess.ctx, state)
+	return sess, func() {
+		cancel()
+		g.server.sessionEnded(state)
+	}
+}
+
+// list answers GET /tools with the tools the client may call.
+func (g *restGateway) list(w http.ResponseWriter, r *http.Request) {
+	sess, end := g.session(w, r)
+	if sess == nil {
+		return
+	}
+	defer end()
+	result, err := g.server.toolsListResult(sess)
+	if err != nil {
+		g.server.logger.Error("encoding list failed", "error", err)
+		writeGatewayError(w, &JSONRPCError{Code: -32603, Message: "Internal error: cannot encode the list"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(result)
+}
+
+// call answers POST /tools/{name} with the result of the call: with 200
+// OK, or 422 Unprocessable Entity if the tool reports an error. Errors
+// answering tools/call are sent as {"error": {"code", "message", "data"}}
+// with a status telling their kind.
+func (g *restGateway) call(w http.ResponseWriter, r *http.Request) {
+	s := g.server
+	sess, end := g.session(w, r)
+	if sess == nil {
+		return
+	}
+	defer end()
+
+	var body io.Reader = r.Body
+	if s.maxMessageSize > 0 {
+		body = http.MaxBytesReader(w, r.Body, int64(s.maxMessageSize))
+	}
+	data, err := io.ReadAll(body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		writeGatewayError(w, &JSONRPCError{Code: -32600, Message: "Invalid Request: message too large", Data: sizeLimitData{Limit: s.maxMessageSize}})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	args := map[string]interface{}{}
+	if data = bytes.TrimSpace(bytes.TrimPrefix(data, utf8BOM)); len(data) > 0 {
+		if err := json.Unmarshal(data, &args); err != nil || args == nil {
+			writeGatewayError(w, &JSONRPCError{Code: -32602, Message: "Invalid parameters: the body must be a JSON object of arguments"})
+			return
+		}
+	}
+	msg, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": r.PathValue("name"), "arguments": args},
+	})
+	if err != nil {
+		writeGatewayError(w, &JSONRPCError{Code: -32602, Message: "Invalid parameters: " + err.Error()})
+		return
+	}
+
+	var out bytes.Buffer
+	sess.w = s.sessionWriter(&out)
+	s.handleLine(sess, msg)
+	sess.inflight.Wait()
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *JSONRPCError   `json:"error"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		writeGatewayError(w, &JSONRPCError{Code: -32603, Message: "Internal error: no response to the call"})
+		return
+	}
+	if resp.Error != nil {
+		writeGatewayError(w, resp.Error)
+		return
+	}
+	var flagged struct {
+		IsError bool `json:"isError"`
+	}
+	_ = json.Unmarshal(resp.Result, &flagged)
+	w.Header().Set("Content-Type", "application/json")
+	if flagged.IsError {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	w.Write(resp.Result)
+}
+
+// writeGatewayError writes e with the HTTP status of its kind, and for a
+// rate limit a Retry-After header.
+func writeGatewayError(w http.ResponseWriter, e *JSONRPCError) {
+	status := http.StatusInternalServerError
+	switch e.Code {
+	case -32600:
+		status = http.StatusBadRequest
+		if _, ok := e.Data.(sizeLimitData); ok {
+			status = http.StatusRequestEntityTooLarge
+		}
+	case -32602:
+		status = http.StatusBadRequest
+	case -32601:
+		status = http.StatusNotFound
+	case codeRateLimited, codeBudgetExceeded:
+		status = http.StatusTooManyRequests
+		var limit rateLimitData
+		if encoded, err := json.Marshal(e.Data); err == nil && json.Unmarshal(encoded, &limit) == nil && limit.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limit.RetryAfter))))
+		}
+	case codeRequestTimeout:
+		status = http.StatusGatewayTimeout
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": e})
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test that tools are listed and called over the REST gateway, with the
+// errors of tools/call as HTTP statuses
+func TestRESTGateway(t *testing.T) {
+	s := NewServer(WithTools(&countTextTool{}, statsTool{}, missingTool{}), WithSessionRateLimit(RateLimit{Rate: 0.001, Burst: 5}))
+	mux := http.NewServeMux()
+	newRESTGateway(s, nil, nil).register(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/tools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(string(body), `{"tools":[{"annotations":{"title":"Count text"`) {
+		t.Errorf("unexpected list %d %s", resp.StatusCode, body)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		status     int
+		want       string
+	}{
+		{"/tools/stats", "", http.StatusOK, `{"content":[{"type":"text","text":"{\"count\":2}"}],"structuredContent":{"count":2}}`},
+		{"/tools/count_text", `{"text":"two words"}`, http.StatusOK, `"text":"characters: 9\nwords: 2`},
+		{"/tools/count_text", `{"text":3}`, http.StatusBadRequest, `"message":"Invalid parameter 'text': expected string, got number","data":{"errors":[`},
+		{"/tools/count_text", `["two words"]`, http.StatusBadRequest, "the body must be a JSON object of arguments"},
+		{"/tools/missing", "{}", http.StatusUnprocessableEntity, `{"content":[{"type":"text","text":"No such record"}],"isError":true}`},
+		{"/tools/qr_code", "{}", http.StatusNotFound, `{"error":{"code":-32601,"message":"Method not found: tool 'qr_code' is not available"}}`},
+		{"/tools/stats", "", http.StatusTooManyRequests, "Rate limit exceeded for this session"},
+	} {
+		resp, err := http.Post(ts.URL+tc.path, "application/json", strings.NewReader(tc.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tc.status || !strings.Contains(string(body), tc.want) {
+			t.Errorf("%s %s: expected %d %s, got %d %s", tc.path, tc.body, tc.status, tc.want, resp.StatusCode, body)
+		}
+		if tc.status == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+			t.Error("expected a Retry-After header")
+		}
+	}
+
+	if resp, err := http.Get(ts.URL + "/tools/stats"); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected calls to need POST, got %v, %v", resp, err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCacheSize is the number of results cached for a tool when its
+// cache policy does not say.
+const defaultCacheSize = 100
+
+// CachePolicy configures the result cache of a tool: successful results
+// are reused for TTL, and at most Size results are kept, evicting the least
+// recently used. A TTL of zero disables the cache.
+type CachePolicy struct {
+	TTL  time.Duration
+	Size int
+}
+
+// WithToolCache caches the results of the named tool, keyed by its
+// arguments. Results are kept apart per session, since a tool may answer
+// differently for the roots or access of another client. Only tools whose repeated calls with the same arguments may
+// return the same result, such as idempotent lookups, should be cached.
+func WithToolCache(name string, policy CachePolicy) Option {
+	return func(s *Server) {
+		if s.caches == nil {
+			s.caches = map[string]*resultCache{}
+		}
+		s.caches[name] = newResultCache(policy)
+	}
+}
+
+// resultCache is an LRU cache of tool results with expiry, safe for
+// concurrent use.
+type resultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element // values are *cacheEntry
+	lru     *list.List               // most recently used first
+	now     func() time.Time
+}
+
+// cacheEntry is one cached result.
+type cacheEntry struct {
+	key     string
+	result  ToolResult // the content and structured content
+	expires time.Time
+}
+
+// newResultCache returns an empty cache for policy, or nil if policy
+// disables caching. A nil *resultCache caches nothing.
+func newResultCache(policy CachePolicy) *resultCache {
+	if policy.TTL <= 0 {
+		return nil
+	}
+	size := policy.Size
+	if size < 1 {
+		size = defaultCacheSize
+	}
+	return &resultCache{ttl: policy.TTL, size: size, entries: map[string]*list.Element{}, lru: list.New(), now: time.Now}
+}
+
+// cacheKey returns the key of a call in session sessionID: the session
+// and the canonical form of args. encoding/json sorts map keys, so
+// arguments that differ only in key order share a key.
+func cacheKey(sessionID string, args map[string]interface{}) (string, bool) {
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return "", false
+	}
+	return sessionID + "\n" + string(encoded), true
+}
+
+// get returns the unexpired result cached under key.
+func (c *resultCache) get(key string) (ToolResult, bool) {
+	if c == nil {
+		return ToolResult{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return ToolResult{}, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return ToolResult{}, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.result, true
+}
+
+// put caches result under key, evicting the least recently used result if
+// the cache is full.
+func (c *resultCache) put(key string, result ToolResult) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.result, entry.expires = result, expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, result: result, expires: expires})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// parseCachePolicy parses a policy of the form "TTL" or "TTL:SIZE", where
+// TTL is a Go duration such as "30s".
+func parseCachePolicy(s string) (CachePolicy, error) {
+	ttlStr, sizeStr, hasSize := strings.Cut(s, ":")
+	ttl, err := time.ParseDuration(ttlStr)
+	if err != nil || ttl <= 0 {
+		return CachePolicy{}, fmt.Errorf("invalid TTL %q", ttlStr)
+	}
+	policy := CachePolicy{TTL: ttl, Size: defaultCacheSize}
+	if hasSize {
+		policy.Size, err = strconv.Atoi(sizeStr)
+		if err != nil || policy.Size < 1 {
+			return CachePolicy{}, fmt.Errorf("invalid size %q", sizeStr)
+		}
+	}
+	return policy, nil
+}
+
+// parseToolCaches parses a comma-separated list of "TOOL=TTL[:SIZE]"
+// entries.
+func parseToolCaches(s string) (map[string]CachePolicy, error) {
+	policies := map[string]CachePolicy{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid tool cache %q", entry)
+		}
+		policy, err := parseCachePolicy(spec)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", name, err)
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
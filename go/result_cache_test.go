@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// callInSession calls tool name in the session served from pw and out,
+// and returns the response, once it is written. Calls made one at a time
+// find the results of the previous ones cached.
+func callInSession(t *testing.T, pw io.Writer, out *syncBuffer, id, name, args string) string {
+	t.Helper()
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"`+name+`","arguments":`+args+`},"id":`+id+`}`)
+	return waitForOutput(t, out, regexp.MustCompile(`(?m)^.*"id":`+id+`,.*$`), 1)[0][0]
+}
+
+// Test expiry and least-recently-used eviction
+func TestResultCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := newResultCache(CachePolicy{TTL: time.Minute, Size: 2})
+	c.now = func() time.Time { return now }
+	content := func(s string) ToolResult { return ToolResult{Content: []ToolContent{{Type: "text", Text: s}}} }
+
+	c.put("a", content("A"))
+	c.put("b", content("B"))
+	if got, ok := c.get("a"); !ok || got.Content[0].Text != "A" {
+		t.Fatalf("expected a cached result for a, got %v, %v", got, ok)
+	}
+	c.put("c", content("C")) // evicts b, the least recently used
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, ok := c.get("c"); !ok {
+		t.Error("expected a cached result for c")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := c.get("a"); ok {
+		t.Error("expected a to have expired")
+	}
+	if len(c.entries) != 1 || c.lru.Len() != 1 {
+		t.Errorf("expected the expired entry to be removed, have %d", len(c.entries))
+	}
+
+	disabled := newResultCache(CachePolicy{})
+	disabled.put("a", content("A"))
+	if _, ok := disabled.get("a"); ok {
+		t.Error("expected a disabled cache to cache nothing")
+	}
+}
+
+// Test parsing of the tool cache flag
+func TestParseToolCaches(t *testing.T) {
+	policies, err := parseToolCaches("echo=30s, count_text=1m:5")
+	if err != nil {
+		t.Fatalf("parseToolCaches error: %v", err)
+	}
+	if policies["echo"] != (CachePolicy{TTL: 30 * time.Second, Size: defaultCacheSize}) {
+		t.Errorf("unexpected echo policy %+v", policies["echo"])
+	}
+	if policies["count_text"] != (CachePolicy{TTL: time.Minute, Size: 5}) {
+		t.Errorf("unexpected count_text policy %+v", policies["count_text"])
+	}
+	for _, bad := range []string{"echo", "=1s", "echo=x", "echo=0s", "echo=1s:0"} {
+		if _, err := parseToolCaches(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+// countingTool counts its executions and fails when asked to.
+type countingTool struct {
+	calls atomic.Int32
+}
+
+func (*countingTool) Name() string        { return "lookup" }
+func (*countingTool) Description() string { return "Counts its calls" }
+func (*countingTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (c *countingTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	n := c.calls.Add(1)
+	if args["fail"] == true {
+		return nil, errors.New("failed")
+	}
+	return []ToolContent{{Type: "text", Text: "call " + strconv.Itoa(int(n))}}, nil
+}
+
+// Test that repeated calls with equal arguments in a session are answered
+// from the cache, that failures are not cached, and that sessions do not
+// share results
+func TestToolCache(t *testing.T) {
+	tool := &countingTool{}
+	s := NewServer(WithTools(tool), WithToolCache("lookup", CachePolicy{TTL: time.Minute}))
+	pw, out, _ := serveInBackground(context.Background(), s)
+	defer pw.Close()
+	call := func(id, args string) string { return callInSession(t, pw, out, id, "lookup", args) }
+	first := call("1", `{"host":"example.com","type":"A"}`)
+	if !strings.Contains(first, "call 1") {
+		t.Fatalf("unexpected response %s", first)
+	}
+	if second := call("2", `{"type":"A","host":"example.com"}`); !strings.Contains(second, "call 1") {
+		t.Errorf("expected the cached result, got %s", second)
+	}
+	if other := call("3", `{"host":"example.org","type":"A"}`); !strings.Contains(other, "call 2") {
+		t.Errorf("expected different arguments to run the tool, got %s", other)
+	}
+	call("4", `{"fail":true}`)
+	call("5", `{"fail":true}`)
+	if got := tool.calls.Load(); got != 4 {
+		t.Errorf("expected 4 executions, got %d", got)
+	}
+
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"lookup","arguments":{"host":"example.com","type":"A"}},"id":6}`+"\n")
+	if !strings.Contains(lines[0], "call 5") {
+		t.Errorf("expected another session to run the tool, got %s", lines[0])
+	}
+}
+
+// structuredCountingTool counts its executions in structured content.
+type structuredCountingTool struct{ countingTool }
+
+func (*structuredCountingTool) Name() string { return "tally" }
+func (c *structuredCountingTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	n := c.calls.Add(1)
+	return &ToolResult{
+		Content:    []ToolContent{{Type: "text", Text: "call " + strconv.Itoa(int(n))}},
+		Structured: map[string]int32{"call": n},
+	}, nil
+}
+
+// Test that results with structured content are cached whole
+func TestToolCacheStructured(t *testing.T) {
+	tool := &structuredCountingTool{}
+	s := NewServer(WithTools(tool), WithToolCache("tally", CachePolicy{TTL: time.Minute}))
+	pw, out, _ := serveInBackground(context.Background(), s)
+	defer pw.Close()
+	for _, id := range []string{"1", "2"} {
+		if got := callInSession(t, pw, out, id, "tally", `{}`); !strings.Contains(got, `"structuredContent":{"call":1}`) || !strings.Contains(got, "call 1") {
+			t.Errorf("expected the first result with its structured content, got %s", got)
+		}
+	}
+	if got := tool.calls.Load(); got != 1 {
+		t.Errorf("expected 1 execution, got %d", got)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// resultMeta is the _meta of a tool result when the server reports what
+// calls cost, so that hosts and agents can weigh tools when planning.
+type resultMeta struct {
+	DurationMs float64 `json:"durationMs"` // zero for a cached result
+	BytesIn    int     `json:"bytesIn"`    // the encoded arguments
+	BytesOut   int     `json:"bytesOut"`   // the encoded content and structured content
+	CacheHit   bool    `json:"cacheHit"`
+}
+
+// WithResultMeta reports the duration, argument and result sizes, and
+// cache use of each tool call in the _meta of its result.
+func WithResultMeta() Option {
+	return func(s *Server) {
+		s.resultMeta = true
+	}
+}
+
+// callMeta returns the _meta of the result of a call with args, or nil if
+// the server does not report it.
+func (s *Server) callMeta(args map[string]interface{}, content []ToolContent, structured interface{}, elapsed time.Duration, cacheHit bool) *resultMeta {
+	if !s.resultMeta {
+		return nil
+	}
+	meta := &resultMeta{DurationMs: float64(elapsed.Microseconds()) / 1000, CacheHit: cacheHit}
+	if args != nil {
+		if encoded, err := json.Marshal(args); err == nil {
+			meta.BytesIn = len(encoded)
+		}
+	}
+	meta.BytesOut, _ = resultSize(content, structured)
+	return meta
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that results report what their calls cost, including cache hits
+func TestResultMeta(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewServer(WithResultMeta(), WithToolCache("echo", CachePolicy{TTL: time.Minute}))
+	pw, out, _ := serveInBackground(ctx, s)
+	defer pw.Close()
+
+	call := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":%d}`
+	fmt.Fprintf(pw, call+"\n", 1)
+	waitForOutput(t, out, regexp.MustCompile(`"id":1,`), 1)
+	fmt.Fprintf(pw, call+"\n", 2)
+	waitForOutput(t, out, regexp.MustCompile(`"id":2,`), 1)
+
+	for i, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var resp struct {
+			Result struct {
+				Meta resultMeta `json:"_meta"`
+			} `json:"result"`
+		}
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatal(err)
+		}
+		meta := resp.Result.Meta
+		// {"message":"hi"} in, [{"type":"text","text":"Echo: hi"}] out
+		if meta.BytesIn != 16 || meta.BytesOut != 35 || meta.CacheHit != (i == 1) || i == 1 && meta.DurationMs != 0 {
+			t.Errorf("call %d: unexpected meta %+v", i+1, meta)
+		}
+	}
+}
+
+// Test that results have no _meta unless asked for
+func TestResultMetaOff(t *testing.T) {
+	lines := runServerInput(t, NewServer(), `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":1}`+"\n")
+	if len(lines) != 1 || strings.Contains(lines[0], "_meta") {
+		t.Errorf("expected a result without _meta, got %q", lines)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WithSchemaDefs sets definitions shared by the input schemas of all tools.
+// A reference such as "#/$defs/Address" that a schema's own $defs (or
+// definitions) lack resolves to the shared definition of that name, so
+// that tools can share argument structures without repeating them.
+func WithSchemaDefs(defs map[string]interface{}) Option {
+	return func(s *Server) {
+		s.schemaDefs = defs
+	}
+}
+
+// inputSchema returns the input schema of t with its references resolved,
+// as both tools/list and argument validation use it. Schemas without
+// references are returned unchanged; a schema whose references do not
+// resolve is returned as it is, with a warning.
+func (s *Server) inputSchema(t MCPTool) map[string]interface{} {
+	schema := t.InputSchema()
+	if hasSchemaRef(schema) {
+		if resolved, err := resolveSchema(schema, s.schemaDefs); err != nil {
+			s.logger.Warn("Input schema not resolved", "tool", t.Name(), "error", err)
+		} else {
+			schema = resolved
+		}
+	}
+	if _, ok := dryRunSupport(t); ok {
+		schema = withDryRunProperty(schema)
+	}
+	return schema
+}
+
+// hasSchemaRef reports whether v, a schema or part of one, holds a $ref.
+func hasSchemaRef(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if _, ok := v["$ref"].(string); ok {
+			return true
+		}
+		for _, item := range v {
+			if hasSchemaRef(item) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if hasSchemaRef(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolveSchema returns a copy of schema with every local reference
+// replaced by the schema it points to, looking definitions up in shared
+// when schema lacks them, and without the then unused $defs and
+// definitions. Keywords beside a $ref override those of the referenced
+// schema. References nested deeper than maxRefDepth, as in recursive
+// schemas, become empty schemas, which accept anything.
+func resolveSchema(schema, shared map[string]interface{}) (map[string]interface{}, error) {
+	r := schemaResolver{root: schema, shared: shared}
+	resolved, err := r.value(schema, 0)
+	if err != nil {
+		return nil, err
+	}
+	out := resolved.(map[string]interface{})
+	delete(out, "$defs")
+	delete(out, "definitions")
+	return out, nil
+}
+
+// schemaResolver resolves the references of one schema.
+type schemaResolver struct {
+	root   map[string]interface{}
+	shared map[string]interface{}
+}
+
+// value returns a copy of v with its references resolved.
+func (r schemaResolver) value(v interface{}, depth int) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			if depth >= maxRefDepth {
+				return map[string]interface{}{}, nil
+			}
+			target, err := r.lookup(ref)
+			if err != nil {
+				return nil, err
+			}
+			resolved, err := r.value(target, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			merged, ok := resolved.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("reference %q does not point to a schema", ref)
+			}
+			for k, item := range v {
+				if k == "$ref" {
+					continue
+				}
+				if merged[k], err = r.value(item, depth); err != nil {
+					return nil, err
+				}
+			}
+			return merged, nil
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			var err error
+			if out[k], err = r.value(item, depth); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if out[i], err = r.value(item, depth); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// lookup returns the part of the root schema the local reference ref, a
+// JSON pointer such as "#/$defs/Address", points to, falling back to the
+// shared definitions for "#/$defs/NAME" and "#/definitions/NAME".
+func (r schemaResolver) lookup(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported reference %q: only local references are resolved", ref)
+	}
+	if ref == "#" {
+		return r.root, nil
+	}
+	pointer := strings.TrimPrefix(ref, "#")
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, tok := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+	}
+	if target, ok := walkPointer(r.root, tokens); ok {
+		return target, nil
+	}
+	if len(tokens) == 2 && (tokens[0] == "$defs" || tokens[0] == "definitions") {
+		if target, ok := r.shared[tokens[1]]; ok {
+			return target, nil
+		}
+	}
+	return nil, fmt.Errorf("unresolved reference %q", ref)
+}
+
+// walkPointer follows the unescaped tokens of a JSON pointer from v.
+func walkPointer(v interface{}, tokens []string) (interface{}, bool) {
+	for _, tok := range tokens {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			next, ok := node[tok]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeSchema decodes a schema written as JSON.
+func decodeSchema(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &schema); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// Test resolving local, shared, overridden, and recursive references
+func TestResolveSchema(t *testing.T) {
+	shared := decodeSchema(t, `{"Money":{"type":"number","minimum":0}}`)
+	schema := decodeSchema(t, `{
+		"$ref": "#/$defs/Order",
+		"$defs": {
+			"Address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]},
+			"Order": {"type": "object", "properties": {
+				"ship": {"$ref": "#/$defs/Address", "description": "Where to ship"},
+				"bill": {"$ref": "#/definitions/Address"},
+				"price": {"$ref": "#/$defs/Money"},
+				"tags": {"type": "array", "items": {"$ref": "#/properties~1x/0"}}
+			}, "required": ["ship"]}
+		},
+		"definitions": {"Address": {"type": "string"}},
+		"properties/x": [{"type": "string"}]
+	}`)
+	resolved, err := resolveSchema(schema, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := decodeSchema(t, `{"type": "object", "properties": {
+		"ship": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"], "description": "Where to ship"},
+		"bill": {"type": "string"},
+		"price": {"type": "number", "minimum": 0},
+		"tags": {"type": "array", "items": {"type": "string"}}
+	}, "required": ["ship"], "properties/x": [{"type": "string"}]}`)
+	if !reflect.DeepEqual(resolved, want) {
+		got, _ := json.Marshal(resolved)
+		t.Errorf("unexpected resolved schema %s", got)
+	}
+	if _, ok := schema["$defs"]; !ok || schema["$ref"] == nil {
+		t.Error("expected the original schema to be left unchanged")
+	}
+
+	tree := decodeSchema(t, `{"type":"object","properties":{"children":{"type":"array","items":{"$ref":"#"}}}}`)
+	if _, err := resolveSchema(tree, nil); err != nil {
+		t.Errorf("expected a recursive schema to resolve, got %v", err)
+	}
+
+	for _, ref := range []string{"#/$defs/Missing", "other.json#/$defs/A", "#definitions"} {
+		if _, err := resolveSchema(map[string]interface{}{"$ref": ref}, shared); err == nil || !strings.Contains(err.Error(), ref) {
+			t.Errorf("expected an error for %q, got %v", ref, err)
+		}
+	}
+}
+
+// Test that tools/list serves resolved schemas and that required arguments
+// behind a reference are validated
+func TestSchemaRefsServed(t *testing.T) {
+	tool := &mockTool{cfg: mockToolConfig{
+		Name:         "ship",
+		InputSchema:  decodeSchema(t, `{"$ref":"#/$defs/Shipment"}`),
+		mockResponse: mockResponse{Response: "shipped to {{address.city}}"},
+	}}
+	s := NewServer(WithTools(tool), WithSchemaDefs(decodeSchema(t, `{
+		"Shipment": {"type":"object","properties":{"address":{"$ref":"#/$defs/Address"}},"required":["address"]},
+		"Address": {"type":"object","properties":{"city":{"type":"string"}}}
+	}`)))
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/list","id":1}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"ship","arguments":{}},"id":2}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"ship","arguments":{"address":{"city":"Kyoto"}}},"id":3}
+`)
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 responses, got %q", lines)
+	}
+	if strings.Contains(lines[0], "$ref") || !strings.Contains(lines[0], `"address":{"properties":{"city":{"type":"string"}},"type":"object"}`) {
+		t.Errorf("expected the resolved schema to be listed, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], "Missing required parameter: 'address'") {
+		t.Errorf("expected the referenced required argument to be enforced, got %s", lines[1])
+	}
+	if !strings.Contains(lines[2], "shipped to Kyoto") {
+		t.Errorf("expected the call to succeed, got %s", lines[2])
+	}
+}
+
+// Test that shared definitions come from the config file and that
+// unresolved references fail at startup
+func TestLoadConfigSchemaDefs(t *testing.T) {
+	dir := t.TempDir()
+	tools := filepath.Join(dir, "tools.json")
+	data := `{"tools":[{"name":"ship","inputSchema":{"$ref":"#/$defs/Shipment"},"response":"ok"}]}`
+	if err := os.WriteFile(tools, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for defs, ok := range map[string]bool{
+		`{"Shipment":{"type":"object"}}`: true,
+		`{"Address":{"type":"object"}}`:  false,
+	} {
+		path := filepath.Join(dir, "config.json")
+		data := `{"mockTools":` + jsonString(tools) + `,"schemaDefs":` + defs + `}`
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := loadConfig([]string{"--config", path}, io.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = cfg.serverOptions(slog.Default(), nil)
+		if (err == nil) != ok {
+			t.Errorf("serverOptions with definitions %s: %v", defs, err)
+		}
+	}
+}
+
+// jsonString returns s as a JSON string.
+func jsonString(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
@@ -0,0 +1,713 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// searchConfig configures the index_documents and semantic_search tools in
+// the "search" section of the config file.
+type searchConfig struct {
+	Dir          string            `json:"dir"`          // documents to index, or the client's first root with workspaceRoots
+	IndexDir     string            `json:"indexDir"`     // defaults to a directory in the user cache directory
+	Embeddings   *embeddingsConfig `json:"embeddings"`   // an embeddings API; words are hashed locally without one
+	ChunkSize    int               `json:"chunkSize"`    // in bytes, defaults to 1000
+	ChunkOverlap int               `json:"chunkOverlap"` // in bytes, defaults to 200
+	MaxFileSize  int64             `json:"maxFileSize"`  // of a document, defaults to 1 MiB
+}
+
+// embeddingsConfig is an OpenAI-compatible embeddings endpoint, such as
+// https://api.openai.com/v1/embeddings or Ollama's /v1/embeddings.
+type embeddingsConfig struct {
+	URL       string `json:"url"`
+	Model     string `json:"model"`
+	APIKey    string `json:"apiKey"`    // $VAR expands from the environment
+	BatchSize int    `json:"batchSize"` // texts a request, defaults to 64
+}
+
+// Defaults and limits of the search tools.
+const (
+	defaultChunkSize       = 1000
+	defaultChunkOverlap    = 200
+	defaultSearchFileSize  = 1 << 20
+	defaultEmbeddingBatch  = 64
+	defaultSearchResults   = 5
+	maxSearchResults       = 20
+	hashEmbeddingDims      = 512
+	maxEmbeddingsResponse  = 64 << 20
+	searchIndexVersion     = 1
+	indexDocumentsDeadline = 10 * time.Minute
+)
+
+// validate reports malformed fields.
+func (c *searchConfig) validate() error {
+	if c.ChunkSize < 0 || c.ChunkOverlap < 0 || c.MaxFileSize < 0 {
+		return errors.New("search: chunkSize, chunkOverlap, and maxFileSize must not be negative")
+	}
+	size, overlap := c.ChunkSize, c.ChunkOverlap
+	if size == 0 {
+		size = defaultChunkSize
+	}
+	if overlap == 0 {
+		overlap = defaultChunkOverlap
+	}
+	if overlap >= size {
+		return fmt.Errorf("search: chunkOverlap %d must be less than chunkSize %d", overlap, size)
+	}
+	if e := c.Embeddings; e != nil {
+		u, err := url.Parse(os.ExpandEnv(e.URL))
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("search: invalid embeddings url %q", e.URL)
+		}
+		if e.Model == "" {
+			return errors.New("search: the embeddings model is required")
+		}
+		if e.BatchSize < 0 {
+			return errors.New("search: the embeddings batchSize must not be negative")
+		}
+	}
+	return nil
+}
+
+// embedder turns texts into vectors of unit length, so that their dot
+// product is their cosine similarity.
+type embedder interface {
+	// name identifies the vectors, and an index is rebuilt when it changes.
+	name() string
+	embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// hashEmbedder embeds texts without a model by hashing their words and
+// pairs of words into the dimensions of the vector. Texts sharing words
+// come out similar, so it finds text by its wording rather than its
+// meaning.
+type hashEmbedder struct{}
+
+func (hashEmbedder) name() string {
+	return fmt.Sprintf("hash-%d", hashEmbeddingDims)
+}
+
+func (hashEmbedder) embed(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, hashEmbeddingDims)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		add := func(feature string) {
+			h := fnv.New64a()
+			h.Write([]byte(feature))
+			sum := h.Sum64()
+			if sum>>63 == 1 {
+				v[sum%hashEmbeddingDims]--
+			} else {
+				v[sum%hashEmbeddingDims]++
+			}
+		}
+		for j, w := range words {
+			add(w)
+			if j > 0 {
+				add(words[j-1] + " " + w)
+			}
+		}
+		vectors[i] = normalize(v)
+	}
+	return vectors, nil
+}
+
+// normalize scales v to unit length.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= scale
+	}
+	return v
+}
+
+// apiEmbedder embeds texts through an OpenAI-compatible embeddings
+// endpoint.
+type apiEmbedder struct {
+	cfg    embeddingsConfig
+	client *http.Client
+}
+
+func (e *apiEmbedder) name() string {
+	return "api:" + e.cfg.Model
+}
+
+func (e *apiEmbedder) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	batch := e.cfg.BatchSize
+	if batch == 0 {
+		batch = defaultEmbeddingBatch
+	}
+	var vectors [][]float32
+	for start := 0; start < len(texts); start += batch {
+		part, err := e.request(ctx, texts[start:min(start+batch, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, part...)
+	}
+	return vectors, nil
+}
+
+// request embeds texts in one request.
+func (e *apiEmbedder) request(ctx context.Context, texts []string) ([][]float32, error) {
+	body, _ := json.Marshal(map[string]interface{}{"model": e.cfg.Model, "input": texts})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, os.ExpandEnv(e.cfg.URL), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key := os.ExpandEnv(e.cfg.APIKey); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("embeddings: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEmbeddingsResponse))
+	if err != nil {
+		return nil, fmt.Errorf("embeddings: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &failure) == nil && failure.Error.Message != "" {
+			return nil, fmt.Errorf("embeddings: %s", failure.Error.Message)
+		}
+		return nil, fmt.Errorf("embeddings: %s", resp.Status)
+	}
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("embeddings: malformed response: %v", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(texts) || len(d.Embedding) == 0 {
+			return nil, errors.New("embeddings: malformed response")
+		}
+		vectors[d.Index] = normalize(d.Embedding)
+	}
+	for _, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("embeddings: %d vectors for %d texts", len(result.Data), len(texts))
+		}
+	}
+	return vectors, nil
+}
+
+// textSpan is a part of a text, by byte offsets.
+type textSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// chunkText splits text into spans of at most size bytes, each starting
+// overlap bytes before the end of the previous one. Spans end at a
+// paragraph, line, sentence, or word break in their last half where there
+// is one, and never inside a UTF-8 sequence.
+func chunkText(text string, size, overlap int) []textSpan {
+	var spans []textSpan
+	for start := 0; start < len(text); {
+		end := start + size
+		if end >= len(text) {
+			spans = append(spans, textSpan{start, len(text)})
+			break
+		}
+		window := text[start:end]
+		cut := -1
+		for _, sep := range []string{"\n\n", "\n", ". ", " "} {
+			if i := strings.LastIndex(window, sep); i >= size/2 {
+				cut = start + i + len(sep)
+				break
+			}
+		}
+		if cut < 0 {
+			cut = end
+			for cut > start && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		spans = append(spans, textSpan{start, cut})
+		next := cut - overlap
+		for next > start && next < len(text) && !utf8.RuneStart(text[next]) {
+			next--
+		}
+		if next <= start {
+			next = cut
+		}
+		start = next
+	}
+	return spans
+}
+
+// searchIndex is the on-disk index of the documents of a directory.
+type searchIndex struct {
+	Version      int                     `json:"version"`
+	Root         string                  `json:"root"`
+	Embedder     string                  `json:"embedder"`
+	ChunkSize    int                     `json:"chunkSize"`
+	ChunkOverlap int                     `json:"chunkOverlap"`
+	Files        map[string]*indexedFile `json:"files"` // by slash-separated path relative to the root
+}
+
+// indexedFile is a document in the index.
+type indexedFile struct {
+	Size    int64          `json:"size"`
+	ModTime time.Time      `json:"modTime"`
+	Chunks  []indexedChunk `json:"chunks"`
+}
+
+// indexedChunk is a chunk of a document with its vector, stored as base64
+// of its little-endian float32s.
+type indexedChunk struct {
+	textSpan
+	Text   string `json:"text"`
+	Vector string `json:"vector"`
+	vec    []float32
+}
+
+// encodeVector returns v as base64 of its little-endian float32s.
+func encodeVector(v []float32) string {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// decodeVector reverses encodeVector.
+func decodeVector(s string) ([]float32, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(buf)%4 != 0 {
+		return nil, errors.New("malformed vector")
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v, nil
+}
+
+// documentSearch is the state the search tools share: the configuration,
+// the embedder, and the indexes loaded, by root.
+type documentSearch struct {
+	cfg      searchConfig
+	ws       *Workspace
+	embedder embedder
+	indexing sync.Mutex // held by index_documents, which may embed for long
+	mu       sync.Mutex // guards indexes and the index files
+	indexes  map[string]*searchIndex
+}
+
+// searchTools returns the index_documents and semantic_search tools for
+// the documents of ws.
+func searchTools(cfg searchConfig, ws *Workspace) ([]MCPTool, error) {
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = defaultChunkSize
+	}
+	if cfg.ChunkOverlap == 0 {
+		cfg.ChunkOverlap = defaultChunkOverlap
+	}
+	if cfg.MaxFileSize == 0 {
+		cfg.MaxFileSize = defaultSearchFileSize
+	}
+	if cfg.IndexDir == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("search: no index directory: %v", err)
+		}
+		cfg.IndexDir = filepath.Join(cache, "mcp-minimal-server", "search")
+	}
+	s := &documentSearch{cfg: cfg, ws: ws, embedder: hashEmbedder{}, indexes: map[string]*searchIndex{}}
+	if cfg.Embeddings != nil {
+		s.embedder = &apiEmbedder{cfg: *cfg.Embeddings, client: &http.Client{}}
+	}
+	return []MCPTool{&indexDocumentsTool{s}, &semanticSearchTool{s}}, nil
+}
+
+// indexPath returns the file of the index of root.
+func (s *documentSearch) indexPath(root string) string {
+	sum := sha256.Sum256([]byte(root))
+	return filepath.Join(s.cfg.IndexDir, hex.EncodeToString(sum[:8])+".json")
+}
+
+// load returns the index of root from memory or its file, or nil if
+// there is none. The caller holds s.mu.
+func (s *documentSearch) load(root string) (*searchIndex, error) {
+	if idx, ok := s.indexes[root]; ok {
+		return idx, nil
+	}
+	data, err := os.ReadFile(s.indexPath(root))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var idx searchIndex
+	if err := json.Unmarshal(data, &idx); err != nil || idx.Version != searchIndexVersion || idx.Root != root {
+		// An index of another version or directory is rebuilt.
+		return nil, nil
+	}
+	for _, f := range idx.Files {
+		for i := range f.Chunks {
+			if f.Chunks[i].vec, err = decodeVector(f.Chunks[i].Vector); err != nil {
+				return nil, nil
+			}
+		}
+	}
+	s.indexes[root] = &idx
+	return &idx, nil
+}
+
+// save writes idx to its file, replacing the file only once it is
+// complete. The caller holds s.mu.
+func (s *documentSearch) save(idx *searchIndex) error {
+	if err := os.MkdirAll(s.cfg.IndexDir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	path := s.indexPath(idx.Root)
+	tmp, err := os.CreateTemp(s.cfg.IndexDir, ".index-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	s.indexes[idx.Root] = idx
+	return nil
+}
+
+// indexDocumentsArgs are the arguments of the index_documents tool.
+type indexDocumentsArgs struct {
+	Rebuild bool `json:"rebuild,omitempty" description:"Index every document again, not only the new and changed ones"`
+}
+
+// indexDocumentsTool brings the index of the documents up to date.
+type indexDocumentsTool struct {
+	s *documentSearch
+}
+
+// Name returns the name of the index_documents tool.
+func (t *indexDocumentsTool) Name() string {
+	return "index_documents"
+}
+
+// Description returns a brief description of the index_documents tool.
+func (t *indexDocumentsTool) Description() string {
+	return "Indexes the text documents of the document directory for semantic_search, embedding the new and changed ones"
+}
+
+// InputSchema returns the JSON schema for the index_documents tool's input
+// parameters.
+func (t *indexDocumentsTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(indexDocumentsArgs{})
+}
+
+// Annotations marks the index_documents tool as idempotent. It only
+// writes its own index, so it is not destructive.
+func (t *indexDocumentsTool) Annotations() ToolAnnotations {
+	destructive := false
+	return ToolAnnotations{Title: "Index documents", IdempotentHint: true, DestructiveHint: &destructive}
+}
+
+// Timeout leaves time to embed a large directory.
+func (t *indexDocumentsTool) Timeout() time.Duration {
+	return indexDocumentsDeadline
+}
+
+// Execute indexes the documents without a deadline of its own.
+func (t *indexDocumentsTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext indexes the documents and returns counts as text.
+func (t *indexDocumentsTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// indexDocumentsResult counts the documents of an index_documents call.
+type indexDocumentsResult struct {
+	Indexed   int `json:"indexed"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	Skipped   int `json:"skipped"` // too large or not text
+	Chunks    int `json:"chunks"`  // in the whole index
+}
+
+// ExecuteResult indexes the new and changed documents, drops the removed
+// ones, and saves the index.
+func (t *indexDocumentsTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a indexDocumentsArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	ws, err := t.s.ws.For(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no document directory: %v", err)
+	}
+	s := t.s
+	s.indexing.Lock()
+	defer s.indexing.Unlock()
+	s.mu.Lock()
+	old, err := s.load(ws.Root)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, toolFailure("Failed to read the index: %v", err)
+	}
+	if a.Rebuild || old == nil || old.Embedder != s.embedder.name() || old.ChunkSize != s.cfg.ChunkSize || old.ChunkOverlap != s.cfg.ChunkOverlap {
+		old = &searchIndex{Files: map[string]*indexedFile{}}
+	}
+	idx := &searchIndex{Version: searchIndexVersion, Root: ws.Root, Embedder: s.embedder.name(), ChunkSize: s.cfg.ChunkSize, ChunkOverlap: s.cfg.ChunkOverlap, Files: map[string]*indexedFile{}}
+
+	var result indexDocumentsResult
+	var pending []*indexedChunk
+	ws.Walk(func(path, rel string, info fs.FileInfo) {
+		if f, ok := old.Files[rel]; ok && f.Size == info.Size() && f.ModTime.Equal(info.ModTime()) {
+			idx.Files[rel] = f
+			result.Unchanged++
+			return
+		}
+		if info.Size() > s.cfg.MaxFileSize {
+			result.Skipped++
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			result.Skipped++
+			return
+		}
+		text := string(data)
+		f := &indexedFile{Size: info.Size(), ModTime: info.ModTime()}
+		for _, span := range chunkText(text, s.cfg.ChunkSize, s.cfg.ChunkOverlap) {
+			if strings.TrimSpace(text[span.Start:span.End]) != "" {
+				f.Chunks = append(f.Chunks, indexedChunk{textSpan: span, Text: text[span.Start:span.End]})
+			}
+		}
+		for i := range f.Chunks {
+			pending = append(pending, &f.Chunks[i])
+		}
+		idx.Files[rel] = f
+		result.Indexed++
+	})
+	for rel := range old.Files {
+		if _, ok := idx.Files[rel]; !ok {
+			result.Removed++
+		}
+	}
+
+	texts := make([]string, len(pending))
+	for i, c := range pending {
+		texts[i] = c.Text
+	}
+	vectors, err := s.embedder.embed(ctx, texts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("Failed to embed the documents: %v", err)
+	}
+	for i, c := range pending {
+		c.vec, c.Vector = vectors[i], encodeVector(vectors[i])
+	}
+	for _, f := range idx.Files {
+		result.Chunks += len(f.Chunks)
+	}
+	s.mu.Lock()
+	err = s.save(idx)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, toolFailure("Failed to save the index: %v", err)
+	}
+	return mcp.NewResult().WithStructured(result), nil
+}
+
+// semanticSearchArgs are the arguments of the semantic_search tool.
+type semanticSearchArgs struct {
+	Query  string `json:"query" description:"What to look for, in natural language"`
+	Limit  int    `json:"limit,omitempty" minimum:"1" maximum:"20" description:"Most results to return (default 5)"`
+	Prefix string `json:"path_prefix,omitempty" description:"Only search documents whose relative path starts with this, such as docs/"`
+}
+
+// semanticSearchTool finds the chunks of the indexed documents most
+// similar to a query.
+type semanticSearchTool struct {
+	s *documentSearch
+}
+
+// Name returns the name of the semantic_search tool.
+func (t *semanticSearchTool) Name() string {
+	return "semantic_search"
+}
+
+// Description returns a brief description of the semantic_search tool.
+func (t *semanticSearchTool) Description() string {
+	d := "Finds the passages of the documents indexed by index_documents most similar to a query"
+	if _, ok := t.s.embedder.(hashEmbedder); ok {
+		d += ". Without an embeddings model, similarity is by shared words"
+	}
+	return d
+}
+
+// InputSchema returns the JSON schema for the semantic_search tool's input
+// parameters.
+func (t *semanticSearchTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(semanticSearchArgs{})
+}
+
+// Annotations marks the semantic_search tool as read-only.
+func (t *semanticSearchTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Semantic search")
+}
+
+// Execute searches without a deadline of its own.
+func (t *semanticSearchTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext searches and returns the results as text.
+func (t *semanticSearchTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// searchHit is a passage semantic_search found.
+type searchHit struct {
+	Path  string  `json:"path"`
+	Start int     `json:"start"`
+	End   int     `json:"end"`
+	Score float64 `json:"score"`
+	Text  string  `json:"text"`
+}
+
+// ExecuteResult embeds the query and returns the most similar chunks,
+// best first.
+func (t *semanticSearchTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a semanticSearchArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(a.Query) == "" {
+		return nil, errors.New("'query' must not be empty")
+	}
+	if a.Limit < 0 || a.Limit > maxSearchResults {
+		return nil, fmt.Errorf("invalid value for 'limit': expected 1 to %d", maxSearchResults)
+	}
+	if a.Limit == 0 {
+		a.Limit = defaultSearchResults
+	}
+	ws, err := t.s.ws.For(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no document directory: %v", err)
+	}
+	t.s.mu.Lock()
+	idx, err := t.s.load(ws.Root)
+	t.s.mu.Unlock()
+	if err != nil {
+		return nil, toolFailure("Failed to read the index: %v", err)
+	}
+	if idx == nil || idx.Embedder != t.s.embedder.name() {
+		return nil, toolFailure("The documents are not indexed; call index_documents first")
+	}
+	vectors, err := t.s.embedder.embed(ctx, []string{a.Query})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("Failed to embed the query: %v", err)
+	}
+	query := vectors[0]
+	hits := []searchHit{}
+	for rel, f := range idx.Files {
+		if !strings.HasPrefix(rel, a.Prefix) {
+			continue
+		}
+		for _, c := range f.Chunks {
+			if len(c.vec) != len(query) {
+				continue
+			}
+			var score float64
+			for i, x := range c.vec {
+				score += float64(x) * float64(query[i])
+			}
+			hits = append(hits, searchHit{Path: rel, Start: c.Start, End: c.End, Score: math.Round(score*1e4) / 1e4, Text: c.Text})
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		if hits[i].Path != hits[j].Path {
+			return hits[i].Path < hits[j].Path
+		}
+		return hits[i].Start < hits[j].Start
+	})
+	if len(hits) > a.Limit {
+		hits = hits[:a.Limit]
+	}
+	return mcp.NewResult().WithStructured(map[string]interface{}{"results": hits}), nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Test that chunks stay within the size, overlap, end at breaks, and keep
+// UTF-8 sequences whole
+func TestChunkText(t *testing.T) {
+	text := "First paragraph here.\n\nSecond paragraph, which is a bit longer. It has two sentences.\n\nThird."
+	spans := chunkText(text, 40, 10)
+	if spans[0] != (textSpan{0, 23}) {
+		t.Errorf("expected the first chunk to end after the paragraph, got %v", spans[0])
+	}
+	for i, s := range spans {
+		if s.End-s.Start > 40 {
+			t.Errorf("chunk %d is %d bytes", i, s.End-s.Start)
+		}
+		if i > 0 && (s.Start >= spans[i-1].End || s.Start <= spans[i-1].Start) {
+			t.Errorf("chunk %d %v does not overlap %v", i, s, spans[i-1])
+		}
+	}
+	if last := spans[len(spans)-1]; last.End != len(text) {
+		t.Errorf("expected the chunks to reach the end, got %v", last)
+	}
+
+	text = strings.Repeat("日本語", 20)
+	for _, s := range chunkText(text, 16, 5) {
+		if !utf8.ValidString(text[s.Start:s.End]) {
+			t.Errorf("chunk %v cuts a character", s)
+		}
+	}
+	if spans := chunkText("", 10, 2); len(spans) != 0 {
+		t.Errorf("expected no chunks of empty text, got %v", spans)
+	}
+}
+
+// searchResults calls semantic_search and returns the paths it found.
+func searchResults(t *testing.T, tool MCPTool, args map[string]interface{}) []string {
+	t.Helper()
+	result, err := tool.(*semanticSearchTool).ExecuteResult(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, h := range result.Structured.(map[string]interface{})["results"].([]searchHit) {
+		paths = append(paths, h.Path)
+	}
+	return paths
+}
+
+// Test that documents are indexed incrementally and searched by
+// similarity, also after the index is read back from disk
+func TestSemanticSearch(t *testing.T) {
+	docs, indexDir := t.TempDir(), t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(docs, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("ops/deploy.md", "# Rolling back\n\nTo roll back a deployment, run kubectl rollout undo deployment/web.")
+	write("recipes/pasta.txt", "Boil the pasta in salted water for nine minutes, then add the tomato sauce.")
+	write("ops/notes.txt", "The deployment of the web service happens every Tuesday.")
+	write("logo.png", "\x89PNG\x00\x00")
+	write(".secrets", "password=hunter2")
+
+	cfg := searchConfig{IndexDir: indexDir, ChunkSize: 200, ChunkOverlap: 20}
+	ws := &Workspace{Root: docs}
+	tools, err := searchTools(cfg, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, search := tools[0].(*indexDocumentsTool), tools[1]
+	if _, err := search.Execute(map[string]interface{}{"query": "rollback"}); err == nil || !strings.Contains(err.Error(), "call index_documents first") {
+		t.Errorf("expected searching without an index to fail, got %v", err)
+	}
+
+	counts := func(args map[string]interface{}) string {
+		t.Helper()
+		result, err := index.ExecuteResult(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := json.Marshal(result.Structured)
+		return string(encoded)
+	}
+	if got := counts(nil); got != `{"indexed":3,"unchanged":0,"removed":0,"skipped":1,"chunks":3}` {
+		t.Errorf("unexpected counts %s", got)
+	}
+	if got := searchResults(t, search, map[string]interface{}{"query": "how do I roll back the web deployment?", "limit": 2}); strings.Join(got, ",") != "ops/deploy.md,ops/notes.txt" {
+		t.Errorf("unexpected results %v", got)
+	}
+	if got := searchResults(t, search, map[string]interface{}{"query": "pasta sauce", "path_prefix": "ops/"}); len(got) != 2 || got[0] == "recipes/pasta.txt" {
+		t.Errorf("expected only ops/ documents, got %v", got)
+	}
+
+	if got := counts(nil); got != `{"indexed":0,"unchanged":3,"removed":0,"skipped":1,"chunks":3}` {
+		t.Errorf("expected nothing to be indexed again, got %s", got)
+	}
+	os.Remove(filepath.Join(docs, "recipes/pasta.txt"))
+	write("ops/notes.txt", "Deployments are frozen in December.")
+	os.Chtimes(filepath.Join(docs, "ops/notes.txt"), time.Now(), time.Now().Add(time.Hour))
+	if got := counts(nil); got != `{"indexed":1,"unchanged":1,"removed":1,"skipped":1,"chunks":2}` {
+		t.Errorf("expected the changes to be indexed, got %s", got)
+	}
+	if got := counts(map[string]interface{}{"rebuild": true}); got != `{"indexed":2,"unchanged":0,"removed":0,"skipped":1,"chunks":2}` {
+		t.Errorf("expected everything to be indexed again, got %s", got)
+	}
+
+	// A new server reads the index from disk.
+	tools, err = searchTools(cfg, ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := searchResults(t, tools[1], map[string]interface{}{"query": "frozen in december"}); len(got) == 0 || got[0] != "ops/notes.txt" {
+		t.Errorf("unexpected results from the saved index %v", got)
+	}
+	files, _ := os.ReadDir(indexDir)
+	if len(files) != 1 {
+		t.Errorf("expected one index file, got %d", len(files))
+	}
+}
+
+// Test that an embeddings API is called in batches with the key, and that
+// its vectors are put back in the order of the texts
+func TestAPIEmbedder(t *testing.T) {
+	var requests []int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":{"message":"Incorrect API key provided"}}`)
+			return
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, len(req.Input))
+		var data []string
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, fmt.Sprintf(`{"index":%d,"embedding":[%d,0]}`, i, len(req.Input[i])))
+		}
+		fmt.Fprintf(w, `{"object":"list","data":[%s],"model":%q}`, strings.Join(data, ","), req.Model)
+	}))
+	defer ts.Close()
+	t.Setenv("TEST_EMBEDDINGS_KEY", "sk-test")
+	e := &apiEmbedder{cfg: embeddingsConfig{URL: ts.URL, Model: "text-embedding-3-small", APIKey: "$TEST_EMBEDDINGS_KEY", BatchSize: 2}, client: ts.Client()}
+	vectors, err := e.embed(context.Background(), []string{"a", "bb", "ccc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 3 || len(requests) != 2 || requests[0] != 2 || requests[1] != 1 {
+		t.Errorf("expected three vectors from two requests, got %v from %v", vectors, requests)
+	}
+	for i, v := range vectors {
+		if v[0] != 1 || v[1] != 0 {
+			t.Errorf("vector %d is not normalized in order: %v", i, v)
+		}
+	}
+	if e.name() != "api:text-embedding-3-small" {
+		t.Errorf("unexpected name %q", e.name())
+	}
+
+	e.cfg.APIKey = "wrong"
+	if _, err := e.embed(context.Background(), []string{"a"}); err == nil || !strings.Contains(err.Error(), "Incorrect API key") {
+		t.Errorf("expected the API's message, got %v", err)
+	}
+}
+
+// Test that malformed config sections are rejected
+func TestSearchConfig(t *testing.T) {
+	for _, c := range []searchConfig{{}, {Dir: "docs", Embeddings: &embeddingsConfig{URL: "http://localhost:11434/v1/embeddings", Model: "nomic-embed-text"}}} {
+		if err := c.validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", c, err)
+		}
+	}
+	for _, c := range []searchConfig{
+		{ChunkSize: 100, ChunkOverlap: 100},
+		{ChunkSize: 100},
+		{ChunkOverlap: -1},
+		{Embeddings: &embeddingsConfig{URL: "localhost:11434", Model: "m"}},
+		{Embeddings: &embeddingsConfig{URL: "https://api.openai.com/v1/embeddings"}},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
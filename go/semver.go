@@ -0,0 +1,565 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// semver is a Semantic Versioning 2.0.0 version.
+type semver struct {
+	major, minor, patch uint64
+	pre                 []string // dot-separated pre-release identifiers
+	build               string
+}
+
+// parseSemver parses a version like 1.2.3-rc.1+build.5, with an optional
+// leading v as in Git tags.
+func parseSemver(s string) (semver, error) {
+	v, rest, err := parseSemverCore(s, false)
+	if err != nil {
+		return semver{}, err
+	}
+	if rest != "" {
+		return semver{}, fmt.Errorf("%q is not a semantic version", s)
+	}
+	return v.semver, nil
+}
+
+// partialSemver is a version in a constraint, whose minor and patch
+// numbers may be missing or wildcards (x, X, or *).
+type partialSemver struct {
+	semver
+	parts int // how many of major, minor, and patch are given
+}
+
+// parseSemverCore parses a version at the start of s and returns the rest
+// of s. If partial, trailing numbers may be missing or wildcards.
+func parseSemverCore(s string, partial bool) (partialSemver, string, error) {
+	orig := s
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "v"), "V")
+	var v partialSemver
+	nums := [3]*uint64{&v.major, &v.minor, &v.patch}
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			if !strings.HasPrefix(s, ".") {
+				if partial {
+					break
+				}
+				return v, "", fmt.Errorf("%q is not a semantic version: expected MAJOR.MINOR.PATCH", orig)
+			}
+			s = s[1:]
+		}
+		n := 0
+		for n < len(s) && s[n] >= '0' && s[n] <= '9' {
+			n++
+		}
+		if n == 0 && partial && s != "" && (s[0] == 'x' || s[0] == 'X' || s[0] == '*') {
+			s = s[1:]
+			// The rest of a wildcard version is wildcards too.
+			for strings.HasPrefix(s, ".x") || strings.HasPrefix(s, ".X") || strings.HasPrefix(s, ".*") {
+				s = s[2:]
+			}
+			break
+		}
+		if n == 0 || n > 1 && s[0] == '0' {
+			return v, "", fmt.Errorf("%q is not a semantic version: invalid number", orig)
+		}
+		num, err := strconv.ParseUint(s[:n], 10, 64)
+		if err != nil {
+			return v, "", fmt.Errorf("%q is not a semantic version: number too large", orig)
+		}
+		*nums[i] = num
+		v.parts++
+		s = s[n:]
+	}
+	if v.parts == 3 && strings.HasPrefix(s, "-") {
+		end := strings.IndexAny(s, "+ ,|")
+		if end < 0 {
+			end = len(s)
+		}
+		v.pre = strings.Split(s[1:end], ".")
+		for _, id := range v.pre {
+			if !validSemverIdentifier(id) || id[0] == '0' && len(id) > 1 && isDigits(id) {
+				return v, "", fmt.Errorf("%q is not a semantic version: invalid pre-release", orig)
+			}
+		}
+		s = s[end:]
+	}
+	if v.parts == 3 && strings.HasPrefix(s, "+") {
+		end := strings.IndexAny(s, " ,|")
+		if end < 0 {
+			end = len(s)
+		}
+		v.build = s[1:end]
+		for _, id := range strings.Split(v.build, ".") {
+			if !validSemverIdentifier(id) {
+				return v, "", fmt.Errorf("%q is not a semantic version: invalid build metadata", orig)
+			}
+		}
+		s = s[end:]
+	}
+	return v, s, nil
+}
+
+// validSemverIdentifier reports whether id is a non-empty run of ASCII
+// letters, digits, and hyphens.
+func validSemverIdentifier(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits reports whether s is all ASCII digits.
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// String returns v without a leading v.
+func (v semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+	if len(v.pre) > 0 {
+		s += "-" + strings.Join(v.pre, ".")
+	}
+	if v.build != "" {
+		s += "+" + v.build
+	}
+	return s
+}
+
+// compare returns -1, 0, or 1 as v has lower, equal, or higher precedence
+// than w. Build metadata does not count.
+func (v semver) compare(w semver) int {
+	for _, d := range [][2]uint64{{v.major, w.major}, {v.minor, w.minor}, {v.patch, w.patch}} {
+		if d[0] != d[1] {
+			if d[0] < d[1] {
+				return -1
+			}
+			return 1
+		}
+	}
+	// A pre-release precedes its release.
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		a, b := v.pre[i], w.pre[i]
+		if a == b {
+			continue
+		}
+		aNum, bNum := isDigits(a), isDigits(b)
+		switch {
+		case aNum && bNum:
+			if len(a) != len(b) {
+				return cmp.Compare(len(a), len(b))
+			}
+			return strings.Compare(a, b)
+		case aNum:
+			return -1 // numeric identifiers precede alphanumeric ones
+		case bNum:
+			return 1
+		}
+		return strings.Compare(a, b)
+	}
+	return cmp.Compare(len(v.pre), len(w.pre))
+}
+
+// sameCore reports whether v and w have the same major, minor, and patch
+// numbers.
+func (v semver) sameCore(w semver) bool {
+	return v.major == w.major && v.minor == w.minor && v.patch == w.patch
+}
+
+// semverComparator is a primitive comparison, such as >=1.2.0, that
+// constraints are reduced to.
+type semverComparator struct {
+	op string // =, !=, <, <=, >, or >=
+	v  semver
+}
+
+// matches reports whether v satisfies c.
+func (c semverComparator) matches(v semver) bool {
+	d := v.compare(c.v)
+	switch c.op {
+	case "=":
+		return d == 0
+	case "!=":
+		return d != 0
+	case "<":
+		return d < 0
+	case "<=":
+		return d <= 0
+	case ">":
+		return d > 0
+	}
+	return d >= 0
+}
+
+// semverConstraint is a union of ranges, each the intersection of its
+// comparators.
+type semverConstraint [][]semverComparator
+
+// parseSemverConstraint parses a constraint in the syntax of npm and
+// Cargo: ranges separated by ||, each of comparators separated by commas
+// or spaces. Comparators are =, !=, <, <=, >, >=, ^ (compatible: same
+// leftmost non-zero number), ~ (same minor), bare versions with x
+// wildcards, and hyphen ranges like 1.2 - 1.4.
+func parseSemverConstraint(s string) (semverConstraint, error) {
+	var c semverConstraint
+	for _, alt := range strings.Split(s, "||") {
+		var set []semverComparator
+		fields := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			if i+2 < len(fields) && fields[i+1] == "-" {
+				lo, err := parsePartialSemver(f)
+				if err != nil {
+					return nil, err
+				}
+				hi, err := parsePartialSemver(fields[i+2])
+				if err != nil {
+					return nil, err
+				}
+				from, err := expandComparator(">=", lo)
+				if err != nil {
+					return nil, err
+				}
+				to, err := expandComparator("<=", hi)
+				if err != nil {
+					return nil, err
+				}
+				set = append(append(set, from...), to...)
+				i += 2
+				continue
+			}
+			op := ""
+			for _, o := range []string{">=", "<=", "!=", "==", "~>", ">", "<", "=", "^", "~"} {
+				if strings.HasPrefix(f, o) {
+					op = o
+					break
+				}
+			}
+			rest := f[len(op):]
+			if rest == "" && i+1 < len(fields) {
+				// An operator apart from its version, as in ">= 1.2".
+				i++
+				rest = fields[i]
+			}
+			v, err := parsePartialSemver(rest)
+			if err != nil {
+				return nil, err
+			}
+			cmps, err := expandComparator(op, v)
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, cmps...)
+		}
+		if len(set) == 0 {
+			set = []semverComparator{{">=", semver{}}} // empty means any
+		}
+		c = append(c, set)
+	}
+	return c, nil
+}
+
+// parsePartialSemver parses a whole version in a constraint.
+func parsePartialSemver(s string) (partialSemver, error) {
+	v, rest, err := parseSemverCore(s, true)
+	if err != nil {
+		return v, err
+	}
+	if rest != "" {
+		return v, fmt.Errorf("%q is not a version", s)
+	}
+	return v, nil
+}
+
+// expandComparator reduces an operator and a partial version to the
+// primitive comparators they mean. As in npm, the upper bounds of ranges
+// are pre-release 0, so that ^1.2.0 excludes 2.0.0-rc.1.
+func expandComparator(op string, v partialSemver) ([]semverComparator, error) {
+	lower := v.semver
+	// above returns the lowest version above all those matching v's first
+	// parts.
+	above := func(parts int) semver {
+		switch parts {
+		case 1:
+			return semver{major: v.major + 1, pre: []string{"0"}}
+		case 2:
+			return semver{major: v.major, minor: v.minor + 1, pre: []string{"0"}}
+		}
+		return semver{major: v.major, minor: v.minor, patch: v.patch + 1, pre: []string{"0"}}
+	}
+	if v.parts == 0 {
+		if op == "<" || op == ">" || op == "!=" {
+			return []semverComparator{{"<", semver{pre: []string{"0"}}}}, nil // nothing
+		}
+		return []semverComparator{{">=", semver{}}}, nil
+	}
+	switch op {
+	case "^":
+		// The same leftmost non-zero number, or all given ones if zero.
+		parts := 3
+		switch {
+		case v.major > 0 || v.parts == 1:
+			parts = 1
+		case v.minor > 0 || v.parts == 2:
+			parts = 2
+		}
+		return []semverComparator{{">=", lower}, {"<", above(parts)}}, nil
+	case "~", "~>":
+		return []semverComparator{{">=", lower}, {"<", above(min(v.parts, 2))}}, nil
+	case ">":
+		if v.parts < 3 {
+			next := above(v.parts)
+			next.pre = nil
+			return []semverComparator{{">=", next}}, nil
+		}
+	case "<=":
+		if v.parts < 3 {
+			return []semverComparator{{"<", above(v.parts)}}, nil
+		}
+	case "<":
+		if v.parts < 3 {
+			lower.pre = []string{"0"}
+		}
+	case "!=":
+		if v.parts < 3 {
+			return nil, fmt.Errorf("!=%s needs a full version", v.String())
+		}
+	case "", "=", "==":
+		if v.parts < 3 {
+			return []semverComparator{{">=", lower}, {"<", above(v.parts)}}, nil
+		}
+		op = "="
+	}
+	return []semverComparator{{op, lower}}, nil
+}
+
+// satisfiedBy reports whether v is in one of c's ranges. A pre-release
+// version is only in a range that has a comparator with a pre-release of
+// the same major, minor, and patch numbers, as in npm and Cargo, so that
+// >=1.2.0 does not match 2.0.0-alpha.
+func (c semverConstraint) satisfiedBy(v semver) bool {
+	for _, set := range c {
+		ok, preAllowed := true, len(v.pre) == 0
+		for _, cmp := range set {
+			if !cmp.matches(v) {
+				ok = false
+				break
+			}
+			if len(cmp.v.pre) > 0 && cmp.v.sameCore(v) {
+				preAllowed = true
+			}
+		}
+		if ok && preAllowed {
+			return true
+		}
+	}
+	return false
+}
+
+// semverTool parses, compares, sorts, and checks semantic versions against
+// constraints.
+type semverTool struct{}
+
+// semverArgs are the arguments of the semver tool.
+type semverArgs struct {
+	Action     string   `json:"action" enum:"parse,compare,satisfies,sort" description:"parse a version, compare it with another, check versions against a constraint, or sort versions"`
+	Version    string   `json:"version,omitempty" description:"The version to parse, compare, or check, such as 1.2.3, v2.0.0-rc.1, or 1.0.0+build.5"`
+	Other      string   `json:"other,omitempty" description:"For compare, the version to compare the version with"`
+	Constraint string   `json:"constraint,omitempty" description:"For satisfies, a range such as ^1.2.0, ~1.4, >=2,<3, 1.x, or 1.2 - 1.4 || >=3"`
+	Versions   []string `json:"versions,omitempty" description:"For satisfies and sort, the versions to check or sort"`
+	Descending bool     `json:"descending,omitempty" description:"For sort, put the highest version first"`
+}
+
+// Name returns the name of the semver tool.
+func (t *semverTool) Name() string {
+	return "semver"
+}
+
+// Description returns a brief description of the semver tool.
+func (t *semverTool) Description() string {
+	return "Parses and compares semantic versions, checks them against constraints like ^1.2.0 or >=2,<3 (npm and Cargo syntax), and sorts lists of versions"
+}
+
+// InputSchema returns the JSON schema for the semver tool's input
+// parameters.
+func (t *semverTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(semverArgs{})
+}
+
+// Annotations marks the semver tool as read-only.
+func (t *semverTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Semantic versions")
+}
+
+// semverParts is the result of the parse action.
+type semverParts struct {
+	Version    string   `json:"version"`
+	Major      uint64   `json:"major"`
+	Minor      uint64   `json:"minor"`
+	Patch      uint64   `json:"patch"`
+	Prerelease []string `json:"prerelease,omitempty"`
+	Build      string   `json:"build,omitempty"`
+}
+
+// semverMatches is the result of the satisfies action for a list of
+// versions.
+type semverMatches struct {
+	Matching []string `json:"matching"` // lowest first
+	Highest  string   `json:"highest,omitempty"`
+}
+
+// Execute performs the action. Compare and satisfies with a single version
+// answer in a sentence, such as "1.2.0 < 1.10.0"; the others answer with
+// indented JSON text. Versions are returned as they were given.
+func (t *semverTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	var a semverArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	a.Version = strings.TrimSpace(a.Version)
+	var result interface{}
+	switch a.Action {
+	case "parse":
+		v, err := parseSemverArg("version", a.Version)
+		if err != nil {
+			return nil, err
+		}
+		result = semverParts{Version: v.String(), Major: v.major, Minor: v.minor, Patch: v.patch, Prerelease: v.pre, Build: v.build}
+	case "compare":
+		v, err := parseSemverArg("version", a.Version)
+		if err != nil {
+			return nil, err
+		}
+		other := strings.TrimSpace(a.Other)
+		w, err := parseSemverArg("other", other)
+		if err != nil {
+			return nil, err
+		}
+		relation := [...]string{"<", "=", ">"}[v.compare(w)+1]
+		return []ToolContent{{Type: "text", Text: a.Version + " " + relation + " " + other}}, nil
+	case "satisfies":
+		if strings.TrimSpace(a.Constraint) == "" {
+			return nil, toolFailure("invalid value for 'constraint': satisfies needs a constraint")
+		}
+		c, err := parseSemverConstraint(a.Constraint)
+		if err != nil {
+			return nil, toolFailure("invalid value for 'constraint': %v", err)
+		}
+		if a.Version == "" && a.Versions == nil {
+			return nil, toolFailure("invalid value for 'version': satisfies needs a version or versions")
+		}
+		if a.Versions == nil {
+			v, err := parseSemverArg("version", a.Version)
+			if err != nil {
+				return nil, err
+			}
+			verb := "satisfies"
+			if !c.satisfiedBy(v) {
+				verb = "does not satisfy"
+			}
+			return []ToolContent{{Type: "text", Text: a.Version + " " + verb + " " + strings.TrimSpace(a.Constraint)}}, nil
+		}
+		sorted, err := sortSemvers(a.Versions, false)
+		if err != nil {
+			return nil, err
+		}
+		matches := semverMatches{Matching: []string{}}
+		for _, s := range sorted {
+			if c.satisfiedBy(s.v) {
+				matches.Matching = append(matches.Matching, s.text)
+				matches.Highest = s.text
+			}
+		}
+		result = matches
+	case "sort":
+		sorted, err := sortSemvers(a.Versions, a.Descending)
+		if err != nil {
+			return nil, err
+		}
+		texts := make([]string, len(sorted))
+		for i, s := range sorted {
+			texts[i] = s.text
+		}
+		result = texts
+	default:
+		return nil, toolFailure("invalid value for 'action'")
+	}
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
+
+// parseSemverArg parses the version argument name.
+func parseSemverArg(name, s string) (semver, error) {
+	if s == "" {
+		return semver{}, toolFailure("invalid value for '%s': %s needs a version", name, name)
+	}
+	v, err := parseSemver(s)
+	if err != nil {
+		return semver{}, toolFailure("invalid value for '%s': %v", name, err)
+	}
+	return v, nil
+}
+
+// parsedSemver is a version and how it was given.
+type parsedSemver struct {
+	text string
+	v    semver
+}
+
+// sortSemvers parses versions and sorts them by precedence, then by build
+// metadata so that the order is the same for any input order.
+func sortSemvers(versions []string, descending bool) ([]parsedSemver, error) {
+	parsed := make([]parsedSemver, 0, len(versions))
+	var invalid []string
+	for _, s := range versions {
+		s = strings.TrimSpace(s)
+		v, err := parseSemver(s)
+		if err != nil {
+			invalid = append(invalid, strconv.Quote(s))
+			continue
+		}
+		parsed = append(parsed, parsedSemver{s, v})
+	}
+	if len(invalid) > 0 {
+		return nil, toolFailure("invalid value for 'versions': not semantic versions: %s", strings.Join(invalid, ", "))
+	}
+	sort.SliceStable(parsed, func(i, j int) bool {
+		a, b := parsed[i], parsed[j]
+		if descending {
+			a, b = b, a
+		}
+		if d := a.v.compare(b.v); d != 0 {
+			return d < 0
+		}
+		return a.v.build < b.v.build
+	})
+	return parsed, nil
+}
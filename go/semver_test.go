@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Test the precedence of versions, including pre-releases and build
+// metadata
+func TestSemverCompare(t *testing.T) {
+	// In increasing precedence, from the examples of the specification.
+	ordered := []string{
+		"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2",
+		"1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.2.0", "1.10.0", "2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			v, _ := parseSemver(ordered[i])
+			w, _ := parseSemver(ordered[j])
+			if got, expected := v.compare(w), strings.Compare(string(rune('a'+i)), string(rune('a'+j))); got != expected {
+				t.Errorf("%s vs %s: expected %d, got %d", ordered[i], ordered[j], expected, got)
+			}
+		}
+	}
+	v, _ := parseSemver("v1.2.3+build.5")
+	w, _ := parseSemver("1.2.3")
+	if v.compare(w) != 0 || v.String() != "1.2.3+build.5" {
+		t.Errorf("expected build metadata to be ignored, got %d for %s", v.compare(w), v)
+	}
+	for _, bad := range []string{"", "1", "1.2", "01.2.3", "1.2.3.4", "1.2.3-", "1.2.3-01", "1.2.3-a..b", "1.2.3+", "1.2.x", "one.two.three"} {
+		if _, err := parseSemver(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+// Test matching versions against constraints
+func TestSemverConstraints(t *testing.T) {
+	for _, tc := range []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{"^1.2.0", []string{"1.2.0", "1.9.9"}, []string{"1.1.9", "2.0.0", "2.0.0-rc.1", "1.5.0-beta"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0", "0.2.2"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"^1.2.0-beta.2", []string{"1.2.0-beta.3", "1.2.0", "1.3.0"}, []string{"1.2.0-beta.1", "1.3.0-beta"}},
+		{"~1.4", []string{"1.4.0", "1.4.7"}, []string{"1.5.0", "1.3.9"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{">=2,<3", []string{"2.0.0", "2.99.0"}, []string{"1.9.9", "3.0.0", "3.0.0-alpha"}},
+		{">= 2 < 3", []string{"2.5.0"}, []string{"3.0.0"}},
+		{">1.2", []string{"1.3.0"}, []string{"1.2.9"}},
+		{"<=1.2", []string{"1.2.9"}, []string{"1.3.0"}},
+		{"<1.2", []string{"1.1.9"}, []string{"1.2.0", "1.2.0-rc.1"}},
+		{"1.x", []string{"1.0.0", "1.9.9"}, []string{"2.0.0", "0.9.0"}},
+		{"1.2.*", []string{"1.2.5"}, []string{"1.3.0"}},
+		{"*", []string{"0.0.1", "10.0.0"}, []string{"1.0.0-rc.1"}},
+		{"=1.2.3", []string{"1.2.3", "v1.2.3+b"}, []string{"1.2.4"}},
+		{"1.2 - 1.4", []string{"1.2.0", "1.4.9"}, []string{"1.5.0", "1.1.0"}},
+		{"^1.0.0 || >=3.1, !=3.2.0", []string{"1.5.0", "3.1.0", "3.3.0"}, []string{"2.0.0", "3.2.0"}},
+		{"~>2.2", []string{"2.2.4"}, []string{"2.3.0"}},
+	} {
+		c, err := parseSemverConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("%s: %v", tc.constraint, err)
+			continue
+		}
+		for _, s := range tc.match {
+			if v, _ := parseSemver(s); !c.satisfiedBy(v) {
+				t.Errorf("%s: expected %s to match", tc.constraint, s)
+			}
+		}
+		for _, s := range tc.noMatch {
+			if v, _ := parseSemver(s); c.satisfiedBy(v) {
+				t.Errorf("%s: expected %s not to match", tc.constraint, s)
+			}
+		}
+	}
+	for _, bad := range []string{"^", ">=a.b", "!=1.2", "1.2.3.4", "^1.2 - 2"} {
+		if _, err := parseSemverConstraint(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+// Test each action of the semver tool
+func TestSemverTool(t *testing.T) {
+	tool := &semverTool{}
+	for _, tc := range []struct {
+		args     map[string]interface{}
+		expected string
+	}{
+		{map[string]interface{}{"action": "compare", "version": "1.2.0", "other": "1.10.0"}, "1.2.0 < 1.10.0"},
+		{map[string]interface{}{"action": "compare", "version": "v2.0.0", "other": "2.0.0-rc.1"}, "v2.0.0 > 2.0.0-rc.1"},
+		{map[string]interface{}{"action": "satisfies", "version": "1.4.2", "constraint": "^1.2.0"}, "1.4.2 satisfies ^1.2.0"},
+		{map[string]interface{}{"action": "satisfies", "version": "3.0.0", "constraint": ">=2,<3"}, "3.0.0 does not satisfy >=2,<3"},
+		{
+			map[string]interface{}{"action": "parse", "version": "v1.2.3-rc.1+build.5"},
+			"{\n  \"version\": \"1.2.3-rc.1+build.5\",\n  \"major\": 1,\n  \"minor\": 2,\n  \"patch\": 3,\n  \"prerelease\": [\n    \"rc\",\n    \"1\"\n  ],\n  \"build\": \"build.5\"\n}",
+		},
+		{
+			map[string]interface{}{"action": "sort", "versions": []interface{}{"1.10.0", "v1.2.0", "1.2.0-rc.1", "0.9.0"}, "descending": true},
+			"[\n  \"1.10.0\",\n  \"v1.2.0\",\n  \"1.2.0-rc.1\",\n  \"0.9.0\"\n]",
+		},
+		{
+			map[string]interface{}{"action": "satisfies", "versions": []interface{}{"2.1.0", "1.9.0", "2.0.0", "3.0.0"}, "constraint": "^2"},
+			"{\n  \"matching\": [\n    \"2.0.0\",\n    \"2.1.0\"\n  ],\n  \"highest\": \"2.1.0\"\n}",
+		},
+		{
+			map[string]interface{}{"action": "satisfies", "versions": []interface{}{"1.0.0"}, "constraint": "^2"},
+			"{\n  \"matching\": []\n}",
+		},
+	} {
+		content, err := tool.Execute(tc.args)
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		if content[0].Text != tc.expected {
+			t.Errorf("%v: expected %q, got %q", tc.args, tc.expected, content[0].Text)
+		}
+	}
+
+	for _, tc := range []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"action": "parse", "version": "1.2"}, `invalid value for 'version': "1.2" is not a semantic version: expected MAJOR.MINOR.PATCH`},
+		{map[string]interface{}{"action": "compare", "version": "1.2.0"}, "invalid value for 'other': other needs a version"},
+		{map[string]interface{}{"action": "satisfies", "version": "1.2.0"}, "invalid value for 'constraint': satisfies needs a constraint"},
+		{map[string]interface{}{"action": "satisfies", "constraint": "^1"}, "invalid value for 'version': satisfies needs a version or versions"},
+		{map[string]interface{}{"action": "sort", "versions": []interface{}{"1.0.0", "latest"}}, `invalid value for 'versions': not semantic versions: "latest"`},
+		{map[string]interface{}{"action": "bump", "version": "1.2.0"}, "invalid value for 'action'"},
+	} {
+		_, err := tool.Execute(tc.args)
+		var failure *toolResultError
+		if !errors.As(err, &failure) || failure.content[0].Text != tc.want {
+			t.Errorf("%v: expected the error result %q, got %v", tc.args, tc.want, err)
+		}
+	}
+}
@@ -0,0 +1,1448 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/client"
+)
+
+// codeRequestTimeout is the implementation-defined JSON-RPC error code sent
+// when a request exceeds the request timeout.
+const codeRequestTimeout = -32001
+
+// codeResultTooLarge is the implementation-defined JSON-RPC error code sent
+// when a tool result exceeds the maximum result size.
+const codeResultTooLarge = -32003
+
+// errMessageTooLarge is returned by messageReader for messages over the limit.
+var errMessageTooLarge = errors.New("message too large")
+
+// sizeLimitData is the "data" member of a size limit error.
+type sizeLimitData struct {
+	Size  int `json:"size,omitempty"`
+	Limit int `json:"limit"`
+}
+
+// errDrainTimeout is returned by Serve when in-flight requests did not finish
+// within the drain timeout after shutdown was requested.
+var errDrainTimeout = errors.New("timed out waiting for in-flight requests")
+
+// errReadFailed and errWriteFailed are wrapped by the errors Serve returns
+// when reading messages from the client or writing messages to it fails.
+var (
+	errReadFailed  = errors.New("reading from the client failed")
+	errWriteFailed = errors.New("writing to the client failed")
+)
+
+// errExitWithoutShutdown is returned by Serve when the client sent exit
+// without sending shutdown first, which the Language Server Protocol,
+// whose shutdown sequence the server follows, treats as a failure.
+var errExitWithoutShutdown = errors.New("the client sent exit without shutdown")
+
+// Server is an MCP server that reads JSON-RPC requests from a reader and
+// writes responses to a writer.
+type Server struct {
+	started            time.Time
+	tools              []MCPTool
+	drainTimeout       time.Duration
+	requestTimeout     time.Duration
+	keepalive          time.Duration // between pings of the client, zero for none
+	workers            chan struct{} // bounds concurrently executing tool calls
+	maxMessageSize     int           // inbound limit in bytes, zero for none
+	maxResultSize      int           // outbound tool result limit in bytes, zero for none
+	logger             *slog.Logger
+	stats              toolStats
+	counts             serverCounts // reported by health, unlike the process-wide metrics
+	memStats           memStatsCache
+	statusTool         bool           // serve the built-in server_status tool
+	batchCallTool      bool           // serve the built-in batch_call tool
+	requestLog         bool           // log every request with its outcome
+	filter             toolFilter     // restricts the exposed tools
+	coerce             []string       // patterns of the tools whose arguments are coerced
+	readOnly           bool           // expose only tools annotated as read-only
+	confirmDestructive bool           // ask the client before running destructive tools
+	resultMeta         bool           // report what each tool call cost in its result
+	redactor           *redactor      // removes secrets from logged and echoed text
+	upstreams          []*upstream    // proxied servers providing resources and prompts
+	files              *fileResources // local files served as resources, if any
+	resourcePoll       time.Duration  // how often to look for changed files, zero for never
+	watcher            *resourceWatcher
+	library            *promptLibrary  // local prompts, if any
+	diagnostics        *diagnosticsLog // recent tool panics and timeouts, if kept
+	hooks              []Hooks
+	completers         map[completerKey]Completer
+	instructions       string     // returned by initialize, if set
+	info               serverInfo // returned by initialize
+
+	sessionRateLimit RateLimit
+	sessionBudget    Budget
+	toolBuckets      map[string]*tokenBucket // shared by all sessions
+	caches           map[string]*resultCache // per-tool result caches, keyed by session
+
+	slotsMu sync.Mutex
+	slots   map[string]chan struct{} // per-tool semaphores for ConcurrencyLimitedTool
+
+	schemaDefs map[string]interface{} // definitions shared by all input schemas
+
+	lists listings       // encoded tools, resources and prompts lists
+	queue *dispatchQueue // work waiting for a worker, if prioritized
+	tasks *taskManager   // background tasks, if enabled
+}
+
+// defaultMaxMessageSize is the default limit for inbound messages and
+// outbound tool results.
+const defaultMaxMessageSize = 16 << 20
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithTools sets the tools served instead of the package-level tools list.
+func WithTools(t ...MCPTool) Option {
+	return func(s *Server) {
+		s.tools = t
+	}
+}
+
+// WithDrainTimeout sets how long Serve waits for in-flight requests to
+// finish once its context is cancelled.
+func WithDrainTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.drainTimeout = d
+	}
+}
+
+// WithRequestTimeout sets the maximum duration of a single request. When it
+// is exceeded the tool's context is cancelled and a timeout error is
+// returned. Zero means no limit.
+func WithRequestTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.requestTimeout = d
+	}
+}
+
+// WithMaxMessageSize sets the largest inbound message, in bytes, that the
+// server accepts. Larger messages are answered with a "message too large"
+// error. Zero means no limit.
+func WithMaxMessageSize(n int) Option {
+	return func(s *Server) {
+		s.maxMessageSize = n
+	}
+}
+
+// WithMaxResultSize sets the largest tool result, in bytes of serialized
+// content, that the server sends. Larger results are replaced by an error.
+// Zero means no limit.
+func WithMaxResultSize(n int) Option {
+	return func(s *Server) {
+		s.maxResultSize = n
+	}
+}
+
+// WithStatusTool adds the built-in server_status tool, which reports the
+// same information as the "stats" method to agents.
+func WithStatusTool() Option {
+	return func(s *Server) {
+		s.statusTool = true
+	}
+}
+
+// WithReadOnly hides every tool not annotated as read-only, so that calls
+// to tools that may modify anything are rejected as unknown tools.
+func WithReadOnly() Option {
+	return func(s *Server) {
+		s.readOnly = true
+	}
+}
+
+// WithRedactor sets how secrets are removed from arguments and messages the
+// server echoes, such as in confirmation prompts and upstream errors.
+func WithRedactor(r *redactor) Option {
+	return func(s *Server) {
+		s.redactor = r
+	}
+}
+
+// serverInfo identifies the server to clients.
+type serverInfo struct {
+	name, version, title string
+}
+
+// WithServerInfo sets the name, version, and human-readable title the
+// server reports in the initialize result. Empty values keep the defaults:
+// the built-in name, the version of the binary, and no title.
+func WithServerInfo(name, version, title string) Option {
+	return func(s *Server) {
+		if name != "" {
+			s.info.name = name
+		}
+		if version != "" {
+			s.info.version = version
+		}
+		s.info.title = title
+	}
+}
+
+// WithInstructions sets the instructions returned in the initialize result,
+// which hosts typically add to the model's system prompt to explain how to
+// use the server's tools.
+func WithInstructions(text string) Option {
+	return func(s *Server) {
+		s.instructions = text
+	}
+}
+
+// WithLogger sets the logger for server diagnostics. The default logs to
+// standard error.
+func WithLogger(l *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+// WithMaxWorkers sets how many tool calls may execute at once across all
+// sessions. Once every worker is busy, the server stops reading requests
+// until one becomes free, unless WithPriorityQueue queues them.
+func WithMaxWorkers(n int) Option {
+	return func(s *Server) {
+		if n < 1 {
+			n = 1
+		}
+		s.workers = make(chan struct{}, n)
+	}
+}
+
+// WithSessionRateLimit limits the number of requests each session may send.
+// Requests over the limit are rejected with a rate limit error.
+func WithSessionRateLimit(limit RateLimit) Option {
+	return func(s *Server) {
+		s.sessionRateLimit = limit
+	}
+}
+
+// WithToolRateLimit limits the number of calls to the named tool across all
+// sessions. Calls over the limit are rejected with a rate limit error.
+func WithToolRateLimit(name string, limit RateLimit) Option {
+	return func(s *Server) {
+		if s.toolBuckets == nil {
+			s.toolBuckets = map[string]*tokenBucket{}
+		}
+		s.toolBuckets[name] = newTokenBucket(limit)
+	}
+}
+
+// session holds the state of one client connection served by Serve.
+type session struct {
+	state     *Session        // shared by the requests of an HTTP session
+	ctx       context.Context // parent of every request's context, carrying state
+	cancel    func()          // cancels ctx, and with it the in-flight requests
+	shutdown  <-chan struct{} // closed when the session stops taking requests
+	w         Responder
+	limiter   *tokenBucket
+	access    *toolFilter     // tools this session may use, nil for all
+	requests  *clientRequests // server-to-client requests, nil if unsupported
+	lifecycle *lifecycle
+	inflight  sync.WaitGroup
+
+	// toClient, if set, receives the requests to the client instead of w,
+	// which then only collects responses, as for a batch.
+	toClient io.Writer
+}
+
+// clientWriter returns the writer for requests to the client.
+func (sess *session) clientWriter() io.Writer {
+	if sess.toClient != nil {
+		return sess.toClient
+	}
+	return sess.w
+}
+
+// allowsTool reports whether the session may list and call the named tool.
+func (sess *session) allowsTool(name string) bool {
+	return sess.access == nil || sess.access.allows(name)
+}
+
+// NewServer returns a Server configured by opts.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		started:        time.Now(),
+		info:           serverInfo{name: serverName, version: serverVersion},
+		tools:          tools,
+		drainTimeout:   5 * time.Second,
+		workers:        make(chan struct{}, 16),
+		maxMessageSize: defaultMaxMessageSize,
+		maxResultSize:  defaultMaxMessageSize,
+		logger:         slog.New(slog.NewTextHandler(os.Stderr, nil)),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.files != nil && s.resourcePoll > 0 {
+		s.watcher = newResourceWatcher(s.files, &s.lists, s.resourcePoll)
+	}
+	if s.statusTool {
+		s.tools = append(append([]MCPTool(nil), s.tools...), &serverStatusTool{server: s})
+	}
+	if s.batchCallTool {
+		s.tools = append(append([]MCPTool(nil), s.tools...), &batchCallTool{server: s})
+	}
+	if s.tasks != nil {
+		s.tools = append(append([]MCPTool(nil), s.tools...), &taskStatusTool{s.tasks}, &taskResultTool{s.tasks})
+	}
+	s.tools = s.filter.apply(s.tools)
+	if s.readOnly {
+		var kept []MCPTool
+		for _, t := range s.tools {
+			if isReadOnly(t) {
+				kept = append(kept, t)
+			}
+		}
+		s.tools = kept
+	}
+	return s
+}
+
+// runMCPServer reads JSON-RPC requests from r and writes responses to w.
+func runMCPServer(r io.Reader, w io.Writer) error {
+	return NewServer().Serve(context.Background(), r, w)
+}
+
+// toolsCallParams holds the parameters expected by "tools/call".
+type toolsCallParams struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Meta      struct {
+		ProgressToken json.RawMessage `json:"progressToken"`
+		TimeoutMs     json.RawMessage `json:"timeoutMs"` // how long the client waits for the result
+		Deadline      json.RawMessage `json:"deadline"`  // when the client gives up, in RFC 3339
+	} `json:"_meta"`
+}
+
+// knownMethods lists the methods handled by the server. Any other method
+// is counted as "other" in metrics to keep label cardinality bounded.
+var knownMethods = map[string]bool{
+	"initialize":                       true,
+	"initialized":                      true,
+	"notifications/initialized":        true,
+	"cancelled":                        true,
+	"ping":                             true,
+	"tools/list":                       true,
+	"resources/list":                   true,
+	"resources/read":                   true,
+	"resources/subscribe":              true,
+	"resources/unsubscribe":            true,
+	"prompts/list":                     true,
+	"prompts/get":                      true,
+	"completion/complete":              true,
+	"logging/setLevel":                 true,
+	"notifications/roots/list_changed": true,
+	"health":                           true,
+	"stats":                            true,
+	"tools/call":                       true,
+	"shutdown":                         true,
+	"exit":                             true,
+}
+
+// Serve reads JSON-RPC requests from r and writes responses to w until r
+// reaches EOF, the client sends exit, or ctx is cancelled. Tool calls run
+// concurrently on the server's worker pool, so their responses may be
+// written out of order. Then Serve stops reading new requests and waits up
+// to the drain timeout for the tool calls in flight, and flushes w if it
+// has a Flush method, so that every response is out when it returns. EOF,
+// exit after shutdown and cancellation are orderly ends, for which it
+// returns nil unless draining times out.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) (err error) {
+	metrics.activeSessions.add(1)
+	defer metrics.activeSessions.add(-1)
+	if f, ok := w.(interface{ Flush() error }); ok {
+		defer func() {
+			if flushErr := f.Flush(); flushErr != nil && err == nil {
+				err = fmt.Errorf("%w: %w", errWriteFailed, flushErr)
+			}
+		}()
+	}
+
+	mw := s.sessionWriter(w)
+	sess := &session{
+		shutdown:  ctx.Done(),
+		w:         mw,
+		limiter:   newTokenBucket(s.sessionRateLimit),
+		requests:  newClientRequests(),
+		lifecycle: &lifecycle{},
+	}
+	sess.state = newSession(newSessionID(), sess.lifecycle, sess.requests, sess.w)
+	// In-flight requests are allowed to finish during shutdown, up to the
+	// drain timeout.
+	reqCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+	sess.ctx, sess.cancel = contextWithSession(reqCtx, sess.state), cancel
+	s.sessionStarted(sess.ctx, sess.state)
+	defer s.sessionEnded(sess.state)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	gone := s.pingClient(sess, stop)
+	lines := make(chan *bytes.Buffer)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		mr := newMessageReader(r, s.maxMessageSize)
+		for {
+			line, err := mr.read()
+			if errors.Is(err, errMessageTooLarge) {
+				sendErrorData(sess.w, nil, -32600, "Invalid Request: message too large",
+					sizeLimitData{Limit: s.maxMessageSize})
+				continue
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+			if s.deliverResponse(sess, line.Bytes()) {
+				putBuffer(line)
+				continue
+			}
+			select {
+			case lines <- line:
+			case <-stop:
+				putBuffer(line)
+				return
+			}
+		}
+	}()
+
+	for {
+		if ctx.Err() != nil {
+			return s.drain(sess)
+		}
+		select {
+		case <-ctx.Done():
+			return s.drain(sess)
+		case <-mw.broken:
+			// The client is gone: nobody is left to answer.
+			cancel()
+			s.drain(sess)
+			return fmt.Errorf("%w: %w", errWriteFailed, mw.err())
+		case <-gone:
+			cancel()
+			s.drain(sess)
+			return errClientUnresponsive
+		case line, ok := <-lines:
+			if !ok {
+				err := <-readErr
+				if err != nil {
+					err = fmt.Errorf("%w: %w", errReadFailed, err)
+				}
+				if drainErr := s.drain(sess); err == nil {
+					err = drainErr
+				}
+				return err
+			}
+			// handleLine keeps no reference to the message, so its
+			// buffer can be reused as soon as it returns.
+			if len(bytes.TrimSpace(line.Bytes())) > 0 {
+				s.handleLine(sess, line.Bytes())
+			}
+			putBuffer(line)
+			if exited, inOrder := sess.lifecycle.exited(); exited {
+				err := s.drain(sess)
+				if err == nil && !inOrder {
+					err = errExitWithoutShutdown
+				}
+				return err
+			}
+		}
+	}
+}
+
+// messageReader reads newline-delimited messages. Unlike bufio.Scanner it
+// reads lines of any length up to the limit, so large tool arguments are
+// read in full. A message whose first line ends within an object or array
+// after an opening bracket or a comma, as pretty-printed JSON does,
+// continues until they are closed, unless a line is a whole message of its
+// own, which then ends the malformed one. Byte order marks at the start of
+// lines and the carriage returns of CRLF line endings are dropped.
+type messageReader struct {
+	br    *bufio.Reader
+	limit int           // largest message in bytes, zero for none
+	next  *bytes.Buffer // a message read while looking for the end of the previous one
+}
+
+// newMessageReader returns a messageReader reading from r.
+func newMessageReader(r io.Reader, limit int) *messageReader {
+	return &messageReader{br: bufio.NewReader(r), limit: limit}
+}
+
+// read reads the next message into a buffer from bufferPool, which the
+// caller returns with putBuffer. A longer message than the limit is
+// discarded up to its end and errMessageTooLarge is returned, leaving the
+// reader positioned at the next message. A final message without a
+// trailing newline is returned before io.EOF.
+func (m *messageReader) read() (*bytes.Buffer, error) {
+	if next := m.next; next != nil {
+		m.next = nil
+		return next, nil
+	}
+	buf := getBuffer()
+	tooLarge := false
+	err := m.readLine(buf)
+	switch {
+	case err == errMessageTooLarge:
+		tooLarge = true
+	case err != nil:
+		putBuffer(buf)
+		return nil, err
+	}
+	var framer messageFramer
+	framer.feed(buf.Bytes())
+	for more := !tooLarge && framer.pretty(); more; more = framer.open() {
+		line := getBuffer()
+		err := m.readLine(line)
+		if err == io.EOF {
+			putBuffer(line)
+			break
+		}
+		if err != nil && err != errMessageTooLarge {
+			putBuffer(line)
+			putBuffer(buf)
+			return nil, err
+		}
+		if err == nil && startsMessage(line.Bytes()) {
+			m.next = line
+			break
+		}
+		framer.feed(line.Bytes())
+		if err == errMessageTooLarge || m.limit > 0 && buf.Len()+1+line.Len() > m.limit {
+			tooLarge = true
+		}
+		if !tooLarge {
+			buf.WriteByte('\n')
+			buf.Write(line.Bytes())
+		}
+		putBuffer(line)
+	}
+	if tooLarge {
+		putBuffer(buf)
+		return nil, errMessageTooLarge
+	}
+	return buf, nil
+}
+
+// readLine reads the next line into line, without its line ending. It
+// returns io.EOF if nothing is left to read, and errMessageTooLarge, after
+// skipping the rest of the line, if the line is longer than the limit.
+func (m *messageReader) readLine(line *bytes.Buffer) error {
+	read := false
+	for {
+		chunk, err := m.br.ReadSlice('\n')
+		read = read || len(chunk) > 0
+		if line.Len() == 0 {
+			chunk = bytes.TrimPrefix(chunk, utf8BOM)
+		}
+		chunk = bytes.TrimSuffix(chunk, []byte("\n"))
+		if m.limit > 0 && line.Len()+len(chunk) > m.limit {
+			for err == bufio.ErrBufferFull {
+				_, err = m.br.ReadSlice('\n')
+			}
+			if err != nil && err != io.EOF {
+				return err
+			}
+			return errMessageTooLarge
+		}
+		line.Write(chunk)
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && !read:
+			return io.EOF
+		case err != nil && err != io.EOF:
+			return err
+		}
+		if b := line.Bytes(); len(b) > 0 && b[len(b)-1] == '\r' {
+			line.Truncate(len(b) - 1)
+		}
+		return nil
+	}
+}
+
+// drain waits up to the drain timeout for the session's in-flight requests.
+// Requests still running then are cancelled and given as long again to
+// return, so that tools observing their context do not outlive the session.
+func (s *Server) drain(sess *session) error {
+	// No more responses are read, so pending client requests cannot finish.
+	if sess.requests != nil {
+		sess.requests.close()
+	}
+	done := make(chan struct{})
+	go func() {
+		sess.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(s.drainTimeout):
+	}
+	sess.cancel()
+	select {
+	case <-done:
+	case <-time.After(s.drainTimeout):
+	}
+	return errDrainTimeout
+}
+
+// dispatch runs fn on the worker pool, blocking until a worker is free, or
+// with the priority queue, until fn is queued. It reports false without
+// running fn if the session shuts down first.
+func (s *Server) dispatch(sess *session, p priority, fn func()) bool {
+	if s.queue != nil {
+		return s.enqueue(sess, p, fn)
+	}
+	select {
+	case s.workers <- struct{}{}:
+	case <-sess.shutdown:
+		return false
+	}
+	sess.inflight.Add(1)
+	go func() {
+		defer sess.inflight.Done()
+		defer func() { <-s.workers }()
+		fn()
+	}()
+	return true
+}
+
+// messageWriter writes newline-delimited JSON messages to w. Each message
+// is encoded into a pooled buffer and written with a single call while
+// holding the lock, so concurrently sent messages never interleave and
+// reach w whole.
+type messageWriter struct {
+	mu       sync.Mutex
+	w        io.Writer
+	counts   *serverCounts // if set, counts the error responses sent
+	broken   chan struct{} // closed when a write fails
+	writeErr error         // of the first failed write
+}
+
+// newMessageWriter returns a messageWriter writing to w.
+func newMessageWriter(w io.Writer) *messageWriter {
+	return &messageWriter{w: w, broken: make(chan struct{})}
+}
+
+// sessionWriter returns a messageWriter writing to w that counts its error
+// responses as errors of s.
+func (s *Server) sessionWriter(w io.Writer) *messageWriter {
+	return &messageWriter{w: w, counts: &s.counts, broken: make(chan struct{})}
+}
+
+// write writes p to w. m.mu must be held.
+func (m *messageWriter) write(p []byte) (int, error) {
+	n, err := m.w.Write(p)
+	if err != nil && m.writeErr == nil {
+		m.writeErr = err
+		close(m.broken)
+	}
+	return n, err
+}
+
+// err returns the error of the first failed write, if any.
+func (m *messageWriter) err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.writeErr
+}
+
+// send encodes v and writes it as one message, returning the number of
+// bytes written. Nothing is written if v cannot be encoded.
+func (m *messageWriter) send(v interface{}) (int, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return 0, fmt.Errorf("%w: %v", errUnencodable, err)
+	}
+	m.mu.Lock()
+	n, err := m.write(buf.Bytes())
+	m.mu.Unlock()
+	if err == nil {
+		metrics.messageSize.observe("outbound", float64(n))
+	}
+	return n, err
+}
+
+// Write writes p, which must hold whole messages, while holding the lock.
+func (m *messageWriter) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.write(p)
+}
+
+// deliverResponse hands line to the server request waiting for it, if it
+// is such a response. The reader calls it before queueing the message: the
+// main loop may be blocked waiting for a worker held by the very call that
+// awaits the response.
+func (s *Server) deliverResponse(sess *session, line []byte) bool {
+	if !sess.requests.waiting() {
+		return false
+	}
+	req, perr := parseMessage(line)
+	if perr != nil || req.Method != "" {
+		return false
+	}
+	metrics.messageSize.observe("inbound", float64(len(line)))
+	return sess.requests.deliver(req.ID, line)
+}
+
+// handleLine processes a single JSON-RPC message.
+func (s *Server) handleLine(sess *session, line []byte) {
+	var w io.Writer = sess.w
+	metrics.messageSize.observe("inbound", float64(len(line)))
+
+	if isBatch(line) {
+		s.handleBatch(sess, line)
+		return
+	}
+	req, perr := parseMessage(line)
+	if perr != nil {
+		sendError(w, nil, perr.code, perr.message)
+		return
+	}
+
+	if req.Method == "" && sess.requests.deliver(req.ID, line) {
+		// A response to a request the server sent, such as an elicitation
+		return
+	}
+
+	if !validID(req.ID) {
+		sendError(w, nil, -32600, "Invalid Request")
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		sendError(w, req.ID, -32600, "Invalid Request")
+		return
+	}
+
+	method := req.Method
+	id := req.ID
+	if string(id) == "null" {
+		// MCP, unlike JSON-RPC, does not allow null IDs. Only a message
+		// without an ID is a notification.
+		sendError(w, nil, -32600, "Invalid Request: id must not be null")
+		return
+	}
+	isNotification := len(id) == 0
+	w = s.logRequest(sess, req, w)
+	// The request is in flight until handleLine returns, or if it hands
+	// the request to the worker pool, until the worker is done.
+	end := func() {}
+	if !isNotification {
+		if !sess.state.requestIDs.start(id) {
+			sendError(w, id, -32600, "Invalid Request: a request with this id is still in flight")
+			return
+		}
+		end = func() { sess.state.requestIDs.end(id) }
+	}
+	dispatched := false
+	defer func() {
+		if !dispatched {
+			end()
+		}
+	}()
+	dispatch := func(p priority, fn func()) bool {
+		dispatched = s.dispatch(sess, p, func() {
+			defer end()
+			fn()
+		})
+		return dispatched
+	}
+	s.counts.requests.Add(1)
+	if knownMethods[method] {
+		metrics.requests.inc(method)
+	} else {
+		metrics.requests.inc("other")
+	}
+
+	if ok, message := sess.lifecycle.admit(method); !ok {
+		if !isNotification {
+			sendError(w, id, -32600, message)
+		}
+		return
+	}
+
+	if isNotification {
+		if method == "exit" {
+			sess.lifecycle.exitRequested()
+			return
+		}
+		if method == "notifications/roots/list_changed" {
+			sess.state.rootsChanged()
+			return
+		}
+		if method != "tools/call" {
+			// Notifications are never answered, and the other methods
+			// have no effect besides their response.
+			return
+		}
+		// A call sent as a notification still runs for its side effects,
+		// but neither its result nor its errors are sent.
+		w = io.Discard
+	}
+
+	if ok, wait := sess.limiter.allow(); !ok {
+		sendErrorData(w, id, codeRateLimited, "Rate limit exceeded for this session",
+			rateLimitData{Scope: "session", RetryAfter: wait.Seconds()})
+		return
+	}
+
+	switch method {
+	case "initialize":
+		// Example: parse protocolVersion and respond with initialization info
+		var params map[string]interface{}
+		_ = json.Unmarshal(req.Params, &params)
+		clientCaps, _ := params["capabilities"].(map[string]interface{})
+		var clientParams struct {
+			Info client.Implementation `json:"clientInfo"`
+		}
+		_ = json.Unmarshal(req.Params, &clientParams)
+		sess.state.initialize(clientParams.Info, clientCaps)
+		s.initialized(sess.ctx, sess.state)
+		clientProtocol, _ := params["protocolVersion"].(string)
+		protocolVersion := clientProtocol
+		if protocolVersion == "" {
+			protocolVersion = "2025-03-08"
+		}
+		sess.lifecycle.setVersion(protocolVersion)
+
+		capabilities := map[string]interface{}{
+			"tools":   map[string]interface{}{},
+			"logging": map[string]interface{}{},
+		}
+		if s.watcher != nil {
+			capabilities["resources"] = map[string]interface{}{"subscribe": true, "listChanged": true}
+		} else if len(s.upstreams) > 0 || s.files != nil || s.diagnostics != nil {
+			capabilities["resources"] = map[string]interface{}{}
+		}
+		if len(s.upstreams) > 0 || s.library != nil {
+			capabilities["prompts"] = map[string]interface{}{}
+		}
+		if len(s.upstreams) > 0 || s.library != nil || len(s.completers) > 0 {
+			capabilities["completions"] = map[string]interface{}{}
+		}
+		info := map[string]string{"name": s.info.name, "version": s.info.version}
+		if s.info.title != "" {
+			info["title"] = s.info.title
+		}
+		result := map[string]interface{}{
+			"protocolVersion": protocolVersion,
+			"serverInfo":      info,
+			"capabilities":    capabilities,
+		}
+		if s.instructions != "" {
+			result["instructions"] = s.instructions
+		}
+		initResponse := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  result,
+		}
+		sendResponse(w, initResponse)
+		if s.watcher != nil {
+			s.watcher.add(sess.state)
+		}
+
+	case "initialized", "notifications/initialized":
+		// No response
+		return
+
+	case "cancelled":
+		// No specific handling
+		return
+
+	case "logging/setLevel":
+		var params struct {
+			Level string `json:"level"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || !logLevels[params.Level] {
+			sendError(w, id, -32602, "Invalid parameters: unknown log level")
+			return
+		}
+		sess.state.setLogLevel(params.Level)
+		sendResponse(w, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  map[string]interface{}{},
+		})
+
+	case "tools/list":
+		result, err := s.toolsListResult(sess)
+		s.sendListResult(w, id, result, err)
+
+	case "resources/list":
+		result, err := s.resourcesListResult()
+		s.sendListResult(w, id, result, err)
+
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+			sendError(w, id, -32602, "Invalid parameters: missing resource URI")
+			return
+		}
+		if params.URI == diagnosticsURI && s.diagnostics != nil {
+			s.sendDiagnostics(w, id)
+			return
+		}
+		if rel, ok := s.localResource(params.URI); ok {
+			if !dispatch(priorityInteractive, func() { s.sendFileResource(w, id, params.URI, rel) }) {
+				sendError(w, id, -32603, "Server is shutting down")
+			}
+			return
+		}
+		u := s.resourceUpstream(params.URI)
+		if u == nil {
+			sendError(w, id, -32602, fmt.Sprintf("Unknown resource: %s", params.URI))
+			return
+		}
+		if !dispatch(priorityInteractive, func() {
+			ctx, cancel := s.upstreamContext(sess.ctx)
+			defer cancel()
+			contents, err := u.client.ReadResource(ctx, params.URI)
+			s.sendUpstreamResult(w, id, map[string]interface{}{"contents": contents}, err)
+		}) {
+			sendError(w, id, -32603, "Server is shutting down")
+		}
+
+	case "resources/subscribe", "resources/unsubscribe":
+		if s.watcher == nil {
+			sendError(w, id, -32601, fmt.Sprintf("Method not found: %s", method))
+			return
+		}
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.URI == "" {
+			sendError(w, id, -32602, "Invalid parameters: missing resource URI")
+			return
+		}
+		if _, ok := s.localResource(params.URI); !ok {
+			sendError(w, id, -32602, fmt.Sprintf("Unknown resource: %s", params.URI))
+			return
+		}
+		if method == "resources/subscribe" {
+			sess.state.subscribe(params.URI)
+		} else {
+			sess.state.unsubscribe(params.URI)
+		}
+		sendResponse(w, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  map[string]interface{}{},
+		})
+
+	case "prompts/list":
+		result, err := s.promptsListResult()
+		s.sendListResult(w, id, result, err)
+
+	case "prompts/get":
+		var params struct {
+			Name      string            `json:"name"`
+			Arguments map[string]string `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			sendError(w, id, -32602, "Invalid parameters: missing prompt name")
+			return
+		}
+		if s.library != nil {
+			if p := s.library.prompt(params.Name); p != nil {
+				sendLibraryPrompt(w, id, p, params.Arguments)
+				return
+			}
+		}
+		u, name := s.promptUpstream(params.Name)
+		if u == nil {
+			sendError(w, id, -32602, fmt.Sprintf("Unknown prompt: %s", params.Name))
+			return
+		}
+		if !dispatch(priorityInteractive, func() {
+			ctx, cancel := s.upstreamContext(sess.ctx)
+			defer cancel()
+			result, err := u.client.GetPrompt(ctx, name, params.Arguments)
+			s.sendUpstreamResult(w, id, result, err)
+		}) {
+			sendError(w, id, -32603, "Server is shutting down")
+		}
+
+	case "completion/complete":
+		s.complete(w, sess, id, req.Params)
+
+	case "shutdown":
+		// The session only accepts exit from now on; requests in flight
+		// still get their responses.
+		sendResponse(w, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  map[string]interface{}{},
+		})
+
+	case "ping":
+		sendResponse(w, map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  map[string]interface{}{},
+		})
+
+	case "health":
+		resp := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  s.healthResult(),
+		}
+		sendResponse(w, resp)
+
+	case "stats":
+		resp := map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      id,
+			"result":  s.statsResult(),
+		}
+		sendResponse(w, resp)
+
+	case "tools/call":
+		var params toolsCallParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			sendError(w, id, -32602, "Invalid parameters")
+			return
+		}
+		if params.Name == "" {
+			sendError(w, id, -32602, "Invalid parameters: missing tool name")
+			return
+		}
+		if params.Arguments == nil {
+			// Arguments are optional; the schema decides what is missing.
+			params.Arguments = map[string]interface{}{}
+		}
+
+		call, callErr := s.prepareToolCall(sess, params.Name, params.Arguments)
+		if callErr != nil {
+			sendErrorData(w, id, callErr.Code, callErr.Message, callErr.Data)
+			return
+		}
+
+		// Execute the tool on the worker pool, until the client gives up
+		deadline := metaDeadline(params.Meta.TimeoutMs, params.Meta.Deadline, time.Now())
+		if !dispatch(priorityTool, func() {
+			s.runToolCall(sess, w, id, call.tool, call.args, call.dryRun, params.Meta.ProgressToken, deadline)
+		}) {
+			sendError(w, id, -32603, "Server is shutting down")
+		}
+
+	default:
+		if !isNotification {
+			sendError(w, id, -32601, fmt.Sprintf("Method not found: %s", method))
+		}
+	}
+}
+
+// preparedCall is a tools/call request that is ready to run.
+type preparedCall struct {
+	tool   MCPTool
+	args   map[string]interface{}
+	dryRun bool
+}
+
+// prepareToolCall finds the named tool among those the session may use,
+// checks the rate limit, coerces, validates, and completes the arguments,
+// and counts the call against the session's budget. It returns the error
+// to answer with if the call cannot run.
+func (s *Server) prepareToolCall(sess *session, name string, args map[string]interface{}) (*preparedCall, *JSONRPCError) {
+	var found MCPTool
+	for _, t := range s.tools {
+		if t.Name() == name && sess.allowsTool(t.Name()) {
+			found = t
+			break
+		}
+	}
+	if found == nil {
+		return nil, &JSONRPCError{Code: -32601, Message: fmt.Sprintf("Method not found: tool '%s' is not available", name)}
+	}
+
+	if ok, wait := s.toolBuckets[found.Name()].allow(); !ok {
+		return nil, &JSONRPCError{Code: codeRateLimited, Message: fmt.Sprintf("Rate limit exceeded for tool '%s'", found.Name()),
+			Data: rateLimitData{Scope: "tool", Tool: found.Name(), RetryAfter: wait.Seconds()}}
+	}
+
+	schema := s.inputSchema(found)
+	if args != nil && matchAny(s.coerce, found.Name()) {
+		if changed := coerceArguments(schema, args); len(changed) > 0 {
+			s.logger.Debug("Coerced tool arguments", "tool", found.Name(), "arguments", changed)
+		}
+	}
+
+	if problems := validateArguments(schema, args); len(problems) > 0 {
+		return nil, &JSONRPCError{Code: -32602, Message: invalidArgumentsMessage(problems), Data: invalidArgumentsData{Errors: problems}}
+	}
+	args = applyDefaults(schema, args)
+	dryRun, args, ok := checkDryRun(found, schema, args)
+	if !ok {
+		return nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid parameter 'dryRun': tool '%s' does not support dry runs", found.Name())}
+	}
+
+	if exceeded := sess.state.usage.start(s.sessionBudget); exceeded != nil {
+		return nil, &JSONRPCError{Code: codeBudgetExceeded, Message: budgetExceededMessage(exceeded), Data: exceeded}
+	}
+	return &preparedCall{tool: found, args: args, dryRun: dryRun}, nil
+}
+
+// runToolCall executes a validated tools/call request and writes its
+// response to w. Destructive tools are confirmed with the client first if
+// the server is configured to. Calls to a tool with a result cache are
+// answered from it when possible. A dry run only asks the tool what the
+// call would do, without confirmation or caching. The tool's context ends
+// at deadline, unless it is zero, when the client stops waiting for the
+// result.
+func (s *Server) runToolCall(sess *session, w io.Writer, id interface{}, t MCPTool, args map[string]interface{}, dryRun bool, progressToken json.RawMessage, deadline time.Time) {
+	ctx := sess.ctx
+	call := &ToolCall{Session: sess.state, Tool: t.Name(), Arguments: args, DryRun: dryRun}
+	if err := s.beforeToolCall(ctx, call); err != nil {
+		sendToolError(w, id, []ToolContent{{Type: "text", Text: "Refused: " + s.redactor.redactString(err.Error())}}, nil)
+		return
+	}
+	finish := func(content []ToolContent, err error, elapsed time.Duration) {
+		call.Content, call.Err, call.Duration = content, err, elapsed
+		sess.state.usage.add(elapsed, 0)
+		s.afterToolCall(ctx, call)
+	}
+
+	cache := s.caches[t.Name()]
+	key, cacheable := "", false
+	if cache != nil && !dryRun {
+		key, cacheable = cacheKey(sess.state.ID(), args)
+	}
+	if cacheable {
+		if cached, ok := cache.get(key); ok {
+			metrics.cacheHits.inc(t.Name())
+			content, structured := sess.adaptResult(withDeprecationWarning(t, cached.Content), cached.Structured)
+			s.chargeResult(sess, content, structured)
+			sendToolResult(w, id, content, structured, s.callMeta(args, content, structured, 0, true))
+			finish(content, nil, 0)
+			return
+		}
+	}
+
+	if s.confirmDestructive && isDestructive(t) && !dryRun {
+		if err := s.confirmCall(ctx, sess, t, args); err != nil {
+			content := []ToolContent{{Type: "text", Text: "Refused: " + err.Error()}}
+			sendToolError(w, id, content, nil)
+			finish(content, err, 0)
+			return
+		}
+	}
+
+	// Partial output is notified only where the transport carries messages
+	// besides the response, as it carries requests to the client.
+	var notify io.Writer
+	if sess.requests != nil {
+		notify = sess.clientWriter()
+	}
+	stream := newToolStream(notify, progressToken, s.maxResultSize)
+	callCtx := context.WithValue(ctx, streamKey{}, stream)
+	callCtx = context.WithValue(callCtx, callSessionKey{}, sess)
+	if s.tasks != nil {
+		callCtx = context.WithValue(callCtx, taskKey{}, &taskStarter{manager: s.tasks, sess: sess, tool: t.Name()})
+	}
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithDeadline(callCtx, deadline)
+		defer cancel()
+	}
+	exec := t
+	if dr, ok := dryRunSupport(t); ok && dryRun {
+		exec = dryRunner{MCPTool: t, dr: dr}
+	}
+	start := time.Now()
+	result, err := s.callTool(callCtx, exec, args)
+	resultContent := result.Content
+	elapsed := time.Since(start)
+	partial := stream.close()
+	metrics.toolDuration.observe(t.Name(), elapsed.Seconds())
+	metrics.tools.record(t.Name(), elapsed, err != nil)
+	s.stats.record(t.Name(), elapsed, err != nil)
+	if errors.Is(err, context.DeadlineExceeded) {
+		message := fmt.Sprintf("Request timed out after %s", s.toolTimeout(t))
+		by, limit := "server", s.toolTimeout(t)
+		if d, ok := callCtx.Deadline(); ok && (s.toolTimeout(t) == 0 || d.Before(start.Add(s.toolTimeout(t)))) {
+			message = "Request timed out at the client's deadline"
+			by, limit = "client", d.Sub(start)
+		}
+		s.recordDiagnostic(sess, args, start, diagnostic{
+			Kind: "timeout", Tool: t.Name(), Message: message,
+			TimeoutMs: float64(limit.Microseconds()) / 1000, DeadlineBy: by,
+		})
+		sendError(w, id, codeRequestTimeout, message)
+		finish(nil, err, elapsed)
+		return
+	}
+	var resultErr *toolResultError
+	if errors.As(err, &resultErr) {
+		content := append([]ToolContent(nil), partial...)
+		for _, c := range resultErr.content {
+			c.Text = s.redactor.redactString(c.Text)
+			content = append(content, c)
+		}
+		sendToolError(w, id, content, s.callMeta(args, content, nil, elapsed, false))
+		finish(content, err, elapsed)
+		return
+	}
+	if err != nil {
+		var panicErr *toolPanicError
+		if errors.As(err, &panicErr) {
+			s.logger.Error("tool panicked", "tool", t.Name(), "panic", panicErr.value, "stack", string(panicErr.stack))
+			s.recordDiagnostic(sess, args, start, diagnostic{
+				Kind: "panic", Tool: t.Name(), Message: panicErr.Error(), Stack: string(panicErr.stack),
+			})
+		}
+		sendError(w, id, -32603, "Internal error during tool execution")
+		finish(nil, err, elapsed)
+		return
+	}
+
+	if len(partial) > 0 {
+		resultContent = append(append([]ToolContent(nil), partial...), resultContent...)
+	}
+	if s.maxResultSize > 0 {
+		if size, err := resultSize(resultContent, result.Structured); err == nil && size > s.maxResultSize {
+			message := fmt.Sprintf("Result too large: %d bytes exceeds the limit of %d bytes", size, s.maxResultSize)
+			sendErrorData(w, id, codeResultTooLarge, message, sizeLimitData{Size: size, Limit: s.maxResultSize})
+			finish(nil, errors.New(message), elapsed)
+			return
+		}
+	}
+
+	// Notified chunks are part of the result all the same, for a cached
+	// answer and the hooks.
+	complete := resultContent
+	if streamed := stream.all(); len(partial) == 0 && len(streamed) > 0 {
+		complete = append(append([]ToolContent(nil), streamed...), resultContent...)
+	}
+	if cacheable {
+		cache.put(key, ToolResult{Content: complete, Structured: result.Structured})
+	}
+	resultContent, structured := sess.adaptResult(withDeprecationWarning(t, resultContent), result.Structured)
+	s.chargeResult(sess, resultContent, structured)
+	sendToolResult(w, id, resultContent, structured, s.callMeta(args, resultContent, structured, elapsed, false))
+	finish(complete, nil, elapsed)
+}
+
+// resultSize returns the encoded size of a tool result's content and
+// structured content.
+func resultSize(content []ToolContent, structured interface{}) (int, error) {
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return 0, err
+	}
+	size := len(encoded)
+	if structured != nil {
+		if encoded, err = json.Marshal(structured); err != nil {
+			return 0, err
+		}
+		size += len(encoded)
+	}
+	return size, nil
+}
+
+// chargeResult adds the size of a result sent to the session's usage, if
+// its budget limits result sizes.
+func (s *Server) chargeResult(sess *session, content []ToolContent, structured interface{}) {
+	if s.sessionBudget.ResultBytes == 0 {
+		return
+	}
+	if size, err := resultSize(content, structured); err == nil {
+		sess.state.usage.add(0, size)
+	}
+}
+
+// sendToolResult writes a successful tools/call response, with the
+// structured content and meta if they are not nil.
+func sendToolResult(w io.Writer, id interface{}, content []ToolContent, structured interface{}, meta *resultMeta) {
+	result := map[string]interface{}{
+		"content": content,
+	}
+	if structured != nil {
+		result["structuredContent"] = structured
+	}
+	if meta != nil {
+		result["_meta"] = meta
+	}
+	callResp := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  result,
+	}
+	sendResponse(w, callResp)
+}
+
+// sendToolError writes a tools/call response flagged as an error, which
+// the client shows to the model rather than treating as a protocol error.
+// The result has meta if it is not nil.
+func sendToolError(w io.Writer, id interface{}, content []ToolContent, meta *resultMeta) {
+	result := map[string]interface{}{
+		"content": content,
+		"isError": true,
+	}
+	if meta != nil {
+		result["_meta"] = meta
+	}
+	sendResponse(w, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"result":  result,
+	})
+}
+
+// toolTimeout returns the time limit for a call to t.
+func (s *Server) toolTimeout(t MCPTool) time.Duration {
+	if tt, ok := t.(TimeoutTool); ok && tt.Timeout() > 0 {
+		return tt.Timeout()
+	}
+	return s.requestTimeout
+}
+
+// callTool executes t with its time limit applied, waiting first for a free
+// slot if t limits its concurrency. Time spent waiting counts against the
+// limit, and the slot is held until t actually returns, even if the call
+// has already timed out.
+func (s *Server) callTool(ctx context.Context, t MCPTool, args map[string]interface{}) (ToolResult, error) {
+	if err := ctx.Err(); err != nil {
+		// The client gave up before the call started.
+		return ToolResult{}, err
+	}
+	if timeout := s.toolTimeout(t); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	release := func() {}
+	if slot := s.toolSlot(t); slot != nil {
+		select {
+		case slot <- struct{}{}:
+			release = func() { <-slot }
+		case <-ctx.Done():
+			return ToolResult{}, ctx.Err()
+		}
+	}
+	return executeTool(ctx, t, args, release)
+}
+
+// toolSlot returns the semaphore limiting concurrent calls to t, or nil if
+// t is not limited.
+func (s *Server) toolSlot(t MCPTool) chan struct{} {
+	cl, ok := t.(ConcurrencyLimitedTool)
+	if !ok || cl.MaxConcurrency() <= 0 {
+		return nil
+	}
+	s.slotsMu.Lock()
+	defer s.slotsMu.Unlock()
+	if s.slots == nil {
+		s.slots = map[string]chan struct{}{}
+	}
+	slot, ok := s.slots[t.Name()]
+	if !ok {
+		slot = make(chan struct{}, cl.MaxConcurrency())
+		s.slots[t.Name()] = slot
+	}
+	return slot
+}
+
+// toolPanicError is returned by executeTool when the tool panics.
+type toolPanicError struct {
+	value interface{}
+	stack []byte
+}
+
+// Error implements the error interface.
+func (e *toolPanicError) Error() string {
+	return fmt.Sprintf("tool panicked: %v", e.value)
+}
+
+// toolResultError is returned by a tool whose call failed in a way the
+// model should see, such as a command exiting with an error status.
+// runToolCall answers with its content as a result flagged as an error, with
+// secrets redacted, instead of an internal error.
+type toolResultError struct {
+	content []ToolContent
+}
+
+// toolFailure returns a toolResultError with a single text.
+func toolFailure(format string, args ...interface{}) error {
+	return &toolResultError{content: []ToolContent{{Type: "text", Text: fmt.Sprintf(format, args...)}}}
+}
+
+// Error implements the error interface.
+func (e *toolResultError) Error() string {
+	var texts []string
+	for _, c := range e.content {
+		texts = append(texts, c.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// executeTool runs t with args and calls release once t returns. If ctx is
+// done before t returns, executeTool returns ctx.Err() without waiting for
+// t; tools implementing ContextTool are expected to observe the cancellation
+// and return promptly. The results of tools implementing ResultTool keep
+// their structured content. A panic in t is recovered and returned as a
+// *toolPanicError.
+func executeTool(ctx context.Context, t MCPTool, args map[string]interface{}, release func()) (ToolResult, error) {
+	type result struct {
+		ToolResult
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer release()
+		var r result
+		defer func() {
+			if v := recover(); v != nil {
+				r.ToolResult, r.err = ToolResult{}, &toolPanicError{value: v, stack: debug.Stack()}
+			}
+			done <- r
+		}()
+		switch tool := t.(type) {
+		case ResultTool:
+			var res *ToolResult
+			if res, r.err = tool.ExecuteResult(ctx, args); res != nil {
+				r.ToolResult = *res
+			}
+		case ContextTool:
+			r.Content, r.err = tool.ExecuteContext(ctx, args)
+		default:
+			r.Content, r.err = t.Execute(args)
+		}
+	}()
+	select {
+	case r := <-done:
+		// Tools outside the server report failures for the model as
+		// ToolErrors.
+		var toolErr *ToolError
+		if errors.As(r.err, &toolErr) {
+			r.err = &toolResultError{content: toolErr.Content}
+		}
+		return r.ToolResult, r.err
+	case <-ctx.Done():
+		return ToolResult{}, ctx.Err()
+	}
+}
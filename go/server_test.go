@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// blockingTool blocks in Execute until release is closed.
+type blockingTool struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingTool() *blockingTool {
+	return &blockingTool{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (b *blockingTool) Name() string        { return "block" }
+func (b *blockingTool) Description() string { return "Blocks until released" }
+func (b *blockingTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (b *blockingTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	close(b.started)
+	<-b.release
+	return []ToolContent{{Type: "text", Text: "released"}}, nil
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// testHandshake is the initialization the test helpers perform before the
+// messages of a test. The client declares every capability the server can
+// use. The response, with the id "init", is left out of the output the
+// helpers return.
+const testHandshake = `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{"elicitation":{}}},"id":"init"}` + "\n" +
+	`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n"
+
+// withHandshake prepends testHandshake to input unless input starts with
+// its own initialize request.
+func withHandshake(input string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(input), "\n")
+	if strings.Contains(first, `"method":"initialize"`) {
+		return input
+	}
+	return testHandshake + input
+}
+
+// handshakeFilter drops the response to testHandshake from the messages
+// written to w. Sessions write one whole message per call.
+type handshakeFilter struct {
+	w io.Writer
+}
+
+func (f handshakeFilter) Write(p []byte) (int, error) {
+	if bytes.HasPrefix(p, []byte(`{"id":"init",`)) {
+		return len(p), nil
+	}
+	return f.w.Write(p)
+}
+
+// serveInBackground starts Serve on a pipe, performs testHandshake, and
+// returns the write end of the pipe, the output buffer, and a channel that
+// receives Serve's result.
+func serveInBackground(ctx context.Context, s *Server) (*io.PipeWriter, *syncBuffer, <-chan error) {
+	pr, pw := io.Pipe()
+	out := &syncBuffer{}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- s.Serve(ctx, pr, handshakeFilter{out})
+	}()
+	io.WriteString(pw, testHandshake)
+	return pw, out, errc
+}
+
+// Test that cancellation waits for the in-flight request and flushes its response
+func TestServeDrainsOnShutdown(t *testing.T) {
+	tool := newBlockingTool()
+	ctx, cancel := context.WithCancel(context.Background())
+	pw, out, errc := serveInBackground(ctx, NewServer(WithTools(tool), WithDrainTimeout(time.Second)))
+	defer pw.Close()
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"block","arguments":{}},"id":1}`)
+	<-tool.started
+	cancel()
+	close(tool.release)
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after shutdown")
+	}
+	if !strings.Contains(out.String(), "released") {
+		t.Errorf("expected the in-flight response to be written, got %q", out.String())
+	}
+}
+
+// Test that shutdown gives up once the drain timeout elapses
+func TestServeDrainTimeout(t *testing.T) {
+	tool := newBlockingTool()
+	defer close(tool.release)
+	ctx, cancel := context.WithCancel(context.Background())
+	pw, _, errc := serveInBackground(ctx, NewServer(WithTools(tool), WithDrainTimeout(10*time.Millisecond)))
+	defer pw.Close()
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"block","arguments":{}},"id":1}`)
+	<-tool.started
+	cancel()
+
+	select {
+	case err := <-errc:
+		if err != errDrainTimeout {
+			t.Fatalf("expected errDrainTimeout, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after the drain timeout")
+	}
+}
+
+// Test that requests still running at the drain timeout are cancelled
+func TestServeDrainCancels(t *testing.T) {
+	tool := &hangingTool{cancelled: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	pw, out, errc := serveInBackground(ctx, NewServer(WithTools(tool), WithDrainTimeout(10*time.Millisecond)))
+	defer pw.Close()
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"hang","arguments":{}},"id":1}`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"ping","id":2}`)
+	waitForOutput(t, out, regexp.MustCompile(`"id":2`), 1)
+	cancel()
+
+	if err := <-errc; err != errDrainTimeout {
+		t.Fatalf("expected errDrainTimeout, got %v", err)
+	}
+	select {
+	case <-tool.cancelled:
+	case <-time.After(time.Second):
+		t.Error("expected the tool's context to be cancelled")
+	}
+}
+
+// breakingWriter fails every write once broken is set, as a pipe does when
+// its reader is gone.
+type breakingWriter struct {
+	syncBuffer
+	broken atomic.Bool
+}
+
+func (b *breakingWriter) Write(p []byte) (int, error) {
+	if b.broken.Load() {
+		return 0, errors.New("broken pipe")
+	}
+	return b.syncBuffer.Write(p)
+}
+
+// Test that in-flight requests are cancelled once the client cannot be
+// written to
+func TestServeCancelsOnDisconnect(t *testing.T) {
+	tool := &hangingTool{cancelled: make(chan struct{})}
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	out := &breakingWriter{}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- NewServer(WithTools(tool)).Serve(context.Background(), pr, handshakeFilter{out})
+	}()
+
+	io.WriteString(pw, testHandshake)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"hang","arguments":{}},"id":1}`)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"ping","id":2}`)
+	waitForOutput(t, &out.syncBuffer, regexp.MustCompile(`"id":2`), 1)
+	out.broken.Store(true)
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"ping","id":3}`)
+
+	select {
+	case <-tool.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the tool's context to be cancelled")
+	}
+	if err := <-errc; !errors.Is(err, errWriteFailed) || !strings.HasSuffix(err.Error(), "broken pipe") {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
+
+// Test that Serve returns cleanly at EOF
+func TestServeEOF(t *testing.T) {
+	var out bytes.Buffer
+	err := NewServer().Serve(context.Background(), strings.NewReader(""), &out)
+	if err != nil {
+		t.Fatalf("expected nil error at EOF, got %v", err)
+	}
+}
+
+// hangingTool waits for its context to be cancelled and records that it was.
+type hangingTool struct {
+	cancelled chan struct{}
+}
+
+func (h *hangingTool) Name() string        { return "hang" }
+func (h *hangingTool) Description() string { return "Never finishes on its own" }
+func (h *hangingTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (h *hangingTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	select {}
+}
+func (h *hangingTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	<-ctx.Done()
+	close(h.cancelled)
+	return nil, ctx.Err()
+}
+
+// Test that a request exceeding the request timeout gets a timeout error and a cancelled context
+func TestRequestTimeout(t *testing.T) {
+	tool := &hangingTool{cancelled: make(chan struct{})}
+	s := NewServer(WithTools(tool), WithRequestTimeout(20*time.Millisecond))
+	var out bytes.Buffer
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"hang","arguments":{}},"id":7}`
+	if err := s.Serve(context.Background(), strings.NewReader(withHandshake(input)), handshakeFilter{&out}); err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+
+	var errResp JSONRPCErrorResponse
+	if err := json.Unmarshal(out.Bytes(), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if errResp.Error.Code != codeRequestTimeout {
+		t.Errorf("expected code=%d, got %d", codeRequestTimeout, errResp.Error.Code)
+	}
+	select {
+	case <-tool.cancelled:
+	case <-time.After(time.Second):
+		t.Error("expected the tool's context to be cancelled")
+	}
+}
+
+// limitedTool declares its own timeout and concurrency limit and records
+// the highest number of concurrent executions it observed.
+type limitedTool struct {
+	timeout time.Duration
+	limit   int
+	delay   time.Duration
+
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (l *limitedTool) Name() string           { return "limited" }
+func (l *limitedTool) Description() string    { return "Sleeps for a while" }
+func (l *limitedTool) Timeout() time.Duration { return l.timeout }
+func (l *limitedTool) MaxConcurrency() int    { return l.limit }
+func (l *limitedTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (l *limitedTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	l.mu.Lock()
+	l.running++
+	if l.running > l.peak {
+		l.peak = l.running
+	}
+	l.mu.Unlock()
+	time.Sleep(l.delay)
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+	return []ToolContent{{Type: "text", Text: "done"}}, nil
+}
+
+// Test that a tool's own timeout takes precedence over the request timeout
+func TestToolTimeout(t *testing.T) {
+	tool := &limitedTool{timeout: 10 * time.Millisecond, delay: time.Second}
+	s := NewServer(WithTools(tool), WithRequestTimeout(time.Hour))
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"limited","arguments":{}},"id":1}`)
+
+	var errResp JSONRPCErrorResponse
+	if err := json.Unmarshal([]byte(lines[0]), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if errResp.Error.Code != codeRequestTimeout || !strings.Contains(errResp.Error.Message, "10ms") {
+		t.Errorf("expected a 10ms timeout error, got %+v", errResp.Error)
+	}
+}
+
+// Test that concurrent calls never exceed a tool's concurrency limit
+func TestToolConcurrencyLimit(t *testing.T) {
+	tool := &limitedTool{limit: 2, delay: 20 * time.Millisecond}
+	s := NewServer(WithTools(tool))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.callTool(context.Background(), tool, nil); err != nil {
+				t.Errorf("callTool error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if tool.peak > 2 {
+		t.Errorf("expected at most 2 concurrent executions, got %d", tool.peak)
+	}
+}
+
+// runServerInput feeds input to s, after testHandshake unless input
+// initializes the session itself, and returns all lines of output.
+func runServerInput(t *testing.T, s *Server, input string) []string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(context.Background(), strings.NewReader(withHandshake(input)), handshakeFilter{&out}); err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(out.String()), "\n")
+}
+
+// Test that a slow tool call does not block other requests
+func TestConcurrentRequests(t *testing.T) {
+	tool := newBlockingTool()
+	pw, out, errc := serveInBackground(context.Background(), NewServer(WithTools(tool)))
+
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"block","arguments":{}},"id":1}`)
+	<-tool.started
+	fmt.Fprintln(pw, `{"jsonrpc":"2.0","method":"tools/list","id":2}`)
+
+	deadline := time.After(2 * time.Second)
+	for !strings.Contains(out.String(), `"id":2`) {
+		select {
+		case <-deadline:
+			t.Fatal("tools/list was blocked by the in-flight tool call")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	close(tool.release)
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "released") {
+		t.Errorf("expected the tool response to follow the tools/list response, got %q", lines)
+	}
+}
+
+// panickingTool panics whenever it is executed.
+type panickingTool struct{}
+
+func (p *panickingTool) Name() string        { return "panic" }
+func (p *panickingTool) Description() string { return "Always panics" }
+func (p *panickingTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (p *panickingTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	panic("boom")
+}
+
+// Test that a panicking tool produces an error response and a logged stack trace
+func TestToolPanicRecovery(t *testing.T) {
+	var logBuf syncBuffer
+	s := NewServer(WithTools(&panickingTool{}), WithLogger(slog.New(slog.NewTextHandler(&logBuf, nil))))
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"panic","arguments":{}},"id":1}
+{"jsonrpc":"2.0","method":"tools/list","id":2}`
+	lines := runServerInput(t, s, input)
+
+	if len(lines) != 2 {
+		t.Fatalf("expected the server to keep serving after the panic, got %d lines", len(lines))
+	}
+	for _, line := range lines {
+		var errResp JSONRPCErrorResponse
+		if err := json.Unmarshal([]byte(line), &errResp); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		if errResp.ID == float64(1) && errResp.Error.Code != -32603 {
+			t.Errorf("expected code=-32603 for the panicking call, got %d", errResp.Error.Code)
+		}
+	}
+	if !strings.Contains(logBuf.String(), "tool panicked") || !strings.Contains(logBuf.String(), "panic=boom") {
+		t.Errorf("expected the panic to be logged, got %q", logBuf.String())
+	}
+	if !strings.Contains(logBuf.String(), "goroutine") {
+		t.Errorf("expected a stack trace in the log, got %q", logBuf.String())
+	}
+}
+
+// Test that oversized messages are rejected without stopping the read loop
+func TestMaxMessageSize(t *testing.T) {
+	big := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"` + strings.Repeat("a", 8192) + `"}},"id":1}`
+	s := NewServer(WithMaxMessageSize(1024))
+	lines := runServerInput(t, s, big+"\n"+`{"jsonrpc":"2.0","method":"tools/list","id":2}`)
+
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines output, got %d lines", len(lines))
+	}
+	var errResp JSONRPCErrorResponse
+	if err := json.Unmarshal([]byte(lines[0]), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if errResp.Error.Code != -32600 || !strings.Contains(errResp.Error.Message, "too large") {
+		t.Errorf("expected a message too large error, got %+v", errResp.Error)
+	}
+	if !strings.Contains(lines[1], `"id":2`) {
+		t.Errorf("expected the following request to be answered, got %q", lines[1])
+	}
+}
+
+// Test that oversized tool results are replaced by an error
+func TestMaxResultSize(t *testing.T) {
+	s := NewServer(WithMaxResultSize(64))
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"`+strings.Repeat("a", 100)+`"}},"id":1}`)
+
+	var errResp JSONRPCErrorResponse
+	if err := json.Unmarshal([]byte(lines[0]), &errResp); err != nil {
+		t.Fatalf("failed to unmarshal error response: %v", err)
+	}
+	if errResp.Error.Code != codeResultTooLarge {
+		t.Errorf("expected code=%d, got %d", codeResultTooLarge, errResp.Error.Code)
+	}
+}
+
+// statsTool answers with structured content.
+type statsTool struct{}
+
+func (statsTool) Name() string        { return "stats" }
+func (statsTool) Description() string { return "Reports counts" }
+func (statsTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (statsTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return nil, errors.New("ExecuteResult is expected to be called")
+}
+func (statsTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	return mcp.NewResult().WithStructured(map[string]int{"count": 2}), nil
+}
+
+// Test that the structured content of results is sent, also for renamed
+// tools
+func TestStructuredResult(t *testing.T) {
+	tools, err := registerTools([]toolSource{{name: "test", tools: []MCPTool{statsTool{}}}}, map[string]string{"stats": "counts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := runServerInput(t, NewServer(WithTools(tools...)), `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"counts"},"id":1}`+"\n")
+	want := `{"id":1,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"{\"count\":2}"}],"structuredContent":{"count":2}}}`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("expected %s, got %q", want, lines)
+	}
+}
+
+// missingTool fails as tools outside the server do, with a ToolError.
+type missingTool struct{ statsTool }
+
+func (missingTool) Name() string { return "missing" }
+func (missingTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	return nil, fmt.Errorf("lookup: %w", &ToolError{Content: []ToolContent{mcp.NewTextContent("No such record")}})
+}
+
+// Test that a ToolError is answered as a result flagged as an error
+func TestToolErrorResult(t *testing.T) {
+	lines := runServerInput(t, NewServer(WithTools(missingTool{})), `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"missing"},"id":1}`+"\n")
+	want := `{"id":1,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"No such record"}],"isError":true}}`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("expected %s, got %q", want, lines)
+	}
+}
+
+// writeRecorder records each call to Write separately.
+type writeRecorder struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (r *writeRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.writes = append(r.writes, string(p))
+	return len(p), nil
+}
+
+// Test that concurrently sent messages reach the writer whole, one write
+// each, and that a message that cannot be encoded writes nothing
+func TestMessageWriter(t *testing.T) {
+	rec := &writeRecorder{}
+	mw := newMessageWriter(rec)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sendResponse(mw, map[string]interface{}{"id": i, "text": strings.Repeat("x", i*1000)})
+		}(i)
+	}
+	wg.Wait()
+	if len(rec.writes) != 50 {
+		t.Fatalf("expected 50 writes, got %d", len(rec.writes))
+	}
+	for _, w := range rec.writes {
+		if !strings.HasSuffix(w, "}\n") || strings.Count(w, "\n") != 1 || !json.Valid([]byte(w)) {
+			t.Fatalf("write is not one whole message: %.80q", w)
+		}
+	}
+
+	if n, err := mw.send(map[string]interface{}{"bad": make(chan int)}); n != 0 || err == nil {
+		t.Errorf("expected an encoding error and no output, got %d, %v", n, err)
+	}
+	if len(rec.writes) != 50 {
+		t.Errorf("expected nothing written for an unencodable message")
+	}
+}
+
+// Test that notifications are never answered, that calls sent as
+// notifications still run, and that null IDs are rejected
+func TestNotifications(t *testing.T) {
+	tool := &countingTool{}
+	lines := runServerInput(t, NewServer(WithTools(tool)), strings.Join([]string{
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"lookup","arguments":{}}}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"lookup","arguments":{"fail":true}}}`,
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"missing","arguments":{}}}`,
+		`{"jsonrpc":"2.0","method":"tools/list"}`,
+		`{"jsonrpc":"2.0","method":"ping"}`,
+		`{"jsonrpc":"2.0","method":"no/such/method"}`,
+		`{"jsonrpc":"2.0","method":"ping","id":null}`,
+	}, "\n")+"\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request: id must not be null"}}`) {
+		t.Errorf("expected only the error for the null ID, got %q", lines)
+	}
+	if n := tool.calls.Load(); n != 2 {
+		t.Errorf("expected both calls to run, got %d", n)
+	}
+}
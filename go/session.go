@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+
+	"mcp-minimal-server-go/client"
+)
+
+// logLevels are the levels logging/setLevel accepts, as in syslog.
+var logLevels = map[string]bool{
+	"debug": true, "info": true, "notice": true, "warning": true,
+	"error": true, "critical": true, "alert": true, "emergency": true,
+}
+
+// logSeverities orders the levels of logLevels from least to most severe.
+var logSeverities = []string{"debug", "info", "notice", "warning", "error", "critical", "alert", "emergency"}
+
+// ErrNoRoots is returned by Session.Roots when the client cannot list
+// roots.
+var ErrNoRoots = errors.New("the client does not support roots")
+
+// Root is a directory or file the client lets the server work in.
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// Session is the state of one client's session: what the client and the
+// server agreed in initialize, the roots and log level the client chose,
+// and Values, where tools and hooks keep state of their own. Tools find the
+// session of a call with SessionFromContext. It is safe for concurrent use.
+type Session struct {
+	// Values holds per-session state of the embedding application, under
+	// keys of its choosing. Values that are io.Closers are closed when the
+	// session ends, once its requests have returned.
+	Values sync.Map
+
+	id        string
+	lifecycle *lifecycle
+	requests  *clientRequests // nil if the transport cannot carry requests to the client
+	toClient  io.Writer       // where requests to the client are written
+
+	mu           sync.Mutex
+	clientInfo   client.Implementation
+	capabilities map[string]interface{}
+	logLevel     string
+	roots        []Root
+	rootsFetched bool // roots holds the client's current list
+	rootsChanges int  // counts list_changed notifications, so a stale answer is not kept
+
+	subscriptions map[string]bool // URIs of the resources the client subscribed to
+
+	usage      budgetUsage // what the session's tool calls consumed
+	requestIDs requestIDs  // of the requests in flight
+}
+
+// newSession returns the session with the given ID. Requests to the
+// client are sent through requests over toClient, if requests is not nil.
+func newSession(id string, l *lifecycle, requests *clientRequests, toClient io.Writer) *Session {
+	return &Session{id: id, lifecycle: l, requests: requests, toClient: toClient}
+}
+
+// sessionKey is the context key of the Session.
+type sessionKey struct{}
+
+// contextWithSession returns a copy of ctx carrying sess.
+func contextWithSession(ctx context.Context, sess *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, sess)
+}
+
+// SessionFromContext returns the session of the request ctx belongs to, or
+// nil if there is none.
+func SessionFromContext(ctx context.Context) *Session {
+	sess, _ := ctx.Value(sessionKey{}).(*Session)
+	return sess
+}
+
+// ID returns the session's ID: for HTTP sessions, the one the client
+// sends in the Mcp-Session-Id header.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// ProtocolVersion returns the protocol version agreed in initialize, or ""
+// before it.
+func (s *Session) ProtocolVersion() string {
+	s.lifecycle.mu.Lock()
+	defer s.lifecycle.mu.Unlock()
+	return s.lifecycle.version
+}
+
+// ClientInfo returns the name and version the client reported in
+// initialize.
+func (s *Session) ClientInfo() client.Implementation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clientInfo
+}
+
+// ClientCapabilities returns the capabilities the client declared in
+// initialize. The result must not be modified.
+func (s *Session) ClientCapabilities() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.capabilities
+}
+
+// SupportsSampling reports whether the client can be asked to sample its
+// model with sampling/createMessage.
+func (s *Session) SupportsSampling() bool {
+	return s.supports("sampling")
+}
+
+// SupportsRoots reports whether the client can be asked for its roots.
+func (s *Session) SupportsRoots() bool {
+	return s.supports("roots")
+}
+
+// SupportsElicitation reports whether the client can be asked for input
+// from the user with elicitation/create. Tools that would ask can check it
+// to do without, rather than fail, with clients that cannot.
+func (s *Session) SupportsElicitation() bool {
+	return s.supports("elicitation")
+}
+
+// supports reports whether the client declared the capability name in
+// initialize and the transport can still carry requests to it.
+func (s *Session) supports(name string) bool {
+	s.mu.Lock()
+	_, declared := s.capabilities[name]
+	s.mu.Unlock()
+	return declared && s.requests.open()
+}
+
+// LogLevel returns the least severe level of log messages the client asked
+// for with logging/setLevel, or "" if it has not.
+func (s *Session) LogLevel() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logLevel
+}
+
+// log sends the client a notifications/message of level unless it asked
+// for more severe messages only, and reports whether it did. Sessions that
+// cannot be sent messages, such as those of the http transport, are never
+// sent any.
+func (s *Session) log(level, logger string, data interface{}) bool {
+	if s.toClient == nil {
+		return false
+	}
+	if min := s.LogLevel(); min != "" && slices.Index(logSeverities, level) < slices.Index(logSeverities, min) {
+		return false
+	}
+	sendNotification(s.toClient, "notifications/message", map[string]interface{}{"level": level, "logger": logger, "data": data})
+	return true
+}
+
+// Roots returns the roots of the client, asking it for them the first
+// time and again after it reports that they changed. It returns ErrNoRoots
+// if the client did not declare the roots capability or the transport
+// cannot ask it.
+func (s *Session) Roots(ctx context.Context) ([]Root, error) {
+	if !s.SupportsRoots() {
+		return nil, ErrNoRoots
+	}
+	s.mu.Lock()
+	roots, fetched, changes := s.roots, s.rootsFetched, s.rootsChanges
+	s.mu.Unlock()
+	if fetched {
+		return roots, nil
+	}
+	raw, err := s.requests.call(ctx, s.toClient, "roots/list", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Roots []Root `json:"roots"`
+	}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.rootsChanges == changes {
+		s.roots, s.rootsFetched = result.Roots, true
+	}
+	s.mu.Unlock()
+	return result.Roots, nil
+}
+
+// initialize records what the client sent in initialize.
+func (s *Session) initialize(info client.Implementation, capabilities map[string]interface{}) {
+	s.mu.Lock()
+	s.clientInfo, s.capabilities = info, capabilities
+	s.mu.Unlock()
+}
+
+// setLogLevel records the level the client asked for.
+func (s *Session) setLogLevel(level string) {
+	s.mu.Lock()
+	s.logLevel = level
+	s.mu.Unlock()
+}
+
+// release closes the values that are io.Closers and forgets all values and
+// the roots, as the session ends.
+func (s *Session) release(logger *slog.Logger) {
+	s.Values.Range(func(key, value interface{}) bool {
+		if c, ok := value.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				logger.Warn("closing session value failed", "session", s.id, "key", key, "error", err)
+			}
+		}
+		s.Values.Delete(key)
+		return true
+	})
+	s.mu.Lock()
+	s.roots, s.rootsFetched = nil, false
+	s.mu.Unlock()
+}
+
+// rootsChanged makes the next call to Roots ask the client again.
+func (s *Session) rootsChanged() {
+	s.mu.Lock()
+	s.roots, s.rootsFetched = nil, false
+	s.rootsChanges++
+	s.mu.Unlock()
+}
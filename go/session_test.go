@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"mcp-minimal-server-go/client"
+	"mcp-minimal-server-go/mcpmock"
+)
+
+// sessionTool reports what it finds in the session of its call.
+type sessionTool struct{}
+
+func (sessionTool) Name() string        { return "whoami" }
+func (sessionTool) Description() string { return "Describes the session" }
+func (sessionTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (t sessionTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+func (sessionTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	sess := SessionFromContext(ctx)
+	if sess == nil {
+		return nil, errors.New("no session")
+	}
+	calls := 1
+	if v, loaded := sess.Values.LoadOrStore("calls", calls); loaded {
+		calls = v.(int) + 1
+		sess.Values.Store("calls", calls)
+	}
+	var uris []string
+	roots, err := sess.Roots(ctx)
+	if err != nil {
+		uris = append(uris, err.Error())
+	}
+	for _, r := range roots {
+		uris = append(uris, r.URI)
+	}
+	info := sess.ClientInfo()
+	text := fmt.Sprintf("%s %s/%s level=%s calls=%d roots=%s", sess.ProtocolVersion(), info.Name, info.Version,
+		sess.LogLevel(), calls, strings.Join(uris, ","))
+	return []ToolContent{{Type: "text", Text: text}}, nil
+}
+
+// Test that tools see the session's state, and that roots are asked for
+// once until the client reports a change
+func TestSession(t *testing.T) {
+	c := mcpmock.New(NewServer(WithTools(sessionTool{})).Serve,
+		mcpmock.Respond("roots/list", map[string]interface{}{"roots": []Root{{URI: "file:///work", Name: "work"}}}),
+		mcpmock.Expect("roots/list", 2),
+	)
+	ctx := context.Background()
+	result, err := c.Initialize(ctx)
+	if err != nil {
+		t.Fatalf("Initialize error: %v", err)
+	}
+	if _, ok := result.Capabilities["logging"]; !ok {
+		t.Errorf("expected the logging capability, got %v", result.Capabilities)
+	}
+	if err := c.Call(ctx, "logging/setLevel", map[string]string{"level": "loud"}, nil); err == nil || !strings.Contains(err.Error(), "unknown log level") {
+		t.Errorf("expected an invalid level to be rejected, got %v", err)
+	}
+	if err := c.Call(ctx, "logging/setLevel", map[string]string{"level": "warning"}, nil); err != nil {
+		t.Fatalf("logging/setLevel error: %v", err)
+	}
+
+	call := func() string {
+		t.Helper()
+		result, err := c.CallTool(ctx, "whoami", map[string]interface{}{})
+		if err != nil || result.IsError || len(result.Content) != 1 {
+			t.Fatalf("unexpected result %+v, %v", result, err)
+		}
+		return result.Content[0].Text
+	}
+	for i, want := range []string{
+		client.ProtocolVersion + " mcpmock/0.1.0 level=warning calls=1 roots=file:///work",
+		client.ProtocolVersion + " mcpmock/0.1.0 level=warning calls=2 roots=file:///work",
+	} {
+		if got := call(); got != want {
+			t.Errorf("call %d: expected %q, got %q", i+1, want, got)
+		}
+	}
+	if err := c.Notify(ctx, "notifications/roots/list_changed", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := call(); !strings.HasSuffix(got, "calls=3 roots=file:///work") {
+		t.Errorf("unexpected result after the roots changed: %q", got)
+	}
+	if err := c.Verify(); err != nil {
+		t.Error(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+}
+
+// Test that sessions are separate, and that roots are not asked for when
+// the client cannot list them
+func TestSessionWithoutRoots(t *testing.T) {
+	s := NewServer(WithTools(sessionTool{}))
+	input := `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-03-26","clientInfo":{"name":"cli","version":"2"},"capabilities":{}},"id":1}` + "\n" +
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\n" +
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"whoami","arguments":{}},"id":2}` + "\n"
+	for i := 0; i < 2; i++ {
+		lines := runServerInput(t, s, input)
+		want := `"text":"2025-03-26 cli/2 level= calls=1 roots=` + ErrNoRoots.Error() + `"`
+		if len(lines) != 2 || !strings.Contains(lines[1], want) {
+			t.Errorf("session %d: expected %s, got %v", i+1, want, lines)
+		}
+	}
+}
+
+// closerFunc is an io.Closer calling itself.
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// Test that the values of a session are closed after its end hooks
+func TestSessionRelease(t *testing.T) {
+	var events []string
+	s := NewServer(WithHooks(Hooks{
+		OnSessionStart: func(ctx context.Context, sess *Session) {
+			sess.Values.Store("conn", closerFunc(func() error {
+				events = append(events, "close")
+				return nil
+			}))
+			sess.Values.Store("name", "not a closer")
+		},
+		OnSessionEnd: func(sess *Session) {
+			if _, ok := sess.Values.Load("conn"); ok {
+				events = append(events, "end")
+			}
+		},
+	}))
+	runServerInput(t, s, `{"jsonrpc":"2.0","method":"ping","id":1}`)
+	if got := strings.Join(events, ", "); got != "end, close" {
+		t.Errorf("expected the end hook to see the values before they are closed, got %s", got)
+	}
+}
+
+// capabilityTool reports which requests its session can send the client.
+type capabilityTool struct{ sessionTool }
+
+func (capabilityTool) Name() string { return "capabilities" }
+func (capabilityTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	sess := SessionFromContext(ctx)
+	text := fmt.Sprintf("sampling=%t roots=%t elicitation=%t", sess.SupportsSampling(), sess.SupportsRoots(), sess.SupportsElicitation())
+	return []ToolContent{{Type: "text", Text: text}}, nil
+}
+
+// Test that sessions tell which capabilities the client declared
+func TestSessionSupports(t *testing.T) {
+	c := mcpmock.New(NewServer(WithTools(capabilityTool{})).Serve,
+		mcpmock.Respond("roots/list", map[string]interface{}{"roots": []Root{}}),
+		mcpmock.Respond("sampling/createMessage", map[string]interface{}{}),
+	)
+	ctx := context.Background()
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err := c.CallTool(ctx, "capabilities", map[string]interface{}{})
+	if want := "sampling=true roots=true elicitation=false"; err != nil || result.Content[0].Text != want {
+		t.Errorf("expected %q, got %+v, %v", want, result, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+
+	c = mcpmock.New(NewServer(WithTools(capabilityTool{})).Serve,
+		mcpmock.Respond("elicitation/create", map[string]interface{}{"action": "decline"}),
+	)
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	result, err = c.CallTool(ctx, "capabilities", map[string]interface{}{})
+	if want := "sampling=false roots=false elicitation=true"; err != nil || result.Content[0].Text != want {
+		t.Errorf("expected %q, got %+v, %v", want, result, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+}
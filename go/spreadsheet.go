@@ -0,0 +1,627 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of read_spreadsheet.
+const (
+	maxSpreadsheetSize     = 32 << 20
+	maxSpreadsheetPart     = 128 << 20 // uncompressed bytes of a part of the file
+	defaultSpreadsheetRows = 100
+	maxSpreadsheetRows     = 1000
+	maxSpreadsheetCols     = 16384 // XFD, the last column of Excel
+)
+
+// readSpreadsheetTool lists the sheets of .xlsx workbooks in ws and reads
+// ranges of their cells as rows of JSON values.
+type readSpreadsheetTool struct {
+	ws *Workspace
+}
+
+// withSpreadsheetWorkspace returns a copy of list in which read_spreadsheet
+// reads files in ws.
+func withSpreadsheetWorkspace(list []MCPTool, ws *Workspace) []MCPTool {
+	out := make([]MCPTool, len(list))
+	for i, t := range list {
+		if _, ok := t.(*readSpreadsheetTool); ok {
+			t = &readSpreadsheetTool{ws: ws}
+		}
+		out[i] = t
+	}
+	return out
+}
+
+// readSpreadsheetArgs are the arguments of the read_spreadsheet tool.
+type readSpreadsheetArgs struct {
+	Path    string `json:"path" description:"The path of the .xlsx file, relative to the server's spreadsheet directory"`
+	Sheet   string `json:"sheet,omitempty" description:"Name of the sheet to read (default the first); without it and range, the result also lists the sheets"`
+	Range   string `json:"range,omitempty" description:"Cells to read, such as A1:D20, B:B, or 2:10 (default all the sheet's cells)"`
+	Header  bool   `json:"header,omitempty" description:"Use the first row of the range as column names, returning the other rows as objects"`
+	MaxRows int    `json:"max_rows,omitempty" minimum:"1" maximum:"1000" description:"Most rows to return, after the header (default 100)"`
+}
+
+// Name returns the name of the read_spreadsheet tool.
+func (t *readSpreadsheetTool) Name() string {
+	return "read_spreadsheet"
+}
+
+// Description returns a brief description of the read_spreadsheet tool.
+func (t *readSpreadsheetTool) Description() string {
+	return "Reads a range of cells of a sheet of an Excel (.xlsx) workbook as rows of values, numbers and booleans as such and dates as ISO 8601 text, and lists the workbook's sheets"
+}
+
+// InputSchema returns the JSON schema for the read_spreadsheet tool's input
+// parameters.
+func (t *readSpreadsheetTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(readSpreadsheetArgs{})
+}
+
+// Annotations marks the read_spreadsheet tool as read-only.
+func (t *readSpreadsheetTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Read spreadsheet")
+}
+
+// Execute reads the spreadsheet without a deadline of its own.
+func (t *readSpreadsheetTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext reads the spreadsheet and returns the rows as JSON text.
+func (t *readSpreadsheetTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult reads the range of the sheet and returns its rows as
+// structured content.
+func (t *readSpreadsheetTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	a := readSpreadsheetArgs{MaxRows: defaultSpreadsheetRows}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Path == "" {
+		return nil, errors.New("'path' is required")
+	}
+	if a.MaxRows < 1 || a.MaxRows > maxSpreadsheetRows {
+		return nil, fmt.Errorf("invalid value for 'max_rows': expected 1 to %d", maxSpreadsheetRows)
+	}
+	var rng cellRange
+	if a.Range != "" {
+		var err error
+		if rng, err = parseCellRange(a.Range); err != nil {
+			return nil, fmt.Errorf("invalid value for 'range': %v", err)
+		}
+	}
+	if t.ws == nil {
+		return nil, errors.New("reading spreadsheets is disabled; start the server with --spreadsheet-dir")
+	}
+	ws, err := t.ws.For(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("no spreadsheet directory: %w", err)
+	}
+	file, err := ws.Resolve(a.Path, "spreadsheet")
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(file); err != nil {
+		return nil, toolFailure("%s: %v", a.Path, errors.Unwrap(err))
+	} else if info.Size() > maxSpreadsheetSize {
+		return nil, toolFailure("%s is larger than %d bytes", a.Path, maxSpreadsheetSize)
+	}
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, toolFailure("%s is not an .xlsx file: %v", a.Path, err)
+	}
+	defer zr.Close()
+	wb, err := openWorkbook(&zr.Reader)
+	if err != nil {
+		return nil, toolFailure("%s: %v", a.Path, err)
+	}
+	if len(wb.sheets) == 0 {
+		return nil, toolFailure("%s has no sheets", a.Path)
+	}
+	sheet := wb.sheets[0]
+	if a.Sheet != "" {
+		found := false
+		for _, s := range wb.sheets {
+			if s.Name == a.Sheet {
+				sheet, found = s, true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(wb.sheets))
+			for i, s := range wb.sheets {
+				names[i] = s.Name
+			}
+			return nil, fmt.Errorf("invalid value for 'sheet': expected one of %s", strings.Join(names, ", "))
+		}
+	}
+	maxRows := a.MaxRows
+	if a.Header {
+		maxRows++
+	}
+	cells, err := wb.readSheet(sheet, rng, maxRows)
+	if err != nil {
+		return nil, toolFailure("%s: sheet %s: %v", a.Path, sheet.Name, err)
+	}
+
+	result := map[string]interface{}{"sheet": sheet.Name}
+	if a.Sheet == "" && a.Range == "" {
+		result["sheets"] = wb.sheets
+	}
+	rows := cells.rows()
+	if len(rows) > 0 {
+		result["range"] = cells.used.String()
+	}
+	if cells.truncated {
+		result["truncated"] = true
+	}
+	if !a.Header {
+		if rows == nil {
+			rows = [][]interface{}{}
+		}
+		result["rows"] = rows
+		return mcp.NewResult().WithStructured(result), nil
+	}
+	columns := []string{}
+	objects := []map[string]interface{}{}
+	if len(rows) > 0 {
+		for i, v := range rows[0] {
+			name := strings.TrimSpace(fmt.Sprint(v))
+			if v == nil || name == "" {
+				name = columnName(cells.used.minCol + i)
+			}
+			for base, n := name, 2; slices.Contains(columns, name); n++ {
+				name = fmt.Sprintf("%s_%d", base, n)
+			}
+			columns = append(columns, name)
+		}
+		for _, row := range rows[1:] {
+			obj := map[string]interface{}{}
+			for i, v := range row {
+				obj[columns[i]] = v
+			}
+			objects = append(objects, obj)
+		}
+	}
+	result["columns"] = columns
+	result["rows"] = objects
+	return mcp.NewResult().WithStructured(result), nil
+}
+
+// cellRange is a rectangle of cells by 1-based column and row numbers; a
+// zero bound is open.
+type cellRange struct {
+	minCol, minRow, maxCol, maxRow int
+}
+
+// contains reports whether the range has the cell at col and row.
+func (r cellRange) contains(col, row int) bool {
+	return (r.minCol == 0 || col >= r.minCol) && (r.maxCol == 0 || col <= r.maxCol) &&
+		(r.minRow == 0 || row >= r.minRow) && (r.maxRow == 0 || row <= r.maxRow)
+}
+
+// String returns the range in A1 notation.
+func (r cellRange) String() string {
+	return columnName(r.minCol) + strconv.Itoa(r.minRow) + ":" + columnName(r.maxCol) + strconv.Itoa(r.maxRow)
+}
+
+// cellRef matches a cell reference such as B12, either part optional.
+var cellRef = regexp.MustCompile(`^\$?([A-Za-z]{0,3})\$?([0-9]{0,7})$`)
+
+// parseCellRef returns the column and row of a reference; either is 0 if
+// the reference leaves it out.
+func parseCellRef(ref string) (col, row int, err error) {
+	m := cellRef.FindStringSubmatch(ref)
+	if m == nil || ref == "" {
+		return 0, 0, fmt.Errorf("invalid cell %q", ref)
+	}
+	for _, c := range strings.ToUpper(m[1]) {
+		col = col*26 + int(c-'A'+1)
+	}
+	if m[2] != "" {
+		row, _ = strconv.Atoi(m[2])
+		if row == 0 {
+			return 0, 0, fmt.Errorf("invalid cell %q", ref)
+		}
+	}
+	if col > maxSpreadsheetCols {
+		return 0, 0, fmt.Errorf("invalid cell %q", ref)
+	}
+	return col, row, nil
+}
+
+// parseCellRange parses a range such as A1:D20, B:D, 2:10, or a single
+// cell.
+func parseCellRange(s string) (cellRange, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		to = from
+	}
+	c1, r1, err := parseCellRef(from)
+	if err != nil {
+		return cellRange{}, err
+	}
+	c2, r2, err := parseCellRef(to)
+	if err != nil {
+		return cellRange{}, err
+	}
+	if (c1 == 0) != (c2 == 0) || (r1 == 0) != (r2 == 0) || c2 < c1 || r2 < r1 {
+		return cellRange{}, fmt.Errorf("expected a range like A1:D20, got %q", s)
+	}
+	return cellRange{c1, r1, c2, r2}, nil
+}
+
+// columnName returns the letters of the 1-based column col.
+func columnName(col int) string {
+	var name []byte
+	for ; col > 0; col = (col - 1) / 26 {
+		name = append([]byte{byte('A' + (col-1)%26)}, name...)
+	}
+	return string(name)
+}
+
+// workbookSheet is a sheet of a workbook as read_spreadsheet lists it.
+type workbookSheet struct {
+	Name  string `json:"name"`
+	State string `json:"state,omitempty"` // hidden or veryHidden
+	part  string // of the sheet's XML in the file
+}
+
+// workbook is an opened .xlsx file.
+type workbook struct {
+	zr         *zip.Reader
+	sheets     []workbookSheet
+	strings    []string
+	dateStyles map[int]bool // indexes of the cell styles formatting dates
+	date1904   bool
+}
+
+// openWorkbook reads the sheets, shared strings, and date styles of the
+// workbook in zr.
+func openWorkbook(zr *zip.Reader) (*workbook, error) {
+	wb := &workbook{zr: zr, dateStyles: map[int]bool{}}
+	var book struct {
+		Pr struct {
+			Date1904 string `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name  string `xml:"name,attr"`
+			State string `xml:"state,attr"`
+			RID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := wb.decode("xl/workbook.xml", &book); err != nil {
+		return nil, err
+	}
+	wb.date1904 = book.Pr.Date1904 == "1" || book.Pr.Date1904 == "true"
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := wb.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	for _, r := range rels.Rels {
+		if strings.HasPrefix(r.Target, "/") {
+			targets[r.ID] = strings.TrimPrefix(r.Target, "/")
+		} else {
+			targets[r.ID] = path.Join("xl", r.Target)
+		}
+	}
+	for _, s := range book.Sheets {
+		if part, ok := targets[s.RID]; ok {
+			wb.sheets = append(wb.sheets, workbookSheet{Name: s.Name, State: s.State, part: part})
+		}
+	}
+
+	var shared struct {
+		Items []struct {
+			T    string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := wb.decode("xl/sharedStrings.xml", &shared); err != nil && !errors.Is(err, errNoPart) {
+		return nil, err
+	}
+	for _, si := range shared.Items {
+		s := si.T
+		for _, r := range si.Runs {
+			s += r.T
+		}
+		wb.strings = append(wb.strings, s)
+	}
+
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		Xfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := wb.decode("xl/styles.xml", &styles); err != nil && !errors.Is(err, errNoPart) {
+		return nil, err
+	}
+	dateFormats := map[int]bool{}
+	for id := 14; id <= 22; id++ {
+		dateFormats[id] = true
+	}
+	for _, id := range []int{45, 46, 47} {
+		dateFormats[id] = true
+	}
+	for _, f := range styles.NumFmts {
+		dateFormats[f.ID] = isDateFormat(f.Code)
+	}
+	for i, xf := range styles.Xfs {
+		if dateFormats[xf.NumFmtID] {
+			wb.dateStyles[i] = true
+		}
+	}
+	return wb, nil
+}
+
+// errNoPart is returned by workbook.open for parts the file lacks.
+var errNoPart = errors.New("no such part")
+
+// open opens the named part of the file.
+func (wb *workbook) open(name string) (io.ReadCloser, error) {
+	for _, f := range wb.zr.File {
+		if f.Name == name {
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(r, maxSpreadsheetPart), r}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", name, errNoPart)
+}
+
+// decode decodes the named XML part of the file into v.
+func (wb *workbook) decode(name string, v interface{}) error {
+	r, err := wb.open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+// dateFormatCode matches the date and time placeholders of a number
+// format, once its quoted text and bracketed parts are removed.
+var (
+	dateFormatLiteral = regexp.MustCompile(`"[^"]*"|\[[^\]]*\]|\\.`)
+	dateFormatCode    = regexp.MustCompile(`[dmyhs]`)
+)
+
+// isDateFormat reports whether the number format code formats dates or
+// times, such as yyyy-mm-dd or h:mm AM/PM.
+func isDateFormat(code string) bool {
+	return dateFormatCode.MatchString(strings.ToLower(dateFormatLiteral.ReplaceAllString(code, "")))
+}
+
+// sheetCells are the cells of a range of a sheet.
+type sheetCells struct {
+	values    map[[2]int]interface{} // by column and row
+	used      cellRange              // the cells holding values
+	truncated bool                   // rows were left out by maxRows
+}
+
+// rows returns the cells row by row, from the first to the last of the
+// used range, nil for blank cells.
+func (c *sheetCells) rows() [][]interface{} {
+	if len(c.values) == 0 {
+		return nil
+	}
+	var rows [][]interface{}
+	for r := c.used.minRow; r <= c.used.maxRow; r++ {
+		row := make([]interface{}, c.used.maxCol-c.used.minCol+1)
+		for col := c.used.minCol; col <= c.used.maxCol; col++ {
+			row[col-c.used.minCol] = c.values[[2]int{col, r}]
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// readSheet reads the cells of the sheet in rng, from the first row with a
+// value, stopping after maxRows rows. A range's bounds are kept as given,
+// open ones shrinking to the cells with values.
+func (wb *workbook) readSheet(sheet workbookSheet, rng cellRange, maxRows int) (*sheetCells, error) {
+	r, err := wb.open(sheet.part)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	cells := &sheetCells{values: map[[2]int]interface{}{}}
+	d := xml.NewDecoder(r)
+	row, col := 0, 0
+	firstRow := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "row":
+			row, col = row+1, 0
+			if n, err := strconv.Atoi(xmlAttr(start, "r")); err == nil {
+				row = n
+			}
+		case "c":
+			var c struct {
+				Ref   string `xml:"r,attr"`
+				Type  string `xml:"t,attr"`
+				Style int    `xml:"s,attr"`
+				V     string `xml:"v"`
+				Is    struct {
+					T    string `xml:"t"`
+					Runs []struct {
+						T string `xml:"t"`
+					} `xml:"r"`
+				} `xml:"is"`
+			}
+			if err := d.DecodeElement(&c, &start); err != nil {
+				return nil, err
+			}
+			col++
+			if c.Ref != "" {
+				if cc, rr, err := parseCellRef(c.Ref); err == nil && cc > 0 && rr > 0 {
+					col, row = cc, rr
+				}
+			}
+			if !rng.contains(col, row) {
+				continue
+			}
+			inline := c.Is.T
+			for _, r := range c.Is.Runs {
+				inline += r.T
+			}
+			v := wb.cellValue(c.Type, c.Style, c.V, inline)
+			if v == nil {
+				continue
+			}
+			if firstRow == 0 {
+				firstRow = row
+			}
+			if row-firstRow >= maxRows {
+				cells.truncated = true
+				continue
+			}
+			cells.values[[2]int{col, row}] = v
+			u := &cells.used
+			if u.minCol == 0 || col < u.minCol {
+				u.minCol = col
+			}
+			if col > u.maxCol {
+				u.maxCol = col
+			}
+			if u.minRow == 0 || row < u.minRow {
+				u.minRow = row
+			}
+			if row > u.maxRow {
+				u.maxRow = row
+			}
+		}
+	}
+	if len(cells.values) > 0 {
+		// Bounds given by the range are kept, so columns line up.
+		if rng.minCol > 0 {
+			cells.used.minCol, cells.used.maxCol = rng.minCol, rng.maxCol
+		}
+		if rng.minRow > 0 {
+			cells.used.minRow = rng.minRow
+			if end := rng.minRow + maxRows - 1; rng.maxRow < end {
+				cells.used.maxRow = rng.maxRow
+			} else {
+				cells.used.maxRow = end
+			}
+		}
+	}
+	return cells, nil
+}
+
+// xmlAttr returns the value of the named attribute of start.
+func xmlAttr(start xml.StartElement, name string) string {
+	for _, a := range start.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// cellValue returns the value of a cell of type t and style s, given its
+// value v or inline string: a string,
+// float64, bool, an ISO 8601 string for dates, or nil for a blank cell.
+// Errors such as #DIV/0! are returned as their text.
+func (wb *workbook) cellValue(t string, s int, v, inline string) interface{} {
+	switch t {
+	case "s":
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 || i >= len(wb.strings) {
+			return nil
+		}
+		return wb.strings[i]
+	case "inlineStr":
+		return inline
+	case "str", "e":
+		return v
+	case "b":
+		return v == "1" || v == "true"
+	case "d":
+		return v
+	}
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	if wb.dateStyles[s] {
+		return excelDate(n, wb.date1904)
+	}
+	return n
+}
+
+// excelDate returns the serial date n as an ISO 8601 date, time, or both.
+// Serials of the 1900 date system count the nonexistent 29 February 1900.
+func excelDate(n float64, date1904 bool) string {
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else if n < 61 && n >= 1 {
+		base = base.AddDate(0, 0, 1)
+	}
+	days := math.Floor(n)
+	seconds := math.Round((n - days) * 86400)
+	t := base.AddDate(0, 0, int(days)).Add(time.Duration(seconds) * time.Second)
+	switch {
+	case n < 1 && !date1904:
+		return t.Format("15:04:05")
+	case seconds == 0:
+		return t.Format(time.DateOnly)
+	}
+	return t.Format("2006-01-02T15:04:05")
+}
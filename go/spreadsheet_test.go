@@ -0,0 +1,148 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeWorkbook writes an .xlsx file of the given parts, adding the
+// workbook, its relationships, and styles unless parts has its own.
+func writeWorkbook(t *testing.T, path string, parts map[string]string) {
+	t.Helper()
+	defaults := map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Sales" sheetId="1" r:id="rId1"/><sheet name="Notes" sheetId="2" state="hidden" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`,
+		"xl/styles.xml": `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd\ hh:mm"/><numFmt numFmtId="165" formatCode="&quot;day&quot;\ 0.00"/></numFmts>
+<cellXfs count="4"><xf numFmtId="0"/><xf numFmtId="14"/><xf numFmtId="164"/><xf numFmtId="165"/></cellXfs></styleSheet>`,
+	}
+	for name, data := range parts {
+		defaults[name] = data
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range defaults {
+		w, _ := zw.Create(name)
+		w.Write([]byte(data))
+	}
+	zw.Close()
+	f.Close()
+}
+
+// readSpreadsheet calls read_spreadsheet and returns its result as JSON.
+func readSpreadsheet(t *testing.T, tool *readSpreadsheetTool, args map[string]interface{}) string {
+	t.Helper()
+	result, err := tool.ExecuteResult(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(result.Structured)
+	return string(encoded)
+}
+
+// Test that cells are read with their types, in ranges, and as objects
+// under a header row
+func TestReadSpreadsheet(t *testing.T) {
+	dir := t.TempDir()
+	writeWorkbook(t, filepath.Join(dir, "sales.xlsx"), map[string]string{
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>Region</t></si><si><t>Date</t></si><si><t>Total</t></si><si><r><t>North </t></r><r><t>East</t></r></si><si><t>South</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>2</v></c></row>
+<row r="2"><c r="A2" t="s"><v>3</v></c><c r="B2" s="1"><v>46309</v></c><c r="C2"><v>1250.5</v></c><c r="D2" t="b"><v>1</v></c></row>
+<row r="3"><c r="A3" t="s"><v>4</v></c><c r="B3" s="2"><v>46309.5</v></c><c r="C3"><f>C2*2</f><v>2501</v></c><c r="D3" t="e"><v>#DIV/0!</v></c></row>
+<row r="5"><c r="A5" t="inlineStr"><is><t>Total</t></is></c><c r="C5" s="3"><v>3</v></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData><row><c><v>7</v></c><c t="str"><v>seven</v></c></row></sheetData></worksheet>`,
+	})
+	tool := &readSpreadsheetTool{ws: &Workspace{Root: dir}}
+	for _, tc := range []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"path": "sales.xlsx"},
+			`{"range":"A1:D5","rows":[["Region","Date","Total","Total"],["North East","2026-10-14",1250.5,true],["South","2026-10-14T12:00:00",2501,"#DIV/0!"],[null,null,null,null],["Total",null,3,null]],` +
+				`"sheet":"Sales","sheets":[{"name":"Sales"},{"name":"Notes","state":"hidden"}]}`},
+		{map[string]interface{}{"path": "sales.xlsx", "range": "B2:C3"},
+			`{"range":"B2:C3","rows":[["2026-10-14",1250.5],["2026-10-14T12:00:00",2501]],"sheet":"Sales"}`},
+		{map[string]interface{}{"path": "sales.xlsx", "range": "A1:C3", "header": true, "max_rows": 1},
+			`{"columns":["Region","Date","Total"],"range":"A1:C2","rows":[{"Date":"2026-10-14","Region":"North East","Total":1250.5}],"sheet":"Sales","truncated":true}`},
+		{map[string]interface{}{"path": "sales.xlsx", "range": "A:A", "header": true},
+			`{"columns":["Region"],"range":"A1:A5","rows":[{"Region":"North East"},{"Region":"South"},{"Region":null},{"Region":"Total"}],"sheet":"Sales"}`},
+		{map[string]interface{}{"path": "sales.xlsx", "range": "C:D", "header": true, "max_rows": 1},
+			`{"columns":["Total","Total_2"],"range":"C1:D2","rows":[{"Total":1250.5,"Total_2":true}],"sheet":"Sales","truncated":true}`},
+		{map[string]interface{}{"path": "sales.xlsx", "sheet": "Notes"},
+			`{"range":"A1:B1","rows":[[7,"seven"]],"sheet":"Notes"}`},
+		{map[string]interface{}{"path": "sales.xlsx", "range": "F10:G12"},
+			`{"rows":[],"sheet":"Sales"}`},
+	} {
+		if got := readSpreadsheet(t, tool, tc.args); got != tc.want {
+			t.Errorf("%v: expected\n%s\ngot\n%s", tc.args, tc.want, got)
+		}
+	}
+
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a zip"), 0o644)
+	for _, tc := range []struct {
+		tool *readSpreadsheetTool
+		args map[string]interface{}
+		want string
+	}{
+		{tool, map[string]interface{}{"path": "sales.xlsx", "sheet": "Costs"}, "expected one of Sales, Notes"},
+		{tool, map[string]interface{}{"path": "sales.xlsx", "range": "D4:A1"}, "invalid value for 'range'"},
+		{tool, map[string]interface{}{"path": "sales.xlsx", "range": "A1:B"}, "invalid value for 'range'"},
+		{tool, map[string]interface{}{"path": "sales.xlsx", "max_rows": 5000}, "invalid value for 'max_rows'"},
+		{tool, map[string]interface{}{"path": "notes.txt"}, "not an .xlsx file"},
+		{tool, map[string]interface{}{"path": "../sales.xlsx"}, "not a path within"},
+		{tool, map[string]interface{}{}, "'path' is required"},
+		{&readSpreadsheetTool{}, map[string]interface{}{"path": "sales.xlsx"}, "--spreadsheet-dir"},
+	} {
+		if _, err := tc.tool.Execute(tc.args); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: expected an error containing %q, got %v", tc.args, tc.want, err)
+		}
+	}
+}
+
+// Test that serial dates of both date systems and date formats are
+// recognized
+func TestExcelDates(t *testing.T) {
+	for _, tc := range []struct {
+		serial   float64
+		date1904 bool
+		want     string
+	}{
+		{1, false, "1900-01-01"},
+		{59, false, "1900-02-28"},
+		{61, false, "1900-03-01"},
+		{45658, false, "2025-01-01"},
+		{45658.75, false, "2025-01-01T18:00:00"},
+		{0.5, false, "12:00:00"},
+		{0, true, "1904-01-01"},
+		{44196, true, "2025-01-01"},
+	} {
+		if got := excelDate(tc.serial, tc.date1904); got != tc.want {
+			t.Errorf("%v (1904: %t): expected %s, got %s", tc.serial, tc.date1904, tc.want, got)
+		}
+	}
+	for code, want := range map[string]bool{
+		"yyyy-mm-dd": true, "h:mm AM/PM": true, "[$-409]mmmm d": true, `0.00"days"`: false,
+		"#,##0.00": false, "[Red]0%": false, `\d0`: false,
+	} {
+		if got := isDateFormat(code); got != want {
+			t.Errorf("%s: expected %t, got %t", code, want, got)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// sqlConfig configures the sql_query tool in the "sql" section of the
+// config file. The drivers are those registered with database/sql by the
+// program, such as "postgres" by github.com/lib/pq or "mysql" by
+// github.com/go-sql-driver/mysql, which a build of the server links in
+// with a blank import.
+type sqlConfig struct {
+	Databases []sqlDatabaseConfig `json:"databases"`
+	MaxRows   int                 `json:"maxRows"`  // defaults to 500
+	MaxBytes  int                 `json:"maxBytes"` // of the rows as JSON, defaults to 1 MiB
+	Timeout   duration            `json:"timeout"`  // per query, defaults to 30s
+}
+
+// sqlDatabaseConfig is one database the sql_query tool can query. The
+// model refers to it by name only, so the DSN, which often holds a
+// password, never passes through the conversation.
+type sqlDatabaseConfig struct {
+	Name        string `json:"name"`
+	Driver      string `json:"driver"`
+	DSN         string `json:"dsn"`         // $VAR expands from the environment
+	Description string `json:"description"` // tells the model what the database holds
+}
+
+// Defaults of the sql_query tool.
+const (
+	defaultSQLMaxRows  = 500
+	defaultSQLMaxBytes = 1 << 20
+	defaultSQLTimeout  = 30 * time.Second
+)
+
+// validate reports missing or malformed fields.
+func (c *sqlConfig) validate() error {
+	if len(c.Databases) == 0 {
+		return errors.New("sql: no databases")
+	}
+	seen := map[string]bool{}
+	for _, db := range c.Databases {
+		switch {
+		case db.Name == "":
+			return errors.New("sql: database without a name")
+		case seen[db.Name]:
+			return fmt.Errorf("sql: database %q defined twice", db.Name)
+		case db.Driver == "" || db.DSN == "":
+			return fmt.Errorf("sql: database %q: driver and dsn are required", db.Name)
+		}
+		seen[db.Name] = true
+	}
+	if c.MaxRows < 0 || c.MaxBytes < 0 || c.Timeout < 0 {
+		return errors.New("sql: maxRows, maxBytes, and timeout must not be negative")
+	}
+	return nil
+}
+
+// sqlQueryTool runs read-only statements against the configured databases.
+// Statements are checked before they are sent and run in read-only
+// transactions, but the database user should only be able to read all the
+// same: functions with side effects cannot be told apart from others.
+type sqlQueryTool struct {
+	cfg sqlConfig
+	dbs map[string]*sql.DB
+}
+
+// newSQLQueryTool opens the databases of cfg. Connections are made when
+// the first query needs them.
+func newSQLQueryTool(cfg sqlConfig) (*sqlQueryTool, error) {
+	if cfg.MaxRows == 0 {
+		cfg.MaxRows = defaultSQLMaxRows
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultSQLMaxBytes
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = duration(defaultSQLTimeout)
+	}
+	t := &sqlQueryTool{cfg: cfg, dbs: map[string]*sql.DB{}}
+	for _, c := range cfg.Databases {
+		if !slices.Contains(sql.Drivers(), c.Driver) {
+			t.close()
+			return nil, fmt.Errorf("sql: database %q: driver %q is not linked into this build (available: %s)", c.Name, c.Driver, strings.Join(sql.Drivers(), ", "))
+		}
+		db, err := sql.Open(c.Driver, os.ExpandEnv(c.DSN))
+		if err != nil {
+			t.close()
+			return nil, fmt.Errorf("sql: database %q: %w", c.Name, err)
+		}
+		t.dbs[c.Name] = db
+	}
+	return t, nil
+}
+
+// close closes the databases.
+func (t *sqlQueryTool) close() {
+	for _, db := range t.dbs {
+		db.Close()
+	}
+}
+
+// sqlQueryArgs are the arguments of the sql_query tool.
+type sqlQueryArgs struct {
+	Database string        `json:"database,omitempty" description:"Name of the database (default the first)"`
+	Query    string        `json:"query" description:"A single read-only statement: SELECT, WITH, EXPLAIN, SHOW, DESCRIBE, VALUES, or TABLE"`
+	Params   []interface{} `json:"params,omitempty" description:"Values of the statement's placeholders, such as $1 or ?, in order"`
+	MaxRows  int           `json:"max_rows,omitempty" minimum:"1" description:"Most rows to return (default the server's limit)"`
+}
+
+// sqlQueryResult is the result of the sql_query tool.
+type sqlQueryResult struct {
+	Columns     []string        `json:"columns"`
+	Rows        [][]interface{} `json:"rows"`
+	Truncated   bool            `json:"truncated,omitempty"`
+	TruncatedBy string          `json:"truncatedBy,omitempty"` // "rows" or "bytes"
+}
+
+// Name returns the name of the sql_query tool.
+func (t *sqlQueryTool) Name() string {
+	return "sql_query"
+}
+
+// Description returns a brief description of the sql_query tool, listing
+// the databases and what they hold.
+func (t *sqlQueryTool) Description() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Runs a read-only SQL statement and returns at most %d rows as JSON. Databases:", t.cfg.MaxRows)
+	for _, db := range t.cfg.Databases {
+		b.WriteString(" " + db.Name + " (" + db.Driver)
+		if db.Description != "" {
+			b.WriteString(", " + db.Description)
+		}
+		b.WriteString("),")
+	}
+	return strings.TrimSuffix(b.String(), ",")
+}
+
+// InputSchema returns the JSON schema for the sql_query tool's input
+// parameters, with the database names as an enum.
+func (t *sqlQueryTool) InputSchema() map[string]interface{} {
+	schema := mcp.SchemaFor(sqlQueryArgs{})
+	props := schema["properties"].(map[string]interface{})
+	names := make([]string, len(t.cfg.Databases))
+	for i, db := range t.cfg.Databases {
+		names[i] = db.Name
+	}
+	props["database"].(map[string]interface{})["enum"] = names
+	props["max_rows"].(map[string]interface{})["maximum"] = t.cfg.MaxRows
+	return schema
+}
+
+// Annotations marks the sql_query tool as read-only. The databases are
+// outside the server, and their contents change, so it is neither closed
+// nor idempotent.
+func (t *sqlQueryTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "SQL query", ReadOnlyHint: true}
+}
+
+// Timeout returns the configured time limit of a query.
+func (t *sqlQueryTool) Timeout() time.Duration {
+	return time.Duration(t.cfg.Timeout)
+}
+
+// Execute runs the query without a deadline of its own.
+func (t *sqlQueryTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the query and returns its rows as text.
+func (t *sqlQueryTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult checks the statement, runs it in a read-only transaction
+// that is rolled back, and returns the rows as structured content, up to
+// the row and byte limits.
+func (t *sqlQueryTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	var a sqlQueryArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Database == "" {
+		a.Database = t.cfg.Databases[0].Name
+	}
+	db, ok := t.dbs[a.Database]
+	if !ok {
+		return nil, toolFailure("invalid value for 'database': unknown database %q", a.Database)
+	}
+	maxRows := t.cfg.MaxRows
+	if a.MaxRows < 0 || a.MaxRows > maxRows {
+		return nil, toolFailure("invalid value for 'max_rows': expected 1 to %d", maxRows)
+	} else if a.MaxRows > 0 {
+		maxRows = a.MaxRows
+	}
+	if err := checkReadOnlySQL(a.Query); err != nil {
+		return nil, toolFailure("Refused: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.Timeout))
+	defer cancel()
+	failed := func(err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return toolFailure("%s: %v", a.Database, err)
+	}
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, failed(err)
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, a.Query, a.Params...)
+	if err != nil {
+		return nil, failed(err)
+	}
+	defer rows.Close()
+	result, err := scanSQLRows(rows, maxRows, t.cfg.MaxBytes)
+	if err != nil {
+		return nil, failed(err)
+	}
+	return mcp.NewResult().WithStructured(result), nil
+}
+
+// scanSQLRows reads up to maxRows rows whose JSON encoding takes up to
+// maxBytes in all.
+func scanSQLRows(rows *sql.Rows, maxRows, maxBytes int) (*sqlQueryResult, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := &sqlQueryResult{Columns: columns, Rows: [][]interface{}{}}
+	size := 0
+	for rows.Next() {
+		if len(result.Rows) == maxRows {
+			result.Truncated, result.TruncatedBy = true, "rows"
+			break
+		}
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		for i, v := range values {
+			values[i] = sqlValue(v)
+		}
+		encoded, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		if size += len(encoded) + 1; size > maxBytes {
+			result.Truncated, result.TruncatedBy = true, "bytes"
+			break
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	return result, rows.Err()
+}
+
+// sqlValue returns a scanned value as JSON represents it: bytes as text if
+// they are UTF-8 and as base64 otherwise, and times in RFC 3339.
+func sqlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// sqlReadStatements are the keywords read-only statements may start with.
+var sqlReadStatements = map[string]bool{
+	"SELECT": true, "WITH": true, "EXPLAIN": true, "SHOW": true,
+	"DESCRIBE": true, "DESC": true, "VALUES": true, "TABLE": true,
+}
+
+// sqlWriteKeywords are keywords of writes that can hide inside a statement
+// starting with a read: in a data-modifying WITH, SELECT INTO, a locking
+// FOR UPDATE, or behind EXPLAIN ANALYZE, which runs what it explains.
+var sqlWriteKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "UPSERT": true,
+	"CREATE": true, "DROP": true, "ALTER": true, "TRUNCATE": true, "GRANT": true, "REVOKE": true,
+	"COPY": true, "INTO": true, "CALL": true, "EXEC": true, "EXECUTE": true, "DO": true,
+	"PREPARE": true, "DECLARE": true, "LOCK": true, "VACUUM": true,
+}
+
+// checkReadOnlySQL reports why query is not a single read-only statement.
+// It reads the statement as loosely as any of the common dialects would,
+// and refuses what the dialects read differently, such as backslashes in
+// quoted text, dollar-quoted strings, nested or MySQL executable comments,
+// and # comments, so that no write can hide in what it takes for a string
+// or a comment.
+func checkReadOnlySQL(query string) error {
+	var words []string
+	ended := false // by a semicolon
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+			continue
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			if i+2 < len(query) && !strings.ContainsRune(" \t\r\n", rune(query[i+2])) {
+				return errors.New("-- comments must be followed by a space")
+			}
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return checkSQLWords(words)
+			}
+			i += end + 1
+			continue
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			if strings.HasPrefix(query[i:], "/*!") {
+				return errors.New("executable comments are not allowed")
+			}
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return errors.New("unterminated comment")
+			}
+			if strings.Contains(query[i+2:i+2+end], "/*") {
+				return errors.New("nested comments are not allowed")
+			}
+			i += end + 4
+			continue
+		}
+		if ended {
+			return errors.New("only a single statement is allowed")
+		}
+		switch {
+		case c == ';':
+			ended = true
+			i++
+		case c == '#':
+			return errors.New("# is not allowed outside quoted text")
+		case c == '$' && (i+1 >= len(query) || query[i+1] < '0' || query[i+1] > '9'):
+			return errors.New("dollar-quoted strings are not allowed; pass values as params")
+		case c == '\'' || c == '"' || c == '`':
+			end, err := sqlQuoteEnd(query, i)
+			if err != nil {
+				return err
+			}
+			i = end
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := i
+			for i < len(query) && (query[i] == '_' || query[i] == '$' || query[i] >= 'A' && query[i] <= 'Z' ||
+				query[i] >= 'a' && query[i] <= 'z' || query[i] >= '0' && query[i] <= '9') {
+				i++
+			}
+			words = append(words, strings.ToUpper(query[start:i]))
+		default:
+			i++
+		}
+	}
+	return checkSQLWords(words)
+}
+
+// sqlQuoteEnd returns the index after the quoted text starting at
+// query[start], in which a doubled quote stands for itself.
+func sqlQuoteEnd(query string, start int) (int, error) {
+	q := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			return 0, errors.New("backslashes are not allowed in quoted text; pass values as params")
+		case q:
+			if i+1 < len(query) && query[i+1] == q {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, errors.New("unterminated quoted text")
+}
+
+// checkSQLWords reports why the words of a statement, outside quoted text
+// and comments, are not those of a read-only one.
+func checkSQLWords(words []string) error {
+	if len(words) == 0 {
+		return errors.New("empty statement")
+	}
+	if !sqlReadStatements[words[0]] {
+		return fmt.Errorf("%s statements are not allowed; only SELECT, WITH, EXPLAIN, SHOW, DESCRIBE, VALUES, and TABLE", words[0])
+	}
+	for _, w := range words[1:] {
+		if sqlWriteKeywords[w] {
+			return fmt.Errorf("%s is not allowed in a read-only statement", w)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSQLDriver answers every query with the rows of a table, recording
+// the queries and whether their transactions were read-only.
+type fakeSQLDriver struct {
+	mu       sync.Mutex
+	queries  []string
+	readOnly []bool
+}
+
+// fakeSQL is registered as the "fakesql" driver.
+var fakeSQL = &fakeSQLDriver{}
+
+func init() { sql.Register("fakesql", fakeSQL) }
+
+func (d *fakeSQLDriver) Open(name string) (driver.Conn, error) { return &fakeSQLConn{d: d}, nil }
+
+type fakeSQLConn struct{ d *fakeSQLDriver }
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeSQLConn) Close() error              { return nil }
+func (c *fakeSQLConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeSQLConn) Commit() error             { return nil }
+func (c *fakeSQLConn) Rollback() error           { return nil }
+func (c *fakeSQLConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.d.mu.Lock()
+	c.d.readOnly = append(c.d.readOnly, opts.ReadOnly)
+	c.d.mu.Unlock()
+	return c, nil
+}
+func (c *fakeSQLConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.mu.Lock()
+	c.d.queries = append(c.d.queries, query)
+	c.d.mu.Unlock()
+	if strings.Contains(query, "sleep") {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	rows := &fakeSQLRows{}
+	for i := 1; i <= 5; i++ {
+		rows.values = append(rows.values, []driver.Value{int64(i), []byte(strings.Repeat("x", i*10)), []byte{0xff, byte(i)}})
+	}
+	if len(args) > 0 {
+		n, _ := args[0].Value.(float64)
+		rows.values = rows.values[:int(n)]
+	}
+	return rows, nil
+}
+
+type fakeSQLRows struct {
+	values [][]driver.Value
+	next   int
+}
+
+func (r *fakeSQLRows) Columns() []string { return []string{"id", "name", "raw"} }
+func (r *fakeSQLRows) Close() error      { return nil }
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if r.next == len(r.values) {
+		return io.EOF
+	}
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+// callSQLQuery calls tool and decodes its structured result.
+func callSQLQuery(t *testing.T, tool *sqlQueryTool, args map[string]interface{}) (*sqlQueryResult, error) {
+	t.Helper()
+	result, err := tool.ExecuteResult(context.Background(), args)
+	if err != nil {
+		return nil, err
+	}
+	encoded, _ := json.Marshal(result.Structured)
+	var r sqlQueryResult
+	if err := json.Unmarshal(encoded, &r); err != nil {
+		t.Fatal(err)
+	}
+	return &r, nil
+}
+
+// Test that queries return rows up to the row and byte limits, in read-only
+// transactions
+func TestSQLQuery(t *testing.T) {
+	tool, err := newSQLQueryTool(sqlConfig{Databases: []sqlDatabaseConfig{
+		{Name: "analytics", Driver: "fakesql", DSN: "dsn", Description: "page views"},
+		{Name: "billing", Driver: "fakesql", DSN: "dsn"},
+	}, MaxRows: 4, MaxBytes: 120})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tool.close()
+	if want := "Runs a read-only SQL statement and returns at most 4 rows as JSON. Databases: analytics (fakesql, page views), billing (fakesql)"; tool.Description() != want {
+		t.Errorf("unexpected description %q", tool.Description())
+	}
+
+	r, err := callSQLQuery(t, tool, map[string]interface{}{"query": "SELECT id, name, raw FROM t WHERE id <= $1", "params": []interface{}{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(r)
+	if want := `{"columns":["id","name","raw"],"rows":[[1,"xxxxxxxxxx","/wE="],[2,"xxxxxxxxxxxxxxxxxxxx","/wI="]]}`; string(encoded) != want {
+		t.Errorf("expected %s, got %s", want, encoded)
+	}
+	if r, err := callSQLQuery(t, tool, map[string]interface{}{"database": "billing", "query": "select * from t", "max_rows": 2}); err != nil || len(r.Rows) != 2 || r.TruncatedBy != "rows" {
+		t.Errorf("expected two rows, truncated by the limit, got %+v, %v", r, err)
+	}
+	if r, err := callSQLQuery(t, tool, map[string]interface{}{"query": "select * from t"}); err != nil || len(r.Rows) != 3 || r.TruncatedBy != "bytes" {
+		t.Errorf("expected the rows to be cut at 120 bytes, got %+v, %v", r, err)
+	}
+	fakeSQL.mu.Lock()
+	for i, ro := range fakeSQL.readOnly {
+		if !ro {
+			t.Errorf("transaction %d is not read-only", i+1)
+		}
+	}
+	fakeSQL.mu.Unlock()
+
+	for _, tc := range []struct {
+		args map[string]interface{}
+		want string
+	}{
+		{map[string]interface{}{"query": "DELETE FROM t"}, "Refused: DELETE statements are not allowed; only SELECT, WITH, EXPLAIN, SHOW, DESCRIBE, VALUES, and TABLE"},
+		{map[string]interface{}{"database": "other", "query": "SELECT 1"}, `invalid value for 'database': unknown database "other"`},
+		{map[string]interface{}{"query": "SELECT 1", "max_rows": 5}, "invalid value for 'max_rows': expected 1 to 4"},
+	} {
+		_, err := tool.ExecuteResult(context.Background(), tc.args)
+		var failure *toolResultError
+		if !errors.As(err, &failure) || failure.content[0].Text != tc.want {
+			t.Errorf("%v: expected the error result %q, got %v", tc.args, tc.want, err)
+		}
+	}
+
+	tool.cfg.Timeout = duration(20 * time.Millisecond)
+	if _, err := tool.ExecuteResult(context.Background(), map[string]interface{}{"query": "SELECT pg_sleep(60)"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the query to time out, got %v", err)
+	}
+
+	if _, err := newSQLQueryTool(sqlConfig{Databases: []sqlDatabaseConfig{{Name: "pg", Driver: "nosuchdriver", DSN: "dsn"}}}); err == nil || !strings.Contains(err.Error(), "not linked into this build") {
+		t.Errorf("expected an unknown driver to be rejected, got %v", err)
+	}
+}
+
+// Test that only single read-only statements pass, and that writes cannot
+// hide in what a dialect reads differently
+func TestCheckReadOnlySQL(t *testing.T) {
+	for _, q := range []string{
+		"SELECT 1",
+		"select * from users where name = 'O''Brien';",
+		"WITH recent AS (SELECT * FROM orders) SELECT count(*) FROM recent",
+		"EXPLAIN SELECT * FROM t",
+		"SHOW TABLES",
+		"SELECT \"update\", `delete` FROM t -- comment\n",
+		"/* report */ SELECT replace(name, 'a', 'b') FROM t WHERE id = $1 ORDER BY id DESC",
+		"SELECT 'a;b' FROM t; -- done",
+	} {
+		if err := checkReadOnlySQL(q); err != nil {
+			t.Errorf("%q: unexpected error %v", q, err)
+		}
+	}
+	for _, q := range []string{
+		"",
+		"-- nothing",
+		"DELETE FROM t",
+		"drop table t",
+		"SELECT 1; DROP TABLE t",
+		"WITH gone AS (DELETE FROM t RETURNING *) SELECT * FROM gone",
+		"SELECT * INTO copy FROM t",
+		"SELECT * FROM t FOR UPDATE",
+		"EXPLAIN ANALYZE DELETE FROM t",
+		`SELECT 'a\' ' ; DROP TABLE t; -- '`,
+		"SELECT $$'$$; DROP TABLE t; --'",
+		"SELECT 1 /* /* */ ' */ ; DROP TABLE t; -- '",
+		"SELECT 1 /*! ; DROP TABLE t */",
+		"SELECT 1 # '\n; DROP TABLE t; -- '",
+		"SELECT 1 --'\n'; DROP TABLE t; --",
+		"SELECT 'unterminated",
+		"SELECT 1 /* unterminated",
+	} {
+		if err := checkReadOnlySQL(q); err == nil {
+			t.Errorf("%q: expected an error", q)
+		}
+	}
+}
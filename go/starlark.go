@@ -0,0 +1,1144 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file, starlark_eval.go, and starlark_builtins.go implement the
+// dialect of Starlark, the Python-like language of Bazel, that script
+// plugins are written in. It has the statements of Starlark but load:
+// def, if, for, return, break, continue, pass, and assignments, and its
+// expressions, with comprehensions, conditionals, lambdas, and slices,
+// but without *args and **kwargs. Values are None, bools, 64-bit ints,
+// floats, strings, lists, tuples, dicts, and functions, and the builtins
+// are limited to computing on them, with json to encode and decode JSON.
+// Scripts can reach nothing outside their own values: no files, network,
+// or clock.
+
+// starPos is a position in a script, for messages.
+type starPos struct {
+	line, col int
+}
+
+// starError is a syntax or runtime error of a script.
+type starError struct {
+	file    string
+	pos     starPos
+	message string
+	cause   error // of errors from fail or the context, for errors.As
+}
+
+// Error implements the error interface.
+func (e *starError) Error() string {
+	if e.pos.line == 0 {
+		return fmt.Sprintf("%s: %s", e.file, e.message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.file, e.pos.line, e.pos.col, e.message)
+}
+
+// Unwrap returns the cause of the error.
+func (e *starError) Unwrap() error {
+	return e.cause
+}
+
+// starTokenKind is the kind of a token.
+type starTokenKind int
+
+const (
+	starEOF starTokenKind = iota
+	starNewline
+	starIndent
+	starOutdent
+	starIdent
+	starKeyword
+	starLiteral // an int, float, or string
+	starOp      // an operator or punctuation
+)
+
+// starToken is a token of a script.
+type starToken struct {
+	kind  starTokenKind
+	text  string      // of identifiers, keywords, and operators
+	value interface{} // of literals: int64, float64, or string
+	pos   starPos
+}
+
+// starKeywords are the keywords of the dialect. starReserved are keywords
+// of Python that it does not have, which may not be used as names.
+var (
+	starKeywords = map[string]bool{
+		"and": true, "break": true, "continue": true, "def": true, "elif": true, "else": true,
+		"for": true, "if": true, "in": true, "lambda": true, "not": true, "or": true,
+		"pass": true, "return": true,
+	}
+	starReserved = map[string]bool{
+		"as": true, "assert": true, "class": true, "del": true, "except": true, "finally": true,
+		"from": true, "global": true, "import": true, "is": true, "load": true, "nonlocal": true,
+		"raise": true, "try": true, "while": true, "with": true, "yield": true,
+	}
+)
+
+// starOps are the operators, longest first so that they match greedily.
+var starOps = []string{
+	"//=", "<<=", ">>=",
+	"//", "<<", ">>", "==", "!=", "<=", ">=", "+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"+", "-", "*", "/", "%", "&", "|", "^", "~", "<", ">", "=", "(", ")", "[", "]", "{", "}", ",", ":", ".", ";",
+}
+
+// starScanner splits a script into tokens, turning changes of indentation
+// into indent and outdent tokens as Python does. Line breaks within
+// brackets are ignored.
+type starScanner struct {
+	file    string
+	src     string
+	i       int
+	line    int // of src[i]
+	lineOff int // the offset of the line's start
+	depth   int // of open brackets
+	indents []int
+	tokens  []starToken
+}
+
+// scanStarlark returns the tokens of src, read from file.
+func scanStarlark(file, src string) (tokens []starToken, err error) {
+	defer recoverStarError(&err)
+	s := &starScanner{file: file, src: src, line: 1, indents: []int{0}}
+	s.scan()
+	return s.tokens, nil
+}
+
+// recoverStarError turns a panicking *starError into err.
+func recoverStarError(err *error) {
+	if e, ok := recover().(*starError); ok {
+		*err = e
+	} else if e != nil {
+		panic(e)
+	}
+}
+
+// pos returns the position of src[i].
+func (s *starScanner) pos() starPos {
+	return starPos{s.line, s.i - s.lineOff + 1}
+}
+
+// errorf aborts scanning with an error at pos.
+func (s *starScanner) errorf(pos starPos, format string, args ...interface{}) {
+	panic(&starError{file: s.file, pos: pos, message: fmt.Sprintf(format, args...)})
+}
+
+// emit appends a token.
+func (s *starScanner) emit(kind starTokenKind, text string, value interface{}, pos starPos) {
+	s.tokens = append(s.tokens, starToken{kind: kind, text: text, value: value, pos: pos})
+}
+
+// newline moves past the line break at src[i].
+func (s *starScanner) newline() {
+	if s.src[s.i] == '\r' && s.i+1 < len(s.src) && s.src[s.i+1] == '\n' {
+		s.i++
+	}
+	s.i++
+	s.line++
+	s.lineOff = s.i
+}
+
+// scan scans the whole script.
+func (s *starScanner) scan() {
+	atLineStart := true
+	for {
+		if atLineStart && s.depth == 0 {
+			if !s.indentation() {
+				break
+			}
+			atLineStart = false
+		}
+		if s.i >= len(s.src) {
+			break
+		}
+		c := s.src[s.i]
+		switch {
+		case c == ' ' || c == '\t':
+			s.i++
+		case c == '#':
+			for s.i < len(s.src) && s.src[s.i] != '\n' && s.src[s.i] != '\r' {
+				s.i++
+			}
+		case c == '\\' && s.i+1 < len(s.src) && (s.src[s.i+1] == '\n' || s.src[s.i+1] == '\r'):
+			s.i++
+			s.newline()
+		case c == '\n' || c == '\r':
+			if s.depth == 0 {
+				s.emit(starNewline, "", nil, s.pos())
+				atLineStart = true
+			}
+			s.newline()
+		case c == '"' || c == '\'':
+			s.string(false)
+		case (c == 'r' || c == 'R') && s.i+1 < len(s.src) && (s.src[s.i+1] == '"' || s.src[s.i+1] == '\''):
+			s.i++
+			s.string(true)
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			s.identifier()
+		case c >= '0' && c <= '9' || c == '.' && s.i+1 < len(s.src) && s.src[s.i+1] >= '0' && s.src[s.i+1] <= '9':
+			s.number()
+		default:
+			s.operator()
+		}
+	}
+	if n := len(s.tokens); n > 0 && s.tokens[n-1].kind != starNewline && s.tokens[n-1].kind != starOutdent {
+		s.emit(starNewline, "", nil, s.pos())
+	}
+	for len(s.indents) > 1 {
+		s.indents = s.indents[:len(s.indents)-1]
+		s.emit(starOutdent, "", nil, s.pos())
+	}
+	s.emit(starEOF, "", nil, s.pos())
+}
+
+// indentation skips blank lines and emits the indent or outdent tokens of
+// the next line with code. It reports false at the end of the script.
+func (s *starScanner) indentation() bool {
+	for {
+		col := 0
+		for s.i < len(s.src) && (s.src[s.i] == ' ' || s.src[s.i] == '\t') {
+			if s.src[s.i] == '\t' {
+				col += 8 - col%8
+			} else {
+				col++
+			}
+			s.i++
+		}
+		if s.i >= len(s.src) {
+			return false
+		}
+		switch s.src[s.i] {
+		case '#':
+			for s.i < len(s.src) && s.src[s.i] != '\n' && s.src[s.i] != '\r' {
+				s.i++
+			}
+			if s.i >= len(s.src) {
+				return false
+			}
+			s.newline()
+			continue
+		case '\n', '\r':
+			s.newline()
+			continue
+		}
+		top := s.indents[len(s.indents)-1]
+		if col > top {
+			s.indents = append(s.indents, col)
+			s.emit(starIndent, "", nil, s.pos())
+			return true
+		}
+		for col < s.indents[len(s.indents)-1] {
+			s.indents = s.indents[:len(s.indents)-1]
+			s.emit(starOutdent, "", nil, s.pos())
+		}
+		if col != s.indents[len(s.indents)-1] {
+			s.errorf(s.pos(), "unindent does not match any outer indentation level")
+		}
+		return true
+	}
+}
+
+// identifier scans a name or keyword.
+func (s *starScanner) identifier() {
+	pos, start := s.pos(), s.i
+	for s.i < len(s.src) {
+		c := s.src[s.i]
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			break
+		}
+		s.i++
+	}
+	name := s.src[start:s.i]
+	switch {
+	case starKeywords[name]:
+		s.emit(starKeyword, name, nil, pos)
+	case starReserved[name]:
+		s.errorf(pos, "%s is reserved", name)
+	default:
+		s.emit(starIdent, name, nil, pos)
+	}
+}
+
+// number scans an int or float literal.
+func (s *starScanner) number() {
+	pos, start := s.pos(), s.i
+	isFloat := false
+	if s.src[s.i] == '0' && s.i+1 < len(s.src) && strings.ContainsRune("xXoObB", rune(s.src[s.i+1])) {
+		s.i += 2
+		for s.i < len(s.src) && isStarDigit(s.src[s.i], true) {
+			s.i++
+		}
+	} else {
+		for s.i < len(s.src) && isStarDigit(s.src[s.i], false) {
+			s.i++
+		}
+		if s.i < len(s.src) && s.src[s.i] == '.' {
+			isFloat = true
+			s.i++
+			for s.i < len(s.src) && isStarDigit(s.src[s.i], false) {
+				s.i++
+			}
+		}
+		if s.i < len(s.src) && (s.src[s.i] == 'e' || s.src[s.i] == 'E') {
+			isFloat = true
+			s.i++
+			if s.i < len(s.src) && (s.src[s.i] == '+' || s.src[s.i] == '-') {
+				s.i++
+			}
+			for s.i < len(s.src) && isStarDigit(s.src[s.i], false) {
+				s.i++
+			}
+		}
+	}
+	text := s.src[start:s.i]
+	if isFloat {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			s.errorf(pos, "invalid float literal %s", text)
+		}
+		s.emit(starLiteral, text, f, pos)
+		return
+	}
+	if len(text) > 1 && text[0] == '0' && text[1] >= '0' && text[1] <= '9' {
+		s.errorf(pos, "invalid int literal %s: use 0o for octal", text)
+	}
+	n, err := strconv.ParseInt(text, 0, 64)
+	if err != nil {
+		s.errorf(pos, "invalid int literal %s", text)
+	}
+	s.emit(starLiteral, text, n, pos)
+}
+
+// isStarDigit reports whether c may be part of a number, including hex
+// digits if hex is set.
+func isStarDigit(c byte, hex bool) bool {
+	return c >= '0' && c <= '9' || hex && (c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F')
+}
+
+// string scans a string literal, triple-quoted if it starts with three
+// quotes. Raw strings keep their backslashes.
+func (s *starScanner) string(raw bool) {
+	pos := s.pos()
+	quote := s.src[s.i]
+	triple := strings.HasPrefix(s.src[s.i:], strings.Repeat(string(quote), 3))
+	if triple {
+		s.i += 3
+	} else {
+		s.i++
+	}
+	var b strings.Builder
+	for {
+		if s.i >= len(s.src) {
+			s.errorf(pos, "unterminated string")
+		}
+		c := s.src[s.i]
+		switch {
+		case c == quote && (!triple || strings.HasPrefix(s.src[s.i:], strings.Repeat(string(quote), 3))):
+			if triple {
+				s.i += 3
+			} else {
+				s.i++
+			}
+			s.emit(starLiteral, "", b.String(), pos)
+			return
+		case c == '\n' || c == '\r':
+			if !triple {
+				s.errorf(pos, "unterminated string")
+			}
+			b.WriteByte('\n')
+			s.newline()
+		case c == '\\':
+			if s.i+1 >= len(s.src) {
+				s.errorf(pos, "unterminated string")
+			}
+			if raw {
+				b.WriteByte('\\')
+				s.i++
+				if next := s.src[s.i]; next == '\n' || next == '\r' {
+					b.WriteByte('\n')
+					s.newline()
+				} else {
+					b.WriteByte(next)
+					s.i++
+				}
+				continue
+			}
+			s.escape(&b)
+		default:
+			b.WriteByte(c)
+			s.i++
+		}
+	}
+}
+
+// escape decodes the escape sequence at src[i].
+func (s *starScanner) escape(b *strings.Builder) {
+	pos := s.pos()
+	s.i++
+	c := s.src[s.i]
+	s.i++
+	switch c {
+	case '\n', '\r':
+		s.i--
+		s.newline()
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case 'a':
+		b.WriteByte('\a')
+	case 'b':
+		b.WriteByte('\b')
+	case 'f':
+		b.WriteByte('\f')
+	case 'v':
+		b.WriteByte('\v')
+	case '\\', '\'', '"':
+		b.WriteByte(c)
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		end := s.i - 1
+		for end < len(s.src) && end < s.i+2 && s.src[end] >= '0' && s.src[end] <= '7' {
+			end++
+		}
+		n, _ := strconv.ParseUint(s.src[s.i-1:end], 8, 8)
+		b.WriteByte(byte(n))
+		s.i = end
+	case 'x', 'u', 'U':
+		size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+		if s.i+size > len(s.src) {
+			s.errorf(pos, "invalid escape sequence \\%c", c)
+		}
+		n, err := strconv.ParseUint(s.src[s.i:s.i+size], 16, 32)
+		if err != nil || c != 'x' && !utf8.ValidRune(rune(n)) {
+			s.errorf(pos, "invalid escape sequence \\%c%s", c, s.src[s.i:s.i+size])
+		}
+		if c == 'x' {
+			b.WriteByte(byte(n))
+		} else {
+			b.WriteRune(rune(n))
+		}
+		s.i += size
+	default:
+		s.errorf(pos, "invalid escape sequence \\%c", c)
+	}
+}
+
+// operator scans an operator or punctuation.
+func (s *starScanner) operator() {
+	pos := s.pos()
+	for _, op := range starOps {
+		if strings.HasPrefix(s.src[s.i:], op) {
+			switch op {
+			case "(", "[", "{":
+				s.depth++
+			case ")", "]", "}":
+				if s.depth > 0 {
+					s.depth--
+				}
+			}
+			s.i += len(op)
+			s.emit(starOp, op, nil, pos)
+			return
+		}
+	}
+	r, _ := utf8.DecodeRuneInString(s.src[s.i:])
+	s.errorf(pos, "unexpected character %q", r)
+}
+
+// Statements and expressions of the syntax tree. Each records where it
+// starts, for messages.
+type (
+	starStmt interface{ position() starPos }
+	starExpr interface{ position() starPos }
+
+	starDefStmt struct {
+		starPos
+		name string
+		fn   *starFuncDef
+	}
+	starIfStmt struct {
+		starPos
+		cond      starExpr
+		then, els []starStmt
+	}
+	starForStmt struct {
+		starPos
+		vars starExpr
+		iter starExpr
+		body []starStmt
+	}
+	starReturnStmt struct {
+		starPos
+		value starExpr // nil for None
+	}
+	starBranchStmt struct {
+		starPos
+		keyword string // break, continue, or pass
+	}
+	starAssignStmt struct {
+		starPos
+		op       string // = or an augmented assignment such as +=
+		lhs, rhs starExpr
+	}
+	starExprStmt struct {
+		starPos
+		x starExpr
+	}
+
+	starIdentExpr struct {
+		starPos
+		name string
+	}
+	starLiteralExpr struct {
+		starPos
+		value interface{}
+	}
+	starListExpr struct {
+		starPos
+		elems []starExpr
+	}
+	starTupleExpr struct {
+		starPos
+		elems []starExpr
+	}
+	starDictExpr struct {
+		starPos
+		keys, values []starExpr
+	}
+	starCompExpr struct {
+		starPos
+		dict     bool
+		key, val starExpr // key is the element of a list comprehension
+		clauses  []starCompClause
+	}
+	starUnaryExpr struct {
+		starPos
+		op string
+		x  starExpr
+	}
+	starBinaryExpr struct {
+		starPos
+		op   string
+		x, y starExpr
+	}
+	starCondExpr struct {
+		starPos
+		cond, then, els starExpr
+	}
+	starCallExpr struct {
+		starPos
+		fn     starExpr
+		args   []starExpr
+		names  []string // of the keyword arguments
+		kwargs []starExpr
+	}
+	starIndexExpr struct {
+		starPos
+		x, index starExpr
+	}
+	starSliceExpr struct {
+		starPos
+		x, lo, hi, step starExpr // nil when left out
+	}
+	starDotExpr struct {
+		starPos
+		x    starExpr
+		name string
+	}
+	starLambdaExpr struct {
+		starPos
+		fn *starFuncDef
+	}
+)
+
+// starCompClause is a for or if clause of a comprehension.
+type starCompClause struct {
+	vars starExpr // nil for an if clause
+	iter starExpr // or the condition of an if clause
+}
+
+// starFuncDef is the definition of a function or lambda.
+type starFuncDef struct {
+	name     string
+	params   []string
+	defaults []starExpr // of the last parameters
+	body     []starStmt // a lambda's is a single return
+	locals   map[string]bool
+}
+
+func (p starPos) position() starPos { return p }
+
+// isAssignment reports whether t is = or an augmented assignment.
+func (t starToken) isAssignment() bool {
+	return t.kind == starOp && strings.HasSuffix(t.text, "=") && !starComparisons[t.text]
+}
+
+// starParser builds the syntax tree from the tokens of a script.
+type starParser struct {
+	file   string
+	tokens []starToken
+	i      int
+	locals []map[string]bool // names bound in the functions being parsed
+}
+
+// parseStarlark parses the script src, read from file.
+func parseStarlark(file, src string) (stmts []starStmt, err error) {
+	tokens, err := scanStarlark(file, src)
+	if err != nil {
+		return nil, err
+	}
+	defer recoverStarError(&err)
+	p := &starParser{file: file, tokens: tokens}
+	for p.peek().kind != starEOF {
+		stmts = append(stmts, p.statement()...)
+	}
+	return stmts, nil
+}
+
+func (p *starParser) peek() starToken { return p.tokens[p.i] }
+
+func (p *starParser) next() starToken {
+	t := p.tokens[p.i]
+	if t.kind != starEOF {
+		p.i++
+	}
+	return t
+}
+
+// is reports whether the next token is the operator or keyword text.
+func (p *starParser) is(text string) bool {
+	t := p.peek()
+	return (t.kind == starOp || t.kind == starKeyword) && t.text == text
+}
+
+// accept consumes the next token if it is the operator or keyword text.
+func (p *starParser) accept(text string) bool {
+	if p.is(text) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+// expect consumes the operator or keyword text, failing if it is not next.
+func (p *starParser) expect(text string) starToken {
+	if !p.is(text) {
+		p.unexpected("expected " + text)
+	}
+	return p.next()
+}
+
+// errorf aborts parsing with an error at pos.
+func (p *starParser) errorf(pos starPos, format string, args ...interface{}) {
+	panic(&starError{file: p.file, pos: pos, message: fmt.Sprintf(format, args...)})
+}
+
+// unexpected aborts parsing at the next token.
+func (p *starParser) unexpected(want string) {
+	t := p.peek()
+	what := t.text
+	switch t.kind {
+	case starEOF:
+		what = "end of file"
+	case starNewline:
+		what = "newline"
+	case starIndent:
+		what = "indent"
+	case starOutdent:
+		what = "outdent"
+	case starLiteral:
+		what = "literal"
+	}
+	p.errorf(t.pos, "syntax error: unexpected %s, %s", what, want)
+}
+
+// bind records name as bound in the function being parsed.
+func (p *starParser) bind(name string) {
+	if n := len(p.locals); n > 0 {
+		p.locals[n-1][name] = true
+	}
+}
+
+// bindTarget records the names assigned by the target x.
+func (p *starParser) bindTarget(x starExpr) {
+	switch x := x.(type) {
+	case *starIdentExpr:
+		p.bind(x.name)
+	case *starTupleExpr:
+		for _, e := range x.elems {
+			p.bindTarget(e)
+		}
+	case *starListExpr:
+		for _, e := range x.elems {
+			p.bindTarget(e)
+		}
+	case *starIndexExpr, *starDotExpr:
+	default:
+		p.errorf(x.position(), "cannot assign to this expression")
+	}
+}
+
+// statement parses a statement, or the simple statements of a line.
+func (p *starParser) statement() []starStmt {
+	t := p.peek()
+	switch {
+	case t.kind == starKeyword && t.text == "def":
+		return []starStmt{p.def()}
+	case t.kind == starKeyword && t.text == "if":
+		p.next()
+		return []starStmt{p.ifStatement(t.pos)}
+	case t.kind == starKeyword && t.text == "for":
+		p.next()
+		vars := p.targets()
+		p.bindTarget(vars)
+		p.expect("in")
+		iter := p.expressionList()
+		p.expect(":")
+		return []starStmt{&starForStmt{starPos: t.pos, vars: vars, iter: iter, body: p.suite()}}
+	case t.kind == starIndent:
+		p.errorf(t.pos, "unexpected indent")
+	}
+	stmts := []starStmt{p.simpleStatement()}
+	for p.accept(";") {
+		if p.peek().kind == starNewline {
+			break
+		}
+		stmts = append(stmts, p.simpleStatement())
+	}
+	if p.peek().kind != starNewline {
+		p.unexpected("expected newline")
+	}
+	p.next()
+	return stmts
+}
+
+// def parses a function definition.
+func (p *starParser) def() starStmt {
+	pos := p.next().pos
+	name := p.peek()
+	if name.kind != starIdent {
+		p.unexpected("expected function name")
+	}
+	p.next()
+	p.bind(name.text)
+	p.expect("(")
+	fn := p.params(name.text, ")")
+	p.expect(")")
+	p.expect(":")
+	p.locals = append(p.locals, fn.locals)
+	fn.body = p.suite()
+	p.locals = p.locals[:len(p.locals)-1]
+	return &starDefStmt{starPos: pos, name: name.text, fn: fn}
+}
+
+// params parses the parameters of a function, up to the operator end.
+func (p *starParser) params(name, end string) *starFuncDef {
+	fn := &starFuncDef{name: name, locals: map[string]bool{}}
+	for !p.is(end) {
+		t := p.peek()
+		if t.kind != starIdent {
+			if t.kind == starOp && (t.text == "*" || t.text == "**") {
+				p.errorf(t.pos, "*args and **kwargs parameters are not supported")
+			}
+			p.unexpected("expected parameter name")
+		}
+		p.next()
+		if fn.locals[t.text] {
+			p.errorf(t.pos, "duplicate parameter %s", t.text)
+		}
+		fn.params = append(fn.params, t.text)
+		fn.locals[t.text] = true
+		if p.accept("=") {
+			fn.defaults = append(fn.defaults, p.test())
+		} else if len(fn.defaults) > 0 {
+			p.errorf(t.pos, "parameter %s without a default follows one with a default", t.text)
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	return fn
+}
+
+// ifStatement parses an if statement after its if or elif.
+func (p *starParser) ifStatement(pos starPos) starStmt {
+	stmt := &starIfStmt{starPos: pos, cond: p.test()}
+	p.expect(":")
+	stmt.then = p.suite()
+	if t := p.peek(); p.accept("elif") {
+		stmt.els = []starStmt{p.ifStatement(t.pos)}
+	} else if p.accept("else") {
+		p.expect(":")
+		stmt.els = p.suite()
+	}
+	return stmt
+}
+
+// suite parses the body of a compound statement: an indented block, or
+// simple statements on the same line.
+func (p *starParser) suite() []starStmt {
+	if p.peek().kind != starNewline {
+		return p.statement()
+	}
+	p.next()
+	if p.peek().kind != starIndent {
+		p.unexpected("expected an indented block")
+	}
+	p.next()
+	var stmts []starStmt
+	for p.peek().kind != starOutdent && p.peek().kind != starEOF {
+		stmts = append(stmts, p.statement()...)
+	}
+	p.next()
+	return stmts
+}
+
+// simpleStatement parses a statement that fits on one line.
+func (p *starParser) simpleStatement() starStmt {
+	t := p.peek()
+	if t.kind == starKeyword {
+		switch t.text {
+		case "return":
+			p.next()
+			if len(p.locals) == 0 {
+				p.errorf(t.pos, "return outside a function")
+			}
+			stmt := &starReturnStmt{starPos: t.pos}
+			if k := p.peek().kind; k != starNewline && !p.is(";") {
+				stmt.value = p.expressionList()
+			}
+			return stmt
+		case "break", "continue", "pass":
+			p.next()
+			return &starBranchStmt{starPos: t.pos, keyword: t.text}
+		}
+	}
+	x := p.expressionList()
+	if op := p.peek(); op.isAssignment() {
+		p.next()
+		if op.text == "=" {
+			p.bindTarget(x)
+		} else if _, ok := x.(*starTupleExpr); ok {
+			p.errorf(op.pos, "cannot use %s with several targets", op.text)
+		} else {
+			p.bindTarget(x)
+		}
+		return &starAssignStmt{starPos: op.pos, op: op.text, lhs: x, rhs: p.expressionList()}
+	}
+	return &starExprStmt{starPos: t.pos, x: x}
+}
+
+// targets parses the variables of a for loop or comprehension.
+func (p *starParser) targets() starExpr {
+	pos := p.peek().pos
+	x := p.primary()
+	if !p.is(",") {
+		return x
+	}
+	elems := []starExpr{x}
+	for p.accept(",") && !p.is("in") {
+		elems = append(elems, p.primary())
+	}
+	return &starTupleExpr{starPos: pos, elems: elems}
+}
+
+// expressionList parses expressions separated by commas, a tuple if there
+// is more than one or a trailing comma.
+func (p *starParser) expressionList() starExpr {
+	pos := p.peek().pos
+	x := p.test()
+	if !p.is(",") {
+		return x
+	}
+	elems := []starExpr{x}
+	for p.accept(",") {
+		if t := p.peek(); t.kind == starNewline || t.isAssignment() || t.kind == starOp && (t.text == ")" || t.text == ";") {
+			break
+		}
+		elems = append(elems, p.test())
+	}
+	return &starTupleExpr{starPos: pos, elems: elems}
+}
+
+// test parses an expression, with conditionals and lambdas.
+func (p *starParser) test() starExpr {
+	if t := p.peek(); p.accept("lambda") {
+		fn := p.params("lambda", ":")
+		p.expect(":")
+		p.locals = append(p.locals, fn.locals)
+		body := p.test()
+		p.locals = p.locals[:len(p.locals)-1]
+		fn.body = []starStmt{&starReturnStmt{starPos: body.position(), value: body}}
+		return &starLambdaExpr{starPos: t.pos, fn: fn}
+	}
+	x := p.or()
+	if t := p.peek(); p.accept("if") {
+		cond := p.or()
+		p.expect("else")
+		return &starCondExpr{starPos: t.pos, cond: cond, then: x, els: p.test()}
+	}
+	return x
+}
+
+// testNoCond parses an expression without a conditional, as in the
+// clauses of a comprehension.
+func (p *starParser) testNoCond() starExpr {
+	if p.is("lambda") {
+		return p.test()
+	}
+	return p.or()
+}
+
+func (p *starParser) or() starExpr {
+	x := p.and()
+	for t := p.peek(); p.accept("or"); t = p.peek() {
+		x = &starBinaryExpr{starPos: t.pos, op: "or", x: x, y: p.and()}
+	}
+	return x
+}
+
+func (p *starParser) and() starExpr {
+	x := p.not()
+	for t := p.peek(); p.accept("and"); t = p.peek() {
+		x = &starBinaryExpr{starPos: t.pos, op: "and", x: x, y: p.not()}
+	}
+	return x
+}
+
+func (p *starParser) not() starExpr {
+	if t := p.peek(); p.accept("not") {
+		return &starUnaryExpr{starPos: t.pos, op: "not", x: p.not()}
+	}
+	return p.comparison()
+}
+
+// starBinaryPrecedence gives the binding strength of the binary operators
+// between comparisons and unary operators.
+var starBinaryPrecedence = map[string]int{
+	"|": 1, "^": 2, "&": 3, "<<": 4, ">>": 4, "+": 5, "-": 5, "*": 6, "/": 6, "//": 6, "%": 6,
+}
+
+// starComparisons are the comparison operators.
+var starComparisons = map[string]bool{"==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true}
+
+// comparison parses comparisons, which do not chain.
+func (p *starParser) comparison() starExpr {
+	x := p.binary(1)
+	t := p.peek()
+	op := t.text
+	switch {
+	case t.kind == starOp && starComparisons[op]:
+		p.next()
+	case t.kind == starKeyword && op == "in":
+		p.next()
+	case t.kind == starKeyword && op == "not" && p.tokens[p.i+1].kind == starKeyword && p.tokens[p.i+1].text == "in":
+		p.i += 2
+		op = "not in"
+	default:
+		return x
+	}
+	y := p.binary(1)
+	if next := p.peek(); next.kind == starOp && starComparisons[next.text] || next.kind == starKeyword && next.text == "in" {
+		p.errorf(next.pos, "comparisons do not chain; use and")
+	}
+	return &starBinaryExpr{starPos: t.pos, op: op, x: x, y: y}
+}
+
+// binary parses binary operators binding at least as strongly as min.
+func (p *starParser) binary(min int) starExpr {
+	x := p.unary()
+	for {
+		t := p.peek()
+		prec, ok := starBinaryPrecedence[t.text]
+		if t.kind != starOp || !ok || prec < min {
+			return x
+		}
+		p.next()
+		x = &starBinaryExpr{starPos: t.pos, op: t.text, x: x, y: p.binary(prec + 1)}
+	}
+}
+
+func (p *starParser) unary() starExpr {
+	if t := p.peek(); t.kind == starOp && (t.text == "-" || t.text == "+" || t.text == "~") {
+		p.next()
+		return &starUnaryExpr{starPos: t.pos, op: t.text, x: p.unary()}
+	}
+	return p.primary()
+}
+
+// primary parses an operand with its calls, indexes, slices, and
+// attributes.
+func (p *starParser) primary() starExpr {
+	x := p.operand()
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("."):
+			name := p.peek()
+			if name.kind != starIdent {
+				p.unexpected("expected attribute name")
+			}
+			p.next()
+			x = &starDotExpr{starPos: t.pos, x: x, name: name.text}
+		case p.accept("("):
+			x = p.call(t.pos, x)
+		case p.accept("["):
+			x = p.index(t.pos, x)
+		default:
+			return x
+		}
+	}
+}
+
+// call parses the arguments of a call after its parenthesis.
+func (p *starParser) call(pos starPos, fn starExpr) starExpr {
+	call := &starCallExpr{starPos: pos, fn: fn}
+	for !p.is(")") {
+		t := p.peek()
+		if t.kind == starOp && (t.text == "*" || t.text == "**") {
+			p.errorf(t.pos, "*args and **kwargs arguments are not supported")
+		}
+		if t.kind == starIdent && p.tokens[p.i+1].kind == starOp && p.tokens[p.i+1].text == "=" {
+			p.i += 2
+			if slicesContainString(call.names, t.text) {
+				p.errorf(t.pos, "keyword argument %s repeated", t.text)
+			}
+			call.names = append(call.names, t.text)
+			call.kwargs = append(call.kwargs, p.test())
+		} else {
+			if len(call.names) > 0 {
+				p.errorf(t.pos, "positional argument after keyword arguments")
+			}
+			call.args = append(call.args, p.test())
+		}
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect(")")
+	return call
+}
+
+// slicesContainString reports whether list has s.
+func slicesContainString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// index parses an index or slice after its bracket.
+func (p *starParser) index(pos starPos, x starExpr) starExpr {
+	var parts [3]starExpr
+	n := 0
+	for {
+		if !p.is(":") && !p.is("]") {
+			parts[n] = p.test()
+		}
+		if n == 2 || !p.accept(":") {
+			break
+		}
+		n++
+	}
+	p.expect("]")
+	if n == 0 {
+		if parts[0] == nil {
+			p.errorf(pos, "syntax error: empty index")
+		}
+		return &starIndexExpr{starPos: pos, x: x, index: parts[0]}
+	}
+	return &starSliceExpr{starPos: pos, x: x, lo: parts[0], hi: parts[1], step: parts[2]}
+}
+
+// operand parses a name, literal, or bracketed expression.
+func (p *starParser) operand() starExpr {
+	t := p.next()
+	switch {
+	case t.kind == starIdent:
+		return &starIdentExpr{starPos: t.pos, name: t.text}
+	case t.kind == starLiteral:
+		return &starLiteralExpr{starPos: t.pos, value: t.value}
+	case t.kind == starOp && t.text == "(":
+		if p.accept(")") {
+			return &starTupleExpr{starPos: t.pos}
+		}
+		x := p.expressionList()
+		p.expect(")")
+		return x
+	case t.kind == starOp && t.text == "[":
+		return p.list(t.pos)
+	case t.kind == starOp && t.text == "{":
+		return p.dict(t.pos)
+	}
+	p.i--
+	p.unexpected("expected an expression")
+	return nil
+}
+
+// list parses a list or list comprehension after its bracket.
+func (p *starParser) list(pos starPos) starExpr {
+	list := &starListExpr{starPos: pos}
+	for !p.is("]") {
+		x := p.test()
+		if len(list.elems) == 0 && p.is("for") {
+			comp := &starCompExpr{starPos: pos, key: x}
+			p.comprehension(comp, "]")
+			return comp
+		}
+		list.elems = append(list.elems, x)
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("]")
+	return list
+}
+
+// dict parses a dict or dict comprehension after its brace.
+func (p *starParser) dict(pos starPos) starExpr {
+	dict := &starDictExpr{starPos: pos}
+	for !p.is("}") {
+		k := p.test()
+		p.expect(":")
+		v := p.test()
+		if len(dict.keys) == 0 && p.is("for") {
+			comp := &starCompExpr{starPos: pos, dict: true, key: k, val: v}
+			p.comprehension(comp, "}")
+			return comp
+		}
+		dict.keys, dict.values = append(dict.keys, k), append(dict.values, v)
+		if !p.accept(",") {
+			break
+		}
+	}
+	p.expect("}")
+	return dict
+}
+
+// comprehension parses the clauses of a comprehension and its closing
+// bracket. Its variables are bound in a scope of its own.
+func (p *starParser) comprehension(comp *starCompExpr, end string) {
+	for {
+		switch {
+		case p.accept("for"):
+			vars := p.targets()
+			p.expect("in")
+			comp.clauses = append(comp.clauses, starCompClause{vars: vars, iter: p.or()})
+		case p.accept("if"):
+			comp.clauses = append(comp.clauses, starCompClause{iter: p.testNoCond()})
+		default:
+			p.expect(end)
+			return
+		}
+	}
+}
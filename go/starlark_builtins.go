@@ -0,0 +1,1485 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// starUniverse holds the builtins every script can use. They compute on
+// values only; none reaches outside the script.
+var starUniverse map[string]interface{}
+
+func init() {
+	starUniverse = map[string]interface{}{
+		"None":  nil,
+		"True":  true,
+		"False": false,
+		"json": &starModule{name: "json", members: map[string]interface{}{
+			"encode": &starBuiltin{name: "encode", fn: starJSONEncode},
+			"decode": &starBuiltin{name: "decode", fn: starJSONDecode},
+		}},
+	}
+	for name, fn := range map[string]starMethod{
+		"abs": starAbs, "all": starAll, "any": starAny, "bool": starBool, "dict": starDictOf,
+		"enumerate": starEnumerate, "fail": starFail, "float": starFloat, "getattr": starGetattr,
+		"hasattr": starHasattr, "int": starInt, "len": starLen, "list": starListOf, "max": starMax,
+		"min": starMin, "range": starRangeOf, "repr": starReprOf, "reversed": starReversed,
+		"sorted": starSorted, "str": starStrOf, "tuple": starTupleOf, "type": starTypeOf, "zip": starZip,
+	} {
+		starUniverse[name] = &starBuiltin{name: name, fn: fn}
+	}
+}
+
+// starFailure is the error raised by fail, whose message is the tool's
+// error.
+type starFailure struct {
+	message string
+}
+
+// Error implements the error interface.
+func (e *starFailure) Error() string {
+	return e.message
+}
+
+// starParam is a parameter of a builtin: its name, ending in ? if it may
+// be left out, and where to store its argument.
+type starParam struct {
+	name string
+	dest *interface{}
+}
+
+// unpackStarArgs binds the arguments of the builtin fn to params. Left out
+// arguments keep the values their destinations hold.
+func unpackStarArgs(fn string, args []interface{}, kwargs []starKwarg, params ...starParam) error {
+	if len(args) > len(params) {
+		return fmt.Errorf("%s() takes at most %d arguments (%d given)", fn, len(params), len(args))
+	}
+	set := make([]bool, len(params))
+	for i, a := range args {
+		*params[i].dest, set[i] = a, true
+	}
+kwargs:
+	for _, kw := range kwargs {
+		for i, p := range params {
+			if strings.TrimSuffix(p.name, "?") == kw.name {
+				if set[i] {
+					return fmt.Errorf("%s() got multiple values for parameter %s", fn, kw.name)
+				}
+				*p.dest, set[i] = kw.value, true
+				continue kwargs
+			}
+		}
+		return fmt.Errorf("%s() got an unexpected keyword argument %s", fn, kw.name)
+	}
+	for i, p := range params {
+		if !set[i] && !strings.HasSuffix(p.name, "?") {
+			return fmt.Errorf("%s() missing argument for parameter %s", fn, p.name)
+		}
+	}
+	return nil
+}
+
+// noStarKwargs fails for the keyword arguments of a builtin taking none.
+func noStarKwargs(fn string, kwargs []starKwarg) error {
+	if len(kwargs) > 0 {
+		return fmt.Errorf("%s() got an unexpected keyword argument %s", fn, kwargs[0].name)
+	}
+	return nil
+}
+
+// starIntArg returns the int argument param of fn.
+func starIntArg(fn, param string, v interface{}) (int64, error) {
+	n, ok := v.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%s(): %s must be an int, not %s", fn, param, starType(v))
+	}
+	return n, nil
+}
+
+// starStringArg returns the string argument param of fn.
+func starStringArg(fn, param string, v interface{}) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("%s(): %s must be a string, not %s", fn, param, starType(v))
+	}
+	return s, nil
+}
+
+// starOne returns the single argument of fn.
+func starOne(fn string, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{}
+	err := unpackStarArgs(fn, args, kwargs, starParam{"x", &x})
+	return x, err
+}
+
+// starIterableArgs returns the elements of the single iterable argument
+// of fn, or of its arguments if it has several, as min and max take them.
+func starIterableArgs(th *starThread, fn string, args []interface{}) ([]interface{}, error) {
+	switch len(args) {
+	case 0:
+		return nil, fmt.Errorf("%s() takes at least 1 argument", fn)
+	case 1:
+		return th.elements(args[0])
+	}
+	return args, nil
+}
+
+func starAbs(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne(b.name, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case int64:
+		if x >= 0 {
+			return x, nil
+		}
+		return starUnary("-", x)
+	case float64:
+		return math.Abs(x), nil
+	}
+	return nil, fmt.Errorf("abs(): %s is not a number", starType(x))
+}
+
+func starAll(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne(b.name, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := th.elements(x)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range elems {
+		if !starTruth(e) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func starAny(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne(b.name, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := th.elements(x)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range elems {
+		if starTruth(e) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func starBool(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{} = false
+	err := unpackStarArgs(b.name, args, kwargs, starParam{"x?", &x})
+	return starTruth(x), err
+}
+
+// starDictOf is dict: of the pairs of an iterable or the entries of a
+// dict, and of keyword arguments.
+func starDictOf(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("dict() takes at most 1 argument (%d given)", len(args))
+	}
+	dict := th.newDict()
+	if len(args) == 1 {
+		if err := starUpdateDict(th, dict, args[0]); err != nil {
+			return nil, err
+		}
+	}
+	for _, kw := range kwargs {
+		if err := dict.set(kw.name, kw.value); err != nil {
+			return nil, err
+		}
+	}
+	return dict, nil
+}
+
+// starUpdateDict sets the entries of a dict, or the pairs of an iterable,
+// in dict.
+func starUpdateDict(th *starThread, dict *starDict, from interface{}) error {
+	if d, ok := from.(*starDict); ok {
+		for i, k := range d.keys {
+			if err := dict.set(k, d.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	pairs, err := th.elements(from)
+	if err != nil {
+		return err
+	}
+	for i, p := range pairs {
+		pair, err := th.elements(p)
+		if err != nil || len(pair) != 2 {
+			return fmt.Errorf("dict: element %d is not a pair", i)
+		}
+		if err := dict.set(pair[0], pair[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func starEnumerate(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{}
+	var start interface{} = int64(0)
+	if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}, starParam{"start?", &start}); err != nil {
+		return nil, err
+	}
+	n, err := starIntArg(b.name, "start", start)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := th.elements(x)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]interface{}, len(elems))
+	for i, e := range elems {
+		pairs[i] = th.newTuple([]interface{}{n + int64(i), e})
+	}
+	return th.newList(pairs), nil
+}
+
+// starFail is fail, ending the call with an error of its arguments.
+func starFail(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	sep := " "
+	for _, kw := range kwargs {
+		if kw.name != "sep" {
+			return nil, fmt.Errorf("fail() got an unexpected keyword argument %s", kw.name)
+		}
+		s, err := starStringArg(b.name, "sep", kw.value)
+		if err != nil {
+			return nil, err
+		}
+		sep = s
+	}
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = starStr(a)
+	}
+	return nil, &starFailure{message: strings.Join(parts, sep)}
+}
+
+func starFloat(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{} = 0.0
+	if err := unpackStarArgs(b.name, args, kwargs, starParam{"x?", &x}); err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case bool:
+		if x {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			return nil, fmt.Errorf("float(): invalid literal %s", strconv.Quote(x))
+		}
+		return f, nil
+	}
+	return nil, fmt.Errorf("float(): cannot convert %s", starType(x))
+}
+
+func starGetattr(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x, name, def interface{}
+	hasDefault := len(args) == 3
+	if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}, starParam{"name", &name}, starParam{"default?", &def}); err != nil {
+		return nil, err
+	}
+	s, err := starStringArg(b.name, "name", name)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := starAttr(x, s); ok {
+		return v, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return nil, fmt.Errorf("%s has no attribute %s", starType(x), s)
+}
+
+func starHasattr(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x, name interface{}
+	if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}, starParam{"name", &name}); err != nil {
+		return nil, err
+	}
+	s, err := starStringArg(b.name, "name", name)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := starAttr(x, s)
+	return ok, nil
+}
+
+// starInt is int, truncating floats and parsing strings in base, which
+// is told by the string's prefix if it is 0.
+func starInt(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{} = int64(0)
+	var base interface{}
+	if err := unpackStarArgs(b.name, args, kwargs, starParam{"x?", &x}, starParam{"base?", &base}); err != nil {
+		return nil, err
+	}
+	if s, ok := x.(string); ok {
+		n := int64(10)
+		if base != nil {
+			var err error
+			if n, err = starIntArg(b.name, "base", base); err != nil {
+				return nil, err
+			}
+			if n != 0 && (n < 2 || n > 36) {
+				return nil, errors.New("int(): base must be 0 or between 2 and 36")
+			}
+		}
+		v, err := strconv.ParseInt(s, int(n), 64)
+		if err != nil {
+			return nil, fmt.Errorf("int(): invalid literal %s in base %d", strconv.Quote(s), n)
+		}
+		return v, nil
+	}
+	if base != nil {
+		return nil, errors.New("int(): base given for a value that is not a string")
+	}
+	switch x := x.(type) {
+	case bool:
+		if x {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case int64:
+		return x, nil
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) || math.Abs(x) >= 1<<63 {
+			return nil, fmt.Errorf("int(): cannot convert %s", starFormatFloat(x))
+		}
+		return int64(x), nil
+	}
+	return nil, fmt.Errorf("int(): cannot convert %s", starType(x))
+}
+
+func starLen(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne(b.name, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case string:
+		return int64(len(x)), nil
+	case *starList:
+		return int64(len(x.elems)), nil
+	case starTuple:
+		return int64(len(x)), nil
+	case *starDict:
+		return int64(len(x.keys)), nil
+	case *starRange:
+		return x.len(), nil
+	}
+	return nil, fmt.Errorf("len(): %s has no length", starType(x))
+}
+
+func starListOf(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{} = starTuple{}
+	if err := unpackStarArgs(b.name, args, kwargs, starParam{"x?", &x}); err != nil {
+		return nil, err
+	}
+	elems, err := th.elements(x)
+	if err != nil {
+		return nil, err
+	}
+	return th.newList(append([]interface{}{}, elems...)), nil
+}
+
+func starMax(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	return starExtreme(th, b.name, args, kwargs, 1)
+}
+
+func starMin(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	return starExtreme(th, b.name, args, kwargs, -1)
+}
+
+// starExtreme returns the greatest of the arguments if sign is 1, and the
+// least if it is -1, comparing them by the key function if one is given.
+func starExtreme(th *starThread, fn string, args []interface{}, kwargs []starKwarg, sign int) (interface{}, error) {
+	var key interface{}
+	if err := unpackStarArgs(fn, nil, kwargs, starParam{"key?", &key}); err != nil {
+		return nil, err
+	}
+	elems, err := starIterableArgs(th, fn, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("%s(): empty sequence", fn)
+	}
+	best, bestKey := elems[0], elems[0]
+	if key != nil {
+		bestKey = th.call(starPos{}, key, []interface{}{best}, nil)
+	}
+	for _, e := range elems[1:] {
+		k := e
+		if key != nil {
+			k = th.call(starPos{}, key, []interface{}{e}, nil)
+		}
+		c, err := starCompare(k, bestKey)
+		if err != nil {
+			return nil, err
+		}
+		if c*sign > 0 {
+			best, bestKey = e, k
+		}
+	}
+	return best, nil
+}
+
+// starRangeOf is range, taking a stop or a start, stop, and step.
+func starRangeOf(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	if err := noStarKwargs(b.name, kwargs); err != nil {
+		return nil, err
+	}
+	if len(args) == 0 || len(args) > 3 {
+		return nil, fmt.Errorf("range() takes 1 to 3 arguments (%d given)", len(args))
+	}
+	bounds := make([]int64, len(args))
+	for i, a := range args {
+		n, err := starIntArg(b.name, "each argument", a)
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = n
+	}
+	r := &starRange{step: 1}
+	switch len(bounds) {
+	case 1:
+		r.stop = bounds[0]
+	case 3:
+		r.step = bounds[2]
+		fallthrough
+	case 2:
+		r.start, r.stop = bounds[0], bounds[1]
+	}
+	if r.step == 0 {
+		return nil, errors.New("range(): step cannot be zero")
+	}
+	return r, nil
+}
+
+func starReprOf(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne(b.name, args, kwargs)
+	return th.newString(starRepr(x)), err
+}
+
+func starReversed(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne(b.name, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	elems, err := th.elements(x)
+	if err != nil {
+		return nil, err
+	}
+	reversed := make([]interface{}, len(elems))
+	for i, e := range elems {
+		reversed[len(elems)-1-i] = e
+	}
+	return th.newList(reversed), nil
+}
+
+// starSorted is sorted, a stable sort by the elements or the results of a
+// key function.
+func starSorted(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x, key interface{}
+	var reverse interface{} = false
+	if err := unpackStarArgs(b.name, args[:min(len(args), 1)], kwargs, starParam{"x", &x}, starParam{"key?", &key}, starParam{"reverse?", &reverse}); err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		return nil, fmt.Errorf("sorted() takes 1 positional argument (%d given)", len(args))
+	}
+	elems, err := th.elements(x)
+	if err != nil {
+		return nil, err
+	}
+	sorted := append([]interface{}{}, elems...)
+	keys := sorted
+	if key != nil {
+		keys = make([]interface{}, len(sorted))
+		for i, e := range sorted {
+			keys[i] = th.call(starPos{}, key, []interface{}{e}, nil)
+		}
+	}
+	order := make([]int, len(sorted))
+	for i := range order {
+		order[i] = i
+	}
+	var cmpErr error
+	desc := starTruth(reverse)
+	sort.SliceStable(order, func(i, j int) bool {
+		c, err := starCompare(keys[order[i]], keys[order[j]])
+		if err != nil && cmpErr == nil {
+			cmpErr = err
+		}
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	})
+	if cmpErr != nil {
+		return nil, cmpErr
+	}
+	result := make([]interface{}, len(order))
+	for i, o := range order {
+		result[i] = sorted[o]
+	}
+	return th.newList(result), nil
+}
+
+func starStrOf(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{} = ""
+	err := unpackStarArgs(b.name, args, kwargs, starParam{"x?", &x})
+	return th.newString(starStr(x)), err
+}
+
+func starTupleOf(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	var x interface{} = starTuple{}
+	if err := unpackStarArgs(b.name, args, kwargs, starParam{"x?", &x}); err != nil {
+		return nil, err
+	}
+	elems, err := th.elements(x)
+	if err != nil {
+		return nil, err
+	}
+	return th.newTuple(append([]interface{}{}, elems...)), nil
+}
+
+func starTypeOf(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne(b.name, args, kwargs)
+	return starType(x), err
+}
+
+func starZip(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	if err := noStarKwargs(b.name, kwargs); err != nil {
+		return nil, err
+	}
+	seqs := make([][]interface{}, len(args))
+	n := -1
+	for i, a := range args {
+		elems, err := th.elements(a)
+		if err != nil {
+			return nil, err
+		}
+		seqs[i] = elems
+		if n < 0 || len(elems) < n {
+			n = len(elems)
+		}
+	}
+	zipped := make([]interface{}, max(n, 0))
+	for i := range zipped {
+		tuple := make([]interface{}, len(seqs))
+		for j, s := range seqs {
+			tuple[j] = s[i]
+		}
+		zipped[i] = th.newTuple(tuple)
+	}
+	return th.newList(zipped), nil
+}
+
+// starJSONEncode is json.encode.
+func starJSONEncode(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne("json.encode", args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	data, err := encodeStarJSON(x)
+	return th.newString(string(data)), err
+}
+
+// starJSONDecode is json.decode.
+func starJSONDecode(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	x, err := starOne("json.decode", args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	s, err := starStringArg("json.decode", "x", x)
+	if err != nil {
+		return nil, err
+	}
+	return decodeStarJSON(th, []byte(s))
+}
+
+// encodeStarJSON encodes v as JSON, keeping the order of dicts. Dicts
+// need string keys, and floats must be finite.
+func encodeStarJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeStarJSON(&buf, v, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeStarJSON(buf *bytes.Buffer, v interface{}, depth int) error {
+	if depth > starMaxReprDepth {
+		return errors.New("json.encode: value nested too deeply")
+	}
+	writeString := func(s string) {
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		enc.Encode(s)
+		buf.Truncate(buf.Len() - 1)
+	}
+	writeAll := func(elems []interface{}) error {
+		buf.WriteByte('[')
+		for i, e := range elems {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeStarJSON(buf, e, depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+		return nil
+	}
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return fmt.Errorf("json.encode: cannot encode %s", starFormatFloat(v))
+		}
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		writeString(v)
+	case *starList:
+		return writeAll(v.elems)
+	case starTuple:
+		return writeAll(v)
+	case *starDict:
+		buf.WriteByte('{')
+		for i, k := range v.keys {
+			s, ok := k.(string)
+			if !ok {
+				return fmt.Errorf("json.encode: dict key %s is not a string", starRepr(k))
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(s)
+			buf.WriteByte(':')
+			if err := writeStarJSON(buf, v.values[i], depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("json.encode: cannot encode %s", starType(v))
+	}
+	return nil
+}
+
+// decodeStarJSON decodes JSON into values allocated by th, keeping the
+// order of objects. Numbers without a fraction or exponent become ints if
+// they fit.
+func decodeStarJSON(th *starThread, data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := readStarJSON(th, dec, 0)
+	if err != nil {
+		return nil, fmt.Errorf("json.decode: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("json.decode: text after the value")
+	}
+	return v, nil
+}
+
+func readStarJSON(th *starThread, dec *json.Decoder, depth int) (interface{}, error) {
+	if depth > starMaxReprDepth {
+		return nil, errors.New("value nested too deeply")
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			list := th.newList(nil)
+			for dec.More() {
+				v, err := readStarJSON(th, dec, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				if err := list.append(v); err != nil {
+					return nil, err
+				}
+			}
+			_, err := dec.Token()
+			return list, err
+		}
+		dict := th.newDict()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := readStarJSON(th, dec, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.set(key, v); err != nil {
+				return nil, err
+			}
+		}
+		_, err := dec.Token()
+		return dict, err
+	case json.Number:
+		return starJSONNumber(tok), nil
+	}
+	return tok, nil
+}
+
+// starJSONNumber returns n as an int if it is written as one and fits,
+// and as a float otherwise.
+func starJSONNumber(n json.Number) interface{} {
+	if !strings.ContainsAny(string(n), ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// starStringMethods are the methods of strings.
+var starStringMethods = map[string]starMethod{
+	"capitalize": starStringFunc(func(s string) string {
+		if s == "" {
+			return s
+		}
+		r, n := utf8.DecodeRuneInString(s)
+		return string(unicode.ToUpper(r)) + strings.ToLower(s[n:])
+	}),
+	"lower": starStringFunc(strings.ToLower),
+	"upper": starStringFunc(strings.ToUpper),
+	"title": starStringFunc(func(s string) string {
+		prev := ' '
+		return strings.Map(func(r rune) rune {
+			defer func() { prev = r }()
+			if unicode.IsLetter(prev) {
+				return unicode.ToLower(r)
+			}
+			return unicode.ToUpper(r)
+		}, s)
+	}),
+	"isalnum":      starStringTest(func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }),
+	"isalpha":      starStringTest(unicode.IsLetter),
+	"isdigit":      starStringTest(unicode.IsDigit),
+	"isspace":      starStringTest(unicode.IsSpace),
+	"islower":      starStringCase(unicode.IsLower, unicode.IsUpper),
+	"isupper":      starStringCase(unicode.IsUpper, unicode.IsLower),
+	"strip":        starStringStrip(strings.Trim, strings.TrimSpace),
+	"lstrip":       starStringStrip(strings.TrimLeft, func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) }),
+	"rstrip":       starStringStrip(strings.TrimRight, func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) }),
+	"startswith":   starStringAffix(strings.HasPrefix),
+	"endswith":     starStringAffix(strings.HasSuffix),
+	"removeprefix": starStringPair(func(s, affix string) interface{} { return strings.TrimPrefix(s, affix) }),
+	"removesuffix": starStringPair(func(s, affix string) interface{} { return strings.TrimSuffix(s, affix) }),
+	"count":        starStringPair(func(s, sub string) interface{} { return int64(strings.Count(s, sub)) }),
+	"find":         starStringPair(func(s, sub string) interface{} { return int64(strings.Index(s, sub)) }),
+	"rfind":        starStringPair(func(s, sub string) interface{} { return int64(strings.LastIndex(s, sub)) }),
+	"index":        starStringIndex(strings.Index),
+	"rindex":       starStringIndex(strings.LastIndex),
+	"partition":    starStringPartition(strings.Index, false),
+	"rpartition":   starStringPartition(strings.LastIndex, true),
+	"elems": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		var elems []interface{}
+		for _, r := range b.recv.(string) {
+			elems = append(elems, string(r))
+		}
+		return th.newList(elems), nil
+	},
+	"format": starFormat,
+	"join": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		elems, err := th.elements(x)
+		if err != nil {
+			return nil, err
+		}
+		parts := make([]string, len(elems))
+		size := 0
+		for i, e := range elems {
+			s, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("join(): element %d is %s, not a string", i, starType(e))
+			}
+			parts[i] = s
+			if size += len(s) + len(b.recv.(string)); size > starMaxLen {
+				return nil, fmt.Errorf("string longer than %d bytes", starMaxLen)
+			}
+		}
+		return th.newString(strings.Join(parts, b.recv.(string))), nil
+	},
+	"replace": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var old, new interface{}
+		var count interface{} = int64(-1)
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"old", &old}, starParam{"new", &new}, starParam{"count?", &count}); err != nil {
+			return nil, err
+		}
+		o, err := starStringArg(b.name, "old", old)
+		if err != nil {
+			return nil, err
+		}
+		n, err := starStringArg(b.name, "new", new)
+		if err != nil {
+			return nil, err
+		}
+		c, err := starIntArg(b.name, "count", count)
+		if err != nil {
+			return nil, err
+		}
+		s := b.recv.(string)
+		if matches := int64(strings.Count(s, o)); c < 0 || c > matches {
+			c = matches
+		}
+		if int64(len(s))+c*int64(len(n)-len(o)) > starMaxLen {
+			return nil, fmt.Errorf("string longer than %d bytes", starMaxLen)
+		}
+		return th.newString(strings.Replace(s, o, n, int(c))), nil
+	},
+	"split":  starStringSplit(false),
+	"rsplit": starStringSplit(true),
+	"splitlines": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var keep interface{} = false
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"keepends?", &keep}); err != nil {
+			return nil, err
+		}
+		s := b.recv.(string)
+		var lines []interface{}
+		for s != "" {
+			i := strings.IndexAny(s, "\r\n")
+			if i < 0 {
+				lines = append(lines, s)
+				break
+			}
+			end := i + 1
+			if s[i] == '\r' && end < len(s) && s[end] == '\n' {
+				end++
+			}
+			if starTruth(keep) {
+				lines = append(lines, s[:end])
+			} else {
+				lines = append(lines, s[:i])
+			}
+			s = s[end:]
+		}
+		return th.newList(lines), nil
+	},
+}
+
+// starStringFunc returns a method computing f of the string.
+func starStringFunc(f func(string) string) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		return th.newString(f(b.recv.(string))), nil
+	}
+}
+
+// starStringTest returns a method reporting whether the string is not
+// empty and f holds for each of its characters.
+func starStringTest(f func(rune) bool) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		s := b.recv.(string)
+		return s != "" && strings.IndexFunc(s, func(r rune) bool { return !f(r) }) < 0, nil
+	}
+}
+
+// starStringCase returns a method reporting whether the string has a
+// character in the case is and none in the case not.
+func starStringCase(is, not func(rune) bool) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		s := b.recv.(string)
+		return strings.IndexFunc(s, is) >= 0 && strings.IndexFunc(s, not) < 0, nil
+	}
+}
+
+// starStringStrip returns a method removing the characters of its
+// argument, or white space without one.
+func starStringStrip(trim func(s, cutset string) string, space func(string) string) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var chars interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"chars?", &chars}); err != nil {
+			return nil, err
+		}
+		if chars == nil {
+			return space(b.recv.(string)), nil
+		}
+		cutset, err := starStringArg(b.name, "chars", chars)
+		if err != nil {
+			return nil, err
+		}
+		return trim(b.recv.(string), cutset), nil
+	}
+}
+
+// starStringAffix returns a method reporting whether the string has a
+// prefix or suffix: its argument, or one of a tuple of them.
+func starStringAffix(has func(s, affix string) bool) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		affixes, ok := x.(starTuple)
+		if !ok {
+			affixes = starTuple{x}
+		}
+		for _, a := range affixes {
+			s, err := starStringArg(b.name, "x", a)
+			if err != nil {
+				return nil, err
+			}
+			if has(b.recv.(string), s) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// starStringPair returns a method computing f of the string and its
+// string argument.
+func starStringPair(f func(s, arg string) interface{}) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		s, err := starStringArg(b.name, "x", x)
+		if err != nil {
+			return nil, err
+		}
+		return f(b.recv.(string), s), nil
+	}
+}
+
+// starStringIndex returns a method finding its argument with find, failing
+// if it is not found.
+func starStringIndex(find func(s, sub string) int) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		sub, err := starStringArg(b.name, "x", x)
+		if err != nil {
+			return nil, err
+		}
+		i := find(b.recv.(string), sub)
+		if i < 0 {
+			return nil, fmt.Errorf("%s(): substring not found", b.name)
+		}
+		return int64(i), nil
+	}
+}
+
+// starStringPartition returns a method splitting the string around the
+// first, or with last the last, occurrence of its argument.
+func starStringPartition(find func(s, sep string) int, last bool) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"sep", &x}); err != nil {
+			return nil, err
+		}
+		sep, err := starStringArg(b.name, "sep", x)
+		if err != nil {
+			return nil, err
+		}
+		if sep == "" {
+			return nil, fmt.Errorf("%s(): empty separator", b.name)
+		}
+		s := b.recv.(string)
+		i := find(s, sep)
+		switch {
+		case i >= 0:
+			return starTuple{s[:i], sep, s[i+len(sep):]}, nil
+		case last:
+			return starTuple{"", "", s}, nil
+		}
+		return starTuple{s, "", ""}, nil
+	}
+}
+
+// starStringSplit returns a method splitting the string at its separator,
+// or at runs of white space without one, at most maxsplit times from the
+// start or, with fromEnd, from the end.
+func starStringSplit(fromEnd bool) starMethod {
+	return func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var sep interface{}
+		var maxsplit interface{} = int64(-1)
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"sep?", &sep}, starParam{"maxsplit?", &maxsplit}); err != nil {
+			return nil, err
+		}
+		n, err := starIntArg(b.name, "maxsplit", maxsplit)
+		if err != nil {
+			return nil, err
+		}
+		s := b.recv.(string)
+		var parts []string
+		if sep == nil {
+			parts = starSplitSpace(s, n, fromEnd)
+		} else {
+			sepStr, err := starStringArg(b.name, "sep", sep)
+			if err != nil {
+				return nil, err
+			}
+			if sepStr == "" {
+				return nil, fmt.Errorf("%s(): empty separator", b.name)
+			}
+			switch {
+			case n < 0:
+				parts = strings.Split(s, sepStr)
+			case !fromEnd:
+				parts = strings.SplitN(s, sepStr, int(min(n, starMaxLen))+1)
+			default:
+				parts = strings.Split(s, sepStr)
+				if keep := int(min(n, starMaxLen)); len(parts) > keep+1 {
+					head := strings.Join(parts[:len(parts)-keep], sepStr)
+					parts = append([]string{head}, parts[len(parts)-keep:]...)
+				}
+			}
+		}
+		elems := make([]interface{}, len(parts))
+		for i, p := range parts {
+			elems[i] = p
+		}
+		return th.newList(elems), nil
+	}
+}
+
+// starSplitSpace splits s at runs of white space, at most n times if n
+// is not negative, from the start or, with fromEnd, from the end. The
+// unsplit rest keeps its white space, as in Python.
+func starSplitSpace(s string, n int64, fromEnd bool) []string {
+	var parts []string
+	if !fromEnd {
+		rest := strings.TrimLeftFunc(s, unicode.IsSpace)
+		for ; n != 0 && rest != ""; n-- {
+			i := strings.IndexFunc(rest, unicode.IsSpace)
+			if i < 0 {
+				break
+			}
+			parts = append(parts, rest[:i])
+			rest = strings.TrimLeftFunc(rest[i:], unicode.IsSpace)
+		}
+		if rest != "" {
+			parts = append(parts, rest)
+		}
+		return parts
+	}
+	rest := strings.TrimRightFunc(s, unicode.IsSpace)
+	for ; n != 0 && rest != ""; n-- {
+		i := strings.LastIndexFunc(rest, unicode.IsSpace)
+		if i < 0 {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(rest[i:])
+		parts = append(parts, rest[i+size:])
+		rest = strings.TrimRightFunc(rest[:i], unicode.IsSpace)
+	}
+	if rest != "" {
+		parts = append(parts, rest)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return parts
+}
+
+// starFormat is str.format, filling in {} and {N} with the arguments and
+// {name} with the keyword arguments, converted with str or, for {!r},
+// repr. Format specifications are not supported.
+func starFormat(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+	s := b.recv.(string)
+	var out strings.Builder
+	auto := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '}' {
+			if i+1 < len(s) && s[i+1] == '}' {
+				out.WriteByte('}')
+				i++
+				continue
+			}
+			return nil, errors.New("format(): single } in the format string")
+		}
+		if c != '{' {
+			out.WriteByte(c)
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '{' {
+			out.WriteByte('{')
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return nil, errors.New("format(): unmatched { in the format string")
+		}
+		field := s[i+1 : i+end]
+		i += end
+		conv := "s"
+		if name, cv, ok := strings.Cut(field, "!"); ok {
+			field, conv = name, cv
+		}
+		if strings.Contains(field, ":") {
+			return nil, errors.New("format(): format specifications are not supported")
+		}
+		var v interface{}
+		switch n, err := strconv.Atoi(field); {
+		case field == "":
+			if auto >= len(args) {
+				return nil, fmt.Errorf("format(): not enough arguments for {} %d", auto)
+			}
+			v = args[auto]
+			auto++
+		case err == nil:
+			if n < 0 || n >= len(args) {
+				return nil, fmt.Errorf("format(): no argument {%d}", n)
+			}
+			v = args[n]
+		default:
+			found := false
+			for _, kw := range kwargs {
+				if kw.name == field {
+					v, found = kw.value, true
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("format(): no keyword argument {%s}", field)
+			}
+		}
+		switch conv {
+		case "s":
+			out.WriteString(starStr(v))
+		case "r":
+			out.WriteString(starRepr(v))
+		default:
+			return nil, fmt.Errorf("format(): unknown conversion !%s", conv)
+		}
+		if out.Len() > starMaxLen {
+			return nil, fmt.Errorf("string longer than %d bytes", starMaxLen)
+		}
+	}
+	return th.newString(out.String()), nil
+}
+
+// starPercent is the % operator of strings, filling in %s, %r, %d, %x,
+// %o, %e, %f, and %g with a value or the elements of a tuple.
+func starPercent(format string, x interface{}) (string, error) {
+	args, ok := x.(starTuple)
+	if !ok {
+		args = starTuple{x}
+	}
+	var out strings.Builder
+	n := 0
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' {
+			out.WriteByte(c)
+			continue
+		}
+		if i++; i >= len(format) {
+			return "", errors.New("incomplete format")
+		}
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if n >= len(args) {
+			return "", errors.New("not enough arguments for format string")
+		}
+		v := args[n]
+		n++
+		switch verb {
+		case 's':
+			out.WriteString(starStr(v))
+		case 'r':
+			out.WriteString(starRepr(v))
+		case 'd', 'i', 'x', 'o':
+			var i int64
+			switch v := v.(type) {
+			case int64:
+				i = v
+			case float64:
+				i = int64(v)
+			default:
+				return "", fmt.Errorf("%%%c format requires a number, not %s", verb, starType(v))
+			}
+			base := map[byte]int{'d': 10, 'i': 10, 'x': 16, 'o': 8}[verb]
+			out.WriteString(strconv.FormatInt(i, base))
+		case 'e', 'f', 'g':
+			var f float64
+			switch v := v.(type) {
+			case int64:
+				f = float64(v)
+			case float64:
+				f = v
+			default:
+				return "", fmt.Errorf("%%%c format requires a number, not %s", verb, starType(v))
+			}
+			fmt.Fprintf(&out, "%"+string(verb), f)
+		default:
+			return "", fmt.Errorf("unsupported format character %q", verb)
+		}
+		if out.Len() > starMaxLen {
+			return "", fmt.Errorf("string longer than %d bytes", starMaxLen)
+		}
+	}
+	if n < len(args) {
+		return "", errors.New("not all arguments converted during string formatting")
+	}
+	return out.String(), nil
+}
+
+// starListMethods are the methods of lists.
+var starListMethods = map[string]starMethod{
+	"append": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		return nil, b.recv.(*starList).append(x)
+	},
+	"clear": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		l := b.recv.(*starList)
+		if err := l.mutable(); err != nil {
+			return nil, err
+		}
+		l.elems = nil
+		return nil, nil
+	},
+	"extend": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		elems, err := th.elements(x)
+		if err != nil {
+			return nil, err
+		}
+		return nil, b.recv.(*starList).extend(elems)
+	},
+	"index": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		for i, e := range b.recv.(*starList).elems {
+			if eq, err := starEqual(e, x); err != nil || eq {
+				return int64(i), err
+			}
+		}
+		return nil, fmt.Errorf("index(): %s not in list", starRepr(x))
+	},
+	"insert": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var index, x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"index", &index}, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		i, err := starIntArg(b.name, "index", index)
+		if err != nil {
+			return nil, err
+		}
+		l := b.recv.(*starList)
+		if err := l.append(nil); err != nil {
+			return nil, err
+		}
+		n := int64(len(l.elems) - 1)
+		if i < 0 {
+			i += n
+		}
+		i = max(0, min(i, n))
+		copy(l.elems[i+1:], l.elems[i:])
+		l.elems[i] = x
+		return nil, nil
+	},
+	"pop": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var index interface{} = int64(-1)
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"index?", &index}); err != nil {
+			return nil, err
+		}
+		l := b.recv.(*starList)
+		if err := l.mutable(); err != nil {
+			return nil, err
+		}
+		i, err := starIndex(index, len(l.elems))
+		if err != nil {
+			return nil, fmt.Errorf("pop(): %w", err)
+		}
+		v := l.elems[i]
+		l.elems = append(l.elems[:i], l.elems[i+1:]...)
+		return v, nil
+	},
+	"remove": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var x interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"x", &x}); err != nil {
+			return nil, err
+		}
+		l := b.recv.(*starList)
+		if err := l.mutable(); err != nil {
+			return nil, err
+		}
+		for i, e := range l.elems {
+			if eq, err := starEqual(e, x); err != nil {
+				return nil, err
+			} else if eq {
+				l.elems = append(l.elems[:i], l.elems[i+1:]...)
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("remove(): %s not in list", starRepr(x))
+	},
+}
+
+// starDictMethods are the methods of dicts.
+var starDictMethods = map[string]starMethod{
+	"clear": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		d := b.recv.(*starDict)
+		if err := d.mutable(); err != nil {
+			return nil, err
+		}
+		*d = starDict{index: map[string]int{}}
+		return nil, nil
+	},
+	"get": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var key, def interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"key", &key}, starParam{"default?", &def}); err != nil {
+			return nil, err
+		}
+		v, found, err := b.recv.(*starDict).get(key)
+		if !found {
+			return def, err
+		}
+		return v, err
+	},
+	"items": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		d := b.recv.(*starDict)
+		items := make([]interface{}, len(d.keys))
+		for i, k := range d.keys {
+			items[i] = th.newTuple([]interface{}{k, d.values[i]})
+		}
+		return th.newList(items), nil
+	},
+	"keys": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		return th.newList(append([]interface{}{}, b.recv.(*starDict).keys...)), nil
+	},
+	"values": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if err := unpackStarArgs(b.name, args, kwargs); err != nil {
+			return nil, err
+		}
+		return th.newList(append([]interface{}{}, b.recv.(*starDict).values...)), nil
+	},
+	"pop": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var key, def interface{}
+		hasDefault := len(args) > 1 || len(kwargs) > 0
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"key", &key}, starParam{"default?", &def}); err != nil {
+			return nil, err
+		}
+		v, found, err := b.recv.(*starDict).delete(key)
+		switch {
+		case err != nil:
+			return nil, err
+		case found:
+			return v, nil
+		case hasDefault:
+			return def, nil
+		}
+		return nil, fmt.Errorf("pop(): key %s not in dict", starRepr(key))
+	},
+	"setdefault": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		var key, def interface{}
+		if err := unpackStarArgs(b.name, args, kwargs, starParam{"key", &key}, starParam{"default?", &def}); err != nil {
+			return nil, err
+		}
+		d := b.recv.(*starDict)
+		v, found, err := d.get(key)
+		if err != nil || found {
+			return v, err
+		}
+		return def, d.set(key, def)
+	},
+	"update": func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error) {
+		if len(args) > 1 {
+			return nil, fmt.Errorf("update() takes at most 1 argument (%d given)", len(args))
+		}
+		d := b.recv.(*starDict)
+		if err := d.mutable(); err != nil {
+			return nil, err
+		}
+		if len(args) == 1 {
+			if err := starUpdateDict(th, d, args[0]); err != nil {
+				return nil, err
+			}
+		}
+		for _, kw := range kwargs {
+			if err := d.set(kw.name, kw.value); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	},
+}
@@ -0,0 +1,1635 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Values of scripts are nil for None, bool, int64, float64, string,
+// starTuple, and the pointer types below. Lists and dicts are frozen, and
+// so immutable, once the script has loaded, which lets calls run
+// concurrently: each call builds its own values.
+
+// starList is a list.
+type starList struct {
+	elems     []interface{}
+	frozen    bool
+	iterators int         // the for loops over the list, which may not modify it
+	th        *starThread // that its growth counts against, nil if none
+}
+
+// starTuple is a tuple.
+type starTuple []interface{}
+
+// starDict is a dict, which keeps its keys in insertion order.
+type starDict struct {
+	keys, values []interface{}
+	index        map[string]int // from the hash keys of keys
+	frozen       bool
+	iterators    int
+	th           *starThread // that its growth counts against, nil if none
+}
+
+// starRange is the result of range.
+type starRange struct {
+	start, stop, step int64
+}
+
+// starFunction is a function defined by def or lambda.
+type starFunction struct {
+	def      *starFuncDef
+	defaults []interface{}
+	env      *starEnv // where it was defined
+	frozen   bool
+}
+
+// starBuiltin is a builtin function, or a method bound to recv.
+type starBuiltin struct {
+	name string
+	recv interface{}
+	fn   func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error)
+}
+
+// starModule is a namespace of builtins, such as json.
+type starModule struct {
+	name    string
+	members map[string]interface{}
+}
+
+// starKwarg is a keyword argument.
+type starKwarg struct {
+	name  string
+	value interface{}
+}
+
+// Limits of scripts, which keep a runaway one from exhausting the server.
+const (
+	starMaxLen       = 1 << 24 // elements of a list, tuple, or dict, and bytes of a string
+	starMaxDepth     = 64      // of nested calls
+	starCheckEvery   = 1 << 10 // steps between checks of the context
+	starMaxReprDepth = 32      // of nested values printed and compared
+	starMaxAlloc     = 1 << 28 // bytes of values a load or call may allocate in all, freed or not
+	starValueSize    = 16      // bytes counted per element of a list, tuple, or dict
+)
+
+// starEnv holds the variables of a module, call, or comprehension. locals
+// are the names bound in a call or comprehension, nil for a module, whose
+// variables are its globals.
+type starEnv struct {
+	vars   map[string]interface{}
+	locals map[string]bool
+	parent *starEnv
+}
+
+// starThread runs the code of a script on behalf of one caller.
+type starThread struct {
+	ctx      context.Context
+	file     string
+	steps    int
+	pos      starPos // of the last step
+	allocs   int     // bytes of values allocated
+	maxAlloc int     // bytes of values it may allocate
+	stack    []*starFuncDef
+}
+
+// starFlow is how the statements of a block ended.
+type starFlow int
+
+const (
+	starFlowNormal starFlow = iota
+	starFlowBreak
+	starFlowContinue
+	starFlowReturn
+)
+
+// newStarThread returns a thread running code of file until ctx is done.
+func newStarThread(ctx context.Context, file string) *starThread {
+	return &starThread{ctx: ctx, file: file, maxAlloc: starMaxAlloc}
+}
+
+// execModule runs the statements of a script, returning its globals,
+// frozen.
+func (th *starThread) execModule(stmts []starStmt) (globals map[string]interface{}, err error) {
+	defer recoverStarError(&err)
+	env := &starEnv{vars: map[string]interface{}{}}
+	if flow, _ := th.exec(env, stmts); flow == starFlowBreak || flow == starFlowContinue {
+		return nil, &starError{file: th.file, message: "break or continue outside a loop"}
+	}
+	for _, v := range env.vars {
+		starFreeze(v)
+	}
+	return env.vars, nil
+}
+
+// callFunction calls fn with args, turning errors into a *starError.
+func (th *starThread) callFunction(fn interface{}, args ...interface{}) (result interface{}, err error) {
+	defer recoverStarError(&err)
+	return th.call(starPos{}, fn, args, nil), nil
+}
+
+// failf aborts the thread with an error at pos.
+func (th *starThread) failf(pos starPos, format string, args ...interface{}) {
+	panic(&starError{file: th.file, pos: pos, message: fmt.Sprintf(format, args...)})
+}
+
+// check aborts the thread with err at pos, unless err is nil. Errors of
+// scripts keep the position they were raised at.
+func (th *starThread) check(pos starPos, err error) {
+	if err == nil {
+		return
+	}
+	var e *starError
+	if errors.As(err, &e) {
+		panic(e)
+	}
+	panic(&starError{file: th.file, pos: pos, message: err.Error(), cause: err})
+}
+
+// step counts a step of the thread, aborting it once its context is done.
+func (th *starThread) step(pos starPos) {
+	th.pos = pos
+	th.steps++
+	if th.steps%starCheckEvery == 0 {
+		if err := th.ctx.Err(); err != nil {
+			panic(&starError{file: th.file, pos: pos, message: err.Error(), cause: err})
+		}
+	}
+}
+
+// alloc counts n bytes of values allocated by the thread, aborting it once
+// it has allocated more than its limit. Values the thread no longer
+// holds still count, so that a loop building garbage stops as well. A nil
+// thread counts nothing.
+func (th *starThread) alloc(n int) {
+	if th == nil {
+		return
+	}
+	th.allocs += n
+	if th.allocs > th.maxAlloc {
+		panic(&starError{file: th.file, pos: th.pos, message: fmt.Sprintf("more than %d bytes allocated", th.maxAlloc)})
+	}
+}
+
+// newList returns a list of elems, allocated by the thread.
+func (th *starThread) newList(elems []interface{}) *starList {
+	th.alloc(len(elems) * starValueSize)
+	return &starList{elems: elems, th: th}
+}
+
+// newTuple returns a tuple of elems, allocated by the thread.
+func (th *starThread) newTuple(elems []interface{}) starTuple {
+	th.alloc(len(elems) * starValueSize)
+	return starTuple(elems)
+}
+
+// newDict returns an empty dict, whose entries the thread allocates.
+func (th *starThread) newDict() *starDict {
+	return &starDict{index: map[string]int{}, th: th}
+}
+
+// newString returns s, allocated by the thread.
+func (th *starThread) newString(s string) string {
+	th.alloc(len(s))
+	return s
+}
+
+// exec runs a block of statements.
+func (th *starThread) exec(env *starEnv, stmts []starStmt) (starFlow, interface{}) {
+	for _, stmt := range stmts {
+		th.step(stmt.position())
+		switch stmt := stmt.(type) {
+		case *starDefStmt:
+			env.vars[stmt.name] = th.function(env, stmt.fn)
+		case *starIfStmt:
+			block := stmt.els
+			if starTruth(th.eval(env, stmt.cond)) {
+				block = stmt.then
+			}
+			if flow, v := th.exec(env, block); flow != starFlowNormal {
+				return flow, v
+			}
+		case *starForStmt:
+			var flow starFlow
+			var result interface{}
+			th.forEach(stmt.iter.position(), th.eval(env, stmt.iter), func(v interface{}) bool {
+				th.assign(env, stmt.vars, v)
+				flow, result = th.exec(env, stmt.body)
+				switch flow {
+				case starFlowBreak:
+					flow = starFlowNormal
+					return false
+				case starFlowContinue:
+					flow = starFlowNormal
+				case starFlowReturn:
+					return false
+				}
+				return true
+			})
+			if flow == starFlowReturn {
+				return flow, result
+			}
+		case *starReturnStmt:
+			var v interface{}
+			if stmt.value != nil {
+				v = th.eval(env, stmt.value)
+			}
+			return starFlowReturn, v
+		case *starBranchStmt:
+			switch stmt.keyword {
+			case "break":
+				return starFlowBreak, nil
+			case "continue":
+				return starFlowContinue, nil
+			}
+		case *starAssignStmt:
+			th.assignStatement(env, stmt)
+		case *starExprStmt:
+			th.eval(env, stmt.x)
+		}
+	}
+	return starFlowNormal, nil
+}
+
+// assignStatement runs an assignment, updating lists in place for +=.
+func (th *starThread) assignStatement(env *starEnv, stmt *starAssignStmt) {
+	if stmt.op == "=" {
+		th.assign(env, stmt.lhs, th.eval(env, stmt.rhs))
+		return
+	}
+	op := strings.TrimSuffix(stmt.op, "=")
+	var old interface{}
+	var x, index interface{}
+	switch lhs := stmt.lhs.(type) {
+	case *starIdentExpr:
+		old = th.lookup(lhs.starPos, env, lhs.name)
+	case *starIndexExpr:
+		x, index = th.eval(env, lhs.x), th.eval(env, lhs.index)
+		v, err := starGetIndex(x, index)
+		th.check(lhs.starPos, err)
+		old = v
+	default:
+		th.failf(stmt.starPos, "cannot use %s with this target", stmt.op)
+	}
+	y := th.eval(env, stmt.rhs)
+	var result interface{}
+	if list, ok := old.(*starList); ok && op == "+" {
+		elems, err := th.elements(y)
+		th.check(stmt.starPos, err)
+		th.check(stmt.starPos, list.extend(elems))
+		result = list
+	} else {
+		v, err := th.binary(op, old, y)
+		th.check(stmt.starPos, err)
+		result = v
+	}
+	switch lhs := stmt.lhs.(type) {
+	case *starIdentExpr:
+		env.vars[lhs.name] = result
+	case *starIndexExpr:
+		th.check(lhs.starPos, starSetIndex(x, index, result))
+	}
+}
+
+// assign assigns v to the target x, unpacking it for tuples and lists.
+func (th *starThread) assign(env *starEnv, x starExpr, v interface{}) {
+	switch x := x.(type) {
+	case *starIdentExpr:
+		env.vars[x.name] = v
+	case *starTupleExpr:
+		th.unpack(env, x.starPos, x.elems, v)
+	case *starListExpr:
+		th.unpack(env, x.starPos, x.elems, v)
+	case *starIndexExpr:
+		th.check(x.starPos, starSetIndex(th.eval(env, x.x), th.eval(env, x.index), v))
+	case *starDotExpr:
+		th.failf(x.starPos, "cannot assign to the attributes of %s", starType(th.eval(env, x.x)))
+	}
+}
+
+// unpack assigns the elements of v to targets.
+func (th *starThread) unpack(env *starEnv, pos starPos, targets []starExpr, v interface{}) {
+	elems, err := th.elements(v)
+	th.check(pos, err)
+	if len(elems) != len(targets) {
+		if len(elems) > len(targets) {
+			th.failf(pos, "too many values to unpack (got %d, want %d)", len(elems), len(targets))
+		}
+		th.failf(pos, "not enough values to unpack (got %d, want %d)", len(elems), len(targets))
+	}
+	for i, target := range targets {
+		th.assign(env, target, elems[i])
+	}
+}
+
+// lookup returns the value of a name: of the innermost call or
+// comprehension binding it, the globals, or the builtins.
+func (th *starThread) lookup(pos starPos, env *starEnv, name string) interface{} {
+	for e := env; e != nil; e = e.parent {
+		if e.locals != nil && !e.locals[name] {
+			continue
+		}
+		if v, ok := e.vars[name]; ok {
+			return v
+		}
+		if e.locals != nil {
+			th.failf(pos, "local variable %s referenced before assignment", name)
+		}
+	}
+	if v, ok := starUniverse[name]; ok {
+		return v
+	}
+	th.failf(pos, "undefined: %s", name)
+	return nil
+}
+
+// function returns the function def defines in env, evaluating its
+// defaults.
+func (th *starThread) function(env *starEnv, def *starFuncDef) *starFunction {
+	fn := &starFunction{def: def, env: env}
+	for _, d := range def.defaults {
+		fn.defaults = append(fn.defaults, th.eval(env, d))
+	}
+	return fn
+}
+
+// eval evaluates an expression.
+func (th *starThread) eval(env *starEnv, x starExpr) interface{} {
+	th.step(x.position())
+	switch x := x.(type) {
+	case *starIdentExpr:
+		return th.lookup(x.starPos, env, x.name)
+	case *starLiteralExpr:
+		return x.value
+	case *starListExpr:
+		elems := make([]interface{}, 0, len(x.elems))
+		for _, e := range x.elems {
+			elems = append(elems, th.eval(env, e))
+		}
+		return th.newList(elems)
+	case *starTupleExpr:
+		elems := make([]interface{}, 0, len(x.elems))
+		for _, e := range x.elems {
+			elems = append(elems, th.eval(env, e))
+		}
+		return th.newTuple(elems)
+	case *starDictExpr:
+		dict := th.newDict()
+		for i, k := range x.keys {
+			key := th.eval(env, k)
+			if _, found, err := dict.get(key); err != nil {
+				th.check(k.position(), err)
+			} else if found {
+				th.failf(k.position(), "duplicate key %s", starRepr(key))
+			}
+			th.check(k.position(), dict.set(key, th.eval(env, x.values[i])))
+		}
+		return dict
+	case *starCompExpr:
+		return th.comprehension(env, x)
+	case *starUnaryExpr:
+		v := th.eval(env, x.x)
+		if x.op == "not" {
+			return !starTruth(v)
+		}
+		result, err := starUnary(x.op, v)
+		th.check(x.starPos, err)
+		return result
+	case *starBinaryExpr:
+		switch x.op {
+		case "and":
+			if v := th.eval(env, x.x); !starTruth(v) {
+				return v
+			}
+			return th.eval(env, x.y)
+		case "or":
+			if v := th.eval(env, x.x); starTruth(v) {
+				return v
+			}
+			return th.eval(env, x.y)
+		}
+		a, b := th.eval(env, x.x), th.eval(env, x.y)
+		th.pos = x.starPos // so an allocation error points at the operator
+		result, err := th.binary(x.op, a, b)
+		th.check(x.starPos, err)
+		return result
+	case *starCondExpr:
+		if starTruth(th.eval(env, x.cond)) {
+			return th.eval(env, x.then)
+		}
+		return th.eval(env, x.els)
+	case *starCallExpr:
+		fn := th.eval(env, x.fn)
+		args := make([]interface{}, len(x.args))
+		for i, a := range x.args {
+			args[i] = th.eval(env, a)
+		}
+		var kwargs []starKwarg
+		for i, name := range x.names {
+			kwargs = append(kwargs, starKwarg{name: name, value: th.eval(env, x.kwargs[i])})
+		}
+		return th.call(x.starPos, fn, args, kwargs)
+	case *starIndexExpr:
+		v, err := starGetIndex(th.eval(env, x.x), th.eval(env, x.index))
+		th.check(x.starPos, err)
+		return v
+	case *starSliceExpr:
+		seq := th.eval(env, x.x)
+		var bounds [3]interface{}
+		for i, b := range []starExpr{x.lo, x.hi, x.step} {
+			if b != nil {
+				bounds[i] = th.eval(env, b)
+			}
+		}
+		v, err := th.slice(seq, bounds[0], bounds[1], bounds[2])
+		th.check(x.starPos, err)
+		return v
+	case *starDotExpr:
+		v := th.eval(env, x.x)
+		attr, ok := starAttr(v, x.name)
+		if !ok {
+			th.failf(x.starPos, "%s has no attribute %s", starType(v), x.name)
+		}
+		return attr
+	case *starLambdaExpr:
+		return th.function(env, x.fn)
+	}
+	th.failf(x.position(), "cannot evaluate %T", x)
+	return nil
+}
+
+// comprehension evaluates a list or dict comprehension in a scope of its
+// own. The first iterable is evaluated outside it, as in Python.
+func (th *starThread) comprehension(env *starEnv, x *starCompExpr) interface{} {
+	scope := &starEnv{vars: map[string]interface{}{}, locals: map[string]bool{}, parent: env}
+	for _, c := range x.clauses {
+		if c.vars != nil {
+			starTargetNames(c.vars, scope.locals)
+		}
+	}
+	list := th.newList(nil)
+	dict := th.newDict()
+	var clause func(i int)
+	clause = func(i int) {
+		if i == len(x.clauses) {
+			if x.dict {
+				k := th.eval(scope, x.key)
+				th.check(x.key.position(), dict.set(k, th.eval(scope, x.val)))
+				return
+			}
+			th.check(x.starPos, list.append(th.eval(scope, x.key)))
+			return
+		}
+		c := x.clauses[i]
+		if c.vars == nil {
+			if starTruth(th.eval(scope, c.iter)) {
+				clause(i + 1)
+			}
+			return
+		}
+		in := scope
+		if i == 0 {
+			in = env
+		}
+		th.forEach(c.iter.position(), th.eval(in, c.iter), func(v interface{}) bool {
+			th.assign(scope, c.vars, v)
+			clause(i + 1)
+			return true
+		})
+	}
+	clause(0)
+	if x.dict {
+		return dict
+	}
+	return list
+}
+
+// starTargetNames adds the names the target x assigns to names.
+func starTargetNames(x starExpr, names map[string]bool) {
+	switch x := x.(type) {
+	case *starIdentExpr:
+		names[x.name] = true
+	case *starTupleExpr:
+		for _, e := range x.elems {
+			starTargetNames(e, names)
+		}
+	case *starListExpr:
+		for _, e := range x.elems {
+			starTargetNames(e, names)
+		}
+	}
+}
+
+// call calls fn. Functions may not call themselves, directly or not, as
+// in Starlark, so every script finishes or runs out of time.
+func (th *starThread) call(pos starPos, fn interface{}, args []interface{}, kwargs []starKwarg) interface{} {
+	switch fn := fn.(type) {
+	case *starBuiltin:
+		v, err := fn.fn(th, fn, args, kwargs)
+		th.check(pos, err)
+		return v
+	case *starFunction:
+		def := fn.def
+		for _, caller := range th.stack {
+			if caller == def {
+				th.failf(pos, "function %s called recursively", def.name)
+			}
+		}
+		if len(th.stack) >= starMaxDepth {
+			th.failf(pos, "calls nested too deeply")
+		}
+		env := &starEnv{vars: make(map[string]interface{}, len(def.locals)), locals: def.locals, parent: fn.env}
+		if len(args) > len(def.params) {
+			th.failf(pos, "%s() takes at most %d arguments (%d given)", def.name, len(def.params), len(args))
+		}
+		for i, a := range args {
+			env.vars[def.params[i]] = a
+		}
+		for _, kw := range kwargs {
+			if !slicesContainString(def.params, kw.name) {
+				th.failf(pos, "%s() got an unexpected keyword argument %s", def.name, kw.name)
+			}
+			if _, ok := env.vars[kw.name]; ok {
+				th.failf(pos, "%s() got multiple values for parameter %s", def.name, kw.name)
+			}
+			env.vars[kw.name] = kw.value
+		}
+		required := len(def.params) - len(fn.defaults)
+		for i, p := range def.params {
+			if _, ok := env.vars[p]; ok {
+				continue
+			}
+			if i < required {
+				th.failf(pos, "%s() missing argument for parameter %s", def.name, p)
+			}
+			env.vars[p] = fn.defaults[i-required]
+		}
+		th.stack = append(th.stack, def)
+		defer func() { th.stack = th.stack[:len(th.stack)-1] }()
+		if flow, v := th.exec(env, def.body); flow == starFlowReturn {
+			return v
+		} else if flow != starFlowNormal {
+			th.failf(pos, "break or continue outside a loop in %s", def.name)
+		}
+		return nil
+	}
+	th.failf(pos, "%s is not callable", starType(fn))
+	return nil
+}
+
+// forEach calls f with the elements of x until it returns false. Lists
+// and dicts may not change while a loop runs over them.
+func (th *starThread) forEach(pos starPos, x interface{}, f func(v interface{}) bool) {
+	switch x := x.(type) {
+	case *starRange:
+		n := x.len()
+		for i := int64(0); i < n; i++ {
+			if !f(x.start + i*x.step) {
+				return
+			}
+		}
+		return
+	case *starList:
+		if !x.frozen {
+			x.iterators++
+			defer func() { x.iterators-- }()
+		}
+		for i := 0; i < len(x.elems); i++ {
+			if !f(x.elems[i]) {
+				return
+			}
+		}
+		return
+	case *starDict:
+		if !x.frozen {
+			x.iterators++
+			defer func() { x.iterators-- }()
+		}
+		for i := 0; i < len(x.keys); i++ {
+			if !f(x.keys[i]) {
+				return
+			}
+		}
+		return
+	}
+	elems, err := th.elements(x)
+	th.check(pos, err)
+	for _, v := range elems {
+		if !f(v) {
+			return
+		}
+	}
+}
+
+// elements returns the elements of the iterable x.
+func (th *starThread) elements(x interface{}) ([]interface{}, error) {
+	switch x := x.(type) {
+	case *starList:
+		return x.elems, nil
+	case starTuple:
+		return x, nil
+	case *starDict:
+		return x.keys, nil
+	case *starRange:
+		n := x.len()
+		if n > starMaxLen {
+			return nil, fmt.Errorf("range has more than %d elements", starMaxLen)
+		}
+		elems := make([]interface{}, n)
+		for i := range elems {
+			elems[i] = x.start + int64(i)*x.step
+		}
+		return elems, nil
+	}
+	return nil, fmt.Errorf("%s is not iterable", starType(x))
+}
+
+// len returns the number of elements of r.
+func (r *starRange) len() int64 {
+	switch {
+	case r.step > 0 && r.start < r.stop:
+		return (r.stop-r.start-1)/r.step + 1
+	case r.step < 0 && r.start > r.stop:
+		return (r.start-r.stop-1)/(-r.step) + 1
+	}
+	return 0
+}
+
+// get returns the value of key.
+func (d *starDict) get(key interface{}) (interface{}, bool, error) {
+	h, err := starHashKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	i, ok := d.index[h]
+	if !ok {
+		return nil, false, nil
+	}
+	return d.values[i], true, nil
+}
+
+// mutable reports why d may not change now, if it may not.
+func (d *starDict) mutable() error {
+	if d.frozen {
+		return errors.New("cannot modify a frozen dict")
+	}
+	if d.iterators > 0 {
+		return errors.New("cannot modify a dict while iterating over it")
+	}
+	return nil
+}
+
+// set sets the value of key.
+func (d *starDict) set(key, value interface{}) error {
+	if err := d.mutable(); err != nil {
+		return err
+	}
+	h, err := starHashKey(key)
+	if err != nil {
+		return err
+	}
+	if i, ok := d.index[h]; ok {
+		d.values[i] = value
+		return nil
+	}
+	if len(d.keys) >= starMaxLen {
+		return fmt.Errorf("dict has more than %d entries", starMaxLen)
+	}
+	d.th.alloc(2*starValueSize + len(h))
+	d.index[h] = len(d.keys)
+	d.keys = append(d.keys, key)
+	d.values = append(d.values, value)
+	return nil
+}
+
+// delete removes key, returning its value.
+func (d *starDict) delete(key interface{}) (interface{}, bool, error) {
+	if err := d.mutable(); err != nil {
+		return nil, false, err
+	}
+	h, err := starHashKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	i, ok := d.index[h]
+	if !ok {
+		return nil, false, nil
+	}
+	v := d.values[i]
+	d.keys = append(d.keys[:i], d.keys[i+1:]...)
+	d.values = append(d.values[:i], d.values[i+1:]...)
+	delete(d.index, h)
+	for h, j := range d.index {
+		if j > i {
+			d.index[h] = j - 1
+		}
+	}
+	return v, true, nil
+}
+
+// mutable reports why l may not change now, if it may not.
+func (l *starList) mutable() error {
+	if l.frozen {
+		return errors.New("cannot modify a frozen list")
+	}
+	if l.iterators > 0 {
+		return errors.New("cannot modify a list while iterating over it")
+	}
+	return nil
+}
+
+// append adds v to the end of l.
+func (l *starList) append(v interface{}) error {
+	return l.extend([]interface{}{v})
+}
+
+// extend adds elems to the end of l.
+func (l *starList) extend(elems []interface{}) error {
+	if err := l.mutable(); err != nil {
+		return err
+	}
+	if len(l.elems)+len(elems) > starMaxLen {
+		return fmt.Errorf("list has more than %d elements", starMaxLen)
+	}
+	l.th.alloc(len(elems) * starValueSize)
+	l.elems = append(l.elems, elems...)
+	return nil
+}
+
+// starFreeze makes v and the values it holds immutable.
+func starFreeze(v interface{}) {
+	switch v := v.(type) {
+	case *starList:
+		if !v.frozen {
+			v.frozen, v.th = true, nil
+			for _, e := range v.elems {
+				starFreeze(e)
+			}
+		}
+	case *starDict:
+		if !v.frozen {
+			v.frozen, v.th = true, nil
+			for i := range v.keys {
+				starFreeze(v.values[i])
+			}
+		}
+	case starTuple:
+		for _, e := range v {
+			starFreeze(e)
+		}
+	case *starFunction:
+		if !v.frozen {
+			v.frozen = true
+			for _, d := range v.defaults {
+				starFreeze(d)
+			}
+			for e := v.env; e != nil && e.locals != nil; e = e.parent {
+				for _, local := range e.vars {
+					starFreeze(local)
+				}
+			}
+		}
+	}
+}
+
+// starHashKey returns the key of v in dicts: the same for values that are
+// equal. Only immutable values are hashable.
+func starHashKey(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "N", nil
+	case bool:
+		if v {
+			return "T", nil
+		}
+		return "F", nil
+	case int64:
+		return "i" + strconv.FormatInt(v, 10), nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<63 {
+			return "i" + strconv.FormatInt(int64(v), 10), nil
+		}
+		return "f" + strconv.FormatFloat(v, 'g', -1, 64), nil
+	case string:
+		return "s" + strconv.Itoa(len(v)) + ":" + v, nil
+	case starTuple:
+		var b strings.Builder
+		b.WriteString("(")
+		for _, e := range v {
+			h, err := starHashKey(e)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(strconv.Itoa(len(h)) + ":" + h)
+		}
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("unhashable type: %s", starType(v))
+}
+
+// starTruth reports whether v counts as true.
+func starTruth(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case int64:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case *starList:
+		return len(v.elems) > 0
+	case starTuple:
+		return len(v) > 0
+	case *starDict:
+		return len(v.keys) > 0
+	case *starRange:
+		return v.len() > 0
+	}
+	return true
+}
+
+// starType returns the name of the type of v.
+func starType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NoneType"
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case *starList:
+		return "list"
+	case starTuple:
+		return "tuple"
+	case *starDict:
+		return "dict"
+	case *starRange:
+		return "range"
+	case *starFunction:
+		return "function"
+	case *starBuiltin:
+		if v.recv != nil {
+			return "builtin_method"
+		}
+		return "builtin_function"
+	case *starModule:
+		return "module"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// starStr returns v as str does: strings as they are, and other values as
+// repr does.
+func starStr(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return starRepr(v)
+}
+
+// starRepr returns v as repr does.
+func starRepr(v interface{}) string {
+	var b strings.Builder
+	writeStarRepr(&b, v, 0)
+	return b.String()
+}
+
+// writeStarRepr writes v as repr does, eliding values nested deeper than
+// starMaxReprDepth, such as a list holding itself.
+func writeStarRepr(b *strings.Builder, v interface{}, depth int) {
+	if depth > starMaxReprDepth {
+		b.WriteString("...")
+		return
+	}
+	writeAll := func(open, close string, elems []interface{}) {
+		b.WriteString(open)
+		for i, e := range elems {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeStarRepr(b, e, depth+1)
+		}
+		b.WriteString(close)
+	}
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("None")
+	case bool:
+		if v {
+			b.WriteString("True")
+		} else {
+			b.WriteString("False")
+		}
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case float64:
+		b.WriteString(starFormatFloat(v))
+	case string:
+		b.WriteString(strconv.Quote(v))
+	case *starList:
+		writeAll("[", "]", v.elems)
+	case starTuple:
+		if len(v) == 1 {
+			writeAll("(", ",)", v)
+		} else {
+			writeAll("(", ")", v)
+		}
+	case *starDict:
+		b.WriteString("{")
+		for i, k := range v.keys {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeStarRepr(b, k, depth+1)
+			b.WriteString(": ")
+			writeStarRepr(b, v.values[i], depth+1)
+		}
+		b.WriteString("}")
+	case *starRange:
+		if v.step == 1 {
+			fmt.Fprintf(b, "range(%d, %d)", v.start, v.stop)
+		} else {
+			fmt.Fprintf(b, "range(%d, %d, %d)", v.start, v.stop, v.step)
+		}
+	case *starFunction:
+		fmt.Fprintf(b, "<function %s>", v.def.name)
+	case *starBuiltin:
+		if v.recv != nil {
+			fmt.Fprintf(b, "<built-in method %s of %s value>", v.name, starType(v.recv))
+		} else {
+			fmt.Fprintf(b, "<built-in function %s>", v.name)
+		}
+	case *starModule:
+		fmt.Fprintf(b, "<module %s>", v.name)
+	default:
+		fmt.Fprintf(b, "%v", v)
+	}
+}
+
+// starFormatFloat formats f as Python does, always telling it from an
+// int.
+func starFormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+// starEqual reports whether x and y are equal. Ints and floats compare by
+// value; other values are equal only to values of their own type.
+func starEqual(x, y interface{}) (bool, error) {
+	return starEqualDepth(x, y, 0)
+}
+
+func starEqualDepth(x, y interface{}, depth int) (bool, error) {
+	if depth > starMaxReprDepth {
+		return false, errors.New("comparison nested too deeply")
+	}
+	if a, b, ok := starNumbers(x, y); ok {
+		return a == b, nil
+	}
+	elemsEqual := func(a, b []interface{}) (bool, error) {
+		if len(a) != len(b) {
+			return false, nil
+		}
+		for i := range a {
+			if eq, err := starEqualDepth(a[i], b[i], depth+1); !eq || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	switch x := x.(type) {
+	case nil:
+		return y == nil, nil
+	case bool, string:
+		return x == y, nil
+	case *starList:
+		if y, ok := y.(*starList); ok {
+			return elemsEqual(x.elems, y.elems)
+		}
+	case starTuple:
+		if y, ok := y.(starTuple); ok {
+			return elemsEqual(x, y)
+		}
+	case *starDict:
+		y, ok := y.(*starDict)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false, nil
+		}
+		for i, k := range x.keys {
+			v, found, _ := y.get(k)
+			if !found {
+				return false, nil
+			}
+			if eq, err := starEqualDepth(x.values[i], v, depth+1); !eq || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case *starRange:
+		if y, ok := y.(*starRange); ok {
+			n := x.len()
+			return n == y.len() && (n == 0 || x.start == y.start && (n == 1 || x.step == y.step)), nil
+		}
+	default:
+		return x == y, nil
+	}
+	return false, nil
+}
+
+// starNumbers returns x and y as floats if both are numbers and one is a
+// float. Callers handle two ints, which floats may not hold exactly.
+func starNumbers(x, y interface{}) (float64, float64, bool) {
+	switch a := x.(type) {
+	case int64:
+		if b, ok := y.(float64); ok {
+			return float64(a), b, true
+		}
+	case float64:
+		switch b := y.(type) {
+		case int64:
+			return a, float64(b), true
+		case float64:
+			return a, b, true
+		}
+	}
+	return 0, 0, false
+}
+
+// starCompare orders x and y, returning a negative number, zero, or a
+// positive number as x is less than, equal to, or greater than y.
+func starCompare(x, y interface{}) (int, error) {
+	return starCompareDepth(x, y, 0)
+}
+
+func starCompareDepth(x, y interface{}, depth int) (int, error) {
+	if depth > starMaxReprDepth {
+		return 0, errors.New("comparison nested too deeply")
+	}
+	if a, ok := x.(int64); ok {
+		if b, ok := y.(int64); ok {
+			switch {
+			case a < b:
+				return -1, nil
+			case a > b:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if a, b, ok := starNumbers(x, y); ok {
+		switch {
+		case a < b:
+			return -1, nil
+		case a > b:
+			return 1, nil
+		case a == b:
+			return 0, nil
+		}
+		return 0, errors.New("cannot compare nan")
+	}
+	compareAll := func(a, b []interface{}) (int, error) {
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c, err := starCompareDepth(a[i], b[i], depth+1); c != 0 || err != nil {
+				return c, err
+			}
+		}
+		return len(a) - len(b), nil
+	}
+	switch a := x.(type) {
+	case string:
+		if b, ok := y.(string); ok {
+			return strings.Compare(a, b), nil
+		}
+	case bool:
+		if b, ok := y.(bool); ok {
+			switch {
+			case a == b:
+				return 0, nil
+			case b:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case *starList:
+		if b, ok := y.(*starList); ok {
+			return compareAll(a.elems, b.elems)
+		}
+	case starTuple:
+		if b, ok := y.(starTuple); ok {
+			return compareAll(a, b)
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s with %s", starType(x), starType(y))
+}
+
+// starUnary applies the arithmetic unary operators.
+func starUnary(op string, v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int64:
+		switch op {
+		case "-":
+			if v == math.MinInt64 {
+				return nil, errors.New("integer overflow")
+			}
+			return -v, nil
+		case "+":
+			return v, nil
+		case "~":
+			return ^v, nil
+		}
+	case float64:
+		switch op {
+		case "-":
+			return -v, nil
+		case "+":
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown unary operation: %s%s", op, starType(v))
+}
+
+// binary applies a binary operator other than and and or.
+func (th *starThread) binary(op string, x, y interface{}) (interface{}, error) {
+	switch op {
+	case "==", "!=":
+		eq, err := starEqual(x, y)
+		return eq == (op == "=="), err
+	case "<", "<=", ">", ">=":
+		c, err := starCompare(x, y)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "in", "not in":
+		found, err := starContains(y, x)
+		return found == (op == "in"), err
+	}
+
+	if a, ok := x.(int64); ok {
+		if b, ok := y.(int64); ok {
+			return starIntBinary(op, a, b)
+		}
+	}
+	if a, b, ok := starNumbers(x, y); ok {
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "/", "//", "%":
+			if b == 0 {
+				return nil, errors.New("floating-point division by zero")
+			}
+			switch op {
+			case "/":
+				return a / b, nil
+			case "//":
+				return math.Floor(a / b), nil
+			}
+			m := math.Mod(a, b)
+			if m != 0 && (m < 0) != (b < 0) {
+				m += b
+			}
+			return m, nil
+		}
+	}
+
+	switch a := x.(type) {
+	case string:
+		switch op {
+		case "+":
+			if b, ok := y.(string); ok {
+				if len(a)+len(b) > starMaxLen {
+					return nil, fmt.Errorf("string longer than %d bytes", starMaxLen)
+				}
+				return th.newString(a + b), nil
+			}
+		case "*":
+			if n, ok := y.(int64); ok {
+				repeated, err := starRepeatString(a, n)
+				if err != nil {
+					return nil, err
+				}
+				return th.newString(repeated), nil
+			}
+		case "%":
+			formatted, err := starPercent(a, y)
+			if err != nil {
+				return nil, err
+			}
+			return th.newString(formatted), nil
+		}
+	case *starList:
+		switch op {
+		case "+":
+			if b, ok := y.(*starList); ok {
+				if len(a.elems)+len(b.elems) > starMaxLen {
+					return nil, fmt.Errorf("list has more than %d elements", starMaxLen)
+				}
+				return th.newList(append(append([]interface{}{}, a.elems...), b.elems...)), nil
+			}
+		case "*":
+			if n, ok := y.(int64); ok {
+				elems, err := starRepeat(a.elems, n)
+				if err != nil {
+					return nil, err
+				}
+				return th.newList(elems), nil
+			}
+		}
+	case starTuple:
+		switch op {
+		case "+":
+			if b, ok := y.(starTuple); ok {
+				if len(a)+len(b) > starMaxLen {
+					return nil, fmt.Errorf("tuple has more than %d elements", starMaxLen)
+				}
+				return th.newTuple(append(append([]interface{}{}, a...), b...)), nil
+			}
+		case "*":
+			if n, ok := y.(int64); ok {
+				elems, err := starRepeat(a, n)
+				if err != nil {
+					return nil, err
+				}
+				return th.newTuple(elems), nil
+			}
+		}
+	case int64:
+		if op == "*" {
+			switch b := y.(type) {
+			case string, *starList, starTuple:
+				return th.binary(op, b, a)
+			}
+		}
+	case *starDict:
+		if b, ok := y.(*starDict); ok && op == "|" {
+			union := th.newDict()
+			for _, d := range []*starDict{a, b} {
+				for i, k := range d.keys {
+					if err := union.set(k, d.values[i]); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return union, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown binary operation: %s %s %s", starType(x), op, starType(y))
+}
+
+// starIntBinary applies an arithmetic or bitwise operator to ints,
+// failing rather than overflowing.
+func starIntBinary(op string, a, b int64) (interface{}, error) {
+	overflow := errors.New("integer overflow")
+	switch op {
+	case "+":
+		r := a + b
+		if (r > a) != (b > 0) {
+			return nil, overflow
+		}
+		return r, nil
+	case "-":
+		r := a - b
+		if (r < a) != (b > 0) {
+			return nil, overflow
+		}
+		return r, nil
+	case "*":
+		if a == 0 || b == 0 {
+			return int64(0), nil
+		}
+		r := a * b
+		if r/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64 {
+			return nil, overflow
+		}
+		return r, nil
+	case "/":
+		if b == 0 {
+			return nil, errors.New("floating-point division by zero")
+		}
+		return float64(a) / float64(b), nil
+	case "//", "%":
+		if b == 0 {
+			return nil, errors.New("integer division by zero")
+		}
+		if a == math.MinInt64 && b == -1 {
+			if op == "%" {
+				return int64(0), nil
+			}
+			return nil, overflow
+		}
+		q, m := a/b, a%b
+		if m != 0 && (m < 0) != (b < 0) {
+			q, m = q-1, m+b
+		}
+		if op == "//" {
+			return q, nil
+		}
+		return m, nil
+	case "&":
+		return a & b, nil
+	case "|":
+		return a | b, nil
+	case "^":
+		return a ^ b, nil
+	case "<<", ">>":
+		if b < 0 {
+			return nil, errors.New("negative shift count")
+		}
+		if op == ">>" {
+			if b > 63 {
+				b = 63
+			}
+			return a >> b, nil
+		}
+		if b > 63 || (a<<b)>>b != a {
+			return nil, overflow
+		}
+		return a << b, nil
+	}
+	return nil, fmt.Errorf("unknown binary operation: int %s int", op)
+}
+
+// starRepeatString returns s repeated n times.
+func starRepeatString(s string, n int64) (string, error) {
+	if n <= 0 || s == "" {
+		return "", nil
+	}
+	if n > starMaxLen/int64(len(s)) {
+		return "", fmt.Errorf("string longer than %d bytes", starMaxLen)
+	}
+	return strings.Repeat(s, int(n)), nil
+}
+
+// starRepeat returns elems repeated n times.
+func starRepeat(elems []interface{}, n int64) ([]interface{}, error) {
+	if n <= 0 || len(elems) == 0 {
+		return []interface{}{}, nil
+	}
+	if n > starMaxLen/int64(len(elems)) {
+		return nil, fmt.Errorf("more than %d elements", starMaxLen)
+	}
+	repeated := make([]interface{}, 0, len(elems)*int(n))
+	for i := int64(0); i < n; i++ {
+		repeated = append(repeated, elems...)
+	}
+	return repeated, nil
+}
+
+// starContains reports whether the container x holds v: as an element,
+// a key of a dict, or a substring.
+func starContains(x, v interface{}) (bool, error) {
+	in := func(elems []interface{}) (bool, error) {
+		for _, e := range elems {
+			if eq, err := starEqual(e, v); eq || err != nil {
+				return eq, err
+			}
+		}
+		return false, nil
+	}
+	switch x := x.(type) {
+	case *starList:
+		return in(x.elems)
+	case starTuple:
+		return in(x)
+	case *starDict:
+		_, found, err := x.get(v)
+		return found, err
+	case string:
+		s, ok := v.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' requires a string, not %s", starType(v))
+		}
+		return strings.Contains(x, s), nil
+	case *starRange:
+		n, ok := v.(int64)
+		if !ok {
+			return false, nil
+		}
+		if x.step > 0 && (n < x.start || n >= x.stop) || x.step < 0 && (n > x.start || n <= x.stop) {
+			return false, nil
+		}
+		return (n-x.start)%x.step == 0, nil
+	}
+	return false, fmt.Errorf("'in' is not supported on %s", starType(x))
+}
+
+// starIndex returns the position index refers to in a sequence of n
+// elements, counting negative ones from the end.
+func starIndex(index interface{}, n int) (int, error) {
+	i, ok := index.(int64)
+	if !ok {
+		return 0, fmt.Errorf("indices must be ints, not %s", starType(index))
+	}
+	if i < 0 {
+		i += int64(n)
+	}
+	if i < 0 || i >= int64(n) {
+		return 0, fmt.Errorf("index %d out of range [0:%d]", index, n)
+	}
+	return int(i), nil
+}
+
+// starGetIndex returns x[index].
+func starGetIndex(x, index interface{}) (interface{}, error) {
+	switch x := x.(type) {
+	case *starList:
+		i, err := starIndex(index, len(x.elems))
+		if err != nil {
+			return nil, err
+		}
+		return x.elems[i], nil
+	case starTuple:
+		i, err := starIndex(index, len(x))
+		if err != nil {
+			return nil, err
+		}
+		return x[i], nil
+	case string:
+		i, err := starIndex(index, len(x))
+		if err != nil {
+			return nil, err
+		}
+		return x[i : i+1], nil
+	case *starRange:
+		n := x.len()
+		if n > math.MaxInt32 {
+			n = math.MaxInt32
+		}
+		i, err := starIndex(index, int(n))
+		if err != nil {
+			return nil, err
+		}
+		return x.start + int64(i)*x.step, nil
+	case *starDict:
+		v, found, err := x.get(index)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("key %s not in dict", starRepr(index))
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("%s is not indexable", starType(x))
+}
+
+// starSetIndex sets x[index] to v.
+func starSetIndex(x, index, v interface{}) error {
+	switch x := x.(type) {
+	case *starList:
+		if x.frozen {
+			return errors.New("cannot modify a frozen list")
+		}
+		i, err := starIndex(index, len(x.elems))
+		if err != nil {
+			return err
+		}
+		x.elems[i] = v
+		return nil
+	case *starDict:
+		return x.set(index, v)
+	}
+	return fmt.Errorf("%s does not support item assignment", starType(x))
+}
+
+// slice returns x[lo:hi:step], where nil bounds are left out.
+func (th *starThread) slice(x, lo, hi, step interface{}) (interface{}, error) {
+	var n int
+	switch x := x.(type) {
+	case *starList:
+		n = len(x.elems)
+	case starTuple:
+		n = len(x)
+	case string:
+		n = len(x)
+	default:
+		return nil, fmt.Errorf("%s cannot be sliced", starType(x))
+	}
+	s := int64(1)
+	if step != nil {
+		v, ok := step.(int64)
+		if !ok {
+			return nil, fmt.Errorf("slice step must be an int, not %s", starType(step))
+		}
+		if v == 0 {
+			return nil, errors.New("slice step cannot be zero")
+		}
+		s = v
+	}
+	bound := func(b interface{}, def int64) (int64, error) {
+		if b == nil {
+			return def, nil
+		}
+		i, ok := b.(int64)
+		if !ok {
+			return 0, fmt.Errorf("slice bounds must be ints, not %s", starType(b))
+		}
+		if i < 0 {
+			i += int64(n)
+		}
+		low, high := int64(0), int64(n)
+		if s < 0 {
+			low, high = -1, int64(n)-1
+		}
+		return max(low, min(i, high)), nil
+	}
+	start, stop := int64(0), int64(n)
+	if s < 0 {
+		start, stop = int64(n)-1, -1
+	}
+	start, err := bound(lo, start)
+	if err != nil {
+		return nil, err
+	}
+	stop, err = bound(hi, stop)
+	if err != nil {
+		return nil, err
+	}
+	var positions []int
+	for i := start; s > 0 && i < stop || s < 0 && i > stop; i += s {
+		positions = append(positions, int(i))
+	}
+	switch x := x.(type) {
+	case *starList:
+		elems := make([]interface{}, len(positions))
+		for j, i := range positions {
+			elems[j] = x.elems[i]
+		}
+		return th.newList(elems), nil
+	case starTuple:
+		elems := make([]interface{}, len(positions))
+		for j, i := range positions {
+			elems[j] = x[i]
+		}
+		return th.newTuple(elems), nil
+	}
+	str := x.(string)
+	if s == 1 {
+		return str[start:max(start, stop)], nil
+	}
+	b := make([]byte, len(positions))
+	for j, i := range positions {
+		b[j] = str[i]
+	}
+	return th.newString(string(b)), nil
+}
+
+// starAttr returns the attribute name of x: a method bound to x, or a
+// member of a module.
+func starAttr(x interface{}, name string) (interface{}, bool) {
+	var methods map[string]starMethod
+	switch x := x.(type) {
+	case string:
+		methods = starStringMethods
+	case *starList:
+		methods = starListMethods
+	case *starDict:
+		methods = starDictMethods
+	case *starModule:
+		v, ok := x.members[name]
+		return v, ok
+	}
+	m, ok := methods[name]
+	if !ok {
+		return nil, false
+	}
+	return &starBuiltin{name: name, recv: x, fn: m}, true
+}
+
+// starMethod is the implementation of a method, called with its receiver
+// in b.recv.
+type starMethod = func(th *starThread, b *starBuiltin, args []interface{}, kwargs []starKwarg) (interface{}, error)
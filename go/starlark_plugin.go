@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Starlark plugins are .star scripts in the plugins directory, each
+// defining one tool with its globals:
+//
+//	name = "word_count"        # the tool's name; the file's by default
+//	description = "Counts the words of a text"
+//	schema = {"type": "object", "properties": {"text": {"type": "string"}}}
+//	annotations = {"readOnlyHint": True}
+//
+//	def handler(args):
+//	    return "%d words" % len(args.get("text", "").split())
+//
+// handler is called with the arguments as a dict. A string it returns is
+// the text of the result, a dict its structured content, and any other
+// value is returned as JSON; fail(message) fails the call with message.
+// Scripts are loaded once and share nothing between calls: their globals
+// are frozen, and they can reach nothing outside the script (see
+// starlark.go), so they extend the server without recompiling it or
+// running other programs.
+
+// starLoadTimeout bounds the running of a script's top-level code.
+const starLoadTimeout = 10 * time.Second
+
+// loadStarlarkPlugins loads every .star file in dir and returns the tools
+// they define.
+func loadStarlarkPlugins(dir string) ([]MCPTool, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.star"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var loaded []MCPTool
+	for _, path := range paths {
+		tool, err := loadStarlarkPlugin(path)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", path, err)
+		}
+		loaded = append(loaded, tool)
+	}
+	return loaded, nil
+}
+
+// loadStarlarkPlugin runs the script at path and returns the tool it
+// defines.
+func loadStarlarkPlugin(path string) (*starlarkTool, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := filepath.Base(path)
+	stmts, err := parseStarlark(file, string(src))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), starLoadTimeout)
+	defer cancel()
+	globals, err := newStarThread(ctx, file).execModule(stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &starlarkTool{
+		file:   file,
+		name:   strings.TrimSuffix(file, ".star"),
+		schema: map[string]interface{}{"type": "object"},
+	}
+	for _, field := range []string{"name", "description"} {
+		v, ok := globals[field]
+		if !ok {
+			continue
+		}
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a string, not %s", field, starType(v))
+		}
+		if field == "name" {
+			t.name = s
+		} else {
+			t.description = s
+		}
+	}
+	if t.name == "" {
+		return nil, errors.New("the tool's name is empty")
+	}
+	if v, ok := globals["schema"]; ok {
+		if err := decodeStarGlobal("schema", v, &t.schema); err != nil {
+			return nil, err
+		}
+	}
+	if v, ok := globals["annotations"]; ok {
+		if err := decodeStarGlobal("annotations", v, &t.annotations); err != nil {
+			return nil, err
+		}
+	}
+	handler, ok := globals["handler"].(*starFunction)
+	if !ok {
+		return nil, errors.New("the script does not define a handler function")
+	}
+	if n := len(handler.def.params); n == 0 || n-len(handler.defaults) > 1 {
+		return nil, errors.New("handler must take one parameter, the arguments")
+	}
+	t.handler = handler
+	return t, nil
+}
+
+// decodeStarGlobal decodes the dict v, the global name, into dest through
+// its JSON encoding.
+func decodeStarGlobal(name string, v interface{}, dest interface{}) error {
+	if _, ok := v.(*starDict); !ok {
+		return fmt.Errorf("%s must be a dict, not %s", name, starType(v))
+	}
+	data, err := encodeStarJSON(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// starlarkTool is the tool of a Starlark plugin.
+type starlarkTool struct {
+	file        string
+	name        string
+	description string
+	schema      map[string]interface{}
+	annotations ToolAnnotations
+	handler     *starFunction
+}
+
+// Name returns the name the script gives the tool.
+func (t *starlarkTool) Name() string {
+	return t.name
+}
+
+// Description returns the script's description of the tool.
+func (t *starlarkTool) Description() string {
+	return t.description
+}
+
+// InputSchema returns the script's schema of the tool's arguments.
+func (t *starlarkTool) InputSchema() map[string]interface{} {
+	return t.schema
+}
+
+// Annotations returns the script's annotations of the tool.
+func (t *starlarkTool) Annotations() ToolAnnotations {
+	return t.annotations
+}
+
+// Execute runs the tool without a deadline of its own.
+func (t *starlarkTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the tool, returning only the content of its result.
+func (t *starlarkTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult calls the script's handler, which stops when ctx is done.
+// Calls of fail, and errors of the script, fail the call with a result
+// flagged as an error.
+func (t *starlarkTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	th := newStarThread(ctx, t.file)
+	v, err := th.callFunction(t.handler, starValueOf(th, args))
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var failure *starFailure
+	if errors.As(err, &failure) {
+		return nil, toolFailure("%s", failure.message)
+	}
+	if err != nil {
+		return nil, toolFailure("%s: %v", t.name, err)
+	}
+	switch v := v.(type) {
+	case nil:
+		return &ToolResult{Content: []ToolContent{}}, nil
+	case string:
+		return &ToolResult{Content: []ToolContent{{Type: "text", Text: v}}}, nil
+	}
+	data, err := encodeStarJSON(v)
+	if err != nil {
+		return nil, toolFailure("%s: the result: %v", t.name, err)
+	}
+	if _, ok := v.(*starDict); ok {
+		return mcp.NewResult().WithStructured(json.RawMessage(data)), nil
+	}
+	return &ToolResult{Content: []ToolContent{{Type: "text", Text: string(data)}}}, nil
+}
+
+// starValueOf converts a value decoded from JSON to a value of a script
+// run by th, sorting the keys of objects. Whole numbers become ints.
+func starValueOf(th *starThread, v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := th.newDict()
+		for _, k := range keys {
+			dict.set(k, starValueOf(th, v[k]))
+		}
+		return dict
+	case []interface{}:
+		elems := make([]interface{}, len(v))
+		for i, e := range v {
+			elems[i] = starValueOf(th, e)
+		}
+		return th.newList(elems)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return int64(v)
+		}
+		return v
+	case json.Number:
+		return starJSONNumber(v)
+	case int:
+		return int64(v)
+	}
+	return v
+}
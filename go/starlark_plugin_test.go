@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStarlarkPlugins are scripts defining a tool each.
+var testStarlarkPlugins = map[string]string{
+	"words.star": `
+name = "word_count"
+description = "Counts the words of a text"
+schema = {
+    "type": "object",
+    "properties": {"text": {"type": "string"}, "top": {"type": "integer"}},
+    "required": ["text"],
+}
+annotations = {"title": "Word count", "readOnlyHint": True}
+
+STOP = ["a", "the"]
+
+def handler(args):
+    words = [w.lower() for w in args["text"].split() if w.lower() not in STOP]
+    if not words:
+        fail("No words in", repr(args["text"]))
+    counts = {}
+    for w in words:
+        counts[w] = counts.get(w, 0) + 1
+    top = sorted(counts.items(), key=lambda kv: (-kv[1], kv[0]))[:args.get("top", 3)]
+    return {"total": len(words), "top": [{"word": w, "count": n} for w, n in top]}
+`,
+	"echo.star": `
+def handler(args):
+    return args.get("value")
+`,
+	"broken.star": `
+def handler(args):
+    return args["missing"]
+`,
+	"spin.star": `
+def handler(args):
+    n = 0
+    for i in range(1 << 62):
+        n += 1
+    return n
+`,
+}
+
+// writeStarlarkPlugins writes the scripts to a directory and loads them,
+// returning the tools by name.
+func writeStarlarkPlugins(t *testing.T, scripts map[string]string) map[string]MCPTool {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded, err := loadStarlarkPlugins(dir)
+	if err != nil {
+		t.Fatalf("loadStarlarkPlugins error: %v", err)
+	}
+	tools := map[string]MCPTool{}
+	for _, tool := range loaded {
+		tools[tool.Name()] = tool
+	}
+	return tools
+}
+
+// Test loading and calling the tools of Starlark plugins
+func TestStarlarkPlugin(t *testing.T) {
+	tools := writeStarlarkPlugins(t, testStarlarkPlugins)
+	if len(tools) != 4 || tools["echo"] == nil || tools["spin"] == nil {
+		t.Fatalf("expected the plugins' tools, got %v", tools)
+	}
+	words := tools["word_count"].(*starlarkTool)
+	if words.Description() != "Counts the words of a text" || words.Annotations().Title != "Word count" || !words.Annotations().ReadOnlyHint {
+		t.Errorf("unexpected definition %+v", words)
+	}
+	if required, _ := words.InputSchema()["required"].([]interface{}); len(required) != 1 || required[0] != "text" {
+		t.Errorf("unexpected schema %v", words.InputSchema())
+	}
+	if schema := tools["echo"].InputSchema(); len(schema) != 1 || schema["type"] != "object" {
+		t.Errorf("expected the default schema, got %v", schema)
+	}
+
+	// Calls run concurrently over the frozen globals.
+	done := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			result, err := words.ExecuteResult(context.Background(), map[string]interface{}{"text": "the cat and the dog and a cat", "top": float64(2)})
+			if err == nil && (len(result.Content) != 1 || result.Content[0].Text != `{"total":5,"top":[{"word":"and","count":2},{"word":"cat","count":2}]}`) {
+				err = fmt.Errorf("unexpected result %v", result.Content)
+			}
+			done <- err
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+
+	var failure *toolResultError
+	if _, err := words.Execute(map[string]interface{}{"text": "a the"}); !errors.As(err, &failure) || failure.content[0].Text != `No words in "a the"` {
+		t.Errorf("expected fail's message as an error result, got %v", err)
+	}
+	if _, err := tools["broken"].Execute(map[string]interface{}{}); !errors.As(err, &failure) || failure.content[0].Text != `broken: broken.star:3:16: key "missing" not in dict` {
+		t.Errorf("expected the script's error as an error result, got %v", err)
+	}
+
+	for value, want := range map[interface{}]string{
+		"text":     "text",
+		float64(3): "3",
+		2.5:        "2.5",
+		nil:        "",
+	} {
+		content, err := tools["echo"].Execute(map[string]interface{}{"value": value})
+		if err != nil {
+			t.Errorf("%v: %v", value, err)
+			continue
+		}
+		got := ""
+		if len(content) > 0 {
+			got = content[0].Text
+		}
+		if got != want {
+			t.Errorf("%v: expected %q, got %q", value, want, got)
+		}
+	}
+}
+
+// Test that a call is stopped by its context
+func TestStarlarkPluginCancel(t *testing.T) {
+	tools := writeStarlarkPlugins(t, map[string]string{"spin.star": testStarlarkPlugins["spin.star"]})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tools["spin"].(ContextTool).ExecuteContext(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+}
+
+// Test that scripts that do not define a tool are reported
+func TestStarlarkPluginErrors(t *testing.T) {
+	for src, want := range map[string]string{
+		"x = (":                               "bad.star:1:6: syntax error",
+		"x = 1\n":                             "the script does not define a handler function",
+		"def handler(): pass\n":               "handler must take one parameter",
+		"name = 1\ndef handler(a): pass\n":    "name must be a string, not int",
+		"schema = []\ndef handler(a): pass\n": "schema must be a dict, not list",
+		"x = {}['k']\n":                       `bad.star:1:7: key "k" not in dict`,
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "bad.star"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadStarlarkPlugins(dir); err == nil || !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), "plugin "+dir) {
+			t.Errorf("%q: expected an error with %q, got %v", src, want, err)
+		}
+	}
+}
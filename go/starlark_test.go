@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// runStarlark runs the script src and returns its globals.
+func runStarlark(t *testing.T, src string) (map[string]interface{}, error) {
+	t.Helper()
+	stmts, err := parseStarlark("test.star", src)
+	if err != nil {
+		return nil, err
+	}
+	return newStarThread(context.Background(), "test.star").execModule(stmts)
+}
+
+// Test that expressions evaluate as in Starlark
+func TestStarlarkExpressions(t *testing.T) {
+	for expr, want := range map[string]string{
+		`1 + 2 * 3`:                                                                      `7`,
+		`(1 + 2) * 3`:                                                                    `9`,
+		`7 // 2, -7 // 2, 7 % -3, -7 % 3`:                                                `(3, -4, -2, 2)`,
+		`7 / 2, 1.5 * 2, 2 if False else 8`:                                              `(3.5, 3.0, 8)`,
+		`1 << 4 | 1, 0xff & ~0x0f, 6 ^ 3, 0o17, 0b101`:                                   `(17, 240, 5, 15, 5)`,
+		`1 < 2 and 2 <= 2, 1 == 1.0, True == 1, "a" < "b"`:                               `(True, True, False, True)`,
+		`None or 0 or "x", 1 and 0, not []`:                                              `("x", 0, True)`,
+		`"ab" * 3, [1] * 2, 2 * (0,)`:                                                    `("ababab", [1, 1], (0, 0))`,
+		`"hello"[1], "hello"[-1], "hello"[1:3], "hello"[::-1]`:                           `("e", "o", "el", "olleh")`,
+		`[0, 1, 2, 3, 4][1::2], (1, 2, 3)[:-1]`:                                          `([1, 3], (1, 2))`,
+		`[x * x for x in range(5) if x % 2 == 0]`:                                        `[0, 4, 16]`,
+		`{k: v for k, v in [("a", 1), ("b", 2)]}`:                                        `{"a": 1, "b": 2}`,
+		`[(x, y) for x in range(2) for y in "ab".elems()]`:                               `[(0, "a"), (0, "b"), (1, "a"), (1, "b")]`,
+		`(lambda x, y=2: x * y)(3)`:                                                      `6`,
+		`3 in [1, 2, 3], "b" in {"b": 1}, "ell" in "hello", 4 not in range(0, 10, 2)`:    `(True, True, True, False)`,
+		`{"b": 1, "a": 2} | {"c": 3}`:                                                    `{"b": 1, "a": 2, "c": 3}`,
+		`"%s is %d (%r) 100%%" % ("x", 42, "y")`:                                         `"x is 42 (\"y\") 100%"`,
+		`"{} and {name}, {0!r}".format("a", name="b")`:                                   `"a and b, \"a\""`,
+		`"a,b,,c".split(","), " a  b ".split(), "a b c".rsplit(None, 1)`:                 `(["a", "b", "", "c"], ["a", "b"], ["a b", "c"])`,
+		`"-".join(["a", "b"]), "  x ".strip(), "xxhixx".strip("x")`:                      `("a-b", "x", "hi")`,
+		`"Hello".upper(), "hello world".title(), "abc".startswith(("x", "a"))`:           `("HELLO", "Hello World", True)`,
+		`"a=b=c".partition("="), "a=b=c".rpartition("="), "abc".find("c")`:               `(("a", "=", "b=c"), ("a=b", "=", "c"), 2)`,
+		`"a\nb\r\nc".splitlines(), "aXbX".replace("X", "-", 1)`:                          `(["a", "b", "c"], "a-bX")`,
+		`len("héllo"), len([1, 2]), len({"a": 1}), len(range(10, 0, -3))`:                `(6, 2, 1, 4)`,
+		`sorted([3, 1, 2]), sorted(["bb", "a", "ccc"], key=len, reverse=True)`:           `([1, 2, 3], ["ccc", "bb", "a"])`,
+		`min(3, 1, 2), max([1, 5, 2]), max(["a", "bbb"], key=len), abs(-3)`:              `(1, 5, "bbb", 3)`,
+		`list(reversed(range(3))), enumerate(["a"], start=1), zip([1, 2], "ab".elems())`: `([2, 1, 0], [(1, "a")], [(1, "a"), (2, "b")])`,
+		`any([0, 1]), all([1, 0]), bool([]), type({}), type(1.0)`:                        `(True, False, False, "dict", "float")`,
+		`int("42"), int("ff", 16), int("0x10", 0), int(3.9), float("1.5"), str(1.0)`:     `(42, 255, 16, 3, 1.5, "1.0")`,
+		`dict([("a", 1)], b=2), tuple([1]), list("ab".elems())`:                          `({"a": 1, "b": 2}, (1,), ["a", "b"])`,
+		`json.encode({"a": [1, 2.5, None, True], "b": "<\"x\">"})`:                       `"{\"a\":[1,2.5,null,true],\"b\":\"<\\\"x\\\">\"}"`,
+		`json.decode('{"z": 1, "a": [1.0, "x", null]}')`:                                 `{"z": 1, "a": [1.0, "x", None]}`,
+		`hasattr("", "upper"), getattr([], "nothing", 7)`:                                `(True, 7)`,
+		`'''a
+b''', r"\n", "\x41\u00e9", 'it\'s'`: `("a\nb", "\\n", "Aé", "it's")`,
+		`{"a": 1}.get("b", 0), {"a": 1}.keys(), {"a": 1}.items()`: `(0, ["a"], [("a", 1)])`,
+		`1.0 / 4, 1e3, 0.1 + 0.2 == 0.3, -(2)`:                    `(0.25, 1000.0, False, -2)`,
+	} {
+		globals, err := runStarlark(t, "result = "+expr+"\n")
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if got := starRepr(globals["result"]); got != want {
+			t.Errorf("%s: expected %s, got %s", expr, want, got)
+		}
+	}
+}
+
+// Test that statements run as in Starlark, with functions closing over
+// their environment and updating values in place
+func TestStarlarkStatements(t *testing.T) {
+	globals, err := runStarlark(t, `
+def counter(words):
+    counts = {}
+    for w in words:
+        if w == "stop":
+            break
+        elif w.startswith("#"):
+            continue
+        else:
+            counts[w] = counts.get(w, 0) + 1
+    return counts
+
+def make_adder(n):
+    def add(x): return x + n
+    return add
+
+def swap(pair):
+    a, b = pair
+    return b, a
+
+def accumulate(items, total=0):
+    acc = []
+    for i, item in enumerate(items):
+        total += item
+        acc += [total]
+    acc.append(len(acc)); acc.insert(0, -1)
+    return acc
+
+def fizz(n):
+    out = []
+    for i in range(1, n + 1):
+        out.append("Fizz" if i % 3 == 0 else str(i))
+    return ",".join(out)
+
+def pops():
+    d = {"a": 1, "b": 2, "c": 3}
+    d.pop("b")
+    l = [1, 2, 3]
+    l.remove(2)
+    first = l.pop(0)
+    return d, l, first, d.setdefault("z", 9), d
+
+counts = counter(["a", "#x", "b", "a", "stop", "a"])
+add2 = make_adder(2)
+added = add2(40)
+swapped = swap((1, 2))
+acc = accumulate([1, 2, 3])
+fizzed = fizz(6)
+popped = pops()
+if added > 40: big = True
+else: big = False
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"counts":  `{"a": 2, "b": 1}`,
+		"added":   `42`,
+		"swapped": `(2, 1)`,
+		"acc":     `[-1, 1, 3, 6, 3]`,
+		"fizzed":  `"1,2,Fizz,4,5,Fizz"`,
+		"popped":  `({"a": 1, "c": 3, "z": 9}, [3], 1, 9, {"a": 1, "c": 3, "z": 9})`,
+		"big":     `True`,
+	} {
+		if got := starRepr(globals[name]); got != want {
+			t.Errorf("%s: expected %s, got %s", name, want, got)
+		}
+	}
+}
+
+// Test that syntax and runtime errors are reported with their positions,
+// and that scripts cannot loop forever, recurse, or change frozen values
+func TestStarlarkErrors(t *testing.T) {
+	for _, tc := range []struct {
+		src, want string
+	}{
+		{"x = 1 +\n", "test.star:1:8: syntax error: unexpected newline, expected an expression"},
+		{"while True:\n    pass\n", "test.star:1:1: while is reserved"},
+		{"def f():\n x = 1\n  y = 2\n", "test.star:3:3: unexpected indent"},
+		{"def f():\n    x = 1\n  y = 2\n", "test.star:3:3: unindent does not match any outer indentation level"},
+		{"x = 'abc\n", "test.star:1:5: unterminated string"},
+		{"x = 012\n", "invalid int literal 012: use 0o for octal"},
+		{"x = 1 < 2 < 3\n", "comparisons do not chain"},
+		{"return 1\n", "return outside a function"},
+		{"def f(a=1, b): pass\n", "parameter b without a default follows one with a default"},
+		{"x = y\n", "test.star:1:5: undefined: y"},
+		{"def f():\n    y = x\n    x = 1\nf()\n", "test.star:2:9: local variable x referenced before assignment"},
+		{"x = 1 / 0\n", "test.star:1:7: floating-point division by zero"},
+		{"x = 9223372036854775807 + 1\n", "integer overflow"},
+		{"x = [1][5]\n", "test.star:1:8: index 5 out of range [0:1]"},
+		{"x = {}['k']\n", `key "k" not in dict`},
+		{"x = {[]: 1}\n", "unhashable type: list"},
+		{"x = 1 + 'a'\n", "unknown binary operation: int + string"},
+		{"x = len(1, 2)\n", "len() takes at most 1 arguments (2 given)"},
+		{"def f(a): pass\nf(b=1)\n", "f() got an unexpected keyword argument b"},
+		{"def f(a): pass\nf()\n", "f() missing argument for parameter a"},
+		{"def f(n): return f(n)\nf(1)\n", "test.star:1:19: function f called recursively"},
+		{"x = [1]\ndef f(): x.append(2)\n", ""},
+		{"l = [1, 2]\nfor x in l:\n    l.append(x)\n", "cannot modify a list while iterating over it"},
+		{"x = 'a' * (1 << 30)\n", "string longer than 16777216 bytes"},
+		{"x = list(range(1 << 40))\n", "range has more than 16777216 elements"},
+		{"fail('bad', 1)\n", "test.star:1:5: bad 1"},
+		{"x = [y for y in 'abc']\n", "string is not iterable"},
+	} {
+		_, err := runStarlark(t, tc.src)
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%q: %v", tc.src, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%q: expected an error with %q, got %v", tc.src, tc.want, err)
+		}
+	}
+
+	globals, err := runStarlark(t, "x = [1]\ndef f(): x.append(2)\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	th := newStarThread(context.Background(), "test.star")
+	if _, err := th.callFunction(globals["f"]); err == nil || !strings.Contains(err.Error(), "cannot modify a frozen list") {
+		t.Errorf("expected the frozen list to refuse the change, got %v", err)
+	}
+
+	globals, err = runStarlark(t, "def spin():\n    for i in range(1 << 62):\n        pass\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newStarThread(ctx, "test.star").callFunction(globals["spin"]); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected the loop to stop, got %v", err)
+	}
+}
+
+// Test that a call stops once it has allocated too much in all, even if
+// it keeps little of it, and that the next call starts afresh
+func TestStarlarkAllocLimit(t *testing.T) {
+	globals, err := runStarlark(t, `
+def strings():
+    for i in range(1 << 20):
+        s = "x" * 1000
+
+def lists():
+    for i in range(1 << 20):
+        l = [0] * 1000
+
+def growing():
+    l = []
+    for i in range(1 << 20):
+        l.append(i)
+
+def dicts():
+    d = {}
+    for i in range(1 << 20):
+        d[i] = i
+
+def decoded():
+    for i in range(1 << 20):
+        json.decode("[" + ",".join(["0"] * 100) + "]")
+
+def modest():
+    return len([str(i) for i in range(1000)])
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fn := range []string{"strings", "lists", "growing", "dicts", "decoded"} {
+		th := newStarThread(context.Background(), "test.star")
+		th.maxAlloc = 1 << 20
+		if _, err := th.callFunction(globals[fn]); err == nil || !strings.Contains(err.Error(), "more than 1048576 bytes allocated") {
+			t.Errorf("%s: expected the allocation limit, got %v", fn, err)
+		}
+		th = newStarThread(context.Background(), "test.star")
+		th.maxAlloc = 1 << 20
+		if v, err := th.callFunction(globals["modest"]); err != nil || v != int64(1000) {
+			t.Errorf("after %s: expected a modest call to run, got %v, %v", fn, v, err)
+		}
+	}
+
+	// The default limit stops garbage that no other limit sees.
+	globals, err = runStarlark(t, `
+def big():
+    for i in range(1 << 20):
+        s = "x" * (1 << 20)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("test.star:4:17: more than %d bytes allocated", starMaxAlloc)
+	if _, err := newStarThread(context.Background(), "test.star").callFunction(globals["big"]); err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"math/bits"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Recent calls, which percentiles and error rates are computed over, are
+// those of the last statsWindows windows of statsWindow each, so the
+// figures follow a tool's behavior now rather than since the server
+// started.
+const (
+	statsWindow  = time.Minute
+	statsWindows = 5
+)
+
+// toolStats tracks usage of every tool served by a Server.
+type toolStats struct {
+	mu     sync.Mutex
+	byTool map[string]*toolStat
+	now    func() time.Time // replaced in tests
+}
+
+// toolStat holds the counters of a single tool since the server started
+// and those of its recent windows.
+type toolStat struct {
+	calls   uint64
+	errors  uint64
+	total   time.Duration // of all calls
+	windows [statsWindows]statWindow
+}
+
+// statWindow holds the calls of a tool that started within one window.
+type statWindow struct {
+	start   time.Time // zero if unused
+	calls   uint64
+	errors  uint64
+	latency latencyHistogram
+}
+
+// toolStatSnapshot is the reported form of a toolStat. Percentiles, the
+// maximum and the error rate are of the recent calls.
+type toolStatSnapshot struct {
+	Calls        uint64  `json:"calls"`
+	Errors       uint64  `json:"errors"`
+	RecentCalls  uint64  `json:"recentCalls"`
+	RecentErrors uint64  `json:"recentErrors"`
+	ErrorRate    float64 `json:"errorRate"`
+	P50Ms        float64 `json:"p50Ms"`
+	P90Ms        float64 `json:"p90Ms"`
+	P99Ms        float64 `json:"p99Ms"`
+	P999Ms       float64 `json:"p999Ms"`
+	MaxMs        float64 `json:"maxMs"`
+	TotalMs      float64 `json:"totalMs"`
+}
+
+// record adds one call to tool that took d and, if failed, ended in an error.
+func (ts *toolStats) record(tool string, d time.Duration, failed bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.byTool == nil {
+		ts.byTool = map[string]*toolStat{}
+	}
+	st, ok := ts.byTool[tool]
+	if !ok {
+		st = &toolStat{}
+		ts.byTool[tool] = st
+	}
+	st.calls++
+	st.total += d
+	if failed {
+		st.errors++
+	}
+	start := ts.clock().Truncate(statsWindow)
+	w := &st.windows[start.Unix()/int64(statsWindow/time.Second)%statsWindows]
+	if !w.start.Equal(start) {
+		*w = statWindow{start: start}
+	}
+	w.calls++
+	if failed {
+		w.errors++
+	}
+	w.latency.record(d)
+}
+
+// clock returns the current time.
+func (ts *toolStats) clock() time.Time {
+	if ts.now != nil {
+		return ts.now()
+	}
+	return time.Now()
+}
+
+// snapshot returns the current statistics of every tool that has been called.
+func (ts *toolStats) snapshot() map[string]toolStatSnapshot {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	oldest := ts.clock().Truncate(statsWindow).Add(-(statsWindows - 1) * statsWindow)
+	result := make(map[string]toolStatSnapshot, len(ts.byTool))
+	for name, st := range ts.byTool {
+		snap := toolStatSnapshot{Calls: st.calls, Errors: st.errors, TotalMs: durationMs(st.total)}
+		var recent latencyHistogram
+		for _, w := range st.windows {
+			if w.start.IsZero() || w.start.Before(oldest) {
+				continue
+			}
+			snap.RecentCalls += w.calls
+			snap.RecentErrors += w.errors
+			recent.merge(&w.latency)
+		}
+		if snap.RecentCalls > 0 {
+			snap.ErrorRate = float64(snap.RecentErrors) / float64(snap.RecentCalls)
+		}
+		snap.P50Ms = recent.percentileMs(0.50)
+		snap.P90Ms = recent.percentileMs(0.90)
+		snap.P99Ms = recent.percentileMs(0.99)
+		snap.P999Ms = recent.percentileMs(0.999)
+		snap.MaxMs = durationMs(recent.max)
+		result[name] = snap
+	}
+	return result
+}
+
+// histogramDigits is the number of significant bits the latency histogram
+// keeps: durations are counted exactly up to 2^histogramDigits
+// microseconds and, above, to within 1/2^(histogramDigits-1) of their
+// value, which is three significant decimal digits at any magnitude.
+const histogramDigits = 11
+
+// latencyHistogram counts durations in the manner of an HDR histogram,
+// in buckets whose width grows with their magnitude, so that percentiles
+// keep the same relative precision from microseconds to hours. Only the
+// buckets in use are stored.
+type latencyHistogram struct {
+	counts map[int]uint64 // by bucket index
+	count  uint64
+	max    time.Duration
+}
+
+// record counts d.
+func (h *latencyHistogram) record(d time.Duration) {
+	if h.counts == nil {
+		h.counts = map[int]uint64{}
+	}
+	h.counts[histogramBucket(d)]++
+	h.count++
+	h.max = max(h.max, d)
+}
+
+// merge adds the counts of other to h.
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	if other.count == 0 {
+		return
+	}
+	if h.counts == nil {
+		h.counts = map[int]uint64{}
+	}
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.count += other.count
+	h.max = max(h.max, other.max)
+}
+
+// percentileMs returns the p-th percentile in milliseconds, using the
+// nearest-rank method, as the middle of the bucket it falls into rounded
+// to the histogram's precision. An empty histogram reports zero.
+func (h *latencyHistogram) percentileMs(p float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(p*float64(h.count) + 0.999999)
+	rank = max(rank, 1)
+	indexes := make([]int, 0, len(h.counts))
+	for i := range h.counts {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	var seen uint64
+	for _, i := range indexes {
+		seen += h.counts[i]
+		if seen >= rank {
+			ms := durationMs(min(histogramValue(i), h.max))
+			rounded, _ := strconv.ParseFloat(strconv.FormatFloat(ms, 'g', 3, 64), 64)
+			return rounded
+		}
+	}
+	return durationMs(h.max)
+}
+
+// histogramBucket returns the index of the bucket d falls into. Durations
+// below 2^histogramDigits microseconds each have a bucket of their own;
+// above, a bucket of magnitude k holds 2^k microseconds.
+func histogramBucket(d time.Duration) int {
+	us := uint64(max(d.Microseconds(), 0))
+	k := max(bits.Len64(us)-histogramDigits, 0)
+	return k<<histogramDigits | int(us>>k)
+}
+
+// histogramValue returns the middle of bucket i.
+func histogramValue(i int) time.Duration {
+	k := i >> histogramDigits
+	lower := uint64(i&(1<<histogramDigits-1)) << k
+	return time.Duration(lower+(1<<k)/2) * time.Microsecond
+}
+
+// durationMs returns d in milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// statsResult builds the result of the "stats" method and the
+// server_status tool: the health snapshot plus per-tool usage.
+func (s *Server) statsResult() map[string]interface{} {
+	result := s.healthResult()
+	result["toolStats"] = s.stats.snapshot()
+	result["toolStatsWindowSeconds"] = int((statsWindows * statsWindow).Seconds())
+	return result
+}
+
+// serverStatusTool reports the server's health and per-tool statistics, so
+// that agents can see which tools are used and which keep failing.
+type serverStatusTool struct {
+	server *Server
+}
+
+// Name returns the name of the server_status tool.
+func (t *serverStatusTool) Name() string {
+	return "server_status"
+}
+
+// Description returns a brief description of the server_status tool.
+func (t *serverStatusTool) Description() string {
+	return "Reports server uptime, request counts, memory usage, and per-tool call counts, latency percentiles, and recent error rates"
+}
+
+// InputSchema returns the JSON schema for the server_status tool's input parameters.
+func (t *serverStatusTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+// Annotations marks the server_status tool as read-only.
+func (t *serverStatusTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Server status")
+}
+
+// Execute returns the server status as indented JSON text.
+func (t *serverStatusTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	encoded, err := json.MarshalIndent(t.server.statsResult(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
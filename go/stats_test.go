@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// Test percentile computation over recorded latencies
+func TestToolStats(t *testing.T) {
+	now := time.Date(2025, 3, 11, 12, 0, 30, 0, time.UTC)
+	ts := toolStats{now: func() time.Time { return now }}
+	for i := 1; i <= 100; i++ {
+		ts.record("echo", time.Duration(i)*time.Millisecond, i%10 == 0)
+	}
+	snap := ts.snapshot()["echo"]
+	if snap.Calls != 100 || snap.Errors != 10 || snap.RecentCalls != 100 || snap.ErrorRate != 0.1 {
+		t.Errorf("expected 100 calls and 10 errors, got %+v", snap)
+	}
+	if snap.P50Ms != 50 || snap.P90Ms != 90 || snap.P99Ms != 99 || snap.P999Ms != 100 || snap.MaxMs != 100 {
+		t.Errorf("unexpected percentiles %+v", snap)
+	}
+	if snap.TotalMs != 5050 {
+		t.Errorf("expected a total of 5050ms, got %v", snap.TotalMs)
+	}
+
+	// Only the calls of the recent windows count toward percentiles and the
+	// error rate.
+	now = now.Add(4 * statsWindow)
+	ts.record("echo", time.Second, false)
+	if snap := ts.snapshot()["echo"]; snap.RecentCalls != 101 {
+		t.Errorf("expected the first window to be recent still, got %+v", snap)
+	}
+	now = now.Add(statsWindow)
+	ts.record("echo", time.Second, false)
+	snap = ts.snapshot()["echo"]
+	if snap.Calls != 102 || snap.Errors != 10 || snap.RecentCalls != 2 || snap.RecentErrors != 0 || snap.ErrorRate != 0 {
+		t.Errorf("expected the first window to be evicted, got %+v", snap)
+	}
+	if snap.P50Ms != 1000 {
+		t.Errorf("expected old samples to be evicted, got p50=%v", snap.P50Ms)
+	}
+	now = now.Add(time.Hour)
+	if snap := ts.snapshot()["echo"]; snap.RecentCalls != 0 || snap.P50Ms != 0 || snap.Calls != 102 {
+		t.Errorf("expected no recent calls, got %+v", snap)
+	}
+}
+
+// Test that the latency histogram keeps three significant digits at any
+// magnitude
+func TestLatencyHistogram(t *testing.T) {
+	for _, d := range []time.Duration{
+		0, 7 * time.Microsecond, 2047 * time.Microsecond, 2049 * time.Microsecond,
+		123456 * time.Microsecond, 98765 * time.Millisecond, 3 * time.Hour,
+	} {
+		var h latencyHistogram
+		h.record(d)
+		h.record(d + time.Hour) // so that the maximum does not cap the result
+		got := time.Duration(h.percentileMs(0.5) * float64(time.Millisecond))
+		if diff := got - d; diff < -d/180 || diff > d/180 {
+			t.Errorf("%v: expected the median to three significant digits, got %v", d, got)
+		}
+	}
+	if i, j := histogramBucket(2047*time.Microsecond), histogramBucket(2048*time.Microsecond); i >= j {
+		t.Errorf("expected buckets to grow with the duration, got %d and %d", i, j)
+	}
+}
+
+// Test that the server_status tool reports per-tool statistics
+func TestServerStatusTool(t *testing.T) {
+	s := NewServer(WithStatusTool())
+	lines := runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"echo","arguments":{"message":"hi"}},"id":1}`)
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line output, got %d lines", len(lines))
+	}
+
+	content, err := (&serverStatusTool{server: s}).Execute(nil)
+	if err != nil {
+		t.Fatalf("Execute error: %v", err)
+	}
+	var status struct {
+		Tools     int                         `json:"tools"`
+		ToolStats map[string]toolStatSnapshot `json:"toolStats"`
+	}
+	if err := json.Unmarshal([]byte(content[0].Text), &status); err != nil {
+		t.Fatalf("failed to unmarshal status: %v", err)
+	}
+	if status.Tools != len(tools)+1 {
+		t.Errorf("expected %d tools including server_status, got %d", len(tools)+1, status.Tools)
+	}
+	if status.ToolStats["echo"].Calls != 1 {
+		t.Errorf("expected 1 echo call, got %+v", status.ToolStats["echo"])
+	}
+}
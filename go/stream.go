@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// errStreamClosed is returned by SendPartial once the call it belongs to
+// has been answered.
+var errStreamClosed = errors.New("the tool call has already returned")
+
+// toolStream carries the partial output of one tool call. Clients that
+// sent a progress token get each chunk in a notifications/progress message
+// as it comes; for the others the chunks are gathered and put before the
+// call's result.
+type toolStream struct {
+	mu      sync.Mutex
+	w       io.Writer       // where notifications go, nil to gather the chunks
+	token   json.RawMessage // the client's progress token, if w is set
+	limit   int             // largest chunk in bytes of serialized content, zero for none
+	chunks  []ToolContent   // all content sent so far
+	sent    int             // chunks notified so far
+	closing bool            // the call has returned
+}
+
+// streamKey is the context key of the toolStream.
+type streamKey struct{}
+
+// SendPartial delivers content as partial output of the tool call ctx
+// belongs to, before the call returns, so long-running tools such as log
+// tailers can hand over data early. Clients that asked for progress get it
+// at once in a notifications/progress message holding the content as
+// "content" and its text as "message"; for the others it comes first in
+// the call's result. It returns an error if ctx is not a tool call's, or
+// once the call has returned or timed out.
+func SendPartial(ctx context.Context, content ...ToolContent) error {
+	st, _ := ctx.Value(streamKey{}).(*toolStream)
+	if st == nil {
+		return errors.New("not within a tool call")
+	}
+	return st.send(content)
+}
+
+// newToolStream returns the stream of a call, notifying over w if the
+// client sent a progress token and w is not nil.
+func newToolStream(w io.Writer, token json.RawMessage, limit int) *toolStream {
+	st := &toolStream{limit: limit}
+	if len(token) > 0 && w != nil {
+		st.w, st.token = w, token
+	}
+	return st
+}
+
+// send notifies the client of content or gathers it.
+func (st *toolStream) send(content []ToolContent) error {
+	if len(content) == 0 {
+		return nil
+	}
+	if st.limit > 0 {
+		if encoded, err := json.Marshal(content); err == nil && len(encoded) > st.limit {
+			return fmt.Errorf("partial result too large: %d bytes exceeds the limit of %d bytes", len(encoded), st.limit)
+		}
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.closing {
+		return errStreamClosed
+	}
+	st.chunks = append(st.chunks, content...)
+	if st.w == nil {
+		return nil
+	}
+	st.sent++
+	var texts []string
+	for _, c := range content {
+		if c.Type == "text" {
+			texts = append(texts, c.Text)
+		}
+	}
+	params := map[string]interface{}{
+		"progressToken": st.token,
+		"progress":      st.sent,
+		"content":       content,
+	}
+	if len(texts) > 0 {
+		params["message"] = strings.Join(texts, "")
+	}
+	sendNotification(st.w, "notifications/progress", params)
+	return nil
+}
+
+// close stops the stream as the call returns, so that nothing is sent after
+// its response. It returns what the result is to begin with: the gathered
+// chunks, which clients that were not notified have not seen yet.
+func (st *toolStream) close() []ToolContent {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.closing = true
+	if st.w != nil {
+		return nil
+	}
+	return st.chunks
+}
+
+// all returns every chunk sent, as a cached result needs.
+func (st *toolStream) all() []ToolContent {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.chunks
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tailTool streams two lines before returning, and keeps its context so
+// the test can send more after the call has returned.
+type tailTool struct {
+	ctx context.Context
+}
+
+func (t *tailTool) Name() string        { return "tail" }
+func (t *tailTool) Description() string { return "Streams a few lines" }
+func (t *tailTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (t *tailTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+func (t *tailTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	t.ctx = ctx
+	for _, line := range []string{"line 1\n", "line 2\n"} {
+		if err := SendPartial(ctx, ToolContent{Type: "text", Text: line}); err != nil {
+			return nil, err
+		}
+	}
+	return []ToolContent{{Type: "text", Text: "done"}}, nil
+}
+
+// Test that partial output is notified to clients that sent a progress
+// token and put before the result for the others
+func TestSendPartial(t *testing.T) {
+	tool := &tailTool{}
+	s := NewServer(WithTools(tool))
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"tail","arguments":{},"_meta":{"progressToken":"tok-1"}},"id":1}`
+	lines := runServerInput(t, s, input)
+	if len(lines) != 3 {
+		t.Fatalf("expected two notifications and the response, got %v", lines)
+	}
+	for i, want := range []string{
+		`{"jsonrpc":"2.0","method":"notifications/progress","params":{"content":[{"type":"text","text":"line 1\n"}],"message":"line 1\n","progress":1,"progressToken":"tok-1"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/progress","params":{"content":[{"type":"text","text":"line 2\n"}],"message":"line 2\n","progress":2,"progressToken":"tok-1"}}`,
+		`{"id":1,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"done"}]}}`,
+	} {
+		if lines[i] != want {
+			t.Errorf("line %d: expected %s, got %s", i+1, want, lines[i])
+		}
+	}
+	if err := SendPartial(tool.ctx, ToolContent{Type: "text", Text: "late"}); err != errStreamClosed {
+		t.Errorf("expected sending after the call returned to fail, got %v", err)
+	}
+	if err := SendPartial(context.Background()); err == nil {
+		t.Error("expected sending outside a tool call to fail")
+	}
+
+	lines = runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"tail","arguments":{}},"id":2}`)
+	want := `{"id":2,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"line 1\n"},{"type":"text","text":"line 2\n"},{"type":"text","text":"done"}]}}`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("expected the chunks in the result, got %v", lines)
+	}
+}
+
+// Test that HTTP responses, which hold a single message, get the chunks in
+// the result even with a progress token
+func TestSendPartialHTTP(t *testing.T) {
+	tr := newHTTPTransport(NewServer(WithTools(&tailTool{})), nil)
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+	defer tr.endAll()
+
+	sessionID := postMCP(t, ts.URL, "", `{"jsonrpc":"2.0","method":"initialize","params":{},"id":1}`).Header.Get(sessionIDHeader)
+	postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	resp := postMCP(t, ts.URL, sessionID, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"tail","arguments":{},"_meta":{"progressToken":7}},"id":2}`)
+	body, _ := io.ReadAll(resp.Body)
+	if strings.Contains(string(body), "notifications/progress") || !strings.Contains(string(body), `"text":"line 1\n"},{"type":"text","text":"line 2\n"},{"type":"text","text":"done"}`) {
+		t.Errorf("expected a single response with all the content, got %s", body)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// tailLogConfig configures the tail_log tool in the "tailLog" section of
+// the config file.
+type tailLogConfig struct {
+	Files     []string `json:"files"`     // absolute paths or glob patterns of the files tail_log may read
+	MaxLines  int      `json:"maxLines"`  // most lines a call returns, defaults to 1000
+	MaxBytes  int      `json:"maxBytes"`  // most bytes a call returns, initial and followed lines together, defaults to 1 MiB
+	MaxFollow duration `json:"maxFollow"` // longest a call follows a file, defaults to 1m
+}
+
+// Defaults of the tail_log tool.
+const (
+	defaultTailLines     = 50
+	defaultTailMaxLines  = 1000
+	defaultTailMaxBytes  = 1 << 20
+	defaultTailMaxFollow = time.Minute
+	tailReadBlock        = 64 << 10
+)
+
+// tailPollInterval is how often a followed file is checked for new lines.
+var tailPollInterval = 250 * time.Millisecond
+
+// validate reports malformed fields.
+func (c *tailLogConfig) validate() error {
+	if len(c.Files) == 0 {
+		return errors.New("tailLog: files is required")
+	}
+	for _, f := range c.Files {
+		if !filepath.IsAbs(f) {
+			return fmt.Errorf("tailLog: %q is not an absolute path", f)
+		}
+		if _, err := filepath.Match(f, ""); err != nil {
+			return fmt.Errorf("tailLog: invalid pattern %q: %v", f, err)
+		}
+	}
+	if c.MaxLines < 0 || c.MaxBytes < 0 || c.MaxFollow < 0 {
+		return errors.New("tailLog: maxLines, maxBytes, and maxFollow must not be negative")
+	}
+	return nil
+}
+
+// tailLogTool returns the last lines of allowlisted log files and follows
+// them for a while, sending new lines as partial results.
+type tailLogTool struct {
+	cfg tailLogConfig
+}
+
+// newTailLogTool returns the tail_log tool for cfg.
+func newTailLogTool(cfg tailLogConfig) *tailLogTool {
+	if cfg.MaxLines == 0 {
+		cfg.MaxLines = defaultTailMaxLines
+	}
+	if cfg.MaxBytes == 0 {
+		cfg.MaxBytes = defaultTailMaxBytes
+	}
+	if cfg.MaxFollow == 0 {
+		cfg.MaxFollow = duration(defaultTailMaxFollow)
+	}
+	files := make([]string, len(cfg.Files))
+	for i, f := range cfg.Files {
+		files[i] = filepath.Clean(f)
+	}
+	cfg.Files = files
+	return &tailLogTool{cfg: cfg}
+}
+
+// tailLogArgs are the arguments of the tail_log tool.
+type tailLogArgs struct {
+	File   string  `json:"file" description:"Absolute path of the log file"`
+	Lines  int     `json:"lines,omitempty" minimum:"0" description:"Number of last lines to return (default 50)"`
+	Follow float64 `json:"follow,omitempty" minimum:"0" description:"Seconds to keep watching the file, sending new lines as they are written (default 0, not following)"`
+}
+
+// Name returns the name of the tail_log tool.
+func (t *tailLogTool) Name() string {
+	return "tail_log"
+}
+
+// Description returns a brief description of the tail_log tool.
+func (t *tailLogTool) Description() string {
+	return "Returns the last lines of a log file and optionally follows it for a number of seconds, sending the lines written in that time as they come"
+}
+
+// InputSchema returns the JSON schema for the tail_log tool's input
+// parameters, listing the files it may read.
+func (t *tailLogTool) InputSchema() map[string]interface{} {
+	schema := mcp.SchemaFor(tailLogArgs{})
+	props := schema["properties"].(map[string]interface{})
+	file := props["file"].(map[string]interface{})
+	file["description"] = "Absolute path of the log file, one of or matching one of " + strings.Join(t.cfg.Files, ", ")
+	props["lines"].(map[string]interface{})["maximum"] = t.cfg.MaxLines
+	props["follow"].(map[string]interface{})["maximum"] = time.Duration(t.cfg.MaxFollow).Seconds()
+	return schema
+}
+
+// Annotations marks the tail_log tool as read-only. Following a file, a
+// call returns different lines every time.
+func (t *tailLogTool) Annotations() ToolAnnotations {
+	destructive := false
+	return ToolAnnotations{Title: "Tail log", ReadOnlyHint: true, DestructiveHint: &destructive}
+}
+
+// Timeout leaves room for the longest follow.
+func (t *tailLogTool) Timeout() time.Duration {
+	return time.Duration(t.cfg.MaxFollow) + 30*time.Second
+}
+
+// Execute tails the file without a deadline of its own.
+func (t *tailLogTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext tails the file and returns the lines as text.
+func (t *tailLogTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	result, err := t.ExecuteResult(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return result.Content, nil
+}
+
+// ExecuteResult returns the last lines of the file. Following it, the
+// lines are sent as partial results, then the lines written until the
+// follow ends, and the result says what was followed; lines that cannot be
+// sent, outside of a server, are put in the result instead.
+func (t *tailLogTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	a := tailLogArgs{Lines: defaultTailLines}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.Lines < 0 || a.Lines > t.cfg.MaxLines {
+		return nil, fmt.Errorf("invalid value for 'lines': expected 0 to %d", t.cfg.MaxLines)
+	}
+	follow := time.Duration(a.Follow * float64(time.Second))
+	if follow < 0 || follow > time.Duration(t.cfg.MaxFollow) {
+		return nil, fmt.Errorf("invalid value for 'follow': expected 0 to %g seconds", time.Duration(t.cfg.MaxFollow).Seconds())
+	}
+	path, err := t.resolve(a.File)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, toolFailure("%s: %v", a.File, errors.Unwrap(err))
+	}
+	defer func() { f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, toolFailure("%s: %v", a.File, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, toolFailure("%s is not a regular file", a.File)
+	}
+	tail, err := lastLines(f, info.Size(), a.Lines, t.cfg.MaxBytes)
+	if err != nil {
+		return nil, toolFailure("%s: %v", a.File, err)
+	}
+	if follow == 0 {
+		return mcp.NewResult(mcp.NewTextContent(string(tail))), nil
+	}
+
+	var unsent strings.Builder
+	send := func(text []byte) {
+		if len(text) > 0 && SendPartial(ctx, mcp.NewTextContent(string(text))) != nil {
+			unsent.Write(text)
+		}
+	}
+	send(tail)
+	budget := t.cfg.MaxBytes - len(tail)
+	offset, lines, cut := info.Size(), 0, false
+	var partial []byte // a line still being written
+	timer := time.NewTimer(follow)
+	defer timer.Stop()
+	ticker := time.NewTicker(tailPollInterval)
+	defer ticker.Stop()
+poll:
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			break poll
+		case <-ticker.C:
+		}
+		// A file rotated away or truncated is read again from the start.
+		if now, err := os.Stat(path); err == nil && !os.SameFile(info, now) {
+			if g, err := os.Open(path); err == nil {
+				f.Close()
+				f, info, offset = g, now, 0
+			}
+		}
+		if now, err := f.Stat(); err == nil && now.Size() < offset {
+			offset = 0
+		}
+		data, err := io.ReadAll(io.NewSectionReader(f, offset, int64(budget-len(partial)+1)))
+		if err != nil {
+			return nil, toolFailure("%s: %v", a.File, err)
+		}
+		offset += int64(len(data))
+		data = append(partial, data...)
+		if len(data) > budget {
+			data, cut = data[:budget], true
+		}
+		end := bytes.LastIndexByte(data, '\n') + 1
+		if cut {
+			end = len(data)
+		}
+		lines += bytes.Count(data[:end], []byte("\n"))
+		send(data[:end])
+		budget -= end
+		partial = append([]byte(nil), data[end:]...)
+		if cut {
+			break
+		}
+	}
+	if !cut {
+		if len(partial) > 0 {
+			lines++
+		}
+		send(partial)
+	}
+	summary := fmt.Sprintf("Followed %s for %s: %d new lines", a.File, follow, lines)
+	if cut {
+		summary = fmt.Sprintf("Stopped following %s at the limit of %d bytes: %d new lines", a.File, t.cfg.MaxBytes, lines)
+	}
+	if unsent.Len() > 0 {
+		return mcp.NewResult(mcp.NewTextContent(unsent.String()), mcp.NewTextContent(summary)), nil
+	}
+	return mcp.NewResult(mcp.NewTextContent(summary)), nil
+}
+
+// resolve returns the path of the named log file if it, and the file it
+// is a link to, are allowlisted.
+func (t *tailLogTool) resolve(name string) (string, error) {
+	if name == "" {
+		return "", errors.New("'file' is required")
+	}
+	if !filepath.IsAbs(name) {
+		return "", errors.New("invalid value for 'file': expected an absolute path")
+	}
+	path := filepath.Clean(name)
+	if !t.allowed(path) {
+		return "", fmt.Errorf("invalid value for 'file': %s is not one of the log files", name)
+	}
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", toolFailure("%s: %v", name, errors.Unwrap(err))
+	}
+	if target != path && !t.allowed(target) {
+		return "", fmt.Errorf("invalid value for 'file': %s links outside of the log files", name)
+	}
+	return path, nil
+}
+
+// allowed reports whether path is a configured file or matches a
+// configured pattern.
+func (t *tailLogTool) allowed(path string) bool {
+	for _, pattern := range t.cfg.Files {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lastLines returns the last n lines of the size bytes of r, but at most
+// maxBytes of them, reading back from the end a block at a time.
+func lastLines(r io.ReaderAt, size int64, n, maxBytes int) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	var data []byte
+	offset := size
+	for offset > 0 && len(data) < maxBytes {
+		block := int64(tailReadBlock)
+		if block > offset {
+			block = offset
+		}
+		buf := make([]byte, block)
+		if _, err := r.ReadAt(buf, offset-block); err != nil && err != io.EOF {
+			return nil, err
+		}
+		offset -= block
+		data = append(buf, data...)
+		// A final newline ends the last line rather than starting one.
+		if bytes.Count(bytes.TrimSuffix(data, []byte("\n")), []byte("\n")) >= n {
+			break
+		}
+	}
+	body := bytes.TrimSuffix(data, []byte("\n"))
+	for i, count := len(body)-1, 0; i >= 0; i-- {
+		if body[i] == '\n' {
+			if count++; count == n {
+				data = data[i+1:]
+				break
+			}
+		}
+	}
+	if len(data) > maxBytes {
+		data = data[len(data)-maxBytes:]
+		if i := bytes.IndexByte(data, '\n'); i >= 0 && i < len(data)-1 {
+			data = data[i+1:]
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that the last lines are read back from the end of large files
+func TestLastLines(t *testing.T) {
+	var log strings.Builder
+	for i := 0; i < 20000; i++ {
+		log.WriteString("line ")
+		log.WriteString(strings.Repeat("x", i%7))
+		log.WriteString("\n")
+	}
+	for _, tc := range []struct {
+		data   string
+		n, max int
+		want   string
+	}{
+		{"a\nb\nc\n", 2, 100, "b\nc\n"},
+		{"a\nb\nc", 2, 100, "b\nc"},
+		{"a\nb\nc\n", 5, 100, "a\nb\nc\n"},
+		{"a\nb\nc\n", 0, 100, ""},
+		{"aaaa\nbbbb\ncccc\n", 3, 8, "cccc\n"},
+		{"", 3, 100, ""},
+		{log.String(), 3, 1 << 20, "line xxxxx\nline xxxxxx\nline \n"},
+	} {
+		got, err := lastLines(strings.NewReader(tc.data), int64(len(tc.data)), tc.n, tc.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%.20q, %d lines, %d bytes: expected %q, got %q", tc.data, tc.n, tc.max, tc.want, got)
+		}
+	}
+}
+
+// Test that allowlisted files are tailed and followed, new lines being
+// sent as partial results
+func TestTailLogTool(t *testing.T) {
+	defer func(d time.Duration) { tailPollInterval = d }(tailPollInterval)
+	tailPollInterval = 10 * time.Millisecond
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("hunter2\n"), 0o644)
+	os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(dir, "link.log"))
+	tool := newTailLogTool(tailLogConfig{Files: []string{filepath.Join(dir, "*.log")}, MaxLines: 100, MaxBytes: 100})
+
+	content, err := tool.Execute(map[string]interface{}{"file": path, "lines": 2})
+	if err != nil || content[0].Text != "two\nthree\n" {
+		t.Errorf("unexpected result %+v, %v", content, err)
+	}
+
+	st := newToolStream(nil, nil, 0)
+	ctx := context.WithValue(context.Background(), streamKey{}, st)
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		f.WriteString("four\nfi")
+		time.Sleep(50 * time.Millisecond)
+		f.WriteString("ve\nsix")
+		f.Close()
+	}()
+	content, err = tool.ExecuteContext(ctx, map[string]interface{}{"file": path, "lines": 1, "follow": 0.3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []string
+	for _, c := range st.all() {
+		sent = append(sent, c.Text)
+	}
+	if got := strings.Join(sent, ""); got != "three\nfour\nfive\nsix" {
+		t.Errorf("unexpected partial results %q", sent)
+	}
+	if content[0].Text != "Followed "+path+" for 300ms: 3 new lines" {
+		t.Errorf("unexpected result %q", content[0].Text)
+	}
+
+	// Outside of a call the lines come in the result, up to the limit, and
+	// a rotated file is read from its start.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path+".new", []byte(strings.Repeat("rotated\n", 20)), 0o644)
+		os.Rename(path+".new", path)
+	}()
+	content, err = tool.ExecuteContext(context.Background(), map[string]interface{}{"file": path, "lines": 0, "follow": 1})
+	if err != nil || len(content) != 2 || len(content[0].Text) != 100 || !strings.HasPrefix(content[1].Text, "Stopped following") {
+		t.Errorf("unexpected result %+v, %v", content, err)
+	}
+
+	for want, args := range map[string]map[string]interface{}{
+		"not one of the log files":       {"file": filepath.Join(dir, "secret.txt")},
+		"links outside of the log files": {"file": filepath.Join(dir, "link.log")},
+		"expected an absolute path":      {"file": "app.log"},
+		"'file' is required":             {},
+		"invalid value for 'lines'":      {"file": path, "lines": 101},
+		"invalid value for 'follow'":     {"file": path, "follow": 61},
+		"no such file":                   {"file": filepath.Join(dir, "missing.log")},
+	} {
+		if _, err := tool.Execute(args); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected an error containing %q, got %v", args, want, err)
+		}
+	}
+}
+
+// Test that malformed config sections are rejected
+func TestTailLogConfig(t *testing.T) {
+	if err := (&tailLogConfig{Files: []string{"/var/log/app/*.log", "/var/log/syslog"}}).validate(); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for _, c := range []tailLogConfig{{}, {Files: []string{"logs/app.log"}}, {Files: []string{"/var/log/[app.log"}}, {Files: []string{"/x"}, MaxLines: -1}} {
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v: expected an error", c)
+		}
+	}
+}
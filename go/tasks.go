@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxTasksPerSession bounds the tasks a session keeps, running or
+// finished. Starting another forgets the oldest finished one.
+const maxTasksPerSession = 64
+
+// States of a task.
+const (
+	taskWorking   = "working"
+	taskCompleted = "completed"
+	taskFailed    = "failed"
+	taskCancelled = "cancelled"
+)
+
+// Task is the work of a background task. It reports how far it got with
+// report, which is safe to call from any goroutine; total is zero when
+// unknown. The content it returns is the task's result, and an error fails
+// the task, showing the error's text, or a failed tool's result, to the
+// client.
+type Task func(ctx context.Context, report func(progress, total float64, message string)) ([]ToolContent, error)
+
+// task is a background task started by a tool call.
+type task struct {
+	id      string
+	tool    string
+	owner   *Session
+	cancel  context.CancelFunc
+	started time.Time
+
+	mu       sync.Mutex
+	notify   io.Writer // where status notifications go, nil once the session ended or if they cannot be sent
+	status   string
+	progress float64
+	total    float64
+	message  string
+	content  []ToolContent // the result or, for a failed task, its error result
+	finished time.Time
+}
+
+// taskManager runs the background tasks of a server's sessions.
+type taskManager struct {
+	server *Server
+
+	mu    sync.Mutex
+	tasks map[string]*task     // by ID
+	order map[*Session][]*task // each session's tasks, oldest first
+}
+
+// taskStarter starts tasks for one tool call.
+type taskStarter struct {
+	manager *taskManager
+	sess    *session
+	tool    string
+}
+
+// taskKey is the context key of the taskStarter.
+type taskKey struct{}
+
+// WithTasks lets tools start background tasks with StartTask, and adds the
+// task_status and task_result tools with which clients keep track of them.
+func WithTasks() Option {
+	return func(s *Server) {
+		s.tasks = &taskManager{server: s, tasks: map[string]*task{}, order: map[*Session][]*task{}}
+	}
+}
+
+// StartTask runs fn in the background as a task of the session of the
+// tool call ctx belongs to, and returns the task's ID at once, for the tool
+// to return to the client. Clients that can receive notifications get a
+// notifications/tasks/status message for each report and when the task
+// finishes; all can poll with the task_status and task_result tools. The
+// task outlives the call but not the session: it is cancelled when the
+// session ends. StartTask fails if the server was not configured
+// WithTasks, if ctx is not a tool call's, or if the session has
+// maxTasksPerSession tasks running.
+func StartTask(ctx context.Context, fn Task) (string, error) {
+	ts, _ := ctx.Value(taskKey{}).(*taskStarter)
+	if ts == nil {
+		return "", errors.New("background tasks are not enabled for this call")
+	}
+	return ts.manager.start(ts.sess, ts.tool, fn)
+}
+
+// start runs fn as a task of sess.
+func (m *taskManager) start(sess *session, tool string, fn Task) (string, error) {
+	// The task runs in the session's context rather than the call's,
+	// which ends with the call.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(sess.ctx))
+	t := &task{id: newSessionID(), tool: tool, owner: sess.state, cancel: cancel, started: time.Now(), status: taskWorking}
+	// Status notifications go where requests to the client do, which
+	// HTTP responses, holding a single message, cannot carry.
+	if sess.requests != nil {
+		t.notify = sess.clientWriter()
+	}
+	m.mu.Lock()
+	tasks := m.order[t.owner]
+	if len(tasks) >= maxTasksPerSession {
+		i := 0
+		for i < len(tasks) && tasks[i].state() == taskWorking {
+			i++
+		}
+		if i == len(tasks) {
+			m.mu.Unlock()
+			cancel()
+			return "", fmt.Errorf("too many running tasks: the limit is %d", maxTasksPerSession)
+		}
+		delete(m.tasks, tasks[i].id)
+		tasks = append(tasks[:i:i], tasks[i+1:]...)
+	}
+	m.tasks[t.id] = t
+	m.order[t.owner] = append(tasks, t)
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		content, err := m.run(ctx, fn, t.report)
+		t.finish(ctx, content, err, m.server)
+	}()
+	return t.id, nil
+}
+
+// run calls fn, recovering a panic as an error.
+func (m *taskManager) run(ctx context.Context, fn Task, report func(progress, total float64, message string)) (content []ToolContent, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			m.server.logger.Error("task panicked", "panic", v)
+			content, err = nil, fmt.Errorf("task panicked: %v", v)
+		}
+	}()
+	return fn(ctx, report)
+}
+
+// lookup returns the task with the given ID if sess owns it.
+func (m *taskManager) lookup(sess *Session, id string) *task {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t := m.tasks[id]; t != nil && t.owner == sess {
+		return t
+	}
+	return nil
+}
+
+// endSession cancels the tasks of sess and forgets them, as it ends.
+func (m *taskManager) endSession(sess *Session) {
+	m.mu.Lock()
+	tasks := m.order[sess]
+	delete(m.order, sess)
+	for _, t := range tasks {
+		delete(m.tasks, t.id)
+	}
+	m.mu.Unlock()
+	for _, t := range tasks {
+		t.mu.Lock()
+		t.notify = nil
+		t.mu.Unlock()
+		t.cancel()
+	}
+}
+
+// report records the progress of t and notifies the client.
+func (t *task) report(progress, total float64, message string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status != taskWorking {
+		return
+	}
+	t.progress, t.total, t.message = progress, total, message
+	t.sendStatus()
+}
+
+// finish records the outcome of t and notifies the client.
+func (t *task) finish(ctx context.Context, content []ToolContent, err error, s *Server) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.finished = time.Now()
+	var resultErr *toolResultError
+	switch {
+	case err == nil:
+		t.status, t.content = taskCompleted, content
+	case ctx.Err() != nil:
+		t.status = taskCancelled
+		t.content = []ToolContent{{Type: "text", Text: "Task cancelled"}}
+	case errors.As(err, &resultErr):
+		t.status = taskFailed
+		for _, c := range resultErr.content {
+			c.Text = s.redactor.redactString(c.Text)
+			t.content = append(t.content, c)
+		}
+	default:
+		t.status = taskFailed
+		t.content = []ToolContent{{Type: "text", Text: s.redactor.redactString(err.Error())}}
+	}
+	t.sendStatus()
+}
+
+// state returns the status of t.
+func (t *task) state() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// taskStatus is what task_status and the status notifications report.
+type taskStatus struct {
+	TaskID   string  `json:"taskId"`
+	Tool     string  `json:"tool"`
+	Status   string  `json:"status"`
+	Progress float64 `json:"progress,omitempty"`
+	Total    float64 `json:"total,omitempty"`
+	Message  string  `json:"message,omitempty"`
+	Started  string  `json:"started"`
+	Finished string  `json:"finished,omitempty"`
+}
+
+// statusLocked returns the status of t. t.mu must be held.
+func (t *task) statusLocked() taskStatus {
+	st := taskStatus{TaskID: t.id, Tool: t.tool, Status: t.status, Progress: t.progress, Total: t.total,
+		Message: t.message, Started: t.started.UTC().Format(time.RFC3339)}
+	if !t.finished.IsZero() {
+		st.Finished = t.finished.UTC().Format(time.RFC3339)
+	}
+	return st
+}
+
+// sendStatus notifies the client of the status of t. t.mu must be held,
+// so that notifications are sent in order.
+func (t *task) sendStatus() {
+	if t.notify == nil {
+		return
+	}
+	sendNotification(t.notify, "notifications/tasks/status", t.statusLocked())
+}
+
+// taskStatusTool reports the status of a background task.
+type taskStatusTool struct {
+	tasks *taskManager
+}
+
+// Name returns the name of the task_status tool.
+func (t *taskStatusTool) Name() string {
+	return "task_status"
+}
+
+// Description returns a brief description of the task_status tool.
+func (t *taskStatusTool) Description() string {
+	return "Reports whether a background task started by another tool is still working, and how far it got"
+}
+
+// InputSchema returns the JSON schema for the task_status tool's input parameters.
+func (t *taskStatusTool) InputSchema() map[string]interface{} {
+	return taskIDSchema()
+}
+
+// Annotations marks the task_status tool as read-only.
+func (t *taskStatusTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Task status")
+}
+
+// Execute needs the session, so it only fails.
+func (t *taskStatusTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext returns the task's status as indented JSON text.
+func (t *taskStatusTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	task, err := t.tasks.fromArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	task.mu.Lock()
+	status := task.statusLocked()
+	task.mu.Unlock()
+	encoded, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
+
+// taskResultTool returns the result of a finished background task.
+type taskResultTool struct {
+	tasks *taskManager
+}
+
+// Name returns the name of the task_result tool.
+func (t *taskResultTool) Name() string {
+	return "task_result"
+}
+
+// Description returns a brief description of the task_result tool.
+func (t *taskResultTool) Description() string {
+	return "Returns the result of a finished background task started by another tool"
+}
+
+// InputSchema returns the JSON schema for the task_result tool's input parameters.
+func (t *taskResultTool) InputSchema() map[string]interface{} {
+	return taskIDSchema()
+}
+
+// Annotations marks the task_result tool as read-only.
+func (t *taskResultTool) Annotations() ToolAnnotations {
+	return readOnlyAnnotations("Task result")
+}
+
+// Execute needs the session, so it only fails.
+func (t *taskResultTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext returns the task's result, failing while it is working
+// and with the task's error if it failed.
+func (t *taskResultTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	task, err := t.tasks.fromArgs(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	switch task.status {
+	case taskWorking:
+		return nil, toolFailure("Task %s is still working; check task_status", task.id)
+	case taskCompleted:
+		return task.content, nil
+	}
+	return nil, &toolResultError{content: task.content}
+}
+
+// fromArgs returns the task named by the task_id argument, if the session
+// of ctx owns it.
+func (m *taskManager) fromArgs(ctx context.Context, args map[string]interface{}) (*task, error) {
+	id, _ := args["task_id"].(string)
+	sess := SessionFromContext(ctx)
+	if sess == nil {
+		return nil, errors.New("no session")
+	}
+	t := m.lookup(sess, id)
+	if t == nil {
+		return nil, toolFailure("Unknown task: %s", id)
+	}
+	return t, nil
+}
+
+// taskIDSchema returns the input schema of the task tools.
+func taskIDSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"task_id": map[string]interface{}{
+				"type":        "string",
+				"description": "The ID of the task, as returned by the tool that started it",
+			},
+		},
+		"required": []string{"task_id"},
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// jobTool starts a background task that reports halfway and finishes once
+// release is closed, or fails when its context is cancelled.
+type jobTool struct {
+	release   chan struct{}
+	cancelled chan struct{}
+}
+
+func (j *jobTool) Name() string        { return "job" }
+func (j *jobTool) Description() string { return "Starts a background job" }
+func (j *jobTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "object"}
+}
+func (j *jobTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return j.ExecuteContext(context.Background(), args)
+}
+func (j *jobTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	id, err := StartTask(ctx, func(ctx context.Context, report func(progress, total float64, message string)) ([]ToolContent, error) {
+		report(1, 2, "halfway")
+		select {
+		case <-j.release:
+			return []ToolContent{{Type: "text", Text: "job done"}}, nil
+		case <-ctx.Done():
+			close(j.cancelled)
+			return nil, ctx.Err()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: "started " + id}}, nil
+}
+
+// Test starting a background task, following it with notifications and the
+// task tools, and cancelling it when the session ends
+func TestTasks(t *testing.T) {
+	tool := &jobTool{release: make(chan struct{}), cancelled: make(chan struct{})}
+	pw, out, errc := serveInBackground(context.Background(), NewServer(WithTools(tool), WithTasks()))
+
+	call := func(id int, name, taskID string) {
+		fmt.Fprintf(pw, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":%q,"arguments":{"task_id":%q}},"id":%d}`+"\n", name, taskID, id)
+	}
+	call(1, "job", "")
+	taskID := waitForOutput(t, out, regexp.MustCompile(`"text":"started ([0-9a-f]+)"`), 1)[0][1]
+	waitForOutput(t, out, regexp.MustCompile(`"method":"notifications/tasks/status","params":\{"taskId":"`+taskID+`","tool":"job","status":"working","progress":1,"total":2,"message":"halfway"`), 1)
+
+	call(2, "task_status", taskID)
+	waitForOutput(t, out, regexp.MustCompile(`"id":2,.*\\"status\\": \\"working\\"`), 1)
+	call(3, "task_result", taskID)
+	waitForOutput(t, out, regexp.MustCompile(`"id":3,.*still working.*"isError":true`), 1)
+	call(4, "task_result", "nope")
+	waitForOutput(t, out, regexp.MustCompile(`"id":4,.*Unknown task: nope.*"isError":true`), 1)
+
+	close(tool.release)
+	waitForOutput(t, out, regexp.MustCompile(`"taskId":"`+taskID+`","tool":"job","status":"completed"`), 1)
+	call(5, "task_result", taskID)
+	waitForOutput(t, out, regexp.MustCompile(`"id":5,"jsonrpc":"2.0","result":\{"content":\[\{"type":"text","text":"job done"\}\]\}`), 1)
+
+	// A task still running when the session ends is cancelled.
+	tool.release = make(chan struct{})
+	call(6, "job", "")
+	waitForOutput(t, out, regexp.MustCompile(`"text":"started `), 2)
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Fatalf("Serve error: %v", err)
+	}
+	select {
+	case <-tool.cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the task to be cancelled when the session ended")
+	}
+	if strings.Contains(out.String(), `"status":"cancelled"`) {
+		t.Error("expected no notification after the session ended")
+	}
+}
+
+// Test that tasks are refused without WithTasks, and kept from other
+// sessions
+func TestTasksIsolation(t *testing.T) {
+	if _, err := StartTask(context.Background(), nil); err == nil {
+		t.Error("expected StartTask outside a call to fail")
+	}
+	lines := runServerInput(t, NewServer(WithTools(&jobTool{})), `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"job","arguments":{}},"id":1}`)
+	if len(lines) != 1 || !strings.Contains(lines[0], `"error"`) {
+		t.Errorf("expected the job to fail without WithTasks, got %v", lines)
+	}
+
+	tool := &jobTool{release: make(chan struct{}), cancelled: make(chan struct{})}
+	s := NewServer(WithTools(tool), WithTasks())
+	lines = runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"job","arguments":{}},"id":1}`)
+	taskID := regexp.MustCompile(`started ([0-9a-f]+)`).FindStringSubmatch(strings.Join(lines, "\n"))
+	if taskID == nil {
+		t.Fatalf("expected a task ID, got %v", lines)
+	}
+	lines = runServerInput(t, s, `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"task_status","arguments":{"task_id":"`+taskID[1]+`"}},"id":2}`)
+	if !strings.Contains(strings.Join(lines, "\n"), "Unknown task") {
+		t.Errorf("expected another session not to see the task, got %v", lines)
+	}
+}
@@ -0,0 +1,56 @@
+// Command reverse is a Go plugin providing a "reverse" tool, used by the
+// plugin loading tests. Build it with
+//
+//	go build -buildmode=plugin -o reverse.so ./testdata/plugin/reverse
+package main
+
+import (
+	"fmt"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// reverseTool returns its input with the characters in reverse order.
+type reverseTool struct{}
+
+// Name returns the name of the reverse tool.
+func (reverseTool) Name() string {
+	return "reverse"
+}
+
+// Description returns a brief description of the reverse tool.
+func (reverseTool) Description() string {
+	return "Returns the specified text reversed"
+}
+
+// InputSchema returns the JSON schema for the reverse tool's input parameters.
+func (reverseTool) InputSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"text": map[string]interface{}{
+				"type":        "string",
+				"description": "The text to reverse",
+			},
+		},
+		"required": []string{"text"},
+	}
+}
+
+// Execute reverses the text argument.
+func (reverseTool) Execute(args map[string]interface{}) ([]mcp.Content, error) {
+	text, ok := args["text"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for 'text'")
+	}
+	r := []rune(text)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return []mcp.Content{{Type: "text", Text: string(r)}}, nil
+}
+
+// Tools is looked up by the server when it loads the plugin.
+func Tools() []mcp.Tool {
+	return []mcp.Tool{reverseTool{}}
+}
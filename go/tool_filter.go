@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path"
+)
+
+// toolFilter decides which registered tools a server exposes. Patterns use
+// path.Match syntax, so "qr_*" matches every tool whose name starts with
+// "qr_".
+type toolFilter struct {
+	allow []string // if non-empty, only matching tools are exposed
+	deny  []string // matching tools are never exposed
+}
+
+// WithToolFilter restricts the tools the server exposes. A tool is exposed
+// if it matches one of the allow patterns, or allow is empty, and matches
+// none of the deny patterns. The filter also applies to built-in tools such
+// as server_status.
+func WithToolFilter(allow, deny []string) Option {
+	return func(s *Server) {
+		s.filter = toolFilter{allow: allow, deny: deny}
+	}
+}
+
+// validateToolPatterns reports the first malformed pattern in patterns.
+func validateToolPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid tool pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// allows reports whether the named tool passes the filter.
+func (f toolFilter) allows(name string) bool {
+	if len(f.allow) > 0 && !matchAny(f.allow, name) {
+		return false
+	}
+	return !matchAny(f.deny, name)
+}
+
+// apply returns the tools in ts that pass the filter.
+func (f toolFilter) apply(ts []MCPTool) []MCPTool {
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return ts
+	}
+	var kept []MCPTool
+	for _, t := range ts {
+		if f.allows(t.Name()) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// matchAny reports whether name matches any of patterns. Malformed patterns
+// never match.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
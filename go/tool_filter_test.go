@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test that allow and deny patterns restrict the exposed tools
+func TestToolFilter(t *testing.T) {
+	cases := []struct {
+		allow, deny []string
+		want        []string
+	}{
+		{nil, nil, []string{"echo", "count_text", "qr_code", "image_transform", "parse_ics", "convert_units", "detect_language", "semver", "format_code", "html_to_text", "chunk_text", "read_spreadsheet", "server_status"}},
+		{[]string{"echo", "qr_*"}, nil, []string{"echo", "qr_code"}},
+		{nil, []string{"*_*"}, []string{"echo", "semver"}},
+		{[]string{"*"}, []string{"server_status"}, []string{"echo", "count_text", "qr_code", "image_transform", "parse_ics", "convert_units", "detect_language", "semver", "format_code", "html_to_text", "chunk_text", "read_spreadsheet"}},
+	}
+	for _, c := range cases {
+		s := NewServer(WithStatusTool(), WithToolFilter(c.allow, c.deny))
+		var got []string
+		for _, tool := range s.tools {
+			got = append(got, tool.Name())
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("allow=%v deny=%v: expected %v, got %v", c.allow, c.deny, c.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("allow=%v deny=%v: expected %v, got %v", c.allow, c.deny, c.want, got)
+				break
+			}
+		}
+	}
+}
+
+// Test that a filtered tool cannot be called
+func TestToolFilterCall(t *testing.T) {
+	input := `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"qr_code","arguments":{"text":"hi"}},"id":1}` + "\n"
+	lines := runServerInput(t, NewServer(WithToolFilter(nil, []string{"qr_*"})), input)
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 line output, got %d", len(lines))
+	}
+	if !strings.Contains(lines[0], `"code":-32601`) {
+		t.Errorf("expected a tool not found error, got %s", lines[0])
+	}
+}
+
+// Test that read-only mode hides tools not annotated as read-only
+func TestReadOnly(t *testing.T) {
+	s := NewServer(WithTools(&echoTool{}, &hangingTool{}, newCommandTool(commandToolConfig{
+		Name: "ls", Command: []string{"ls"}, Annotations: &ToolAnnotations{ReadOnlyHint: true},
+	})), WithReadOnly())
+	input := `{"jsonrpc":"2.0","method":"tools/list","id":1}` + "\n" +
+		`{"jsonrpc":"2.0","method":"tools/call","params":{"name":"hang","arguments":{}},"id":2}` + "\n"
+	lines := runServerInput(t, s, input)
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines output, got %d", len(lines))
+	}
+	if !strings.Contains(lines[0], `"name":"echo"`) || !strings.Contains(lines[0], `"name":"ls"`) || strings.Contains(lines[0], `"name":"hang"`) {
+		t.Errorf("expected only the read-only tools, got %s", lines[0])
+	}
+	if !strings.Contains(lines[0], `"annotations":{"title":"Echo","readOnlyHint":true`) {
+		t.Errorf("expected annotations in the tools list, got %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"code":-32601`) {
+		t.Errorf("expected the hidden tool to be rejected, got %s", lines[1])
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// toolSource is a group of tools registered together, such as the tools of
+// one upstream server. A non-empty namespace is prepended to the names of
+// its tools, separated by a dot: namespace "git" turns "status" into
+// "git.status".
+type toolSource struct {
+	name      string // describes the source in error messages
+	namespace string
+	tools     []MCPTool
+}
+
+// registerTools combines the tools of sources into one list. Each tool is
+// named with its source's namespace and then renamed as listed in renames,
+// which maps namespaced names to final ones. Two tools ending up with the
+// same name are an error, as is a rename of a tool that does not exist.
+func registerTools(sources []toolSource, renames map[string]string) ([]MCPTool, error) {
+	var registered []MCPTool
+	owner := map[string]string{} // final name to source name
+	used := map[string]bool{}
+	for _, src := range sources {
+		for _, t := range src.tools {
+			name := t.Name()
+			if src.namespace != "" {
+				name = src.namespace + "." + name
+			}
+			if renamed, ok := renames[name]; ok {
+				used[name] = true
+				name = renamed
+			}
+			if prev, ok := owner[name]; ok {
+				return nil, fmt.Errorf("tool %q from %s collides with the tool of the same name from %s; rename one of them", name, src.name, prev)
+			}
+			owner[name] = src.name
+			if name != t.Name() {
+				t = &renamedTool{MCPTool: t, name: name}
+			}
+			registered = append(registered, t)
+		}
+	}
+	var unused []string
+	for _, from := range sortedKeys(renames) {
+		if !used[from] {
+			unused = append(unused, from)
+		}
+	}
+	if len(unused) > 0 {
+		return nil, fmt.Errorf("cannot rename unknown tools: %s", strings.Join(unused, ", "))
+	}
+	return registered, nil
+}
+
+// validateNamespace reports whether ns can prefix tool names.
+func validateNamespace(ns string) error {
+	if strings.ContainsAny(ns, ". \t\n") {
+		return fmt.Errorf("invalid namespace %q: must not contain dots or spaces", ns)
+	}
+	return nil
+}
+
+// renamedTool serves a tool under another name. The optional tool
+// interfaces are forwarded to the wrapped tool.
+type renamedTool struct {
+	MCPTool
+	name string
+}
+
+// Name returns the new name.
+func (r *renamedTool) Name() string {
+	return r.name
+}
+
+// ExecuteContext calls the wrapped tool, passing ctx if it accepts one.
+func (r *renamedTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	if ct, ok := r.MCPTool.(ContextTool); ok {
+		return ct.ExecuteContext(ctx, args)
+	}
+	return r.MCPTool.Execute(args)
+}
+
+// ExecuteResult calls the wrapped tool, keeping the structured content of
+// its result if it has any.
+func (r *renamedTool) ExecuteResult(ctx context.Context, args map[string]interface{}) (*ToolResult, error) {
+	if rt, ok := r.MCPTool.(ResultTool); ok {
+		return rt.ExecuteResult(ctx, args)
+	}
+	content, err := r.ExecuteContext(ctx, args)
+	return &ToolResult{Content: content}, err
+}
+
+// Timeout returns the wrapped tool's time limit, if it has one.
+func (r *renamedTool) Timeout() time.Duration {
+	if tt, ok := r.MCPTool.(TimeoutTool); ok {
+		return tt.Timeout()
+	}
+	return 0
+}
+
+// MaxConcurrency returns the wrapped tool's concurrency limit, if it has
+// one.
+func (r *renamedTool) MaxConcurrency() int {
+	if cl, ok := r.MCPTool.(ConcurrencyLimitedTool); ok {
+		return cl.MaxConcurrency()
+	}
+	return 0
+}
+
+// Version returns the wrapped tool's version, if it has one.
+func (r *renamedTool) Version() ToolVersion {
+	if vt, ok := r.MCPTool.(VersionedTool); ok {
+		return vt.Version()
+	}
+	return ToolVersion{}
+}
+
+// Annotations returns the wrapped tool's annotations, if it has any.
+func (r *renamedTool) Annotations() ToolAnnotations {
+	if at, ok := r.MCPTool.(AnnotatedTool); ok {
+		return at.Annotations()
+	}
+	return ToolAnnotations{}
+}
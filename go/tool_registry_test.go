@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test that namespaces and renames determine the served names
+func TestRegisterTools(t *testing.T) {
+	sources := []toolSource{
+		{name: "built-in", tools: []MCPTool{&echoTool{}}},
+		{name: "upstream a", namespace: "a", tools: []MCPTool{&echoTool{}, &limitedTool{timeout: 3 * time.Second, limit: 2}}},
+		{name: "upstream b", namespace: "b", tools: []MCPTool{&echoTool{}}},
+	}
+	registered, err := registerTools(sources, map[string]string{"b.echo": "shout"})
+	if err != nil {
+		t.Fatalf("registerTools error: %v", err)
+	}
+	var names []string
+	for _, tool := range registered {
+		names = append(names, tool.Name())
+	}
+	if got := strings.Join(names, ","); got != "echo,a.echo,a.limited,shout" {
+		t.Errorf("unexpected names %s", got)
+	}
+
+	content, err := registered[3].(ContextTool).ExecuteContext(context.Background(), map[string]interface{}{"message": "hi"})
+	if err != nil || content[0].Text != "Echo: hi" {
+		t.Errorf("unexpected result of the renamed tool %v, %v", content, err)
+	}
+	if timeout := registered[2].(TimeoutTool).Timeout(); timeout != 3*time.Second {
+		t.Errorf("expected the wrapped tool's timeout, got %v", timeout)
+	}
+	if limit := registered[2].(ConcurrencyLimitedTool).MaxConcurrency(); limit != 2 {
+		t.Errorf("expected the wrapped tool's concurrency limit, got %d", limit)
+	}
+	if timeout := registered[1].(TimeoutTool).Timeout(); timeout != 0 {
+		t.Errorf("expected no timeout for echo, got %v", timeout)
+	}
+}
+
+// Test that ambiguous registrations are rejected
+func TestRegisterToolsErrors(t *testing.T) {
+	sources := []toolSource{
+		{name: "built-in", tools: []MCPTool{&echoTool{}}},
+		{name: "upstream a", namespace: "a", tools: []MCPTool{&echoTool{}}},
+	}
+	_, err := registerTools(sources, map[string]string{"a.echo": "echo"})
+	if err == nil || !strings.Contains(err.Error(), "upstream a") || !strings.Contains(err.Error(), "built-in") {
+		t.Errorf("expected a collision error naming both sources, got %v", err)
+	}
+	if _, err := registerTools(sources, map[string]string{"a.nope": "x"}); err == nil {
+		t.Error("expected an error for renaming an unknown tool")
+	}
+	if err := validateNamespace("a.b"); err == nil {
+		t.Error("expected an error for a namespace with a dot")
+	}
+}
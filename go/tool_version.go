@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// toolVersion returns the version and deprecation t declares, if any.
+func toolVersion(t MCPTool) ToolVersion {
+	if vt, ok := t.(VersionedTool); ok {
+		return vt.Version()
+	}
+	return ToolVersion{}
+}
+
+// versionMeta returns the _meta of the tools/list entry of a tool with
+// version v, or nil if v declares nothing.
+func versionMeta(v ToolVersion) map[string]interface{} {
+	meta := map[string]interface{}{}
+	if v.Version != "" {
+		meta["version"] = v.Version
+	}
+	if v.Deprecated != "" {
+		deprecated := map[string]interface{}{"reason": v.Deprecated}
+		if v.ReplacedBy != "" {
+			deprecated["replacedBy"] = v.ReplacedBy
+		}
+		meta["deprecated"] = deprecated
+	}
+	if len(meta) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// deprecationNotice returns the sentences telling that a tool with
+// version v is deprecated, or "" if it is not.
+func deprecationNotice(v ToolVersion) string {
+	if v.Deprecated == "" {
+		return ""
+	}
+	notice := "Deprecated: " + strings.TrimRight(v.Deprecated, ".")
+	if v.ReplacedBy != "" {
+		notice += fmt.Sprintf(". Use %s instead", v.ReplacedBy)
+	}
+	return notice + "."
+}
+
+// withDeprecationWarning returns content with a warning for the model
+// first if t is deprecated, so that agents move to the replacement even
+// when their prompts name the old tool.
+func withDeprecationWarning(t MCPTool, content []ToolContent) []ToolContent {
+	notice := deprecationNotice(toolVersion(t))
+	if notice == "" {
+		return content
+	}
+	warning := mcp.NewTextContent(fmt.Sprintf("Warning: the tool %s is deprecated%s", t.Name(), strings.TrimPrefix(notice, "Deprecated"))).WithAudience("assistant")
+	return append([]ToolContent{warning}, content...)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Test that versions are listed and that deprecated tools warn in their
+// description and results
+func TestToolVersion(t *testing.T) {
+	old := &mockTool{cfg: mockToolConfig{
+		Name:         "search_v1",
+		Description:  "Searches the index",
+		ToolVersion:  ToolVersion{Version: "1.4.0", Deprecated: "Results are unranked.", ReplacedBy: "search"},
+		mockResponse: mockResponse{Response: "found"},
+	}}
+	current := &mockTool{cfg: mockToolConfig{
+		Name:         "search",
+		ToolVersion:  ToolVersion{Version: "2.0.0"},
+		mockResponse: mockResponse{Response: "found"},
+	}}
+	lines := runServerInput(t, NewServer(WithTools(old, current, &echoTool{})), `{"jsonrpc":"2.0","method":"tools/list","id":1}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"search_v1"},"id":2}
+{"jsonrpc":"2.0","method":"tools/call","params":{"name":"search"},"id":3}
+`)
+	out := strings.Join(lines, "\n")
+	for _, want := range []string{
+		`"_meta":{"deprecated":{"reason":"Results are unranked.","replacedBy":"search"},"version":"1.4.0"},"annotations":{},"description":"Deprecated: Results are unranked. Use search instead. Searches the index"`,
+		`"_meta":{"version":"2.0.0"},"annotations":{},"description":""`,
+		`{"id":2,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"Warning: the tool search_v1 is deprecated: Results are unranked. Use search instead.","annotations":{"audience":["assistant"]}},{"type":"text","text":"found"}]}}`,
+		`{"id":3,"jsonrpc":"2.0","result":{"content":[{"type":"text","text":"found"}]}}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"name":"echo","_meta"`) || strings.Count(out, `"_meta"`) != 2 {
+		t.Errorf("expected _meta only for the versioned tools:\n%s", out)
+	}
+}
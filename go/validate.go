@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// invalidArgumentsData is the data of the error answering a tools/call
+// whose arguments do not match the tool's input schema. It tells the
+// calling model what to change instead of leaving it to guess.
+type invalidArgumentsData struct {
+	Errors []argumentError `json:"errors"`
+}
+
+// argumentError describes one argument that does not match its schema.
+type argumentError struct {
+	Field    string        `json:"field"`  // e.g. "size.width" or "tags[2]"
+	Reason   string        `json:"reason"` // missing, type, enum, pattern, minimum, or maximum
+	Expected string        `json:"expected,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+	Pattern  string        `json:"pattern,omitempty"`
+	Minimum  *float64      `json:"minimum,omitempty"`
+	Maximum  *float64      `json:"maximum,omitempty"`
+	Received interface{}   `json:"received,omitempty"`
+}
+
+// message returns the text of the error reporting e.
+func (e argumentError) message() string {
+	received, _ := json.Marshal(e.Received)
+	switch e.Reason {
+	case "missing":
+		return fmt.Sprintf("Missing required parameter: '%s'", e.Field)
+	case "type":
+		return fmt.Sprintf("Invalid parameter '%s': expected %s, got %s", e.Field, e.Expected, jsonTypeName(e.Received))
+	case "enum":
+		values := make([]string, len(e.Enum))
+		for i, v := range e.Enum {
+			encoded, _ := json.Marshal(v)
+			values[i] = string(encoded)
+		}
+		return fmt.Sprintf("Invalid parameter '%s': expected one of %s, got %s", e.Field, strings.Join(values, ", "), received)
+	case "pattern":
+		return fmt.Sprintf("Invalid parameter '%s': %s does not match the pattern %s", e.Field, received, e.Pattern)
+	case "minimum":
+		return fmt.Sprintf("Invalid parameter '%s': %s is less than the minimum %v", e.Field, received, *e.Minimum)
+	case "maximum":
+		return fmt.Sprintf("Invalid parameter '%s': %s is greater than the maximum %v", e.Field, received, *e.Maximum)
+	}
+	return fmt.Sprintf("Invalid parameter '%s'", e.Field)
+}
+
+// invalidArgumentsMessage returns the message of the error reporting
+// problems, which names the first of them.
+func invalidArgumentsMessage(problems []argumentError) string {
+	msg := problems[0].message()
+	if len(problems) > 1 {
+		msg += fmt.Sprintf(" (and %d more)", len(problems)-1)
+	}
+	return msg
+}
+
+// validateArguments checks args against schema: that required properties
+// are present and that values have the declared type, are among the enum,
+// match the pattern, and lie within minimum and maximum, in nested objects
+// and arrays too. Keywords it does not know are ignored.
+func validateArguments(schema map[string]interface{}, args map[string]interface{}) []argumentError {
+	var problems []argumentError
+	validateObject(schema, args, "", &problems)
+	return problems
+}
+
+// validateObject checks the properties of the object obj, found at path.
+func validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, problems *[]argumentError) {
+	for _, name := range requiredFields(schema) {
+		if _, ok := obj[name]; !ok {
+			*problems = append(*problems, argumentError{Field: path + name, Reason: "missing"})
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	for _, name := range sortedKeys(obj) {
+		if prop, ok := properties[name].(map[string]interface{}); ok {
+			validateValue(prop, obj[name], path+name, problems)
+		}
+	}
+}
+
+// validateValue checks the value v, found at path.
+func validateValue(schema map[string]interface{}, v interface{}, path string, problems *[]argumentError) {
+	if types := schemaTypes(schema); !matchesAnyType(types, v) {
+		*problems = append(*problems, argumentError{Field: path, Reason: "type", Expected: strings.Join(types, " or "), Received: v})
+		return
+	}
+	if enum := schemaList(schema["enum"]); len(enum) > 0 {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(allowed, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*problems = append(*problems, argumentError{Field: path, Reason: "enum", Enum: enum, Received: v})
+			return
+		}
+	}
+	switch v := v.(type) {
+	case string:
+		if pattern, ok := schema["pattern"].(string); ok {
+			// Patterns that RE2 cannot compile are not enforced.
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				*problems = append(*problems, argumentError{Field: path, Reason: "pattern", Pattern: pattern, Received: v})
+			}
+		}
+	case float64:
+		if min, ok := schemaNumber(schema["minimum"]); ok && v < min {
+			*problems = append(*problems, argumentError{Field: path, Reason: "minimum", Minimum: &min, Received: v})
+		}
+		if max, ok := schemaNumber(schema["maximum"]); ok && v > max {
+			*problems = append(*problems, argumentError{Field: path, Reason: "maximum", Maximum: &max, Received: v})
+		}
+	case map[string]interface{}:
+		validateObject(schema, v, path+".", problems)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	}
+}
+
+// schemaList returns the elements of a list in a schema, which schemas
+// built in Go hold in typed slices such as []string.
+func schemaList(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list
+}
+
+// schemaNumber returns a number in a schema, of any Go numeric type.
+func schemaNumber(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// jsonEqual reports whether a and b have the same JSON encoding, so that
+// values of schemas built in Go compare equal to decoded arguments.
+func jsonEqual(a, b interface{}) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// jsonTypeName returns the JSON type of v, as decoded from JSON.
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
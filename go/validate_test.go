@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// validationSchema exercises every checked keyword.
+const validationSchema = `{"type":"object","required":["id"],"properties":{
+	"id": {"type": "string", "pattern": "^[a-z]+-[0-9]+$"},
+	"format": {"type": "string", "enum": ["png", "jpeg"]},
+	"width": {"type": "integer", "minimum": 1, "maximum": 4096},
+	"crop": {"type": "object", "required": ["x"], "properties": {"x": {"type": "number"}}},
+	"tags": {"type": "array", "items": {"type": "string"}}
+}}`
+
+// Test the problems found in arguments and their messages
+func TestValidateArguments(t *testing.T) {
+	schema := decodeSchema(t, validationSchema)
+	for _, tc := range []struct {
+		args    string
+		want    string // the problems as JSON
+		message string
+	}{
+		{`{"id":"img-1","format":"png","width":64,"crop":{"x":0.5},"tags":["a"]}`, `null`, ""},
+		{`{}`, `[{"field":"id","reason":"missing"}]`, "Missing required parameter: 'id'"},
+		{`{"id":"IMG"}`, `[{"field":"id","reason":"pattern","pattern":"^[a-z]+-[0-9]+$","received":"IMG"}]`,
+			`Invalid parameter 'id': "IMG" does not match the pattern ^[a-z]+-[0-9]+$`},
+		{`{"id":"a-1","format":"gif"}`, `[{"field":"format","reason":"enum","enum":["png","jpeg"],"received":"gif"}]`,
+			`Invalid parameter 'format': expected one of "png", "jpeg", got "gif"`},
+		{`{"id":"a-1","width":"64"}`, `[{"field":"width","reason":"type","expected":"integer","received":"64"}]`,
+			"Invalid parameter 'width': expected integer, got string"},
+		{`{"id":"a-1","width":0}`, `[{"field":"width","reason":"minimum","minimum":1,"received":0}]`,
+			"Invalid parameter 'width': 0 is less than the minimum 1"},
+		{`{"id":"a-1","width":5000}`, `[{"field":"width","reason":"maximum","maximum":4096,"received":5000}]`,
+			"Invalid parameter 'width': 5000 is greater than the maximum 4096"},
+		{`{"id":"a-1","crop":{},"tags":["a",2]}`,
+			`[{"field":"crop.x","reason":"missing"},{"field":"tags[1]","reason":"type","expected":"string","received":2}]`,
+			"Missing required parameter: 'crop.x' (and 1 more)"},
+	} {
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(tc.args), &args); err != nil {
+			t.Fatal(err)
+		}
+		problems := validateArguments(schema, args)
+		if got, _ := json.Marshal(problems); string(got) != tc.want {
+			t.Errorf("validateArguments(%s) = %s, expected %s", tc.args, got, tc.want)
+		}
+		if len(problems) > 0 && invalidArgumentsMessage(problems) != tc.message {
+			t.Errorf("message for %s = %q, expected %q", tc.args, invalidArgumentsMessage(problems), tc.message)
+		}
+	}
+}
+
+// Test that the typed lists and numbers of schemas built in Go are
+// checked
+func TestValidateGoSchema(t *testing.T) {
+	schema := mcp.SchemaFor(struct {
+		Level string `json:"level" enum:"low,high"`
+		Count int    `json:"count,omitempty" minimum:"1"`
+	}{})
+	problems := validateArguments(schema, map[string]interface{}{"level": "mid", "count": float64(0)})
+	if got, _ := json.Marshal(problems); !strings.Contains(string(got), `"reason":"minimum"`) || !strings.Contains(string(got), `"enum":["low","high"]`) {
+		t.Errorf("unexpected problems %s", got)
+	}
+}
+
+// Test that invalid arguments are answered with the problems as error data
+func TestInvalidArgumentsData(t *testing.T) {
+	tool := &mockTool{cfg: mockToolConfig{
+		Name:         "resize",
+		InputSchema:  decodeSchema(t, validationSchema),
+		mockResponse: mockResponse{Response: "ok"},
+	}}
+	lines := runServerInput(t, NewServer(WithTools(tool)), `{"jsonrpc":"2.0","method":"tools/call","params":{"name":"resize","arguments":{"id":"a-1","format":"gif"}},"id":1}`+"\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 response, got %q", lines)
+	}
+	var resp struct {
+		Error struct {
+			Code int                  `json:"code"`
+			Data invalidArgumentsData `json:"data"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error.Code != -32602 || len(resp.Error.Data.Errors) != 1 || resp.Error.Data.Errors[0].Field != "format" {
+		t.Errorf("expected the enum violation as error data, got %s", lines[0])
+	}
+}
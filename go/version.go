@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+)
+
+// serverName identifies the server in the initialize result and by
+// --version unless configured otherwise.
+const serverName = "simple-mcp-server"
+
+// defaultVersion is reported by builds without version information.
+const defaultVersion = "0.1.0"
+
+// linkedVersion is set at build time, as with
+//
+//	go build -ldflags "-X main.linkedVersion=1.2.3"
+var linkedVersion string
+
+// serverVersion is the version of this binary: the linked version, else
+// the module version recorded by go install, else defaultVersion.
+var serverVersion = buildVersion(linkedVersion, debug.ReadBuildInfo)
+
+// buildVersion returns the version of a binary linked with linked and
+// whose build information readInfo returns.
+func buildVersion(linked string, readInfo func() (*debug.BuildInfo, bool)) string {
+	if linked != "" {
+		return strings.TrimPrefix(linked, "v")
+	}
+	if info, ok := readInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return strings.TrimPrefix(info.Main.Version, "v")
+	}
+	return defaultVersion
+}
+
+// versionString returns the --version output: the name and version, plus
+// the commit and toolchain the binary was built from, if recorded.
+func versionString() string {
+	return formatVersion(serverVersion, debug.ReadBuildInfo)
+}
+
+// formatVersion formats version and the build information readInfo returns.
+func formatVersion(version string, readInfo func() (*debug.BuildInfo, bool)) string {
+	s := serverName + " " + version
+	info, ok := readInfo()
+	if !ok {
+		return s
+	}
+	var details []string
+	settings := map[string]string{}
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if rev := settings["vcs.revision"]; rev != "" {
+		detail := "commit " + rev[:min(12, len(rev))]
+		if settings["vcs.modified"] == "true" {
+			detail += ", modified"
+		}
+		details = append(details, detail)
+	}
+	if t := settings["vcs.time"]; t != "" {
+		details = append(details, "committed "+t)
+	}
+	if info.GoVersion != "" {
+		details = append(details, "built with "+info.GoVersion)
+	}
+	if len(details) > 0 {
+		s += fmt.Sprintf(" (%s)", strings.Join(details, "; "))
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+// Test choosing the version from the linker flag and the build information
+func TestBuildVersion(t *testing.T) {
+	info := func(version string) func() (*debug.BuildInfo, bool) {
+		return func() (*debug.BuildInfo, bool) {
+			return &debug.BuildInfo{Main: debug.Module{Version: version}}, true
+		}
+	}
+	none := func() (*debug.BuildInfo, bool) { return nil, false }
+	for _, c := range []struct {
+		linked   string
+		readInfo func() (*debug.BuildInfo, bool)
+		want     string
+	}{
+		{"v2.0.0", info("v1.4.0"), "2.0.0"},
+		{"", info("v1.4.0"), "1.4.0"},
+		{"", info("(devel)"), defaultVersion},
+		{"", none, defaultVersion},
+	} {
+		if got := buildVersion(c.linked, c.readInfo); got != c.want {
+			t.Errorf("buildVersion(%q) = %q, expected %q", c.linked, got, c.want)
+		}
+	}
+}
+
+// Test the --version output
+func TestFormatVersion(t *testing.T) {
+	info := &debug.BuildInfo{GoVersion: "go1.23.2", Settings: []debug.BuildSetting{
+		{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+		{Key: "vcs.time", Value: "2025-03-08T12:00:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	}}
+	got := formatVersion("1.4.0", func() (*debug.BuildInfo, bool) { return info, true })
+	want := "simple-mcp-server 1.4.0 (commit 0123456789ab, modified; committed 2025-03-08T12:00:00Z; built with go1.23.2)"
+	if got != want {
+		t.Errorf("got %q, expected %q", got, want)
+	}
+	if got := formatVersion("1.4.0", func() (*debug.BuildInfo, bool) { return nil, false }); got != "simple-mcp-server 1.4.0" {
+		t.Errorf("unexpected output without build information %q", got)
+	}
+}
+
+// Test that the configured server info is returned by initialize
+func TestServerInfo(t *testing.T) {
+	initialize := `{"jsonrpc":"2.0","method":"initialize","params":{"protocolVersion":"2025-06-18"},"id":1}` + "\n"
+	lines := runServerInput(t, NewServer(), initialize)
+	if !strings.Contains(lines[0], `"serverInfo":{"name":"simple-mcp-server","version":"`+serverVersion+`"}`) {
+		t.Errorf("expected the default server info, got %s", lines[0])
+	}
+	lines = runServerInput(t, NewServer(WithServerInfo("files", "", "File Tools")), initialize)
+	if !strings.Contains(lines[0], `"serverInfo":{"name":"files","title":"File Tools","version":"`+serverVersion+`"}`) {
+		t.Errorf("expected the configured server info, got %s", lines[0])
+	}
+}
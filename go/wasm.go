@@ -0,0 +1,983 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// This file decodes WebAssembly binary modules for the interpreter in
+// wasm_exec.go. It supports the core 2.0 instruction set except SIMD,
+// reference-typed tables, and multiple memories: what compilers emit for
+// wasm32 targets by default.
+
+// WebAssembly value types.
+const (
+	wasmI32 byte = 0x7f
+	wasmI64 byte = 0x7e
+	wasmF32 byte = 0x7d
+	wasmF64 byte = 0x7c
+)
+
+// wasmPageSize is the unit in which memories grow.
+const wasmPageSize = 65536
+
+// wasmMaxPages caps the memory of a module instance at 16 MiB, whatever
+// the module declares.
+const wasmMaxPages = 256
+
+// wasmFuncType is the signature of a function.
+type wasmFuncType struct {
+	params, results []byte
+}
+
+// equal reports whether two signatures are the same.
+func (t wasmFuncType) equal(u wasmFuncType) bool {
+	return bytes.Equal(t.params, u.params) && bytes.Equal(t.results, u.results)
+}
+
+// wasmInstr is a decoded instruction. Ops prefixed by 0xfc are stored as
+// 0xfc00 plus their subopcode. For block, loop, and if, in and out are the
+// number of values the block takes and leaves, a is the index of its end
+// and b that of its else, if any.
+type wasmInstr struct {
+	op      uint16
+	in, out uint16
+	a, b    uint64
+}
+
+// wasmFunc is a function defined by a module.
+type wasmFunc struct {
+	typ    wasmFuncType
+	locals []byte // the types of the locals after the parameters
+	code   []wasmInstr
+	tables [][]uint32 // the targets of its br_table instructions
+}
+
+// wasmGlobal is a global variable and its initial value.
+type wasmGlobal struct {
+	typ     byte
+	mutable bool
+	init    wasmConstExpr
+}
+
+// wasmConstExpr is a constant expression: a value, or a global's value.
+type wasmConstExpr struct {
+	value  uint64
+	global int // when not -1, the global whose value it is
+}
+
+// wasmSegment is an element or data segment. Passive segments have no
+// offset and are only copied by memory.init.
+type wasmSegment struct {
+	active bool
+	offset wasmConstExpr
+	funcs  []int32 // of element segments, -1 for null references
+	data   []byte  // of data segments
+}
+
+// wasmModule is a decoded module.
+type wasmModule struct {
+	types    []wasmFuncType
+	funcs    []*wasmFunc
+	table    *wasmLimits
+	memory   *wasmLimits
+	globals  []wasmGlobal
+	exports  map[string]wasmExport
+	start    int // -1 without a start function
+	elements []wasmSegment
+	data     []wasmSegment
+}
+
+// wasmLimits are the initial and maximum sizes of a table or memory.
+type wasmLimits struct {
+	min, max uint32
+	hasMax   bool
+}
+
+// wasmExport is an exported function, table, memory, or global.
+type wasmExport struct {
+	kind  byte
+	index uint32
+}
+
+// Kinds of exports.
+const (
+	wasmExportFunc   = 0
+	wasmExportTable  = 1
+	wasmExportMemory = 2
+	wasmExportGlobal = 3
+)
+
+// errWASMTruncated is returned for modules that end inside a section.
+var errWASMTruncated = errors.New("wasm: unexpected end of module")
+
+// wasmReader reads the primitive encodings of the binary format.
+type wasmReader struct {
+	p []byte
+}
+
+func (r *wasmReader) byte() (byte, error) {
+	if len(r.p) == 0 {
+		return 0, errWASMTruncated
+	}
+	b := r.p[0]
+	r.p = r.p[1:]
+	return b, nil
+}
+
+func (r *wasmReader) bytes(n uint32) ([]byte, error) {
+	if uint64(n) > uint64(len(r.p)) {
+		return nil, errWASMTruncated
+	}
+	b := r.p[:n]
+	r.p = r.p[n:]
+	return b, nil
+}
+
+// u32 reads an unsigned LEB128 integer of at most 32 bits.
+func (r *wasmReader) u32() (uint32, error) {
+	var v uint64
+	for shift := uint(0); shift < 35; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if v > math.MaxUint32 {
+				return 0, errors.New("wasm: integer too large")
+			}
+			return uint32(v), nil
+		}
+	}
+	return 0, errors.New("wasm: integer too long")
+}
+
+// signed reads a signed LEB128 integer of at most bits bits.
+func (r *wasmReader) signed(bits uint) (int64, error) {
+	var v int64
+	var shift uint
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		if shift >= bits+7 {
+			return 0, errors.New("wasm: integer too long")
+		}
+		v |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 {
+			if shift < 64 && b&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v, nil
+		}
+	}
+}
+
+func (r *wasmReader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(n)
+	return string(b), err
+}
+
+// count reads the length of a vector, bounding it by the bytes left so
+// that a corrupt length cannot cause a huge allocation.
+func (r *wasmReader) count() (uint32, error) {
+	n, err := r.u32()
+	if err == nil && uint64(n) > uint64(len(r.p)) {
+		err = errWASMTruncated
+	}
+	return n, err
+}
+
+func (r *wasmReader) valueType() (byte, error) {
+	t, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch t {
+	case wasmI32, wasmI64, wasmF32, wasmF64:
+		return t, nil
+	}
+	return 0, fmt.Errorf("wasm: unsupported value type 0x%02x", t)
+}
+
+func (r *wasmReader) limits() (*wasmLimits, error) {
+	flag, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	l := &wasmLimits{}
+	if l.min, err = r.u32(); err != nil {
+		return nil, err
+	}
+	switch flag {
+	case 0:
+	case 1:
+		l.hasMax = true
+		if l.max, err = r.u32(); err != nil {
+			return nil, err
+		}
+		if l.max < l.min {
+			return nil, errors.New("wasm: maximum size below the minimum")
+		}
+	default:
+		return nil, fmt.Errorf("wasm: unsupported limits 0x%02x", flag)
+	}
+	return l, nil
+}
+
+// decodeWASM decodes a binary module.
+func decodeWASM(data []byte) (*wasmModule, error) {
+	if len(data) < 8 || string(data[:4]) != "\x00asm" {
+		return nil, errors.New("wasm: not a WebAssembly module")
+	}
+	if binary.LittleEndian.Uint32(data[4:]) != 1 {
+		return nil, errors.New("wasm: unsupported binary format version")
+	}
+	m := &wasmModule{exports: map[string]wasmExport{}, start: -1}
+	var funcTypes []uint32
+	r := &wasmReader{p: data[8:]}
+	last := byte(0)
+	for len(r.p) > 0 {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		content, err := r.bytes(size)
+		if err != nil {
+			return nil, err
+		}
+		if id == 0 {
+			continue // custom sections
+		}
+		if wasmSectionOrder[id] == 0 {
+			return nil, fmt.Errorf("wasm: unknown section %d", id)
+		}
+		if wasmSectionOrder[id] <= last {
+			return nil, fmt.Errorf("wasm: section %d out of order", id)
+		}
+		last = wasmSectionOrder[id]
+		s := &wasmReader{p: content}
+		switch id {
+		case 1:
+			err = m.decodeTypes(s)
+		case 2:
+			err = decodeWASMImports(s)
+		case 3:
+			funcTypes, err = m.decodeFunctions(s)
+		case 4:
+			err = m.decodeTable(s)
+		case 5:
+			err = m.decodeMemory(s)
+		case 6:
+			err = m.decodeGlobals(s)
+		case 7:
+			err = m.decodeExports(s)
+		case 8:
+			var start uint32
+			start, err = s.u32()
+			m.start = int(start)
+		case 9:
+			err = m.decodeElements(s)
+		case 10:
+			err = m.decodeCode(s, funcTypes)
+		case 11:
+			err = m.decodeData(s)
+		case 12:
+			_, err = s.u32()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(s.p) > 0 {
+			return nil, fmt.Errorf("wasm: section %d has trailing bytes", id)
+		}
+	}
+	if len(m.funcs) != len(funcTypes) {
+		return nil, errors.New("wasm: function and code sections differ in length")
+	}
+	if err := m.check(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// wasmSectionOrder ranks the known sections in the order they must
+// appear. The data count section, added later, precedes the code section.
+var wasmSectionOrder = map[byte]byte{1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9, 12: 10, 10: 11, 11: 12}
+
+// check reports references to functions, globals, tables, and memories
+// the module does not define.
+func (m *wasmModule) check() error {
+	if m.start >= len(m.funcs) {
+		return errors.New("wasm: start function out of range")
+	}
+	if m.start >= 0 && len(m.funcs[m.start].typ.params)+len(m.funcs[m.start].typ.results) > 0 {
+		return errors.New("wasm: start function with parameters or results")
+	}
+	for name, e := range m.exports {
+		var n int
+		switch e.kind {
+		case wasmExportFunc:
+			n = len(m.funcs)
+		case wasmExportTable:
+			n = boolInt(m.table != nil)
+		case wasmExportMemory:
+			n = boolInt(m.memory != nil)
+		case wasmExportGlobal:
+			n = len(m.globals)
+		default:
+			return fmt.Errorf("wasm: export %q of unknown kind", name)
+		}
+		if int(e.index) >= n {
+			return fmt.Errorf("wasm: export %q out of range", name)
+		}
+	}
+	for _, seg := range m.elements {
+		if m.table == nil && seg.active {
+			return errors.New("wasm: element segment without a table")
+		}
+		for _, f := range seg.funcs {
+			if int(f) >= len(m.funcs) {
+				return errors.New("wasm: element segment function out of range")
+			}
+		}
+	}
+	for _, seg := range m.data {
+		if m.memory == nil && seg.active {
+			return errors.New("wasm: data segment without a memory")
+		}
+	}
+	for i, f := range m.funcs {
+		if err := m.checkFunc(f); err != nil {
+			return fmt.Errorf("wasm: function %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkFunc reports indices in f's instructions that are out of range.
+func (m *wasmModule) checkFunc(f *wasmFunc) error {
+	locals := uint64(len(f.typ.params) + len(f.locals))
+	for _, ins := range f.code {
+		var ok bool
+		switch ins.op {
+		case 0x10:
+			ok = ins.a < uint64(len(m.funcs))
+		case 0x11:
+			ok = ins.a < uint64(len(m.types)) && m.table != nil
+		case 0x20, 0x21, 0x22:
+			ok = ins.a < locals
+		case 0x23:
+			ok = ins.a < uint64(len(m.globals))
+		case 0x24:
+			ok = ins.a < uint64(len(m.globals)) && m.globals[ins.a].mutable
+		case 0xfc08:
+			ok = ins.a < uint64(len(m.data)) && m.memory != nil
+		case 0xfc09:
+			ok = ins.a < uint64(len(m.data))
+		default:
+			memory := ins.op >= 0x28 && ins.op <= 0x40 || ins.op == 0xfc0a || ins.op == 0xfc0b
+			ok = !memory || m.memory != nil
+		}
+		if !ok {
+			return fmt.Errorf("invalid operand of instruction 0x%x", ins.op)
+		}
+	}
+	return nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (m *wasmModule) decodeTypes(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		if form, err := r.byte(); err != nil {
+			return err
+		} else if form != 0x60 {
+			return fmt.Errorf("wasm: unsupported type form 0x%02x", form)
+		}
+		var t wasmFuncType
+		for _, list := range []*[]byte{&t.params, &t.results} {
+			count, err := r.count()
+			if err != nil {
+				return err
+			}
+			*list = make([]byte, count)
+			for j := range *list {
+				if (*list)[j], err = r.valueType(); err != nil {
+					return err
+				}
+			}
+		}
+		m.types = append(m.types, t)
+	}
+	return nil
+}
+
+// decodeWASMImports rejects modules that import anything: plugins are
+// sandboxed and the host provides nothing but their memory.
+func decodeWASMImports(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil || n == 0 {
+		return err
+	}
+	module, err := r.name()
+	if err != nil {
+		return err
+	}
+	name, err := r.name()
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("wasm: the module imports %s.%s, but the host provides no imports", module, name)
+}
+
+func (m *wasmModule) decodeFunctions(r *wasmReader) ([]uint32, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	types := make([]uint32, n)
+	for i := range types {
+		if types[i], err = r.u32(); err != nil {
+			return nil, err
+		}
+		if int(types[i]) >= len(m.types) {
+			return nil, errors.New("wasm: function type out of range")
+		}
+	}
+	return types, nil
+}
+
+func (m *wasmModule) decodeTable(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	if n > 1 {
+		return errors.New("wasm: multiple tables are not supported")
+	}
+	if n == 1 {
+		if t, err := r.byte(); err != nil {
+			return err
+		} else if t != 0x70 {
+			return errors.New("wasm: only tables of functions are supported")
+		}
+		if m.table, err = r.limits(); err != nil {
+			return err
+		}
+		if m.table.min > 1<<20 {
+			return errors.New("wasm: table too large")
+		}
+	}
+	return nil
+}
+
+func (m *wasmModule) decodeMemory(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	if n > 1 {
+		return errors.New("wasm: multiple memories are not supported")
+	}
+	if n == 1 {
+		if m.memory, err = r.limits(); err != nil {
+			return err
+		}
+		if m.memory.min > wasmMaxPages {
+			return fmt.Errorf("wasm: the module needs %d pages of memory, more than the %d allowed", m.memory.min, wasmMaxPages)
+		}
+	}
+	return nil
+}
+
+func (m *wasmModule) decodeGlobals(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		var g wasmGlobal
+		if g.typ, err = r.valueType(); err != nil {
+			return err
+		}
+		mut, err := r.byte()
+		if err != nil {
+			return err
+		}
+		g.mutable = mut == 1
+		if g.init, err = m.constExpr(r, g.typ); err != nil {
+			return err
+		}
+		m.globals = append(m.globals, g)
+	}
+	return nil
+}
+
+// constExpr reads a constant expression of type typ.
+func (m *wasmModule) constExpr(r *wasmReader, typ byte) (wasmConstExpr, error) {
+	e := wasmConstExpr{global: -1}
+	op, err := r.byte()
+	if err != nil {
+		return e, err
+	}
+	var got byte
+	switch op {
+	case 0x41:
+		v, err := r.signed(32)
+		if err != nil {
+			return e, err
+		}
+		e.value, got = uint64(uint32(v)), wasmI32
+	case 0x42:
+		v, err := r.signed(64)
+		if err != nil {
+			return e, err
+		}
+		e.value, got = uint64(v), wasmI64
+	case 0x43:
+		b, err := r.bytes(4)
+		if err != nil {
+			return e, err
+		}
+		e.value, got = uint64(binary.LittleEndian.Uint32(b)), wasmF32
+	case 0x44:
+		b, err := r.bytes(8)
+		if err != nil {
+			return e, err
+		}
+		e.value, got = binary.LittleEndian.Uint64(b), wasmF64
+	case 0x23:
+		index, err := r.u32()
+		if err != nil {
+			return e, err
+		}
+		if int(index) >= len(m.globals) || m.globals[index].mutable {
+			return e, errors.New("wasm: constant expression reads an unknown or mutable global")
+		}
+		e.global, got = int(index), m.globals[index].typ
+	default:
+		return e, fmt.Errorf("wasm: unsupported constant expression 0x%02x", op)
+	}
+	if end, err := r.byte(); err != nil {
+		return e, err
+	} else if end != 0x0b {
+		return e, errors.New("wasm: constant expression is not constant")
+	}
+	if got != typ {
+		return e, errors.New("wasm: constant expression of the wrong type")
+	}
+	return e, nil
+}
+
+func (m *wasmModule) decodeExports(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+		var e wasmExport
+		if e.kind, err = r.byte(); err != nil {
+			return err
+		}
+		if e.index, err = r.u32(); err != nil {
+			return err
+		}
+		if _, ok := m.exports[name]; ok {
+			return fmt.Errorf("wasm: duplicate export %q", name)
+		}
+		m.exports[name] = e
+	}
+	return nil
+}
+
+func (m *wasmModule) decodeElements(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		flags, err := r.u32()
+		if err != nil {
+			return err
+		}
+		if flags > 7 {
+			return fmt.Errorf("wasm: unknown element segment kind %d", flags)
+		}
+		var seg wasmSegment
+		seg.active = flags&1 == 0
+		if flags&2 != 0 && seg.active {
+			if table, err := r.u32(); err != nil {
+				return err
+			} else if table != 0 {
+				return errors.New("wasm: element segment for an unknown table")
+			}
+		}
+		if seg.active {
+			if seg.offset, err = m.constExpr(r, wasmI32); err != nil {
+				return err
+			}
+		}
+		if flags&3 != 0 {
+			kind, err := r.byte()
+			if err != nil {
+				return err
+			}
+			if flags&4 == 0 && kind != 0x00 || flags&4 != 0 && kind != 0x70 {
+				return errors.New("wasm: only elements of functions are supported")
+			}
+		}
+		count, err := r.count()
+		if err != nil {
+			return err
+		}
+		seg.funcs = make([]int32, count)
+		for j := range seg.funcs {
+			if seg.funcs[j], err = elementFunc(r, flags&4 != 0); err != nil {
+				return err
+			}
+		}
+		if flags&3 == 3 {
+			continue // declarative segments only declare references
+		}
+		m.elements = append(m.elements, seg)
+	}
+	return nil
+}
+
+// elementFunc reads an element: a function index, or an expression that
+// is a function reference or null.
+func elementFunc(r *wasmReader, expr bool) (int32, error) {
+	if !expr {
+		f, err := r.u32()
+		return int32(f), err
+	}
+	op, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	f := int32(-1)
+	switch op {
+	case 0xd0: // ref.null
+		if _, err := r.byte(); err != nil {
+			return 0, err
+		}
+	case 0xd2: // ref.func
+		index, err := r.u32()
+		if err != nil {
+			return 0, err
+		}
+		f = int32(index)
+	default:
+		return 0, errors.New("wasm: unsupported element expression")
+	}
+	if end, err := r.byte(); err != nil {
+		return 0, err
+	} else if end != 0x0b {
+		return 0, errors.New("wasm: unsupported element expression")
+	}
+	return f, nil
+}
+
+func (m *wasmModule) decodeData(r *wasmReader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < n; i++ {
+		flags, err := r.u32()
+		if err != nil {
+			return err
+		}
+		var seg wasmSegment
+		switch flags {
+		case 0, 2:
+			if flags == 2 {
+				if memory, err := r.u32(); err != nil {
+					return err
+				} else if memory != 0 {
+					return errors.New("wasm: data segment for an unknown memory")
+				}
+			}
+			seg.active = true
+			if seg.offset, err = m.constExpr(r, wasmI32); err != nil {
+				return err
+			}
+		case 1:
+		default:
+			return fmt.Errorf("wasm: unknown data segment kind %d", flags)
+		}
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		if seg.data, err = r.bytes(size); err != nil {
+			return err
+		}
+		m.data = append(m.data, seg)
+	}
+	return nil
+}
+
+func (m *wasmModule) decodeCode(r *wasmReader, funcTypes []uint32) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	if int(n) != len(funcTypes) {
+		return errors.New("wasm: function and code sections differ in length")
+	}
+	for i := uint32(0); i < n; i++ {
+		size, err := r.u32()
+		if err != nil {
+			return err
+		}
+		body, err := r.bytes(size)
+		if err != nil {
+			return err
+		}
+		f := &wasmFunc{typ: m.types[funcTypes[i]]}
+		if err := m.decodeBody(f, &wasmReader{p: body}); err != nil {
+			return fmt.Errorf("wasm: function %d: %w", i, err)
+		}
+		m.funcs = append(m.funcs, f)
+	}
+	return nil
+}
+
+// wasmMaxLocals bounds the locals of a function.
+const wasmMaxLocals = 50000
+
+// decodeBody decodes a function's locals and instructions, resolving the
+// end and else of each block.
+func (m *wasmModule) decodeBody(f *wasmFunc, r *wasmReader) error {
+	groups, err := r.count()
+	if err != nil {
+		return err
+	}
+	for i := uint32(0); i < groups; i++ {
+		count, err := r.u32()
+		if err != nil {
+			return err
+		}
+		typ, err := r.valueType()
+		if err != nil {
+			return err
+		}
+		if uint64(len(f.locals))+uint64(count) > wasmMaxLocals {
+			return errors.New("too many locals")
+		}
+		f.locals = append(f.locals, bytes.Repeat([]byte{typ}, int(count))...)
+	}
+
+	var blocks []int // the open blocks, by the index of their instruction
+	for {
+		op, err := r.byte()
+		if err != nil {
+			return err
+		}
+		ins := wasmInstr{op: uint16(op)}
+		switch {
+		case op == 0x02 || op == 0x03 || op == 0x04:
+			if ins.in, ins.out, err = m.blockType(r); err != nil {
+				return err
+			}
+			blocks = append(blocks, len(f.code))
+		case op == 0x05:
+			if len(blocks) == 0 || f.code[blocks[len(blocks)-1]].op != 0x04 || f.code[blocks[len(blocks)-1]].b != 0 {
+				return errors.New("else outside of if")
+			}
+			f.code[blocks[len(blocks)-1]].b = uint64(len(f.code))
+		case op == 0x0b:
+			if len(blocks) == 0 {
+				f.code = append(f.code, ins)
+				if len(r.p) > 0 {
+					return errors.New("instructions after the end of the function")
+				}
+				return nil
+			}
+			f.code[blocks[len(blocks)-1]].a = uint64(len(f.code))
+			blocks = blocks[:len(blocks)-1]
+		case op == 0x0c || op == 0x0d || op == 0x10 || op >= 0x20 && op <= 0x24:
+			index, err := r.u32()
+			if err != nil {
+				return err
+			}
+			ins.a = uint64(index)
+			if op == 0x0c || op == 0x0d {
+				if int(index) > len(blocks) {
+					return errors.New("branch out of range")
+				}
+			}
+		case op == 0x0e:
+			count, err := r.count()
+			if err != nil {
+				return err
+			}
+			targets := make([]uint32, count+1) // the last is the default
+			for j := range targets {
+				if targets[j], err = r.u32(); err != nil {
+					return err
+				}
+				if int(targets[j]) > len(blocks) {
+					return errors.New("branch out of range")
+				}
+			}
+			ins.a = uint64(len(f.tables))
+			f.tables = append(f.tables, targets)
+		case op == 0x11:
+			typ, err := r.u32()
+			if err != nil {
+				return err
+			}
+			table, err := r.u32()
+			if err != nil {
+				return err
+			}
+			if table != 0 {
+				return errors.New("call_indirect through an unknown table")
+			}
+			ins.a = uint64(typ)
+		case op == 0x1c:
+			count, err := r.count()
+			if err != nil {
+				return err
+			}
+			for j := uint32(0); j < count; j++ {
+				if _, err := r.valueType(); err != nil {
+					return err
+				}
+			}
+			ins.op = 0x1b
+		case op >= 0x28 && op <= 0x3e:
+			if _, err := r.u32(); err != nil { // alignment
+				return err
+			}
+			offset, err := r.u32()
+			if err != nil {
+				return err
+			}
+			ins.a = uint64(offset)
+		case op == 0x3f || op == 0x40:
+			if memory, err := r.byte(); err != nil {
+				return err
+			} else if memory != 0 {
+				return errors.New("unknown memory")
+			}
+		case op == 0x41:
+			v, err := r.signed(32)
+			if err != nil {
+				return err
+			}
+			ins.a = uint64(uint32(v))
+		case op == 0x42:
+			v, err := r.signed(64)
+			if err != nil {
+				return err
+			}
+			ins.a = uint64(v)
+		case op == 0x43:
+			b, err := r.bytes(4)
+			if err != nil {
+				return err
+			}
+			ins.a = uint64(binary.LittleEndian.Uint32(b))
+		case op == 0x44:
+			b, err := r.bytes(8)
+			if err != nil {
+				return err
+			}
+			ins.a = binary.LittleEndian.Uint64(b)
+		case op == 0xfc:
+			sub, err := r.u32()
+			if err != nil {
+				return err
+			}
+			if sub > 11 {
+				return fmt.Errorf("unsupported instruction 0xfc %d", sub)
+			}
+			ins.op = 0xfc00 | uint16(sub)
+			switch sub {
+			case 8: // memory.init
+				index, err := r.u32()
+				if err != nil {
+					return err
+				}
+				ins.a = uint64(index)
+				if _, err := r.byte(); err != nil {
+					return err
+				}
+			case 9: // data.drop
+				index, err := r.u32()
+				if err != nil {
+					return err
+				}
+				ins.a = uint64(index)
+			case 10: // memory.copy
+				if _, err := r.bytes(2); err != nil {
+					return err
+				}
+			case 11: // memory.fill
+				if _, err := r.byte(); err != nil {
+					return err
+				}
+			}
+		case op == 0x00 || op == 0x01 || op == 0x0f || op == 0x1a || op == 0x1b || op >= 0x45 && op <= 0xc4:
+		default:
+			return fmt.Errorf("unsupported instruction 0x%02x", op)
+		}
+		f.code = append(f.code, ins)
+	}
+}
+
+// blockType reads the type of a block and returns the number of values it
+// takes and leaves.
+func (m *wasmModule) blockType(r *wasmReader) (in, out uint16, err error) {
+	if len(r.p) == 0 {
+		return 0, 0, errWASMTruncated
+	}
+	switch r.p[0] {
+	case 0x40:
+		r.p = r.p[1:]
+		return 0, 0, nil
+	case wasmI32, wasmI64, wasmF32, wasmF64:
+		r.p = r.p[1:]
+		return 0, 1, nil
+	}
+	index, err := r.signed(33)
+	if err != nil {
+		return 0, 0, err
+	}
+	if index < 0 || index >= int64(len(m.types)) {
+		return 0, 0, errors.New("block type out of range")
+	}
+	t := m.types[index]
+	return uint16(len(t.params)), uint16(len(t.results)), nil
+}
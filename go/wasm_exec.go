@@ -0,0 +1,876 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"runtime"
+)
+
+// wasmInstance is an instantiated module: its memory, globals, and table,
+// and the stack of the call running in it. An instance runs one call at a
+// time.
+type wasmInstance struct {
+	mod     *wasmModule
+	memory  []byte
+	maxMem  uint32 // in pages
+	globals []uint64
+	table   []int32  // function indices, -1 for null
+	data    [][]byte // the data segments not dropped
+	stack   []uint64 // operands and locals
+	depth   int      // of calls
+	steps   int      // until the context is checked again
+	fuel    int64    // instructions left
+	ctx     context.Context
+}
+
+// Limits on a call into an instance, so that runaway plugins trap instead
+// of exhausting the host.
+const (
+	wasmMaxDepth   = 1000    // nested calls
+	wasmMaxStack   = 1 << 20 // operands and locals
+	wasmCheckEvery = 1 << 14 // instructions between checks of the context
+	wasmMaxFuel    = 1 << 30 // instructions per plugin run, whatever the time limit
+)
+
+// wasmTrap is a runtime error of the module, such as an out-of-bounds
+// memory access.
+type wasmTrap struct {
+	message string
+}
+
+// Error implements the error interface.
+func (t *wasmTrap) Error() string {
+	return "wasm trap: " + t.message
+}
+
+// trap aborts the running call.
+func trap(format string, args ...interface{}) {
+	panic(&wasmTrap{message: fmt.Sprintf(format, args...)})
+}
+
+// instantiate creates an instance of m, initializing its memory and table
+// from the active segments and running its start function. The instance
+// traps once it has run fuel instructions, the start function included.
+func (m *wasmModule) instantiate(ctx context.Context, fuel int64) (in *wasmInstance, err error) {
+	in = &wasmInstance{mod: m, ctx: ctx, steps: wasmCheckEvery, fuel: fuel}
+	if m.memory != nil {
+		in.memory = make([]byte, int(m.memory.min)*wasmPageSize)
+		in.maxMem = wasmMaxPages
+		if m.memory.hasMax && m.memory.max < wasmMaxPages {
+			in.maxMem = m.memory.max
+		}
+	}
+	if m.table != nil {
+		in.table = make([]int32, m.table.min)
+		for i := range in.table {
+			in.table[i] = -1
+		}
+	}
+	in.globals = make([]uint64, len(m.globals))
+	for i, g := range m.globals {
+		in.globals[i] = in.constValue(g.init)
+	}
+	for _, seg := range m.data {
+		in.data = append(in.data, seg.data)
+	}
+
+	defer in.recover(&err)
+	for _, seg := range m.elements {
+		if !seg.active {
+			continue
+		}
+		offset := uint64(uint32(in.constValue(seg.offset)))
+		if offset+uint64(len(seg.funcs)) > uint64(len(in.table)) {
+			trap("element segment out of bounds")
+		}
+		copy(in.table[offset:], seg.funcs)
+	}
+	for i, seg := range m.data {
+		if !seg.active {
+			continue
+		}
+		offset := uint64(uint32(in.constValue(seg.offset)))
+		if offset+uint64(len(seg.data)) > uint64(len(in.memory)) {
+			trap("data segment out of bounds")
+		}
+		copy(in.memory[offset:], seg.data)
+		in.data[i] = nil // active segments are dropped once copied
+	}
+	if m.start >= 0 {
+		in.invoke(m.start)
+	}
+	return in, nil
+}
+
+// constValue evaluates a constant expression.
+func (in *wasmInstance) constValue(e wasmConstExpr) uint64 {
+	if e.global >= 0 {
+		return in.globals[e.global]
+	}
+	return e.value
+}
+
+// recover turns a trap, or a runtime error caused by a malformed module,
+// into an error. Other panics are not the module's doing and propagate.
+func (in *wasmInstance) recover(err *error) {
+	switch p := recover().(type) {
+	case nil:
+	case *wasmTrap:
+		*err = p
+	case runtime.Error:
+		*err = &wasmTrap{message: "invalid module: " + p.Error()}
+	case error:
+		if errors.Is(p, context.Canceled) || errors.Is(p, context.DeadlineExceeded) {
+			*err = p
+			return
+		}
+		panic(p)
+	default:
+		panic(p)
+	}
+}
+
+// call calls the exported function name with args and returns its
+// results. A trap leaves the instance unusable.
+func (in *wasmInstance) call(ctx context.Context, name string, args ...uint64) (results []uint64, err error) {
+	e, ok := in.mod.exports[name]
+	if !ok || e.kind != wasmExportFunc {
+		return nil, fmt.Errorf("wasm: the module does not export the function %s", name)
+	}
+	f := in.mod.funcs[e.index]
+	if len(args) != len(f.typ.params) {
+		return nil, fmt.Errorf("wasm: %s takes %d arguments, not %d", name, len(f.typ.params), len(args))
+	}
+	in.ctx = ctx
+	in.stack = append(in.stack[:0], args...)
+	defer in.recover(&err)
+	in.invoke(int(e.index))
+	return append([]uint64(nil), in.stack...), nil
+}
+
+// push pushes an operand.
+func (in *wasmInstance) push(v uint64) {
+	in.stack = append(in.stack, v)
+}
+
+// pop pops an operand.
+func (in *wasmInstance) pop() uint64 {
+	v := in.stack[len(in.stack)-1]
+	in.stack = in.stack[:len(in.stack)-1]
+	return v
+}
+
+// pop32 pops an i32 operand.
+func (in *wasmInstance) pop32() uint32 {
+	return uint32(in.pop())
+}
+
+// popF32 and popF64 pop float operands.
+func (in *wasmInstance) popF32() float32 { return math.Float32frombits(uint32(in.pop())) }
+func (in *wasmInstance) popF64() float64 { return math.Float64frombits(in.pop()) }
+
+// pushF32 and pushF64 push float operands.
+func (in *wasmInstance) pushF32(x float32) { in.push(uint64(math.Float32bits(x))) }
+func (in *wasmInstance) pushF64(x float64) { in.push(math.Float64bits(x)) }
+
+// pushBool pushes a comparison's result.
+func (in *wasmInstance) pushBool(b bool) {
+	if b {
+		in.push(1)
+	} else {
+		in.push(0)
+	}
+}
+
+// address returns the range of n bytes of memory at base plus offset.
+func (in *wasmInstance) address(offset uint64, n uint64) []byte {
+	ea := uint64(in.pop32()) + offset
+	if ea+n > uint64(len(in.memory)) {
+		trap("out of bounds memory access")
+	}
+	return in.memory[ea : ea+n]
+}
+
+// wasmLabel is the target of a branch: where to continue, the height of
+// the stack to restore, and the number of values the branch carries.
+type wasmLabel struct {
+	pc     int
+	height int
+	arity  int
+}
+
+// invoke runs function index, whose arguments are on the stack, leaving
+// its results there instead.
+func (in *wasmInstance) invoke(index int) {
+	f := in.mod.funcs[index]
+	in.depth++
+	if in.depth > wasmMaxDepth {
+		trap("call stack exhausted")
+	}
+	defer func() { in.depth-- }()
+	base := len(in.stack) - len(f.typ.params)
+	if base < 0 {
+		trap("invalid module: stack underflow")
+	}
+	if len(in.stack)+len(f.locals) > wasmMaxStack {
+		trap("stack exhausted")
+	}
+	for range f.locals {
+		in.push(0)
+	}
+	code := f.code
+	last := len(code) - 1
+	labels := []wasmLabel{{pc: last - 1, height: len(in.stack), arity: len(f.typ.results)}}
+
+	// branch unwinds to the label depth levels out, returning the pc of
+	// the instruction before the one to continue with.
+	branch := func(depth int) int {
+		l := labels[len(labels)-1-depth]
+		copy(in.stack[l.height:], in.stack[len(in.stack)-l.arity:])
+		in.stack = in.stack[:l.height+l.arity]
+		labels = labels[:len(labels)-1-depth]
+		return l.pc
+	}
+
+	for pc := 0; ; pc++ {
+		if in.steps--; in.steps <= 0 {
+			in.steps = wasmCheckEvery
+			if err := in.ctx.Err(); err != nil {
+				panic(err)
+			}
+		}
+		if in.fuel--; in.fuel < 0 {
+			trap("out of fuel")
+		}
+		ins := &code[pc]
+		switch ins.op {
+		case 0x00:
+			trap("unreachable")
+		case 0x01:
+		case 0x02: // block
+			labels = append(labels, wasmLabel{pc: int(ins.a), height: len(in.stack) - int(ins.in), arity: int(ins.out)})
+		case 0x03: // loop
+			labels = append(labels, wasmLabel{pc: pc - 1, height: len(in.stack) - int(ins.in), arity: int(ins.in)})
+		case 0x04: // if
+			cond := in.pop32()
+			labels = append(labels, wasmLabel{pc: int(ins.a), height: len(in.stack) - int(ins.in), arity: int(ins.out)})
+			if cond == 0 {
+				if ins.b != 0 {
+					pc = int(ins.b)
+				} else {
+					labels = labels[:len(labels)-1]
+					pc = int(ins.a)
+				}
+			}
+		case 0x05: // else, reached from the end of the then branch
+			pc = labels[len(labels)-1].pc - 1
+		case 0x0b: // end
+			if pc == last {
+				n := len(f.typ.results)
+				copy(in.stack[base:], in.stack[len(in.stack)-n:])
+				in.stack = in.stack[:base+n]
+				return
+			}
+			labels = labels[:len(labels)-1]
+		case 0x0c: // br
+			pc = branch(int(ins.a))
+		case 0x0d: // br_if
+			if in.pop32() != 0 {
+				pc = branch(int(ins.a))
+			}
+		case 0x0e: // br_table
+			targets := f.tables[ins.a]
+			i := in.pop32()
+			if i >= uint32(len(targets)-1) {
+				i = uint32(len(targets) - 1)
+			}
+			pc = branch(int(targets[i]))
+		case 0x0f: // return
+			pc = branch(len(labels) - 1)
+		case 0x10: // call
+			in.invoke(int(ins.a))
+		case 0x11: // call_indirect
+			i := in.pop32()
+			if i >= uint32(len(in.table)) {
+				trap("undefined element")
+			}
+			target := in.table[i]
+			if target < 0 {
+				trap("uninitialized element")
+			}
+			if !in.mod.funcs[target].typ.equal(in.mod.types[ins.a]) {
+				trap("indirect call type mismatch")
+			}
+			in.invoke(int(target))
+		case 0x1a: // drop
+			in.pop()
+		case 0x1b: // select
+			cond := in.pop32()
+			b, a := in.pop(), in.pop()
+			if cond != 0 {
+				in.push(a)
+			} else {
+				in.push(b)
+			}
+		case 0x20: // local.get
+			in.push(in.stack[base+int(ins.a)])
+		case 0x21: // local.set
+			in.stack[base+int(ins.a)] = in.pop()
+		case 0x22: // local.tee
+			in.stack[base+int(ins.a)] = in.stack[len(in.stack)-1]
+		case 0x23: // global.get
+			in.push(in.globals[ins.a])
+		case 0x24: // global.set
+			in.globals[ins.a] = in.pop()
+
+		case 0x28, 0x2a: // i32.load, f32.load
+			in.push(uint64(binary.LittleEndian.Uint32(in.address(ins.a, 4))))
+		case 0x29, 0x2b: // i64.load, f64.load
+			in.push(binary.LittleEndian.Uint64(in.address(ins.a, 8)))
+		case 0x2c:
+			in.push(uint64(uint32(int8(in.address(ins.a, 1)[0]))))
+		case 0x2d:
+			in.push(uint64(in.address(ins.a, 1)[0]))
+		case 0x2e:
+			in.push(uint64(uint32(int16(binary.LittleEndian.Uint16(in.address(ins.a, 2))))))
+		case 0x2f:
+			in.push(uint64(binary.LittleEndian.Uint16(in.address(ins.a, 2))))
+		case 0x30:
+			in.push(uint64(int8(in.address(ins.a, 1)[0])))
+		case 0x31:
+			in.push(uint64(in.address(ins.a, 1)[0]))
+		case 0x32:
+			in.push(uint64(int16(binary.LittleEndian.Uint16(in.address(ins.a, 2)))))
+		case 0x33:
+			in.push(uint64(binary.LittleEndian.Uint16(in.address(ins.a, 2))))
+		case 0x34:
+			in.push(uint64(int32(binary.LittleEndian.Uint32(in.address(ins.a, 4)))))
+		case 0x35:
+			in.push(uint64(binary.LittleEndian.Uint32(in.address(ins.a, 4))))
+		case 0x36, 0x38, 0x3e: // i32.store, f32.store, i64.store32
+			v := in.pop()
+			binary.LittleEndian.PutUint32(in.address(ins.a, 4), uint32(v))
+		case 0x37, 0x39: // i64.store, f64.store
+			v := in.pop()
+			binary.LittleEndian.PutUint64(in.address(ins.a, 8), v)
+		case 0x3a, 0x3c: // store8
+			v := in.pop()
+			in.address(ins.a, 1)[0] = byte(v)
+		case 0x3b, 0x3d: // store16
+			v := in.pop()
+			binary.LittleEndian.PutUint16(in.address(ins.a, 2), uint16(v))
+		case 0x3f: // memory.size
+			in.push(uint64(len(in.memory) / wasmPageSize))
+		case 0x40: // memory.grow
+			n := in.pop32()
+			pages := uint32(len(in.memory) / wasmPageSize)
+			if uint64(pages)+uint64(n) > uint64(in.maxMem) {
+				in.push(uint64(math.MaxUint32))
+				break
+			}
+			in.memory = append(in.memory, make([]byte, int(n)*wasmPageSize)...)
+			in.push(uint64(pages))
+
+		case 0x41, 0x42, 0x43, 0x44: // constants
+			in.push(ins.a)
+
+		case 0xfc08: // memory.init
+			n, src, dst := uint64(in.pop32()), uint64(in.pop32()), uint64(in.pop32())
+			data := in.data[ins.a]
+			if src+n > uint64(len(data)) || dst+n > uint64(len(in.memory)) {
+				trap("out of bounds memory access")
+			}
+			copy(in.memory[dst:], data[src:src+n])
+		case 0xfc09: // data.drop
+			in.data[ins.a] = nil
+		case 0xfc0a: // memory.copy
+			n, src, dst := uint64(in.pop32()), uint64(in.pop32()), uint64(in.pop32())
+			if src+n > uint64(len(in.memory)) || dst+n > uint64(len(in.memory)) {
+				trap("out of bounds memory access")
+			}
+			copy(in.memory[dst:dst+n], in.memory[src:src+n])
+		case 0xfc0b: // memory.fill
+			n, v, dst := uint64(in.pop32()), byte(in.pop32()), uint64(in.pop32())
+			if dst+n > uint64(len(in.memory)) {
+				trap("out of bounds memory access")
+			}
+			fill := in.memory[dst : dst+n]
+			for i := range fill {
+				fill[i] = v
+			}
+
+		default:
+			in.numeric(ins.op)
+		}
+	}
+}
+
+// numeric executes a numeric instruction.
+func (in *wasmInstance) numeric(op uint16) {
+	switch {
+	case op <= 0x5a:
+		in.compareInt(op)
+	case op <= 0x66:
+		in.compareFloat(op)
+	case op <= 0x78:
+		in.int32Op(op)
+	case op <= 0x8a:
+		in.int64Op(op)
+	case op <= 0xa6:
+		in.floatOp(op)
+	default:
+		in.convert(op)
+	}
+}
+
+// compareInt executes the integer comparisons, 0x45 to 0x5a.
+func (in *wasmInstance) compareInt(op uint16) {
+	if op == 0x45 {
+		in.pushBool(in.pop32() == 0)
+		return
+	}
+	if op == 0x50 {
+		in.pushBool(in.pop() == 0)
+		return
+	}
+	b, a := in.pop(), in.pop()
+	if op < 0x50 {
+		b, a = uint64(uint32(b)), uint64(uint32(a))
+		op += 0x51 - 0x46 // the same comparison of i64
+		sa, sb := int64(int32(a)), int64(int32(b))
+		in.pushBool(compareInts(op, a, b, sa, sb))
+		return
+	}
+	in.pushBool(compareInts(op, a, b, int64(a), int64(b)))
+}
+
+// compareInts compares integers by the i64 comparison op.
+func compareInts(op uint16, a, b uint64, sa, sb int64) bool {
+	switch op {
+	case 0x51:
+		return a == b
+	case 0x52:
+		return a != b
+	case 0x53:
+		return sa < sb
+	case 0x54:
+		return a < b
+	case 0x55:
+		return sa > sb
+	case 0x56:
+		return a > b
+	case 0x57:
+		return sa <= sb
+	case 0x58:
+		return a <= b
+	case 0x59:
+		return sa >= sb
+	default:
+		return a >= b
+	}
+}
+
+// compareFloat executes the float comparisons, 0x5b to 0x66.
+func (in *wasmInstance) compareFloat(op uint16) {
+	var a, b float64
+	if op <= 0x60 {
+		y, x := in.popF32(), in.popF32()
+		a, b = float64(x), float64(y)
+		op += 0x61 - 0x5b
+	} else {
+		b, a = in.popF64(), in.popF64()
+	}
+	switch op {
+	case 0x61:
+		in.pushBool(a == b)
+	case 0x62:
+		in.pushBool(a != b)
+	case 0x63:
+		in.pushBool(a < b)
+	case 0x64:
+		in.pushBool(a > b)
+	case 0x65:
+		in.pushBool(a <= b)
+	default:
+		in.pushBool(a >= b)
+	}
+}
+
+// int32Op executes the i32 arithmetic, 0x67 to 0x78.
+func (in *wasmInstance) int32Op(op uint16) {
+	switch op {
+	case 0x67:
+		in.push(uint64(bits.LeadingZeros32(in.pop32())))
+		return
+	case 0x68:
+		in.push(uint64(bits.TrailingZeros32(in.pop32())))
+		return
+	case 0x69:
+		in.push(uint64(bits.OnesCount32(in.pop32())))
+		return
+	}
+	b, a := in.pop32(), in.pop32()
+	var r uint32
+	switch op {
+	case 0x6a:
+		r = a + b
+	case 0x6b:
+		r = a - b
+	case 0x6c:
+		r = a * b
+	case 0x6d:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			trap("integer overflow")
+		}
+		r = uint32(int32(a) / int32(b))
+	case 0x6e:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		r = a / b
+	case 0x6f:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(b) == -1 {
+			r = 0
+		} else {
+			r = uint32(int32(a) % int32(b))
+		}
+	case 0x70:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		r = a % b
+	case 0x71:
+		r = a & b
+	case 0x72:
+		r = a | b
+	case 0x73:
+		r = a ^ b
+	case 0x74:
+		r = a << (b & 31)
+	case 0x75:
+		r = uint32(int32(a) >> (b & 31))
+	case 0x76:
+		r = a >> (b & 31)
+	case 0x77:
+		r = bits.RotateLeft32(a, int(b&31))
+	case 0x78:
+		r = bits.RotateLeft32(a, -int(b&31))
+	}
+	in.push(uint64(r))
+}
+
+// int64Op executes the i64 arithmetic, 0x79 to 0x8a.
+func (in *wasmInstance) int64Op(op uint16) {
+	switch op {
+	case 0x79:
+		in.push(uint64(bits.LeadingZeros64(in.pop())))
+		return
+	case 0x7a:
+		in.push(uint64(bits.TrailingZeros64(in.pop())))
+		return
+	case 0x7b:
+		in.push(uint64(bits.OnesCount64(in.pop())))
+		return
+	}
+	b, a := in.pop(), in.pop()
+	var r uint64
+	switch op {
+	case 0x7c:
+		r = a + b
+	case 0x7d:
+		r = a - b
+	case 0x7e:
+		r = a * b
+	case 0x7f:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			trap("integer overflow")
+		}
+		r = uint64(int64(a) / int64(b))
+	case 0x80:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		r = a / b
+	case 0x81:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(b) == -1 {
+			r = 0
+		} else {
+			r = uint64(int64(a) % int64(b))
+		}
+	case 0x82:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		r = a % b
+	case 0x83:
+		r = a & b
+	case 0x84:
+		r = a | b
+	case 0x85:
+		r = a ^ b
+	case 0x86:
+		r = a << (b & 63)
+	case 0x87:
+		r = uint64(int64(a) >> (b & 63))
+	case 0x88:
+		r = a >> (b & 63)
+	case 0x89:
+		r = bits.RotateLeft64(a, int(b&63))
+	case 0x8a:
+		r = bits.RotateLeft64(a, -int(b&63))
+	}
+	in.push(r)
+}
+
+// floatOp executes the float arithmetic, 0x8b to 0xa6. The sign
+// operations work on the bits, so that they keep NaN payloads.
+func (in *wasmInstance) floatOp(op uint16) {
+	if op <= 0x98 {
+		switch op {
+		case 0x8b:
+			in.push(in.pop() &^ (1 << 31))
+		case 0x8c:
+			in.push(in.pop() ^ (1 << 31))
+		case 0x98:
+			b, a := in.pop(), in.pop()
+			in.push(a&^(1<<31) | b&(1<<31))
+		default:
+			if op <= 0x91 {
+				in.pushF32(float32(unaryFloat(op-0x8d+0x9b, float64(in.popF32()))))
+				return
+			}
+			y, x := in.popF32(), in.popF32()
+			switch op {
+			case 0x92:
+				in.pushF32(x + y)
+			case 0x93:
+				in.pushF32(x - y)
+			case 0x94:
+				in.pushF32(x * y)
+			case 0x95:
+				in.pushF32(x / y)
+			case 0x96:
+				in.pushF32(float32(wasmMin(float64(x), float64(y))))
+			case 0x97:
+				in.pushF32(float32(wasmMax(float64(x), float64(y))))
+			}
+		}
+		return
+	}
+	switch op {
+	case 0x99:
+		in.push(in.pop() &^ (1 << 63))
+	case 0x9a:
+		in.push(in.pop() ^ (1 << 63))
+	case 0xa6:
+		b, a := in.pop(), in.pop()
+		in.push(a&^(1<<63) | b&(1<<63))
+	default:
+		if op <= 0x9f {
+			in.pushF64(unaryFloat(op, in.popF64()))
+			return
+		}
+		y, x := in.popF64(), in.popF64()
+		switch op {
+		case 0xa0:
+			in.pushF64(x + y)
+		case 0xa1:
+			in.pushF64(x - y)
+		case 0xa2:
+			in.pushF64(x * y)
+		case 0xa3:
+			in.pushF64(x / y)
+		case 0xa4:
+			in.pushF64(wasmMin(x, y))
+		case 0xa5:
+			in.pushF64(wasmMax(x, y))
+		}
+	}
+}
+
+// unaryFloat applies the f64 operation op, 0x9b to 0x9f. They are exact,
+// so they serve f32 as well, and sqrt rounds correctly when narrowed.
+func unaryFloat(op uint16, x float64) float64 {
+	switch op {
+	case 0x9b:
+		return math.Ceil(x)
+	case 0x9c:
+		return math.Floor(x)
+	case 0x9d:
+		return math.Trunc(x)
+	case 0x9e:
+		return math.RoundToEven(x)
+	default:
+		return math.Sqrt(x)
+	}
+}
+
+// wasmMin and wasmMax are min and max propagating NaN, with -0 below +0.
+func wasmMin(x, y float64) float64 {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return math.NaN()
+	}
+	return math.Min(x, y)
+}
+
+func wasmMax(x, y float64) float64 {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return math.NaN()
+	}
+	return math.Max(x, y)
+}
+
+// convert executes the conversions, 0xa7 to 0xc4 and the saturating
+// truncations 0xfc00 to 0xfc07.
+func (in *wasmInstance) convert(op uint16) {
+	switch op {
+	case 0xa7: // i32.wrap_i64
+		in.push(uint64(in.pop32()))
+	case 0xa8:
+		in.push(uint64(uint32(int32(truncate(float64(in.popF32()), -1<<31, 1<<31)))))
+	case 0xa9:
+		in.push(uint64(uint32(truncate(float64(in.popF32()), 0, 1<<32))))
+	case 0xaa:
+		in.push(uint64(uint32(int32(truncate(in.popF64(), -1<<31, 1<<31)))))
+	case 0xab:
+		in.push(uint64(uint32(truncate(in.popF64(), 0, 1<<32))))
+	case 0xac:
+		in.push(uint64(int64(int32(in.pop32()))))
+	case 0xad:
+		in.push(uint64(in.pop32()))
+	case 0xae:
+		in.push(uint64(int64(truncate(float64(in.popF32()), -1<<63, 1<<63))))
+	case 0xaf:
+		in.push(truncateUint64(float64(in.popF32())))
+	case 0xb0:
+		in.push(uint64(int64(truncate(in.popF64(), -1<<63, 1<<63))))
+	case 0xb1:
+		in.push(truncateUint64(in.popF64()))
+	case 0xb2:
+		in.pushF32(float32(int32(in.pop32())))
+	case 0xb3:
+		in.pushF32(float32(in.pop32()))
+	case 0xb4:
+		in.pushF32(float32(int64(in.pop())))
+	case 0xb5:
+		in.pushF32(float32(in.pop()))
+	case 0xb6:
+		in.pushF32(float32(in.popF64()))
+	case 0xb7:
+		in.pushF64(float64(int32(in.pop32())))
+	case 0xb8:
+		in.pushF64(float64(in.pop32()))
+	case 0xb9:
+		in.pushF64(float64(int64(in.pop())))
+	case 0xba:
+		in.pushF64(float64(in.pop()))
+	case 0xbb:
+		in.pushF64(float64(in.popF32()))
+	case 0xbc, 0xbd, 0xbe, 0xbf: // reinterpretations leave the bits alone
+	case 0xc0:
+		in.push(uint64(uint32(int32(int8(in.pop())))))
+	case 0xc1:
+		in.push(uint64(uint32(int32(int16(in.pop())))))
+	case 0xc2:
+		in.push(uint64(int64(int8(in.pop()))))
+	case 0xc3:
+		in.push(uint64(int64(int16(in.pop()))))
+	case 0xc4:
+		in.push(uint64(int64(int32(in.pop()))))
+	case 0xfc00:
+		in.push(uint64(uint32(int32(saturate(float64(in.popF32()), math.MinInt32, math.MaxInt32)))))
+	case 0xfc01:
+		in.push(uint64(uint32(saturate(float64(in.popF32()), 0, math.MaxUint32))))
+	case 0xfc02:
+		in.push(uint64(uint32(int32(saturate(in.popF64(), math.MinInt32, math.MaxInt32)))))
+	case 0xfc03:
+		in.push(uint64(uint32(saturate(in.popF64(), 0, math.MaxUint32))))
+	case 0xfc04:
+		in.push(uint64(saturateInt64(float64(in.popF32()))))
+	case 0xfc05:
+		in.push(saturateUint64(float64(in.popF32())))
+	case 0xfc06:
+		in.push(uint64(saturateInt64(in.popF64())))
+	case 0xfc07:
+		in.push(saturateUint64(in.popF64()))
+	default:
+		trap("invalid module: unknown instruction 0x%x", op)
+	}
+}
+
+// truncate truncates x toward zero, trapping unless the result is at
+// least lo and below hi.
+func truncate(x, lo, hi float64) float64 {
+	if math.IsNaN(x) {
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(x)
+	if t < lo || t >= hi {
+		trap("integer overflow")
+	}
+	return t
+}
+
+// truncateUint64 truncates x to a uint64, trapping when out of range.
+func truncateUint64(x float64) uint64 {
+	t := truncate(x, 0, 1<<64)
+	if t >= 1<<63 {
+		return uint64(t-(1<<63)) | 1<<63
+	}
+	return uint64(t)
+}
+
+// saturate truncates x, clamping it to lo and hi, with NaN becoming 0.
+func saturate(x, lo, hi float64) float64 {
+	switch {
+	case math.IsNaN(x):
+		return 0
+	case x <= lo:
+		return lo
+	case x >= hi:
+		return hi
+	}
+	return math.Trunc(x)
+}
+
+// saturateInt64 and saturateUint64 are saturate for the 64-bit integers,
+// whose limits are not all floats.
+func saturateInt64(x float64) int64 {
+	switch {
+	case math.IsNaN(x):
+		return 0
+	case x <= -1<<63:
+		return math.MinInt64
+	case x >= 1<<63:
+		return math.MaxInt64
+	}
+	return int64(x)
+}
+
+func saturateUint64(x float64) uint64 {
+	switch {
+	case math.IsNaN(x) || x <= 0:
+		return 0
+	case x >= 1<<64:
+		return math.MaxUint64
+	case x >= 1<<63:
+		return uint64(x-(1<<63)) | 1<<63
+	}
+	return uint64(x)
+}
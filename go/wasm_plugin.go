@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// WebAssembly plugins are .wasm modules in the plugins directory. Unlike Go
+// plugins they load in every build, may be written in any language that
+// compiles to wasm32, and are sandboxed: they import nothing, so they can
+// only compute on the memory the host gives them. Each module exports
+//
+//	memory                                   the linear memory
+//	mcp_alloc(size i32) i32                  reserves size bytes for the host to write
+//	mcp_tools() i64                          returns the tool definitions
+//	mcp_call(name, nameLen, args, argsLen i32) i64  runs a tool
+//
+// Both mcp_tools and mcp_call return a pointer in the high 32 bits and a
+// length in the low 32 bits of their result, locating JSON in memory: an
+// array of definitions with the fields of tools/list for mcp_tools, and a
+// result with "content" and "isError" as in tools/call for mcp_call. The
+// host writes the tool name and its arguments, as JSON, to memory from
+// mcp_alloc. Every call runs in a fresh instance of the module, so calls
+// share no state and a trap cannot corrupt later ones. An instance gets at
+// most wasmMaxPages of memory and traps after wasmMaxFuel instructions, so a
+// hostile module cannot hold the host even when no deadline is set.
+
+// wasmPlugin is a decoded plugin module.
+type wasmPlugin struct {
+	path string
+	mod  *wasmModule
+	fuel int64 // instructions a run may take
+}
+
+// wasmPluginExports are the functions a plugin must export, with their
+// signatures.
+var wasmPluginExports = map[string]wasmFuncType{
+	"mcp_alloc": {params: []byte{wasmI32}, results: []byte{wasmI32}},
+	"mcp_tools": {results: []byte{wasmI64}},
+	"mcp_call":  {params: []byte{wasmI32, wasmI32, wasmI32, wasmI32}, results: []byte{wasmI64}},
+}
+
+// wasmLoadTimeout bounds a plugin's mcp_tools call.
+const wasmLoadTimeout = 10 * time.Second
+
+// wasmToolDef is a tool as a plugin defines it.
+type wasmToolDef struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	Annotations *ToolAnnotations       `json:"annotations"`
+}
+
+// loadWASMPlugins decodes every .wasm file in dir and returns the tools
+// they provide.
+func loadWASMPlugins(dir string) ([]MCPTool, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.wasm"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var loaded []MCPTool
+	for _, path := range paths {
+		tools, err := loadWASMPlugin(path)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s: %w", path, err)
+		}
+		loaded = append(loaded, tools...)
+	}
+	return loaded, nil
+}
+
+// loadWASMPlugin decodes the module at path and asks it for its tools.
+func loadWASMPlugin(path string) ([]MCPTool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mod, err := decodeWASM(data)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := mod.exports["memory"]; !ok || e.kind != wasmExportMemory {
+		return nil, errors.New("the module does not export its memory")
+	}
+	for _, name := range sortedKeys(wasmPluginExports) {
+		e, ok := mod.exports[name]
+		if !ok || e.kind != wasmExportFunc {
+			return nil, fmt.Errorf("the module does not export %s", name)
+		}
+		if !mod.funcs[e.index].typ.equal(wasmPluginExports[name]) {
+			return nil, fmt.Errorf("%s has the wrong signature", name)
+		}
+	}
+
+	p := &wasmPlugin{path: path, mod: mod, fuel: wasmMaxFuel}
+	ctx, cancel := context.WithTimeout(context.Background(), wasmLoadTimeout)
+	defer cancel()
+	out, err := p.run(ctx, "mcp_tools")
+	if err != nil {
+		return nil, err
+	}
+	var defs []wasmToolDef
+	if err := json.Unmarshal(out, &defs); err != nil {
+		return nil, fmt.Errorf("mcp_tools: %w", err)
+	}
+	var tools []MCPTool
+	for _, def := range defs {
+		if def.Name == "" {
+			return nil, errors.New("mcp_tools: a tool without a name")
+		}
+		if def.InputSchema == nil {
+			def.InputSchema = map[string]interface{}{"type": "object"}
+		}
+		tools = append(tools, &wasmTool{plugin: p, def: def})
+	}
+	return tools, nil
+}
+
+// run instantiates the module and calls function fn, passing each input
+// as a pointer and length in memory from mcp_alloc. It returns the bytes
+// the result locates.
+func (p *wasmPlugin) run(ctx context.Context, fn string, inputs ...[]byte) ([]byte, error) {
+	in, err := p.mod.instantiate(ctx, p.fuel)
+	if err != nil {
+		return nil, err
+	}
+	var args []uint64
+	for _, input := range inputs {
+		results, err := in.call(ctx, "mcp_alloc", uint64(len(input)))
+		if err != nil {
+			return nil, err
+		}
+		ptr := uint64(uint32(results[0]))
+		if ptr+uint64(len(input)) > uint64(len(in.memory)) {
+			return nil, errors.New("mcp_alloc returned memory out of bounds")
+		}
+		copy(in.memory[ptr:], input)
+		args = append(args, ptr, uint64(len(input)))
+	}
+	results, err := in.call(ctx, fn, args...)
+	if err != nil {
+		return nil, err
+	}
+	ptr, n := results[0]>>32, results[0]&0xffffffff
+	if ptr+n > uint64(len(in.memory)) {
+		return nil, fmt.Errorf("%s returned memory out of bounds", fn)
+	}
+	return in.memory[ptr : ptr+n], nil
+}
+
+// wasmTool is a tool of a WebAssembly plugin.
+type wasmTool struct {
+	plugin *wasmPlugin
+	def    wasmToolDef
+}
+
+// Name returns the name the plugin gives the tool.
+func (t *wasmTool) Name() string {
+	return t.def.Name
+}
+
+// Description returns the plugin's description of the tool.
+func (t *wasmTool) Description() string {
+	return t.def.Description
+}
+
+// InputSchema returns the plugin's schema of the tool's arguments.
+func (t *wasmTool) InputSchema() map[string]interface{} {
+	return t.def.InputSchema
+}
+
+// Annotations returns the plugin's annotations of the tool.
+func (t *wasmTool) Annotations() ToolAnnotations {
+	if t.def.Annotations == nil {
+		return ToolAnnotations{}
+	}
+	return *t.def.Annotations
+}
+
+// Execute runs the tool without a deadline of its own.
+func (t *wasmTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext runs the tool in a fresh instance of its module, which
+// stops when ctx is done. Traps, and results the plugin flags as errors,
+// fail the call with a result flagged as an error.
+func (t *wasmTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return nil, err
+	}
+	out, err := t.plugin.run(ctx, "mcp_call", []byte(t.def.Name), payload)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	var fault *wasmTrap
+	if errors.As(err, &fault) {
+		return nil, toolFailure("%s: %v", t.def.Name, fault)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", t.def.Name, err)
+	}
+	var result struct {
+		Content []ToolContent `json:"content"`
+		IsError bool          `json:"isError"`
+	}
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("%s: invalid result: %w", t.def.Name, err)
+	}
+	if result.IsError {
+		return nil, &toolResultError{content: result.Content}
+	}
+	return result.Content, nil
+}
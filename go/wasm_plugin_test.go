@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testSLEB encodes a signed LEB128 integer.
+func testSLEB(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// Locations of the strings of testWASMPlugin in its memory.
+const (
+	testPluginPrefix = `{"content":[{"type":"text","text":"`
+	testPluginSuffix = `"}]}`
+	testPluginRefuse = `{"content":[{"type":"text","text":"refused"}],"isError":true}`
+	testPluginTools  = `[{"name":"size","description":"Counts the bytes of its arguments","annotations":{"readOnlyHint":true}},` +
+		`{"name":"crash"},{"name":"refuse"}]`
+)
+
+// testWASMPlugin returns a plugin module with three tools: size, which
+// returns the length of its JSON arguments, crash, which traps, and
+// refuse, which returns an error result. Its mcp_call tells them apart by
+// the length of their names. In the text format:
+//
+//	(module
+//	  (memory (export "memory") 1)
+//	  (global $next (mut i32) (i32.const 4096))
+//	  (data (i32.const 0) "<testPluginTools>")
+//	  (data (i32.const 512) "<testPluginPrefix>")
+//	  (data (i32.const 600) "<testPluginSuffix>")
+//	  (data (i32.const 700) "<testPluginRefuse>")
+//	  (func (export "mcp_alloc") (param i32) (result i32)
+//	    (global.get $next)
+//	    (global.set $next (i32.add (global.get $next) (local.get 0))))
+//	  (func (export "mcp_tools") (result i64) (i64.const <len(testPluginTools)>))
+//	  (func (export "mcp_call") (param $name i32) (param $nameLen i32) (param $args i32) (param $argsLen i32) (result i64)
+//	    (local $p i32) (local $n i32)
+//	    (if (i32.eq (local.get $nameLen) (i32.const 5)) (then unreachable))
+//	    (if (i32.eq (local.get $nameLen) (i32.const 6))
+//	      (then (return (i64.const <700<<32 | len(testPluginRefuse)>))))
+//	    (local.set $p (i32.const 2100))
+//	    (local.set $n (local.get $argsLen))
+//	    (loop ;; the decimal digits of n, backwards from 2100
+//	      (i32.store8 (local.tee $p (i32.sub (local.get $p) (i32.const 1)))
+//	        (i32.add (i32.rem_u (local.get $n) (i32.const 10)) (i32.const 48)))
+//	      (br_if 0 (local.tee $n (i32.div_u (local.get $n) (i32.const 10)))))
+//	    (memory.copy (i32.const 2100) (i32.const 600) (i32.const <len(testPluginSuffix)>))
+//	    (memory.copy (local.tee $p (i32.sub (local.get $p) (i32.const <len(testPluginPrefix)>)))
+//	      (i32.const 512) (i32.const <len(testPluginPrefix)>))
+//	    (i64.or (i64.shl (i64.extend_i32_u (local.get $p)) (i64.const 32))
+//	      (i64.extend_i32_u (i32.sub (i32.const <2100+len(testPluginSuffix)>) (local.get $p))))))
+func testWASMPlugin() []byte {
+	i32, i64 := wasmI32, wasmI64
+	i32Const := func(v int64) []byte { return append([]byte{0x41}, testSLEB(v)...) }
+	i64Const := func(v int64) []byte { return append([]byte{0x42}, testSLEB(v)...) }
+	code := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, p := range parts {
+			b = append(b, p...)
+		}
+		return b
+	}
+	memoryCopy := []byte{0xfc, 10, 0, 0}
+
+	call := code(
+		[]byte{0x20, 1}, i32Const(5), []byte{0x46, 0x04, 0x40, 0x00, 0x0b},
+		[]byte{0x20, 1}, i32Const(6), []byte{0x46, 0x04, 0x40},
+		i64Const(700<<32|int64(len(testPluginRefuse))), []byte{0x0f, 0x0b},
+		i32Const(2100), []byte{0x21, 4},
+		[]byte{0x20, 3, 0x21, 5},
+		[]byte{0x03, 0x40},
+		[]byte{0x20, 4}, i32Const(1), []byte{0x6b, 0x22, 4},
+		[]byte{0x20, 5}, i32Const(10), []byte{0x70}, i32Const(48), []byte{0x6a},
+		[]byte{0x3a, 0, 0},
+		[]byte{0x20, 5}, i32Const(10), []byte{0x6e, 0x22, 5},
+		[]byte{0x0d, 0, 0x0b},
+		i32Const(2100), i32Const(600), i32Const(int64(len(testPluginSuffix))), memoryCopy,
+		[]byte{0x20, 4}, i32Const(int64(len(testPluginPrefix))), []byte{0x6b, 0x22, 4},
+		i32Const(512), i32Const(int64(len(testPluginPrefix))), memoryCopy,
+		[]byte{0x20, 4, 0xad}, i64Const(32), []byte{0x86},
+		i32Const(2100+int64(len(testPluginSuffix))), []byte{0x20, 4, 0x6b, 0xad, 0x84},
+		[]byte{0x0b},
+	)
+	data := func(offset int64, s string) []byte {
+		return code([]byte{0}, i32Const(offset), []byte{0x0b}, binary.AppendUvarint(nil, uint64(len(s))), []byte(s))
+	}
+	return testWASMModule(
+		testWASMSection(1, testWASMVector(
+			testFuncType([]byte{i32}, []byte{i32}),
+			testFuncType(nil, []byte{i64}),
+			testFuncType([]byte{i32, i32, i32, i32}, []byte{i64}),
+		)),
+		testWASMSection(3, testWASMVector([]byte{0}, []byte{1}, []byte{2})),
+		testWASMSection(5, testWASMVector([]byte{0, 1})),
+		testWASMSection(6, testWASMVector(code([]byte{i32, 1}, i32Const(4096), []byte{0x0b}))),
+		testWASMSection(7, testWASMVector(
+			testWASMExport("memory", wasmExportMemory, 0),
+			testWASMExport("mcp_alloc", wasmExportFunc, 0),
+			testWASMExport("mcp_tools", wasmExportFunc, 1),
+			testWASMExport("mcp_call", wasmExportFunc, 2),
+		)),
+		testWASMSection(10, testWASMVector(
+			testWASMBody(nil, 0x23, 0, 0x23, 0, 0x20, 0, 0x6a, 0x24, 0, 0x0b),
+			testWASMBody(nil, append(i64Const(int64(len(testPluginTools))), 0x0b)...),
+			testWASMBody([]byte{2, i32}, call...),
+		)),
+		testWASMSection(11, testWASMVector(
+			data(0, testPluginTools),
+			data(512, testPluginPrefix),
+			data(600, testPluginSuffix),
+			data(700, testPluginRefuse),
+		)),
+	)
+}
+
+// Test loading and calling the tools of a WebAssembly plugin
+func TestWASMPlugin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "size.wasm"), testWASMPlugin(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadWASMPlugins(dir)
+	if err != nil {
+		t.Fatalf("loadWASMPlugins error: %v", err)
+	}
+	if len(loaded) != 3 || loaded[0].Name() != "size" || loaded[1].Name() != "crash" {
+		t.Fatalf("expected the plugin's tools, got %v", loaded)
+	}
+	size := loaded[0].(*wasmTool)
+	if !size.Annotations().ReadOnlyHint || size.InputSchema()["type"] != "object" {
+		t.Errorf("unexpected definition %+v", size.def)
+	}
+
+	// Each call gets a fresh instance, so the allocator starts over.
+	for i := 0; i < 2; i++ {
+		content, err := size.Execute(map[string]interface{}{"text": "hello"})
+		if err != nil || len(content) != 1 || content[0].Text != "16" {
+			t.Errorf("expected the length of {\"text\":\"hello\"}, got %v, %v", content, err)
+		}
+	}
+
+	var failure *toolResultError
+	if _, err := loaded[1].Execute(nil); !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "unreachable") {
+		t.Errorf("expected the trap as an error result, got %v", err)
+	}
+	if _, err := loaded[2].Execute(nil); !errors.As(err, &failure) || failure.content[0].Text != "refused" {
+		t.Errorf("expected the plugin's error result, got %v", err)
+	}
+}
+
+// Test that a call is stopped by its context
+func TestWASMPluginCancel(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "size.wasm"), testWASMPlugin(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadWASMPlugins(dir)
+	if err != nil {
+		t.Fatalf("loadWASMPlugins error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := loaded[0].(ContextTool).ExecuteContext(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline, got %v", err)
+	}
+}
+
+// Test that modules that are not plugins are reported
+func TestWASMPluginErrors(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"garbage":    []byte("not wasm"),
+		"no exports": testWASMMath(),
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "bad.wasm"), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadWASMPlugins(dir); err == nil || !strings.Contains(err.Error(), "bad.wasm") {
+			t.Errorf("expected the %s plugin to be reported, got %v", name, err)
+		}
+	}
+}
+
+// testWASMHostile returns a plugin module defining one tool, evil, whose
+// mcp_alloc and mcp_call have the given bodies. Its memory declares no
+// maximum.
+func testWASMHostile(alloc, call []byte) []byte {
+	tools := `[{"name":"evil"}]`
+	return testWASMModule(
+		testWASMSection(1, testWASMVector(
+			testFuncType([]byte{wasmI32}, []byte{wasmI32}),
+			testFuncType(nil, []byte{wasmI64}),
+			testFuncType([]byte{wasmI32, wasmI32, wasmI32, wasmI32}, []byte{wasmI64}),
+		)),
+		testWASMSection(3, testWASMVector([]byte{0}, []byte{1}, []byte{2})),
+		testWASMSection(5, testWASMVector([]byte{0, 1})),
+		testWASMSection(7, testWASMVector(
+			testWASMExport("memory", wasmExportMemory, 0),
+			testWASMExport("mcp_alloc", wasmExportFunc, 0),
+			testWASMExport("mcp_tools", wasmExportFunc, 1),
+			testWASMExport("mcp_call", wasmExportFunc, 2),
+		)),
+		testWASMSection(10, testWASMVector(
+			testWASMBody(nil, alloc...),
+			testWASMBody(nil, append(append([]byte{0x42}, testSLEB(int64(len(tools)))...), 0x0b)...),
+			testWASMBody(nil, call...),
+		)),
+		testWASMSection(11, testWASMVector(
+			append([]byte{0, 0x41, 0, 0x0b, byte(len(tools))}, tools...),
+		)),
+	)
+}
+
+// Test that plugins trying to hold or escape the host are stopped without
+// a deadline
+func TestWASMPluginHostile(t *testing.T) {
+	alloc := []byte{0x41, 0x80, 0x08, 0x0b} // (i32.const 1024)
+	result := append([]byte{0x42}, testSLEB(16)...)
+	for _, tc := range []struct {
+		name   string
+		alloc  []byte
+		call   []byte
+		failed string // the text of the error result, or
+		err    string // the error
+	}{
+		// (loop (br 0))
+		{name: "spin", alloc: alloc, call: append([]byte{0x03, 0x40, 0x0c, 0, 0x0b}, append(result, 0x0b)...),
+			failed: "evil: wasm trap: out of fuel"},
+		// (loop (br_if 0 (i32.ne (memory.grow (i32.const 1)) (i32.const -1)))) unreachable
+		{name: "hog", alloc: alloc, call: []byte{0x03, 0x40, 0x41, 1, 0x40, 0, 0x41, 0x7f, 0x47, 0x0d, 0, 0x0b, 0x00, 0x0b},
+			failed: "evil: wasm trap: unreachable"},
+		// (call $mcp_call (local.get 0) (local.get 1) (local.get 2) (local.get 3))
+		{name: "recurse", alloc: alloc, call: []byte{0x20, 0, 0x20, 1, 0x20, 2, 0x20, 3, 0x10, 2, 0x0b},
+			failed: "evil: wasm trap: call stack exhausted"},
+		// (i64.const 0x7fff0000<<32 | 16)
+		{name: "wild result", alloc: alloc, call: append(append([]byte{0x42}, testSLEB(0x7fff0000<<32|16)...), 0x0b),
+			err: "mcp_call returned memory out of bounds"},
+		// (i32.const -16)
+		{name: "wild alloc", alloc: []byte{0x41, 0x70, 0x0b}, call: append(result, 0x0b),
+			err: "mcp_alloc returned memory out of bounds"},
+	} {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "evil.wasm"), testWASMHostile(tc.alloc, tc.call), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := loadWASMPlugins(dir)
+		if err != nil {
+			t.Fatalf("%s: loadWASMPlugins error: %v", tc.name, err)
+		}
+		tool := loaded[0].(*wasmTool)
+		tool.plugin.fuel = 1 << 20
+		_, err = tool.Execute(nil)
+		var failure *toolResultError
+		switch {
+		case tc.failed != "":
+			if !errors.As(err, &failure) || failure.content[0].Text != tc.failed {
+				t.Errorf("%s: expected the result %q, got %v", tc.name, tc.failed, err)
+			}
+		case err == nil || errors.As(err, &failure) || !strings.Contains(err.Error(), tc.err):
+			t.Errorf("%s: expected the error %q, got %v", tc.name, tc.err, err)
+		}
+	}
+}
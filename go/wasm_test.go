@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWASMVector encodes a vector of already encoded items.
+func testWASMVector(items ...[]byte) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+// testWASMSection encodes a section.
+func testWASMSection(id byte, content []byte) []byte {
+	return append(binary.AppendUvarint([]byte{id}, uint64(len(content))), content...)
+}
+
+// testWASMModule encodes a module of the given sections.
+func testWASMModule(sections ...[]byte) []byte {
+	b := []byte("\x00asm\x01\x00\x00\x00")
+	for _, s := range sections {
+		b = append(b, s...)
+	}
+	return b
+}
+
+// testWASMBody encodes a function body with the given locals, each a count
+// and a type, and code, which must include the final end.
+func testWASMBody(locals []byte, code ...byte) []byte {
+	body := append(binary.AppendUvarint(nil, uint64(len(locals)/2)), locals...)
+	body = append(body, code...)
+	return append(binary.AppendUvarint(nil, uint64(len(body))), body...)
+}
+
+// testWASMExport encodes an export.
+func testWASMExport(name string, kind, index byte) []byte {
+	return append(append([]byte{byte(len(name))}, name...), kind, index)
+}
+
+// testFuncType encodes a function type.
+func testFuncType(params, results []byte) []byte {
+	b := append([]byte{0x60, byte(len(params))}, params...)
+	return append(append(b, byte(len(results))), results...)
+}
+
+// testWASMMath returns a module exporting functions that exercise the
+// interpreter. It is equivalent to this text format:
+//
+//	(module
+//	  (type $i32 (func (param i32) (result i32)))
+//	  (memory 1 2)
+//	  (table 2 funcref)
+//	  (elem (i32.const 0) $double $div)
+//	  (global $g (mut i32) (i32.const 42))
+//	  (func $fac (export "fac") (param i64) (result i64)
+//	    (if (result i64) (i64.eqz (local.get 0)) (then (i64.const 1))
+//	      (else (i64.mul (local.get 0) (call $fac (i64.sub (local.get 0) (i64.const 1)))))))
+//	  (func $sum (export "sum") (param i32) (result i32) (local i32)
+//	    (block (loop
+//	      (br_if 1 (i32.eqz (local.get 0)))
+//	      (local.set 1 (i32.add (local.get 1) (local.get 0)))
+//	      (local.set 0 (i32.sub (local.get 0) (i32.const 1)))
+//	      (br 0)))
+//	    (local.get 1))
+//	  (func $div (export "div") (param i32 i32) (result i32)
+//	    (i32.div_s (local.get 0) (local.get 1)))
+//	  (func $poke (export "poke") (param i32) (result i32)
+//	    (i32.store (local.get 0) (i32.const 0x01020304))
+//	    (i32.load8_u (local.get 0)))
+//	  (func $indirect (export "indirect") (param i32) (result i32)
+//	    (call_indirect (type $i32) (i32.const 5) (local.get 0)))
+//	  (func $double (param i32) (result i32)
+//	    (i32.mul (local.get 0) (i32.const 2)))
+//	  (func $spin (export "spin") (result i32)
+//	    (loop (br 0)) (i32.const 0))
+//	  (func $pick (export "pick") (param i32) (result i32)
+//	    (block (block (block (br_table 0 1 2 (local.get 0)))
+//	      (return (i32.const 10))) (return (i32.const 20)))
+//	    (i32.const 30))
+//	  (func $recurse (export "recurse") (result i32) (call $recurse))
+//	  (func $counter (export "counter") (result i32)
+//	    (global.set $g (i32.add (global.get $g) (i32.const 1)))
+//	    (global.get $g))
+//	  (func $grow (export "grow") (param i32) (result i32)
+//	    (memory.grow (local.get 0)))
+//	  (func $toint (export "toint") (param f64) (result i32)
+//	    (i32.trunc_f64_s (local.get 0))))
+func testWASMMath() []byte {
+	i32, i64, f64 := wasmI32, wasmI64, wasmF64
+	types := testWASMVector(
+		testFuncType([]byte{i32}, []byte{i32}),      // 0
+		testFuncType([]byte{i64}, []byte{i64}),      // 1
+		testFuncType([]byte{i32, i32}, []byte{i32}), // 2
+		testFuncType(nil, []byte{i32}),              // 3
+		testFuncType([]byte{f64}, []byte{i32}),      // 4
+	)
+	funcs := []byte{12, 1, 0, 2, 0, 0, 0, 3, 0, 3, 3, 0, 4}
+	bodies := testWASMVector(
+		// fac
+		testWASMBody(nil, 0x20, 0, 0x50, 0x04, i64, 0x42, 1, 0x05,
+			0x20, 0, 0x20, 0, 0x42, 1, 0x7d, 0x10, 0, 0x7e, 0x0b, 0x0b),
+		// sum
+		testWASMBody([]byte{1, i32}, 0x02, 0x40, 0x03, 0x40,
+			0x20, 0, 0x45, 0x0d, 1,
+			0x20, 1, 0x20, 0, 0x6a, 0x21, 1,
+			0x20, 0, 0x41, 1, 0x6b, 0x21, 0,
+			0x0c, 0, 0x0b, 0x0b, 0x20, 1, 0x0b),
+		// div
+		testWASMBody(nil, 0x20, 0, 0x20, 1, 0x6d, 0x0b),
+		// poke
+		testWASMBody(nil, 0x20, 0, 0x41, 0x84, 0x86, 0x88, 0x08, 0x36, 2, 0,
+			0x20, 0, 0x2d, 0, 0, 0x0b),
+		// indirect
+		testWASMBody(nil, 0x41, 5, 0x20, 0, 0x11, 0, 0, 0x0b),
+		// double
+		testWASMBody(nil, 0x20, 0, 0x41, 2, 0x6c, 0x0b),
+		// spin
+		testWASMBody(nil, 0x03, 0x40, 0x0c, 0, 0x0b, 0x41, 0, 0x0b),
+		// pick
+		testWASMBody(nil, 0x02, 0x40, 0x02, 0x40, 0x02, 0x40, 0x20, 0, 0x0e, 2, 0, 1, 2, 0x0b,
+			0x41, 10, 0x0f, 0x0b, 0x41, 20, 0x0f, 0x0b, 0x41, 30, 0x0b),
+		// recurse
+		testWASMBody(nil, 0x10, 8, 0x0b),
+		// counter
+		testWASMBody(nil, 0x23, 0, 0x41, 1, 0x6a, 0x24, 0, 0x23, 0, 0x0b),
+		// grow
+		testWASMBody(nil, 0x20, 0, 0x40, 0, 0x0b),
+		// toint
+		testWASMBody(nil, 0x20, 0, 0xaa, 0x0b),
+	)
+	exports := testWASMVector(
+		testWASMExport("fac", 0, 0),
+		testWASMExport("sum", 0, 1),
+		testWASMExport("div", 0, 2),
+		testWASMExport("poke", 0, 3),
+		testWASMExport("indirect", 0, 4),
+		testWASMExport("spin", 0, 6),
+		testWASMExport("pick", 0, 7),
+		testWASMExport("recurse", 0, 8),
+		testWASMExport("counter", 0, 9),
+		testWASMExport("grow", 0, 10),
+		testWASMExport("toint", 0, 11),
+	)
+	return testWASMModule(
+		testWASMSection(1, types),
+		testWASMSection(3, funcs),
+		testWASMSection(4, testWASMVector([]byte{0x70, 0, 2})),
+		testWASMSection(5, testWASMVector([]byte{1, 1, 2})),
+		testWASMSection(6, testWASMVector([]byte{i32, 1, 0x41, 42, 0x0b})),
+		testWASMSection(7, exports),
+		testWASMSection(9, testWASMVector([]byte{0, 0x41, 0, 0x0b, 2, 5, 2})),
+		testWASMSection(10, bodies),
+	)
+}
+
+// Test running functions of a module
+func TestWASMExec(t *testing.T) {
+	mod, err := decodeWASM(testWASMMath())
+	if err != nil {
+		t.Fatalf("decodeWASM error: %v", err)
+	}
+	for _, tc := range []struct {
+		fn       string
+		args     []uint64
+		expected uint64
+	}{
+		{"fac", []uint64{20}, 2432902008176640000},
+		{"sum", []uint64{100}, 5050},
+		{"div", []uint64{uint64(uint32(-7 & 0xffffffff)), 2}, uint64(uint32(0xfffffffd))},
+		{"poke", []uint64{100}, 4},
+		{"indirect", []uint64{0}, 10},
+		{"pick", []uint64{0}, 10},
+		{"pick", []uint64{1}, 20},
+		{"pick", []uint64{7}, 30},
+		{"counter", nil, 43},
+		{"grow", []uint64{1}, 1},
+		{"grow", []uint64{5}, 0xffffffff},
+		{"toint", []uint64{0xc00c000000000000}, uint64(uint32(0xfffffffd))}, // -3.5
+	} {
+		in, err := mod.instantiate(context.Background(), wasmMaxFuel)
+		if err != nil {
+			t.Fatalf("instantiate error: %v", err)
+		}
+		results, err := in.call(context.Background(), tc.fn, tc.args...)
+		if err != nil || len(results) != 1 || results[0] != tc.expected {
+			t.Errorf("%s%v = %v, %v, expected %d", tc.fn, tc.args, results, err, tc.expected)
+		}
+	}
+}
+
+// Test that runtime errors trap
+func TestWASMTraps(t *testing.T) {
+	mod, err := decodeWASM(testWASMMath())
+	if err != nil {
+		t.Fatalf("decodeWASM error: %v", err)
+	}
+	for _, tc := range []struct {
+		fn   string
+		args []uint64
+		trap string
+	}{
+		{"div", []uint64{1, 0}, "integer divide by zero"},
+		{"div", []uint64{0x80000000, 0xffffffff}, "integer overflow"},
+		{"poke", []uint64{65534}, "out of bounds memory access"},
+		{"indirect", []uint64{1}, "indirect call type mismatch"},
+		{"indirect", []uint64{2}, "undefined element"},
+		{"recurse", nil, "call stack exhausted"},
+		{"toint", []uint64{0x7ff8000000000000}, "invalid conversion to integer"},
+	} {
+		in, err := mod.instantiate(context.Background(), wasmMaxFuel)
+		if err != nil {
+			t.Fatalf("instantiate error: %v", err)
+		}
+		_, err = in.call(context.Background(), tc.fn, tc.args...)
+		if _, ok := err.(*wasmTrap); !ok || !strings.Contains(err.Error(), tc.trap) {
+			t.Errorf("%s%v error = %v, expected %q", tc.fn, tc.args, err, tc.trap)
+		}
+	}
+}
+
+// Test that a call stops when its context is done
+func TestWASMCancel(t *testing.T) {
+	mod, err := decodeWASM(testWASMMath())
+	if err != nil {
+		t.Fatalf("decodeWASM error: %v", err)
+	}
+	in, err := mod.instantiate(context.Background(), wasmMaxFuel)
+	if err != nil {
+		t.Fatalf("instantiate error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := in.call(ctx, "spin"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to stop the loop, got %v", err)
+	}
+}
+
+// Test that a call traps once it has used up its fuel, with no deadline
+func TestWASMFuel(t *testing.T) {
+	mod, err := decodeWASM(testWASMMath())
+	if err != nil {
+		t.Fatalf("decodeWASM error: %v", err)
+	}
+	in, err := mod.instantiate(context.Background(), 1<<16)
+	if err != nil {
+		t.Fatalf("instantiate error: %v", err)
+	}
+	if _, err := in.call(context.Background(), "spin"); err == nil || err.Error() != "wasm trap: out of fuel" {
+		t.Errorf("expected the loop to run out of fuel, got %v", err)
+	}
+	in, err = mod.instantiate(context.Background(), 1<<16)
+	if err != nil {
+		t.Fatalf("instantiate error: %v", err)
+	}
+	if got, err := in.call(context.Background(), "fac", 10); err != nil || got[0] != 3628800 {
+		t.Errorf("expected a short call to run, got %v, %v", got, err)
+	}
+}
+
+// Test that malformed and unsupported modules are rejected
+func TestWASMDecodeErrors(t *testing.T) {
+	valid := testWASMMath()
+	imports := testWASMModule(testWASMSection(2, testWASMVector(append([]byte("\x03env\x03log"), 0, 0))))
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"not wasm":  []byte("\x7fELF\x01\x00\x00\x00"),
+		"truncated": valid[:len(valid)-3],
+		"imports":   imports,
+		"order":     testWASMModule(testWASMSection(3, testWASMVector()), testWASMSection(1, testWASMVector())),
+		"no code":   testWASMModule(testWASMSection(1, testWASMVector(testFuncType(nil, nil))), testWASMSection(3, testWASMVector([]byte{0}))),
+		"memory":    testWASMModule(testWASMSection(5, testWASMVector(binary.AppendUvarint([]byte{0}, wasmMaxPages+1)))),
+		"table":     testWASMModule(testWASMSection(4, testWASMVector(binary.AppendUvarint([]byte{0x70, 0}, 1<<20+1)))),
+	} {
+		if _, err := decodeWASM(data); err == nil {
+			t.Errorf("expected the %s module to be rejected", name)
+		}
+	}
+	if _, err := decodeWASM(imports); err == nil || !strings.Contains(err.Error(), "env.log") {
+		t.Errorf("expected the import to be named, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// webhookConfig defines a webhook in the "webhooks" section of the config
+// file. The model refers to it by name only, so the URL, which often is
+// the webhook's secret, never passes through the conversation.
+type webhookConfig struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`         // $VAR expands from the environment
+	Kind        string `json:"kind"`        // slack, discord, or generic (default)
+	Description string `json:"description"` // tells the model what the webhook reaches
+}
+
+// validate reports missing or malformed fields.
+func (c *webhookConfig) validate() error {
+	if c.Name == "" {
+		return errors.New("webhook without a name")
+	}
+	if c.URL == "" {
+		return fmt.Errorf("webhook %q: missing url", c.Name)
+	}
+	if !strings.Contains(c.URL, "$") {
+		if u, err := url.Parse(c.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook %q: url must be an http(s) URL", c.Name)
+		}
+	}
+	switch c.Kind {
+	case "", "slack", "discord", "generic":
+	default:
+		return fmt.Errorf("webhook %q: unknown kind %q", c.Name, c.Kind)
+	}
+	return nil
+}
+
+// validateWebhooks validates each webhook and rejects duplicate names.
+func validateWebhooks(hooks []webhookConfig) error {
+	seen := map[string]bool{}
+	for i := range hooks {
+		if err := hooks[i].validate(); err != nil {
+			return err
+		}
+		if seen[hooks[i].Name] {
+			return fmt.Errorf("webhook %q defined twice", hooks[i].Name)
+		}
+		seen[hooks[i].Name] = true
+	}
+	return nil
+}
+
+// webhookTimeout bounds a webhook request unless the call's context ends
+// it sooner.
+const webhookTimeout = 10 * time.Second
+
+// notifyWebhookTool posts messages to the configured webhooks, formatted
+// for the chat service each one belongs to.
+type notifyWebhookTool struct {
+	hooks  []webhookConfig
+	client *http.Client
+}
+
+// newNotifyWebhookTool returns the notify_webhook tool for hooks.
+func newNotifyWebhookTool(hooks []webhookConfig) *notifyWebhookTool {
+	return &notifyWebhookTool{hooks: hooks, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// notifyWebhookArgs are the arguments of the notify_webhook tool.
+type notifyWebhookArgs struct {
+	Webhook string `json:"webhook" description:"Name of the webhook to notify"`
+	Message string `json:"message" description:"The message to post"`
+	Title   string `json:"title,omitempty" description:"A title shown above the message"`
+}
+
+// Name returns the name of the notify_webhook tool.
+func (t *notifyWebhookTool) Name() string {
+	return "notify_webhook"
+}
+
+// Description returns a brief description of the notify_webhook tool,
+// listing the webhooks and what they reach.
+func (t *notifyWebhookTool) Description() string {
+	var b strings.Builder
+	b.WriteString("Posts a message to a configured webhook:")
+	for _, h := range t.hooks {
+		b.WriteString(" " + h.Name)
+		if h.Description != "" {
+			b.WriteString(" (" + h.Description + ")")
+		}
+		b.WriteString(",")
+	}
+	return strings.TrimSuffix(b.String(), ",")
+}
+
+// InputSchema returns the JSON schema for the notify_webhook tool's input
+// parameters, with the webhook names as an enum.
+func (t *notifyWebhookTool) InputSchema() map[string]interface{} {
+	schema := mcp.SchemaFor(notifyWebhookArgs{})
+	names := make([]string, len(t.hooks))
+	for i, h := range t.hooks {
+		names[i] = h.Name
+	}
+	schema["properties"].(map[string]interface{})["webhook"].(map[string]interface{})["enum"] = names
+	return schema
+}
+
+// Annotations marks the notify_webhook tool as reaching outside the
+// server. Posting adds a message without changing others, so it is not
+// destructive.
+func (t *notifyWebhookTool) Annotations() ToolAnnotations {
+	destructive := false
+	return ToolAnnotations{Title: "Notify webhook", DestructiveHint: &destructive}
+}
+
+// Execute posts the message without a deadline of its own.
+func (t *notifyWebhookTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext posts the message to the named webhook. Errors never
+// include the webhook's URL.
+func (t *notifyWebhookTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	a, hook, err := t.parse(args)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(webhookPayload(hook.Kind, a.Title, a.Message))
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, os.ExpandEnv(hook.URL), bytes.NewReader(payload))
+	if err != nil {
+		return nil, toolFailure("%s: invalid webhook URL", hook.Name)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, toolFailure("%s: %v", hook.Name, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			return nil, toolFailure("%s: %s: %s", hook.Name, resp.Status, msg)
+		}
+		return nil, toolFailure("%s: %s", hook.Name, resp.Status)
+	}
+	return []ToolContent{{Type: "text", Text: "Notified " + hook.Name}}, nil
+}
+
+// DryRun tells which webhook a call would notify, without posting to it.
+func (t *notifyWebhookTool) DryRun(args map[string]interface{}) (string, error) {
+	a, hook, err := t.parse(args)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("would post a message of %d characters to %s", len([]rune(a.Message)), hook.Name), nil
+}
+
+// parse decodes and checks the arguments of a call, returning them with
+// the webhook they name.
+func (t *notifyWebhookTool) parse(args map[string]interface{}) (notifyWebhookArgs, *webhookConfig, error) {
+	var a notifyWebhookArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return a, nil, err
+	}
+	var hook *webhookConfig
+	for i := range t.hooks {
+		if t.hooks[i].Name == a.Webhook {
+			hook = &t.hooks[i]
+		}
+	}
+	if hook == nil {
+		return a, nil, fmt.Errorf("invalid value for 'webhook': unknown webhook %q", a.Webhook)
+	}
+	if a.Message == "" {
+		return a, nil, errors.New("invalid value for 'message': the message is empty")
+	}
+	return a, hook, nil
+}
+
+// webhookPayload returns the JSON body that posts a message with an
+// optional title to a webhook of the given kind.
+func webhookPayload(kind, title, message string) map[string]interface{} {
+	switch kind {
+	case "slack":
+		if title != "" {
+			message = "*" + title + "*\n" + message
+		}
+		return map[string]interface{}{"text": message}
+	case "discord":
+		if title != "" {
+			message = "**" + title + "**\n" + message
+		}
+		return map[string]interface{}{"content": message}
+	}
+	payload := map[string]interface{}{"text": message}
+	if title != "" {
+		payload["title"] = title
+	}
+	return payload
+}
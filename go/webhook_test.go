@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test posting messages in the format of each kind of webhook
+func TestNotifyWebhook(t *testing.T) {
+	bodies := make(chan map[string]interface{}, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken/token" {
+			http.Error(w, "invalid_token", http.StatusForbidden)
+			return
+		}
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request %q", data)
+		}
+		bodies <- body
+	}))
+	defer ts.Close()
+	t.Setenv("TEST_WEBHOOK_TOKEN", "token")
+	tool := newNotifyWebhookTool([]webhookConfig{
+		{Name: "ops", URL: ts.URL + "/slack/$TEST_WEBHOOK_TOKEN", Kind: "slack", Description: "the #ops channel"},
+		{Name: "team", URL: ts.URL + "/discord", Kind: "discord"},
+		{Name: "ci", URL: ts.URL + "/generic"},
+		{Name: "broken", URL: ts.URL + "/broken/$TEST_WEBHOOK_TOKEN"},
+	})
+	if !strings.Contains(tool.Description(), "ops (the #ops channel)") {
+		t.Errorf("expected the webhooks in the description, got %q", tool.Description())
+	}
+	enum := tool.InputSchema()["properties"].(map[string]interface{})["webhook"].(map[string]interface{})["enum"]
+	if names, ok := enum.([]string); !ok || strings.Join(names, ",") != "ops,team,ci,broken" {
+		t.Errorf("expected the names as an enum, got %v", enum)
+	}
+
+	for _, tc := range []struct {
+		hook, field, expected string
+	}{
+		{"ops", "text", "*Deploy*\ndone"},
+		{"team", "content", "**Deploy**\ndone"},
+		{"ci", "text", "done"},
+	} {
+		content, err := tool.Execute(map[string]interface{}{"webhook": tc.hook, "title": "Deploy", "message": "done"})
+		if err != nil || content[0].Text != "Notified "+tc.hook {
+			t.Fatalf("%s: unexpected result %v, %v", tc.hook, content, err)
+		}
+		if body := <-bodies; body[tc.field] != tc.expected {
+			t.Errorf("%s: unexpected payload %v", tc.hook, body)
+		}
+	}
+
+	var failure *toolResultError
+	_, err := tool.Execute(map[string]interface{}{"webhook": "broken", "message": "x"})
+	if !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "403 Forbidden: invalid_token") {
+		t.Errorf("expected the refusal as an error result, got %v", err)
+	}
+	if strings.Contains(failure.content[0].Text, "/broken/") {
+		t.Errorf("expected the URL to stay secret, got %q", failure.content[0].Text)
+	}
+	if _, err := tool.Execute(map[string]interface{}{"webhook": ts.URL, "message": "x"}); err == nil {
+		t.Error("expected a raw URL to be refused")
+	}
+}
+
+// Test that an unreachable webhook fails without revealing its URL
+func TestNotifyWebhookUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL + "/secret"
+	ts.Close()
+	tool := newNotifyWebhookTool([]webhookConfig{{Name: "gone", URL: url}})
+	var failure *toolResultError
+	if _, err := tool.Execute(map[string]interface{}{"webhook": "gone", "message": "x"}); !errors.As(err, &failure) || strings.Contains(failure.content[0].Text, "secret") {
+		t.Errorf("expected an error result without the URL, got %v", err)
+	}
+}
+
+// Test the validation of the webhooks section of the config file
+func TestWebhookConfig(t *testing.T) {
+	if err := validateWebhooks([]webhookConfig{{Name: "a", URL: "https://hooks.example.com/x"}, {Name: "b", URL: "$HOOK_URL", Kind: "slack"}}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	for name, hooks := range map[string][]webhookConfig{
+		"no name":   {{URL: "https://hooks.example.com/x"}},
+		"no url":    {{Name: "a"}},
+		"bad url":   {{Name: "a", URL: "file:///etc/passwd"}},
+		"bad kind":  {{Name: "a", URL: "https://hooks.example.com/x", Kind: "teams"}},
+		"duplicate": {{Name: "a", URL: "https://hooks.example.com/x"}, {Name: "a", URL: "https://hooks.example.com/y"}},
+	} {
+		if err := validateWebhooks(hooks); err == nil {
+			t.Errorf("expected the webhooks with %s to be rejected", name)
+		}
+	}
+}
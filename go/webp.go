@@ -0,0 +1,291 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// The standard library reads and writes PNG and JPEG but not WebP, so
+// encodeWebP writes the lossless format, VP8L (RFC 9649), in its simplest
+// form: no transforms, no color cache and no backward references, just
+// every pixel's channels under one prefix code each. The files are larger
+// than an optimizing encoder's but decode everywhere WebP does.
+
+// webpMaxSize is the largest width or height VP8L can describe.
+const webpMaxSize = 1 << 14
+
+// webpCodeLengthOrder is the order in which the lengths of the code length
+// code are written.
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// encodeWebP writes img to w as a lossless WebP file.
+func encodeWebP(w io.Writer, img image.Image) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > webpMaxSize || height > webpMaxSize {
+		return errors.New("webp: image dimensions out of range")
+	}
+
+	// The channels in the order they are coded: green, red, blue, alpha.
+	pixels := make([][4]uint8, 0, width*height)
+	var freq [4][256]int
+	opaque := true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			p := [4]uint8{c.G, c.R, c.B, c.A}
+			for i, v := range p {
+				freq[i][v]++
+			}
+			opaque = opaque && c.A == 0xff
+			pixels = append(pixels, p)
+		}
+	}
+
+	var bw webpBitWriter
+	bw.write(0x2f, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if opaque {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3) // version
+	bw.write(0, 1) // no transforms
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // a single group of prefix codes
+
+	var codes [4]webpPrefixCode
+	for i := range codes {
+		// Green shares its alphabet with 24 length prefixes, never used here.
+		alphabet := 256
+		if i == 0 {
+			alphabet += 24
+		}
+		f := make([]int, alphabet)
+		copy(f, freq[i][:])
+		codes[i] = bw.writePrefixCode(f)
+	}
+	// There are no distances: a simple code of the single symbol 0.
+	bw.write(0b0001, 4)
+	for _, p := range pixels {
+		for i, v := range p {
+			codes[i].write(&bw, int(v))
+		}
+	}
+	data := bw.flush()
+
+	chunk := len(data) + len(data)&1
+	header := make([]byte, 0, 20)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(4+8+chunk))
+	header = append(header, "WEBPVP8L"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(data)))
+	if len(data)&1 == 1 {
+		data = append(data, 0)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// webpBitWriter packs values least significant bit first.
+type webpBitWriter struct {
+	out   []byte
+	bits  uint64
+	nbits uint
+}
+
+// write appends the n low bits of v.
+func (bw *webpBitWriter) write(v uint32, n uint) {
+	bw.bits |= uint64(v) << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits >>= 8
+		bw.nbits -= 8
+	}
+}
+
+// flush returns the bytes written, padding the last one with zeros.
+func (bw *webpBitWriter) flush() []byte {
+	if bw.nbits > 0 {
+		bw.out = append(bw.out, byte(bw.bits))
+		bw.bits, bw.nbits = 0, 0
+	}
+	return bw.out
+}
+
+// webpPrefixCode is a canonical prefix code: the length and the bit
+// reversed code of each symbol, ready to be written least significant bit
+// first.
+type webpPrefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+// write writes the code of symbol.
+func (c *webpPrefixCode) write(bw *webpBitWriter, symbol int) {
+	bw.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// writePrefixCode writes a prefix code for symbols with the given
+// frequencies and returns it. A single symbol takes the simple form, whose
+// symbol is coded with zero bits; otherwise the code lengths are written
+// under a code of their own.
+func (bw *webpBitWriter) writePrefixCode(freq []int) webpPrefixCode {
+	used := -1
+	for s, f := range freq {
+		if f > 0 {
+			if used >= 0 {
+				used = -2
+				break
+			}
+			used = s
+		}
+	}
+	if used >= 0 {
+		bw.write(1, 1) // simple
+		bw.write(0, 1) // of one symbol
+		bw.write(1, 1) // of 8 bits
+		bw.write(uint32(used), 8)
+		return webpPrefixCode{lengths: make([]uint8, len(freq)), codes: make([]uint32, len(freq))}
+	}
+
+	code := newWebPPrefixCode(prefixCodeLengths(freq, 15))
+	var lengthFreq [19]int
+	for _, l := range code.lengths {
+		lengthFreq[l]++
+	}
+	lengthCode := newWebPPrefixCode(prefixCodeLengths(lengthFreq[:], 7))
+	bw.write(0, 1)  // normal
+	bw.write(15, 4) // all 19 code length code lengths follow
+	for _, s := range webpCodeLengthOrder {
+		bw.write(uint32(lengthCode.lengths[s]), 3)
+	}
+	bw.write(0, 1) // a length for every symbol of the alphabet
+	for _, l := range code.lengths {
+		lengthCode.write(bw, int(l))
+	}
+	return code
+}
+
+// newWebPPrefixCode assigns the canonical codes of the given lengths, as
+// in DEFLATE: shorter codes first, and codes of one length in symbol order.
+func newWebPPrefixCode(lengths []uint8) webpPrefixCode {
+	var count [16]uint32
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+		}
+	}
+	var next [16]uint32
+	for l, code := 1, uint32(0); l < 16; l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		code := next[l]
+		next[l]++
+		var rev uint32
+		for i := uint8(0); i < l; i++ {
+			rev = rev<<1 | code>>i&1
+		}
+		codes[s] = rev
+	}
+	return webpPrefixCode{lengths: lengths, codes: codes}
+}
+
+// prefixCodeLengths returns the lengths of a Huffman code for symbols with
+// the given frequencies, none longer than maxLength. Decoders require a
+// complete code, so a lone symbol is paired with another to make one of
+// two symbols of one bit each. Codes that come out too long are rebuilt
+// from flattened frequencies.
+func prefixCodeLengths(freq []int, maxLength uint8) []uint8 {
+	f := append([]int(nil), freq...)
+	var symbols []int
+	for s, n := range f {
+		if n > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	lengths := make([]uint8, len(f))
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		other := 0
+		if symbols[0] == 0 {
+			other = 1
+		}
+		lengths[symbols[0]], lengths[other] = 1, 1
+		return lengths
+	}
+	for {
+		h := make(huffmanHeap, 0, len(symbols))
+		for _, s := range symbols {
+			h = append(h, &huffmanNode{weight: f[s], symbol: s})
+		}
+		heap.Init(&h)
+		for h.Len() > 1 {
+			a, b := heap.Pop(&h).(*huffmanNode), heap.Pop(&h).(*huffmanNode)
+			heap.Push(&h, &huffmanNode{weight: a.weight + b.weight, symbol: -1, left: a, right: b})
+		}
+		longest := h[0].assign(lengths, 0)
+		if longest <= maxLength {
+			return lengths
+		}
+		for _, s := range symbols {
+			f[s] = (f[s] + 1) / 2
+		}
+	}
+}
+
+// huffmanNode is a symbol, or a subtree of symbols, of a Huffman code
+// under construction.
+type huffmanNode struct {
+	weight      int
+	symbol      int
+	left, right *huffmanNode
+}
+
+// assign sets the lengths of the symbols under n, at the given depth, and
+// returns the longest.
+func (n *huffmanNode) assign(lengths []uint8, depth uint8) uint8 {
+	if n.left == nil {
+		lengths[n.symbol] = depth
+		return depth
+	}
+	return max(n.left.assign(lengths, depth+1), n.right.assign(lengths, depth+1))
+}
+
+// huffmanHeap orders nodes by weight, and subtrees after symbols of equal
+// weight, keeping codes short.
+type huffmanHeap []*huffmanNode
+
+func (h huffmanHeap) Len() int { return len(h) }
+func (h huffmanHeap) Less(i, j int) bool {
+	if h[i].weight != h[j].weight {
+		return h[i].weight < h[j].weight
+	}
+	return h[i].symbol > h[j].symbol
+}
+func (h huffmanHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *huffmanHeap) Push(x interface{}) { *h = append(*h, x.(*huffmanNode)) }
+func (h *huffmanHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
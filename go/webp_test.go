@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// testWebPReader reads bits least significant first.
+type testWebPReader struct {
+	data []byte
+	pos  int // in bits
+}
+
+func (r *testWebPReader) read(n int) (int, error) {
+	v := 0
+	for i := 0; i < n; i++ {
+		if r.pos/8 >= len(r.data) {
+			return 0, errors.New("unexpected end of data")
+		}
+		v |= int(r.data[r.pos/8]>>(r.pos%8)&1) << i
+		r.pos++
+	}
+	return v, nil
+}
+
+// testWebPCode is a prefix code decoded bit by bit: the symbols by code
+// length and canonical code, or a single symbol coded with zero bits.
+type testWebPCode struct {
+	symbols map[[2]int]int
+	single  int
+}
+
+// newTestWebPCode builds the canonical code of lengths and checks that it
+// is complete, as decoders require.
+func newTestWebPCode(lengths []int) (*testWebPCode, error) {
+	c := &testWebPCode{symbols: map[[2]int]int{}, single: -1}
+	var used []int
+	for s, l := range lengths {
+		if l > 0 {
+			used = append(used, s)
+		}
+	}
+	switch len(used) {
+	case 0:
+		return nil, errors.New("empty code")
+	case 1:
+		c.single = used[0]
+		return c, nil
+	}
+	code, kraft := 0, 0.0
+	for l := 1; l <= 15; l++ {
+		for _, s := range used {
+			if lengths[s] == l {
+				c.symbols[[2]int{l, code}] = s
+				code++
+				kraft += 1 / float64(int(1)<<l)
+			}
+		}
+		code <<= 1
+	}
+	if kraft != 1 {
+		return nil, fmt.Errorf("incomplete code of lengths %v", lengths)
+	}
+	return c, nil
+}
+
+func (c *testWebPCode) decode(r *testWebPReader) (int, error) {
+	if c.single >= 0 {
+		return c.single, nil
+	}
+	code := 0
+	for l := 1; l <= 15; l++ {
+		b, err := r.read(1)
+		if err != nil {
+			return 0, err
+		}
+		code = code<<1 | b
+		if s, ok := c.symbols[[2]int{l, code}]; ok {
+			return s, nil
+		}
+	}
+	return 0, errors.New("invalid code")
+}
+
+// readTestWebPCode reads a prefix code of the given alphabet size.
+func readTestWebPCode(r *testWebPReader, alphabet int) (*testWebPCode, error) {
+	lengths := make([]int, alphabet)
+	simple, err := r.read(1)
+	if err != nil {
+		return nil, err
+	}
+	if simple == 1 {
+		n, _ := r.read(1)
+		first8, _ := r.read(1)
+		s, _ := r.read(1 + 7*first8)
+		lengths[s] = 1
+		if n == 1 {
+			s, err = r.read(8)
+			lengths[s] = 1
+		}
+		if err != nil {
+			return nil, err
+		}
+		return newTestWebPCode(lengths)
+	}
+
+	n, _ := r.read(4)
+	lengthLengths := make([]int, 19)
+	for i := 0; i < n+4; i++ {
+		lengthLengths[webpCodeLengthOrder[i]], err = r.read(3)
+	}
+	if err != nil {
+		return nil, err
+	}
+	lengthCode, err := newTestWebPCode(lengthLengths)
+	if err != nil {
+		return nil, fmt.Errorf("code length code: %w", err)
+	}
+	limit := alphabet
+	if bounded, _ := r.read(1); bounded == 1 {
+		nbits, _ := r.read(3)
+		limit, _ = r.read(2 + 2*nbits)
+		limit += 2
+	}
+	prev := 8
+	for s := 0; s < alphabet && limit > 0; limit-- {
+		sym, err := lengthCode.decode(r)
+		if err != nil {
+			return nil, err
+		}
+		value, repeat := sym, 1
+		switch sym {
+		case 16:
+			value = prev
+			repeat, _ = r.read(2)
+			repeat += 3
+		case 17:
+			value = 0
+			repeat, _ = r.read(3)
+			repeat += 3
+		case 18:
+			value = 0
+			repeat, _ = r.read(7)
+			repeat += 11
+		}
+		for ; repeat > 0 && s < alphabet; repeat-- {
+			lengths[s] = value
+			s++
+		}
+		if sym < 16 && sym != 0 {
+			prev = sym
+		}
+	}
+	return newTestWebPCode(lengths)
+}
+
+// decodeTestWebP decodes the lossless WebP files that encodeWebP writes,
+// following RFC 9649 but rejecting the features encodeWebP does not use.
+func decodeTestWebP(data []byte) (*image.NRGBA, error) {
+	if len(data) < 21 || string(data[:4]) != "RIFF" || string(data[8:16]) != "WEBPVP8L" {
+		return nil, errors.New("not a lossless WebP file")
+	}
+	if riff := binary.LittleEndian.Uint32(data[4:]); int(riff) != len(data)-8 {
+		return nil, fmt.Errorf("RIFF size %d, file of %d bytes", riff, len(data))
+	}
+	n := int(binary.LittleEndian.Uint32(data[16:]))
+	if 20+n > len(data) || len(data)-20-n > 1 {
+		return nil, fmt.Errorf("chunk size %d, file of %d bytes", n, len(data))
+	}
+	r := &testWebPReader{data: data[20 : 20+n]}
+	if sig, _ := r.read(8); sig != 0x2f {
+		return nil, errors.New("bad signature")
+	}
+	width, _ := r.read(14)
+	height, _ := r.read(14)
+	r.read(1) // alpha hint
+	version, _ := r.read(3)
+	transform, _ := r.read(1)
+	cache, _ := r.read(1)
+	meta, err := r.read(1)
+	if err != nil || version != 0 || transform != 0 || cache != 0 || meta != 0 {
+		return nil, errors.New("unsupported header")
+	}
+	var codes [5]*testWebPCode
+	for i, alphabet := range []int{280, 256, 256, 256, 40} {
+		if codes[i], err = readTestWebPCode(r, alphabet); err != nil {
+			return nil, fmt.Errorf("prefix code %d: %w", i, err)
+		}
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, width+1, height+1))
+	for i := 0; i < len(img.Pix); i += 4 {
+		var argb [4]int
+		for c := 0; c < 4; c++ {
+			if argb[c], err = codes[c].decode(r); err != nil {
+				return nil, err
+			}
+		}
+		if argb[0] >= 256 {
+			return nil, errors.New("backward reference")
+		}
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = uint8(argb[1]), uint8(argb[0]), uint8(argb[2]), uint8(argb[3])
+	}
+	if len(r.data)-r.pos/8 > 1 {
+		return nil, fmt.Errorf("%d bytes after the pixels", len(r.data)-r.pos/8-1)
+	}
+	return img, nil
+}
+
+// Test that encoded images decode to the same pixels
+func TestWebPRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		name          string
+		width, height int
+		pixel         func(x, y int) color.NRGBA
+	}{
+		{"single pixel", 1, 1, func(x, y int) color.NRGBA { return color.NRGBA{1, 2, 3, 255} }},
+		{"two colors", 7, 3, func(x, y int) color.NRGBA { return color.NRGBA{uint8(255 * (x % 2)), 0, 0, 255} }},
+		{"gradient", 256, 4, func(x, y int) color.NRGBA { return color.NRGBA{uint8(x), uint8(255 - x), uint8(x * y), uint8(x)} }},
+		{"skewed", 300, 200, func(x, y int) color.NRGBA {
+			// Geometric frequencies make codes longer than 15 bits.
+			v := uint8(0)
+			for v < 40 && rnd.Intn(2) == 0 {
+				v++
+			}
+			return color.NRGBA{v, uint8(rnd.Intn(256)), 7, 255}
+		}},
+	} {
+		src := image.NewNRGBA(image.Rect(0, 0, tc.width, tc.height))
+		for y := 0; y < tc.height; y++ {
+			for x := 0; x < tc.width; x++ {
+				src.SetNRGBA(x, y, tc.pixel(x, y))
+			}
+		}
+		var buf bytes.Buffer
+		if err := encodeWebP(&buf, src); err != nil {
+			t.Fatalf("%s: encodeWebP error: %v", tc.name, err)
+		}
+		got, err := decodeTestWebP(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s: decode error: %v", tc.name, err)
+			continue
+		}
+		if got.Bounds() != src.Bounds() || !bytes.Equal(got.Pix, src.Pix) {
+			t.Errorf("%s: decoded pixels differ", tc.name)
+		}
+	}
+
+	if err := encodeWebP(&bytes.Buffer{}, image.NewNRGBA(image.Rect(0, 0, webpMaxSize+1, 1))); err == nil {
+		t.Error("expected an image wider than WebP allows to be rejected")
+	}
+}
+
+// Test that code lengths stay within the limit and form complete codes
+func TestPrefixCodeLengths(t *testing.T) {
+	freq := make([]int, 30)
+	for i := range freq {
+		freq[i] = 1 << i
+	}
+	lengths := prefixCodeLengths(freq, 15)
+	ints := make([]int, len(lengths))
+	for i, l := range lengths {
+		if l > 15 {
+			t.Fatalf("length %d over the limit", l)
+		}
+		ints[i] = int(l)
+	}
+	if _, err := newTestWebPCode(ints); err != nil {
+		t.Error(err)
+	}
+	if lengths := prefixCodeLengths([]int{0, 0, 5}, 7); lengths[0] != 1 || lengths[2] != 1 {
+		t.Errorf("expected a lone symbol to be paired, got %v", lengths)
+	}
+}
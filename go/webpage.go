@@ -0,0 +1,461 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of read_webpage.
+const (
+	webpageTimeout     = 20 * time.Second
+	maxWebpageBytes    = 4 << 20
+	maxRobotsBytes     = 512 << 10
+	robotsCacheTTL     = time.Hour
+	maxRobotsCached    = 256
+	defaultWebpageSize = 20000 // characters of Markdown
+	maxWebpageSize     = 200000
+)
+
+// webpageUserAgent identifies read_webpage to servers and is the product
+// token it looks for in robots.txt.
+const webpageUserAgent = "mcp-minimal-server"
+
+// readWebpageTool fetches a page and returns its main content as Markdown.
+// It obeys robots.txt and, unless allowPrivate is set, connects only to
+// public addresses.
+type readWebpageTool struct {
+	client       *http.Client
+	allowPrivate bool
+
+	mu     sync.Mutex
+	robots map[string]robotsEntry // by scheme and host
+}
+
+// robotsEntry is a cached robots.txt.
+type robotsEntry struct {
+	rules     *robotsRules
+	fetchedAt time.Time
+}
+
+// newReadWebpageTool returns the read_webpage tool.
+func newReadWebpageTool() *readWebpageTool {
+	t := &readWebpageTool{robots: map[string]robotsEntry{}}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: t.checkAddress}
+	t.client = &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return t.checkRobots(req.Context(), req.URL)
+		},
+	}
+	return t
+}
+
+// checkAddress refuses connections to addresses that are not public.
+func (t *readWebpageTool) checkAddress(network, address string, c syscall.RawConn) error {
+	if t.allowPrivate {
+		return nil
+	}
+	return checkPublicAddress(network, address, c)
+}
+
+// nonPublicPrefixes are the special-purpose IPv4 ranges that net.IP has
+// no predicate for: "this network", shared address space for carrier-grade
+// NAT, IETF protocol assignments, and benchmarking.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+}
+
+// checkPublicAddress is a net.Dialer Control function that refuses
+// connections to loopback, private, link-local and other addresses that
+// are not public, so that tools fetching URLs the model chooses cannot
+// reach services on the server's own network.
+func checkPublicAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() ||
+		ip.IsMulticast() || ip.IsInterfaceLocalMulticast() {
+		return fmt.Errorf("refusing to connect to the non-public address %s", host)
+	}
+	addr, _ := netip.AddrFromSlice(ip)
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr.Unmap()) {
+			return fmt.Errorf("refusing to connect to the non-public address %s", host)
+		}
+	}
+	return nil
+}
+
+// readWebpageArgs are the arguments of the read_webpage tool.
+type readWebpageArgs struct {
+	URL       string `json:"url" description:"The http or https URL of the page"`
+	MaxLength int    `json:"max_length,omitempty" minimum:"100" maximum:"200000" description:"Most characters of Markdown to return (default 20000)"`
+}
+
+// Name returns the name of the read_webpage tool.
+func (t *readWebpageTool) Name() string {
+	return "read_webpage"
+}
+
+// Description returns a brief description of the read_webpage tool.
+func (t *readWebpageTool) Description() string {
+	return "Fetches a web page and returns its main content as Markdown, without navigation and other boilerplate, with its title and canonical URL"
+}
+
+// InputSchema returns the JSON schema for the read_webpage tool's input
+// parameters.
+func (t *readWebpageTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(readWebpageArgs{})
+}
+
+// Annotations marks the read_webpage tool as read-only. It reaches the
+// open web.
+func (t *readWebpageTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "Read web page", ReadOnlyHint: true}
+}
+
+// webpageMetadata describes a page read by read_webpage.
+type webpageMetadata struct {
+	URL         string `json:"url"` // after redirects
+	Title       string `json:"title,omitempty"`
+	Canonical   string `json:"canonicalURL,omitempty"`
+	Description string `json:"description,omitempty"`
+	Language    string `json:"language,omitempty"`
+	Truncated   bool   `json:"truncated,omitempty"`
+}
+
+// Execute reads the page without a deadline of its own.
+func (t *readWebpageTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext fetches the page, if robots.txt allows it, and returns
+// its metadata as JSON followed by its content as Markdown.
+func (t *readWebpageTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	a := readWebpageArgs{MaxLength: defaultWebpageSize}
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	if a.MaxLength < 100 || a.MaxLength > maxWebpageSize {
+		return nil, fmt.Errorf("invalid value for 'max_length'")
+	}
+	u, err := url.Parse(a.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid value for 'url': an http or https URL is required")
+	}
+	ctx, cancel := context.WithTimeout(ctx, webpageTimeout)
+	defer cancel()
+	if err := t.checkRobots(ctx, u); err != nil {
+		return nil, toolFailure("%v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", webpageUserAgent)
+	req.Header.Set("Accept", "text/html, application/xhtml+xml;q=0.9, text/plain;q=0.8")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, toolFailure("%s: %v", u.Host, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, toolFailure("%s: %s", u, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxWebpageBytes+1))
+	if err != nil {
+		return nil, toolFailure("%s: %v", u, err)
+	}
+	meta := webpageMetadata{URL: resp.Request.URL.String()}
+	if len(body) > maxWebpageBytes {
+		body, meta.Truncated = body[:maxWebpageBytes], true
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	text := decodeCharset(body, params["charset"])
+	var markdown string
+	switch mediaType {
+	case "text/html", "application/xhtml+xml", "":
+		markdown = webpageMarkdown(text, resp.Request.URL, &meta)
+	case "text/plain", "text/markdown":
+		markdown = strings.TrimSpace(text)
+	default:
+		return nil, toolFailure("%s: unsupported content type %s", u, mediaType)
+	}
+	if utf8.RuneCountInString(markdown) > a.MaxLength {
+		markdown = truncateRunes(markdown, a.MaxLength) + "\n\n[…]"
+		meta.Truncated = true
+	}
+
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(metaJSON)}, {Type: "text", Text: markdown}}, nil
+}
+
+// webpageMarkdown parses page, fills in meta from its head, and renders
+// its main content.
+func webpageMarkdown(page string, pageURL *url.URL, meta *webpageMetadata) string {
+	root := parseHTML(page)
+	base := pageURL
+	if b := root.find("base"); b != nil {
+		if u, err := pageURL.Parse(b.attr("href")); err == nil && b.attr("href") != "" {
+			base = u
+		}
+	}
+	if h := root.find("html"); h != nil {
+		meta.Language = h.attr("lang")
+	}
+	if title := root.find("title"); title != nil {
+		meta.Title = strings.TrimSpace(collapseSpace(title.textContent()))
+	}
+	for _, m := range root.findAll("meta", nil) {
+		key := strings.ToLower(m.attr("name") + m.attr("property"))
+		switch {
+		case key == "og:title" && meta.Title == "":
+			meta.Title = strings.TrimSpace(m.attr("content"))
+		case (key == "description" || key == "og:description") && meta.Description == "":
+			meta.Description = strings.TrimSpace(m.attr("content"))
+		}
+	}
+	for _, l := range root.findAll("link", nil) {
+		for _, rel := range strings.Fields(strings.ToLower(l.attr("rel"))) {
+			if rel == "canonical" && meta.Canonical == "" {
+				if u, err := base.Parse(l.attr("href")); err == nil {
+					meta.Canonical = u.String()
+				}
+			}
+		}
+	}
+	return htmlToMarkdown(mainContent(root), base)
+}
+
+// decodeCharset returns body as UTF-8. Only UTF-8 and the Latin-1 family
+// are decoded; other bytes not valid as UTF-8 become U+FFFD.
+func decodeCharset(body []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "us-ascii":
+		runes := make([]rune, len(body))
+		for i, b := range body {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return strings.ToValidUTF8(string(body), "�")
+}
+
+// truncateRunes returns the first n runes of s.
+func truncateRunes(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// checkRobots returns an error if the robots.txt of u's site disallows
+// fetching u.
+func (t *readWebpageTool) checkRobots(ctx context.Context, u *url.URL) error {
+	site := u.Scheme + "://" + u.Host
+	t.mu.Lock()
+	entry, ok := t.robots[site]
+	t.mu.Unlock()
+	if !ok || time.Since(entry.fetchedAt) > robotsCacheTTL {
+		rules, err := t.fetchRobots(ctx, site)
+		if err != nil {
+			return err
+		}
+		entry = robotsEntry{rules: rules, fetchedAt: time.Now()}
+		t.mu.Lock()
+		if len(t.robots) >= maxRobotsCached {
+			clear(t.robots)
+		}
+		t.robots[site] = entry
+		t.mu.Unlock()
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	if !entry.rules.allowed(path) {
+		return fmt.Errorf("%s disallows fetching %s in its robots.txt", u.Host, path)
+	}
+	return nil
+}
+
+// fetchRobots fetches and parses the robots.txt of site. As RFC 9309
+// asks, a missing file allows everything and an unreachable one, a server
+// error, disallows everything.
+func (t *readWebpageTool) fetchRobots(ctx context.Context, site string) (*robotsRules, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", webpageUserAgent)
+	resp, err := t.client.Transport.RoundTrip(req)
+	for redirects := 0; err == nil && redirects < 5 && resp.StatusCode >= 300 && resp.StatusCode < 400; redirects++ {
+		resp.Body.Close()
+		next, perr := req.URL.Parse(resp.Header.Get("Location"))
+		if perr != nil {
+			return nil, fmt.Errorf("robots.txt of %s: %v", site, perr)
+		}
+		req = req.Clone(ctx)
+		req.URL, req.Host = next, ""
+		resp, err = t.client.Transport.RoundTrip(req)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("robots.txt of %s: %v", site, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("robots.txt of %s is unavailable: %s", site, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return &robotsRules{}, nil
+	}
+	return parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), webpageUserAgent), nil
+}
+
+// robotsRules are the rules of a robots.txt that apply to one user agent.
+type robotsRules struct {
+	rules []robotsRule
+}
+
+// robotsRule allows or disallows the paths matching a pattern.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// parseRobots returns the rules of the robots.txt read from r for the
+// agent: those of the groups naming it, or if none does, of the groups for
+// "*". The agent is lowercase.
+func parseRobots(r io.Reader, agent string) *robotsRules {
+	var mine, any []robotsRule
+	found := false
+	var groupAgents []string
+	inRules := false
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents, inRules = nil, false
+			}
+			value = strings.ToLower(value)
+			groupAgents = append(groupAgents, value)
+			found = found || value != "" && value != "*" && strings.Contains(agent, value)
+		case "allow", "disallow":
+			inRules = true
+			if value == "" {
+				continue
+			}
+			rule := robotsRule{allow: key == "allow", pattern: value}
+			for _, a := range groupAgents {
+				switch {
+				case a == "*":
+					any = append(any, rule)
+				case a != "" && strings.Contains(agent, a):
+					mine = append(mine, rule)
+				}
+			}
+		}
+	}
+	if found {
+		return &robotsRules{rules: mine}
+	}
+	return &robotsRules{rules: any}
+}
+
+// allowed reports whether path, with its query, may be fetched: the
+// longest matching rule decides, and of equally long ones an allow rule.
+func (r *robotsRules) allowed(path string) bool {
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if len(rule.pattern) < best || !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if len(rule.pattern) > best || rule.allow {
+			best, allow = len(rule.pattern), rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsMatch reports whether path matches pattern, in which * matches
+// any characters and a final $ anchors the end.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, p := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, p)
+		}
+		j := strings.Index(rest, p)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(p):]
+	}
+	return !anchored || rest == ""
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestWebsite returns a server with a robots.txt and a few pages, and a
+// read_webpage tool allowed to reach it on the loopback interface.
+func newTestWebsite(t *testing.T) (*httptest.Server, *readWebpageTool) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != webpageUserAgent {
+			t.Errorf("unexpected user agent %q", r.Header.Get("User-Agent"))
+		}
+		fmt.Fprint(w, "User-agent: *\nDisallow: /\n\nUser-agent: mcp-minimal-server\nDisallow: /private\nAllow: /private/ok$\n")
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<!DOCTYPE html><html lang="en"><head><title>An &amp; article</title>
+			<meta name="description" content="About things">
+			<link rel="canonical" href="/articles/1"></head>
+			<body><header><nav><a href="/">Home</a></nav></header>
+			<article><h1>Things</h1><p>A <a href="/other">link</a>.</p></article>
+			<footer>Copyright</footer></body></html>`)
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/to-private", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/private/page", http.StatusFound)
+	})
+	mux.HandleFunc("/private/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, "plain text")
+	})
+	mux.HandleFunc("/long", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<p>"+strings.Repeat("word ", 1000)+"</p>")
+	})
+	mux.HandleFunc("/binary", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write([]byte{0, 1, 2})
+	})
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	tool := newReadWebpageTool()
+	tool.allowPrivate = true
+	return ts, tool
+}
+
+// Test that only public addresses may be connected to
+func TestCheckPublicAddress(t *testing.T) {
+	for _, tc := range []struct {
+		host   string
+		public bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"100.63.255.255", true},
+		{"198.20.0.1", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"::ffff:100.64.0.1", false},
+		{"::ffff:198.18.0.1", false},
+	} {
+		err := checkPublicAddress("tcp", net.JoinHostPort(tc.host, "80"), nil)
+		if public := err == nil; public != tc.public {
+			t.Errorf("%s: expected public %v, got error %v", tc.host, tc.public, err)
+		}
+	}
+}
+
+// Test reading a page's content and metadata
+func TestReadWebpage(t *testing.T) {
+	ts, tool := newTestWebsite(t)
+	content, err := tool.Execute(map[string]interface{}{"url": ts.URL + "/old"})
+	if err != nil || len(content) != 2 {
+		t.Fatalf("unexpected result %v, %v", content, err)
+	}
+	var meta webpageMetadata
+	if err := json.Unmarshal([]byte(content[0].Text), &meta); err != nil {
+		t.Fatal(err)
+	}
+	expected := webpageMetadata{URL: ts.URL + "/article", Title: "An & article", Canonical: ts.URL + "/articles/1", Description: "About things", Language: "en"}
+	if meta != expected {
+		t.Errorf("expected %+v, got %+v", expected, meta)
+	}
+	if md := fmt.Sprintf("# Things\n\nA [link](%s/other).", ts.URL); content[1].Text != md {
+		t.Errorf("expected %q, got %q", md, content[1].Text)
+	}
+
+	content, err = tool.Execute(map[string]interface{}{"url": ts.URL + "/private/ok"})
+	if err != nil || content[1].Text != "plain text" {
+		t.Errorf("expected the page allowed by robots.txt, got %v, %v", content, err)
+	}
+
+	content, err = tool.Execute(map[string]interface{}{"url": ts.URL + "/long", "max_length": 100})
+	if err != nil || !strings.HasSuffix(content[1].Text, "[…]") || !strings.Contains(content[0].Text, `"truncated":true`) {
+		t.Errorf("expected the page truncated, got %v, %v", content, err)
+	}
+}
+
+// Test the pages read_webpage refuses to read
+func TestReadWebpageRefusals(t *testing.T) {
+	ts, tool := newTestWebsite(t)
+	for _, tc := range []struct {
+		url, expected string
+	}{
+		{ts.URL + "/private/page", "disallows fetching /private/page"},
+		{ts.URL + "/to-private", "disallows fetching /private/page"},
+		{ts.URL + "/binary", "unsupported content type application/octet-stream"},
+		{ts.URL + "/missing", "404 Not Found"},
+	} {
+		var failure *toolResultError
+		_, err := tool.Execute(map[string]interface{}{"url": tc.url})
+		if !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, tc.expected) {
+			t.Errorf("%s: expected an error result with %q, got %v", tc.url, tc.expected, err)
+		}
+	}
+
+	for _, u := range []string{"file:///etc/passwd", "example.com", "ftp://example.com/"} {
+		if _, err := tool.Execute(map[string]interface{}{"url": u}); err == nil {
+			t.Errorf("%s: expected an error", u)
+		}
+	}
+
+	var failure *toolResultError
+	_, err := newReadWebpageTool().Execute(map[string]interface{}{"url": ts.URL + "/article"})
+	if !errors.As(err, &failure) || !strings.Contains(failure.content[0].Text, "non-public address") {
+		t.Errorf("expected loopback addresses refused, got %v", err)
+	}
+}
+
+// Test matching paths against robots.txt rules
+func TestRobotsRules(t *testing.T) {
+	robots := `# comment
+User-agent: OtherBot
+Disallow: /
+
+User-agent: *
+Disallow: /search
+Disallow: /*.pdf$
+Allow: /search/about
+Disallow: /tmp/
+`
+	rules := parseRobots(strings.NewReader(robots), webpageUserAgent)
+	for path, allowed := range map[string]bool{
+		"/":                 true,
+		"/search":           false,
+		"/search?q=x":       false,
+		"/search/about":     true,
+		"/files/a.pdf":      false,
+		"/files/a.pdf?x":    true,
+		"/tmp":              true,
+		"/tmp/a":            false,
+		"/searchable-index": false,
+	} {
+		if got := rules.allowed(path); got != allowed {
+			t.Errorf("%s: expected allowed %v, got %v", path, allowed, got)
+		}
+	}
+	if parseRobots(strings.NewReader("User-agent: mcp-minimal-server\nDisallow:\n\nUser-agent: *\nDisallow: /\n"), webpageUserAgent).allowed("/x") != true {
+		t.Error("expected the group naming the agent to override *")
+	}
+}
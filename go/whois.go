@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mcp-minimal-server-go/mcp"
+)
+
+// Limits of the whois tool.
+const (
+	whoisTimeout      = 15 * time.Second
+	maxRDAPSize       = 1 << 20
+	maxWhoisSize      = 256 << 10
+	rdapBootstrapTTL  = 24 * time.Hour
+	defaultRDAPBase   = "https://data.iana.org/rdap/"
+	defaultWhoisIANA  = "whois.iana.org:43"
+	maxWhoisReferrals = 2
+)
+
+// whoisTool looks up the registration of domains and IP addresses. It
+// asks the RDAP server that IANA's bootstrap registry names for the TLD or
+// address block and, for TLDs without RDAP, falls back to WHOIS, following
+// IANA's referral to the TLD's server.
+type whoisTool struct {
+	client        *http.Client
+	bootstrapBase string // of IANA's RDAP bootstrap files
+	ianaWhois     string // host:port
+	whoisPort     string // of the servers IANA refers to
+
+	mu        sync.Mutex
+	bootstrap map[string]*rdapBootstrap // by file: dns, ipv4, ipv6
+}
+
+// rdapBootstrap is an RDAP bootstrap file (RFC 9224).
+type rdapBootstrap struct {
+	Services  [][][]string `json:"services"` // entries, then URLs
+	fetchedAt time.Time
+}
+
+// newWhoisTool returns the whois tool.
+func newWhoisTool() *whoisTool {
+	return &whoisTool{
+		client:        &http.Client{Timeout: whoisTimeout},
+		bootstrapBase: defaultRDAPBase,
+		ianaWhois:     defaultWhoisIANA,
+		whoisPort:     "43",
+		bootstrap:     map[string]*rdapBootstrap{},
+	}
+}
+
+// whoisArgs are the arguments of the whois tool.
+type whoisArgs struct {
+	Query string `json:"query" description:"A domain name, such as example.com, or an IPv4 or IPv6 address"`
+}
+
+// Name returns the name of the whois tool.
+func (t *whoisTool) Name() string {
+	return "whois"
+}
+
+// Description returns a brief description of the whois tool.
+func (t *whoisTool) Description() string {
+	return "Looks up the registration of a domain (registrar, dates, status, name servers) or IP address (network, holder, country) with RDAP or WHOIS"
+}
+
+// InputSchema returns the JSON schema for the whois tool's input
+// parameters.
+func (t *whoisTool) InputSchema() map[string]interface{} {
+	return mcp.SchemaFor(whoisArgs{})
+}
+
+// Annotations marks the whois tool as read-only. It queries registries on
+// the internet.
+func (t *whoisTool) Annotations() ToolAnnotations {
+	return ToolAnnotations{Title: "WHOIS lookup", ReadOnlyHint: true, IdempotentHint: true}
+}
+
+// whoisResult is the normalized registration of a domain or address.
+// Dates are RFC 3339 where the source's format is known.
+type whoisResult struct {
+	Query       string   `json:"query"`
+	Type        string   `json:"type"`   // domain or ip
+	Source      string   `json:"source"` // the RDAP URL or WHOIS server asked
+	Name        string   `json:"name,omitempty"`
+	Handle      string   `json:"handle,omitempty"`
+	Registrar   string   `json:"registrar,omitempty"`
+	Registrant  string   `json:"registrant,omitempty"`
+	Created     string   `json:"created,omitempty"`
+	Updated     string   `json:"updated,omitempty"`
+	Expires     string   `json:"expires,omitempty"`
+	Status      []string `json:"status,omitempty"`
+	Nameservers []string `json:"nameservers,omitempty"`
+	Network     string   `json:"network,omitempty"` // first to last address, or CIDR
+	Country     string   `json:"country,omitempty"`
+}
+
+// Execute looks the query up without a deadline of its own.
+func (t *whoisTool) Execute(args map[string]interface{}) ([]ToolContent, error) {
+	return t.ExecuteContext(context.Background(), args)
+}
+
+// ExecuteContext looks the query up and returns the registration as
+// indented JSON text.
+func (t *whoisTool) ExecuteContext(ctx context.Context, args map[string]interface{}) ([]ToolContent, error) {
+	var a whoisArgs
+	if err := mcp.DecodeArgs(args, &a); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, whoisTimeout)
+	defer cancel()
+	query := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(a.Query)), ".")
+	var result *whoisResult
+	var err error
+	if ip, perr := netip.ParseAddr(query); perr == nil {
+		result, err = t.lookupIP(ctx, ip.Unmap().WithZone(""))
+	} else {
+		if err := checkDomainName(query); err != nil {
+			return nil, fmt.Errorf("invalid value for 'query': %v", err)
+		}
+		result, err = t.lookupDomain(ctx, query)
+	}
+	if err != nil {
+		if ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ctx.Err()
+		}
+		return nil, toolFailure("%s: %v", query, err)
+	}
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []ToolContent{{Type: "text", Text: string(encoded)}}, nil
+}
+
+// checkDomainName reports whether name is an ASCII domain name with at
+// least two labels.
+func checkDomainName(name string) error {
+	labels := strings.Split(name, ".")
+	if len(name) > 253 || len(labels) < 2 {
+		return fmt.Errorf("%q is not a domain name or IP address", name)
+	}
+	for _, l := range labels {
+		if l == "" || len(l) > 63 || l[0] == '-' || l[len(l)-1] == '-' {
+			return fmt.Errorf("%q is not a domain name or IP address", name)
+		}
+		for _, c := range l {
+			if c > 0x7f {
+				return fmt.Errorf("%q is not ASCII; use its xn-- form", name)
+			}
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("%q is not a domain name or IP address", name)
+			}
+		}
+	}
+	return nil
+}
+
+// lookupDomain asks the TLD's RDAP server about name, or its WHOIS server
+// if it has none.
+func (t *whoisTool) lookupDomain(ctx context.Context, name string) (*whoisResult, error) {
+	tld := name[strings.LastIndexByte(name, '.')+1:]
+	b, err := t.bootstrapFile(ctx, "dns")
+	if err != nil {
+		return nil, err
+	}
+	for _, service := range b.Services {
+		if len(service) < 2 {
+			continue
+		}
+		for _, entry := range service[0] {
+			if strings.EqualFold(entry, tld) {
+				return t.rdap(ctx, service[1], "domain/"+name, name, "domain")
+			}
+		}
+	}
+	return t.whois(ctx, name, tld)
+}
+
+// lookupIP asks the RDAP server of the registry that allocated the
+// address block of ip.
+func (t *whoisTool) lookupIP(ctx context.Context, ip netip.Addr) (*whoisResult, error) {
+	file := "ipv6"
+	if ip.Is4() {
+		file = "ipv4"
+	}
+	b, err := t.bootstrapFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	best := -1
+	for _, service := range b.Services {
+		if len(service) < 2 {
+			continue
+		}
+		for _, entry := range service[0] {
+			if p, err := netip.ParsePrefix(entry); err == nil && p.Contains(ip) && p.Bits() > best {
+				best, urls = p.Bits(), service[1]
+			}
+		}
+	}
+	if urls == nil {
+		return nil, errors.New("no registry serves this address")
+	}
+	return t.rdap(ctx, urls, "ip/"+ip.String(), ip.String(), "ip")
+}
+
+// bootstrapFile returns IANA's bootstrap file of the given kind, fetching
+// it at most once a day.
+func (t *whoisTool) bootstrapFile(ctx context.Context, kind string) (*rdapBootstrap, error) {
+	t.mu.Lock()
+	b := t.bootstrap[kind]
+	t.mu.Unlock()
+	if b != nil && time.Since(b.fetchedAt) < rdapBootstrapTTL {
+		return b, nil
+	}
+	var fresh rdapBootstrap
+	if err := t.getJSON(ctx, t.bootstrapBase+kind+".json", &fresh); err != nil {
+		if b != nil {
+			return b, nil // a stale registry beats none
+		}
+		return nil, fmt.Errorf("RDAP bootstrap: %v", err)
+	}
+	fresh.fetchedAt = time.Now()
+	t.mu.Lock()
+	t.bootstrap[kind] = &fresh
+	t.mu.Unlock()
+	return &fresh, nil
+}
+
+// getJSON fetches rawURL and decodes its JSON body into v.
+func (t *whoisTool) getJSON(ctx context.Context, rawURL string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/rdap+json, application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.New("not found")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRDAPSize))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// rdapObject is the part of an RDAP domain or IP network response the
+// whois tool reports.
+type rdapObject struct {
+	Handle       string       `json:"handle"`
+	Name         string       `json:"name"`
+	LDHName      string       `json:"ldhName"`
+	Status       []string     `json:"status"`
+	Country      string       `json:"country"`
+	StartAddress string       `json:"startAddress"`
+	EndAddress   string       `json:"endAddress"`
+	Events       []rdapEvent  `json:"events"`
+	Entities     []rdapEntity `json:"entities"`
+	Nameservers  []struct {
+		LDHName string `json:"ldhName"`
+	} `json:"nameservers"`
+	CIDRs []struct {
+		V4Prefix string `json:"v4prefix"`
+		V6Prefix string `json:"v6prefix"`
+		Length   int    `json:"length"`
+	} `json:"cidr0_cidrs"`
+}
+
+// rdapEvent is a dated event in the life of an RDAP object.
+type rdapEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+}
+
+// rdapEntity is a contact of an RDAP object.
+type rdapEntity struct {
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	Entities   []rdapEntity      `json:"entities"`
+}
+
+// fullName returns the fn property of the entity's jCard (RFC 7095).
+func (e *rdapEntity) fullName() string {
+	if len(e.VCardArray) < 2 {
+		return ""
+	}
+	var props [][]interface{}
+	if json.Unmarshal(e.VCardArray[1], &props) != nil {
+		return ""
+	}
+	for _, p := range props {
+		if len(p) >= 4 && p[0] == "fn" {
+			if s, ok := p[3].(string); ok {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// rdap asks the first of the RDAP servers that answers for path, and
+// normalizes the response.
+func (t *whoisTool) rdap(ctx context.Context, bases []string, path, query, kind string) (*whoisResult, error) {
+	// Prefer HTTPS servers, as RFC 9224 asks.
+	bases = append([]string(nil), bases...)
+	sort.SliceStable(bases, func(i, j int) bool {
+		return strings.HasPrefix(bases[i], "https:") && !strings.HasPrefix(bases[j], "https:")
+	})
+	var err error
+	for _, base := range bases {
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		var obj rdapObject
+		if err = t.getJSON(ctx, base+path, &obj); err != nil {
+			continue
+		}
+		r := &whoisResult{Query: query, Type: kind, Source: base + path, Handle: obj.Handle, Name: obj.Name, Country: obj.Country, Status: obj.Status}
+		if kind == "domain" && obj.LDHName != "" {
+			r.Name = strings.ToLower(obj.LDHName)
+		}
+		for _, e := range obj.Events {
+			switch e.Action {
+			case "registration":
+				r.Created = e.Date
+			case "last changed":
+				r.Updated = e.Date
+			case "expiration":
+				r.Expires = e.Date
+			}
+		}
+		for i := range obj.Entities {
+			for _, role := range obj.Entities[i].Roles {
+				switch role {
+				case "registrar":
+					r.Registrar = obj.Entities[i].fullName()
+				case "registrant":
+					r.Registrant = obj.Entities[i].fullName()
+				}
+			}
+		}
+		for _, ns := range obj.Nameservers {
+			r.Nameservers = append(r.Nameservers, strings.ToLower(ns.LDHName))
+		}
+		if len(obj.CIDRs) > 0 {
+			c := obj.CIDRs[0]
+			r.Network = fmt.Sprintf("%s%s/%d", c.V4Prefix, c.V6Prefix, c.Length)
+		}
+		if r.Network == "" && obj.StartAddress != "" {
+			r.Network = obj.StartAddress + " - " + obj.EndAddress
+		}
+		return r, nil
+	}
+	return nil, err
+}
+
+// whois asks the WHOIS server that IANA refers the TLD to about name.
+func (t *whoisTool) whois(ctx context.Context, name, tld string) (*whoisResult, error) {
+	server := t.ianaWhois
+	query := tld
+	r := &whoisResult{Query: name, Type: "domain"}
+	for i := 0; i <= maxWhoisReferrals; i++ {
+		response, err := whoisQuery(ctx, server, query)
+		if err != nil {
+			return nil, fmt.Errorf("WHOIS %s: %v", server, err)
+		}
+		fields := parseWhois(response)
+		if i == 0 {
+			refer := firstWhoisField(fields, "refer", "whois")
+			if refer == "" {
+				return nil, errors.New("no RDAP or WHOIS server is known for ." + tld)
+			}
+			server, query = net.JoinHostPort(refer, t.whoisPort), name
+			continue
+		}
+		r.Source = server
+		r.Name = name
+		r.Registrar = firstWhoisField(fields, "registrar", "sponsoring registrar", "registrar name")
+		r.Registrant = firstWhoisField(fields, "registrant organization", "registrant", "org")
+		r.Created = whoisDate(firstWhoisField(fields, "creation date", "created", "registered on", "registration time", "domain registration date"))
+		r.Updated = whoisDate(firstWhoisField(fields, "updated date", "last updated", "last modified", "changed", "last-update"))
+		r.Expires = whoisDate(firstWhoisField(fields, "registry expiry date", "registrar registration expiration date", "expiration date", "expiry date", "expires", "paid-till"))
+		for _, key := range []string{"domain status", "status"} {
+			for _, s := range fields[key] {
+				if f := strings.Fields(s); len(f) > 0 {
+					r.Status = append(r.Status, f[0])
+				}
+			}
+		}
+		for _, key := range []string{"name server", "nserver", "nameserver", "nameservers"} {
+			for _, s := range fields[key] {
+				if f := strings.Fields(s); len(f) > 0 {
+					r.Nameservers = append(r.Nameservers, strings.ToLower(strings.TrimSuffix(f[0], ".")))
+				}
+			}
+		}
+		// Thin registries refer to the registrar's server for the rest.
+		if refer := firstWhoisField(fields, "registrar whois server"); r.Registrar == "" && refer != "" && i < maxWhoisReferrals {
+			server = net.JoinHostPort(refer, t.whoisPort)
+			continue
+		}
+		if r.Registrar == "" && r.Created == "" && len(r.Nameservers) == 0 {
+			return nil, fmt.Errorf("%s has no registration for %s", server, name)
+		}
+		return r, nil
+	}
+	return r, nil
+}
+
+// whoisQuery sends query to a WHOIS server (RFC 3912) and returns its
+// response.
+func whoisQuery(ctx context.Context, server, query string) (string, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", server)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", err
+	}
+	data, err := io.ReadAll(io.LimitReader(conn, maxWhoisSize))
+	if err != nil && len(data) == 0 {
+		return "", err
+	}
+	return strings.ToValidUTF8(string(data), "�"), nil
+}
+
+// parseWhois returns the values of the "key: value" lines of a WHOIS
+// response by lowercase key, in order.
+func parseWhois(response string) map[string][]string {
+	fields := map[string][]string{}
+	sc := bufio.NewScanner(strings.NewReader(response))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '%' || line[0] == '#' || line[0] == '>' {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if value = strings.TrimSpace(value); !ok || value == "" || len(key) > 50 {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		fields[key] = append(fields[key], value)
+	}
+	return fields
+}
+
+// firstWhoisField returns the first value of the first key present.
+func firstWhoisField(fields map[string][]string, keys ...string) string {
+	for _, k := range keys {
+		if v := fields[k]; len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// whoisDateLayouts are the date formats common in WHOIS responses.
+var whoisDateLayouts = []string{
+	time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02",
+	"2006.01.02", "02-Jan-2006", "2006/01/02", "02.01.2006",
+}
+
+// whoisDate returns s as an RFC 3339 time if it has a known format, and
+// as it is otherwise.
+func whoisDate(s string) string {
+	for _, layout := range whoisDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return s
+}
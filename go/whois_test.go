@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry returns a whois tool whose bootstrap files, RDAP server,
+// and WHOIS servers are local: .com and 192.0.2.0/24 have RDAP, and .test
+// only WHOIS.
+func newTestRegistry(t *testing.T) *whoisTool {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rdap := srv.URL + "/rdap/"
+		switch r.URL.Path {
+		case "/dns.json":
+			fmt.Fprintf(w, `{"services": [[["net", "com"], ["http://127.0.0.1:1/", %q]]]}`, rdap)
+		case "/ipv4.json":
+			fmt.Fprintf(w, `{"services": [[["192.0.0.0/8"], ["http://127.0.0.1:1/"]], [["192.0.2.0/24"], [%q]]]}`, rdap)
+		case "/ipv6.json":
+			fmt.Fprint(w, `{"services": []}`)
+		case "/rdap/domain/example.com":
+			w.Header().Set("Content-Type", "application/rdap+json")
+			fmt.Fprint(w, `{
+				"objectClassName": "domain", "handle": "2336799_DOMAIN_COM-VRSN", "ldhName": "EXAMPLE.COM",
+				"status": ["client delete prohibited", "client transfer prohibited"],
+				"events": [
+					{"eventAction": "registration", "eventDate": "1995-08-14T04:00:00Z"},
+					{"eventAction": "expiration", "eventDate": "2026-08-13T04:00:00Z"},
+					{"eventAction": "last changed", "eventDate": "2025-08-14T07:01:39Z"}
+				],
+				"entities": [{"roles": ["registrar"], "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "RESERVED-Internet Assigned Numbers Authority"]]]}],
+				"nameservers": [{"ldhName": "A.IANA-SERVERS.NET"}, {"ldhName": "B.IANA-SERVERS.NET"}]
+			}`)
+		case "/rdap/ip/192.0.2.1":
+			fmt.Fprint(w, `{
+				"objectClassName": "ip network", "handle": "NET-192-0-2-0-1", "name": "TEST-NET-1", "country": "US",
+				"startAddress": "192.0.2.0", "endAddress": "192.0.2.255",
+				"cidr0_cidrs": [{"v4prefix": "192.0.2.0", "length": 24}],
+				"entities": [{"roles": ["registrant"], "vcardArray": ["vcard", [["fn", {}, "text", "Internet Assigned Numbers Authority"]]]}],
+				"events": [{"eventAction": "registration", "eventDate": "2010-01-06"}]
+			}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			query, _ := bufio.NewReader(conn).ReadString('\n')
+			switch strings.TrimSpace(query) {
+			case "test":
+				fmt.Fprint(conn, "% IANA WHOIS server\r\n\r\ndomain:       TEST\r\nrefer:        127.0.0.1\r\n")
+			case "example.test":
+				fmt.Fprint(conn, "Domain Name: EXAMPLE.TEST\r\nRegistrar: Example Registrar, Inc.\r\n"+
+					"Creation Date: 2001-02-03T04:05:06Z\r\nRegistry Expiry Date: 30-Jan-2030\r\n"+
+					"Domain Status: clientTransferProhibited https://icann.org/epp#clientTransferProhibited\r\n"+
+					"Name Server: NS1.EXAMPLE.TEST.\r\nName Server: NS2.EXAMPLE.TEST\r\n>>> Last update of WHOIS database: 2025-03-10T00:00:00Z <<<\r\n")
+			case "missing.test":
+				fmt.Fprint(conn, "No match for \"MISSING.TEST\".\r\n")
+			default:
+				fmt.Fprint(conn, "% This query returned 0 objects.\r\n")
+			}
+			conn.Close()
+		}
+	}()
+
+	tool := newWhoisTool()
+	tool.bootstrapBase = srv.URL + "/"
+	tool.ianaWhois = ln.Addr().String()
+	_, tool.whoisPort, _ = net.SplitHostPort(ln.Addr().String())
+	return tool
+}
+
+// Test normalizing RDAP and WHOIS registrations of domains and addresses
+func TestWhois(t *testing.T) {
+	tool := newTestRegistry(t)
+	for query, expected := range map[string]whoisResult{
+		"Example.COM.": {
+			Query: "example.com", Type: "domain", Name: "example.com", Handle: "2336799_DOMAIN_COM-VRSN",
+			Registrar: "RESERVED-Internet Assigned Numbers Authority",
+			Created:   "1995-08-14T04:00:00Z", Updated: "2025-08-14T07:01:39Z", Expires: "2026-08-13T04:00:00Z",
+			Status:      []string{"client delete prohibited", "client transfer prohibited"},
+			Nameservers: []string{"a.iana-servers.net", "b.iana-servers.net"},
+		},
+		"192.0.2.1": {
+			Query: "192.0.2.1", Type: "ip", Name: "TEST-NET-1", Handle: "NET-192-0-2-0-1",
+			Registrant: "Internet Assigned Numbers Authority", Created: "2010-01-06", Network: "192.0.2.0/24", Country: "US",
+		},
+		"example.test": {
+			Query: "example.test", Type: "domain", Name: "example.test", Registrar: "Example Registrar, Inc.",
+			Created: "2001-02-03T04:05:06Z", Expires: "2030-01-30T00:00:00Z",
+			Status:      []string{"clientTransferProhibited"},
+			Nameservers: []string{"ns1.example.test", "ns2.example.test"},
+		},
+	} {
+		content, err := tool.Execute(map[string]interface{}{"query": query})
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		var got whoisResult
+		if err := json.Unmarshal([]byte(content[0].Text), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Source == "" {
+			t.Errorf("%s: expected the source", query)
+		}
+		got.Source = ""
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %+v, got %+v", query, expected, got)
+		}
+	}
+}
+
+// Test queries that are invalid or that no registry knows
+func TestWhoisFailures(t *testing.T) {
+	tool := newTestRegistry(t)
+	for _, query := range []string{"localhost", "-bad.com", "exa mple.com", "bücher.de"} {
+		if _, err := tool.Execute(map[string]interface{}{"query": query}); err == nil || !strings.Contains(err.Error(), "invalid value for 'query'") {
+			t.Errorf("%s: expected an invalid value error, got %v", query, err)
+		}
+	}
+	for _, query := range []string{"missing.com", "missing.test", "example.nowhere", "2001:db8::1"} {
+		_, err := tool.Execute(map[string]interface{}{"query": query})
+		var failure *toolResultError
+		if !errors.As(err, &failure) {
+			t.Errorf("%s: expected a tool failure, got %v", query, err)
+		}
+	}
+}
+
+// Test parsing dates and fields of WHOIS responses
+func TestParseWhois(t *testing.T) {
+	fields := parseWhois("% comment\nnserver:  ns.example.de\nnserver: ns2.example.de\nChanged: 2024-01-02T03:04:05+01:00\nURL:\n")
+	if !reflect.DeepEqual(fields["nserver"], []string{"ns.example.de", "ns2.example.de"}) || fields["url"] != nil {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	for in, expected := range map[string]string{
+		"2024-01-02T03:04:05+01:00": "2024-01-02T02:04:05Z",
+		"2025.03.10":                "2025-03-10T00:00:00Z",
+		"before 1995":               "before 1995",
+	} {
+		if got := whoisDate(in); got != expected {
+			t.Errorf("%s: expected %s, got %s", in, expected, got)
+		}
+	}
+}
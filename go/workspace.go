@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace is a directory that file-oriented tools work in, such as
+// image_transform, parse_ics, and the files served as resources. It keeps
+// the paths they are given within the directory and away from the files
+// matching its ignore patterns, so that each tool does not check paths its
+// own way.
+type Workspace struct {
+	// Root is the absolute path of the directory. Without one, each call
+	// works in the first directory root of its client.
+	Root string
+	// Ignore holds path.Match patterns of files to leave out of walks and
+	// refuse, matched against the slash-separated path relative to the
+	// root and against each of its elements.
+	Ignore []string
+	// ReadOnly refuses the paths of files to write.
+	ReadOnly bool
+}
+
+// NewWorkspace returns the workspace of the directory root.
+func NewWorkspace(root string, ignore []string, readOnly bool) (*Workspace, error) {
+	if err := checkIgnorePatterns(ignore); err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stat(abs); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	return &Workspace{Root: abs, Ignore: ignore, ReadOnly: readOnly}, nil
+}
+
+// checkIgnorePatterns reports the first malformed pattern of ignore.
+func checkIgnorePatterns(ignore []string) error {
+	for _, p := range ignore {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// For returns the workspace of the call ctx belongs to: w itself if it has
+// a root, and otherwise the first root of the call's client that is a
+// local directory.
+func (w *Workspace) For(ctx context.Context) (*Workspace, error) {
+	if w.Root != "" {
+		return w, nil
+	}
+	sess := SessionFromContext(ctx)
+	if sess == nil {
+		return nil, ErrNoRoots
+	}
+	roots, err := sess.Roots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range roots {
+		dir, ok := localFilePath(r.URI)
+		if !ok {
+			continue
+		}
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return &Workspace{Root: dir, Ignore: w.Ignore, ReadOnly: w.ReadOnly}, nil
+		}
+	}
+	return nil, errors.New("none of the client's roots is a local directory")
+}
+
+// Resolve returns the path of the file name within the workspace,
+// following symbolic links, and fails if the file is not in it or is
+// ignored. The kind of directory is for errors.
+func (w *Workspace) Resolve(name, kind string) (string, error) {
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%s is not a path within the %s directory", name, kind)
+	}
+	if w.Ignored(filepath.ToSlash(name)) {
+		return "", fmt.Errorf("%s is ignored in the %s directory", name, kind)
+	}
+	root, err := filepath.EvalSymlinks(w.Root)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", toolFailure("%s: %v", name, errors.Unwrap(err))
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%s is not a path within the %s directory", name, kind)
+	}
+	if w.Ignored(filepath.ToSlash(rel)) {
+		return "", fmt.Errorf("%s is ignored in the %s directory", name, kind)
+	}
+	return resolved, nil
+}
+
+// ResolveWrite returns the path to write the file name to, which need not
+// exist yet, within a workspace that is not read-only. Its directory must
+// exist.
+func (w *Workspace) ResolveWrite(name, kind string) (string, error) {
+	if w.ReadOnly {
+		return "", fmt.Errorf("the %s directory is read-only", kind)
+	}
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("%s is not a path within the %s directory", name, kind)
+	}
+	if w.Ignored(filepath.ToSlash(name)) {
+		return "", fmt.Errorf("%s is ignored in the %s directory", name, kind)
+	}
+	dir, err := w.Resolve(filepath.Dir(name), kind)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, filepath.Base(name))
+	if _, err := os.Lstat(target); err == nil {
+		return w.Resolve(name, kind)
+	}
+	return target, nil
+}
+
+// Ignored reports whether the slash-separated path rel, relative to the
+// root, matches an ignore pattern.
+func (w *Workspace) Ignored(rel string) bool {
+	for _, p := range w.Ignore {
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+		for _, elem := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(p, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Walk calls fn with the path, the slash-separated path relative to the
+// root, and the information of each regular file in the workspace. Files
+// and directories whose names start with a dot are left out, as are the
+// ignored ones. Files that cannot be read are skipped.
+func (w *Workspace) Walk(fn func(path, rel string, info fs.FileInfo)) {
+	filepath.WalkDir(w.Root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == w.Root {
+			return nil
+		}
+		rel, _ := filepath.Rel(w.Root, p)
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(d.Name(), ".") || w.Ignored(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		fn(p, rel, info)
+		return nil
+	})
+}
+
+// localFilePath returns the path of a file:// URI on this host, and false
+// for other URIs.
+func localFilePath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Host != "" && u.Host != "localhost" || u.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
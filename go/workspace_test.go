@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"mcp-minimal-server-go/mcpmock"
+)
+
+// newTestWorkspace returns a workspace of a directory holding the given
+// files, and the directory.
+func newTestWorkspace(t *testing.T, ignore []string, readOnly bool, files ...string) (*Workspace, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ws, err := NewWorkspace(dir, ignore, readOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ws, dir
+}
+
+// Test that paths resolve only within the workspace and away from ignored
+// files
+func TestWorkspaceResolve(t *testing.T) {
+	ws, dir := newTestWorkspace(t, []string{".env", "*.key", "secrets/*"}, true,
+		"notes.md", "sub/a.txt", ".env", "sub/id.key", "secrets/token")
+	if err := os.Symlink(filepath.Join(dir, "sub", "id.key"), filepath.Join(dir, "key.txt")); err != nil {
+		t.Skip("symbolic links unavailable:", err)
+	}
+
+	if path, err := ws.Resolve("sub/a.txt", "test"); err != nil || path != filepath.Join(dir, "sub", "a.txt") {
+		t.Errorf("expected sub/a.txt to resolve, got %q, %v", path, err)
+	}
+	for name, want := range map[string]string{
+		"../notes.md":   "not a path within the test directory",
+		"/etc/passwd":   "not a path within the test directory",
+		".env":          "is ignored",
+		"sub/id.key":    "is ignored",
+		"secrets/token": "is ignored",
+		"key.txt":       "is ignored",
+	} {
+		if _, err := ws.Resolve(name, "test"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, want, err)
+		}
+	}
+	var failure *toolResultError
+	if _, err := ws.Resolve("missing.md", "test"); !errors.As(err, &failure) || strings.Contains(failure.content[0].Text, dir) {
+		t.Errorf("expected a missing file to fail without revealing the directory, got %v", err)
+	}
+
+	if _, err := NewWorkspace(dir, []string{"["}, true); err == nil {
+		t.Error("expected a malformed pattern to be rejected")
+	}
+	if _, err := NewWorkspace(filepath.Join(dir, "notes.md"), nil, true); err == nil {
+		t.Error("expected a file to be rejected as a workspace")
+	}
+}
+
+// Test that walks leave out dot files and ignored files
+func TestWorkspaceWalk(t *testing.T) {
+	ws, _ := newTestWorkspace(t, []string{"*.key", "build"}, true,
+		"notes.md", "sub/a.txt", ".env", ".git/config", "sub/id.key", "build/out.bin")
+	var got []string
+	ws.Walk(func(path, rel string, info os.FileInfo) {
+		got = append(got, rel)
+	})
+	if want := []string{"notes.md", "sub/a.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+// Test that read-only workspaces refuse writes, and that writable ones
+// keep them inside
+func TestWorkspaceResolveWrite(t *testing.T) {
+	ws, dir := newTestWorkspace(t, []string{"*.key"}, true, "notes.md")
+	if _, err := ws.ResolveWrite("new.md", "test"); err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("expected a read-only error, got %v", err)
+	}
+	ws.ReadOnly = false
+	if path, err := ws.ResolveWrite("new.md", "test"); err != nil || path != filepath.Join(dir, "new.md") {
+		t.Errorf("expected new.md to be writable, got %q, %v", path, err)
+	}
+	for _, name := range []string{"../new.md", "id.key", "missing/new.md"} {
+		if _, err := ws.ResolveWrite(name, "test"); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// Test that a workspace without a root works in the client's first local
+// directory root
+func TestWorkspaceFromRoots(t *testing.T) {
+	_, dir := newTestWorkspace(t, nil, true, "team.ics")
+	if err := os.WriteFile(filepath.Join(dir, "team.ics"), []byte(testCalendar), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tool := &parseICSTool{ws: &Workspace{ReadOnly: true}}
+	c := mcpmock.New(NewServer(WithTools(tool)).Serve,
+		mcpmock.Respond("roots/list", map[string]interface{}{"roots": []Root{
+			{URI: "https://example.com/repo"},
+			{URI: fileURI(filepath.Join(dir, "missing"))},
+			{URI: fileURI(dir)},
+		}}),
+	)
+	ctx := context.Background()
+	if _, err := c.Initialize(ctx); err != nil {
+		t.Fatal(err)
+	}
+	args := map[string]interface{}{"path": "team.ics", "start": "2025-03-10T00:00:00Z", "end": "2025-03-11T00:00:00Z"}
+	result, err := c.CallTool(ctx, "parse_ics", args)
+	if err != nil || result.IsError || !strings.Contains(result.Content[0].Text, "Standup") {
+		t.Errorf("expected the events of the file in the root, got %+v, %v", result, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := tool.Execute(args); err == nil || !strings.Contains(err.Error(), "no calendar directory") {
+		t.Errorf("expected an error outside a session, got %v", err)
+	}
+}
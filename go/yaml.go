@@ -0,0 +1,391 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yamlFloat matches the plain scalars read as floating-point numbers.
+var yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// yamlParser parses the subset of YAML that hand-written configuration
+// files use: block mappings and sequences, plain, quoted, and block
+// scalars, flow sequences of scalars, and the empty flow mapping {}.
+// Anchors, tags, other flow mappings, multi-line plain scalars, and
+// multiple documents are not supported.
+type yamlParser struct {
+	lines []string
+	pos   int // the current line
+}
+
+// parseYAML parses the YAML document data into maps, slices, strings,
+// bools, int64s, float64s, and nils, which encoding/json can marshal.
+func parseYAML(data string) (interface{}, error) {
+	p := &yamlParser{lines: strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")}
+	v, err := p.node(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.next() {
+		return nil, p.errorf("unexpected indentation")
+	}
+	return v, nil
+}
+
+// errorf returns an error for the current line.
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// next skips blank lines and comments and reports whether a line is left.
+func (p *yamlParser) next() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		if t := strings.TrimSpace(p.lines[p.pos]); t != "" && !strings.HasPrefix(t, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// indent returns the indentation of the current line, which YAML allows
+// only with spaces.
+func (p *yamlParser) indent() (int, string, error) {
+	line := p.lines[p.pos]
+	text := strings.TrimLeft(line, " ")
+	if strings.HasPrefix(text, "\t") {
+		return 0, "", p.errorf("tabs are not allowed for indentation")
+	}
+	return len(line) - len(text), strings.TrimSpace(text), nil
+}
+
+// node parses the block starting at the next line, if it is indented by
+// at least min spaces.
+func (p *yamlParser) node(min int) (interface{}, error) {
+	if !p.next() {
+		return nil, nil
+	}
+	indent, text, err := p.indent()
+	if err != nil || indent < min {
+		return nil, err
+	}
+	if isYAMLItem(text) {
+		return p.sequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(text); ok {
+		return p.mapping(indent)
+	}
+	p.pos++
+	return p.value(indent, text)
+}
+
+// mapping parses the keys indented by indent spaces.
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.next() {
+		i, text, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if i < indent {
+			break
+		}
+		key, value, ok := splitYAMLKey(text)
+		if i > indent || !ok {
+			return nil, p.errorf("expected a key at indentation %d", indent)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		p.pos++
+		if m[key], err = p.value(indent, value); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// sequence parses the items indented by indent spaces. It ends at a key
+// with the same indentation, since a sequence may be indented as far as
+// the key holding it.
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	s := []interface{}{}
+	for p.next() {
+		i, text, err := p.indent()
+		if err != nil {
+			return nil, err
+		}
+		if i < indent || i == indent && !isYAMLItem(text) {
+			break
+		}
+		if i > indent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		item := strings.TrimSpace(text[1:])
+		var v interface{}
+		if _, _, ok := splitYAMLKey(item); ok || isYAMLItem(item) {
+			// A mapping or sequence starting on the item's line is
+			// indented to where it starts: replace the dash by a space.
+			line := p.lines[p.pos]
+			p.lines[p.pos] = line[:i] + " " + line[i+1:]
+			v, err = p.node(indent + 1)
+		} else if item == "" {
+			p.pos++
+			v, err = p.node(indent + 1)
+		} else {
+			p.pos++
+			v, err = p.value(indent, item)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+// value parses the value text after a key or dash on a line indented by
+// indent spaces, continuing on the following lines if text is empty or
+// starts a block scalar.
+func (p *yamlParser) value(indent int, text string) (interface{}, error) {
+	if strings.HasPrefix(text, "#") {
+		text = ""
+	}
+	switch {
+	case text == "":
+		if p.next() {
+			i, t, err := p.indent()
+			if err != nil {
+				return nil, err
+			}
+			if i == indent && isYAMLItem(t) {
+				return p.sequence(indent)
+			}
+		}
+		return p.node(indent + 1)
+	case text[0] == '|' || text[0] == '>':
+		return p.blockScalar(indent, text)
+	}
+	v, err := yamlScalar(text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %v", p.pos, err)
+	}
+	return v, nil
+}
+
+// blockScalar parses the literal (|) or folded (>) scalar with the given
+// header, made of the following lines indented by more than indent
+// spaces.
+func (p *yamlParser) blockScalar(indent int, header string) (interface{}, error) {
+	header, _, _ = strings.Cut(header, " #")
+	header = strings.TrimSpace(header)
+	chomp := header[1:]
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return nil, fmt.Errorf("line %d: unsupported block scalar header %q", p.pos, header)
+	}
+	var lines []string
+	blockIndent := -1
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		i := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent < 0 {
+			if i <= indent {
+				break
+			}
+			blockIndent = i
+		}
+		if i < blockIndent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+	}
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	var b strings.Builder
+	for i, l := range lines {
+		switch {
+		case i == 0:
+		case header[0] == '|':
+			b.WriteByte('\n')
+		case l == "":
+			// A blank line folds to a line break; the one before it is
+			// dropped.
+		case lines[i-1] == "":
+		case l[0] == ' ' || lines[i-1][0] == ' ':
+			// More indented lines keep their line breaks.
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(' ')
+		}
+		if l == "" && header[0] == '>' {
+			b.WriteByte('\n')
+		}
+		b.WriteString(l)
+	}
+	switch chomp {
+	case "":
+		b.WriteByte('\n')
+	case "+":
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	}
+	return b.String(), nil
+}
+
+// isYAMLItem reports whether text is a sequence item.
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits the line text into key and value, and reports
+// whether it is a key at all.
+func splitYAMLKey(text string) (key, value string, ok bool) {
+	if text == "" || strings.ContainsRune("[{#|>&*!%@`", rune(text[0])) || isYAMLItem(text) {
+		return "", "", false
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		k, rest, err := yamlQuoted(text)
+		if err != nil || !strings.HasPrefix(rest, ":") || len(rest) > 1 && rest[1] != ' ' {
+			return "", "", false
+		}
+		return k, strings.TrimSpace(rest[1:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+		if text[i] == ' ' && i+1 < len(text) && text[i+1] == '#' {
+			break
+		}
+	}
+	return "", "", false
+}
+
+// yamlScalar parses a scalar, a flow sequence of scalars, or an empty flow
+// mapping on one line.
+func yamlScalar(text string) (interface{}, error) {
+	switch text[0] {
+	case '"', '\'':
+		s, rest, err := yamlQuoted(text)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("unexpected %q after a quoted string", rest)
+		}
+		return s, nil
+	case '[':
+		return yamlFlowSequence(text)
+	case '{':
+		if rest := strings.TrimSpace(strings.TrimPrefix(text, "{")); strings.HasPrefix(rest, "}") {
+			if tail := strings.TrimSpace(rest[1:]); tail == "" || strings.HasPrefix(tail, "#") {
+				return map[string]interface{}{}, nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported YAML syntax %q", text)
+	case '&', '*', '!', '%', '@', '`':
+		return nil, fmt.Errorf("unsupported YAML syntax %q", text)
+	}
+	if i := strings.Index(text, " #"); i >= 0 {
+		text = strings.TrimSpace(text[:i])
+	}
+	switch text {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~", "":
+		return nil, nil
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	if yamlFloat.MatchString(text) {
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f, nil
+		}
+	}
+	return text, nil
+}
+
+// yamlQuoted parses the quoted string at the start of text and returns it
+// with the rest of text after it, trimmed.
+func yamlQuoted(text string) (string, string, error) {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case q == '\'' && text[i] == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			i++
+		case text[i] == q:
+			rest := strings.TrimSpace(text[i+1:])
+			if q == '\'' {
+				return strings.ReplaceAll(text[1:i], "''", "'"), rest, nil
+			}
+			s, err := strconv.Unquote(text[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid quoted string %s", text[:i+1])
+			}
+			return s, rest, nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted string %s", text)
+}
+
+// yamlFlowSequence parses a flow sequence of scalars, such as [a, "b"].
+func yamlFlowSequence(text string) (interface{}, error) {
+	s := []interface{}{}
+	rest := strings.TrimSpace(text[1:])
+	for {
+		if strings.HasPrefix(rest, "]") {
+			if tail := strings.TrimSpace(rest[1:]); tail != "" && !strings.HasPrefix(tail, "#") {
+				return nil, fmt.Errorf("unexpected %q after a flow sequence", tail)
+			}
+			return s, nil
+		}
+		var item string
+		if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+			q, after, err := yamlQuoted(rest)
+			if err != nil {
+				return nil, err
+			}
+			s, rest = append(s, q), after
+		} else {
+			end := strings.IndexAny(rest, ",]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated flow sequence %s", text)
+			}
+			item, rest = strings.TrimSpace(rest[:end]), rest[end:]
+			if strings.ContainsAny(item, "[{") {
+				return nil, fmt.Errorf("unsupported YAML syntax %q", text)
+			}
+			if item == "" && rest[0] == ',' {
+				return nil, fmt.Errorf("empty item in flow sequence %s", text)
+			}
+			if item != "" {
+				v, err := yamlScalar(item)
+				if err != nil {
+					return nil, err
+				}
+				s = append(s, v)
+			}
+		}
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, fmt.Errorf("expected , or ] in flow sequence %s", text)
+		}
+	}
+}
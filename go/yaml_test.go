@@ -0,0 +1,84 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Test parsing the supported subset of YAML
+func TestParseYAML(t *testing.T) {
+	doc := `# A prompt
+name: review   # the name
+description: "Review: a \"diff\""
+count: 3
+ratio: 0.5
+enabled: true
+missing: ~
+tags: [go, 'a, b', "c"]
+arguments:
+  - name: code
+    required: true
+  -   name: style
+      description: 'It''s optional'
+steps:
+- one
+- - nested
+  - list
+template: |
+  Line one
+    indented
+
+  Line three
+folded: >-
+  a
+  b
+
+  c
+empty:
+preferences: {}  # as kubectl writes them
+`
+	v, err := parseYAML(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":        "review",
+		"description": `Review: a "diff"`,
+		"count":       int64(3),
+		"ratio":       0.5,
+		"enabled":     true,
+		"missing":     nil,
+		"tags":        []interface{}{"go", "a, b", "c"},
+		"arguments": []interface{}{
+			map[string]interface{}{"name": "code", "required": true},
+			map[string]interface{}{"name": "style", "description": "It's optional"},
+		},
+		"steps":       []interface{}{"one", []interface{}{"nested", "list"}},
+		"template":    "Line one\n  indented\n\nLine three\n",
+		"folded":      "a b\nc",
+		"empty":       nil,
+		"preferences": map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expected, v)
+	}
+
+	for doc, want := range map[string]string{
+		"a: 1\na: 2":           "line 2: duplicate key",
+		"a:\n\tb: 1":           "tabs are not allowed",
+		"a: 1\n  b: 2":         "line 2: expected a key",
+		"a: {b: 1}":            "unsupported YAML syntax",
+		"a: \"open":            "unterminated quoted string",
+		"a: [1, 2":             "unterminated flow sequence",
+		"- a\nb: 1":            "line 2: unexpected indentation",
+		"a: |2\n  x":           "unsupported block scalar header",
+		"a: 'quoted' trailing": "after a quoted string",
+		"list:\n- a\n  - b\n":  "line 3: unexpected indentation",
+		"a: [b, , c]":          "empty item",
+	} {
+		if _, err := parseYAML(doc); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: expected an error containing %q, got %v", doc, want, err)
+		}
+	}
+}